@@ -1,14 +1,37 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
 	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
+	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-merkletree"
 )
 
+// StateProofProvider generates merkle proofs of account leaves against the
+// state root of a past batch, from the StateDB checkpoints
+type StateProofProvider interface {
+	MTGetProofAtBatch(batchNum common.BatchNum, idx common.Idx) (*merkletree.CircomVerifierProof, error)
+}
+
+// SetStateProofProvider enables the account state proof endpoint, serving
+// merkle proofs generated by the given provider.  Until this method is
+// called, that endpoint rejects all requests.
+func (a *API) SetStateProofProvider(stateProofs StateProofProvider) {
+	a.stateProofs = stateProofs
+}
+
 func (a *API) getAccount(c *gin.Context) {
 	// Get Addr
 	idx, err := parsers.ParseAccountFilter(c)
@@ -25,10 +48,123 @@ func (a *API) getAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, apiAccount)
 }
 
+func (a *API) getAccountPending(c *gin.Context) {
+	// Get Idx
+	idx, err := parsers.ParseAccountFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Get the on-chain state of the account
+	apiAccount, err := a.h.GetAccountAPI(*idx)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Get the pending pool txs sent from the account, ordered by nonce
+	poolTxs, err := a.l2.GetPendingTxsByFromIdxAPI(*idx)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Project the account state after applying the pool txs, following the
+	// same rules used by the TxSelector: txs are applied in nonce order,
+	// stale nonces are skipped, and the projection stops at the first nonce
+	// gap as the txs after it are queued
+	pendingNonce := apiAccount.Nonce
+	pendingBalance, ok := new(big.Int).SetString(string(*apiAccount.Balance), 10)
+	if !ok {
+		retSQLErr(fmt.Errorf("invalid stored balance: %s", *apiAccount.Balance), c)
+		return
+	}
+	appliedTxs := 0
+	for i := range poolTxs {
+		if poolTxs[i].Nonce < pendingNonce {
+			continue
+		}
+		if poolTxs[i].Nonce > pendingNonce {
+			break
+		}
+		feeAmount, err := common.CalcFeeAmount(poolTxs[i].Amount, poolTxs[i].Fee)
+		if err != nil {
+			retSQLErr(err, c)
+			return
+		}
+		cost := new(big.Int).Add(poolTxs[i].Amount, feeAmount)
+		if cost.Cmp(pendingBalance) == 1 {
+			// the tx doesn't fit the projected balance, but another
+			// pool tx with the same nonce may
+			continue
+		}
+		pendingBalance.Sub(pendingBalance, cost)
+		pendingNonce++
+		appliedTxs++
+	}
+	// Build successful response
+	type accountPendingResponse struct {
+		AccountIndex   apitypes.HezIdx     `json:"accountIndex"`
+		Nonce          common.Nonce        `json:"nonce"`
+		Balance        *apitypes.BigIntStr `json:"balance"`
+		PendingNonce   common.Nonce        `json:"pendingNonce"`
+		PendingBalance *apitypes.BigIntStr `json:"pendingBalance"`
+		PendingTxs     int                 `json:"pendingTxs"`
+		AppliedTxs     int                 `json:"appliedTxs"`
+	}
+	c.JSON(http.StatusOK, &accountPendingResponse{
+		AccountIndex:   apiAccount.Idx,
+		Nonce:          apiAccount.Nonce,
+		Balance:        apiAccount.Balance,
+		PendingNonce:   pendingNonce,
+		PendingBalance: apitypes.NewBigIntStr(pendingBalance),
+		PendingTxs:     len(poolTxs),
+		AppliedTxs:     appliedTxs,
+	})
+}
+
+func (a *API) getAccountProof(c *gin.Context) {
+	if a.stateProofs == nil {
+		retBadReq(&apiError{Err: errors.New(ErrStateProofsNotEnabled), Code: ErrStateProofsNotEnabledCode, Type: ErrStateProofsNotEnabledType}, c)
+		return
+	}
+	// Get Idx
+	idx, err := parsers.ParseAccountFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Get batchNum
+	var filter struct {
+		BatchNum uint32 `form:"batchNum" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	batchNum := common.BatchNum(filter.BatchNum)
+	proof, err := a.stateProofs.MTGetProofAtBatch(batchNum, *idx)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Build successful response
+	type accountProofResponse struct {
+		AccountIndex string                          `json:"accountIndex"`
+		BatchNum     common.BatchNum                 `json:"batchNum"`
+		Proof        *merkletree.CircomVerifierProof `json:"proof"`
+	}
+	c.JSON(http.StatusOK, &accountProofResponse{
+		AccountIndex: c.Param("accountIndex"),
+		BatchNum:     batchNum,
+		Proof:        proof,
+	})
+}
+
 func (a *API) getAccounts(c *gin.Context) {
 	for id := range c.Request.URL.Query() {
 		if id != "tokenIds" && id != "hezEthereumAddress" && id != "BJJ" &&
-			id != "fromItem" && id != "order" && id != "limit" {
+			id != "minBalance" && id != "maxBalance" && id != "sortBy" &&
+			id != "fromItem" && id != "order" && id != "limit" &&
+			id != "fields" {
 			retBadReq(fmt.Errorf("invalid Param: %s", id), c)
 			return
 		}
@@ -51,9 +187,166 @@ func (a *API) getAccounts(c *gin.Context) {
 	type accountResponse struct {
 		Accounts     []historydb.AccountAPI `json:"accounts"`
 		PendingItems uint64                 `json:"pendingItems"`
+		NextCursor   string                 `json:"nextCursor,omitempty"`
 	}
-	c.JSON(http.StatusOK, &accountResponse{
+	retJSONWithFields(c, &accountResponse{
 		Accounts:     apiAccounts,
 		PendingItems: pendingItems,
-	})
+		NextCursor:   nextCursor(c, apiAccounts, pendingItems),
+	}, "accounts")
+}
+
+// maxResolveIdentifiers is the maximum number of identifiers accepted by a
+// single accounts resolve request
+const maxResolveIdentifiers = 100
+
+// parseAccountIdentifier classifies a mixed account identifier into an
+// account idx (either a plain number or hez:<tokenSymbol>:<idx>), a hez
+// ethereum address or a BJJ public key
+func parseAccountIdentifier(identifier string) (*common.Idx,
+	*ethCommon.Address, *babyjub.PublicKeyComp, error) {
+	if !strings.HasPrefix(identifier, "hez:") {
+		idxInt, err := strconv.Atoi(identifier)
+		if err != nil {
+			return nil, nil, nil, tracerr.Wrap(fmt.Errorf(
+				"invalid identifier %q: must be an account idx, "+
+					"hez:<tokenSymbol>:<idx>, a hez ethereum address or a BJJ "+
+					"public key", identifier))
+		}
+		idx := common.Idx(idxInt)
+		return &idx, nil, nil, nil
+	}
+	if strings.Count(identifier, ":") == 2 {
+		idx, err := common.StringToIdx(identifier, "identifier")
+		if err != nil {
+			return nil, nil, nil, tracerr.Wrap(err)
+		}
+		return idx, nil, nil, nil
+	}
+	if strings.HasPrefix(identifier, "hez:0x") {
+		addr, err := common.HezStringToEthAddr(identifier, "identifier")
+		if err != nil {
+			return nil, nil, nil, tracerr.Wrap(err)
+		}
+		return nil, addr, nil, nil
+	}
+	bjj, err := common.HezStringToBJJ(identifier, "identifier")
+	if err != nil {
+		return nil, nil, nil, tracerr.Wrap(err)
+	}
+	return nil, nil, bjj, nil
+}
+
+// hezIdxToIdx extracts the numeric account idx from a hez idx
+// (hez:<tokenSymbol>:<idx>)
+func hezIdxToIdx(hezIdx apitypes.HezIdx) (common.Idx, error) {
+	splitted := strings.Split(string(hezIdx), ":")
+	idxInt, err := strconv.Atoi(splitted[len(splitted)-1])
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return common.Idx(idxInt), nil
+}
+
+func (a *API) postAccountsResolve(c *gin.Context) {
+	// Parse body
+	var req struct {
+		Identifiers []string `json:"identifiers" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if len(req.Identifiers) == 0 {
+		retBadReq(errors.New("identifiers is empty"), c)
+		return
+	}
+	if len(req.Identifiers) > maxResolveIdentifiers {
+		retBadReq(fmt.Errorf("too many identifiers: %v, maximum is %v",
+			len(req.Identifiers), maxResolveIdentifiers), c)
+		return
+	}
+	// Classify the identifiers, deduplicating the DB filters
+	idxSet := map[common.Idx]bool{}
+	addrSet := map[ethCommon.Address]bool{}
+	bjjSet := map[babyjub.PublicKeyComp]bool{}
+	for _, identifier := range req.Identifiers {
+		idx, addr, bjj, err := parseAccountIdentifier(identifier)
+		if err != nil {
+			retBadReq(err, c)
+			return
+		}
+		if idx != nil {
+			idxSet[*idx] = true
+		} else if addr != nil {
+			addrSet[*addr] = true
+		} else {
+			bjjSet[*bjj] = true
+		}
+	}
+	idxs := make([]common.Idx, 0, len(idxSet))
+	for idx := range idxSet {
+		idxs = append(idxs, idx)
+	}
+	addrs := make([]ethCommon.Address, 0, len(addrSet))
+	for addr := range addrSet {
+		addrs = append(addrs, addr)
+	}
+	bjjs := make([]babyjub.PublicKeyComp, 0, len(bjjSet))
+	for bjj := range bjjSet {
+		bjjs = append(bjjs, bjj)
+	}
+	// Fetch all the matching accounts from historyDB with a single query
+	apiAccounts, err := a.h.GetAccountsByIdentifiersAPI(idxs, addrs, bjjs)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Index the accounts by each identifier kind
+	byIdx := map[common.Idx]historydb.AccountAPI{}
+	byAddr := map[apitypes.HezEthAddr][]historydb.AccountAPI{}
+	byBjj := map[apitypes.HezBJJ][]historydb.AccountAPI{}
+	for i := range apiAccounts {
+		account := apiAccounts[i]
+		idx, err := hezIdxToIdx(account.Idx)
+		if err != nil {
+			retSQLErr(err, c)
+			return
+		}
+		byIdx[idx] = account
+		byAddr[account.EthAddr] = append(byAddr[account.EthAddr], account)
+		byBjj[account.PublicKey] = append(byBjj[account.PublicKey], account)
+	}
+	// Build the response preserving the order of the request identifiers
+	type resolvedAccounts struct {
+		Identifier string                 `json:"identifier"`
+		Accounts   []historydb.AccountAPI `json:"accounts"`
+	}
+	resolved := make([]resolvedAccounts, len(req.Identifiers))
+	for i, identifier := range req.Identifiers {
+		// Identifiers were already validated above
+		idx, addr, bjj, _ := parseAccountIdentifier(identifier)
+		accounts := []historydb.AccountAPI{}
+		if idx != nil {
+			if account, ok := byIdx[*idx]; ok {
+				accounts = append(accounts, account)
+			}
+		} else if addr != nil {
+			if accs, ok := byAddr[apitypes.NewHezEthAddr(*addr)]; ok {
+				accounts = accs
+			}
+		} else {
+			if accs, ok := byBjj[apitypes.NewHezBJJ(*bjj)]; ok {
+				accounts = accs
+			}
+		}
+		resolved[i] = resolvedAccounts{
+			Identifier: identifier,
+			Accounts:   accounts,
+		}
+	}
+	type resolveResponse struct {
+		Resolved []resolvedAccounts `json:"resolved"`
+	}
+	c.JSON(http.StatusOK, &resolveResponse{Resolved: resolved})
 }
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/api/parsers"
 	"github.com/arnaubennassar/hermez-node/common"
 	ethCommon "github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
 	"github.com/iden3/go-iden3-crypto/babyjub"
 )
@@ -57,6 +60,75 @@ func (a *API) getAccountCreationAuth(c *gin.Context) {
 	c.JSON(http.StatusOK, auth)
 }
 
+// verifyAccountCreationAuth recomputes the EIP-712 digest of an account
+// creation authorization message for the configured chainID and contract
+// address, and returns the recovered address along with the validity of the
+// signature and the reason why it is invalid, to help debugging signatures
+// generated outside the node
+func (a *API) verifyAccountCreationAuth(c *gin.Context) {
+	// Parse body
+	var apiAuth receivedAuth
+	if err := c.ShouldBindJSON(&apiAuth); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	commonAuth := accountCreationAuthAPIToCommon(&apiAuth)
+	type verifyResponse struct {
+		Valid            bool                 `json:"valid"`
+		HashToSign       string               `json:"hashToSign,omitempty"`
+		RecoveredAddress *apitypes.HezEthAddr `json:"recoveredAddress,omitempty"`
+		Reason           string               `json:"reason,omitempty"`
+	}
+	ret := func(resp verifyResponse) {
+		c.JSON(http.StatusOK, &resp)
+	}
+	hash, err := commonAuth.HashToSign(a.cg.ChainID, a.hermezAddress)
+	if err != nil {
+		ret(verifyResponse{Reason: fmt.Sprintf("cannot compute the EIP-712 digest: %v", err)})
+		return
+	}
+	resp := verifyResponse{HashToSign: "0x" + hex.EncodeToString(hash)}
+	if len(commonAuth.Signature) != 65 {
+		resp.Reason = fmt.Sprintf("signature must be 65 bytes long, but it is %v bytes long",
+			len(commonAuth.Signature))
+		ret(resp)
+		return
+	}
+	var sig [65]byte
+	copy(sig[:], commonAuth.Signature)
+	if sig[64] != 27 && sig[64] != 28 {
+		resp.Reason = fmt.Sprintf("signature recovery id (byte 65) must be 27 or 28, "+
+			"but it is %v", sig[64])
+		ret(resp)
+		return
+	}
+	sig[64] -= 27
+	pubKBytes, err := ethCrypto.Ecrecover(hash, sig[:])
+	if err != nil {
+		resp.Reason = fmt.Sprintf("cannot recover the public key from the signature: %v", err)
+		ret(resp)
+		return
+	}
+	pubK, err := ethCrypto.UnmarshalPubkey(pubKBytes)
+	if err != nil {
+		resp.Reason = fmt.Sprintf("cannot unmarshal the recovered public key: %v", err)
+		ret(resp)
+		return
+	}
+	recoveredAddr := apitypes.NewHezEthAddr(ethCrypto.PubkeyToAddress(*pubK))
+	resp.RecoveredAddress = &recoveredAddr
+	if recoveredAddr != apitypes.NewHezEthAddr(commonAuth.EthAddr) {
+		resp.Reason = "the recovered address doesn't match hezEthereumAddress: the " +
+			"message was signed by another account, or the signature doesn't " +
+			"follow the AccountCreationAuth.Sign semantics (EIP-712 digest for " +
+			"this chainID and contract address, recovery id offset by 27)"
+		ret(resp)
+		return
+	}
+	resp.Valid = true
+	ret(resp)
+}
+
 type receivedAuth struct {
 	EthAddr   apitypes.StrHezEthAddr `json:"hezEthereumAddress" binding:"required"`
 	BJJ       apitypes.StrHezBJJ     `json:"bjj" binding:"required"`
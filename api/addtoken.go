@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// TokenRegistrar runs the RollupAddToken workflow on behalf of the API:
+// checking that the token is not registered yet, that the HEZ balance of the
+// coordinator covers the feeAddToken, submitting the AddToken call and
+// tracking the registration until the AddToken event is synced
+type TokenRegistrar interface {
+	// RegisterToken submits the AddToken call, returning the hash of the
+	// ethereum transaction
+	RegisterToken(tokenAddr ethCommon.Address) (ethCommon.Hash, error)
+	// TokenRegistrationStatus returns the status of the registration
+	// ("sent", "confirmed", "failed" or "synced") and the hash of its
+	// AddToken ethereum transaction, when known.  Unknown tokens return
+	// sql.ErrNoRows
+	TokenRegistrationStatus(tokenAddr ethCommon.Address) (string, ethCommon.Hash, error)
+}
+
+// SetTokenRegistrar enables the admin endpoints that register new tokens in
+// the Rollup smart contract.  Until this method is called, those endpoints
+// reject all requests.
+func (a *API) SetTokenRegistrar(registrar TokenRegistrar) {
+	a.tokenRegistrar = registrar
+}
+
+// receivedAddToken is the body of a token registration request
+type receivedAddToken struct {
+	EthereumAddress string `json:"ethereumAddress" binding:"required"`
+}
+
+// tokenRegistrationResponse is the representation of the state of a token
+// registration returned by the admin endpoints
+type tokenRegistrationResponse struct {
+	EthereumAddress ethCommon.Address `json:"ethereumAddress"`
+	Status          string            `json:"status"`
+	EthereumTxHash  *ethCommon.Hash   `json:"ethereumTxHash"`
+}
+
+func newTokenRegistrationResponse(tokenAddr ethCommon.Address, status string,
+	ethTxHash ethCommon.Hash) *tokenRegistrationResponse {
+	response := tokenRegistrationResponse{
+		EthereumAddress: tokenAddr,
+		Status:          status,
+	}
+	if ethTxHash != (ethCommon.Hash{}) {
+		response.EthereumTxHash = &ethTxHash
+	}
+	return &response
+}
+
+// postAddToken registers a new token in the Rollup smart contract
+func (a *API) postAddToken(c *gin.Context) {
+	if a.tokenRegistrar == nil {
+		retBadReq(errors.New(
+			"token registration is only available in coordinator mode"), c)
+		return
+	}
+	// Parse body
+	var addToken receivedAddToken
+	if err := c.ShouldBindJSON(&addToken); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if !ethCommon.IsHexAddress(addToken.EthereumAddress) {
+		retBadReq(errors.New("invalid ethereumAddress"), c)
+		return
+	}
+	tokenAddr := ethCommon.HexToAddress(addToken.EthereumAddress)
+	ethTxHash, err := a.tokenRegistrar.RegisterToken(tokenAddr)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newTokenRegistrationResponse(tokenAddr,
+		"sent", ethTxHash))
+}
+
+// getAddTokenStatus returns the status of the registration of a token
+func (a *API) getAddTokenStatus(c *gin.Context) {
+	if a.tokenRegistrar == nil {
+		retBadReq(errors.New(
+			"token registration is only available in coordinator mode"), c)
+		return
+	}
+	addrStr := c.Param("ethereumAddress")
+	if !ethCommon.IsHexAddress(addrStr) {
+		retBadReq(errors.New("invalid ethereumAddress"), c)
+		return
+	}
+	tokenAddr := ethCommon.HexToAddress(addrStr)
+	status, ethTxHash, err := a.tokenRegistrar.TokenRegistrationStatus(tokenAddr)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newTokenRegistrationResponse(tokenAddr,
+		status, ethTxHash))
+}
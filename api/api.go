@@ -18,7 +18,8 @@ All the information needed for the API to work should be obtained through the co
 to facilitate the format conversion. Most of the time, this is done directly at the db level.
 - The API endpoints are fully documented using OpenAPI aka Swagger. All the endpoints are tested against the spec to ensure consistency
 between implementation and specification. To get a sense of which endpoints exist and how they work, it's strongly recommended to check this specification.
-The specification can be found at api/swagger.yml.
+The specification can be found at api/swagger.yml. The operator-only endpoints under /admin are the one exception: they are not part of the public
+contract and are documented only through the generated /openapi.json document.
 - In general, all the API endpoints produce queries to the SQL database in order to retrieve / insert the requested information. The most notable exceptions to this are
 the /config endpoint, which returns a static object generated at construction time, and the /state, which also is retrieved from the database, but it's generated by API/stateapiupdater package.
 */
@@ -28,6 +29,8 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/arnaubennassar/hermez-node/api/parsers"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
@@ -47,6 +50,52 @@ type API struct {
 	l2            *l2db.L2DB
 	hermezAddress ethCommon.Address
 	validate      *validator.Validate
+	auth          *Auth
+	stateProofs   StateProofProvider
+	// dataAvailability decodes on-chain forgeBatch calldata (see
+	// SetDataAvailabilityProvider)
+	dataAvailability DataAvailabilityProvider
+	// openapi is the JSON encoded OpenAPI 3 document generated from the
+	// registered routes (see openapi.go)
+	openapi []byte
+	// txGossiper forwards received pool txs to cooperating coordinators
+	// (see SetPoolTxGossiper)
+	txGossiper PoolTxGossiper
+	// coordAccountRotator rotates the Coordinator fee account of the
+	// TxSelector (see SetCoordAccountRotator)
+	coordAccountRotator CoordAccountRotator
+	// tokenRegistrar registers new tokens in the Rollup smart contract
+	// (see SetTokenRegistrar)
+	tokenRegistrar TokenRegistrar
+	// idxReserver reserves the future Idx of accounts pending creation
+	// (see SetIdxReserver)
+	idxReserver IdxReserver
+	// responseSigner signs the critical API responses with the
+	// coordinator forger key (see signing.go)
+	responseSigner *ResponseSigner
+	// readinessProbes are the additional dependency checks run by the
+	// readiness endpoint (see AddReadinessProbe)
+	readinessProbes []readinessProbeEntry
+	// Safe mode staleness policy and sync lag cache (see
+	// SetStalenessPolicy)
+	stalenessThreshold int64
+	rejectStalePoolTxs bool
+	stalenessMutex     sync.Mutex
+	stalenessLag       int64
+	stalenessUpdate    time.Time
+}
+
+// PoolTxGossiper forwards the JSON encoded body of valid received pool txs
+// to a set of cooperating coordinators
+type PoolTxGossiper interface {
+	ForwardPoolTx(body []byte)
+}
+
+// SetPoolTxGossiper enables forwarding the valid pool txs received by the
+// API to cooperating coordinators.  Txs that were themselves received from
+// a cooperating coordinator are not forwarded again
+func (a *API) SetPoolTxGossiper(txGossiper PoolTxGossiper) {
+	a.txGossiper = txGossiper
 }
 
 // NewAPI sets the endpoints and the appropriate handlers, but doesn't start the server
@@ -84,6 +133,14 @@ func NewAPI(
 		validate:      newValidate(),
 	}
 
+	// Correlation IDs are attached before any other middleware so that
+	// every log emitted while serving a request carries one (see
+	// requestid.go)
+	server.Use(a.requestIDMiddleware())
+	// Tracing spans are opened after the correlation ID is attached so
+	// that they carry it as an attribute (see tracing.go)
+	server.Use(a.traceMiddleware())
+
 	middleware, err := metric.PrometheusMiddleware()
 	if err != nil {
 		return nil, err
@@ -93,32 +150,110 @@ func NewAPI(
 	server.NoRoute(a.noRoute)
 
 	v1 := server.Group("/v1")
+	// Translate opaque pagination cursors into fromItem (see cursor.go)
+	v1.Use(a.cursorMiddleware())
+	// Warn about stale state while the synchronizer lags behind head (see
+	// staleness.go)
+	v1.Use(a.stalenessMiddleware())
 
 	v1.GET("/health", gin.WrapH(a.healthRoute(version, ethClient, forgerAddress)))
+	// Liveness and readiness probes for load balancers and orchestrators
+	// (see probes.go).  They live outside the versioned group so that the
+	// probe paths don't change across API versions
+	server.GET("/healthz", a.getHealthz(version))
+	server.GET("/readyz", a.getReadyz(ethClient))
 	// Add coordinator endpoints
 	if coordinatorEndpoints {
 		// Account creation authorization
 		v1.POST("/account-creation-authorization", a.postAccountCreationAuth)
+		v1.POST("/account-creation-authorization/verify", a.verifyAccountCreationAuth)
 		v1.GET("/account-creation-authorization/:hezEthereumAddress", a.getAccountCreationAuth)
-		// Transaction
-		v1.POST("/transactions-pool", a.postPoolTx)
+		// Account index reservation for deposits to accounts pending
+		// creation.  Only available when the API runs along a
+		// coordinator (see SetIdxReserver)
+		v1.POST("/account-index-reservation", a.postAccountIndexReservation)
+		// Transaction.  The admission receipt can be signed with the
+		// forger key (see signing.go)
+		v1.POST("/transactions-pool", a.signedResponseMiddleware(), a.postPoolTx)
+		// HashToSign helper for unsigned txs, to verify client side
+		// hashing implementations against the node before submitting
+		v1.POST("/transactions-pool/hash", a.postPoolTxHash)
 		v1.GET("/transactions-pool/:id", a.getPoolTx)
 		v1.GET("/transactions-pool", a.getPoolTxs)
+		// Fee estimation against the current token price and the
+		// minimum fee policy of this coordinator
+		v1.GET("/fees/estimate", a.getFeeEstimate)
+		// Account state projected after the pending pool txs, needs both
+		// the HistoryDB and the L2DB
+		v1.GET("/accounts/:accountIndex/pending", a.getAccountPending)
+		// Withdrawal relay requests, processed by the coordinator
+		// withdrawal relayer when it is enabled (see
+		// coordinator/withdrawrelayer.go)
+		v1.POST("/withdrawal-relay", a.postWithdrawalRelay)
+		v1.GET("/withdrawal-relay/:batchNum/:accountIndex", a.getWithdrawalRelay)
+		// Operator-only endpoints, protected by the auth subsystem (see
+		// auth.go).  They reject all requests until an Auth is
+		// configured with SetAuth
+		admin := v1.Group("/admin")
+		admin.POST("/transactions-pool/purge", a.authRequired(ScopePool), a.purgePoolTxs)
+		admin.DELETE("/transactions-pool/:id", a.authRequired(ScopePool), a.deletePoolTx)
+		admin.GET("/token-policies", a.authRequired(ScopeConfig), a.getTokenPolicies)
+		admin.POST("/token-policies", a.authRequired(ScopeConfig), a.setTokenPolicy)
+		admin.POST("/token-prices", a.authRequired(ScopeConfig), a.setTokenPrice)
+		admin.POST("/tokens", a.authRequired(ScopeConfig), a.postAddToken)
+		admin.GET("/tokens/:ethereumAddress", a.authRequired(ScopeConfig),
+			a.getAddTokenStatus)
+		// Only available when the API runs along a coordinator (see
+		// SetCoordAccountRotator)
+		admin.POST("/coord-account", a.authRequired(ScopeConfig), a.setCoordAccount)
+		// Webhook subscriptions (see the webhook package)
+		admin.POST("/webhooks", a.authRequired(ScopeWebhooks), a.postWebhook)
+		admin.GET("/webhooks", a.authRequired(ScopeWebhooks), a.getWebhooks)
+		admin.DELETE("/webhooks/:id", a.authRequired(ScopeWebhooks), a.deleteWebhook)
 	}
 
 	// Add explorer endpoints
 	if explorerEndpoints {
 		// Account
 		v1.GET("/accounts", a.getAccounts)
+		// Batch resolution of mixed account identifiers.  The static
+		// "resolve" segment doesn't conflict with the ":accountIndex"
+		// wildcard because they live in different method trees
+		v1.POST("/accounts/resolve", a.postAccountsResolve)
 		v1.GET("/accounts/:accountIndex", a.getAccount)
-		v1.GET("/exits", a.getExits)
-		v1.GET("/exits/:batchNum/:accountIndex", a.getExit)
+		// Only available when the API runs along a synchronizer (see
+		// SetStateProofProvider)
+		v1.GET("/accounts/:accountIndex/proof", a.getAccountProof)
+		// Exit data can be signed with the forger key (see signing.go)
+		v1.GET("/exits", a.signedResponseMiddleware(), a.getExits)
+		v1.GET("/exits/:batchNum/:accountIndex", a.signedResponseMiddleware(), a.getExit)
+		// Sibling path instead of "/exits/summary" to avoid conflicting
+		// with the ":batchNum" wildcard
+		v1.GET("/exits-summary", a.getExitsSummary)
+		// L1 deposit watcher (see the depositwatcher package)
+		v1.POST("/deposit-watch", a.postDepositWatch)
+		v1.GET("/deposit-watch/:ethereumTxHash", a.getDepositWatch)
+		// L1 tx calldata helper, builds the addL1Transaction call for
+		// the wallet of the user (see l1txcalldata.go)
+		v1.GET("/l1-tx/calldata", a.getL1TxCalldata)
 		// Transaction
 		v1.GET("/transactions-history", a.getHistoryTxs)
 		v1.GET("/transactions-history/:id", a.getHistoryTx)
+		// Data needed to verify the inclusion of a forged L2 tx
+		// against the forgeBatch calldata published on-chain
+		v1.GET("/transactions-history/:id/inclusion-proof", a.getHistoryTxInclusionProof)
+		// Static "/transactions-history/export" would conflict with the
+		// ":id" wildcard in gin's route tree, so the export lives in a
+		// sibling path
+		v1.GET("/transactions-history-export", a.exportHistoryTxs)
 		// Batches
 		v1.GET("/batches", a.getBatches)
 		v1.GET("/batches/:batchNum", a.getBatch)
+		v1.GET("/batches-profitability", a.getBatchesProfitability)
+		// Only available when the API runs along a synchronizer (see
+		// SetDataAvailabilityProvider)
+		v1.GET("/batches/:batchNum/data-availability", a.getBatchDataAvailability)
+		v1.GET("/batches/:batchNum/state-diff", a.getBatchStateDiff)
 		v1.GET("/full-batches/:batchNum", a.getFullBatch)
 		// Slots
 		v1.GET("/slots", a.getSlots)
@@ -127,16 +262,30 @@ func NewAPI(
 		v1.GET("/bids", a.getBids)
 		// State
 		v1.GET("/state", a.getState)
+		v1.GET("/state/delta", a.getStateDelta)
+		// Metrics history
+		v1.GET("/metrics-history", a.getMetricsHistory)
 		// Config
 		v1.GET("/config", a.getConfig)
 		// Tokens
 		v1.GET("/tokens", a.getTokens)
 		v1.GET("/tokens/:id", a.getToken)
+		v1.GET("/tokens/:id/stats", a.getTokenStats)
+		// Search
+		v1.GET("/search", a.getSearch)
 		// Fiat Currencies
 		v1.GET("/currencies", a.getFiatCurrencies)
 		v1.GET("/currencies/:symbol", a.getFiatCurrency)
 		// Coordinators
 		v1.GET("/coordinators", a.getCoordinators)
+		v1.GET("/coordinators/:bidderAddr/stats", a.getCoordinatorStats)
+	}
+
+	// OpenAPI 3 document generated from the routes registered above (see
+	// openapi.go)
+	server.GET("/openapi.json", a.getOpenAPI)
+	if err := a.setOpenAPIDoc(version, server.Routes()); err != nil {
+		return nil, tracerr.Wrap(err)
 	}
 
 	return a, nil
@@ -201,8 +201,9 @@ func TestMain(m *testing.M) {
 	// Initializations
 	// Swagger
 	router := swagger.NewRouter().WithSwaggerFromFile("./swagger.yml")
-	// HistoryDB
-	database, err := db.InitTestSQLDB()
+	// HistoryDB, on an isolated database so the suite can run in parallel
+	// with the ones of other packages
+	database, teardown, err := db.InitTestSQLDBIsolated("api")
 	if err != nil {
 		panic(err)
 	}
@@ -622,7 +623,8 @@ func TestMain(m *testing.M) {
 	if err := server.Shutdown(context.Background()); err != nil {
 		panic(err)
 	}
-	if err := database.Close(); err != nil {
+	// Close and drop the test DB
+	if err := teardown(); err != nil {
 		panic(err)
 	}
 	os.Exit(result)
@@ -693,11 +695,13 @@ func TestTimeout(t *testing.T) {
 	body, err := ioutil.ReadAll(resp.Body)
 	require.NoError(t, err)
 	// Unmarshal body into return struct
-	msg := &errorMsg{}
+	msg := &apiErrorResponse{}
 	err = json.Unmarshal(body, msg)
 	require.NoError(t, err)
 	// Check that the error was the expected down
 	require.Equal(t, ErrSQLTimeout, msg.Message)
+	require.Equal(t, ErrSQLTimeoutCode, msg.Code)
+	require.Equal(t, ErrSQLTimeoutType, msg.Type)
 	finishWait <- nil
 
 	// Stop server
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Scopes of the operator-only endpoints.  Each API key or JWT carries the set
+// of scopes it's allowed to use, so operators can hand out keys limited to a
+// subset of the admin surface.
+const (
+	// ScopeConfig allows managing the node configuration
+	ScopeConfig = "config"
+	// ScopePool allows managing the L2 txs pool
+	ScopePool = "pool"
+	// ScopeProvers allows managing the proof servers
+	ScopeProvers = "provers"
+	// ScopeBidding allows managing the auction bidding
+	ScopeBidding = "bidding"
+	// ScopeWebhooks allows managing the webhook subscriptions
+	ScopeWebhooks = "webhooks"
+)
+
+// APIKey is an API key accepted by the operator-only endpoints together with
+// the scopes it grants
+type APIKey struct {
+	Key    string
+	Scopes []string
+}
+
+// Auth authenticates requests to the operator-only endpoints.  Requests can
+// authenticate either with an API key (X-API-Key header) or with an HS256 JWT
+// bearer token (Authorization header) whose claims carry the granted scopes.
+// Keys can be rotated at runtime with SetKeys without restarting the API.
+type Auth struct {
+	jwtSecret []byte
+
+	mutex sync.RWMutex
+	// keys maps each API key to its set of granted scopes
+	keys map[string]map[string]bool
+}
+
+// NewAuth creates an Auth that accepts the given API keys, and JWTs signed
+// with jwtSecret when it's not empty
+func NewAuth(jwtSecret string, keys []APIKey) *Auth {
+	auth := &Auth{}
+	if jwtSecret != "" {
+		auth.jwtSecret = []byte(jwtSecret)
+	}
+	auth.SetKeys(keys)
+	return auth
+}
+
+// SetKeys replaces the set of accepted API keys, dropping the previous ones.
+// This is the key rotation mechanism: keys removed from the set stop being
+// accepted immediately.
+func (auth *Auth) SetKeys(keys []APIKey) {
+	newKeys := make(map[string]map[string]bool)
+	for _, key := range keys {
+		scopes := make(map[string]bool)
+		for _, scope := range key.Scopes {
+			scopes[scope] = true
+		}
+		newKeys[key.Key] = scopes
+	}
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth.keys = newKeys
+}
+
+// keyHasScope returns true if key is an accepted API key that grants scope
+func (auth *Auth) keyHasScope(key, scope string) bool {
+	auth.mutex.RLock()
+	defer auth.mutex.RUnlock()
+	scopes, ok := auth.keys[key]
+	return ok && scopes[scope]
+}
+
+// jwtClaims are the claims of the JWTs accepted by the operator-only
+// endpoints.  Scopes can be given either as an array ("scopes") or as an
+// OAuth style space-separated string ("scope").
+type jwtClaims struct {
+	Exp    int64    `json:"exp"`
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+}
+
+// verifyJWT verifies the signature and expiration of an HS256 JWT and returns
+// its granted scopes
+func (auth *Auth) verifyJWT(token string) (map[string]bool, error) {
+	if auth.jwtSecret == nil {
+		return nil, tracerr.Wrap(errJWTNotEnabled)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:gomnd
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	mac := hmac.New(sha256.New, auth.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, tracerr.Wrap(errJWTInvalid)
+	}
+	if claims.Exp != 0 && time.Now().Unix() > claims.Exp {
+		return nil, tracerr.Wrap(errJWTExpired)
+	}
+	scopes := make(map[string]bool)
+	for _, scope := range claims.Scopes {
+		scopes[scope] = true
+	}
+	for _, scope := range strings.Fields(claims.Scope) {
+		scopes[scope] = true
+	}
+	return scopes, nil
+}
+
+// SetAuth enables the operator-only endpoints with the given Auth.  Until
+// this method is called, those endpoints reject all requests.
+func (a *API) SetAuth(auth *Auth) {
+	a.auth = auth
+}
+
+// SetAuthKeys rotates the API keys accepted by the operator-only endpoints.
+// It's a no-op when no Auth has been configured.
+func (a *API) SetAuthKeys(keys []APIKey) {
+	if a.auth != nil {
+		a.auth.SetKeys(keys)
+	}
+}
+
+// authRequired is the middleware that protects the operator-only endpoints,
+// only letting through requests that authenticate with an API key or JWT
+// granting the given scope
+func (a *API) authRequired(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.auth == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrorResponse{
+				Message: ErrUnauthorized,
+				Code:    ErrUnauthorizedCode,
+				Type:    ErrUnauthorizedType,
+			})
+			return
+		}
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			if !a.auth.keyHasScope(key, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, apiErrorResponse{
+					Message: ErrForbidden,
+					Code:    ErrForbiddenCode,
+					Type:    ErrForbiddenType,
+				})
+				return
+			}
+			return
+		}
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			scopes, err := a.auth.verifyJWT(strings.TrimPrefix(authHeader, "Bearer "))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrorResponse{
+					Message: ErrUnauthorized,
+					Code:    ErrUnauthorizedCode,
+					Type:    ErrUnauthorizedType,
+				})
+				return
+			}
+			if !scopes[scope] {
+				c.AbortWithStatusJSON(http.StatusForbidden, apiErrorResponse{
+					Message: ErrForbidden,
+					Code:    ErrForbiddenCode,
+					Type:    ErrForbiddenType,
+				})
+				return
+			}
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrorResponse{
+			Message: ErrUnauthorized,
+			Code:    ErrUnauthorizedCode,
+			Type:    ErrUnauthorizedType,
+		})
+	}
+}
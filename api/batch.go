@@ -2,10 +2,12 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/arnaubennassar/hermez-node/api/parsers"
 	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/dataavailability"
 	"github.com/arnaubennassar/hermez-node/db"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
 	"github.com/gin-gonic/gin"
@@ -19,21 +21,62 @@ func (a *API) getBatches(c *gin.Context) {
 		retBadReq(err, c)
 		return
 	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
 	// Fetch batches from historyDB
 	batches, pendingItems, err := a.h.GetBatchesAPI(filter)
 	if err != nil {
 		retSQLErr(err, c)
 		return
 	}
+	applyCurrencyToBatches(batches, rate)
 
 	// Build successful response
 	type batchesResponse struct {
 		Batches      []historydb.BatchAPI `json:"batches"`
 		PendingItems uint64               `json:"pendingItems"`
+		NextCursor   string               `json:"nextCursor,omitempty"`
+	}
+	retJSONWithFields(c, &batchesResponse{
+		Batches:      batches,
+		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, batches, pendingItems),
+	}, "batches")
+}
+
+func (a *API) getBatchesProfitability(c *gin.Context) {
+	// Get query parameters
+	filter, err := parsers.ParseBatchesProfitabilityFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch profitability report from historyDB
+	batches, pendingItems, err := a.h.GetBatchesProfitabilityAPI(filter)
+	if err != nil {
+		retSQLErr(err, c)
+		return
 	}
-	c.JSON(http.StatusOK, &batchesResponse{
+	applyCurrencyToProfitability(batches, rate)
+
+	// Build successful response
+	type batchesProfitabilityResponse struct {
+		Batches      []historydb.BatchProfitabilityAPI `json:"batches"`
+		PendingItems uint64                            `json:"pendingItems"`
+		NextCursor   string                            `json:"nextCursor,omitempty"`
+	}
+	c.JSON(http.StatusOK, &batchesProfitabilityResponse{
 		Batches:      batches,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, batches, pendingItems),
 	})
 }
 
@@ -44,14 +87,21 @@ func (a *API) getBatch(c *gin.Context) {
 		retBadReq(err, c)
 		return
 	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
 	// Fetch batch from historyDB
 	batch, err := a.h.GetBatchAPI(common.BatchNum(*batchNum))
 	if err != nil {
 		retSQLErr(err, c)
 		return
 	}
+	batches := []historydb.BatchAPI{*batch}
+	applyCurrencyToBatches(batches, rate)
 	// JSON response
-	c.JSON(http.StatusOK, batch)
+	c.JSON(http.StatusOK, &batches[0])
 }
 
 type fullBatch struct {
@@ -90,3 +140,56 @@ func (a *API) getFullBatch(c *gin.Context) {
 		Txs:   txs,
 	})
 }
+
+// getBatchStateDiff returns the state changes introduced by a batch: the
+// accounts it created, the balance and nonce updates it applied and the
+// exits it added to the exit tree
+func (a *API) getBatchStateDiff(c *gin.Context) {
+	// Get batchNum
+	batchNum, err := parsers.ParseBatchFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch the state diff from historyDB
+	diff, err := a.h.GetBatchStateDiffAPI(common.BatchNum(*batchNum))
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// JSON response
+	c.JSON(http.StatusOK, diff)
+}
+
+// DataAvailabilityProvider decodes the data availability published on-chain
+// in the forgeBatch call of a forged batch
+type DataAvailabilityProvider interface {
+	DecodeBatch(batchNum common.BatchNum) (*dataavailability.BatchData, error)
+}
+
+// SetDataAvailabilityProvider enables the batch data availability endpoint.
+// Until this method is called, that endpoint rejects all requests
+func (a *API) SetDataAvailabilityProvider(provider DataAvailabilityProvider) {
+	a.dataAvailability = provider
+}
+
+func (a *API) getBatchDataAvailability(c *gin.Context) {
+	if a.dataAvailability == nil {
+		retBadReq(&apiError{Err: errors.New(ErrDataAvailabilityNotEnabled), Code: ErrDataAvailabilityNotEnabledCode, Type: ErrDataAvailabilityNotEnabledType}, c)
+		return
+	}
+	// Get batchNum
+	batchNum, err := parsers.ParseBatchFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch the batch calldata and decode it
+	batchData, err := a.dataAvailability.DecodeBatch(common.BatchNum(*batchNum))
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// JSON response
+	c.JSON(http.StatusOK, batchData)
+}
@@ -32,9 +32,11 @@ func (a *API) getBids(c *gin.Context) {
 	type bidsResponse struct {
 		Bids         []historydb.BidAPI `json:"bids"`
 		PendingItems uint64             `json:"pendingItems"`
+		NextCursor   string             `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &bidsResponse{
 		Bids:         bids,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, bids, pendingItems),
 	})
 }
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// gzipWriter wraps the gin ResponseWriter compressing everything written to
+// it, so that handlers (including the streaming ones) don't need to be aware
+// of the compression
+type gzipWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (g *gzipWriter) Write(data []byte) (int, error) {
+	return g.gz.Write(data)
+}
+
+func (g *gzipWriter) WriteString(s string) (int, error) {
+	return g.gz.Write([]byte(s))
+}
+
+func (g *gzipWriter) WriteHeader(code int) {
+	// The compressed length is not known in advance
+	g.Header().Del("Content-Length")
+	g.ResponseWriter.WriteHeader(code)
+}
+
+// NewGzipMiddleware returns a middleware that compresses the responses with
+// gzip for the clients that accept it.  level follows compress/gzip: from 1
+// (fastest) to 9 (best compression), with 0 applying the default level.
+func NewGzipMiddleware(level int) (gin.HandlerFunc, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return nil, tracerr.Wrap(fmt.Errorf("invalid gzip compression level %v", level))
+	}
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") ||
+			c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		gz, err := gzip.NewWriterLevel(c.Writer, level)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Header("Content-Encoding", "gzip")
+		c.Header("Vary", "Accept-Encoding")
+		c.Writer = &gzipWriter{ResponseWriter: c.Writer, gz: gz}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				log.Errorw("gzip middleware close", "err", err)
+			}
+		}()
+		c.Next()
+	}, nil
+}
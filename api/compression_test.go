@@ -0,0 +1,51 @@
+package api
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	body := strings.Repeat("hermez ", 1000)
+	engine := gin.New()
+	mw, err := NewGzipMiddleware(0)
+	require.NoError(t, err)
+	engine.GET("/foo", mw, func(c *gin.Context) {
+		c.String(http.StatusOK, body)
+	})
+
+	// Clients accepting gzip get a compressed body
+	req := httptest.NewRequest("GET", "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), len(body))
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decompressed))
+
+	// Clients not accepting gzip get the plain body
+	req = httptest.NewRequest("GET", "/foo", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, w.Body.String())
+
+	// Invalid levels are rejected
+	_, err = NewGzipMiddleware(10)
+	assert.Error(t, err)
+}
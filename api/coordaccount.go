@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// CoordAccountRotator replaces the Coordinator fee account used by the
+// TxSelector, applying to the selections of the subsequent batches
+type CoordAccountRotator interface {
+	RotateCoordAccount(addr ethCommon.Address, bjj babyjub.PublicKeyComp,
+		accountCreationAuth []byte) error
+}
+
+// SetCoordAccountRotator enables the admin endpoint that rotates the
+// Coordinator fee account.  Until this method is called, that endpoint
+// rejects all requests.
+func (a *API) SetCoordAccountRotator(rotator CoordAccountRotator) {
+	a.coordAccountRotator = rotator
+}
+
+// setCoordAccount rotates the Coordinator fee account of the TxSelector.
+// The new account is provided with the same shape as an account creation
+// authorization, so that the TxSelector can keep creating the missing
+// Coordinator accounts via L1CoordinatorTxs
+func (a *API) setCoordAccount(c *gin.Context) {
+	if a.coordAccountRotator == nil {
+		retBadReq(errors.New(
+			"Coordinator account rotation is only available in coordinator mode"), c)
+		return
+	}
+	// Parse body
+	var apiAuth receivedAuth
+	if err := c.ShouldBindJSON(&apiAuth); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// API to common + verify signature
+	commonAuth := accountCreationAuthAPIToCommon(&apiAuth)
+	if !commonAuth.VerifySignature(a.cg.ChainID, a.hermezAddress) {
+		retBadReq(errors.New("invalid signature"), c)
+		return
+	}
+	// Apply to the TxSelector
+	if err := a.coordAccountRotator.RotateCoordAccount(commonAuth.EthAddr,
+		commonAuth.BJJ, commonAuth.Signature); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	type okResponse struct {
+		Success string `json:"success"`
+	}
+	// Return OK
+	c.JSON(http.StatusOK, &okResponse{
+		Success: "OK",
+	})
+}
@@ -26,9 +26,35 @@ func (a *API) getCoordinators(c *gin.Context) {
 	type coordinatorsResponse struct {
 		Coordinators []historydb.CoordinatorAPI `json:"coordinators"`
 		PendingItems uint64                     `json:"pendingItems"`
+		NextCursor   string                     `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &coordinatorsResponse{
 		Coordinators: coordinators,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, coordinators, pendingItems),
 	})
 }
+
+// getCoordinatorStats returns the auction and forging track record of a
+// coordinator: slots won, slots forged vs missed, average batches per slot
+// and the uptime inferred from its forge events
+func (a *API) getCoordinatorStats(c *gin.Context) {
+	bidderAddr, err := parsers.ParseGetCoordinatorStatsFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// The current slot delimits the won slots that have already finished
+	currentBlock, err := a.h.GetLastBlockAPI()
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	currentSlot := a.getCurrentSlot(currentBlock.Num)
+	stats, err := a.h.GetCoordinatorStatsAPI(*bidderAddr, currentSlot)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// baseCurrency is the currency in which all the stored fiat amounts are
+// expressed
+const baseCurrency = "USD"
+
+// requestCurrencyRate resolves the optional `currency` query parameter into
+// the rate used to convert the fiat amounts of the response.  The rate is 1
+// when the parameter is missing or is the base currency; any other symbol
+// must exist in the fiat table (see GET /currencies)
+func (a *API) requestCurrencyRate(c *gin.Context) (float64, error) {
+	symbol := strings.ToUpper(c.Query("currency"))
+	if symbol == "" || symbol == baseCurrency {
+		return 1, nil
+	}
+	currency, err := a.h.GetCurrencyAPI(symbol)
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		return 0, tracerr.Wrap(fmt.Errorf("currency %s is not supported", symbol))
+	} else if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return currency.Price, nil
+}
+
+// convertFiat scales an optional fiat amount by the requested currency rate
+func convertFiat(usd *float64, rate float64) *float64 {
+	if usd == nil {
+		return nil
+	}
+	converted := *usd * rate
+	return &converted
+}
+
+// applyCurrencyToTxs converts the fiat amounts of a list of txs in place
+func applyCurrencyToTxs(txs []historydb.TxAPI, rate float64) {
+	if rate == 1 {
+		return
+	}
+	for i := range txs {
+		txs[i].HistoricUSD = convertFiat(txs[i].HistoricUSD, rate)
+		txs[i].HistoricDepositAmountUSD = convertFiat(txs[i].HistoricDepositAmountUSD, rate)
+		txs[i].HistoricFeeUSD = convertFiat(txs[i].HistoricFeeUSD, rate)
+		txs[i].TokenUSD = convertFiat(txs[i].TokenUSD, rate)
+	}
+}
+
+// applyCurrencyToBatches converts the fiat amounts of a list of batches in
+// place
+func applyCurrencyToBatches(batches []historydb.BatchAPI, rate float64) {
+	if rate == 1 {
+		return
+	}
+	for i := range batches {
+		batches[i].TotalFeesUSD = convertFiat(batches[i].TotalFeesUSD, rate)
+	}
+}
+
+// applyCurrencyToProfitability converts the fiat amounts of a profitability
+// report in place
+func applyCurrencyToProfitability(batches []historydb.BatchProfitabilityAPI, rate float64) {
+	if rate == 1 {
+		return
+	}
+	for i := range batches {
+		batches[i].TotalFeesUSD = convertFiat(batches[i].TotalFeesUSD, rate)
+		batches[i].GasCostUSD = convertFiat(batches[i].GasCostUSD, rate)
+		batches[i].NetProfitUSD = convertFiat(batches[i].NetProfitUSD, rate)
+	}
+}
+
+// applyCurrencyToMetricsHistory converts the fiat amounts of a list of
+// metrics snapshots in place
+func applyCurrencyToMetricsHistory(snapshots []historydb.MetricsSnapshotAPI, rate float64) {
+	if rate == 1 {
+		return
+	}
+	for i := range snapshots {
+		snapshots[i].AvgTransactionFee *= rate
+	}
+}
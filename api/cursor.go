@@ -0,0 +1,120 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cursorPayload is the decoded content of an opaque pagination cursor.  The
+// cursor pins the item_id where the next page starts and a hash of the rest
+// of the query filters, so that changing any filter (or the order) between
+// pages is detected and rejected instead of silently returning inconsistent
+// results.
+type cursorPayload struct {
+	ItemID  uint64 `json:"itemId"`
+	Filters string `json:"filters"`
+}
+
+// encodeCursor returns the opaque cursor for a page starting at itemID with
+// the given filter hash
+func encodeCursor(itemID uint64, filterHash string) string {
+	payload, _ := json.Marshal(cursorPayload{ItemID: itemID, Filters: filterHash}) //nolint:errcheck
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeCursor parses an opaque cursor back into its payload
+func decodeCursor(cursor string) (*cursorPayload, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// cursorFilterHash returns a short hash of all the query params that select
+// and order the listed items.  The pagination position params (cursor,
+// fromItem and limit) are excluded, since they legitimately change from page
+// to page; order is included because switching it mid-pagination breaks the
+// sequence.
+func cursorFilterHash(query url.Values) string {
+	filters := url.Values{}
+	for key, values := range query {
+		if key == "cursor" || key == "fromItem" || key == "limit" {
+			continue
+		}
+		filters[key] = values
+	}
+	// url.Values.Encode sorts by key, so the input to the hash is canonical
+	sum := sha256.Sum256([]byte(filters.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cursorMiddleware translates the opaque cursor query param, when present,
+// into the equivalent fromItem param so that the listing endpoints don't need
+// to know about cursors.  Requests whose filters don't match the ones the
+// cursor was minted for are rejected.
+func (a *API) cursorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cursor := c.Query("cursor")
+		if cursor == "" {
+			c.Next()
+			return
+		}
+		payload, err := decodeCursor(cursor)
+		if err != nil {
+			retBadReq(&apiError{Err: errors.New(ErrInvalidCursor), Code: ErrInvalidCursorCode, Type: ErrInvalidCursorType}, c)
+			c.Abort()
+			return
+		}
+		query := c.Request.URL.Query()
+		if payload.Filters != cursorFilterHash(query) {
+			retBadReq(&apiError{Err: errors.New(ErrCursorFilterMismatch), Code: ErrCursorFilterMismatchCode, Type: ErrCursorFilterMismatchType}, c)
+			c.Abort()
+			return
+		}
+		query.Set("fromItem", strconv.FormatUint(payload.ItemID, 10))
+		query.Del("cursor")
+		c.Request.URL.RawQuery = query.Encode()
+		c.Next()
+	}
+}
+
+// nextCursor returns the opaque cursor of the next page given the items of
+// the current one, or an empty string when there are no more pages.  items
+// must be a slice whose elements have an ItemID field (which all the paginated
+// API views do).
+func nextCursor(c *gin.Context, items interface{}, pendingItems uint64) string {
+	if pendingItems == 0 {
+		return ""
+	}
+	slice := reflect.ValueOf(items)
+	if slice.Kind() != reflect.Slice || slice.Len() == 0 {
+		return ""
+	}
+	itemID := slice.Index(slice.Len() - 1).FieldByName("ItemID")
+	if !itemID.IsValid() || itemID.Kind() != reflect.Uint64 {
+		return ""
+	}
+	// The next page starts right after the last returned item, following
+	// the requested order (ASC by default, matching parsers.Pagination)
+	fromItem := itemID.Uint() + 1
+	if c.Query("order") == "DESC" {
+		if itemID.Uint() == 0 {
+			return ""
+		}
+		fromItem = itemID.Uint() - 1
+	}
+	return encodeCursor(fromItem, cursorFilterHash(c.Request.URL.Query()))
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	cursor := encodeCursor(42, "abcd")
+	payload, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), payload.ItemID)
+	assert.Equal(t, "abcd", payload.Filters)
+
+	_, err = decodeCursor("not-a-cursor!")
+	assert.Error(t, err)
+}
+
+func TestCursorFilterHash(t *testing.T) {
+	newCtx := func(rawQuery string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/v1/batches?"+rawQuery, nil)
+		return c
+	}
+	// Pagination position params don't affect the hash
+	h1 := cursorFilterHash(newCtx("slotNum=3&limit=10").Request.URL.Query())
+	h2 := cursorFilterHash(newCtx("slotNum=3&fromItem=7&limit=20&cursor=xxx").Request.URL.Query())
+	assert.Equal(t, h1, h2)
+	// Changing a filter or the order does
+	assert.NotEqual(t, h1,
+		cursorFilterHash(newCtx("slotNum=4&limit=10").Request.URL.Query()))
+	assert.NotEqual(t, h1,
+		cursorFilterHash(newCtx("slotNum=3&order=DESC").Request.URL.Query()))
+}
+
+func TestNextCursor(t *testing.T) {
+	type item struct {
+		ItemID uint64
+	}
+	newCtx := func(rawQuery string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/v1/batches?"+rawQuery, nil)
+		return c
+	}
+
+	// No more pages
+	assert.Equal(t, "", nextCursor(newCtx(""), []item{{ItemID: 5}}, 0))
+	// Empty page
+	assert.Equal(t, "", nextCursor(newCtx(""), []item{}, 3))
+
+	// ASC: next page starts right after the last item
+	cursor := nextCursor(newCtx("slotNum=3"), []item{{ItemID: 5}, {ItemID: 6}}, 3)
+	payload, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), payload.ItemID)
+
+	// DESC: next page starts right before the last item
+	cursor = nextCursor(newCtx("order=DESC"), []item{{ItemID: 6}, {ItemID: 5}}, 3)
+	payload, err = decodeCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), payload.ItemID)
+}
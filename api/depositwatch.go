@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Deposit watch status values returned by the API
+const (
+	// depositWatchStatusPending: the ethereum tx hasn't been seen
+	// included in a block yet
+	depositWatchStatusPending = "pending"
+	// depositWatchStatusFailed: the ethereum tx was included but
+	// reverted or didn't generate any L1UserTx
+	depositWatchStatusFailed = "failed"
+	// depositWatchStatusIncluded: the L1UserTx is waiting in its queue
+	// to be forged
+	depositWatchStatusIncluded = "included"
+	// depositWatchStatusForged: the L1UserTx has been forged in a batch
+	depositWatchStatusForged = "forged"
+)
+
+// depositWatchResponse is the representation of a deposit watch returned by
+// the API
+type depositWatchResponse struct {
+	EthereumTxHash   ethCommon.Hash `json:"ethereumTxHash"`
+	Status           string         `json:"status"`
+	EthereumBlockNum *int64         `json:"ethereumBlockNum"`
+	L1TransactionID  *common.TxID   `json:"id"`
+	// ToForgeL1TransactionsNum is the L1UserTx queue where the deposit
+	// waits to be forged
+	ToForgeL1TransactionsNum *int64 `json:"toForgeL1TransactionsNum"`
+	// Position of the deposit inside the L1UserTx queue
+	Position *int `json:"position"`
+	// BatchNum where the L1UserTx was forged
+	BatchNum *common.BatchNum `json:"batchNum"`
+	// ExpectedBatchNum is a lower bound estimation of the batch that
+	// will forge the L1UserTx, only returned while it's not forged
+	ExpectedBatchNum *common.BatchNum `json:"expectedBatchNum"`
+}
+
+func newDepositWatchResponse(watch *historydb.L1DepositWatchAPI) depositWatchResponse {
+	response := depositWatchResponse{
+		EthereumTxHash:           watch.EthTxHash,
+		EthereumBlockNum:         watch.EthBlockNum,
+		L1TransactionID:          watch.TxID,
+		ToForgeL1TransactionsNum: watch.ToForgeL1TxsNum,
+		Position:                 watch.Position,
+		BatchNum:                 watch.ForgedBatchNum,
+	}
+	switch {
+	case watch.Failed:
+		response.Status = depositWatchStatusFailed
+	case watch.EthBlockNum == nil:
+		response.Status = depositWatchStatusPending
+	case watch.ForgedBatchNum != nil:
+		response.Status = depositWatchStatusForged
+	default:
+		response.Status = depositWatchStatusIncluded
+		// Each L1UserTx queue requires at least one L1 batch to be
+		// forged, so the deposit queue can't be forged before
+		// lastBatchNum + (queue - lastForgedQueue) batches
+		if watch.ToForgeL1TxsNum != nil && watch.LastBatchNum != nil {
+			lastForged := int64(-1)
+			if watch.LastForgedL1TxsNum != nil {
+				lastForged = *watch.LastForgedL1TxsNum
+			}
+			pendingQueues := *watch.ToForgeL1TxsNum - lastForged
+			if pendingQueues < 1 {
+				pendingQueues = 1
+			}
+			expected := *watch.LastBatchNum + common.BatchNum(pendingQueues)
+			response.ExpectedBatchNum = &expected
+		}
+	}
+	return response
+}
+
+// parseEthTxHash parses a hex encoded ethereum transaction hash with an
+// optional "0x" prefix
+func parseEthTxHash(hashStr string) (ethCommon.Hash, error) {
+	hashBytes, err := hex.DecodeString(strings.TrimPrefix(hashStr, "0x"))
+	if err != nil || len(hashBytes) != ethCommon.HashLength {
+		return ethCommon.Hash{}, errors.New(
+			"invalid ethereumTxHash, must be a hex encoded 32 byte hash")
+	}
+	return ethCommon.BytesToHash(hashBytes), nil
+}
+
+// postDepositWatch registers an ethereum transaction hash of an
+// addL1Transaction call to be watched by the deposit watcher
+func (a *API) postDepositWatch(c *gin.Context) {
+	// Parse body
+	var receivedWatch struct {
+		EthereumTxHash string `json:"ethereumTxHash" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&receivedWatch); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	hash, err := parseEthTxHash(receivedWatch.EthereumTxHash)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if err := a.h.AddL1DepositWatchAPI(hash); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	watch, err := a.h.GetL1DepositWatchAPI(hash)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newDepositWatchResponse(watch))
+}
+
+// getDepositWatch returns the status of a watched ethereum transaction: its
+// queue position once included and the batch where it was (or is expected to
+// be) forged
+func (a *API) getDepositWatch(c *gin.Context) {
+	hash, err := parseEthTxHash(c.Param("ethereumTxHash"))
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	watch, err := a.h.GetL1DepositWatchAPI(hash)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newDepositWatchResponse(watch))
+}
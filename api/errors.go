@@ -1,23 +1,311 @@
 package api
 
+import "errors"
+
+// apiErrorCode is a stable machine-readable identifier of an API error.
+// Codes are part of the public API: once assigned, a code must never be
+// reused for a different error
+type apiErrorCode uint
+
+// apiErrorType is a stable machine-readable name of an API error
+type apiErrorType string
+
 const (
 	// Public error messages (included in response objects)
 
+	// ErrParamValidationFailedCode code returned on any bad request that
+	// doesn't have a more specific code, such as malformed or out of
+	// range parameters
+	ErrParamValidationFailedCode apiErrorCode = 1
+	// ErrParamValidationFailedType type for param validation failed error
+	ErrParamValidationFailedType apiErrorType = "ErrParamValidationFailed"
+
 	// ErrDuplicatedKey error message returned when trying to insert an item with duplicated key
 	ErrDuplicatedKey = "Item already exists"
+	// ErrDuplicatedKeyCode code for duplicated key error
+	ErrDuplicatedKeyCode apiErrorCode = 2
+	// ErrDuplicatedKeyType type for duplicated key error
+	ErrDuplicatedKeyType apiErrorType = "ErrDuplicatedKey"
+
 	// ErrSQLTimeout error message returned when timeout due to SQL connection
 	ErrSQLTimeout = "The node is under heavy preasure, please try again later"
-	// ErrExitAmount0 error message returned when receiving (and rejecting) a tr of type exit with amount 0
-	ErrExitAmount0 = "Transaction rejected because an exit with amount 0 has no sense"
+	// ErrSQLTimeoutCode code for sql timeout error
+	ErrSQLTimeoutCode apiErrorCode = 3
+	// ErrSQLTimeoutType type for sql timeout error
+	ErrSQLTimeoutType apiErrorType = "ErrSQLTimeout"
 
-	// Internal error messages (used for logs or handling errors returned from internal comopnents)
+	// ErrSQLNoRowsCode code returned when the requested item doesn't
+	// exist.  The legacy message text (the underlying sql error) is kept
+	// for backwards compatibility
+	ErrSQLNoRowsCode apiErrorCode = 4
+	// ErrSQLNoRowsType type for no rows error
+	ErrSQLNoRowsType apiErrorType = "ErrSQLNoRows"
 
-	// errCtxTimeout error message received internally when context reaches timeout
-	errCtxTimeout = "context deadline exceeded"
+	// ErrInternalCode code returned on internal server errors.  The
+	// legacy message text (the underlying error) is kept for backwards
+	// compatibility
+	ErrInternalCode apiErrorCode = 5
+	// ErrInternalType type for internal server errors
+	ErrInternalType apiErrorType = "ErrInternal"
+
+	// ErrExitAmount0 error message returned when receiving (and rejecting) a tr of type exit with amount 0
+	ErrExitAmount0 = "Transaction rejected because an exit with amount 0 has no sense"
+	// ErrExitAmount0Code code for 0 exit amount error
+	ErrExitAmount0Code apiErrorCode = 6
+	// ErrExitAmount0Type type for 0 exit amount error
+	ErrExitAmount0Type apiErrorType = "ErrExitAmount0"
 
 	// ErrInvalidSymbol error message returned when receiving (and rejecting) an invalid Symbol
 	ErrInvalidSymbol = "Invalid Symbol"
+	// ErrInvalidSymbolCode code for invalid symbol error
+	ErrInvalidSymbolCode apiErrorCode = 7
+	// ErrInvalidSymbolType type for invalid symbol error
+	ErrInvalidSymbolType apiErrorType = "ErrInvalidSymbol"
 
 	// ErrIsAtomic filter atomic transactions on POST /transactions-pool
 	ErrIsAtomic = "Thies endpoint does not accept atomic transactions"
+	// ErrIsAtomicCode code filter atomic transactions on POST /transactions-pool
+	ErrIsAtomicCode apiErrorCode = 8
+	// ErrIsAtomicType type filter atomic transactions on POST /transactions-pool
+	ErrIsAtomicType apiErrorType = "ErrIsAtomic"
+
+	// ErrSameTxIDDifferentContent error message returned when receiving (and rejecting)
+	// a tx whose TxID is already in the pool but whose signed fields differ from the stored ones
+	ErrSameTxIDDifferentContent = "A different transaction with the same TxID already exists in the pool"
+	// ErrSameTxIDDifferentContentCode code for same TxID different content error
+	ErrSameTxIDDifferentContentCode apiErrorCode = 9
+	// ErrSameTxIDDifferentContentType type for same TxID different content error
+	ErrSameTxIDDifferentContentType apiErrorType = "ErrSameTxIDDifferentContent"
+
+	// ErrUnauthorized error message returned when an operator-only endpoint receives a
+	// request without valid credentials
+	ErrUnauthorized = "Missing or invalid credentials"
+	// ErrUnauthorizedCode code for missing or invalid credentials error
+	ErrUnauthorizedCode apiErrorCode = 10
+	// ErrUnauthorizedType type for missing or invalid credentials error
+	ErrUnauthorizedType apiErrorType = "ErrUnauthorized"
+
+	// ErrForbidden error message returned when an operator-only endpoint receives a
+	// request whose credentials don't grant the required scope
+	ErrForbidden = "Credentials don't grant the required scope"
+	// ErrForbiddenCode code for insufficient scope error
+	ErrForbiddenCode apiErrorCode = 11
+	// ErrForbiddenType type for insufficient scope error
+	ErrForbiddenType apiErrorType = "ErrForbidden"
+
+	// ErrStateProofsNotEnabled error message returned by the account state proof
+	// endpoint when the API is not running along a synchronizer
+	ErrStateProofsNotEnabled = "State proofs are not available on this node"
+	// ErrStateProofsNotEnabledCode code for state proofs not enabled error
+	ErrStateProofsNotEnabledCode apiErrorCode = 12
+	// ErrStateProofsNotEnabledType type for state proofs not enabled error
+	ErrStateProofsNotEnabledType apiErrorType = "ErrStateProofsNotEnabled"
+
+	// ErrDataAvailabilityNotEnabled error message returned by the batch data
+	// availability endpoint when the API is not running along a synchronizer
+	ErrDataAvailabilityNotEnabled = "Batch data availability is not available on this node"
+	// ErrDataAvailabilityNotEnabledCode code for data availability not enabled error
+	ErrDataAvailabilityNotEnabledCode apiErrorCode = 13
+	// ErrDataAvailabilityNotEnabledType type for data availability not enabled error
+	ErrDataAvailabilityNotEnabledType apiErrorType = "ErrDataAvailabilityNotEnabled"
+
+	// ErrInvalidCursor error message returned when a pagination cursor
+	// can't be decoded
+	ErrInvalidCursor = "Invalid cursor"
+	// ErrInvalidCursorCode code for invalid cursor error
+	ErrInvalidCursorCode apiErrorCode = 14
+	// ErrInvalidCursorType type for invalid cursor error
+	ErrInvalidCursorType apiErrorType = "ErrInvalidCursor"
+
+	// ErrCursorFilterMismatch error message returned when a pagination
+	// cursor is used with different filters than the ones it was minted for
+	ErrCursorFilterMismatch = "Cursor does not match the provided filters, " +
+		"filters must not change mid-pagination"
+	// ErrCursorFilterMismatchCode code for cursor filter mismatch error
+	ErrCursorFilterMismatchCode apiErrorCode = 15
+	// ErrCursorFilterMismatchType type for cursor filter mismatch error
+	ErrCursorFilterMismatchType apiErrorType = "ErrCursorFilterMismatch"
+
+	// ErrTokenDenied error message returned when receiving (and rejecting) a tx
+	// of a token denied by the operator
+	ErrTokenDenied = "Transaction rejected because the token is denied by the operator"
+	// ErrTokenDeniedCode code for token denied error
+	ErrTokenDeniedCode apiErrorCode = 16
+	// ErrTokenDeniedType type for token denied error
+	ErrTokenDeniedType apiErrorType = "ErrTokenDenied"
+
+	// ErrTokenPaused error message returned when receiving (and rejecting) a tx
+	// of a token temporarily paused by the operator
+	ErrTokenPaused = "Transaction rejected because the token is temporarily paused by the operator"
+	// ErrTokenPausedCode code for token paused error
+	ErrTokenPausedCode apiErrorCode = 17
+	// ErrTokenPausedType type for token paused error
+	ErrTokenPausedType apiErrorType = "ErrTokenPaused"
+
+	// ErrInvalidTokenPolicy error message returned when setting a token policy
+	// that is not "denied", "paused" or "none"
+	ErrInvalidTokenPolicy = "Invalid policy: must be one of denied, paused, none"
+	// ErrInvalidTokenPolicyCode code for invalid token policy error
+	ErrInvalidTokenPolicyCode apiErrorCode = 18
+	// ErrInvalidTokenPolicyType type for invalid token policy error
+	ErrInvalidTokenPolicyType apiErrorType = "ErrInvalidTokenPolicy"
+
+	// ErrInvalidTokenPrice error message returned when pinning a token price
+	// that is not a positive amount
+	ErrInvalidTokenPrice = "Invalid price: USD must be a positive amount or null to unpin"
+	// ErrInvalidTokenPriceCode code for invalid token price error
+	ErrInvalidTokenPriceCode apiErrorCode = 19
+	// ErrInvalidTokenPriceType type for invalid token price error
+	ErrInvalidTokenPriceType apiErrorType = "ErrInvalidTokenPrice"
+
+	// ErrEmptySearchQuery error message returned when the search endpoint
+	// receives an empty q parameter
+	ErrEmptySearchQuery = "Empty search query"
+	// ErrEmptySearchQueryCode code for empty search query error
+	ErrEmptySearchQueryCode apiErrorCode = 20
+	// ErrEmptySearchQueryType type for empty search query error
+	ErrEmptySearchQueryType apiErrorType = "ErrEmptySearchQuery"
+
+	// ErrFeeTooLow error message returned when receiving (and rejecting) a
+	// tx whose fee is below the minimum required by the coordinator fee
+	// policy
+	ErrFeeTooLow = "Transaction rejected because the fee is lower than the minimum required by the coordinator"
+	// ErrFeeTooLowCode code for fee too low error
+	ErrFeeTooLowCode apiErrorCode = 21
+	// ErrFeeTooLowType type for fee too low error
+	ErrFeeTooLowType apiErrorType = "ErrFeeTooLow"
+
+	// ErrFreeTxAllowanceExceeded error message returned when receiving
+	// (and rejecting) a tx whose fee is below the minimum required by the
+	// coordinator fee policy and the sender account has used up its free
+	// tx allowance for the day
+	ErrFreeTxAllowanceExceeded = "Transaction rejected because the fee is lower than the minimum " +
+		"required by the coordinator and the free transaction allowance of the account is exhausted"
+	// ErrFreeTxAllowanceExceededCode code for free tx allowance exceeded error
+	ErrFreeTxAllowanceExceededCode apiErrorCode = 22
+	// ErrFreeTxAllowanceExceededType type for free tx allowance exceeded error
+	ErrFreeTxAllowanceExceededType apiErrorType = "ErrFreeTxAllowanceExceeded"
+
+	// ErrTxAdminDeleted error message returned when receiving (and
+	// rejecting) a tx whose TxID was previously deleted by the operator
+	ErrTxAdminDeleted = "Transaction rejected because its TxID was previously deleted by the operator"
+	// ErrTxAdminDeletedCode code for tx admin deleted error
+	ErrTxAdminDeletedCode apiErrorCode = 23
+	// ErrTxAdminDeletedType type for tx admin deleted error
+	ErrTxAdminDeletedType apiErrorType = "ErrTxAdminDeleted"
+
+	// ErrTxNotPending error message returned when trying to delete a pool
+	// tx that is not in pending state
+	ErrTxNotPending = "The transaction can't be deleted because it is not in pending state"
+	// ErrTxNotPendingCode code for tx not pending error
+	ErrTxNotPendingCode apiErrorCode = 24
+	// ErrTxNotPendingType type for tx not pending error
+	ErrTxNotPendingType apiErrorType = "ErrTxNotPending"
+
+	// ErrInvalidWebhookEventType error message returned when registering a
+	// webhook with an unknown event type
+	ErrInvalidWebhookEventType = "Invalid webhook event type"
+	// ErrInvalidWebhookEventTypeCode code for invalid webhook event type error
+	ErrInvalidWebhookEventTypeCode apiErrorCode = 25
+	// ErrInvalidWebhookEventTypeType type for invalid webhook event type error
+	ErrInvalidWebhookEventTypeType apiErrorType = "ErrInvalidWebhookEventType"
+
+	// ErrStaleState error message returned when rejecting pool txs while
+	// the synchronizer lags behind the ethereum head
+	ErrStaleState = "The node is syncing and transactions can't be validated against the current state, try again later"
+	// ErrStaleStateCode code for stale state error
+	ErrStaleStateCode apiErrorCode = 26
+	// ErrStaleStateType type for stale state error
+	ErrStaleStateType apiErrorType = "ErrStaleState"
+
+	// ErrReplaceFeeTooLow error message returned when rejecting a pool tx
+	// from an account and nonce that already has a pending tx, because
+	// the fee of the new tx is not strictly higher than the pending one
+	ErrReplaceFeeTooLow = "A transaction from the same account and nonce is already pending. " +
+		"To replace it the new transaction must pay a strictly higher fee"
+	// ErrReplaceFeeTooLowCode code for replace fee too low error
+	ErrReplaceFeeTooLowCode apiErrorCode = 27
+	// ErrReplaceFeeTooLowType type for replace fee too low error
+	ErrReplaceFeeTooLowType apiErrorType = "ErrReplaceFeeTooLow"
+
+	// ErrAmountNotRepresentable error message returned when rejecting a
+	// pool tx whose amount can't be encoded as Float40 without losing
+	// precision, and that would therefore be forged with a different
+	// amount than the signed one.  The nearest lower representable
+	// amount is appended to the message
+	ErrAmountNotRepresentable = "Amount can't be encoded as Float40 without losing precision. " +
+		"Nearest lower representable amount: "
+	// ErrAmountNotRepresentableCode code for amount not representable error
+	ErrAmountNotRepresentableCode apiErrorCode = 28
+	// ErrAmountNotRepresentableType type for amount not representable error
+	ErrAmountNotRepresentableType apiErrorType = "ErrAmountNotRepresentable"
+
+	// ErrMaxNumBatchPassed error message returned when rejecting a pool tx
+	// whose MaxNumBatch is not greater than the last forged batch, as it
+	// could never be selected
+	ErrMaxNumBatchPassed = "MaxNumBatch must be greater than the last forged batch"
+	// ErrMaxNumBatchPassedCode code for max num batch passed error
+	ErrMaxNumBatchPassedCode apiErrorCode = 29
+	// ErrMaxNumBatchPassedType type for max num batch passed error
+	ErrMaxNumBatchPassedType apiErrorType = "ErrMaxNumBatchPassed"
+
+	// ErrInclusionProofNotAvailable error message returned by the tx
+	// inclusion proof endpoint when the tx was synchronized before the
+	// calldata byte offsets were recorded
+	ErrInclusionProofNotAvailable = "The calldata byte offsets of this transaction were not recorded"
+	// ErrInclusionProofNotAvailableCode code for inclusion proof not available error
+	ErrInclusionProofNotAvailableCode apiErrorCode = 30
+	// ErrInclusionProofNotAvailableType type for inclusion proof not available error
+	ErrInclusionProofNotAvailableType apiErrorType = "ErrInclusionProofNotAvailable"
+
+	// Internal error messages (used for logs or handling errors returned from internal comopnents)
+
+	// errCtxTimeout error message received internally when context reaches timeout
+	errCtxTimeout = "context deadline exceeded"
 )
+
+var (
+	// errJWTNotEnabled is returned when a JWT is presented but no JWT secret has
+	// been configured
+	errJWTNotEnabled = errors.New("JWT authentication is not enabled")
+	// errJWTInvalid is returned when a JWT fails to parse or its signature doesn't verify
+	errJWTInvalid = errors.New("invalid JWT")
+	// errJWTExpired is returned when a JWT is past its expiration claim
+	errJWTExpired = errors.New("expired JWT")
+)
+
+// apiError wraps an error with the stable code and type that must be
+// returned along its message, so that SDKs can branch on the code instead of
+// parsing the message text
+type apiError struct {
+	Err  error
+	Code apiErrorCode
+	Type apiErrorType
+}
+
+// apiErrorResponse is the JSON body returned on API errors.  Message keeps
+// the legacy free-text error for backwards compatibility, while Code and
+// Type are stable and machine-readable
+type apiErrorResponse struct {
+	Message string       `json:"message"`
+	Code    apiErrorCode `json:"code"`
+	Type    apiErrorType `json:"type"`
+	// ParamError carries field-level details when the error is a parse
+	// failure of a single parameter or field
+	ParamError *apiParamError `json:"paramError,omitempty"`
+}
+
+// apiParamError is the field-level detail attached to parameter parse
+// failures, built from the typed parse errors of the common package
+type apiParamError struct {
+	// Param is the name of the offending parameter or field
+	Param string `json:"param"`
+	// Value is the received value that failed to parse
+	Value string `json:"value"`
+	// Expected describes the format that the value must follow
+	Expected string `json:"expected"`
+}
+
+func (a apiError) Error() string {
+	return a.Err.Error()
+}
@@ -1,10 +1,14 @@
 package api
 
 import (
+	"errors"
+	"math/big"
 	"net/http"
 
 	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,15 +26,18 @@ func (a *API) getExits(c *gin.Context) {
 		retSQLErr(err, c)
 		return
 	}
+	a.setInstantWithdrawAllowed(exits)
 
 	// Build successful response
 	type exitsResponse struct {
 		Exits        []historydb.ExitAPI `json:"exits"`
 		PendingItems uint64              `json:"pendingItems"`
+		NextCursor   string              `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &exitsResponse{
 		Exits:        exits,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, exits, pendingItems),
 	})
 }
 
@@ -47,6 +54,103 @@ func (a *API) getExit(c *gin.Context) {
 		retSQLErr(err, c)
 		return
 	}
+	exits := []historydb.ExitAPI{*exit}
+	a.setInstantWithdrawAllowed(exits)
 	// Build successful response
-	c.JSON(http.StatusOK, exit)
+	c.JSON(http.StatusOK, exits[0])
+}
+
+// setInstantWithdrawAllowed fills the InstantWithdrawAllowed field of the
+// exits that have not been withdrawn yet, evaluating the bucket check of the
+// Rollup Smart Contract against the current bucket state of the GET /state
+// object.  The predicate is informative only: on error it is left unset
+// rather than failing the request
+func (a *API) setInstantWithdrawAllowed(exits []historydb.ExitAPI) {
+	state, err := a.h.GetStateInternalAPI()
+	if err != nil {
+		log.Warnw("setInstantWithdrawAllowed: GetStateInternalAPI", "err", err)
+		return
+	}
+	for i := range exits {
+		exits[i].InstantWithdrawAllowed = instantWithdrawAllowed(&exits[i], state)
+	}
+}
+
+// instantWithdrawAllowed evaluates the bucket check for a single exit, nil
+// when the predicate does not apply (already withdrawn) or can not be
+// evaluated (unknown token USD value)
+func instantWithdrawAllowed(exit *historydb.ExitAPI, state *historydb.StateAPI) *bool {
+	if exit.InstantWithdrawn != nil || exit.DelayedWithdrawRequest != nil ||
+		exit.DelayedWithdrawn != nil {
+		return nil
+	}
+	allowed, notAllowed := true, false
+	if state.Rollup.SafeMode {
+		return &notAllowed
+	}
+	if len(state.Rollup.Buckets) == 0 {
+		return &allowed
+	}
+	if exit.TokenUSD == nil {
+		return nil
+	}
+	balance, ok := new(big.Int).SetString(string(exit.Balance), 10)
+	if !ok {
+		return nil
+	}
+	amountUSD := common.TokensToUSD(balance, exit.TokenDecimals, *exit.TokenUSD)
+	// As in the smart contract, zero USD amounts skip the bucket check
+	if amountUSD == 0 {
+		return &allowed
+	}
+	// Find the first bucket whose ceiling covers the amount; if the amount
+	// exceeds all the ceilings the instant withdraw is not allowed
+	for _, bucket := range state.Rollup.Buckets {
+		if bucket.CeilUSD == nil {
+			continue
+		}
+		ceilUSD, ok := new(big.Float).SetString(string(*bucket.CeilUSD))
+		if !ok {
+			continue
+		}
+		ceil, _ := ceilUSD.Float64()
+		if amountUSD <= ceil {
+			if bucket.WithdrawalsAvailable == nil {
+				return nil
+			}
+			withdrawals, ok := new(big.Int).SetString(
+				string(*bucket.WithdrawalsAvailable), 10)
+			if !ok {
+				return nil
+			}
+			bucketAllowed := withdrawals.Sign() > 0
+			return &bucketAllowed
+		}
+	}
+	return &notAllowed
+}
+
+func (a *API) getExitsSummary(c *gin.Context) {
+	// Get hezEthereumAddress
+	addrStr := c.Query("hezEthereumAddress")
+	if addrStr == "" {
+		retBadReq(errors.New("hezEthereumAddress is required"), c)
+		return
+	}
+	addr, err := common.HezStringToEthAddr(addrStr, "hezEthereumAddress")
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch the per token summary from historyDB
+	summary, err := a.h.GetExitsSummaryAPI(*addr)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Build successful response
+	type exitsSummaryResponse struct {
+		Summary []historydb.ExitsSummaryAPI `json:"summary"`
+	}
+	c.JSON(http.StatusOK, &exitsSummaryResponse{Summary: summary})
 }
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"math"
+	"math/big"
+	"net/http"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/gin-gonic/gin"
+)
+
+// feeEstimate is the cost of applying one fee selector to the requested
+// amount
+type feeEstimate struct {
+	// FeeSelector is the value to set in the fee field of the tx
+	FeeSelector common.FeeSelector `json:"feeSelector"`
+	// Factor is the fraction of the amount that the selector charges
+	Factor float64 `json:"factor"`
+	// Amount is the fee in token units
+	Amount apitypes.BigIntStr `json:"amount"`
+	// USD is the fee in USD, null when the token has no known price
+	USD *float64 `json:"USD"`
+	// BelowMinimum is true when a tx paying this fee would be rejected
+	// by this coordinator because of its minimum fee policy
+	BelowMinimum bool `json:"belowMinimum"`
+}
+
+// getFeeEstimate returns, for every fee selector, the absolute fee that a tx
+// of the given token and amount would pay, in token units and in USD, and
+// whether the selector is below the minimum fee policy of this coordinator.
+// It saves wallets from re-implementing the fee factor table
+func (a *API) getFeeEstimate(c *gin.Context) {
+	tokenID, amount, err := parsers.ParseFeeEstimateFilters(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Check that the token exists, returning 404 otherwise, and get its
+	// current price
+	token, err := a.h.GetTokenAPI(tokenID)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	var amountUSD *float64
+	if token.USD != nil {
+		amountF, _ := new(big.Float).SetInt(amount).Float64()
+		aUSD := amountF * *token.USD / math.Pow(10, float64(token.Decimals))
+		amountUSD = &aUSD
+	}
+	minFeeUSD := a.l2.MinFeeUSD()
+	tokenMinFee := a.l2.TokenMinFee(tokenID)
+	estimates := make([]feeEstimate, 0, 256)
+	for i := 0; i < 256; i++ {
+		feeSel := common.FeeSelector(i)
+		feeAmount, err := common.CalcFeeAmount(amount, feeSel)
+		if err != nil {
+			retBadReq(err, c)
+			return
+		}
+		estimate := feeEstimate{
+			FeeSelector: feeSel,
+			Factor:      feeSel.Percentage(),
+			Amount:      *apitypes.NewBigIntStr(feeAmount),
+		}
+		if amountUSD != nil {
+			feeUSD := *amountUSD * feeSel.Percentage()
+			estimate.USD = &feeUSD
+			estimate.BelowMinimum = feeUSD < minFeeUSD
+		}
+		if feeSel < tokenMinFee {
+			estimate.BelowMinimum = true
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	// Build successful response
+	type feeEstimateResponse struct {
+		Token     historydb.TokenWithUSD `json:"token"`
+		Amount    apitypes.BigIntStr     `json:"amount"`
+		MinFeeUSD float64                `json:"minFeeUSD"`
+		Estimates []feeEstimate          `json:"estimates"`
+	}
+	c.JSON(http.StatusOK, &feeEstimateResponse{
+		Token:     *token,
+		Amount:    *apitypes.NewBigIntStr(amount),
+		MinFeeUSD: minFeeUSD,
+		Estimates: estimates,
+	})
+}
@@ -13,7 +13,7 @@ func (a *API) getFiatCurrency(c *gin.Context) {
 	// Get symbol
 	symbol, err := parsers.ParseCurrencyFilter(c)
 	if err != nil {
-		retBadReq(errors.New(ErrInvalidSymbol), c)
+		retBadReq(&apiError{Err: errors.New(ErrInvalidSymbol), Code: ErrInvalidSymbolCode, Type: ErrInvalidSymbolType}, c)
 		return
 	}
 	// Fetch currency from historyDB
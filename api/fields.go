@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// fieldsSelection is the set of fields requested through the `fields` query
+// param, as a tree where nested selections are expressed with dots
+// (e.g. "token.symbol")
+type fieldsSelection map[string]fieldsSelection
+
+// parseFieldsSelection parses the comma separated `fields` query param of the
+// request, nil when the param is not set
+func parseFieldsSelection(c *gin.Context) fieldsSelection {
+	fieldsStr := c.Query("fields")
+	if fieldsStr == "" {
+		return nil
+	}
+	selection := fieldsSelection{}
+	for _, field := range strings.Split(fieldsStr, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		node := selection
+		for _, part := range strings.Split(field, ".") {
+			child, ok := node[part]
+			if !ok {
+				child = fieldsSelection{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	if len(selection) == 0 {
+		return nil
+	}
+	return selection
+}
+
+// filter returns value keeping only the selected fields.  Arrays are filtered
+// element by element and nested selections apply to nested objects.  The
+// itemId field is always kept so that fromItem pagination keeps working on
+// filtered responses
+func (s fieldsSelection) filter(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		filtered := make(map[string]interface{}, len(s)+1)
+		for name, child := range s {
+			fieldValue, ok := v[name]
+			if !ok {
+				continue
+			}
+			if len(child) > 0 {
+				fieldValue = child.filter(fieldValue)
+			}
+			filtered[name] = fieldValue
+		}
+		if itemID, ok := v["itemId"]; ok {
+			filtered["itemId"] = itemID
+		}
+		return filtered
+	case []interface{}:
+		for i := range v {
+			v[i] = s.filter(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// retJSONWithFields writes resp as a successful JSON response, applying the
+// `fields` query param selection, if any, to the elements of the itemsKey
+// array of the response.  The rest of the response (pendingItems, nextCursor)
+// is always kept.  The selection is applied on the serialized representation,
+// so the field names are the JSON ones and no extra SQL is involved
+func retJSONWithFields(c *gin.Context, resp interface{}, itemsKey string) {
+	selection := parseFieldsSelection(c)
+	if selection == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		retSQLErr(tracerr.Wrap(err), c)
+		return
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		retSQLErr(tracerr.Wrap(err), c)
+		return
+	}
+	if items, ok := obj[itemsKey].([]interface{}); ok {
+		for i := range items {
+			items[i] = selection.filter(items[i])
+		}
+	}
+	c.JSON(http.StatusOK, obj)
+}
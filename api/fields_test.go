@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFieldsSelection(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET",
+		"/transactions-history?fields=amount,token.symbol,token.decimals", nil)
+	selection := parseFieldsSelection(c)
+	require.NotNil(t, selection)
+	assert.Len(t, selection, 2)
+	assert.Len(t, selection["amount"], 0)
+	assert.Len(t, selection["token"], 2)
+
+	// No fields param
+	c.Request = httptest.NewRequest("GET", "/transactions-history", nil)
+	assert.Nil(t, parseFieldsSelection(c))
+
+	// Empty fields param
+	c.Request = httptest.NewRequest("GET", "/transactions-history?fields=,", nil)
+	assert.Nil(t, parseFieldsSelection(c))
+}
+
+func TestFieldsSelectionFilter(t *testing.T) {
+	selection := fieldsSelection{
+		"amount": fieldsSelection{},
+		"token": fieldsSelection{
+			"symbol": fieldsSelection{},
+		},
+	}
+	item := map[string]interface{}{
+		"itemId":   float64(3),
+		"amount":   "1000",
+		"batchNum": float64(42),
+		"token": map[string]interface{}{
+			"id":     float64(1),
+			"symbol": "HEZ",
+		},
+	}
+	filtered := selection.filter(item).(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		// itemId is always kept so that pagination keeps working
+		"itemId": float64(3),
+		"amount": "1000",
+		"token": map[string]interface{}{
+			"symbol": "HEZ",
+		},
+	}, filtered)
+
+	// Unknown selected fields are skipped
+	selection = fieldsSelection{"unknown": fieldsSelection{}}
+	filtered = selection.filter(item).(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"itemId": float64(3)}, filtered)
+}
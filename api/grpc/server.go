@@ -0,0 +1,622 @@
+// Package grpc implements the gRPC surface of the node, serving the services
+// defined in hermez.proto (accounts, txs, batches and pool submission) over
+// the same hand-rolled gRPC wire protocol used by the prover gRPC client: the
+// gRPC framing over HTTP/2 (h2c) with the JSON codec, so that no extra
+// dependencies nor generated protobuf code are required.  With the JSON codec
+// the messages are encoded with the JSON field names of the REST API instead
+// of the proto field names, so replies are byte compatible with the REST
+// responses.
+//
+// The server queries the historydb/l2db through the same *API methods as the
+// REST handlers, so both surfaces share the API connection limiter and see
+// the same data and the same backpressure.
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/api"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// servicePathPrefix is the path prefix of all the services of hermez.proto
+const servicePathPrefix = "/hermez.v1."
+
+// contentType is the gRPC content type of the JSON codec
+const contentType = "application/grpc+json"
+
+// gRPC status codes used by the server (a subset of the canonical codes)
+const (
+	codeOK              = 0
+	codeCanceled        = 1
+	codeInvalidArgument = 3
+	codeDeadline        = 4
+	codeNotFound        = 5
+	codeAlreadyExists   = 6
+	codeUnimplemented   = 12
+	codeInternal        = 13
+)
+
+// dfltLimit and maxLimit mirror the pagination bounds of the REST API
+const (
+	dfltLimit = 20
+	maxLimit  = 2049
+)
+
+// dfltWatchInterval is the default poll interval of the WatchBatches stream
+const dfltWatchInterval = 2 * time.Second
+
+// PoolSubmitter accepts a pool tx submission, running the same admission
+// pipeline as POST /transactions-pool.  It is implemented by *api.API
+type PoolSubmitter interface {
+	SubmitPoolTx(body []byte, clientIP string, forwarded bool) (common.TxID, error)
+}
+
+// Server serves the hermez.v1 gRPC services.  It implements http.Handler and
+// is meant to be served over plaintext HTTP/2 (h2c)
+type Server struct {
+	hdb           *historydb.HistoryDB
+	l2            *l2db.L2DB
+	pool          PoolSubmitter
+	watchInterval time.Duration
+}
+
+// NewServer creates a new gRPC Server using the given DB access layers.  The
+// Pool.SubmitPoolTx method returns Unimplemented until a PoolSubmitter is set
+// with SetPoolSubmitter
+func NewServer(hdb *historydb.HistoryDB, l2 *l2db.L2DB) *Server {
+	return &Server{
+		hdb:           hdb,
+		l2:            l2,
+		watchInterval: dfltWatchInterval,
+	}
+}
+
+// SetPoolSubmitter sets the PoolSubmitter used by Pool.SubmitPoolTx
+func (s *Server) SetPoolSubmitter(pool PoolSubmitter) {
+	s.pool = pool
+}
+
+// SetWatchInterval sets the poll interval of the Batches.WatchBatches stream
+func (s *Server) SetWatchInterval(interval time.Duration) {
+	s.watchInterval = interval
+}
+
+// statusError is an error carrying an explicit gRPC status code
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// invalidArgument wraps a request parsing/validation error with the
+// InvalidArgument status code
+func invalidArgument(err error) error {
+	return tracerr.Wrap(&statusError{code: codeInvalidArgument, err: err})
+}
+
+// errStatus maps an error of a service method to its gRPC status code and
+// message
+func errStatus(err error) (int, string) {
+	switch e := tracerr.Unwrap(err).(type) {
+	case *statusError:
+		return e.code, e.err.Error()
+	case *api.InvalidPoolTxError:
+		return codeInvalidArgument, e.Error()
+	case *common.ParseError:
+		return codeInvalidArgument, e.Error()
+	}
+	switch tracerr.Unwrap(err) {
+	case sql.ErrNoRows:
+		return codeNotFound, "not found"
+	case l2db.ErrTxIDConflict:
+		return codeAlreadyExists, l2db.ErrTxIDConflict.Error()
+	}
+	return codeInternal, err.Error()
+}
+
+// grpcFrame wraps a JSON encoded message with the gRPC length-prefixed frame
+// header (1 byte compression flag + 4 bytes big endian length)
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5+len(msg)) //nolint:gomnd
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	return frame
+}
+
+// grpcUnframe extracts the JSON encoded message from a gRPC length-prefixed
+// frame
+func grpcUnframe(data []byte) ([]byte, error) {
+	if len(data) < 5 { //nolint:gomnd
+		return nil, tracerr.Wrap(fmt.Errorf("gRPC frame too short: %v bytes", len(data)))
+	}
+	if data[0] != 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("unexpected compressed gRPC frame"))
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		return nil, tracerr.Wrap(fmt.Errorf("incomplete gRPC frame"))
+	}
+	return data[5 : 5+length], nil
+}
+
+// parseGrpcTimeout parses a grpc-timeout header value ("100m", "5S", ...)
+func parseGrpcTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 { //nolint:gomnd
+		return 0, false
+	}
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(amount) * unit, true
+}
+
+// writeStatus sets the gRPC status trailers of the response.  The Trailer
+// keys are declared before the response headers are written, so net/http
+// sends them as real HTTP/2 trailers
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+}
+
+// clientIP extracts the IP of the caller from the request, so that pool
+// submissions through gRPC are rate-limited per client like the REST ones
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// ServeHTTP routes a gRPC request (POST /hermez.v1.<Service>/<Method>) to the
+// matching service method
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost ||
+		!strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Add("Trailer", "Grpc-Status")
+	w.Header().Add("Trailer", "Grpc-Message")
+	w.Header().Set("Content-Type", contentType)
+	if timeout, ok := parseGrpcTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, codeInternal, err.Error())
+		return
+	}
+	msg, err := grpcUnframe(body)
+	if err != nil {
+		writeStatus(w, codeInvalidArgument, err.Error())
+		return
+	}
+	var reply interface{}
+	switch strings.TrimPrefix(r.URL.Path, servicePathPrefix) {
+	case "Accounts/GetAccount":
+		reply, err = s.getAccount(msg)
+	case "Accounts/ListAccounts":
+		reply, err = s.listAccounts(msg)
+	case "Txs/GetTx":
+		reply, err = s.getTx(msg)
+	case "Txs/ListTxs":
+		reply, err = s.listTxs(msg)
+	case "Batches/GetBatch":
+		reply, err = s.getBatch(msg)
+	case "Batches/ListBatches":
+		reply, err = s.listBatches(msg)
+	case "Batches/WatchBatches":
+		s.watchBatches(w, r, msg)
+		return
+	case "Pool/SubmitPoolTx":
+		reply, err = s.submitPoolTx(msg, clientIP(r))
+	case "Pool/GetPoolTx":
+		reply, err = s.getPoolTx(msg)
+	default:
+		writeStatus(w, codeUnimplemented,
+			fmt.Sprintf("unknown method %v", r.URL.Path))
+		return
+	}
+	if err != nil {
+		code, message := errStatus(err)
+		writeStatus(w, code, message)
+		return
+	}
+	encoded, err := json.Marshal(reply)
+	if err != nil {
+		writeStatus(w, codeInternal, err.Error())
+		return
+	}
+	if _, err := w.Write(grpcFrame(encoded)); err != nil {
+		return
+	}
+	writeStatus(w, codeOK, "")
+}
+
+// pagination carries the REST-style cursor pagination of the list requests
+type pagination struct {
+	FromItem *uint  `json:"fromItem"`
+	Order    string `json:"order"`
+	Limit    *uint  `json:"limit"`
+}
+
+// normalize applies the REST pagination defaults and bounds
+func (p *pagination) normalize() error {
+	switch p.Order {
+	case "":
+		p.Order = db.OrderAsc
+	case db.OrderAsc, db.OrderDesc:
+	default:
+		return invalidArgument(fmt.Errorf("invalid order: %v", p.Order))
+	}
+	if p.Limit == nil {
+		limit := uint(dfltLimit)
+		p.Limit = &limit
+	} else if *p.Limit < 1 || *p.Limit > maxLimit {
+		return invalidArgument(fmt.Errorf("invalid limit: %v", *p.Limit))
+	}
+	return nil
+}
+
+type getAccountRequest struct {
+	AccountIndex string `json:"accountIndex"`
+}
+
+func (s *Server) getAccount(msg []byte) (interface{}, error) {
+	var req getAccountRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	idx, err := common.StringToIdx(req.AccountIndex, "accountIndex")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if idx == nil {
+		return nil, invalidArgument(errors.New("accountIndex is required"))
+	}
+	return s.hdb.GetAccountAPI(*idx)
+}
+
+type listAccountsRequest struct {
+	HezEthereumAddress string   `json:"hezEthereumAddress"`
+	BJJ                string   `json:"BJJ"`
+	TokenIDs           []uint32 `json:"tokenIds"`
+	pagination
+}
+
+// listAccountsReply mirrors the REST /accounts response
+type listAccountsReply struct {
+	Accounts     []historydb.AccountAPI `json:"accounts"`
+	PendingItems uint64                 `json:"pendingItems"`
+}
+
+func (s *Server) listAccounts(msg []byte) (interface{}, error) {
+	var req listAccountsRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := req.normalize(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	addr, err := common.HezStringToEthAddr(req.HezEthereumAddress, "hezEthereumAddress")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	bjj, err := common.HezStringToBJJ(req.BJJ, "BJJ")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if addr != nil && bjj != nil {
+		return nil, invalidArgument(errors.New(
+			"hezEthereumAddress and BJJ are incompatible"))
+	}
+	tokenIDs := make([]common.TokenID, len(req.TokenIDs))
+	for i, tokenID := range req.TokenIDs {
+		tokenIDs[i] = common.TokenID(tokenID)
+	}
+	accounts, pendingItems, err := s.hdb.GetAccountsAPI(historydb.GetAccountsAPIRequest{
+		TokenIDs: tokenIDs,
+		EthAddr:  addr,
+		Bjj:      bjj,
+		FromItem: req.FromItem,
+		Limit:    req.Limit,
+		Order:    req.Order,
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &listAccountsReply{Accounts: accounts, PendingItems: pendingItems}, nil
+}
+
+type getTxRequest struct {
+	ID string `json:"id"`
+}
+
+func (s *Server) getTx(msg []byte) (interface{}, error) {
+	var req getTxRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	txID, err := common.NewTxIDFromString(req.ID)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return s.hdb.GetTxAPI(txID)
+}
+
+type listTxsRequest struct {
+	HezEthereumAddress string  `json:"hezEthereumAddress"`
+	BJJ                string  `json:"BJJ"`
+	AccountIndex       string  `json:"accountIndex"`
+	TokenID            *uint32 `json:"tokenId"`
+	BatchNum           *uint   `json:"batchNum"`
+	Type               string  `json:"type"`
+	pagination
+}
+
+// listTxsReply mirrors the REST /transactions-history response
+type listTxsReply struct {
+	Txs          []historydb.TxAPI `json:"transactions"`
+	PendingItems uint64            `json:"pendingItems"`
+}
+
+func (s *Server) listTxs(msg []byte) (interface{}, error) {
+	var req listTxsRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := req.normalize(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	addr, err := common.HezStringToEthAddr(req.HezEthereumAddress, "hezEthereumAddress")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	bjj, err := common.HezStringToBJJ(req.BJJ, "BJJ")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	idx, err := common.StringToIdx(req.AccountIndex, "accountIndex")
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	txType, err := common.StringToTxType(req.Type)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	var tokenID *common.TokenID
+	if req.TokenID != nil {
+		tokenIDCasted := common.TokenID(*req.TokenID)
+		tokenID = &tokenIDCasted
+	}
+	txs, pendingItems, err := s.hdb.GetTxsAPI(historydb.GetTxsAPIRequest{
+		EthAddr:  addr,
+		Bjj:      bjj,
+		TokenID:  tokenID,
+		Idx:      idx,
+		BatchNum: req.BatchNum,
+		TxType:   txType,
+		FromItem: req.FromItem,
+		Limit:    req.Limit,
+		Order:    req.Order,
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &listTxsReply{Txs: txs, PendingItems: pendingItems}, nil
+}
+
+type getBatchRequest struct {
+	BatchNum int64 `json:"batchNum"`
+}
+
+func (s *Server) getBatch(msg []byte) (interface{}, error) {
+	var req getBatchRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if req.BatchNum < 0 {
+		return nil, invalidArgument(fmt.Errorf("invalid batchNum: %v", req.BatchNum))
+	}
+	return s.hdb.GetBatchAPI(common.BatchNum(req.BatchNum))
+}
+
+type listBatchesRequest struct {
+	MinBatchNum *uint  `json:"minBatchNum"`
+	MaxBatchNum *uint  `json:"maxBatchNum"`
+	ForgerAddr  string `json:"forgerAddr"`
+	pagination
+}
+
+// listBatchesReply mirrors the REST /batches response
+type listBatchesReply struct {
+	Batches      []historydb.BatchAPI `json:"batches"`
+	PendingItems uint64               `json:"pendingItems"`
+}
+
+func (s *Server) listBatches(msg []byte) (interface{}, error) {
+	var req listBatchesRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := req.normalize(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	forgerAddr, err := common.StringToEthAddr(req.ForgerAddr)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	batches, pendingItems, err := s.hdb.GetBatchesAPI(historydb.GetBatchesAPIRequest{
+		MinBatchNum: req.MinBatchNum,
+		MaxBatchNum: req.MaxBatchNum,
+		ForgerAddr:  forgerAddr,
+		FromItem:    req.FromItem,
+		Limit:       req.Limit,
+		Order:       req.Order,
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &listBatchesReply{Batches: batches, PendingItems: pendingItems}, nil
+}
+
+type watchBatchesRequest struct {
+	FromBatchNum int64 `json:"fromBatchNum"`
+}
+
+// watchBatches serves the Batches.WatchBatches server stream: it pushes one
+// frame per forged batch, polling the historydb for batches beyond the last
+// sent one, until the client goes away
+func (s *Server) watchBatches(w http.ResponseWriter, r *http.Request, msg []byte) {
+	var req watchBatchesRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		writeStatus(w, codeInvalidArgument, err.Error())
+		return
+	}
+	if req.FromBatchNum < 0 {
+		writeStatus(w, codeInvalidArgument,
+			fmt.Sprintf("invalid fromBatchNum: %v", req.FromBatchNum))
+		return
+	}
+	// lastSent is the last batch number already seen by the client:
+	// fromBatchNum == 0 subscribes to the batches forged from now on
+	var lastSent common.BatchNum
+	if req.FromBatchNum == 0 {
+		lastBatchNum, err := s.hdb.GetLastBatchNum()
+		if err != nil && tracerr.Unwrap(err) != sql.ErrNoRows {
+			writeStatus(w, codeInternal, err.Error())
+			return
+		}
+		lastSent = lastBatchNum
+	} else {
+		lastSent = common.BatchNum(req.FromBatchNum - 1)
+	}
+	flusher, _ := w.(http.Flusher)
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		for {
+			minBatchNum := uint(lastSent)
+			limit := uint(dfltLimit)
+			// MinBatchNum is an exclusive bound, so this returns
+			// the batches forged after lastSent
+			batches, _, err := s.hdb.GetBatchesAPI(historydb.GetBatchesAPIRequest{
+				MinBatchNum: &minBatchNum,
+				FromItem:    nil,
+				Limit:       &limit,
+				Order:       db.OrderAsc,
+			})
+			if err != nil {
+				code, message := errStatus(tracerr.Wrap(err))
+				writeStatus(w, code, message)
+				return
+			}
+			for i := range batches {
+				encoded, err := json.Marshal(batches[i])
+				if err != nil {
+					writeStatus(w, codeInternal, err.Error())
+					return
+				}
+				if _, err := w.Write(grpcFrame(encoded)); err != nil {
+					return
+				}
+				lastSent = batches[i].BatchNum
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if uint(len(batches)) < limit {
+				break
+			}
+		}
+		select {
+		case <-r.Context().Done():
+			if err := r.Context().Err(); err == context.DeadlineExceeded {
+				writeStatus(w, codeDeadline, err.Error())
+			} else {
+				writeStatus(w, codeCanceled, "stream canceled")
+			}
+			return
+		case <-time.After(s.watchInterval):
+		}
+	}
+}
+
+// submitPoolTxReply mirrors the SubmitPoolTxReply proto message
+type submitPoolTxReply struct {
+	ID string `json:"id"`
+}
+
+func (s *Server) submitPoolTx(msg []byte, ip string) (interface{}, error) {
+	if s.pool == nil {
+		return nil, tracerr.Wrap(&statusError{code: codeUnimplemented,
+			err: errors.New("pool submission is not enabled")})
+	}
+	// The PoolTx message maps one to one to the POST /transactions-pool
+	// body, so with the JSON codec the frame payload is the REST body
+	txID, err := s.pool.SubmitPoolTx(msg, ip, false)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &submitPoolTxReply{ID: txID.String()}, nil
+}
+
+type getPoolTxRequest struct {
+	ID string `json:"id"`
+}
+
+func (s *Server) getPoolTx(msg []byte) (interface{}, error) {
+	var req getPoolTxRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, invalidArgument(err)
+	}
+	txID, err := common.NewTxIDFromString(req.ID)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return s.l2.GetTxAPI(txID)
+}
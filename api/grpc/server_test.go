@@ -0,0 +1,207 @@
+package grpc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/api"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newTestServer serves srv over h2c on a random local port, returning the
+// base URL and a function to shut the server down
+func newTestServer(t *testing.T, srv *Server) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: h2c.NewHandler(srv, &http2.Server{})}
+	go func() { _ = server.Serve(listener) }()
+	return "http://" + listener.Addr().String(), func() { _ = server.Close() }
+}
+
+// newTestClient builds an h2c client like the one of the prover gRPC backend
+func newTestClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+}
+
+// grpcRawCall performs a gRPC call sending body as the raw request body,
+// returning the status code, the status message and the raw response body
+func grpcRawCall(t *testing.T, client *http.Client, baseURL, method string,
+	body []byte) (string, string, []byte) {
+	req, err := http.NewRequest(http.MethodPost,
+		baseURL+servicePathPrefix+method, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("TE", "trailers")
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close() //nolint:errcheck
+	resBody, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	// The status is sent in the trailers, or in the headers in
+	// trailers-only responses
+	status := res.Trailer.Get("Grpc-Status")
+	message := res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = res.Header.Get("Grpc-Status")
+		message = res.Header.Get("Grpc-Message")
+	}
+	return status, message, resBody
+}
+
+// grpcCall performs a gRPC call with the JSON encoding of body as the message
+func grpcCall(t *testing.T, client *http.Client, baseURL, method string,
+	body interface{}) (string, string, []byte) {
+	msg, err := json.Marshal(body)
+	require.NoError(t, err)
+	return grpcRawCall(t, client, baseURL, method, grpcFrame(msg))
+}
+
+func TestGRPCFrame(t *testing.T) {
+	msg := []byte(`{"id":"0x00"}`)
+	unframed, err := grpcUnframe(grpcFrame(msg))
+	require.NoError(t, err)
+	assert.Equal(t, msg, unframed)
+	// Empty messages are valid frames
+	unframed, err = grpcUnframe(grpcFrame([]byte{}))
+	require.NoError(t, err)
+	assert.Len(t, unframed, 0)
+	// Malformed frames
+	_, err = grpcUnframe([]byte{0, 0})
+	assert.Error(t, err)
+	_, err = grpcUnframe(grpcFrame(msg)[:7])
+	assert.Error(t, err)
+	compressed := grpcFrame(msg)
+	compressed[0] = 1
+	_, err = grpcUnframe(compressed)
+	assert.Error(t, err)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	baseURL, closeServer := newTestServer(t, NewServer(nil, nil))
+	defer closeServer()
+	client := newTestClient()
+	status, _, _ := grpcCall(t, client, baseURL, "Accounts/Frobnicate", struct{}{})
+	assert.Equal(t, "12", status)
+	status, _, _ = grpcCall(t, client, baseURL, "Nope/Nope", struct{}{})
+	assert.Equal(t, "12", status)
+}
+
+func TestMalformedFrame(t *testing.T) {
+	baseURL, closeServer := newTestServer(t, NewServer(nil, nil))
+	defer closeServer()
+	client := newTestClient()
+	status, _, _ := grpcRawCall(t, client, baseURL, "Accounts/GetAccount",
+		[]byte{0, 0})
+	assert.Equal(t, "3", status)
+}
+
+func TestRequestParsing(t *testing.T) {
+	// Request parsing happens before any DB access, so invalid arguments
+	// can be exercised without a DB
+	baseURL, closeServer := newTestServer(t, NewServer(nil, nil))
+	defer closeServer()
+	client := newTestClient()
+	status, message, _ := grpcCall(t, client, baseURL, "Accounts/GetAccount",
+		getAccountRequest{AccountIndex: "not-an-index"})
+	assert.Equal(t, "3", status)
+	assert.Contains(t, message, "accountIndex")
+	status, _, _ = grpcCall(t, client, baseURL, "Accounts/GetAccount",
+		getAccountRequest{})
+	assert.Equal(t, "3", status)
+	status, _, _ = grpcCall(t, client, baseURL, "Txs/GetTx",
+		getTxRequest{ID: "0xzz"})
+	assert.Equal(t, "3", status)
+	status, _, _ = grpcCall(t, client, baseURL, "Batches/GetBatch",
+		getBatchRequest{BatchNum: -1})
+	assert.Equal(t, "3", status)
+	status, _, _ = grpcCall(t, client, baseURL, "Accounts/ListAccounts",
+		listAccountsRequest{pagination: pagination{Order: "sideways"}})
+	assert.Equal(t, "3", status)
+}
+
+// poolSubmitterStub records the submission and returns a canned result
+type poolSubmitterStub struct {
+	body      []byte
+	clientIP  string
+	forwarded bool
+	txID      common.TxID
+	err       error
+}
+
+func (s *poolSubmitterStub) SubmitPoolTx(body []byte, clientIP string,
+	forwarded bool) (common.TxID, error) {
+	s.body = append([]byte{}, body...)
+	s.clientIP = clientIP
+	s.forwarded = forwarded
+	return s.txID, s.err
+}
+
+func TestSubmitPoolTx(t *testing.T) {
+	server := NewServer(nil, nil)
+	baseURL, closeServer := newTestServer(t, server)
+	defer closeServer()
+	client := newTestClient()
+	poolTx := map[string]interface{}{
+		"type":    "Transfer",
+		"tokenId": 0,
+		"amount":  "100",
+	}
+	// Without a PoolSubmitter the method is unimplemented
+	status, _, _ := grpcCall(t, client, baseURL, "Pool/SubmitPoolTx", poolTx)
+	assert.Equal(t, "12", status)
+	// The frame payload must reach the submitter untouched, as it is the
+	// POST /transactions-pool body
+	stub := &poolSubmitterStub{txID: common.TxID{2}}
+	server.SetPoolSubmitter(stub)
+	status, _, resBody := grpcCall(t, client, baseURL, "Pool/SubmitPoolTx", poolTx)
+	require.Equal(t, "0", status)
+	expectedBody, err := json.Marshal(poolTx)
+	require.NoError(t, err)
+	assert.Equal(t, expectedBody, stub.body)
+	assert.Equal(t, "127.0.0.1", stub.clientIP)
+	assert.False(t, stub.forwarded)
+	reply, err := grpcUnframe(resBody)
+	require.NoError(t, err)
+	var submitReply submitPoolTxReply
+	require.NoError(t, json.Unmarshal(reply, &submitReply))
+	assert.Equal(t, stub.txID.String(), submitReply.ID)
+	// Admission failures caused by the tx map to InvalidArgument
+	stub.err = tracerr.Wrap(&api.InvalidPoolTxError{Err: errors.New("fee too low")})
+	status, message, _ := grpcCall(t, client, baseURL, "Pool/SubmitPoolTx", poolTx)
+	assert.Equal(t, "3", status)
+	assert.Contains(t, message, "fee too low")
+	// Resubmissions with a different content map to AlreadyExists
+	stub.err = tracerr.Wrap(l2db.ErrTxIDConflict)
+	status, _, _ = grpcCall(t, client, baseURL, "Pool/SubmitPoolTx", poolTx)
+	assert.Equal(t, "6", status)
+}
+
+func TestParseGrpcTimeout(t *testing.T) {
+	timeout, ok := parseGrpcTimeout("1500m")
+	require.True(t, ok)
+	assert.Equal(t, "1.5s", timeout.String())
+	timeout, ok = parseGrpcTimeout("2S")
+	require.True(t, ok)
+	assert.Equal(t, "2s", timeout.String())
+	for _, value := range []string{"", "m", "100", "-1m", "100x"} {
+		_, ok := parseGrpcTimeout(value)
+		assert.False(t, ok, value)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/arnaubennassar/hermez-node/metric"
 	"github.com/gin-gonic/gin"
@@ -12,50 +13,80 @@ import (
 	"github.com/russross/meddler"
 )
 
-type errorMsg struct {
-	Message string
-}
-
 func retSQLErr(err error, c *gin.Context) {
-	log.Warnw("HTTP API SQL request error", "err", err)
+	log.WarnwCtx(c.Request.Context(), "HTTP API SQL request error", "err", err)
 	unwrapErr := tracerr.Unwrap(err)
 	metric.CollectError(unwrapErr)
 	errMsg := unwrapErr.Error()
 	retDupKey := func(errCode pq.ErrorCode) {
 		// https://www.postgresql.org/docs/current/errcodes-appendix.html
 		if errCode == "23505" {
-			c.JSON(http.StatusConflict, errorMsg{
+			c.JSON(http.StatusConflict, apiErrorResponse{
 				Message: ErrDuplicatedKey,
+				Code:    ErrDuplicatedKeyCode,
+				Type:    ErrDuplicatedKeyType,
 			})
 		} else {
-			c.JSON(http.StatusInternalServerError, errorMsg{
+			c.JSON(http.StatusInternalServerError, apiErrorResponse{
 				Message: errMsg,
+				Code:    ErrInternalCode,
+				Type:    ErrInternalType,
 			})
 		}
 	}
 	if errMsg == errCtxTimeout {
-		c.JSON(http.StatusServiceUnavailable, errorMsg{
+		c.JSON(http.StatusServiceUnavailable, apiErrorResponse{
 			Message: ErrSQLTimeout,
+			Code:    ErrSQLTimeoutCode,
+			Type:    ErrSQLTimeoutType,
 		})
 	} else if sqlErr, ok := tracerr.Unwrap(err).(*pq.Error); ok {
 		retDupKey(sqlErr.Code)
 	} else if sqlErr, ok := meddler.DriverErr(tracerr.Unwrap(err)); ok {
 		retDupKey(sqlErr.(*pq.Error).Code)
 	} else if tracerr.Unwrap(err) == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, errorMsg{
+		c.JSON(http.StatusNotFound, apiErrorResponse{
 			Message: errMsg,
+			Code:    ErrSQLNoRowsCode,
+			Type:    ErrSQLNoRowsType,
 		})
 	} else {
-		c.JSON(http.StatusInternalServerError, errorMsg{
+		c.JSON(http.StatusInternalServerError, apiErrorResponse{
 			Message: errMsg,
+			Code:    ErrInternalCode,
+			Type:    ErrInternalType,
 		})
 	}
 }
 
 func retBadReq(err error, c *gin.Context) {
-	log.Warnw("HTTP API Bad request error", "err", err)
+	log.WarnwCtx(c.Request.Context(), "HTTP API Bad request error", "err", err)
 	metric.CollectError(err)
-	c.JSON(http.StatusBadRequest, errorMsg{
+	if parseErr, ok := tracerr.Unwrap(err).(*common.ParseError); ok {
+		c.JSON(http.StatusBadRequest, apiErrorResponse{
+			Message: parseErr.Error(),
+			Code:    ErrParamValidationFailedCode,
+			Type:    ErrParamValidationFailedType,
+			ParamError: &apiParamError{
+				Param:    parseErr.Field,
+				Value:    parseErr.Value,
+				Expected: parseErr.Expected,
+			},
+		})
+		return
+	}
+	if apiErr, ok := tracerr.Unwrap(err).(*apiError); ok {
+		c.JSON(http.StatusBadRequest, apiErrorResponse{
+			Message: apiErr.Error(),
+			Code:    apiErr.Code,
+			Type:    apiErr.Type,
+		})
+		return
+	}
+	// errors without a specific code are parameter validation failures
+	c.JSON(http.StatusBadRequest, apiErrorResponse{
 		Message: err.Error(),
+		Code:    ErrParamValidationFailedCode,
+		Type:    ErrParamValidationFailedType,
 	})
 }
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/common"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// IdxReserver returns the Idx that an account will get when the Coordinator
+// creates it in the next forged batch, holding a short-lived reservation so
+// that the promise is kept.  Implemented by the Coordinator on top of the
+// TxSelector
+type IdxReserver interface {
+	ReserveIdx(addr ethCommon.Address, bjj babyjub.PublicKeyComp,
+		tokenID common.TokenID, nPendingL1CreateAccounts int) (common.Idx, time.Time, error)
+}
+
+// SetIdxReserver enables the account index reservation endpoint.  Until this
+// method is called, that endpoint rejects all requests.
+func (a *API) SetIdxReserver(reserver IdxReserver) {
+	a.idxReserver = reserver
+}
+
+// receivedIdxReservation is the body of an account index reservation request
+type receivedIdxReservation struct {
+	EthAddr apitypes.StrHezEthAddr `json:"hezEthereumAddress" binding:"required"`
+	BJJ     apitypes.StrHezBJJ     `json:"bjj" binding:"required"`
+	TokenID uint32                 `json:"tokenId"`
+}
+
+// postAccountIndexReservation returns the Idx that the account will get when
+// the coordinator creates it in the next forged batch, holding a short-lived
+// reservation so that wallets can show the destination index immediately.
+// Requires an account creation authorization of the address with the same
+// BJJ to have been sent to this coordinator beforehand
+func (a *API) postAccountIndexReservation(c *gin.Context) {
+	if a.idxReserver == nil {
+		retBadReq(errors.New(
+			"account index reservation is only available in coordinator mode"), c)
+		return
+	}
+	// Parse body
+	var req receivedIdxReservation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Check that the token is registered, and get its symbol to render
+	// the account index
+	token, err := a.h.GetTokenAPI(common.TokenID(req.TokenID))
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// The Idxs of the accounts created by the L1UserTxs already queued
+	// come before the ones created by the coordinator
+	nPendingL1CreateAccounts, err := a.h.GetUnforgedNewAccountL1UserTxsCount()
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	idx, expiry, err := a.idxReserver.ReserveIdx(ethCommon.Address(req.EthAddr),
+		babyjub.PublicKeyComp(req.BJJ), common.TokenID(req.TokenID),
+		nPendingL1CreateAccounts)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	type reservationResponse struct {
+		AccountIndex       string              `json:"accountIndex"`
+		HezEthereumAddress apitypes.HezEthAddr `json:"hezEthereumAddress"`
+		BJJ                apitypes.HezBJJ     `json:"bjj"`
+		TokenID            common.TokenID      `json:"tokenId"`
+		ExpiresAt          time.Time           `json:"expiresAt"`
+	}
+	c.JSON(http.StatusOK, &reservationResponse{
+		AccountIndex:       fmt.Sprintf("hez:%s:%d", token.Symbol, idx),
+		HezEthereumAddress: apitypes.NewHezEthAddr(ethCommon.Address(req.EthAddr)),
+		BJJ:                apitypes.NewHezBJJ(babyjub.PublicKeyComp(req.BJJ)),
+		TokenID:            common.TokenID(req.TokenID),
+		ExpiresAt:          expiry.UTC(),
+	})
+}
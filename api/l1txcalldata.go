@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/common"
+	hermez "github.com/arnaubennassar/hermez-node/eth/contracts/hermez"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+var (
+	hermezABI     abi.ABI
+	hermezABIOnce sync.Once
+	hermezABIErr  error
+)
+
+// parsedHermezABI returns the parsed ABI of the Hermez smart contract,
+// parsing it only once
+func parsedHermezABI() (abi.ABI, error) {
+	hermezABIOnce.Do(func() {
+		hermezABI, hermezABIErr = abi.JSON(strings.NewReader(hermez.HermezABI))
+	})
+	return hermezABI, tracerr.Wrap(hermezABIErr)
+}
+
+// l1TxCalldataResponse is the representation of the addL1Transaction call
+// that the wallet of the user must submit to create an L1UserTx
+type l1TxCalldataResponse struct {
+	// To is the address of the Hermez smart contract
+	To ethCommon.Address `json:"to"`
+	// Data is the hex encoded calldata of the addL1Transaction call
+	Data string `json:"data"`
+	// Value is the ether value that must be sent along the call: the
+	// deposit amount for ETH deposits, 0 otherwise
+	Value apitypes.BigIntStr `json:"value"`
+	// Type of the L1UserTx that the call creates
+	Type common.TxType `json:"type"`
+	// ApprovalRequired is true when the deposited token is an ERC20 and
+	// no permit is embedded in the calldata, so the Hermez smart contract
+	// must have an allowance of at least the deposit amount
+	ApprovalRequired bool `json:"approvalRequired"`
+}
+
+// getL1TxCalldata builds the exact addL1Transaction calldata for the given
+// L1UserTx parameters, so that integrators don't have to reimplement the
+// packed Float40 amounts and BJJ endianness.  The amounts are validated to
+// be representable in Float40 without precission loss and the token to be
+// registered in the rollup.  For ERC20 deposits the wallet must either hold
+// an allowance (see ApprovalRequired) or provide the EIP-2612 permit bytes
+// in the `permit` query param to use the permit path
+func (a *API) getL1TxCalldata(c *gin.Context) {
+	filters, err := parsers.ParseL1TxCalldataFilters(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Check that the token is registered in the rollup
+	if _, err := a.h.GetTokenAPI(filters.TokenID); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Derive the L1UserTx type, validating the idx combination
+	tx := common.L1Tx{
+		FromIdx:       filters.FromIdx,
+		ToIdx:         filters.ToIdx,
+		TokenID:       filters.TokenID,
+		Amount:        filters.Amount,
+		DepositAmount: filters.DepositAmount,
+		UserOrigin:    true,
+	}
+	if filters.FromBJJ != nil {
+		tx.FromBJJ = *filters.FromBJJ
+	}
+	if err := tx.SetType(); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if tx.FromIdx == 0 && tx.FromBJJ == common.EmptyBJJComp {
+		retBadReq(errors.New(
+			"fromBJJ is required when creating an account (empty fromAccountIndex)"), c)
+		return
+	}
+	// Validate that the amounts are representable in Float40, suggesting
+	// the nearest representable value when they are not
+	amountF, err := float40OrSuggestion(tx.Amount, "amount")
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	depositAmountF, err := float40OrSuggestion(tx.DepositAmount, "depositAmount")
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if filters.TokenID == 0 && len(filters.Permit) > 0 {
+		retBadReq(errors.New("permit is only applicable to ERC20 deposits"), c)
+		return
+	}
+	// Pack the calldata exactly as eth.RollupClient.RollupL1UserTx* do
+	parsedABI, err := parsedHermezABI()
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	babyPubKey := big.NewInt(0)
+	if tx.FromBJJ != common.EmptyBJJComp {
+		babyPubKey = new(big.Int).SetBytes(common.SwapEndianness(tx.FromBJJ[:]))
+	}
+	data, err := parsedABI.Pack("addL1Transaction", babyPubKey,
+		big.NewInt(int64(tx.FromIdx)), big.NewInt(int64(depositAmountF)),
+		big.NewInt(int64(amountF)), uint32(tx.TokenID),
+		big.NewInt(int64(tx.ToIdx)), filters.Permit)
+	if err != nil {
+		retSQLErr(tracerr.Wrap(err), c)
+		return
+	}
+	value := big.NewInt(0)
+	if filters.TokenID == 0 {
+		value = tx.DepositAmount
+	}
+	c.JSON(http.StatusOK, &l1TxCalldataResponse{
+		To:    a.hermezAddress,
+		Data:  "0x" + hex.EncodeToString(data),
+		Value: *apitypes.NewBigIntStr(value),
+		Type:  tx.Type,
+		ApprovalRequired: filters.TokenID != 0 &&
+			tx.DepositAmount.Sign() > 0 && len(filters.Permit) == 0,
+	})
+}
+
+// float40OrSuggestion converts an amount to Float40, wrapping the not enough
+// precission error with the nearest lower representable value so that the
+// caller can fix the request
+func float40OrSuggestion(amount *big.Int, name string) (common.Float40, error) {
+	amountF, err := common.NewFloat40(amount)
+	if tracerr.Unwrap(err) == common.ErrFloat40NotEnoughPrecission {
+		floorF, floorErr := common.NewFloat40Floor(amount)
+		if floorErr != nil {
+			return 0, tracerr.Wrap(err)
+		}
+		floor, floorErr := floorF.BigInt()
+		if floorErr != nil {
+			return 0, tracerr.Wrap(err)
+		}
+		return 0, tracerr.Wrap(fmt.Errorf(
+			"%s can not be represented in Float40 without losing precission, "+
+				"nearest lower representable value: %s", name, floor.String()))
+	} else if err != nil {
+		return 0, tracerr.Wrap(fmt.Errorf("invalid %s: %w", name, err))
+	}
+	return amountF, nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloat40OrSuggestion(t *testing.T) {
+	// Representable amount
+	amountF, err := float40OrSuggestion(big.NewInt(1000), "amount")
+	require.NoError(t, err)
+	expected, err := common.NewFloat40(big.NewInt(1000))
+	require.NoError(t, err)
+	assert.Equal(t, expected, amountF)
+
+	// Non representable amount: the error suggests the nearest lower
+	// representable value
+	amount, ok := new(big.Int).SetString("1000000000000000000001", 10)
+	require.True(t, ok)
+	_, err = float40OrSuggestion(amount, "amount")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nearest lower representable value")
+}
+
+func TestL1TxCalldataPack(t *testing.T) {
+	parsedABI, err := parsedHermezABI()
+	require.NoError(t, err)
+	data, err := parsedABI.Pack("addL1Transaction", big.NewInt(0),
+		big.NewInt(256), big.NewInt(0), big.NewInt(0), uint32(1),
+		big.NewInt(0), []byte{})
+	require.NoError(t, err)
+	method, err := parsedABI.MethodById(data[:4])
+	require.NoError(t, err)
+	assert.Equal(t, "addL1Transaction", method.Name)
+}
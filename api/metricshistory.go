@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/gin-gonic/gin"
+)
+
+func (a *API) getMetricsHistory(c *gin.Context) {
+	request, err := parsers.ParseMetricsHistoryFilters(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch metrics snapshots from historyDB
+	snapshots, pendingItems, err := a.h.GetMetricsHistoryAPI(request)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	applyCurrencyToMetricsHistory(snapshots, rate)
+
+	// Build successful response
+	type metricsHistoryResponse struct {
+		Snapshots    []historydb.MetricsSnapshotAPI `json:"snapshots"`
+		PendingItems uint64                         `json:"pendingItems"`
+		NextCursor   string                         `json:"nextCursor,omitempty"`
+	}
+	c.JSON(http.StatusOK, &metricsHistoryResponse{
+		Snapshots:    snapshots,
+		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, snapshots, pendingItems),
+	})
+}
@@ -0,0 +1,434 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// This file generates an OpenAPI 3 document from the gin route definitions
+// and the Go types used by the handlers, with the struct tags (form, uri,
+// binding, json) as the source of truth for parameter names and schemas.
+// The document is built once at API construction and served at
+// /openapi.json, so that client SDKs can be generated against the exact
+// parameter names accepted by the parsers package instead of
+// reverse-engineering them from the code.
+
+// endpointDoc holds the extra endpoint information that cannot be derived
+// from the gin route alone: a summary, the parsers struct whose form tags
+// define the accepted query parameters, and the response type
+type endpointDoc struct {
+	// Summary is the one-line description of the endpoint
+	Summary string
+	// Query is an instance of the parsers filters struct whose form
+	// tags define the accepted query parameters
+	Query interface{}
+	// ListKey, when not empty, declares that the response is a list
+	// envelope with the items under this key, plus pendingItems and
+	// nextCursor
+	ListKey string
+	// Item is an instance of the element type of a list response
+	Item interface{}
+	// Response is an instance of the response type of a single-object
+	// endpoint
+	Response interface{}
+}
+
+// openAPIDocs maps the name of each handler method of the API to its
+// endpoint documentation
+var openAPIDocs = map[string]endpointDoc{
+	"getAccounts": {
+		Summary: "Get accounts balances and other associated information",
+		Query:   parsers.AccountsFilters{},
+		ListKey: "accounts", Item: historydb.AccountAPI{},
+	},
+	"postAccountsResolve": {
+		Summary: "Resolve a batch of mixed account identifiers (idx, " +
+			"hez:tokenSymbol:idx, hez ethereum address, BJJ) to account records",
+	},
+	"getAccount": {
+		Summary:  "Get an account by its index",
+		Response: historydb.AccountAPI{},
+	},
+	"getExits": {
+		Summary: "Get exits, potentially used to withdraw funds from the smart contract",
+		Query:   parsers.ExitsFilters{},
+		ListKey: "exits", Item: historydb.ExitAPI{},
+	},
+	"getExit": {
+		Summary:  "Get an exit by its batch number and account index",
+		Response: historydb.ExitAPI{},
+	},
+	"getHistoryTxs": {
+		Summary: "Get forged transactions",
+		Query:   parsers.HistoryTxsFilters{},
+		ListKey: "transactions", Item: historydb.TxAPI{},
+	},
+	"getHistoryTx": {
+		Summary:  "Get a forged transaction by its id",
+		Response: historydb.TxAPI{},
+	},
+	"exportHistoryTxs": {
+		Summary: "Export forged transactions as a CSV stream",
+		Query:   parsers.HistoryTxsFilters{},
+	},
+	"getBatches": {
+		Summary: "Get batches",
+		Query:   parsers.BatchesFilters{},
+		ListKey: "batches", Item: historydb.BatchAPI{},
+	},
+	"getBatch": {
+		Summary:  "Get a batch by its number",
+		Response: historydb.BatchAPI{},
+	},
+	"getBatchesProfitability": {
+		Summary: "Get per-batch collected fees and estimated cost",
+		Query:   parsers.BatchesProfitabilityFilters{},
+		ListKey: "batches", Item: historydb.BatchProfitabilityAPI{},
+	},
+	"getSlots": {
+		Summary: "Get auction slots",
+		Query:   parsers.SlotsFilters{},
+		ListKey: "slots", Item: SlotAPI{},
+	},
+	"getSlot": {
+		Summary:  "Get an auction slot by its number",
+		Response: SlotAPI{},
+	},
+	"getBids": {
+		Summary: "Get auction bids",
+		Query:   parsers.BidsFilters{},
+		ListKey: "bids", Item: historydb.BidAPI{},
+	},
+	"getTokens": {
+		Summary: "Get tokens supported by the network",
+		Query:   parsers.TokensFilters{},
+		ListKey: "tokens", Item: historydb.TokenWithUSD{},
+	},
+	"getToken": {
+		Summary:  "Get a token by its id",
+		Response: historydb.TokenWithUSD{},
+	},
+	"getPoolTxs": {
+		Summary: "Get transactions from the pool",
+		Query:   parsers.PoolTxsFilters{},
+		ListKey: "transactions", Item: l2db.PoolTxAPI{},
+	},
+	"getPoolTx": {
+		Summary:  "Get a pool transaction by its id",
+		Response: l2db.PoolTxAPI{},
+	},
+	"getCoordinators": {
+		Summary: "Get coordinators",
+		Query:   parsers.CoordinatorsFilters{},
+		ListKey: "coordinators", Item: historydb.CoordinatorAPI{},
+	},
+	"getMetricsHistory": {
+		Summary: "Get historic metrics snapshots",
+		Query:   parsers.MetricsHistoryFilters{},
+		ListKey: "snapshots", Item: historydb.MetricsSnapshotAPI{},
+	},
+	"getFiatCurrencies": {
+		Summary: "Get fiat currencies price information",
+		Query:   parsers.CurrenciesFilters{},
+	},
+	"getFiatCurrency": {
+		Summary: "Get a fiat currency price information by its symbol",
+	},
+	"getState": {
+		Summary: "Get the state of the network",
+	},
+	"getConfig": {
+		Summary: "Get the constant configuration of the network",
+	},
+	"getSearch": {
+		Summary: "Search accounts, batches and transactions by a single term",
+	},
+	"getAccountCreationAuth": {
+		Summary: "Get an account creation authorization by hermez ethereum address",
+	},
+	"postAccountCreationAuth": {
+		Summary: "Post an account creation authorization",
+	},
+	"verifyAccountCreationAuth": {
+		Summary: "Verify an account creation authorization signature, returning " +
+			"the recovered address and the reason why it is invalid",
+	},
+	"postPoolTx": {
+		Summary: "Post a transaction to the pool",
+	},
+}
+
+const openAPIMaxSchemaDepth = 8
+
+var (
+	openAPITimeType          = reflect.TypeOf(time.Time{})
+	openAPIBigIntType        = reflect.TypeOf(big.Int{})
+	openAPIJSONMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	openAPITextMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// openAPISchema builds the OpenAPI schema object of a Go type by reflection,
+// using the json struct tags as the property names
+func openAPISchema(t reflect.Type, depth int) map[string]interface{} {
+	if depth > openAPIMaxSchemaDepth {
+		return map[string]interface{}{}
+	}
+	if t.Kind() == reflect.Ptr {
+		schema := openAPISchema(t.Elem(), depth)
+		schema["nullable"] = true
+		return schema
+	}
+	if t == openAPITimeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == openAPIBigIntType {
+		return map[string]interface{}{"type": "string"}
+	}
+	// Types with a custom JSON or text marshaler are serialized as
+	// strings (addresses, public keys, tx ids, stringified big ints...)
+	if t.Kind() != reflect.Struct || t.Implements(openAPIJSONMarshalerType) ||
+		t.Implements(openAPITextMarshalerType) ||
+		reflect.PtrTo(t).Implements(openAPIJSONMarshalerType) ||
+		reflect.PtrTo(t).Implements(openAPITextMarshalerType) {
+		switch t.Kind() {
+		case reflect.Bool:
+			return map[string]interface{}{"type": "boolean"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return map[string]interface{}{"type": "integer"}
+		case reflect.Float32, reflect.Float64:
+			return map[string]interface{}{"type": "number"}
+		case reflect.String, reflect.Struct, reflect.Array:
+			return map[string]interface{}{"type": "string"}
+		case reflect.Slice:
+			if t.Elem().Kind() == reflect.Uint8 {
+				return map[string]interface{}{"type": "string"}
+			}
+			return map[string]interface{}{
+				"type":  "array",
+				"items": openAPISchema(t.Elem(), depth+1),
+			}
+		case reflect.Map:
+			return map[string]interface{}{"type": "object"}
+		default:
+			return map[string]interface{}{}
+		}
+	}
+	properties := map[string]interface{}{}
+	openAPIStructProperties(t, depth, properties)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// openAPIStructProperties adds the properties of the struct type t to
+// properties, flattening embedded structs like the json package does
+func openAPIStructProperties(t reflect.Type, depth int, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		jsonTag := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if jsonTag == "-" {
+			continue
+		}
+		if field.Anonymous && jsonTag == "" && field.Type.Kind() == reflect.Struct {
+			openAPIStructProperties(field.Type, depth, properties)
+			continue
+		}
+		name := jsonTag
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = openAPISchema(field.Type, depth+1)
+	}
+}
+
+// openAPIQueryParams builds the OpenAPI query parameter objects of a
+// parsers filters struct, using the form tags as the parameter names and the
+// binding tags to mark required parameters
+func openAPIQueryParams(v interface{}) []interface{} {
+	params := []interface{}{}
+	openAPIAppendQueryParams(reflect.TypeOf(v), &params)
+	return params
+}
+
+func openAPIAppendQueryParams(t reflect.Type, params *[]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			openAPIAppendQueryParams(field.Type, params)
+			continue
+		}
+		name := strings.SplitN(field.Tag.Get("form"), ",", 2)[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		param := map[string]interface{}{
+			"name":   name,
+			"in":     "query",
+			"schema": openAPISchema(field.Type, 0),
+		}
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			param["required"] = true
+		}
+		*params = append(*params, param)
+	}
+}
+
+// openAPIPath translates a gin route path into an OpenAPI path and its path
+// parameter objects (":param" and "*param" become "{param}")
+func openAPIPath(ginPath string) (string, []interface{}) {
+	parts := strings.Split(ginPath, "/")
+	params := []interface{}{}
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			name := part[1:]
+			parts[i] = "{" + name + "}"
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// openAPIListSchema builds the schema of a list envelope response with the
+// items under listKey plus the pagination fields
+func openAPIListSchema(listKey string, item interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			listKey: map[string]interface{}{
+				"type":  "array",
+				"items": openAPISchema(reflect.TypeOf(item), 0),
+			},
+			"pendingItems": map[string]interface{}{"type": "integer"},
+			"nextCursor":   map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// openAPIHandlerName extracts the method name of an API handler from the
+// fully qualified gin handler name
+func openAPIHandlerName(handler string) string {
+	name := strings.TrimSuffix(handler, "-fm")
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// buildOpenAPIDoc builds the OpenAPI 3 document of the registered routes
+func buildOpenAPIDoc(version string, routes gin.RoutesInfo) map[string]interface{} {
+	errorSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"Message": map[string]interface{}{"type": "string"},
+		},
+	}
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/v1/") {
+			continue
+		}
+		path, pathParams := openAPIPath(route.Path)
+		doc := openAPIDocs[openAPIHandlerName(route.Handler)]
+		params := pathParams
+		if doc.Query != nil {
+			params = append(params, openAPIQueryParams(doc.Query)...)
+		}
+		okResponse := map[string]interface{}{
+			"description": "Successful operation",
+		}
+		var respSchema map[string]interface{}
+		if doc.ListKey != "" && doc.Item != nil {
+			respSchema = openAPIListSchema(doc.ListKey, doc.Item)
+		} else if doc.Response != nil {
+			respSchema = openAPISchema(reflect.TypeOf(doc.Response), 0)
+		}
+		if respSchema != nil {
+			okResponse["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": respSchema,
+				},
+			}
+		}
+		op := map[string]interface{}{
+			"operationId": openAPIHandlerName(route.Handler),
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				"200": okResponse,
+				"400": map[string]interface{}{
+					"description": "Bad request",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": errorSchema,
+						},
+					},
+				},
+			},
+		}
+		if doc.Summary != "" {
+			op["summary"] = doc.Summary
+		}
+		if route.Method == "POST" || route.Method == "PUT" {
+			op["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "object"},
+					},
+				},
+			}
+		}
+		pathItem, ok := paths[path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[path] = pathItem
+		}
+		pathItem[strings.ToLower(route.Method)] = op
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title": "Hermez Network API",
+			"description": "Generated from the gin route definitions and the Go " +
+				"types used by the handlers, with the struct tags as the " +
+				"source of truth",
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+// setOpenAPIDoc builds and caches the JSON encoded OpenAPI document of the
+// registered routes
+func (a *API) setOpenAPIDoc(version string, routes gin.RoutesInfo) error {
+	doc, err := json.Marshal(buildOpenAPIDoc(version, routes))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	a.openapi = doc
+	return nil
+}
+
+// getOpenAPI serves the generated OpenAPI 3 document
+func (a *API) getOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", a.openapi)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openAPIPath("/v1/exits/:batchNum/:accountIndex")
+	assert.Equal(t, "/v1/exits/{batchNum}/{accountIndex}", path)
+	require.Len(t, params, 2)
+	assert.Equal(t, "batchNum", params[0].(map[string]interface{})["name"])
+	assert.Equal(t, "accountIndex", params[1].(map[string]interface{})["name"])
+
+	path, params = openAPIPath("/v1/tokens")
+	assert.Equal(t, "/v1/tokens", path)
+	assert.Len(t, params, 0)
+}
+
+func TestOpenAPIQueryParams(t *testing.T) {
+	params := openAPIQueryParams(parsers.AccountsFilters{})
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.(map[string]interface{})["name"].(string))
+	}
+	// Filters plus the embedded pagination params
+	assert.Contains(t, names, "tokenIds")
+	assert.Contains(t, names, "hezEthereumAddress")
+	assert.Contains(t, names, "BJJ")
+	assert.Contains(t, names, "fromItem")
+	assert.Contains(t, names, "order")
+	assert.Contains(t, names, "limit")
+}
+
+func TestBuildOpenAPIDoc(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: "GET", Path: "/v1/accounts",
+			Handler: "github.com/arnaubennassar/hermez-node/api.(*API).getAccounts-fm"},
+		{Method: "GET", Path: "/v1/accounts/:accountIndex",
+			Handler: "github.com/arnaubennassar/hermez-node/api.(*API).getAccount-fm"},
+		{Method: "POST", Path: "/v1/transactions-pool",
+			Handler: "github.com/arnaubennassar/hermez-node/api.(*API).postPoolTx-fm"},
+		{Method: "GET", Path: "/metrics", Handler: "metrics"},
+	}
+	doc := buildOpenAPIDoc("v1.0.0", routes)
+	assert.Equal(t, "3.0.0", doc["openapi"])
+
+	paths := doc["paths"].(map[string]interface{})
+	// Routes outside /v1 are not documented
+	require.Len(t, paths, 3)
+	accounts := paths["/v1/accounts"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "getAccounts", accounts["operationId"])
+	// Query params from parsers.AccountsFilters
+	assert.NotEmpty(t, accounts["parameters"])
+
+	account := paths["/v1/accounts/{accountIndex}"].(map[string]interface{})["get"].(map[string]interface{})
+	require.Len(t, account["parameters"], 1)
+
+	postTx := paths["/v1/transactions-pool"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.NotNil(t, postTx["requestBody"])
+}
@@ -1,7 +1,8 @@
 package parsers
 
 import (
-	"strconv"
+	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/arnaubennassar/hermez-node/common"
@@ -27,9 +28,12 @@ func ParseAccountFilter(c *gin.Context) (*common.Idx, error) {
 
 // AccountsFilters for parsing /accounts query params to struct
 type AccountsFilters struct {
-	IDs  string `form:"tokenIds"`
-	Addr string `form:"hezEthereumAddress"`
-	Bjj  string `form:"BJJ"`
+	IDs        string `form:"tokenIds"`
+	Addr       string `form:"hezEthereumAddress"`
+	Bjj        string `form:"BJJ"`
+	MinBalance string `form:"minBalance"`
+	MaxBalance string `form:"maxBalance"`
+	SortBy     string `form:"sortBy"`
 
 	Pagination
 }
@@ -59,12 +63,11 @@ func ParseAccountsFilters(c *gin.Context, v *validator.Validate) (historydb.GetA
 	if accountsFilter.IDs != "" {
 		ids := strings.Split(accountsFilter.IDs, ",")
 		for _, id := range ids {
-			idUint, err := strconv.Atoi(id)
+			tokenID, err := common.StringToTokenID(id, "tokenIds")
 			if err != nil {
-				return historydb.GetAccountsAPIRequest{}, err
+				return historydb.GetAccountsAPIRequest{}, tracerr.Wrap(err)
 			}
-			tokenID := common.TokenID(idUint)
-			tokenIDs = append(tokenIDs, tokenID)
+			tokenIDs = append(tokenIDs, *tokenID)
 		}
 	}
 
@@ -78,12 +81,39 @@ func ParseAccountsFilters(c *gin.Context, v *validator.Validate) (historydb.GetA
 		return historydb.GetAccountsAPIRequest{}, err
 	}
 
+	minBalance, err := parseBigInt(accountsFilter.MinBalance, "minBalance")
+	if err != nil {
+		return historydb.GetAccountsAPIRequest{}, err
+	}
+
+	maxBalance, err := parseBigInt(accountsFilter.MaxBalance, "maxBalance")
+	if err != nil {
+		return historydb.GetAccountsAPIRequest{}, err
+	}
+
 	return historydb.GetAccountsAPIRequest{
-		TokenIDs: tokenIDs,
-		EthAddr:  addr,
-		Bjj:      bjj,
-		FromItem: accountsFilter.FromItem,
-		Order:    *accountsFilter.Order,
-		Limit:    accountsFilter.Limit,
+		TokenIDs:   tokenIDs,
+		EthAddr:    addr,
+		Bjj:        bjj,
+		MinBalance: minBalance,
+		MaxBalance: maxBalance,
+		SortBy:     accountsFilter.SortBy,
+		FromItem:   accountsFilter.FromItem,
+		Order:      *accountsFilter.Order,
+		Limit:      accountsFilter.Limit,
 	}, nil
 }
+
+// parseBigInt parses a base 10 unsigned integer query param, returning nil
+// when the param is empty
+func parseBigInt(s, name string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	value, ok := new(big.Int).SetString(s, 10)
+	if !ok || value.Sign() < 0 {
+		return nil, tracerr.Wrap(fmt.Errorf(
+			"invalid %s, must be a base 10 unsigned integer", name))
+	}
+	return value, nil
+}
@@ -31,6 +31,35 @@ type BatchesFilters struct {
 	Pagination
 }
 
+// BatchesProfitabilityFilters struct to hold the filters of the
+// /batches-profitability request
+type BatchesProfitabilityFilters struct {
+	ForgerAddr string `form:"forgerAddr" binding:"required"`
+
+	Pagination
+}
+
+// ParseBatchesProfitabilityFilter parses the batches profitability filters to
+// the GetBatchesProfitabilityAPIRequest
+func ParseBatchesProfitabilityFilter(c *gin.Context) (historydb.GetBatchesProfitabilityAPIRequest, error) {
+	var filters BatchesProfitabilityFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		return historydb.GetBatchesProfitabilityAPIRequest{}, err
+	}
+
+	addr, err := common.StringToEthAddr(filters.ForgerAddr)
+	if err != nil {
+		return historydb.GetBatchesProfitabilityAPIRequest{}, tracerr.Wrap(err)
+	}
+
+	return historydb.GetBatchesProfitabilityAPIRequest{
+		ForgerAddr: addr,
+		FromItem:   filters.FromItem,
+		Limit:      filters.Limit,
+		Order:      *filters.Order,
+	}, nil
+}
+
 // ParseBatchesFilter parsing batches filter to the GetBatchesAPIRequest
 func ParseBatchesFilter(c *gin.Context) (historydb.GetBatchesAPIRequest, error) {
 	var batchesFilters BatchesFilters
@@ -3,6 +3,7 @@ package parsers
 import (
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
+	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/hermeznetwork/tracerr"
 )
@@ -38,3 +39,19 @@ func ParseCoordinatorsFilters(c *gin.Context) (historydb.GetCoordinatorsAPIReque
 		Order:      *coordinatorsFilters.Order,
 	}, nil
 }
+
+// GetCoordinatorStatsFilter struct to get the bidderAddr uri param from the
+// /coordinators/:bidderAddr/stats request
+type GetCoordinatorStatsFilter struct {
+	BidderAddr string `uri:"bidderAddr" binding:"required"`
+}
+
+// ParseGetCoordinatorStatsFilter parses the uri of the
+// /coordinators/:bidderAddr/stats request to the bidder eth address
+func ParseGetCoordinatorStatsFilter(c *gin.Context) (*ethCommon.Address, error) {
+	var filter GetCoordinatorStatsFilter
+	if err := c.ShouldBindUri(&filter); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return common.StringToEthAddr(filter.BidderAddr)
+}
@@ -0,0 +1,33 @@
+package parsers
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// FeeEstimateFilters struct to get query params from /fees/estimate request
+type FeeEstimateFilters struct {
+	TokenID *uint  `form:"tokenId" binding:"required"`
+	Amount  string `form:"amount" binding:"required"`
+}
+
+// ParseFeeEstimateFilters func for parsing fee estimate filters from query
+// to the token id and the amount
+func ParseFeeEstimateFilters(c *gin.Context) (common.TokenID, *big.Int, error) {
+	var feeEstimateFilters FeeEstimateFilters
+	if err := c.BindQuery(&feeEstimateFilters); err != nil {
+		return 0, nil, err
+	}
+	amount, ok := new(big.Int).SetString(feeEstimateFilters.Amount, 10)
+	if !ok {
+		return 0, nil, tracerr.Wrap(fmt.Errorf("amount must be a base 10 integer"))
+	}
+	if amount.Sign() <= 0 {
+		return 0, nil, tracerr.Wrap(fmt.Errorf("amount must be positive"))
+	}
+	return common.TokenID(*feeEstimateFilters.TokenID), amount, nil
+}
@@ -0,0 +1,107 @@
+package parsers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// L1TxCalldataFilters struct for holding the query params of the
+// /l1-tx/calldata request
+type L1TxCalldataFilters struct {
+	FromBJJ          string `form:"fromBJJ"`
+	FromAccountIndex string `form:"fromAccountIndex"`
+	ToAccountIndex   string `form:"toAccountIndex"`
+	TokenID          *uint  `form:"tokenId" binding:"required"`
+	Amount           string `form:"amount"`
+	DepositAmount    string `form:"depositAmount"`
+	Permit           string `form:"permit"`
+}
+
+// L1TxCalldata holds the parsed parameters of a /l1-tx/calldata request
+type L1TxCalldata struct {
+	FromBJJ       *babyjub.PublicKeyComp
+	FromIdx       common.Idx
+	ToIdx         common.Idx
+	TokenID       common.TokenID
+	Amount        *big.Int
+	DepositAmount *big.Int
+	Permit        []byte
+}
+
+// ParseL1TxCalldataFilters func parsing the query params of the
+// /l1-tx/calldata request
+func ParseL1TxCalldataFilters(c *gin.Context) (L1TxCalldata, error) {
+	var filters L1TxCalldataFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	bjj, err := common.HezStringToBJJ(filters.FromBJJ, "fromBJJ")
+	if err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	fromIdx, err := common.StringToIdx(filters.FromAccountIndex, "fromAccountIndex")
+	if err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	toIdx, err := common.StringToIdx(filters.ToAccountIndex, "toAccountIndex")
+	if err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	amount, err := stringToAmount(filters.Amount, "amount")
+	if err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	depositAmount, err := stringToAmount(filters.DepositAmount, "depositAmount")
+	if err != nil {
+		return L1TxCalldata{}, tracerr.Wrap(err)
+	}
+
+	var permit []byte
+	if filters.Permit != "" {
+		permit, err = hex.DecodeString(strings.TrimPrefix(filters.Permit, "0x"))
+		if err != nil {
+			return L1TxCalldata{}, tracerr.Wrap(
+				fmt.Errorf("invalid permit, must be an hex encoded byte array"))
+		}
+	}
+
+	calldata := L1TxCalldata{
+		FromBJJ:       bjj,
+		TokenID:       common.TokenID(*filters.TokenID),
+		Amount:        amount,
+		DepositAmount: depositAmount,
+		Permit:        permit,
+	}
+	if fromIdx != nil {
+		calldata.FromIdx = *fromIdx
+	}
+	if toIdx != nil {
+		calldata.ToIdx = *toIdx
+	}
+	return calldata, nil
+}
+
+// stringToAmount converts a base 10 string into a *big.Int, 0 when empty
+func stringToAmount(amountStr, name string) (*big.Int, error) {
+	if amountStr == "" {
+		return big.NewInt(0), nil
+	}
+	amount, ok := new(big.Int).SetString(amountStr, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, tracerr.Wrap(
+			fmt.Errorf("invalid %s, must be a positive base 10 integer", name))
+	}
+	return amount, nil
+}
@@ -0,0 +1,33 @@
+package parsers
+
+import (
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// MetricsHistoryFilters struct to hold metrics history filters
+type MetricsHistoryFilters struct {
+	FromTimestamp *time.Time `form:"fromTimestamp" time_format:"2006-01-02T15:04:05Z07:00"`
+	ToTimestamp   *time.Time `form:"toTimestamp" time_format:"2006-01-02T15:04:05Z07:00"`
+
+	Pagination
+}
+
+// ParseMetricsHistoryFilters function for parsing the metrics history filters
+// from the request /metrics-history to the GetMetricsHistoryAPIRequest
+func ParseMetricsHistoryFilters(c *gin.Context) (historydb.GetMetricsHistoryAPIRequest, error) {
+	var filters MetricsHistoryFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		return historydb.GetMetricsHistoryAPIRequest{}, tracerr.Wrap(err)
+	}
+	return historydb.GetMetricsHistoryAPIRequest{
+		FromTimestamp: filters.FromTimestamp,
+		ToTimestamp:   filters.ToTimestamp,
+		FromItem:      filters.FromItem,
+		Order:         *filters.Order,
+		Limit:         filters.Limit,
+	}, nil
+}
@@ -1,7 +1,6 @@
 package parsers
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/arnaubennassar/hermez-node/common"
@@ -44,12 +43,11 @@ func ParseTokensFilters(c *gin.Context) (historydb.GetTokensAPIRequest, error) {
 		ids := strings.Split(tokensFilters.IDs, ",")
 
 		for _, id := range ids {
-			idUint, err := strconv.Atoi(id)
+			tokenID, err := common.StringToTokenID(id, "ids")
 			if err != nil {
 				return historydb.GetTokensAPIRequest{}, tracerr.Wrap(err)
 			}
-			tokenID := common.TokenID(idUint)
-			tokensIDs = append(tokensIDs, tokenID)
+			tokensIDs = append(tokensIDs, *tokenID)
 		}
 	}
 
@@ -1,8 +1,6 @@
 package parsers
 
 import (
-	"fmt"
-
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
 	"github.com/gin-gonic/gin"
@@ -23,7 +21,7 @@ func ParseHistoryTxFilter(c *gin.Context) (common.TxID, error) {
 	}
 	txID, err := common.NewTxIDFromString(historyTxFilter.TxID)
 	if err != nil {
-		return common.TxID{}, tracerr.Wrap(fmt.Errorf("invalid %s", err))
+		return common.TxID{}, tracerr.Wrap(err)
 	}
 	return txID, nil
 }
@@ -43,6 +41,7 @@ type HistoryTxsFilters struct {
 	BatchNum            *uint  `form:"batchNum"`
 	TxType              string `form:"type"`
 	IncludePendingTxs   *bool  `form:"includePendingL1s"`
+	ZeroedAmounts       *bool  `form:"zeroedAmounts"`
 
 	Pagination
 }
@@ -160,6 +159,7 @@ func ParseHistoryTxsFilters(c *gin.Context, v *validator.Validate) (historydb.Ge
 		BatchNum:          historyTxsFilters.BatchNum,
 		TxType:            txType,
 		IncludePendingL1s: historyTxsFilters.IncludePendingTxs,
+		ZeroedAmounts:     historyTxsFilters.ZeroedAmounts,
 		FromItem:          historyTxsFilters.FromItem,
 		Limit:             historyTxsFilters.Limit,
 		Order:             *historyTxsFilters.Order,
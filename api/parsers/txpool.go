@@ -1,8 +1,6 @@
 package parsers
 
 import (
-	"fmt"
-
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/l2db"
 	"github.com/gin-gonic/gin"
@@ -23,7 +21,7 @@ func ParsePoolTxFilter(c *gin.Context) (common.TxID, error) {
 	}
 	txID, err := common.NewTxIDFromString(poolTxFilter.TxID)
 	if err != nil {
-		return common.TxID{}, tracerr.Wrap(fmt.Errorf("invalid txID"))
+		return common.TxID{}, tracerr.Wrap(err)
 	}
 	return txID, nil
 }
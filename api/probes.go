@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// probeTimeout bounds every dependency check run by the readiness endpoint
+const probeTimeout = 3 * time.Second
+
+const (
+	probeStatusUp   = "UP"
+	probeStatusDown = "DOWN"
+)
+
+// ReadinessProbe is an additional dependency check run by the readiness
+// endpoint (see AddReadinessProbe)
+type ReadinessProbe func(ctx context.Context) error
+
+// readinessProbeEntry is a named ReadinessProbe registered through
+// AddReadinessProbe
+type readinessProbeEntry struct {
+	name  string
+	probe ReadinessProbe
+}
+
+// AddReadinessProbe registers an additional named dependency check to be run
+// by the readiness endpoint.  Used by the node wiring to expose the
+// dependencies that the API package can't reach through the database
+// (StateDB, provers).  Must be called before the server starts
+func (a *API) AddReadinessProbe(name string, probe ReadinessProbe) {
+	a.readinessProbes = append(a.readinessProbes, readinessProbeEntry{
+		name:  name,
+		probe: probe,
+	})
+}
+
+// dependencyStatus is the result of one dependency check of the readiness
+// endpoint
+type dependencyStatus struct {
+	Status    string                 `json:"status"`
+	LatencyMs float64                `json:"latencyMs"`
+	Error     string                 `json:"error,omitempty"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+}
+
+// runProbe runs one dependency check, measuring its latency.  The check can
+// return detail to be included in the response both on success and failure
+func runProbe(ctx context.Context,
+	check func(ctx context.Context) (map[string]interface{}, error)) dependencyStatus {
+	start := time.Now()
+	detail, err := check(ctx)
+	status := dependencyStatus{
+		Status:    probeStatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000, //nolint:gomnd
+		Detail:    detail,
+	}
+	if err != nil {
+		status.Status = probeStatusDown
+		status.Error = tracerr.Unwrap(err).Error()
+	}
+	return status
+}
+
+// getHealthz is the liveness probe: it only reports that the process serves
+// requests.  Dependency state is reported by the readiness probe, so that
+// orchestrators don't restart the node because of an unreachable dependency
+func (a *API) getHealthz(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    probeStatusUp,
+			"version":   version,
+			"timestamp": time.Now().UTC(),
+		})
+	}
+}
+
+// getReadyz is the readiness probe: it checks every dependency of the node
+// reporting per-dependency status and latency, and returns 503 when any of
+// them is down so that load balancers stop routing traffic here
+func (a *API) getReadyz(ethClient *ethclient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), probeTimeout)
+		defer cancel()
+		dependencies := make(map[string]dependencyStatus)
+		if a.h != nil {
+			dependencies["postgres-write"] = runProbe(ctx,
+				func(ctx context.Context) (map[string]interface{}, error) {
+					return nil, a.h.PingWrite(ctx)
+				})
+			dependencies["postgres-read"] = runProbe(ctx,
+				func(ctx context.Context) (map[string]interface{}, error) {
+					return nil, a.h.PingRead(ctx)
+				})
+			dependencies["price-updater"] = runProbe(ctx, a.checkPriceUpdater)
+		}
+		if ethClient != nil {
+			dependencies["ethereum"] = runProbe(ctx,
+				func(ctx context.Context) (map[string]interface{}, error) {
+					return a.checkEthereum(ctx, ethClient)
+				})
+		}
+		for _, entry := range a.readinessProbes {
+			probe := entry.probe
+			dependencies[entry.name] = runProbe(ctx,
+				func(ctx context.Context) (map[string]interface{}, error) {
+					return nil, probe(ctx)
+				})
+		}
+		status := probeStatusUp
+		httpStatus := http.StatusOK
+		for _, dependency := range dependencies {
+			if dependency.Status != probeStatusUp {
+				status = probeStatusDown
+				httpStatus = http.StatusServiceUnavailable
+				break
+			}
+		}
+		c.JSON(httpStatus, gin.H{
+			"status":       status,
+			"dependencies": dependencies,
+		})
+	}
+}
+
+// checkEthereum checks the ethereum RPC by fetching the latest header, and
+// reports the synchronizer lag behind it.  The check fails when the RPC is
+// unreachable or the lag is beyond the staleness threshold (when configured)
+func (a *API) checkEthereum(ctx context.Context,
+	ethClient *ethclient.Client) (map[string]interface{}, error) {
+	header, err := ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	lag := a.syncLag()
+	detail := map[string]interface{}{
+		"latestBlock": header.Number.Uint64(),
+		"syncLag":     lag,
+	}
+	if isStale(lag, a.stalenessThreshold) {
+		return detail, fmt.Errorf("synchronizer lags %v blocks behind the head", lag)
+	}
+	return detail, nil
+}
+
+// checkPriceUpdater reports the age of the most recent token price update.
+// The check only fails when the query itself fails: tokens without a price
+// are a valid state (static prices, no price provider configured)
+func (a *API) checkPriceUpdater(ctx context.Context) (map[string]interface{}, error) {
+	lastUpdate, err := a.h.GetLastTokenUSDUpdate()
+	if err != nil {
+		return nil, err
+	}
+	if lastUpdate == nil {
+		return map[string]interface{}{"lastUpdate": nil}, nil
+	}
+	return map[string]interface{}{
+		"lastUpdate": lastUpdate.UTC(),
+		"ageSeconds": time.Since(*lastUpdate).Seconds(),
+	}, nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header through which clients can supply their own
+// correlation ID, which is echoed back in the response.  When the header is
+// missing a random ID is generated
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware attaches a correlation ID to the context of every
+// request, so that all the logs emitted while serving it (handlers, DB
+// queries, eth calls) can be correlated.  The ID is echoed back in the
+// RequestIDHeader of the response so that clients can report it
+func (a *API) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = log.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(
+			log.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
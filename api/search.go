@@ -0,0 +1,188 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// searchLimit is the maximum number of resources returned for each
+// interpretation of the search query
+var searchLimit = uint(20)
+
+// searchResult is a resource matched by the search endpoint, pointing to its
+// canonical API URL
+type searchResult struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+}
+
+// getSearch classifies the free-form query by its format (tx ID, number,
+// ethereum/hez address, BJJ or token symbol) and looks up the resources that
+// it can refer to.  A query can be valid under more than one interpretation
+// (e.g. a number can be both a batch and a slot), so every matching
+// interpretation is returned.
+func (a *API) getSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		retBadReq(&apiError{Err: errors.New(ErrEmptySearchQuery), Code: ErrEmptySearchQueryCode, Type: ErrEmptySearchQueryType}, c)
+		return
+	}
+	var results []searchResult
+	var err error
+	if txID, errTxID := common.NewTxIDFromString(q); errTxID == nil {
+		results, err = a.searchTxID(txID)
+	} else if num, errNum := strconv.ParseInt(q, 10, 64); errNum == nil && num >= 0 {
+		results, err = a.searchNum(num)
+	} else if addr, bjj, errAddr := parseSearchAddr(q); errAddr == nil {
+		results, err = a.searchAccounts(addr, bjj)
+	} else {
+		results, err = a.searchTokenSymbol(q)
+	}
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	if results == nil {
+		results = []searchResult{}
+	}
+	// Build successful response
+	type searchResponse struct {
+		Results []searchResult `json:"results"`
+	}
+	c.JSON(http.StatusOK, &searchResponse{
+		Results: results,
+	})
+}
+
+// parseSearchAddr parses a query that looks like an address: an ethereum
+// address with optional "hez:" prefix, or a BJJ in hez format
+func parseSearchAddr(q string) (*ethCommon.Address, *babyjub.PublicKeyComp, error) {
+	if withoutHez := strings.TrimPrefix(q, "hez:"); ethCommon.IsHexAddress(withoutHez) {
+		addr := ethCommon.HexToAddress(withoutHez)
+		return &addr, nil, nil
+	}
+	var strBJJ apitypes.StrHezBJJ
+	if err := strBJJ.UnmarshalText([]byte(q)); err == nil {
+		bjj := babyjub.PublicKeyComp(strBJJ)
+		return nil, &bjj, nil
+	}
+	return nil, nil, tracerr.Wrap(fmt.Errorf("%q is not an address", q))
+}
+
+// searchTxID looks up the tx ID in the history and, if available, in the pool
+func (a *API) searchTxID(txID common.TxID) ([]searchResult, error) {
+	var results []searchResult
+	if _, err := a.h.GetTxAPI(txID); err == nil {
+		results = append(results, searchResult{
+			Type: "transaction",
+			ID:   txID.String(),
+			URL:  "/v1/transactions-history/" + txID.String(),
+		})
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return nil, tracerr.Wrap(err)
+	}
+	if a.l2 != nil {
+		if _, err := a.l2.GetTxAPI(txID); err == nil {
+			results = append(results, searchResult{
+				Type: "poolTransaction",
+				ID:   txID.String(),
+				URL:  "/v1/transactions-pool/" + txID.String(),
+			})
+		} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+	return results, nil
+}
+
+// searchNum looks up the number as a batch number and as a slot number
+func (a *API) searchNum(num int64) ([]searchResult, error) {
+	var results []searchResult
+	if _, err := a.h.GetBatchAPI(common.BatchNum(num)); err == nil {
+		results = append(results, searchResult{
+			Type: "batch",
+			ID:   strconv.FormatInt(num, 10),
+			URL:  fmt.Sprintf("/v1/batches/%d", num),
+		})
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return nil, tracerr.Wrap(err)
+	}
+	// A slot matches if it has already started or is within the bidding
+	// window of the auction
+	currentBlock, err := a.h.GetLastBlockAPI()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	auctionVars, err := a.h.GetAuctionVarsAPI()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	lastBiddableSlot := a.getCurrentSlot(currentBlock.Num) +
+		int64(auctionVars.ClosedAuctionSlots) + int64(auctionVars.OpenAuctionSlots)
+	if num <= lastBiddableSlot {
+		results = append(results, searchResult{
+			Type: "slot",
+			ID:   strconv.FormatInt(num, 10),
+			URL:  fmt.Sprintf("/v1/slots/%d", num),
+		})
+	}
+	return results, nil
+}
+
+// searchAccounts looks up the accounts of an ethereum address or a BJJ
+func (a *API) searchAccounts(addr *ethCommon.Address, bjj *babyjub.PublicKeyComp) ([]searchResult, error) {
+	accounts, _, err := a.h.GetAccountsAPI(historydb.GetAccountsAPIRequest{
+		EthAddr: addr,
+		Bjj:     bjj,
+		Limit:   &searchLimit,
+		Order:   db.OrderAsc,
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var results []searchResult
+	for i := range accounts {
+		idx := string(accounts[i].Idx)
+		results = append(results, searchResult{
+			Type: "account",
+			ID:   idx,
+			URL:  "/v1/accounts/" + idx,
+		})
+	}
+	return results, nil
+}
+
+// searchTokenSymbol looks up the tokens with the given symbol
+func (a *API) searchTokenSymbol(symbol string) ([]searchResult, error) {
+	tokens, _, err := a.h.GetTokensAPI(historydb.GetTokensAPIRequest{
+		Symbols: []string{symbol},
+		Limit:   &searchLimit,
+		Order:   db.OrderAsc,
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var results []searchResult
+	for i := range tokens {
+		results = append(results, searchResult{
+			Type: "token",
+			ID:   strconv.FormatUint(uint64(tokens[i].TokenID), 10),
+			URL:  fmt.Sprintf("/v1/tokens/%d", tokens[i].TokenID),
+		})
+	}
+	return results, nil
+}
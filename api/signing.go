@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// Response attestation: the coordinator can sign the critical API responses
+// (pool admission receipts, exit data) with its forger key, so that users
+// can later prove that the coordinator accepted a tx or served a piece of
+// exit data.  The signed message is the exact response body: the JSON
+// serialization is canonical because the field order of Go structs is
+// deterministic, so storing the body bytes along with the signature is
+// enough to verify the attestation afterwards.
+
+const (
+	// SignatureHeader is the response header carrying the forger key
+	// signature of the response body
+	SignatureHeader = "X-Hermez-Signature"
+	// SignerHeader is the response header carrying the ethereum address
+	// that produced the signature
+	SignerHeader = "X-Hermez-Signer"
+)
+
+// ResponseSigner signs API response bodies with the coordinator forger key
+type ResponseSigner struct {
+	addr ethCommon.Address
+	// sign signs the given 32 byte hash, like ethKeystore.SignHash
+	sign func(hash []byte) ([]byte, error)
+}
+
+// NewResponseSigner returns a ResponseSigner that signs with the given
+// function, which receives the 32 byte hash to sign (like
+// ethKeystore.SignHash for an unlocked account)
+func NewResponseSigner(addr ethCommon.Address,
+	sign func(hash []byte) ([]byte, error)) *ResponseSigner {
+	return &ResponseSigner{addr: addr, sign: sign}
+}
+
+// SetResponseSigner enables signing the critical API responses with the
+// coordinator forger key
+func (a *API) SetResponseSigner(signer *ResponseSigner) {
+	a.responseSigner = signer
+}
+
+// bufferedResponseWriter captures the response body instead of writing it,
+// so that headers can still be added after the handler has run
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// signedResponseMiddleware signs the body of successful responses with the
+// configured ResponseSigner, returning the signature in SignatureHeader.
+// The signature is an EIP-191 personal message signature of the body
+func (a *API) signedResponseMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.responseSigner == nil {
+			c.Next()
+			return
+		}
+		writer := c.Writer
+		buffered := &bufferedResponseWriter{ResponseWriter: writer}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = writer
+		body := buffered.body.Bytes()
+		if buffered.Status() < 300 && len(body) > 0 { //nolint:gomnd
+			hash := ethCrypto.Keccak256(
+				[]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(body))),
+				body)
+			if signature, err := a.responseSigner.sign(hash); err == nil {
+				writer.Header().Set(SignatureHeader, hexutil.Encode(signature))
+				writer.Header().Set(SignerHeader, a.responseSigner.addr.Hex())
+			} else {
+				log.Errorw("signedResponseMiddleware: sign", "err", err)
+			}
+		}
+		if _, err := writer.Write(body); err != nil {
+			log.Errorw("signedResponseMiddleware: write", "err", err)
+		}
+	}
+}
@@ -21,6 +21,31 @@ type SlotAPI struct {
 	LastBlock   int64             `json:"lastBlock"`
 	OpenAuction bool              `json:"openAuction"`
 	WinnerBid   *historydb.BidAPI `json:"bestBid"`
+	// Forged is true when at least one batch was forged during the slot
+	Forged bool `json:"forged"`
+	// Forging aggregates the forging outcome of the slot, null when no
+	// batch was forged during it
+	Forging *historydb.SlotForgingSummaryAPI `json:"forging"`
+}
+
+// addForgingSummaries fills the Forged and Forging fields of the given slots
+// with the forging outcome of the slots in range [minSlotNum, maxSlotNum]
+func (a *API) addForgingSummaries(slots []SlotAPI, minSlotNum, maxSlotNum int64) error {
+	summaries, err := a.h.GetSlotForgingSummariesAPI(minSlotNum, maxSlotNum)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	bySlotNum := make(map[int64]historydb.SlotForgingSummaryAPI)
+	for i := range summaries {
+		bySlotNum[summaries[i].SlotNum] = summaries[i]
+	}
+	for i := range slots {
+		if summary, ok := bySlotNum[slots[i].SlotNum]; ok {
+			slots[i].Forged = true
+			slots[i].Forging = &summary
+		}
+	}
+	return nil
 }
 
 func (a *API) getFirstLastBlock(slotNum int64) (int64, int64) {
@@ -123,9 +148,14 @@ func (a *API) getSlot(c *gin.Context) {
 	} else {
 		slot = a.newSlotAPI(bid.SlotNum, currentBlock.Num, &bid, auctionVars)
 	}
+	slots := []SlotAPI{slot}
+	if err := a.addForgingSummaries(slots, slot.SlotNum, slot.SlotNum); err != nil {
+		retSQLErr(err, c)
+		return
+	}
 
 	// JSON response
-	c.JSON(http.StatusOK, slot)
+	c.JSON(http.StatusOK, slots[0])
 }
 
 func getLimits(
@@ -323,13 +353,20 @@ func (a *API) getSlots(c *gin.Context) {
 		return
 	}
 
+	if err := a.addForgingSummaries(slots, slotMinLim, slotMaxLim); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+
 	// Build successful response
 	type slotsResponse struct {
 		Slots        []SlotAPI `json:"slots"`
 		PendingItems uint64    `json:"pendingItems"`
+		NextCursor   string    `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &slotsResponse{
 		Slots:        slots,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, slots, pendingItems),
 	})
 }
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// StaleStateHeader is the header added to every response while the API is in
+// safe mode (the synchronizer lags more than the configured threshold behind
+// the ethereum head), carrying the number of blocks of lag
+const StaleStateHeader = "X-Hermez-Stale-State"
+
+// stalenessCacheTTL is the time during which the computed sync lag is reused
+// instead of reading the synced state from the DB again
+const stalenessCacheTTL = 5 * time.Second
+
+// SetStalenessPolicy enables the safe mode: when the synchronizer is more
+// than threshold blocks behind the last known ethereum block, every response
+// carries the StaleStateHeader and, if rejectPoolTxs is set, pool tx
+// submissions are rejected with 503 since their nonce and balance would be
+// validated against stale state.  A threshold of 0 disables the checks
+func (a *API) SetStalenessPolicy(threshold int64, rejectPoolTxs bool) {
+	a.stalenessThreshold = threshold
+	a.rejectStalePoolTxs = rejectPoolTxs
+}
+
+// isStale returns true if a synchronizer lag of lag blocks is beyond the
+// given staleness threshold.  A threshold of 0 disables the check
+func isStale(lag, threshold int64) bool {
+	return threshold > 0 && lag > threshold
+}
+
+// syncLag returns the number of ethereum blocks the synchronizer is behind
+// the head, reading the state kept up to date by the stateapiupdater and
+// caching the result for stalenessCacheTTL so that the per-request check
+// doesn't add a query per request
+func (a *API) syncLag() int64 {
+	a.stalenessMutex.Lock()
+	defer a.stalenessMutex.Unlock()
+	if time.Since(a.stalenessUpdate) < stalenessCacheTTL {
+		return a.stalenessLag
+	}
+	var lag int64
+	state, err := a.h.GetStateInternalAPI()
+	if err != nil {
+		// If the state is not available yet the API can't claim
+		// staleness, so the lag is reported as 0
+		log.Debugw("API.syncLag: GetStateInternalAPI", "err", tracerr.Unwrap(err))
+	} else {
+		lag = state.Network.LastEthBlock - state.Network.LastSyncBlock
+	}
+	a.stalenessLag = lag
+	a.stalenessUpdate = time.Now()
+	return lag
+}
+
+// stalenessMiddleware adds the StaleStateHeader to every response while the
+// API is in safe mode
+func (a *API) stalenessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.stalenessThreshold <= 0 {
+			return
+		}
+		if lag := a.syncLag(); isStale(lag, a.stalenessThreshold) {
+			c.Header(StaleStateHeader, strconv.FormatInt(lag, 10))
+		}
+	}
+}
+
+// rejectIfStale aborts the request with 503 when the API is in safe mode and
+// configured to reject pool tx submissions, returning true if it did
+func (a *API) rejectIfStale(c *gin.Context) bool {
+	if !a.rejectStalePoolTxs || a.stalenessThreshold <= 0 {
+		return false
+	}
+	if !isStale(a.syncLag(), a.stalenessThreshold) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, apiErrorResponse{
+		Message: ErrStaleState,
+		Code:    ErrStaleStateCode,
+		Type:    ErrStaleStateType,
+	})
+	return true
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStale(t *testing.T) {
+	// A threshold of 0 disables the check no matter the lag
+	assert.False(t, isStale(0, 0))
+	assert.False(t, isStale(1000, 0))
+	// The lag must be strictly greater than the threshold
+	assert.False(t, isStale(0, 10))
+	assert.False(t, isStale(10, 10))
+	assert.True(t, isStale(11, 10))
+	// A negative lag (state not available yet) is never stale
+	assert.False(t, isStale(-1, 10))
+}
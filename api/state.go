@@ -1,16 +1,116 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
 	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
 )
 
+// stateDeltaMaxBatches is the maximum amount of new batches returned by the
+// delta endpoint.  Pollers further behind should fall back to GET /state and
+// the batches endpoint
+const stateDeltaMaxBatches = 100
+
 func (a *API) getState(c *gin.Context) {
 	stateAPI, err := a.h.GetStateAPI()
 	if err != nil {
 		retBadReq(err, c)
 		return
 	}
-	c.JSON(http.StatusOK, stateAPI)
+	body, err := json.Marshal(stateAPI)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// The ETag is the hash of the response content, so that pollers can
+	// skip the transfer of an unchanged state with If-None-Match
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:]) + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// stateDeltaResponse is the response of the GET /state/delta endpoint: only
+// what changed since the batch given as marker
+type stateDeltaResponse struct {
+	Since        common.BatchNum  `json:"since"`
+	LastBatchNum *common.BatchNum `json:"lastBatchNum"`
+	Changed      bool             `json:"changed"`
+	// NewBatches are the batches forged after the marker, up to
+	// stateDeltaMaxBatches
+	NewBatches []historydb.BatchAPI `json:"newBatches,omitempty"`
+	// Rollup, Auction and WithdrawalDelayer are only set when the
+	// variables have been updated after the block of the marker batch
+	Rollup            *historydb.RollupVariablesAPI  `json:"rollup,omitempty"`
+	Auction           *historydb.AuctionVariablesAPI `json:"auction,omitempty"`
+	WithdrawalDelayer *common.WDelayerVariables      `json:"withdrawalDelayer,omitempty"`
+	NextForgers       []historydb.NextForgerAPI      `json:"nextForgers,omitempty"`
+}
+
+func (a *API) getStateDelta(c *gin.Context) {
+	// Get since, the batchNum the poller is synced to
+	sinceUint, err := strconv.ParseUint(c.Query("since"), 10, 63)
+	if err != nil {
+		retBadReq(tracerr.Wrap(fmt.Errorf("invalid since: %w", err)), c)
+		return
+	}
+	since := common.BatchNum(sinceUint)
+	stateAPI, err := a.h.GetStateAPI()
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	delta := stateDeltaResponse{Since: since}
+	if stateAPI.Network.LastBatch != nil {
+		delta.LastBatchNum = &stateAPI.Network.LastBatch.BatchNum
+	}
+	if delta.LastBatchNum == nil || *delta.LastBatchNum <= since {
+		// nothing forged since the marker
+		c.JSON(http.StatusOK, &delta)
+		return
+	}
+	delta.Changed = true
+	// the marker batch gives the block from which the vars updates are
+	// detected
+	sinceBatch, err := a.h.GetBatchAPI(since)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	minBatchNum := uint(since)
+	limit := uint(stateDeltaMaxBatches)
+	newBatches, _, err := a.h.GetBatchesAPI(historydb.GetBatchesAPIRequest{
+		MinBatchNum: &minBatchNum,
+		Limit:       &limit,
+		Order:       db.OrderAsc,
+	})
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	delta.NewBatches = newBatches
+	if stateAPI.Rollup.EthBlockNum > sinceBatch.EthBlockNum {
+		delta.Rollup = &stateAPI.Rollup
+	}
+	if stateAPI.Auction.EthBlockNum > sinceBatch.EthBlockNum {
+		delta.Auction = &stateAPI.Auction
+	}
+	if stateAPI.WithdrawalDelayer.EthBlockNum > sinceBatch.EthBlockNum {
+		delta.WithdrawalDelayer = &stateAPI.WithdrawalDelayer
+	}
+	delta.NextForgers = stateAPI.Network.NextForgers
+	c.JSON(http.StatusOK, &delta)
 }
@@ -10,10 +10,12 @@ package stateapiupdater
 import (
 	"database/sql"
 	"fmt"
+	"math/big"
 	"sync"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/hermeznetwork/tracerr"
 )
@@ -21,6 +23,7 @@ import (
 // Updater is an utility object to facilitate updating the StateAPI
 type Updater struct {
 	hdb    *historydb.HistoryDB
+	l2     *l2db.L2DB
 	state  historydb.StateAPI
 	config historydb.NodeConfig
 	vars   common.SCVariablesPtr
@@ -29,6 +32,14 @@ type Updater struct {
 	rfp    *RecommendedFeePolicy
 }
 
+// SetL2DB sets the L2DB used to include pool composition statistics in the
+// state.  Until this method is called, the state carries no pool statistics
+func (u *Updater) SetL2DB(l2 *l2db.L2DB) {
+	u.rw.Lock()
+	defer u.rw.Unlock()
+	u.l2 = l2
+}
+
 // RecommendedFeePolicy describes how the recommended fee is calculated
 type RecommendedFeePolicy struct {
 	PolicyType  RecommendedFeePolicyType `validate:"required"`
@@ -72,6 +83,11 @@ func NewUpdater(hdb *historydb.HistoryDB, config *historydb.NodeConfig, vars *co
 		state: historydb.StateAPI{
 			NodePublicInfo: historydb.NodePublicInfo{
 				ForgeDelay: config.ForgeDelay,
+				MinFeePolicy: &historydb.MinFeePolicyAPI{
+					MinFeeUSD:               config.MinFeeUSD,
+					TokenMinFees:            config.TokenMinFees,
+					FreeTxsPerAccountPerDay: config.FreeTxsPerAccountPerDay,
+				},
 			},
 		},
 		rfp: rfp,
@@ -146,9 +162,23 @@ func (u *Updater) UpdateMetrics() error {
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
+	// Persist the metrics history; the HistoryDB takes care of only
+	// storing snapshots at a bounded rate
+	if err := u.hdb.AddMetricsSnapshotInternalAPI(metrics); err != nil {
+		return tracerr.Wrap(err)
+	}
+	var poolStats *historydb.PoolStats
+	if u.l2 != nil {
+		if poolStats, err = u.l2.GetPoolStatsInternalAPI(); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
 	u.rw.Lock()
 	u.state.Metrics = *metrics
 	u.state.NodePublicInfo.PoolLoad = poolLoad
+	if poolStats != nil {
+		u.state.NodePublicInfo.Pool = poolStats
+	}
 	u.rw.Unlock()
 	return nil
 }
@@ -194,15 +224,27 @@ func (u *Updater) UpdateNetworkInfo(
 	}
 
 	u.rw.Lock()
-	// Update NodeInfo struct
-	for i, bucketParams := range u.state.Rollup.Buckets {
+	// Update NodeInfo struct: overlay the latest bucket updates on top of
+	// the bucket parameters and recompute the refill derived fields
+	// against the last ethereum block
+	for i := range u.vars.Rollup.Buckets {
+		if i >= len(u.state.Rollup.Buckets) {
+			break
+		}
+		bucket := u.vars.Rollup.Buckets[i]
 		for _, bucketUpdate := range bucketUpdates {
 			if bucketUpdate.NumBucket == i {
-				bucketParams.Withdrawals = bucketUpdate.Withdrawals
-				u.state.Rollup.Buckets[i] = bucketParams
+				if bucketUpdate.Withdrawals != nil {
+					if withdrawals, ok := new(big.Int).SetString(
+						string(*bucketUpdate.Withdrawals), 10); ok {
+						bucket.Withdrawals = withdrawals
+						bucket.BlockStamp = big.NewInt(bucketUpdate.BlockStamp)
+					}
+				}
 				break
 			}
 		}
+		u.state.Rollup.Buckets[i] = historydb.NewBucketParamsAPI(&bucket, lastEthBlock.Num)
 	}
 	// Update pending L1s
 	pendingL1s, err := u.hdb.GetUnforgedL1UserTxsCount()
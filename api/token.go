@@ -26,6 +26,30 @@ func (a *API) getToken(c *gin.Context) {
 	c.JSON(http.StatusOK, token)
 }
 
+// getTokenStats returns aggregated usage statistics of a token: transfer
+// volume (raw and USD), tx count and unique active accounts over the last
+// 24h, the last 7 days and since genesis
+func (a *API) getTokenStats(c *gin.Context) {
+	// Get TokenID
+	tokenIDUint, err := parsers.ParseTokenFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	tokenID := common.TokenID(*tokenIDUint)
+	// Check that the token exists, returning 404 otherwise
+	if _, err := a.h.GetTokenAPI(tokenID); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	stats, err := a.h.GetTokenStatsAPI(tokenID)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
 func (a *API) getTokens(c *gin.Context) {
 	// Account filters
 	filters, err := parsers.ParseTokensFilters(c)
@@ -44,9 +68,11 @@ func (a *API) getTokens(c *gin.Context) {
 	type tokensResponse struct {
 		Tokens       []historydb.TokenWithUSD `json:"tokens"`
 		PendingItems uint64                   `json:"pendingItems"`
+		NextCursor   string                   `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &tokensResponse{
 		Tokens:       tokens,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, tokens, pendingItems),
 	})
 }
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/gin-gonic/gin"
+)
+
+// getTokenPolicies returns the policies of all the tokens that have one set.
+// Operator-only endpoint.
+func (a *API) getTokenPolicies(c *gin.Context) {
+	policies, err := a.l2.GetTokenPoliciesAPI()
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	// Build successful response
+	type tokenPoliciesResponse struct {
+		TokenPolicies []l2db.TokenPolicyEntry `json:"tokenPolicies"`
+	}
+	c.JSON(http.StatusOK, &tokenPoliciesResponse{
+		TokenPolicies: policies,
+	})
+}
+
+// setTokenPolicy sets, updates or clears the policy of a token.  The policy
+// "none" clears the policy, accepting the txs of the token again.
+// Operator-only endpoint.
+func (a *API) setTokenPolicy(c *gin.Context) {
+	// Parse body
+	var receivedPolicy struct {
+		TokenID uint32 `json:"tokenId"`
+		Policy  string `json:"policy" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&receivedPolicy); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	tokenID := common.TokenID(receivedPolicy.TokenID)
+	switch l2db.TokenPolicy(receivedPolicy.Policy) {
+	case l2db.TokenPolicyDenied, l2db.TokenPolicyPaused:
+		if err := a.l2.SetTokenPolicyAPI(tokenID,
+			l2db.TokenPolicy(receivedPolicy.Policy)); err != nil {
+			retSQLErr(err, c)
+			return
+		}
+	case "none":
+		if err := a.l2.ClearTokenPolicyAPI(tokenID); err != nil {
+			retSQLErr(err, c)
+			return
+		}
+	default:
+		retBadReq(&apiError{Err: errors.New(ErrInvalidTokenPolicy), Code: ErrInvalidTokenPolicyCode, Type: ErrInvalidTokenPolicyType}, c)
+		return
+	}
+	c.Status(http.StatusOK)
+}
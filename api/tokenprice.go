@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/gin-gonic/gin"
+)
+
+// setTokenPrice pins or clears a manual USD price override for a token.
+// While an override is pinned, the price updater leaves the token price
+// untouched.  A null USD clears the override.
+// Operator-only endpoint.
+func (a *API) setTokenPrice(c *gin.Context) {
+	// Parse body
+	var receivedPrice struct {
+		TokenID uint32   `json:"tokenId"`
+		USD     *float64 `json:"USD"`
+	}
+	if err := c.ShouldBindJSON(&receivedPrice); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	tokenID := common.TokenID(receivedPrice.TokenID)
+	if receivedPrice.USD == nil {
+		if err := a.h.UnpinTokenPrice(tokenID); err != nil {
+			retSQLErr(err, c)
+			return
+		}
+	} else if *receivedPrice.USD <= 0 {
+		retBadReq(&apiError{Err: errors.New(ErrInvalidTokenPrice), Code: ErrInvalidTokenPriceCode, Type: ErrInvalidTokenPriceType}, c)
+		return
+	} else if err := a.h.PinTokenPrice(tokenID, *receivedPrice.USD); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.Status(http.StatusOK)
+}
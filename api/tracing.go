@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/arnaubennassar/hermez-node/trace"
+	"github.com/gin-gonic/gin"
+)
+
+// traceMiddleware opens a tracing span covering each request, so that the
+// spans opened further down (DB queries, eth calls) are correlated under it
+// (see the trace package).  When tracing is disabled the spans are no-ops
+func (a *API) traceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The route pattern (with the parameter placeholders) keeps
+		// the span name cardinality low; unrouted requests fall back
+		// to the raw path
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := trace.StartSpan(c.Request.Context(),
+			c.Request.Method+" "+route)
+		c.Request = c.Request.WithContext(ctx)
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.target", c.Request.URL.Path)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", strconv.Itoa(status))
+		if status >= 500 {
+			span.SetError(errors.New("HTTP " + strconv.Itoa(status)))
+		}
+		span.End()
+	}
+}
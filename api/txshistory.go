@@ -1,11 +1,18 @@
 package api
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/arnaubennassar/hermez-node/api/parsers"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/gin-gonic/gin"
+	"github.com/hermeznetwork/tracerr"
 )
 
 func (a *API) getHistoryTxs(c *gin.Context) {
@@ -14,22 +21,163 @@ func (a *API) getHistoryTxs(c *gin.Context) {
 		retBadReq(err, c)
 		return
 	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
 	// Fetch txs from historyDB
 	txs, pendingItems, err := a.h.GetTxsAPI(txFilters)
 	if err != nil {
 		retSQLErr(err, c)
 		return
 	}
+	applyCurrencyToTxs(txs, rate)
 
 	// Build successful response
 	type txsResponse struct {
 		Txs          []historydb.TxAPI `json:"transactions"`
 		PendingItems uint64            `json:"pendingItems"`
+		NextCursor   string            `json:"nextCursor,omitempty"`
 	}
-	c.JSON(http.StatusOK, &txsResponse{
+	retJSONWithFields(c, &txsResponse{
 		Txs:          txs,
 		PendingItems: pendingItems,
-	})
+		NextCursor:   nextCursor(c, txs, pendingItems),
+	}, "transactions")
+}
+
+// historyTxCSVHeader is the column layout of the CSV export returned by
+// /transactions-history/export
+var historyTxCSVHeader = []string{
+	"id", "type", "L1orL2", "position", "fromAccountIndex", "fromHezEthereumAddress",
+	"fromBJJ", "toAccountIndex", "toHezEthereumAddress", "toBJJ", "amount",
+	"historicUSD", "batchNum", "ethereumBlockNum", "timestamp", "tokenId",
+	"tokenSymbol", "fee", "historicFeeUSD", "nonce", "depositAmount",
+	"historicDepositAmountUSD",
+}
+
+// historyTxCSVRecord flattens a TxAPI into a CSV record following
+// historyTxCSVHeader.  Pointer fields that are nil are rendered as empty
+// columns.
+func historyTxCSVRecord(tx *historydb.TxAPI) []string {
+	l1OrL2 := "L2"
+	if tx.IsL1 {
+		l1OrL2 = "L1"
+	}
+	record := make([]string, 0, len(historyTxCSVHeader))
+	record = append(record, tx.TxID.String(), string(tx.Type), l1OrL2,
+		strconv.Itoa(tx.Position))
+	if tx.FromIdx != nil {
+		record = append(record, string(*tx.FromIdx))
+	} else {
+		record = append(record, "")
+	}
+	if tx.FromEthAddr != nil {
+		record = append(record, string(*tx.FromEthAddr))
+	} else {
+		record = append(record, "")
+	}
+	if tx.FromBJJ != nil {
+		record = append(record, string(*tx.FromBJJ))
+	} else {
+		record = append(record, "")
+	}
+	record = append(record, string(tx.ToIdx))
+	if tx.ToEthAddr != nil {
+		record = append(record, string(*tx.ToEthAddr))
+	} else {
+		record = append(record, "")
+	}
+	if tx.ToBJJ != nil {
+		record = append(record, string(*tx.ToBJJ))
+	} else {
+		record = append(record, "")
+	}
+	record = append(record, string(tx.Amount))
+	if tx.HistoricUSD != nil {
+		record = append(record, strconv.FormatFloat(*tx.HistoricUSD, 'f', -1, 64))
+	} else {
+		record = append(record, "")
+	}
+	if tx.BatchNum != nil {
+		record = append(record, strconv.FormatInt(int64(*tx.BatchNum), 10))
+	} else {
+		record = append(record, "")
+	}
+	record = append(record, strconv.FormatInt(tx.EthBlockNum, 10),
+		tx.Timestamp.UTC().Format("2006-01-02T15:04:05Z"),
+		strconv.FormatUint(uint64(tx.TokenID), 10), tx.TokenSymbol)
+	if tx.Fee != nil {
+		record = append(record, strconv.FormatUint(uint64(*tx.Fee), 10))
+	} else {
+		record = append(record, "")
+	}
+	if tx.HistoricFeeUSD != nil {
+		record = append(record, strconv.FormatFloat(*tx.HistoricFeeUSD, 'f', -1, 64))
+	} else {
+		record = append(record, "")
+	}
+	if tx.Nonce != nil {
+		record = append(record, strconv.FormatInt(int64(*tx.Nonce), 10))
+	} else {
+		record = append(record, "")
+	}
+	if tx.DepositAmount != nil {
+		record = append(record, string(*tx.DepositAmount))
+	} else {
+		record = append(record, "")
+	}
+	if tx.HistoricDepositAmountUSD != nil {
+		record = append(record, strconv.FormatFloat(*tx.HistoricDepositAmountUSD, 'f', -1, 64))
+	} else {
+		record = append(record, "")
+	}
+	return record
+}
+
+func (a *API) exportHistoryTxs(c *gin.Context) {
+	txFilters, err := parsers.ParseHistoryTxsFilters(c, a.validate)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=transactions-history.csv")
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(historyTxCSVHeader); err != nil {
+			retSQLErr(tracerr.Wrap(err), c)
+			return
+		}
+		if err := a.h.GetTxsStreamAPI(txFilters, func(tx *historydb.TxAPI) error {
+			return w.Write(historyTxCSVRecord(tx))
+		}); err != nil {
+			// Headers are already sent, the best that can be done is
+			// logging the error and truncating the response
+			log.Errorw("tx history CSV export interrupted", "err", err)
+			c.Abort()
+			return
+		}
+		w.Flush()
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", "attachment; filename=transactions-history.jsonl")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		if err := a.h.GetTxsStreamAPI(txFilters, func(tx *historydb.TxAPI) error {
+			return enc.Encode(tx)
+		}); err != nil {
+			log.Errorw("tx history JSONL export interrupted", "err", err)
+			c.Abort()
+			return
+		}
+	default:
+		retBadReq(tracerr.Wrap(fmt.Errorf("invalid format %s, expected csv or jsonl", format)), c)
+	}
 }
 
 func (a *API) getHistoryTx(c *gin.Context) {
@@ -39,12 +187,47 @@ func (a *API) getHistoryTx(c *gin.Context) {
 		retBadReq(err, c)
 		return
 	}
+	rate, err := a.requestCurrencyRate(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
 	// Fetch tx from historyDB
 	tx, err := a.h.GetTxAPI(txID)
 	if err != nil {
 		retSQLErr(err, c)
 		return
 	}
+	txs := []historydb.TxAPI{*tx}
+	applyCurrencyToTxs(txs, rate)
 	// Build successful response
-	c.JSON(http.StatusOK, tx)
+	c.JSON(http.StatusOK, &txs[0])
+}
+
+// getHistoryTxInclusionProof returns the data needed to verify that a forged
+// L2 tx is included in the data availability bytes published on-chain in the
+// forgeBatch call of its batch: the batchNum, the tx position, the state
+// root, the ethereum tx hash and the offsets of the tx's encoded bytes
+// within the calldata
+func (a *API) getHistoryTxInclusionProof(c *gin.Context) {
+	// Get TxID
+	txID, err := parsers.ParseHistoryTxFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Fetch the inclusion proof from historyDB
+	proof, err := a.h.GetTxInclusionProofAPI(txID)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	if proof.DAOffset == nil {
+		retBadReq(&apiError{Err: errors.New(ErrInclusionProofNotAvailable),
+			Code: ErrInclusionProofNotAvailableCode,
+			Type: ErrInclusionProofNotAvailableType}, c)
+		return
+	}
+	// JSON response
+	c.JSON(http.StatusOK, proof)
 }
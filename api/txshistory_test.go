@@ -22,8 +22,10 @@ type testL1Info struct {
 	ToForgeL1TxsNum          *int64   `json:"toForgeL1TransactionsNum"`
 	UserOrigin               bool     `json:"userOrigin"`
 	DepositAmount            string   `json:"depositAmount"`
-	AmountSuccess            bool     `json:"amountSuccess"`
-	DepositAmountSuccess     bool     `json:"depositAmountSuccess"`
+	AmountSuccess            *bool    `json:"amountSuccess"`
+	DepositAmountSuccess     *bool    `json:"depositAmountSuccess"`
+	EffectiveAmount          *string  `json:"effectiveAmount"`
+	EffectiveDepositAmount   *string  `json:"effectiveDepositAmount"`
 	HistoricDepositAmountUSD *float64 `json:"historicDepositAmountUSD"`
 	EthBlockNum              int64    `json:"ethereumBlockNum"`
 }
@@ -117,6 +119,9 @@ func genTestTxs(
 		// l1.FromEthAddr and l1.FromBJJ can't be nil
 		fromEthAddr := string(apitypes.NewHezEthAddr(l1.FromEthAddr))
 		fromBJJ := string(apitypes.NewHezBJJ(l1.FromBJJ))
+		success := true
+		effectiveAmount := l1.Amount.String()
+		effectiveDepositAmount := l1.DepositAmount.String()
 		tx := testTx{
 			IsL1:        "L1",
 			TxID:        l1.TxID,
@@ -129,12 +134,14 @@ func genTestTxs(
 			BatchNum:    l1.BatchNum,
 			Timestamp:   getTimestamp(l1.EthBlockNum, blocks),
 			L1Info: &testL1Info{
-				ToForgeL1TxsNum:      l1.ToForgeL1TxsNum,
-				UserOrigin:           l1.UserOrigin,
-				DepositAmount:        l1.DepositAmount.String(),
-				AmountSuccess:        true,
-				DepositAmountSuccess: true,
-				EthBlockNum:          l1.EthBlockNum,
+				ToForgeL1TxsNum:        l1.ToForgeL1TxsNum,
+				UserOrigin:             l1.UserOrigin,
+				DepositAmount:          l1.DepositAmount.String(),
+				AmountSuccess:          &success,
+				DepositAmountSuccess:   &success,
+				EffectiveAmount:        &effectiveAmount,
+				EffectiveDepositAmount: &effectiveDepositAmount,
+				EthBlockNum:            l1.EthBlockNum,
 			},
 			Token: token,
 		}
@@ -152,8 +159,10 @@ func genTestTxs(
 		if i == len(l1s)-1 {
 			// Last tx of the L1 set is supposed to be unforged as per the til set.
 			// Unforged txs have some special propperties
-			tx.L1Info.DepositAmountSuccess = false
-			tx.L1Info.AmountSuccess = false
+			tx.L1Info.DepositAmountSuccess = nil
+			tx.L1Info.AmountSuccess = nil
+			tx.L1Info.EffectiveAmount = nil
+			tx.L1Info.EffectiveDepositAmount = nil
 			tx.BatchNum = nil
 			idxStrUnforged := common.IdxToHez(l1.FromIdx, token.Symbol)
 			tx.FromIdx = &idxStrUnforged
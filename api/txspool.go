@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"math/big"
@@ -10,38 +11,239 @@ import (
 	"github.com/arnaubennassar/hermez-node/api/parsers"
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/gossip"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-iden3-crypto/babyjub"
 )
 
 func (a *API) postPoolTx(c *gin.Context) {
-	// Parse body
-	var receivedTx receivedPoolTx
-	if err := c.ShouldBindJSON(&receivedTx); err != nil {
+	// In safe mode the nonce/balance validation would run against stale
+	// state, so submissions can be configured to be rejected (see
+	// staleness.go)
+	if a.rejectIfStale(c) {
+		return
+	}
+	// Parse body, keeping the raw bytes so that the tx can be forwarded
+	// as received to cooperating coordinators
+	body, err := c.GetRawData()
+	if err != nil {
 		retBadReq(err, c)
 		return
 	}
+	txID, err := a.SubmitPoolTx(body, c.ClientIP(),
+		c.GetHeader(gossip.ForwardedHeader) != "")
+	if err != nil {
+		if invalidErr, ok := tracerr.Unwrap(err).(*InvalidPoolTxError); ok {
+			retBadReq(invalidErr.Err, c)
+		} else if tracerr.Unwrap(err) == l2db.ErrTxIDConflict {
+			c.JSON(http.StatusConflict, apiErrorResponse{
+				Message: ErrSameTxIDDifferentContent,
+				Code:    ErrSameTxIDDifferentContentCode,
+				Type:    ErrSameTxIDDifferentContentType,
+			})
+		} else {
+			retSQLErr(err, c)
+		}
+		return
+	}
+	// Return TxID
+	c.JSON(http.StatusOK, txID.String())
+}
+
+// InvalidPoolTxError wraps the pool tx admission failures caused by the
+// submitted tx itself (parsing, validation, token policy, fee) rather than by
+// the node, so that each API surface can map them to its bad request status
+// without knowing the individual reasons
+type InvalidPoolTxError struct {
+	Err error
+}
+
+func (e *InvalidPoolTxError) Error() string {
+	return e.Err.Error()
+}
+
+// SubmitPoolTx runs the pool tx admission pipeline shared by the REST and
+// gRPC surfaces: it parses body (the JSON encoding of a receivedPoolTx),
+// validates the tx, inserts it in the pool and forwards it to cooperating
+// coordinators unless it was itself received from one (forwarded).
+// Resubmissions of the exact same signed tx are idempotent and return the
+// same TxID.  Failures caused by the tx itself are returned wrapped in
+// InvalidPoolTxError.
+func (a *API) SubmitPoolTx(body []byte, clientIP string, forwarded bool) (common.TxID, error) {
+	var receivedTx receivedPoolTx
+	if err := binding.JSON.BindBody(body, &receivedTx); err != nil {
+		return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: err})
+	}
 	// Transform from received to insert format and validate
 	writeTx := receivedTx.toPoolL2TxWrite()
 	// Reject atomic transactions
 	if isAtomic(*writeTx) {
-		retBadReq(errors.New(ErrIsAtomic), c)
-		return
+		return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+			Err: errors.New(ErrIsAtomic), Code: ErrIsAtomicCode, Type: ErrIsAtomicType}})
+	}
+	// Reject txs of tokens denied or paused by the operator
+	if policy, err := a.l2.GetTokenPolicyAPI(writeTx.TokenID); err == nil {
+		switch policy {
+		case l2db.TokenPolicyDenied:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err: errors.New(ErrTokenDenied), Code: ErrTokenDeniedCode, Type: ErrTokenDeniedType}})
+		case l2db.TokenPolicyPaused:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err: errors.New(ErrTokenPaused), Code: ErrTokenPausedCode, Type: ErrTokenPausedType}})
+		}
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return common.TxID{}, tracerr.Wrap(err)
 	}
 	if err := a.verifyPoolL2TxWrite(writeTx); err != nil {
-		retBadReq(err, c)
-		return
+		return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: err})
 	}
-	writeTx.ClientIP = c.ClientIP()
+	writeTx.ClientIP = clientIP
 	// Insert to DB
 	if err := a.l2.AddTxAPI(writeTx); err != nil {
-		retSQLErr(err, c)
+		switch tracerr.Unwrap(err) {
+		case l2db.ErrDuplicatedTx:
+			// Idempotent resubmission of the exact same signed tx:
+			// return the same response as the original insert
+			return writeTx.TxID, nil
+		case l2db.ErrFeeTooLow:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err: errors.New(ErrFeeTooLow), Code: ErrFeeTooLowCode,
+				Type: ErrFeeTooLowType}})
+		case l2db.ErrFreeTxAllowanceExceeded:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err:  errors.New(ErrFreeTxAllowanceExceeded),
+				Code: ErrFreeTxAllowanceExceededCode, Type: ErrFreeTxAllowanceExceededType}})
+		case l2db.ErrReplaceFeeTooLow:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err:  errors.New(ErrReplaceFeeTooLow),
+				Code: ErrReplaceFeeTooLowCode, Type: ErrReplaceFeeTooLowType}})
+		case l2db.ErrTxAdminDeleted:
+			return common.TxID{}, tracerr.Wrap(&InvalidPoolTxError{Err: &apiError{
+				Err:  errors.New(ErrTxAdminDeleted),
+				Code: ErrTxAdminDeletedCode, Type: ErrTxAdminDeletedType}})
+		default:
+			return common.TxID{}, tracerr.Wrap(err)
+		}
+	}
+	// Forward the tx to cooperating coordinators, unless it was itself
+	// received from one (loop suppression, see the gossip package)
+	if a.txGossiper != nil && !forwarded {
+		a.txGossiper.ForwardPoolTx(body)
+	}
+	return writeTx.TxID, nil
+}
+
+// unsignedPoolTx is a receivedPoolTx without the signature, and with the id
+// and type optional, since the endpoint that receives it computes both
+type unsignedPoolTx struct {
+	TxID        common.TxID             `json:"id"`
+	Type        common.TxType           `json:"type"`
+	TokenID     common.TokenID          `json:"tokenId"`
+	FromIdx     apitypes.StrHezIdx      `json:"fromAccountIndex" binding:"required"`
+	ToIdx       *apitypes.StrHezIdx     `json:"toAccountIndex"`
+	ToEthAddr   *apitypes.StrHezEthAddr `json:"toHezEthereumAddress"`
+	ToBJJ       *apitypes.StrHezBJJ     `json:"toBjj"`
+	Amount      apitypes.StrBigInt      `json:"amount" binding:"required"`
+	Fee         common.FeeSelector      `json:"fee"`
+	Nonce       common.Nonce            `json:"nonce"`
+	RqFromIdx   *apitypes.StrHezIdx     `json:"requestFromAccountIndex"`
+	RqToIdx     *apitypes.StrHezIdx     `json:"requestToAccountIndex"`
+	RqToEthAddr *apitypes.StrHezEthAddr `json:"requestToHezEthereumAddress"`
+	RqToBJJ     *apitypes.StrHezBJJ     `json:"requestToBjj"`
+	RqTokenID   *common.TokenID         `json:"requestTokenId"`
+	RqAmount    *apitypes.StrBigInt     `json:"requestAmount"`
+	RqFee       *common.FeeSelector     `json:"requestFee"`
+	RqNonce     *common.Nonce           `json:"requestNonce"`
+	MaxNumBatch uint32                  `json:"maxNumBatch"`
+}
+
+// postPoolTxHash computes the Poseidon HashToSign of an unsigned pool tx for
+// the node's chainID along with the expected TxID, without inserting
+// anything.  It lets wallets and SDKs verify their local hashing
+// implementation against the node before submitting txs, since hashing with
+// a mismatched chainID is a common integration failure
+func (a *API) postPoolTxHash(c *gin.Context) {
+	var receivedTx unsignedPoolTx
+	if err := c.ShouldBindJSON(&receivedTx); err != nil {
+		retBadReq(err, c)
 		return
 	}
-	// Return TxID
-	c.JSON(http.StatusOK, writeTx.TxID.String())
+	poolTx := common.PoolL2Tx{
+		TxID:        receivedTx.TxID,
+		FromIdx:     common.Idx(receivedTx.FromIdx),
+		TokenID:     receivedTx.TokenID,
+		Amount:      (*big.Int)(&receivedTx.Amount),
+		Fee:         receivedTx.Fee,
+		Nonce:       receivedTx.Nonce,
+		RqAmount:    (*big.Int)(receivedTx.RqAmount),
+		Type:        receivedTx.Type,
+		MaxNumBatch: receivedTx.MaxNumBatch,
+	}
+	// Optional fields get the same empty defaults as in verifyPoolL2TxWrite
+	if receivedTx.ToIdx != nil {
+		poolTx.ToIdx = common.Idx(*receivedTx.ToIdx)
+	}
+	if receivedTx.ToEthAddr == nil {
+		poolTx.ToEthAddr = common.EmptyAddr
+	} else {
+		poolTx.ToEthAddr = ethCommon.Address(*receivedTx.ToEthAddr)
+	}
+	if receivedTx.ToBJJ == nil {
+		poolTx.ToBJJ = common.EmptyBJJComp
+	} else {
+		poolTx.ToBJJ = babyjub.PublicKeyComp(*receivedTx.ToBJJ)
+	}
+	if receivedTx.RqFromIdx != nil {
+		poolTx.RqFromIdx = common.Idx(*receivedTx.RqFromIdx)
+	}
+	if receivedTx.RqToIdx != nil {
+		poolTx.RqToIdx = common.Idx(*receivedTx.RqToIdx)
+	}
+	if receivedTx.RqToEthAddr == nil {
+		poolTx.RqToEthAddr = common.EmptyAddr
+	} else {
+		poolTx.RqToEthAddr = ethCommon.Address(*receivedTx.RqToEthAddr)
+	}
+	if receivedTx.RqToBJJ == nil {
+		poolTx.RqToBJJ = common.EmptyBJJComp
+	} else {
+		poolTx.RqToBJJ = babyjub.PublicKeyComp(*receivedTx.RqToBJJ)
+	}
+	if receivedTx.RqTokenID != nil {
+		poolTx.RqTokenID = *receivedTx.RqTokenID
+	}
+	if receivedTx.RqFee != nil {
+		poolTx.RqFee = *receivedTx.RqFee
+	}
+	if receivedTx.RqNonce != nil {
+		poolTx.RqNonce = *receivedTx.RqNonce
+	}
+	// Compute the type and the TxID; if the request included them they are
+	// validated against the computed ones
+	tx, err := common.NewPoolL2Tx(&poolTx)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	hashToSign, err := tx.HashToSign(a.cg.ChainID)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Build successful response
+	type txHashResponse struct {
+		ID         common.TxID         `json:"id"`
+		ChainID    uint16              `json:"chainId"`
+		HashToSign *apitypes.BigIntStr `json:"hashToSign"`
+	}
+	c.JSON(http.StatusOK, &txHashResponse{
+		ID:         tx.TxID,
+		ChainID:    a.cg.ChainID,
+		HashToSign: apitypes.NewBigIntStr(hashToSign),
+	})
 }
 
 func (a *API) getPoolTx(c *gin.Context) {
@@ -78,10 +280,12 @@ func (a *API) getPoolTxs(c *gin.Context) {
 	type txsResponse struct {
 		Txs          []l2db.PoolTxAPI `json:"transactions"`
 		PendingItems uint64           `json:"pendingItems"`
+		NextCursor   string           `json:"nextCursor,omitempty"`
 	}
 	c.JSON(http.StatusOK, &txsResponse{
 		Txs:          txs,
 		PendingItems: pendingItems,
+		NextCursor:   nextCursor(c, txs, pendingItems),
 	})
 }
 
@@ -105,6 +309,7 @@ type receivedPoolTx struct {
 	RqAmount    *apitypes.StrBigInt     `json:"requestAmount"`
 	RqFee       *common.FeeSelector     `json:"requestFee"`
 	RqNonce     *common.Nonce           `json:"requestNonce"`
+	MaxNumBatch uint32                  `json:"maxNumBatch"`
 }
 
 func (tx *receivedPoolTx) toPoolL2TxWrite() *l2db.PoolL2TxWrite {
@@ -132,6 +337,7 @@ func (tx *receivedPoolTx) toPoolL2TxWrite() *l2db.PoolL2TxWrite {
 		RqFee:       tx.RqFee,
 		RqNonce:     tx.RqNonce,
 		Type:        tx.Type,
+		MaxNumBatch: tx.MaxNumBatch,
 	}
 }
 
@@ -144,9 +350,10 @@ func (a *API) verifyPoolL2TxWrite(txw *l2db.PoolL2TxWrite) error {
 		Fee:     txw.Fee,
 		Nonce:   txw.Nonce,
 		// State:     txw.State,
-		Signature: txw.Signature,
-		RqAmount:  txw.RqAmount,
-		Type:      txw.Type,
+		Signature:   txw.Signature,
+		RqAmount:    txw.RqAmount,
+		Type:        txw.Type,
+		MaxNumBatch: txw.MaxNumBatch,
 	}
 	// ToIdx
 	if txw.ToIdx != nil {
@@ -206,6 +413,26 @@ func (a *API) verifyPoolL2TxWrite(txw *l2db.PoolL2TxWrite) error {
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
+	// Amount representability: amounts are encoded as Float40 when the tx
+	// is forged, so an amount that loses precision in the conversion
+	// would be forged with a different value than the signed one
+	if _, err := common.NewFloat40(poolTx.Amount); err != nil {
+		return tracerr.Wrap(amountNotRepresentableError(poolTx.Amount))
+	}
+	// MaxNumBatch: the tx will be forged in a batch after the last forged
+	// one, so a MaxNumBatch that the chain has already reached could never
+	// be selected
+	if poolTx.MaxNumBatch != 0 {
+		state, err := a.h.GetStateInternalAPI()
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if state.Network.LastBatch != nil &&
+			poolTx.MaxNumBatch <= uint32(state.Network.LastBatch.BatchNum) {
+			return tracerr.Wrap(&apiError{Err: errors.New(ErrMaxNumBatchPassed),
+				Code: ErrMaxNumBatchPassedCode, Type: ErrMaxNumBatchPassedType})
+		}
+	}
 	// Get sender account information
 	account, err := a.h.GetCommonAccountAPI(poolTx.FromIdx)
 	if err != nil {
@@ -257,7 +484,8 @@ func (a *API) verifyPoolL2TxWrite(txw *l2db.PoolL2TxWrite) error {
 	switch poolTx.Type {
 	case common.TxTypeExit:
 		if poolTx.Amount.Cmp(big.NewInt(0)) <= 0 {
-			return tracerr.New(ErrExitAmount0)
+			return tracerr.Wrap(&apiError{Err: errors.New(ErrExitAmount0),
+				Code: ErrExitAmount0Code, Type: ErrExitAmount0Type})
 		}
 	}
 	return nil
@@ -274,3 +502,60 @@ func isAtomic(tx l2db.PoolL2TxWrite) bool {
 		(tx.RqNonce != nil && *tx.RqNonce != 0) ||
 		(tx.RqTokenID != nil && *tx.RqTokenID != 0)
 }
+
+// purgePoolTxs deletes from the pool the txs that have been marked for
+// external deletion.  Operator-only endpoint.
+func (a *API) purgePoolTxs(c *gin.Context) {
+	if err := a.l2.PurgeByExternalDelete(); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// deletePoolTx marks a pending pool tx as invalid with an optional
+// operator-supplied reason that is recorded in the audit trail.  The TxID
+// can't be re-admitted afterwards.  Operator-only endpoint.
+func (a *API) deletePoolTx(c *gin.Context) {
+	txID, err := parsers.ParsePoolTxFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			retBadReq(err, c)
+			return
+		}
+	}
+	if err := a.l2.InvalidateTxByAdminAPI(txID, body.Reason); err != nil {
+		if tracerr.Unwrap(err) == l2db.ErrTxNotPending {
+			retBadReq(&apiError{Err: errors.New(ErrTxNotPending),
+				Code: ErrTxNotPendingCode, Type: ErrTxNotPendingType}, c)
+			return
+		}
+		retSQLErr(err, c)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// amountNotRepresentableError builds the ErrAmountNotRepresentable apiError
+// for the given amount, including the nearest lower amount that can be
+// encoded as Float40 without precision loss
+func amountNotRepresentableError(amount *big.Int) error {
+	nearest := big.NewInt(0)
+	if f40, err := common.NewFloat40Floor(amount); err == nil {
+		if v, err := f40.BigInt(); err == nil {
+			nearest = v
+		}
+	}
+	return &apiError{
+		Err:  fmt.Errorf("%s%s", ErrAmountNotRepresentable, nearest.String()),
+		Code: ErrAmountNotRepresentableCode,
+		Type: ErrAmountNotRepresentableType,
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/webhook"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// webhookResponse is the representation of a webhook subscription returned by
+// the API.  The secret is never returned
+type webhookResponse struct {
+	ID                 int64                `json:"id"`
+	URL                string               `json:"url"`
+	HezEthereumAddress *apitypes.HezEthAddr `json:"hezEthereumAddress"`
+	BJJ                *apitypes.HezBJJ     `json:"bjj"`
+	AccountIndex       *common.Idx          `json:"accountIndex"`
+	EventTypes         []string             `json:"eventTypes"`
+}
+
+func newWebhookResponse(sub *historydb.WebhookSubscription) webhookResponse {
+	response := webhookResponse{
+		ID:           sub.ID,
+		URL:          sub.URL,
+		AccountIndex: sub.Idx,
+		EventTypes:   strings.Split(sub.EventTypes, ","),
+	}
+	if sub.EthAddr != nil {
+		ethAddr := apitypes.NewHezEthAddr(*sub.EthAddr)
+		response.HezEthereumAddress = &ethAddr
+	}
+	if sub.BJJ != nil {
+		bjj := apitypes.NewHezBJJ(*sub.BJJ)
+		response.BJJ = &bjj
+	}
+	return response
+}
+
+// postWebhook registers a new webhook subscription: a URL that will receive
+// signed notifications of the events matching the filter.  Operator-only
+// endpoint.
+func (a *API) postWebhook(c *gin.Context) {
+	// Parse body
+	var receivedSub struct {
+		URL                string                  `json:"url" binding:"required,url"`
+		Secret             string                  `json:"secret" binding:"required"`
+		HezEthereumAddress *apitypes.StrHezEthAddr `json:"hezEthereumAddress"`
+		BJJ                *apitypes.StrHezBJJ     `json:"bjj"`
+		AccountIndex       *apitypes.StrHezIdx     `json:"accountIndex"`
+		EventTypes         []string                `json:"eventTypes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&receivedSub); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	for _, eventType := range receivedSub.EventTypes {
+		if !webhook.ValidEventType(eventType) {
+			retBadReq(&apiError{
+				Err:  errors.New(ErrInvalidWebhookEventType),
+				Code: ErrInvalidWebhookEventTypeCode,
+				Type: ErrInvalidWebhookEventTypeType,
+			}, c)
+			return
+		}
+	}
+	sub := historydb.WebhookSubscription{
+		URL:        receivedSub.URL,
+		Secret:     receivedSub.Secret,
+		EthAddr:    (*ethCommon.Address)(receivedSub.HezEthereumAddress),
+		BJJ:        (*babyjub.PublicKeyComp)(receivedSub.BJJ),
+		Idx:        (*common.Idx)(receivedSub.AccountIndex),
+		EventTypes: strings.Join(receivedSub.EventTypes, ","),
+	}
+	if err := a.h.AddWebhookSubscriptionAPI(&sub); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(&sub))
+}
+
+// getWebhooks returns all the registered webhook subscriptions.
+// Operator-only endpoint.
+func (a *API) getWebhooks(c *gin.Context) {
+	subs, err := a.h.GetWebhookSubscriptionsAPI()
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	webhooks := make([]webhookResponse, len(subs))
+	for i := range subs {
+		webhooks[i] = newWebhookResponse(&subs[i])
+	}
+	// Build successful response
+	type webhooksResponse struct {
+		Webhooks []webhookResponse `json:"webhooks"`
+	}
+	c.JSON(http.StatusOK, &webhooksResponse{Webhooks: webhooks})
+}
+
+// deleteWebhook unregisters the webhook subscription with the given id.
+// Operator-only endpoint.
+func (a *API) deleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	if err := a.h.DeleteWebhookSubscriptionAPI(id); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.Status(http.StatusOK)
+}
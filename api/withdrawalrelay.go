@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/api/parsers"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// receivedWithdrawalRelay is the body of a withdrawal relay request
+type receivedWithdrawalRelay struct {
+	EthAddr      apitypes.StrHezEthAddr `json:"hezEthereumAddress" binding:"required"`
+	BatchNum     uint                   `json:"batchNum" binding:"required"`
+	AccountIndex string                 `json:"accountIndex" binding:"required"`
+	Fee          apitypes.StrBigInt     `json:"fee"`
+	Signature    apitypes.EthSignature  `json:"signature" binding:"required"`
+}
+
+// withdrawalRelayResponse is the representation of a withdrawal relay
+// request returned by the API
+type withdrawalRelayResponse struct {
+	BatchNum           common.BatchNum     `json:"batchNum"`
+	AccountIndex       apitypes.HezIdx     `json:"accountIndex"`
+	HezEthereumAddress apitypes.HezEthAddr `json:"hezEthereumAddress"`
+	Fee                apitypes.BigIntStr  `json:"fee"`
+	Status             string              `json:"status"`
+	EthereumTxHash     *ethCommon.Hash     `json:"ethereumTxHash"`
+	Info               string              `json:"info,omitempty"`
+	Timestamp          time.Time           `json:"timestamp"`
+}
+
+func newWithdrawalRelayResponse(request *l2db.WithdrawalRelayRequest) *withdrawalRelayResponse {
+	resp := &withdrawalRelayResponse{
+		BatchNum:           request.BatchNum,
+		AccountIndex:       request.AccountIdxHez,
+		HezEthereumAddress: apitypes.NewHezEthAddr(request.EthAddr),
+		Fee:                *apitypes.NewBigIntStr(request.Fee),
+		Status:             request.Status,
+		Info:               request.Info,
+		Timestamp:          request.Timestamp,
+	}
+	if request.EthTxHash != (ethCommon.Hash{}) {
+		resp.EthereumTxHash = &request.EthTxHash
+	}
+	return resp
+}
+
+// postWithdrawalRelay receives an authorization to submit the
+// withdrawMerkleProof of an exit on behalf of its owner.  The exit must be
+// already forged and not withdrawn, and the signature must be done by the
+// owner of the exit.  The per token safety limits are applied by the
+// coordinator withdrawal relayer when the request is processed, not here:
+// requests that don't pass them are moved to the rejected status
+func (a *API) postWithdrawalRelay(c *gin.Context) {
+	// Parse body
+	var req receivedWithdrawalRelay
+	if err := c.ShouldBindJSON(&req); err != nil {
+		retBadReq(err, c)
+		return
+	}
+	idx, err := common.StringToIdx(req.AccountIndex, "accountIndex")
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	// Check that the exit exists and is still withdrawable
+	exit, err := a.h.GetExitAPI(&req.BatchNum, idx)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	if exit.InstantWithdrawn != nil || exit.DelayedWithdrawRequest != nil {
+		retBadReq(errors.New("the exit has already been withdrawn"), c)
+		return
+	}
+	// The withdrawn amount is sent to the owner of the exit by the smart
+	// contract, but only the owner can commit to pay the relay fee
+	if exit.EthAddr == nil ||
+		*exit.EthAddr != apitypes.NewHezEthAddr(ethCommon.Address(req.EthAddr)) {
+		retBadReq(errors.New("hezEthereumAddress is not the owner of the exit"), c)
+		return
+	}
+	auth := &common.WithdrawalRelayAuth{
+		EthAddr:    ethCommon.Address(req.EthAddr),
+		BatchNum:   common.BatchNum(req.BatchNum),
+		AccountIdx: *idx,
+		Fee:        (*big.Int)(&req.Fee),
+		Signature:  []byte(req.Signature),
+	}
+	if !auth.VerifySignature(a.cg.ChainID, a.hermezAddress) {
+		retBadReq(errors.New("invalid signature"), c)
+		return
+	}
+	// Insert to DB
+	if err := a.l2.AddWithdrawalRelayRequestAPI(auth); err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	request, err := a.l2.GetWithdrawalRelayRequestAPI(auth.BatchNum, auth.AccountIdx)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newWithdrawalRelayResponse(request))
+}
+
+// getWithdrawalRelay returns the withdrawal relay request of an exit,
+// including its L1 submission status
+func (a *API) getWithdrawalRelay(c *gin.Context) {
+	batchNum, idx, err := parsers.ParseExitFilter(c)
+	if err != nil {
+		retBadReq(err, c)
+		return
+	}
+	request, err := a.l2.GetWithdrawalRelayRequestAPI(common.BatchNum(*batchNum), *idx)
+	if err != nil {
+		retSQLErr(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, newWithdrawalRelayResponse(request))
+}
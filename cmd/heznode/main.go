@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"os/signal"
 	"path"
@@ -11,18 +15,26 @@ import (
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/config"
+	"github.com/arnaubennassar/hermez-node/dataavailability"
 	dbUtils "github.com/arnaubennassar/hermez-node/db"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
 	"github.com/arnaubennassar/hermez-node/db/kvdb"
 	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/db/statedb"
+	"github.com/arnaubennassar/hermez-node/eth"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/arnaubennassar/hermez-node/node"
+	"github.com/arnaubennassar/hermez-node/txprocessor"
+	"github.com/arnaubennassar/hermez-node/txselector"
+	"github.com/ethereum/go-ethereum/accounts"
 	ethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/hermeznetwork/hermez-go-sdk/account"
 	"github.com/hermeznetwork/hermez-go-sdk/client"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-merkletree/db/pebble"
 	"github.com/jmoiron/sqlx"
 	"github.com/urfave/cli/v2"
 )
@@ -33,12 +45,17 @@ const (
 	flagSK                  = "privatekey"
 	flagYes                 = "yes"
 	flagBlock               = "block"
+	flagBatch               = "batch"
+	flagDir                 = "dir"
 	modeSync                = "sync"
 	modeCoord               = "coord"
+	modeFull                = "full"
 	nMigrations             = "nMigrations"
 	flagAuctContractAddrHex = "auctContractAddrHex"
 	flagEthNodeURL          = "ethNodeUrl"
 	flagAccountAddrHex      = "accountAddrHex"
+	flagNetworks            = "networks"
+	flagAPIAddress          = "apiAddress"
 )
 
 var (
@@ -71,8 +88,8 @@ func cmdImportKey(c *cli.Context) error {
 	if err != nil {
 		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
 	}
-	if _cfg.mode != node.ModeCoordinator {
-		return tracerr.Wrap(fmt.Errorf("importkey must use mode coordinator"))
+	if !_cfg.mode.Forging() {
+		return tracerr.Wrap(fmt.Errorf("importkey must use mode coordinator or full"))
 	}
 	cfg := _cfg.node
 
@@ -134,7 +151,7 @@ func resetStateDBs(cfg *Config, batchNum common.BatchNum) error {
 		return tracerr.Wrap(fmt.Errorf("db.Reset: %w", err))
 	}
 
-	if cfg.mode == node.ModeCoordinator {
+	if cfg.mode.Forging() {
 		log.Infof("Wipe Coordinator StateDBs...")
 
 		// We wipe the Coordinator StateDBs entirely (by deleting
@@ -261,6 +278,30 @@ func cmdSQLMigrationDown(c *cli.Context) error {
 	return nil
 }
 
+func cmdSQLMigrationUp(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+	db, err := dbUtils.ConnectSQLDB(
+		cfg.PostgreSQL.PortWrite,
+		cfg.PostgreSQL.HostWrite,
+		cfg.PostgreSQL.UserWrite,
+		cfg.PostgreSQL.PasswordWrite,
+		cfg.PostgreSQL.NameWrite,
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	log.Info("Running pending SQL migrations...")
+	if err := dbUtils.MigrationsUp(db.DB); err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.MigrationsUp: %w", err))
+	}
+	log.Info("SQL migrations up successfully")
+	return nil
+}
+
 func waitSigInt() {
 	stopCh := make(chan interface{})
 
@@ -300,6 +341,49 @@ func cmdRun(c *cli.Context) error {
 	return nil
 }
 
+func cmdRunMulti(c *cli.Context) error {
+	var mode node.Mode
+	coordinator := false
+	switch c.String(flagMode) {
+	case modeSync:
+		mode = node.ModeSynchronizer
+	case modeCoord:
+		mode = node.ModeCoordinator
+		coordinator = true
+	case modeFull:
+		mode = node.ModeFull
+		coordinator = true
+	default:
+		return tracerr.Wrap(fmt.Errorf("invalid mode \"%v\"", c.String(flagMode)))
+	}
+	// Parse the network specs, formatted as
+	// "name1=cfgPath1,name2=cfgPath2"
+	var networks []node.NetworkConfig
+	for _, spec := range strings.Split(c.String(flagNetworks), ",") {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return tracerr.Wrap(fmt.Errorf(
+				"invalid network spec \"%v\", expecting \"name=cfgPath\"", spec))
+		}
+		cfg, err := config.LoadNode(parts[1], coordinator)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("error loading network %q config: %w",
+				parts[0], err))
+		}
+		networks = append(networks, node.NetworkConfig{Name: parts[0], Cfg: cfg})
+	}
+	multiNode, err := node.NewMultiNode(mode, networks, c.App.Version,
+		c.String(flagAPIAddress))
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error starting multi network node: %w", err))
+	}
+	multiNode.Start()
+	waitSigInt()
+	multiNode.Stop()
+
+	return nil
+}
+
 func cmdServeAPI(c *cli.Context) error {
 	cfg, err := parseCliAPIServer(c)
 	if err != nil {
@@ -316,6 +400,63 @@ func cmdServeAPI(c *cli.Context) error {
 	return nil
 }
 
+func cmdBatchDA(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+	batchNum := c.Int64(flagBatch)
+
+	var dbRead *sqlx.DB
+	if cfg.PostgreSQL.HostRead == "" {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortWrite,
+			cfg.PostgreSQL.HostWrite,
+			cfg.PostgreSQL.UserWrite,
+			cfg.PostgreSQL.PasswordWrite,
+			cfg.PostgreSQL.NameWrite,
+		)
+	} else {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortRead,
+			cfg.PostgreSQL.HostRead,
+			cfg.PostgreSQL.UserRead,
+			cfg.PostgreSQL.PasswordRead,
+			cfg.PostgreSQL.NameRead,
+		)
+	}
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	historyDB := historydb.NewHistoryDB(dbRead, dbRead, nil)
+
+	ethClient, err := ethclient.Dial(cfg.Web3.URL)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	client, err := eth.NewClient(ethClient, nil, nil, &eth.ClientConfig{
+		Rollup: eth.RollupConfig{
+			Address: cfg.SmartContracts.Rollup,
+		},
+	})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	decoder := dataavailability.NewDecoder(historyDB, client)
+	batchData, err := decoder.DecodeBatch(common.BatchNum(batchNum))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	batchDataJSON, err := json.MarshalIndent(batchData, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	fmt.Println(string(batchDataJSON))
+	return nil
+}
+
 func cmdGetAccountDetails(c *cli.Context) error {
 	ethereumNodeURL := c.String(flagEthNodeURL)
 	auctionContractAddressHex := c.String(flagAuctContractAddrHex)
@@ -401,7 +542,7 @@ func cmdDiscard(c *cli.Context) error {
 		cfg.Coordinator.L2DB.TTL.Duration,
 		nil,
 	)
-	if err := l2DB.Reorg(batchNum); err != nil {
+	if _, err := l2DB.Reorg(batchNum); err != nil {
 		return tracerr.Wrap(fmt.Errorf("l2DB.Reorg: %w", err))
 	}
 
@@ -413,6 +554,520 @@ func cmdDiscard(c *cli.Context) error {
 	return nil
 }
 
+func cmdVerifyState(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+
+	dbWrite, err := dbUtils.InitSQLDB(
+		cfg.PostgreSQL.PortWrite,
+		cfg.PostgreSQL.HostWrite,
+		cfg.PostgreSQL.UserWrite,
+		cfg.PostgreSQL.PasswordWrite,
+		cfg.PostgreSQL.NameWrite,
+	)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	var dbRead *sqlx.DB
+	if cfg.PostgreSQL.HostRead == "" {
+		dbRead = dbWrite
+	} else if cfg.PostgreSQL.HostRead == cfg.PostgreSQL.HostWrite {
+		return tracerr.Wrap(fmt.Errorf(
+			"PostgreSQL.HostRead and PostgreSQL.HostWrite must be different",
+		))
+	} else {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortRead,
+			cfg.PostgreSQL.HostRead,
+			cfg.PostgreSQL.UserRead,
+			cfg.PostgreSQL.PasswordRead,
+			cfg.PostgreSQL.NameRead,
+		)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+		}
+	}
+	historyDB := historydb.NewHistoryDB(dbRead, dbWrite, nil)
+
+	stateDB, err := statedb.NewStateDB(statedb.Config{
+		Path:      cfg.StateDB.Path,
+		Keep:      cfg.StateDB.Keep,
+		KeepEvery: cfg.StateDB.KeepEvery,
+		Type:      statedb.TypeSynchronizer,
+		NLevels:   statedb.MaxNLevels,
+	})
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("statedb.NewStateDB: %w", err))
+	}
+	defer stateDB.Close()
+	batchNum, err := stateDB.LastGetCurrentBatch()
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("stateDB.LastGetCurrentBatch: %w", err))
+	}
+
+	var expectedRoot *big.Int
+	batch, err := historyDB.GetBatch(batchNum)
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		log.Warnf("Batch %v is not in the HistoryDB, the recomputed root "+
+			"will not be compared against the stored state root", batchNum)
+	} else if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetBatch: %w", err))
+	} else {
+		expectedRoot = batch.StateRoot
+	}
+
+	log.Infof("Checking consistency of the StateDB at batch %v...", batchNum)
+	report, err := stateDB.CheckConsistency(expectedRoot)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("stateDB.CheckConsistency: %w", err))
+	}
+	log.Infow("StateDB consistency report",
+		"batch", report.BatchNum,
+		"accounts", report.NAccounts,
+		"root", report.Root,
+		"computedRoot", report.ComputedRoot,
+		"expectedRoot", report.ExpectedRoot,
+	)
+	for _, div := range report.Divergences {
+		log.Warnw("Divergent account leaf",
+			"idx", div.Idx,
+			"treeLeaf", div.TreeLeaf,
+			"computedLeaf", div.ComputedLeaf,
+		)
+	}
+	if !report.Ok() {
+		return tracerr.Wrap(fmt.Errorf(
+			"the StateDB at batch %v is not consistent", report.BatchNum))
+	}
+	log.Info("The StateDB is consistent")
+	return nil
+}
+
+func cmdStateDBSnapshot(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+	dest := c.String(flagDir)
+
+	db, err := kvdb.NewKVDB(kvdb.Config{
+		Path:        cfg.StateDB.Path,
+		NoGapsCheck: true,
+		NoLast:      true,
+	})
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("kvdb.NewKVDB: %w", err))
+	}
+	defer db.Close()
+	batchNum, err := db.GetCurrentBatch()
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("db.GetCurrentBatch: %w", err))
+	}
+	if batchNum == 0 {
+		return tracerr.Wrap(fmt.Errorf("the StateDB has no batches, nothing to snapshot"))
+	}
+	log.Infof("Snapshotting the StateDB at batch %v...", batchNum)
+	if err := db.MakeCheckpointFromTo(batchNum, dest); err != nil {
+		return tracerr.Wrap(fmt.Errorf("db.MakeCheckpointFromTo: %w", err))
+	}
+	log.Infow("StateDB snapshot created", "batch", batchNum, "dir", dest)
+	return nil
+}
+
+func cmdStateDBRestore(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+	source := c.String(flagDir)
+	yes := c.Bool(flagYes)
+	if !yes {
+		fmt.Print("*WARNING* Are you sure you want to overwrite " +
+			"the StateDBs with the snapshot? [y/N]: ")
+		var input string
+		if _, err := fmt.Scanln(&input); err != nil {
+			return tracerr.Wrap(err)
+		}
+		input = strings.ToLower(input)
+		if !(input == "y" || input == "yes") {
+			return nil
+		}
+	}
+
+	// Read the batch number stored in the snapshot
+	sto, err := pebble.NewPebbleStorage(source, false)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("pebble.NewPebbleStorage: %w", err))
+	}
+	cbBytes, err := sto.Get(kvdb.KeyCurrentBatch)
+	if err != nil {
+		sto.Close()
+		return tracerr.Wrap(fmt.Errorf("the snapshot has no current batch: %w", err))
+	}
+	batchNum, err := common.BatchNumFromBytes(cbBytes)
+	sto.Close()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	log.Infof("Restoring the StateDB snapshot at batch %v...", batchNum)
+	checkpoint := path.Join(cfg.StateDB.Path,
+		fmt.Sprintf("%s%d", kvdb.PathBatchNum, batchNum))
+	if err := kvdb.PebbleMakeCheckpoint(source, checkpoint); err != nil {
+		return tracerr.Wrap(fmt.Errorf("kvdb.PebbleMakeCheckpoint: %w", err))
+	}
+	if err := resetStateDBs(_cfg, batchNum); err != nil {
+		return tracerr.Wrap(fmt.Errorf("resetStateDBs: %w", err))
+	}
+	log.Infow("StateDB snapshot restored", "batch", batchNum)
+	return nil
+}
+
+func cmdPoolPurge(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+	yes := c.Bool(flagYes)
+	if !yes {
+		fmt.Print("*WARNING* Are you sure you want to purge " +
+			"outdated txs from the pool? [y/N]: ")
+		var input string
+		if _, err := fmt.Scanln(&input); err != nil {
+			return tracerr.Wrap(err)
+		}
+		input = strings.ToLower(input)
+		if !(input == "y" || input == "yes") {
+			return nil
+		}
+	}
+
+	dbWrite, err := dbUtils.InitSQLDB(
+		cfg.PostgreSQL.PortWrite,
+		cfg.PostgreSQL.HostWrite,
+		cfg.PostgreSQL.UserWrite,
+		cfg.PostgreSQL.PasswordWrite,
+		cfg.PostgreSQL.NameWrite,
+	)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	var dbRead *sqlx.DB
+	if cfg.PostgreSQL.HostRead == "" {
+		dbRead = dbWrite
+	} else if cfg.PostgreSQL.HostRead == cfg.PostgreSQL.HostWrite {
+		return tracerr.Wrap(fmt.Errorf(
+			"PostgreSQL.HostRead and PostgreSQL.HostWrite must be different",
+		))
+	} else {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortRead,
+			cfg.PostgreSQL.HostRead,
+			cfg.PostgreSQL.UserRead,
+			cfg.PostgreSQL.PasswordRead,
+			cfg.PostgreSQL.NameRead,
+		)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+		}
+	}
+	historyDB := historydb.NewHistoryDB(dbRead, dbWrite, nil)
+	batchNum, err := historyDB.GetLastBatchNum()
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		log.Info("The HistoryDB has no batches, nothing to purge")
+		return nil
+	} else if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastBatchNum: %w", err))
+	}
+	l2DB := l2db.NewL2DB(
+		dbRead, dbWrite,
+		cfg.Coordinator.L2DB.SafetyPeriod,
+		cfg.Coordinator.L2DB.MaxTxs,
+		cfg.Coordinator.L2DB.MinFeeUSD,
+		cfg.Coordinator.L2DB.MaxFeeUSD,
+		cfg.Coordinator.L2DB.TTL.Duration,
+		nil,
+	)
+	log.Infof("Purging the pool at batch %v...", batchNum)
+	if err := l2DB.Purge(batchNum); err != nil {
+		return tracerr.Wrap(fmt.Errorf("l2DB.Purge: %w", err))
+	}
+	log.Info("Pool purged successfully")
+	return nil
+}
+
+func cmdSyncStatus(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	cfg := _cfg.node
+
+	var dbRead *sqlx.DB
+	if cfg.PostgreSQL.HostRead == "" {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortWrite,
+			cfg.PostgreSQL.HostWrite,
+			cfg.PostgreSQL.UserWrite,
+			cfg.PostgreSQL.PasswordWrite,
+			cfg.PostgreSQL.NameWrite,
+		)
+	} else {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortRead,
+			cfg.PostgreSQL.HostRead,
+			cfg.PostgreSQL.UserRead,
+			cfg.PostgreSQL.PasswordRead,
+			cfg.PostgreSQL.NameRead,
+		)
+	}
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	historyDB := historydb.NewHistoryDB(dbRead, dbRead, nil)
+
+	lastBlock, err := historyDB.GetLastBlock()
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		fmt.Println("The node has not synchronized any block yet")
+		return nil
+	} else if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastBlock: %w", err))
+	}
+	lastBatchNum := common.BatchNum(0)
+	batchNum, err := historyDB.GetLastBatchNum()
+	if err != nil && tracerr.Unwrap(err) != sql.ErrNoRows {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastBatchNum: %w", err))
+	} else if err == nil {
+		lastBatchNum = batchNum
+	}
+
+	ethClient, err := ethclient.Dial(cfg.Web3.URL)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	header, err := ethClient.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	ethBlockNum := header.Number.Int64()
+
+	fmt.Printf("LastSyncedBlock = %v\n", lastBlock.Num)
+	fmt.Printf("LastEthereumBlock = %v\n", ethBlockNum)
+	fmt.Printf("BlocksBehind = %v\n", ethBlockNum-lastBlock.Num)
+	fmt.Printf("LastBatch = %v\n", lastBatchNum)
+	return nil
+}
+
+// cmdForgeOnce runs the tx selection that the coordinator would run for the
+// next batch and prints the resulting batch content as JSON, without sending
+// anything to Ethereum.  The TxSelector StateDB is reset from the
+// synchronizer StateDB before and after the selection so that the dry run
+// leaves no trace
+func cmdForgeOnce(c *cli.Context) error {
+	_cfg, err := parseCli(c)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("error parsing flags and config: %w", err))
+	}
+	if !_cfg.mode.Forging() {
+		return tracerr.Wrap(fmt.Errorf("forge once must use mode coordinator or full"))
+	}
+	cfg := _cfg.node
+
+	var dbRead *sqlx.DB
+	if cfg.PostgreSQL.HostRead == "" {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortWrite,
+			cfg.PostgreSQL.HostWrite,
+			cfg.PostgreSQL.UserWrite,
+			cfg.PostgreSQL.PasswordWrite,
+			cfg.PostgreSQL.NameWrite,
+		)
+	} else {
+		dbRead, err = dbUtils.InitSQLDB(
+			cfg.PostgreSQL.PortRead,
+			cfg.PostgreSQL.HostRead,
+			cfg.PostgreSQL.UserRead,
+			cfg.PostgreSQL.PasswordRead,
+			cfg.PostgreSQL.NameRead,
+		)
+	}
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	historyDB := historydb.NewHistoryDB(dbRead, dbRead, nil)
+	l2DB := l2db.NewL2DB(
+		dbRead, dbRead,
+		cfg.Coordinator.L2DB.SafetyPeriod,
+		cfg.Coordinator.L2DB.MaxTxs,
+		cfg.Coordinator.L2DB.MinFeeUSD,
+		cfg.Coordinator.L2DB.MaxFeeUSD,
+		cfg.Coordinator.L2DB.TTL.Duration,
+		nil,
+	)
+
+	ethClient, err := ethclient.Dial(cfg.Web3.URL)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	chainID, err := ethClient.ChainID(context.Background())
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	const maxUint16 uint64 = 0xffff
+	if !chainID.IsUint64() || chainID.Uint64() > maxUint16 {
+		return tracerr.Wrap(fmt.Errorf("chainID overflows uint16"))
+	}
+	chainIDU16 := uint16(chainID.Uint64())
+
+	stateDB, err := statedb.NewStateDB(statedb.Config{
+		Path:      cfg.StateDB.Path,
+		Keep:      cfg.StateDB.Keep,
+		KeepEvery: cfg.StateDB.KeepEvery,
+		Type:      statedb.TypeSynchronizer,
+		NLevels:   statedb.MaxNLevels,
+	})
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("statedb.NewStateDB: %w", err))
+	}
+	defer stateDB.Close()
+	batchNum, err := stateDB.LastGetCurrentBatch()
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("stateDB.LastGetCurrentBatch: %w", err))
+	}
+
+	scryptN := ethKeystore.StandardScryptN
+	scryptP := ethKeystore.StandardScryptP
+	if cfg.Coordinator.Debug.LightScrypt {
+		scryptN = ethKeystore.LightScryptN
+		scryptP = ethKeystore.LightScryptP
+	}
+	keyStore := ethKeystore.NewKeyStore(cfg.Coordinator.EthClient.Keystore.Path,
+		scryptN, scryptP)
+	feeAccount := accounts.Account{
+		Address: cfg.Coordinator.FeeAccount.Address,
+	}
+	if err := keyStore.Unlock(feeAccount,
+		cfg.Coordinator.EthClient.Keystore.Password); err != nil {
+		return tracerr.Wrap(err)
+	}
+	auth := &common.AccountCreationAuth{
+		EthAddr: cfg.Coordinator.FeeAccount.Address,
+		BJJ:     cfg.Coordinator.FeeAccount.BJJ,
+	}
+	if err := auth.Sign(func(msg []byte) ([]byte, error) {
+		return keyStore.SignHash(feeAccount, msg)
+	}, chainIDU16, cfg.SmartContracts.Rollup); err != nil {
+		return tracerr.Wrap(err)
+	}
+	coordAccount := &txselector.CoordAccount{
+		Addr:                cfg.Coordinator.FeeAccount.Address,
+		BJJ:                 cfg.Coordinator.FeeAccount.BJJ,
+		AccountCreationAuth: auth.Signature,
+	}
+	txSelector, err := txselector.NewTxSelector(coordAccount,
+		cfg.Coordinator.TxSelector.Path, stateDB, l2DB)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if cfg.Coordinator.TxSelector.FeeIdxStrategy != "" {
+		tokenWhitelist := make([]common.TokenID,
+			len(cfg.Coordinator.TxSelector.FeeIdxTokenWhitelist))
+		for i, tokenID := range cfg.Coordinator.TxSelector.FeeIdxTokenWhitelist {
+			tokenWhitelist[i] = common.TokenID(tokenID)
+		}
+		if err := txSelector.SetFeeIdxConfig(txselector.FeeIdxConfig{
+			Strategy:       txselector.FeeIdxStrategy(cfg.Coordinator.TxSelector.FeeIdxStrategy),
+			TokenWhitelist: tokenWhitelist,
+		}); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	if err := txSelector.Reset(batchNum, true); err != nil {
+		return tracerr.Wrap(fmt.Errorf("txSelector.Reset: %w", err))
+	}
+
+	txProcessorCfg := txprocessor.Config{
+		NLevels:  uint32(cfg.Coordinator.Circuit.NLevels),
+		MaxTx:    uint32(cfg.Coordinator.Circuit.MaxTx),
+		ChainID:  chainIDU16,
+		MaxFeeTx: common.RollupConstMaxFeeIdxCoordinator,
+		MaxL1Tx:  common.RollupConstMaxL1Tx,
+	}
+
+	lastForgeL1TxsNum := int64(-1)
+	if lastL1TxsNum, err := historyDB.GetLastL1TxsNum(); err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastL1TxsNum: %w", err))
+	} else if lastL1TxsNum != nil {
+		lastForgeL1TxsNum = *lastL1TxsNum
+	}
+
+	log.Infof("Running the tx selection for batch %v...", batchNum+1)
+	var coordIdxs []common.Idx
+	var l1UserTxs, l1CoordTxs []common.L1Tx
+	var poolL2Txs, discardedL2Txs []common.PoolL2Tx
+	l1Batch := false
+	_l1UserTxs, err := historyDB.GetUnforgedL1UserTxs(lastForgeL1TxsNum + 1)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetUnforgedL1UserTxs: %w", err))
+	}
+	if len(_l1UserTxs) > 0 {
+		l1Batch = true
+		l1UserFutureTxs, err := historyDB.GetUnforgedL1UserFutureTxs(lastForgeL1TxsNum + 1)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("historyDB.GetUnforgedL1UserFutureTxs: %w", err))
+		}
+		coordIdxs, _, l1UserTxs, l1CoordTxs, poolL2Txs, discardedL2Txs, err =
+			txSelector.GetL1L2TxSelection(txProcessorCfg, _l1UserTxs, l1UserFutureTxs)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("txSelector.GetL1L2TxSelection: %w", err))
+		}
+	} else {
+		l1UserFutureTxs, err := historyDB.GetUnforgedL1UserFutureTxs(lastForgeL1TxsNum)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("historyDB.GetUnforgedL1UserFutureTxs: %w", err))
+		}
+		coordIdxs, _, l1CoordTxs, poolL2Txs, discardedL2Txs, err =
+			txSelector.GetL2TxSelection(txProcessorCfg, l1UserFutureTxs)
+		if err != nil {
+			return tracerr.Wrap(fmt.Errorf("txSelector.GetL2TxSelection: %w", err))
+		}
+	}
+	// Leave the TxSelector StateDB as it was before the selection
+	if err := txSelector.Reset(batchNum, true); err != nil {
+		return tracerr.Wrap(fmt.Errorf("txSelector.Reset: %w", err))
+	}
+
+	report := struct {
+		BatchNum         common.BatchNum   `json:"batchNum"`
+		L1Batch          bool              `json:"l1Batch"`
+		CoordIdxs        []common.Idx      `json:"coordIdxs"`
+		L1UserTxs        []common.L1Tx     `json:"l1UserTxs"`
+		L1CoordinatorTxs []common.L1Tx     `json:"l1CoordinatorTxs"`
+		L2Txs            []common.PoolL2Tx `json:"l2Txs"`
+		DiscardedL2Txs   []common.PoolL2Tx `json:"discardedL2Txs"`
+	}{
+		BatchNum:         batchNum + 1,
+		L1Batch:          l1Batch,
+		CoordIdxs:        coordIdxs,
+		L1UserTxs:        l1UserTxs,
+		L1CoordinatorTxs: l1CoordTxs,
+		L2Txs:            poolL2Txs,
+		DiscardedL2Txs:   discardedL2Txs,
+	}
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	fmt.Println(string(reportJSON))
+	return nil
+}
+
 // Config is the configuration of the hermez node execution
 type Config struct {
 	mode node.Mode
@@ -448,6 +1103,12 @@ func getConfig(c *cli.Context) (*Config, error) {
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
+	case modeFull:
+		cfg.mode = node.ModeFull
+		cfg.node, err = config.LoadNode(nodeCfgPath, true)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
 	default:
 		return nil, tracerr.Wrap(fmt.Errorf("invalid mode \"%v\"", mode))
 	}
@@ -490,6 +1151,12 @@ func getConfigAPIServer(c *cli.Context) (*ConfigAPIServer, error) {
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
+	case modeFull:
+		cfg.mode = node.ModeFull
+		cfg.server, err = config.LoadAPIServer(nodeCfgPath, true)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
 	default:
 		return nil, tracerr.Wrap(fmt.Errorf("invalid mode \"%v\"", mode))
 	}
@@ -504,7 +1171,7 @@ func main() {
 	flags := []cli.Flag{
 		&cli.StringFlag{
 			Name:     flagMode,
-			Usage:    fmt.Sprintf("Set node `MODE` (can be \"%v\" or \"%v\")", modeSync, modeCoord),
+			Usage:    fmt.Sprintf("Set node `MODE` (can be \"%v\", \"%v\" or \"%v\")", modeSync, modeCoord, modeFull),
 			Required: true,
 		},
 		&cli.StringFlag{
@@ -577,6 +1244,28 @@ func main() {
 			Action:  cmdRun,
 			Flags:   flags,
 		},
+		{
+			Name:    "runmulti",
+			Aliases: []string{},
+			Usage:   "Run one hermez-node per network in a single process",
+			Action:  cmdRunMulti,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     flagMode,
+					Usage:    fmt.Sprintf("Set node `MODE` (can be \"%v\", \"%v\" or \"%v\")", modeSync, modeCoord, modeFull),
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     flagNetworks,
+					Usage:    "Network `SPECS` formatted as \"name1=cfgPath1,name2=cfgPath2\"",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  flagAPIAddress,
+					Usage: "`ADDRESS` of the shared API server that exposes each network under \"/<name>\"",
+				},
+			},
+		},
 		{
 			Name:    "serveapi",
 			Aliases: []string{},
@@ -596,6 +1285,138 @@ func main() {
 					Required: false,
 				}),
 		},
+		{
+			Name:    "verifystate",
+			Aliases: []string{},
+			Usage: "Verify the consistency of the synchronizer StateDB " +
+				"against the state root stored in the HistoryDB",
+			Action: cmdVerifyState,
+			Flags:  flags,
+		},
+		{
+			Name:    "batchda",
+			Aliases: []string{},
+			Usage: "Decode and print the on-chain data availability " +
+				"of a forged batch",
+			Action: cmdBatchDA,
+			Flags: append(flags,
+				&cli.Int64Flag{
+					Name:     flagBatch,
+					Usage:    "batch number to decode",
+					Required: true,
+				}),
+		},
+		{
+			Name:  "db",
+			Usage: "Operate on the SQL DB (HistoryDB and L2DB)",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "migrate",
+					Usage:  "Run the pending SQL migrations",
+					Action: cmdSQLMigrationUp,
+					Flags:  flags,
+				},
+				{
+					Name: "wipe",
+					Usage: "Wipe the SQL DB (HistoryDB and L2DB) and the StateDBs, " +
+						"leaving the DB in a clean state",
+					Action: cmdWipeDBs,
+					Flags: append(flags,
+						&cli.BoolFlag{
+							Name:     flagYes,
+							Usage:    "automatic yes to the prompt",
+							Required: false,
+						}),
+				},
+			},
+		},
+		{
+			Name:  "statedb",
+			Usage: "Operate on the synchronizer StateDB",
+			Subcommands: []*cli.Command{
+				{
+					Name: "verify",
+					Usage: "Verify the consistency of the synchronizer StateDB " +
+						"against the state root stored in the HistoryDB",
+					Action: cmdVerifyState,
+					Flags:  flags,
+				},
+				{
+					Name: "snapshot",
+					Usage: "Copy the last checkpoint of the synchronizer StateDB " +
+						"to a directory",
+					Action: cmdStateDBSnapshot,
+					Flags: append(flags,
+						&cli.StringFlag{
+							Name:     flagDir,
+							Usage:    "destination `DIR` of the snapshot",
+							Required: true,
+						}),
+				},
+				{
+					Name: "restore",
+					Usage: "Restore the synchronizer StateDB from a snapshot, " +
+						"resetting it to the snapshot batch",
+					Action: cmdStateDBRestore,
+					Flags: append(flags,
+						&cli.StringFlag{
+							Name:     flagDir,
+							Usage:    "source `DIR` of the snapshot",
+							Required: true,
+						},
+						&cli.BoolFlag{
+							Name:     flagYes,
+							Usage:    "automatic yes to the prompt",
+							Required: false,
+						}),
+				},
+			},
+		},
+		{
+			Name:  "pool",
+			Usage: "Operate on the L2DB tx pool",
+			Subcommands: []*cli.Command{
+				{
+					Name: "purge",
+					Usage: "Delete txs that have been forged or marked as invalid " +
+						"for longer than the safety period, and pending txs " +
+						"that have expired",
+					Action: cmdPoolPurge,
+					Flags: append(flags,
+						&cli.BoolFlag{
+							Name:     flagYes,
+							Usage:    "automatic yes to the prompt",
+							Required: false,
+						}),
+				},
+			},
+		},
+		{
+			Name:  "sync",
+			Usage: "Query the synchronizer state",
+			Subcommands: []*cli.Command{
+				{
+					Name: "status",
+					Usage: "Show the last synchronized block and batch against " +
+						"the current Ethereum block",
+					Action: cmdSyncStatus,
+					Flags:  flags,
+				},
+			},
+		},
+		{
+			Name:  "forge",
+			Usage: "Operate the coordinator forging",
+			Subcommands: []*cli.Command{
+				{
+					Name: "once",
+					Usage: "Run the tx selection for the next batch and print " +
+						"its content without forging it",
+					Action: cmdForgeOnce,
+					Flags:  flags,
+				},
+			},
+		},
 		{
 			Name:    "accountInfo",
 			Aliases: []string{},
@@ -0,0 +1,231 @@
+/*
+Command loadgen populates a HistoryDB/L2DB with large volumes of generated
+data (blocks, tokens, batches, accounts and txs) following a realistic
+traffic profile, and can drive the POST /transactions-pool endpoint of a
+running api at a target TPS.  It is meant to produce reproducible datasets
+for performance work: the same seed always generates the same accounts, keys
+and txs, so a node populated with `loadgen populate` can be driven with
+`loadgen drive` using the same seed.
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	dbUtils "github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/arnaubennassar/hermez-node/test"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagPGHost     = "pghost"
+	flagPGPort     = "pgport"
+	flagPGUser     = "pguser"
+	flagPGPassword = "pgpassword"
+	flagPGName     = "pgname"
+	flagSeed       = "seed"
+	flagChainID    = "chainid"
+	flagBlocks     = "blocks"
+	flagTokens     = "tokens"
+	flagBatches    = "batches"
+	flagAccounts   = "accounts"
+	flagTxs        = "txs"
+	flagPoolTxs    = "pooltxs"
+	flagChunk      = "chunk"
+	flagURL        = "url"
+	flagTPS        = "tps"
+	flagDuration   = "duration"
+)
+
+func newLoadGen(c *cli.Context) (*test.LoadGen, []common.Block,
+	[]common.Token, []common.Batch) {
+	blocks := test.GenBlocks(1, int64(c.Int(flagBlocks))+1)
+	tokens, ethToken := test.GenTokens(c.Int(flagTokens), blocks)
+	batches := test.GenBatches(c.Int(flagBatches), blocks)
+	lg := test.NewLoadGen(c.Int64(flagSeed),
+		append([]common.Token{ethToken}, tokens...))
+	return lg, blocks, tokens, batches
+}
+
+func cmdPopulate(c *cli.Context) error {
+	db, err := dbUtils.InitSQLDB(
+		c.Int(flagPGPort),
+		c.String(flagPGHost),
+		c.String(flagPGUser),
+		c.String(flagPGPassword),
+		c.String(flagPGName),
+	)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("dbUtils.InitSQLDB: %w", err))
+	}
+	hdb := historydb.NewHistoryDB(db, db, nil)
+	l2DB := l2db.NewL2DB(db, db, 10, 0, 0.0, 0.0, 24*time.Hour, nil) //nolint:gomnd
+
+	lg, blocks, tokens, batches := newLoadGen(c)
+	chunk := c.Int(flagChunk)
+
+	log.Infow("populating", "blocks", len(blocks), "tokens", len(tokens),
+		"batches", len(batches), "accounts", c.Int(flagAccounts),
+		"txs", c.Int(flagTxs), "poolTxs", c.Int(flagPoolTxs))
+	if err := hdb.AddBlocks(blocks); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := hdb.AddTokens(tokens); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := hdb.AddBatches(batches); err != nil {
+		return tracerr.Wrap(err)
+	}
+	for remaining := c.Int(flagAccounts); remaining > 0; remaining -= chunk {
+		n := chunk
+		if remaining < n {
+			n = remaining
+		}
+		if err := hdb.AddAccounts(lg.GenLoadAccounts(n, batches)); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	inserted := 0
+	for remaining := c.Int(flagTxs); remaining > 0; remaining -= chunk {
+		n := chunk
+		if remaining < n {
+			n = remaining
+		}
+		if err := hdb.AddL2Txs(lg.GenLoadL2Txs(n, blocks, batches)); err != nil {
+			return tracerr.Wrap(err)
+		}
+		inserted += n
+		log.Infow("inserted L2 txs", "total", inserted)
+	}
+	poolTxs, err := lg.GenLoadPoolTxs(c.Int(flagPoolTxs), uint16(c.Uint(flagChainID)))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, tx := range poolTxs {
+		if err := l2DB.AddTxTest(tx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	log.Info("populate done")
+	return nil
+}
+
+// poolTxBody builds the POST /transactions-pool JSON body of a tx
+func poolTxBody(tx *common.PoolL2Tx) []byte {
+	return []byte(fmt.Sprintf(`{
+		"id": "%s",
+		"type": "%s",
+		"tokenId": %d,
+		"fromAccountIndex": "hez:TOK:%d",
+		"toAccountIndex": "hez:TOK:%d",
+		"amount": "%s",
+		"fee": %d,
+		"nonce": %d,
+		"signature": "%s"
+	}`, tx.TxID, tx.Type, tx.TokenID, tx.FromIdx, tx.ToIdx,
+		tx.Amount.String(), tx.Fee, tx.Nonce, tx.Signature))
+}
+
+func cmdDrive(c *cli.Context) error {
+	lg, _, _, batches := newLoadGen(c)
+	// Regenerate the accounts (and keys) that `populate` inserted with the
+	// same seed, so that the signatures pass the api verification
+	lg.GenLoadAccounts(c.Int(flagAccounts), batches)
+
+	tps := c.Int(flagTPS)
+	duration := c.Duration(flagDuration)
+	total := int(duration.Seconds() * float64(tps))
+	txs, err := lg.GenLoadPoolTxs(total, uint16(c.Uint(flagChainID)))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	url := c.String(flagURL) + "/v1/transactions-pool"
+	log.Infow("driving pool txs", "url", url, "tps", tps, "total", total)
+
+	client := &http.Client{Timeout: 10 * time.Second} //nolint:gomnd
+	ticker := time.NewTicker(time.Second / time.Duration(tps))
+	defer ticker.Stop()
+	ok, failed := 0, 0
+	for _, tx := range txs {
+		<-ticker.C
+		resp, err := client.Post(url, "application/json",
+			bytes.NewReader(poolTxBody(tx)))
+		if err != nil {
+			failed++
+			log.Warnw("POST /transactions-pool", "err", err)
+			continue
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			failed++
+			body, _ := ioutil.ReadAll(resp.Body)
+			log.Warnw("POST /transactions-pool", "status", resp.StatusCode,
+				"response", string(body))
+		} else {
+			ok++
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+		}
+		if err := resp.Body.Close(); err != nil {
+			log.Warnw("resp.Body.Close", "err", err)
+		}
+	}
+	log.Infow("drive done", "ok", ok, "failed", failed)
+	return nil
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "loadgen"
+	app.Usage = "generate reproducible load test datasets for hermez-node"
+	pgFlags := []cli.Flag{
+		&cli.StringFlag{Name: flagPGHost, Usage: "PostgreSQL host", Value: "localhost"},
+		&cli.IntFlag{Name: flagPGPort, Usage: "PostgreSQL port", Value: 5432}, //nolint:gomnd
+		&cli.StringFlag{Name: flagPGUser, Usage: "PostgreSQL user", Value: "hermez"},
+		&cli.StringFlag{Name: flagPGPassword, Usage: "PostgreSQL password", Required: true},
+		&cli.StringFlag{Name: flagPGName, Usage: "PostgreSQL database name", Value: "hermez"},
+	}
+	genFlags := []cli.Flag{
+		&cli.Int64Flag{Name: flagSeed, Usage: "seed of the generated dataset", Value: 0},
+		&cli.UintFlag{Name: flagChainID, Usage: "chainID used to sign pool txs", Value: 5}, //nolint:gomnd
+		&cli.IntFlag{Name: flagBlocks, Usage: "number of blocks", Value: 1000},             //nolint:gomnd
+		&cli.IntFlag{Name: flagTokens, Usage: "number of tokens", Value: 20},               //nolint:gomnd
+		&cli.IntFlag{Name: flagBatches, Usage: "number of batches", Value: 100},            //nolint:gomnd
+		&cli.IntFlag{Name: flagAccounts, Usage: "number of accounts", Value: 1000},         //nolint:gomnd
+	}
+	app.Commands = []*cli.Command{
+		{
+			Name:   "populate",
+			Usage:  "fill a HistoryDB/L2DB with a generated dataset",
+			Action: cmdPopulate,
+			Flags: append(append(pgFlags, genFlags...),
+				&cli.IntFlag{Name: flagTxs, Usage: "number of forged L2 txs", Value: 100000},   //nolint:gomnd
+				&cli.IntFlag{Name: flagPoolTxs, Usage: "number of pending pool txs", Value: 0}, //nolint:gomnd
+				&cli.IntFlag{Name: flagChunk, Usage: "insertion chunk size", Value: 10000},     //nolint:gomnd
+			),
+		},
+		{
+			Name:   "drive",
+			Usage:  "POST generated txs to /transactions-pool at a target TPS",
+			Action: cmdDrive,
+			Flags: append(genFlags,
+				&cli.StringFlag{Name: flagURL, Usage: "base URL of the api", Value: "http://localhost:8086"},
+				&cli.IntFlag{Name: flagTPS, Usage: "target transactions per second", Value: 10}, //nolint:gomnd
+				&cli.DurationFlag{Name: flagDuration, Usage: "how long to send txs", Value: time.Minute},
+			),
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Printf("\nError: %v\n", tracerr.Sprint(err))
+		os.Exit(1)
+	}
+}
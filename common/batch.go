@@ -29,6 +29,11 @@ type Batch struct {
 	ForgeL1TxsNum *int64   `meddler:"forge_l1_txs_num"`
 	SlotNum       int64    `meddler:"slot_num"` // Slot in which the batch is forged
 	TotalFeesUSD  *float64 `meddler:"total_fees_usd"`
+	// GasUsed is the gas used by the L1 forge transaction, filled by the
+	// synchronizer from the transaction receipt
+	GasUsed *int64 `meddler:"gas_used"`
+	// GasPrice is the gas price (in Wei) paid by the L1 forge transaction
+	GasPrice *big.Int `meddler:"gas_price,bigintnull"`
 }
 
 // NewEmptyBatch creates a new empty batch
@@ -46,6 +51,8 @@ func NewEmptyBatch() *Batch {
 		ForgeL1TxsNum:      nil,
 		SlotNum:            0,
 		TotalFeesUSD:       nil,
+		GasUsed:            nil,
+		GasPrice:           nil,
 	}
 }
 
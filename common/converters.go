@@ -55,13 +55,52 @@ func StringToIdx(idxStr, name string) (*Idx, error) {
 	splitted := strings.Split(idxStr, ":")
 	const expectedLen = 3
 	if len(splitted) != expectedLen || splitted[0] != "hez" {
-		return nil, tracerr.Wrap(fmt.Errorf(
-			"invalid %s, must follow this: hez:<tokenSymbol>:index", name))
+		return nil, tracerr.Wrap(&ParseError{
+			Field: name, Value: idxStr,
+			Expected: "hez:<tokenSymbol>:index",
+		})
 	}
 	// TODO: check that the tokenSymbol match the token related to the account index
 	idxInt, err := strconv.Atoi(splitted[2])
+	if err != nil {
+		return nil, tracerr.Wrap(&ParseError{
+			Field: name, Value: idxStr,
+			Expected: "hez:<tokenSymbol>:index",
+		})
+	}
 	idx := Idx(idxInt)
-	return &idx, tracerr.Wrap(err)
+	return &idx, nil
+}
+
+// StringToTokenID converts a string into a token ID.  Note that, unlike the
+// rest of the parsing helpers of this package, the empty string is rejected,
+// as this helper is meant to parse the elements of comma separated lists
+func StringToTokenID(tokenIDStr, name string) (*TokenID, error) {
+	tokenIDUint, err := strconv.ParseUint(tokenIDStr, 10, 32)
+	if err != nil {
+		return nil, tracerr.Wrap(&ParseError{
+			Field: name, Value: tokenIDStr,
+			Expected: "an integer between 0 and 2^32 - 1",
+		})
+	}
+	tokenID := TokenID(tokenIDUint)
+	return &tokenID, nil
+}
+
+// StringToBatchNum converts a string into a batch number
+func StringToBatchNum(batchNumStr, name string) (*BatchNum, error) {
+	if batchNumStr == "" {
+		return nil, nil
+	}
+	batchNumInt, err := strconv.ParseInt(batchNumStr, 10, 64)
+	if err != nil || batchNumInt < 0 {
+		return nil, tracerr.Wrap(&ParseError{
+			Field: name, Value: batchNumStr,
+			Expected: "a positive integer",
+		})
+	}
+	batchNum := BatchNum(batchNumInt)
+	return &batchNum, nil
 }
 
 // HezStringToEthAddr converts hez ethereum address to ethereum address
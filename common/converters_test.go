@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/hermeznetwork/tracerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringToIdx(t *testing.T) {
+	idx, err := StringToIdx("hez:ETH:256", "accountIndex")
+	require.NoError(t, err)
+	assert.Equal(t, Idx(256), *idx)
+
+	idx, err = StringToIdx("", "accountIndex")
+	require.NoError(t, err)
+	assert.Nil(t, idx)
+
+	for _, invalid := range []string{"hez:ETH", "foo:ETH:256", "hez:ETH:abc"} {
+		_, err = StringToIdx(invalid, "accountIndex")
+		require.Error(t, err)
+		parseErr, ok := tracerr.Unwrap(err).(*ParseError)
+		require.True(t, ok)
+		assert.Equal(t, "accountIndex", parseErr.Field)
+		assert.Equal(t, invalid, parseErr.Value)
+	}
+}
+
+func TestStringToTokenID(t *testing.T) {
+	tokenID, err := StringToTokenID("33", "ids")
+	require.NoError(t, err)
+	assert.Equal(t, TokenID(33), *tokenID)
+
+	for _, invalid := range []string{"", "-1", "foo", "4294967296"} {
+		_, err = StringToTokenID(invalid, "ids")
+		require.Error(t, err)
+		parseErr, ok := tracerr.Unwrap(err).(*ParseError)
+		require.True(t, ok)
+		assert.Equal(t, "ids", parseErr.Field)
+		assert.Equal(t, invalid, parseErr.Value)
+	}
+}
+
+func TestStringToBatchNum(t *testing.T) {
+	batchNum, err := StringToBatchNum("4417", "batchNum")
+	require.NoError(t, err)
+	assert.Equal(t, BatchNum(4417), *batchNum)
+
+	batchNum, err = StringToBatchNum("", "batchNum")
+	require.NoError(t, err)
+	assert.Nil(t, batchNum)
+
+	for _, invalid := range []string{"-1", "foo"} {
+		_, err = StringToBatchNum(invalid, "batchNum")
+		require.Error(t, err)
+		parseErr, ok := tracerr.Unwrap(err).(*ParseError)
+		require.True(t, ok)
+		assert.Equal(t, "batchNum", parseErr.Field)
+		assert.Equal(t, invalid, parseErr.Value)
+	}
+}
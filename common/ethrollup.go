@@ -138,6 +138,52 @@ type BucketParams struct {
 	MaxWithdrawals  *big.Int
 }
 
+// WithdrawalsAt returns the withdrawable amount of the bucket at the given
+// block, applying the refills accumulated since BlockStamp with the same
+// arithmetic as the Rollup Smart Contract: every RateBlocks blocks the bucket
+// gains RateWithdrawals, capped at MaxWithdrawals
+func (b *BucketParams) WithdrawalsAt(blockNum int64) *big.Int {
+	withdrawals := new(big.Int)
+	if b.Withdrawals != nil {
+		withdrawals.Set(b.Withdrawals)
+	}
+	if b.BlockStamp == nil || b.RateBlocks == nil || b.RateBlocks.Sign() <= 0 {
+		return withdrawals
+	}
+	elapsed := new(big.Int).Sub(big.NewInt(blockNum), b.BlockStamp)
+	if elapsed.Sign() <= 0 {
+		return withdrawals
+	}
+	periods := elapsed.Div(elapsed, b.RateBlocks)
+	if b.RateWithdrawals != nil {
+		withdrawals.Add(withdrawals, periods.Mul(periods, b.RateWithdrawals))
+	}
+	if b.MaxWithdrawals != nil && withdrawals.Cmp(b.MaxWithdrawals) > 0 {
+		withdrawals.Set(b.MaxWithdrawals)
+	}
+	return withdrawals
+}
+
+// NextRefillBlock returns the block at which the bucket will next gain
+// withdrawable amount, or 0 when the bucket never refills (RateBlocks or
+// RateWithdrawals is 0) or is already at MaxWithdrawals at the given block
+func (b *BucketParams) NextRefillBlock(blockNum int64) int64 {
+	if b.BlockStamp == nil || b.RateBlocks == nil || b.RateBlocks.Sign() <= 0 ||
+		b.RateWithdrawals == nil || b.RateWithdrawals.Sign() <= 0 {
+		return 0
+	}
+	if b.MaxWithdrawals != nil && b.WithdrawalsAt(blockNum).Cmp(b.MaxWithdrawals) >= 0 {
+		return 0
+	}
+	elapsed := new(big.Int).Sub(big.NewInt(blockNum), b.BlockStamp)
+	periods := new(big.Int)
+	if elapsed.Sign() > 0 {
+		periods.Div(elapsed, b.RateBlocks)
+	}
+	nextRefill := new(big.Int).Mul(periods.Add(periods, big.NewInt(1)), b.RateBlocks)
+	return nextRefill.Add(nextRefill, b.BlockStamp).Int64()
+}
+
 // BucketUpdate are the bucket updates (tracking the withdrawals value changes)
 // in Rollup Smart Contract
 type BucketUpdate struct {
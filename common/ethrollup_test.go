@@ -0,0 +1,50 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketParamsWithdrawalsAt(t *testing.T) {
+	bucket := BucketParams{
+		CeilUSD:         big.NewInt(1000),
+		BlockStamp:      big.NewInt(100),
+		Withdrawals:     big.NewInt(3),
+		RateBlocks:      big.NewInt(10),
+		RateWithdrawals: big.NewInt(2),
+		MaxWithdrawals:  big.NewInt(8),
+	}
+	// Before the first refill period the withdrawals are unchanged
+	assert.Equal(t, big.NewInt(3), bucket.WithdrawalsAt(100))
+	assert.Equal(t, big.NewInt(3), bucket.WithdrawalsAt(109))
+	// One refill period adds RateWithdrawals
+	assert.Equal(t, big.NewInt(5), bucket.WithdrawalsAt(110))
+	// The refills are capped at MaxWithdrawals
+	assert.Equal(t, big.NewInt(7), bucket.WithdrawalsAt(120))
+	assert.Equal(t, big.NewInt(8), bucket.WithdrawalsAt(130))
+	assert.Equal(t, big.NewInt(8), bucket.WithdrawalsAt(1000))
+	// A bucket that never refills keeps its withdrawals
+	bucket.RateBlocks = big.NewInt(0)
+	assert.Equal(t, big.NewInt(3), bucket.WithdrawalsAt(1000))
+}
+
+func TestBucketParamsNextRefillBlock(t *testing.T) {
+	bucket := BucketParams{
+		CeilUSD:         big.NewInt(1000),
+		BlockStamp:      big.NewInt(100),
+		Withdrawals:     big.NewInt(0),
+		RateBlocks:      big.NewInt(10),
+		RateWithdrawals: big.NewInt(2),
+		MaxWithdrawals:  big.NewInt(8),
+	}
+	assert.Equal(t, int64(110), bucket.NextRefillBlock(100))
+	assert.Equal(t, int64(110), bucket.NextRefillBlock(109))
+	assert.Equal(t, int64(120), bucket.NextRefillBlock(110))
+	// Once the bucket is full there is no next refill
+	assert.Equal(t, int64(0), bucket.NextRefillBlock(140))
+	// A bucket that never refills has no next refill either
+	bucket.RateBlocks = big.NewInt(0)
+	assert.Equal(t, int64(0), bucket.NextRefillBlock(100))
+}
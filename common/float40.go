@@ -1,7 +1,12 @@
 // Package common float40.go provides methods to work with Hermez custom half
 // float precision, 40 bits, codification internally called Float40 has been
 // adopted to encode large integers. This is done in order to save bits when L2
-// transactions are published.
+// transactions are published.  Float40 replaced the older 16 bit Float16
+// encoding (which truncated large amounts) and is used end-to-end: in the
+// data availability and calldata serializations (see l1tx.go, l2tx.go and
+// the eth package), and in the txprocessor when building ZKInputs.  The
+// serialization format of a batch is selected through the codec versioning
+// of txcodec.go, where Float40 amounts correspond to TxCodecV1.
 //nolint:gomnd
 package common
 
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Fork describes a protocol upgrade of the rollup smart contract activated
+// at a given Ethereum block.  Fields left at their zero value keep whatever
+// was in force before the fork, so an entry only needs to set the behaviors
+// that actually change.
+type Fork struct {
+	// BlockNum is the Ethereum block at which the fork activates
+	BlockNum int64
+	// TxCodec is the tx serialization version used from BlockNum on.  Set
+	// to 0 to keep the version of the previous fork (TxCodecV1 if there
+	// is none)
+	TxCodec TxCodecVersion
+}
+
+// ForkConfig is the schedule of protocol upgrades of the chain being synced,
+// sorted by ascending BlockNum.  A nil or empty ForkConfig means the current
+// protocol has been in force since the contract deployment, so a node synced
+// against a never-upgraded contract needs no configuration at all.
+type ForkConfig []Fork
+
+// NewForkConfig validates a fork schedule and returns it as a ForkConfig.
+// The entries must have strictly ascending BlockNum, and every non-zero
+// TxCodec must be a known TxCodecVersion.
+func NewForkConfig(forks []Fork) (ForkConfig, error) {
+	lastBlockNum := int64(-1)
+	for i, fork := range forks {
+		if fork.BlockNum <= lastBlockNum {
+			return nil, tracerr.Wrap(
+				fmt.Errorf("fork %d: BlockNum (%d) is not greater than the previous "+
+					"fork BlockNum (%d)", i, fork.BlockNum, lastBlockNum))
+		}
+		lastBlockNum = fork.BlockNum
+		if fork.TxCodec != 0 {
+			if _, err := TxCodecByVersion(fork.TxCodec); err != nil {
+				return nil, tracerr.Wrap(fmt.Errorf("fork %d: %w", i, err))
+			}
+		}
+	}
+	return ForkConfig(forks), nil
+}
+
+// TxCodecVersionAt returns the tx serialization version in force at blockNum
+func (fc ForkConfig) TxCodecVersionAt(blockNum int64) TxCodecVersion {
+	version := TxCodecV1
+	for _, fork := range fc {
+		if blockNum < fork.BlockNum {
+			break
+		}
+		if fork.TxCodec != 0 {
+			version = fork.TxCodec
+		}
+	}
+	return version
+}
+
+// TxCodecAt returns the TxCodec in force at blockNum
+func (fc ForkConfig) TxCodecAt(blockNum int64) (TxCodec, error) {
+	return TxCodecByVersion(fc.TxCodecVersionAt(blockNum))
+}
@@ -25,6 +25,11 @@ type L2Tx struct {
 	Type  TxType `meddler:"type"`
 	// EthBlockNum in which this L2Tx was added to the queue
 	EthBlockNum int64 `meddler:"eth_block_num"`
+	// DAOffset and DALength locate the encoded bytes of the tx within the
+	// forgeBatch calldata: the tx is calldata[DAOffset:DAOffset+DALength].
+	// Filled by the synchronizer; both are 0 when unknown
+	DAOffset int `meddler:"da_offset"`
+	DALength int `meddler:"da_length"`
 }
 
 // NewL2Tx returns the given L2Tx with the TxId & Type parameters calculated
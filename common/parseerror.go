@@ -0,0 +1,22 @@
+package common
+
+import "fmt"
+
+// ParseError is returned by the string parsing helpers of this package when a
+// value can't be converted into its typed representation.  It carries the
+// name of the field being parsed, the offending value and a description of
+// the expected format, so that upper layers (such as the API) can build
+// field-level error details instead of exposing opaque strconv errors
+type ParseError struct {
+	// Field is the name of the field or parameter being parsed
+	Field string
+	// Value is the value that failed to parse, as received
+	Value string
+	// Expected describes the format that the value must follow
+	Expected string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid %s \"%s\", must follow this: %s",
+		e.Field, e.Value, e.Expected)
+}
@@ -40,7 +40,10 @@ type PoolL2Tx struct {
 	Amount    *big.Int              `meddler:"amount,bigint"`
 	Fee       FeeSelector           `meddler:"fee"`
 	Nonce     Nonce                 `meddler:"nonce"` // effective 40 bits used
-	State     PoolL2TxState         `meddler:"state"`
+	// MaxNumBatch is the maximum batch number in which the tx can be
+	// forged.  0 means no limit
+	MaxNumBatch uint32        `meddler:"max_num_batch,zeroisnull"`
+	State       PoolL2TxState `meddler:"state"`
 	// Info contains information about the status & State of the
 	// transaction. As for example, if the Tx has not been selected in the
 	// last batch due not enough Balance at the Sender account, this reason
@@ -298,8 +301,12 @@ func (tx *PoolL2Tx) HashToSign(chainID uint16) (*big.Int, error) {
 		return nil, tracerr.Wrap(err)
 	}
 
-	// e1: [5 bytes AmountFloat40 | 20 bytes ToEthAddr]
-	var e1B [25]byte
+	// e1: [4 bytes MaxNumBatch | 5 bytes AmountFloat40 | 20 bytes ToEthAddr].
+	// When MaxNumBatch is 0 the hash is the same as the previous
+	// serialization without the field, so old signatures remain valid
+	var e1B [29]byte
+	maxNumBatchBytes := make([]byte, 4) //nolint:gomnd
+	binary.BigEndian.PutUint32(maxNumBatchBytes, tx.MaxNumBatch)
 	amountFloat40, err := NewFloat40(tx.Amount)
 	if err != nil {
 		return nil, tracerr.Wrap(err)
@@ -308,8 +315,9 @@ func (tx *PoolL2Tx) HashToSign(chainID uint16) (*big.Int, error) {
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
-	copy(e1B[0:5], amountFloat40Bytes)
-	copy(e1B[5:25], tx.ToEthAddr[:])
+	copy(e1B[0:4], maxNumBatchBytes)
+	copy(e1B[4:9], amountFloat40Bytes)
+	copy(e1B[9:29], tx.ToEthAddr[:])
 	e1 := new(big.Int).SetBytes(e1B[:])
 	rqToEthAddr := EthAddrToBigInt(tx.RqToEthAddr)
 
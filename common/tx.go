@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -71,13 +70,14 @@ func (txid TxID) String() string {
 // NewTxIDFromString returns a string hexadecimal representation of the TxID
 func NewTxIDFromString(idStr string) (TxID, error) {
 	txid := TxID{}
-	idStr = strings.TrimPrefix(idStr, "0x")
-	decoded, err := hex.DecodeString(idStr)
-	if err != nil {
-		return TxID{}, tracerr.Wrap(err)
-	}
-	if len(decoded) != TxIDLen {
-		return txid, tracerr.Wrap(errors.New("Invalid idStr"))
+	trimmed := strings.TrimPrefix(idStr, "0x")
+	decoded, err := hex.DecodeString(trimmed)
+	if err != nil || len(decoded) != TxIDLen {
+		return txid, tracerr.Wrap(&ParseError{
+			Field: "id", Value: idStr,
+			Expected: fmt.Sprintf("a %d byte hexadecimal string prefixed with 0x",
+				TxIDLen),
+		})
 	}
 	copy(txid[:], decoded)
 	return txid, nil
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hermeznetwork/tracerr"
+)
+
+// TxCodecVersion identifies a version of the L1Tx/L2Tx byte serialization:
+// both the user/coordinator tx forms and the data availability forms.
+// Circuit upgrades that change the wire format (a different amount float
+// encoding, a different idx width, etc.) are added as new versions, so that
+// batches forged before and after a fork can be decoded side-by-side.
+type TxCodecVersion uint8
+
+const (
+	// TxCodecV1 is the serialization used by the current circuits, with
+	// Float40 amounts and idx width derived from nLevels
+	TxCodecV1 TxCodecVersion = 1
+	// TxCodecCurrent is the version used to encode newly forged batches
+	TxCodecCurrent = TxCodecV1
+)
+
+// TxCodec is the set of L1Tx/L2Tx serialization primitives of a single codec
+// version.  The methods mirror the package-level functions and tx methods
+// that implement the current format (see l1tx.go and l2tx.go), which are
+// kept as the implementation of TxCodecV1.
+type TxCodec interface {
+	// Version returns the TxCodecVersion the codec implements
+	Version() TxCodecVersion
+	// L1UserTxBytes encodes a L1UserTx the way it's hashed in the smart
+	// contract queues
+	L1UserTxBytes(tx *L1Tx) ([]byte, error)
+	// L1UserTxFromBytes decodes a L1UserTx
+	L1UserTxFromBytes(b []byte) (*L1Tx, error)
+	// L1TxBytesDataAvailability encodes a L1Tx for the forgeBatch calldata
+	L1TxBytesDataAvailability(tx *L1Tx, nLevels uint32) ([]byte, error)
+	// L1TxFromDataAvailability decodes a L1Tx from the forgeBatch calldata
+	L1TxFromDataAvailability(b []byte, nLevels uint32) (*L1Tx, error)
+	// L2TxBytesDataAvailability encodes a L2Tx for the forgeBatch calldata
+	L2TxBytesDataAvailability(tx L2Tx, nLevels uint32) ([]byte, error)
+	// L2TxFromBytesDataAvailability decodes a L2Tx from the forgeBatch
+	// calldata
+	L2TxFromBytesDataAvailability(b []byte, nLevels int) (*L2Tx, error)
+	// L1L2TxsDataAvailabilityBytesLen returns the length in bytes of a
+	// single tx record in the forgeBatch calldata
+	L1L2TxsDataAvailabilityBytesLen(nLevels uint32) int
+}
+
+// txCodecV1 implements TxCodec delegating to the current serialization
+// functions of the package
+type txCodecV1 struct{}
+
+func (txCodecV1) Version() TxCodecVersion { return TxCodecV1 }
+
+func (txCodecV1) L1UserTxBytes(tx *L1Tx) ([]byte, error) {
+	return tx.BytesUser()
+}
+
+func (txCodecV1) L1UserTxFromBytes(b []byte) (*L1Tx, error) {
+	return L1UserTxFromBytes(b)
+}
+
+func (txCodecV1) L1TxBytesDataAvailability(tx *L1Tx, nLevels uint32) ([]byte, error) {
+	return tx.BytesDataAvailability(nLevels)
+}
+
+func (txCodecV1) L1TxFromDataAvailability(b []byte, nLevels uint32) (*L1Tx, error) {
+	return L1TxFromDataAvailability(b, nLevels)
+}
+
+func (txCodecV1) L2TxBytesDataAvailability(tx L2Tx, nLevels uint32) ([]byte, error) {
+	return tx.BytesDataAvailability(nLevels)
+}
+
+func (txCodecV1) L2TxFromBytesDataAvailability(b []byte, nLevels int) (*L2Tx, error) {
+	return L2TxFromBytesDataAvailability(b, nLevels)
+}
+
+func (txCodecV1) L1L2TxsDataAvailabilityBytesLen(nLevels uint32) int {
+	return int((nLevels/8)*2 + Float40BytesLength + 1) //nolint:gomnd
+}
+
+// TxCodecByVersion returns the TxCodec that implements the given version
+func TxCodecByVersion(version TxCodecVersion) (TxCodec, error) {
+	switch version {
+	case TxCodecV1:
+		return txCodecV1{}, nil
+	default:
+		return nil, tracerr.Wrap(fmt.Errorf("unknown TxCodecVersion %d", version))
+	}
+}
+
+// TxCodecFork ties a codec version to the first batch forged with it
+type TxCodecFork struct {
+	// Version of the codec used from FromBatchNum on
+	Version TxCodecVersion
+	// FromBatchNum is the first batch forged with Version
+	FromBatchNum BatchNum
+}
+
+// TxCodecForBatch returns the codec used to serialize the txs of batchNum
+// given the fork schedule of the chain.  forks must be sorted by ascending
+// FromBatchNum; batches before the first fork use TxCodecV1.
+func TxCodecForBatch(forks []TxCodecFork, batchNum BatchNum) (TxCodec, error) {
+	version := TxCodecV1
+	for _, fork := range forks {
+		if batchNum < fork.FromBatchNum {
+			break
+		}
+		version = fork.Version
+	}
+	return TxCodecByVersion(version)
+}
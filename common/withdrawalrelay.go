@@ -0,0 +1,148 @@
+package common
+
+import (
+	"math/big"
+	"time"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	ethMath "github.com/ethereum/go-ethereum/common/math"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	ethSigner "github.com/ethereum/go-ethereum/signer/core"
+	"github.com/hermeznetwork/tracerr"
+)
+
+const (
+	// WithdrawalRelayAuthMsg is the message that is signed to authorize the
+	// coordinator to submit the withdrawMerkleProof of an exit on behalf of
+	// its owner
+	WithdrawalRelayAuthMsg = "Withdrawal relay"
+)
+
+// WithdrawalRelayAuth authorizations sent by users to the L2DB, allowing the
+// coordinator to submit the withdrawMerkleProof of an exit on their behalf in
+// exchange for the committed fee.  The withdrawn amount is always sent to the
+// owner of the exit by the smart contract, so the signature doesn't put the
+// funds at risk: it binds the owner to the fee
+type WithdrawalRelayAuth struct {
+	EthAddr    ethCommon.Address `meddler:"owner_addr"`
+	BatchNum   BatchNum          `meddler:"batch_num"`
+	AccountIdx Idx               `meddler:"account_idx"`
+	// Fee is the amount, denominated in the withdrawn token, that the
+	// owner commits to pay the coordinator for the relay
+	Fee       *big.Int  `meddler:"fee,bigint"`
+	Signature []byte    `meddler:"signature"`
+	Timestamp time.Time `meddler:"timestamp,utctime"`
+}
+
+// toHash returns a byte array to be hashed from the WithdrawalRelayAuth,
+// which follows the EIP-712 encoding
+func (a *WithdrawalRelayAuth) toHash(chainID uint16,
+	hermezContractAddr ethCommon.Address) ([]byte, error) {
+	chainIDFormatted := ethMath.NewHexOrDecimal256(int64(chainID))
+
+	signerData := ethSigner.TypedData{
+		Types: ethSigner.Types{
+			"EIP712Domain": []ethSigner.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Authorise": []ethSigner.Type{
+				{Name: "Provider", Type: "string"},
+				{Name: "Authorisation", Type: "string"},
+				{Name: "BatchNum", Type: "uint64"},
+				{Name: "AccountIndex", Type: "uint64"},
+				{Name: "Fee", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Authorise",
+		Domain: ethSigner.TypedDataDomain{
+			Name:              EIP712Provider,
+			Version:           EIP712Version,
+			ChainId:           chainIDFormatted,
+			VerifyingContract: hermezContractAddr.Hex(),
+		},
+		Message: ethSigner.TypedDataMessage{
+			"Provider":      EIP712Provider,
+			"Authorisation": WithdrawalRelayAuthMsg,
+			"BatchNum":      ethMath.NewHexOrDecimal256(int64(a.BatchNum)),
+			"AccountIndex":  ethMath.NewHexOrDecimal256(int64(a.AccountIdx)),
+			"Fee":           (*ethMath.HexOrDecimal256)(a.Fee),
+		},
+	}
+
+	domainSeparator, err := signerData.HashStruct("EIP712Domain", signerData.Domain.Map())
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	typedDataHash, err := signerData.HashStruct(signerData.PrimaryType, signerData.Message)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	rawData := []byte{0x19, 0x01} // "\x19\x01"
+	rawData = append(rawData, domainSeparator...)
+	rawData = append(rawData, typedDataHash...)
+	return rawData, nil
+}
+
+// HashToSign returns the hash to be signed by the Ethereum address to
+// authorize the withdrawal relay, which follows the EIP-712 encoding
+func (a *WithdrawalRelayAuth) HashToSign(chainID uint16,
+	hermezContractAddr ethCommon.Address) ([]byte, error) {
+	b, err := a.toHash(chainID, hermezContractAddr)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return ethCrypto.Keccak256(b), nil
+}
+
+// Sign signs the withdrawal relay authorization message using the provided
+// `signHash` function, and stores the signature in `a.Signature`.  `signHash`
+// should do an ethereum signature using the account corresponding to
+// `a.EthAddr`.  Sign follows the EIP-712 encoding.
+func (a *WithdrawalRelayAuth) Sign(signHash func(hash []byte) ([]byte, error),
+	chainID uint16, hermezContractAddr ethCommon.Address) error {
+	hash, err := a.HashToSign(chainID, hermezContractAddr)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	sig, err := signHash(hash)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	sig[64] += 27
+	a.Signature = sig
+	a.Timestamp = time.Now()
+	return nil
+}
+
+// VerifySignature ensures that the Signature is done with the EthAddr, for
+// the chainID and hermezContractAddress passed by parameter. VerifySignature
+// follows the EIP-712 encoding.
+func (a *WithdrawalRelayAuth) VerifySignature(chainID uint16,
+	hermezContractAddr ethCommon.Address) bool {
+	// Calculate hash to be signed
+	hash, err := a.HashToSign(chainID, hermezContractAddr)
+	if err != nil {
+		return false
+	}
+
+	var sig [65]byte
+	copy(sig[:], a.Signature[:])
+	sig[64] -= 27
+
+	// Get public key from Signature
+	pubKBytes, err := ethCrypto.Ecrecover(hash, sig[:])
+	if err != nil {
+		return false
+	}
+	pubK, err := ethCrypto.UnmarshalPubkey(pubKBytes)
+	if err != nil {
+		return false
+	}
+	// Get addr from pubK
+	addr := ethCrypto.PubkeyToAddress(*pubK)
+	return addr == a.EthAddr
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithdrawalRelayAuthSignVerify(t *testing.T) {
+	// Ethereum key
+	ethSk, err :=
+		ethCrypto.HexToECDSA("fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	require.NoError(t, err)
+	ethAddr := ethCrypto.PubkeyToAddress(ethSk.PublicKey)
+
+	chainID := uint16(0)
+	hermezContractAddr := ethCommon.HexToAddress("0xc344E203a046Da13b0B4467EB7B3629D0C99F6E6")
+	a := WithdrawalRelayAuth{
+		EthAddr:    ethAddr,
+		BatchNum:   BatchNum(42),
+		AccountIdx: Idx(256),
+		Fee:        big.NewInt(1000),
+	}
+
+	// Sign using the Sign function (stores signature in a.Signature)
+	err = a.Sign(func(hash []byte) ([]byte, error) {
+		return ethCrypto.Sign(hash, ethSk)
+	}, chainID, hermezContractAddr)
+	require.NoError(t, err)
+
+	// Hash and sign manually and compare the generated signature
+	hash, err := a.HashToSign(chainID, hermezContractAddr)
+	require.NoError(t, err)
+	sig, err := ethCrypto.Sign(hash, ethSk)
+	require.NoError(t, err)
+	sig[64] += 27
+	assert.Equal(t, sig, a.Signature)
+
+	assert.True(t, a.VerifySignature(chainID, hermezContractAddr))
+
+	// A different fee invalidates the signature
+	a.Fee = big.NewInt(2000)
+	assert.False(t, a.VerifySignature(chainID, hermezContractAddr))
+
+	// A different signer is not the owner
+	a.Fee = big.NewInt(1000)
+	a.EthAddr = ethCommon.HexToAddress("0x71C7656EC7ab88b098defB751B7401B5f6d8976F")
+	assert.False(t, a.VerifySignature(chainID, hermezContractAddr))
+}
@@ -33,7 +33,9 @@ func (d *Duration) UnmarshalText(data []byte) error {
 
 // ServerProof is the server proof configuration data.
 type ServerProof struct {
-	// URL is the server proof API URL
+	// URL is the server proof API URL.  The scheme selects the prover
+	// backend: "grpc://" and "grpcs://" (with TLS) use the gRPC backend,
+	// any other scheme uses the HTTP one.
 	URL string `validate:"required,url"`
 }
 
@@ -46,6 +48,28 @@ type ForgeBatchGasCost struct {
 	L2Tx      uint64 `validate:"required"`
 }
 
+// TokenMinFee is a per-token minimum fee rule applied when accepting txs
+// into the pool
+type TokenMinFee struct {
+	// TokenID of the token the rule applies to
+	TokenID uint32
+	// MinFeeSelector is the minimum fee selector that txs of the token
+	// must use in order to be accepted into the pool
+	MinFeeSelector uint8
+}
+
+// WithdrawRelayerTokenLimit is the safety limit of a token relayed by the
+// withdrawal relayer
+type WithdrawRelayerTokenLimit struct {
+	// TokenID of the token the limit applies to
+	TokenID uint32
+	// MaxAmount is the maximum balance of a single relayed exit
+	MaxAmount *big.Int
+	// MinFee is the minimum fee, denominated in the token, that the owner
+	// must commit to pay for the relay
+	MinFee *big.Int
+}
+
 // CoordinatorAPI specifies the configuration parameters of the API in mode
 // coordinator
 type CoordinatorAPI struct {
@@ -124,6 +148,28 @@ type Coordinator struct {
 	// overrides `ForgeDelay`, `ForgeNoTxsDelay`, `MustForgeAtSlotDeadline`
 	// and `IgnoreSlotCommitment`.
 	ForgeOncePerSlotIfTxs bool
+	// ForgePolicy configures the batch trigger policy.  When enabled, a
+	// batch is forged as soon as any of the configured trigger conditions
+	// is met, instead of following the fixed `ForgeDelay` cadence.
+	// Conditions with a zero value are disabled.
+	ForgePolicy struct {
+		// Enabled enables the batch trigger policy
+		Enabled bool
+		// MinPoolTxs triggers a forge when the number of pending txs
+		// in the pool reaches this value
+		MinPoolTxs int
+		// MinFeesUSD triggers a forge when the accumulated fees in
+		// USD of the pending txs in the pool reach this value
+		MinFeesUSD float64
+		// L1TimeoutBlocks triggers a forge when the number of
+		// remaining blocks until the L1L2 batch timeout is this value
+		// or less
+		L1TimeoutBlocks int64
+		// MaxInterval triggers a forge when this interval has passed
+		// since the last forged batch, regardless of the pool
+		// contents
+		MaxInterval Duration
+	}
 	// SyncRetryInterval is the waiting interval between calls to the main
 	// handler of a synced block after an error
 	SyncRetryInterval Duration `validate:"required"`
@@ -131,6 +177,59 @@ type Coordinator struct {
 	// to the PurgeByExternalDelete function of the l2db which deletes
 	// pending txs externally marked by the column `external_delete`
 	PurgeByExtDelInterval Duration `validate:"required"`
+	// ForcedExits configures the automatic generation of L1 ForceExit
+	// transactions on behalf of accounts whose exit pool txs repeatedly
+	// fail selection (for example when the account BJJ key can't sign a
+	// valid tx anymore)
+	ForcedExits struct {
+		// Enabled enables the forced exit generation
+		Enabled bool
+		// MaxFailedSelections is the number of consecutive batch
+		// selections in which the exit txs of an account must be
+		// discarded before a ForceExit is generated on its behalf
+		MaxFailedSelections int
+		// MaxPerScan is the maximum number of ForceExit transactions
+		// queued after a single batch selection
+		MaxPerScan int
+	}
+	// WithdrawRelayer configures the automatic submission of the
+	// withdrawMerkleProof of the exits whose owners have requested it
+	// through the API, in exchange for the fee committed in the signed
+	// request
+	WithdrawRelayer struct {
+		// Enabled enables the withdrawal relayer
+		Enabled bool
+		// Interval is the waiting interval between scans of the
+		// pending withdrawal relay requests
+		Interval Duration `validate:"required_with=Enabled"`
+		// TokenLimits is the list of relayed tokens with their safety
+		// limits.  Exits of tokens not in this list are never relayed
+		TokenLimits []WithdrawRelayerTokenLimit
+	}
+	// Gossip configures the forwarding of received pool txs to
+	// cooperating coordinators, so that the txs get forged by whichever
+	// coordinator wins the slot
+	Gossip struct {
+		// Peers is the list of API base URLs of the cooperating
+		// coordinators to forward received pool txs to
+		Peers []string `validate:"omitempty,dive,url"`
+	}
+	// HA configures the primary/standby failover pair mode, where two
+	// coordinator instances share the PostgreSQL database and only the
+	// one holding the leader lease forges
+	HA struct {
+		// Enabled activates the leader election
+		Enabled bool
+		// HolderID identifies this instance in the lease table.
+		// Defaults to "<hostname>-<pid>" when empty
+		HolderID string
+		// LeaseTTL is the duration after which a non-renewed lease
+		// expires and the standby takes over
+		LeaseTTL Duration `validate:"required_with=Enabled"`
+		// HeartbeatInterval is the interval between lease renewals.
+		// It must be shorter than LeaseTTL
+		HeartbeatInterval Duration `validate:"required_with=Enabled"`
+	}
 	// L2DB is the DB that holds the pool of L2Txs
 	L2DB struct {
 		// SafetyPeriod is the number of batches after which
@@ -149,6 +248,15 @@ type Coordinator struct {
 		// order to be accepted into the pool.  Txs with greater than
 		// maximum fee will be rejected at the API level.
 		MaxFeeUSD float64 `validate:"required,gte=0"`
+		// TokenMinFees is a list of per-token minimum fee selectors.
+		// Txs of a listed token with a fee selector below the minimum
+		// will be rejected at the API level, regardless of MinFeeUSD.
+		TokenMinFees []TokenMinFee
+		// FreeTxsPerAccountPerDay is the number of txs per sender
+		// account and day that are accepted into the pool even if
+		// they don't reach the minimum fee.  0 disables the
+		// allowance.
+		FreeTxsPerAccountPerDay uint32 `validate:"gte=0"`
 		// TTL is the Time To Live for L2Txs in the pool. L2Txs older
 		// than TTL will be deleted.
 		TTL Duration `validate:"required"`
@@ -172,10 +280,22 @@ type Coordinator struct {
 		// invalid transactions due to nonce lower than the account
 		// nonce.
 		InvalidateBlockDelay int64 `validate:"required,gte=0"`
+		// WarmStart enables the pool warm start: before the first
+		// selection after the node starts, the pending txs that
+		// survived the restart are revalidated against the restored
+		// state and the stale ones are invalidated.
+		WarmStart bool
 	} `validate:"required"`
 	TxSelector struct {
 		// Path where the TxSelector StateDB is stored
 		Path string `validate:"required"`
+		// FeeIdxStrategy selects how the coordinator fee collection
+		// idxs are chosen among the TokenIDs used in each batch:
+		// "all-tokens" (default), "highest-fees" or "whitelist"
+		FeeIdxStrategy string
+		// FeeIdxTokenWhitelist is the list of TokenIDs for which fees
+		// are collected, only used when FeeIdxStrategy is "whitelist"
+		FeeIdxTokenWhitelist []uint32
 	} `validate:"required"`
 	BatchBuilder struct {
 		// Path where the BatchBuilder StateDB is stored
@@ -188,6 +308,33 @@ type Coordinator struct {
 		// NLevels is the maximum number of merkle tree levels
 		// supported by the circuit
 		NLevels int64 `validate:"required,gte=0"`
+		// ProofCostUSD is the estimated cost in USD of generating a
+		// proof for this circuit.  Only used when AltVerifiers is set
+		ProofCostUSD float64 `validate:"gte=0"`
+		// VerifyingKeyPath is the optional path to the Groth16
+		// verifying key of the circuit (snarkjs
+		// verification_key.json format).  When set, every proof
+		// received from a proof server is verified locally before
+		// being submitted in a forgeBatch call
+		VerifyingKeyPath string
+		// AltVerifiers optionally lists alternative circuits
+		// (registered as verifiers in the rollup smart contract with
+		// the same NLevels) that the coordinator can also forge with.
+		// When set, the verifier of each batch is chosen among them
+		// and the main circuit to maximize the collected fees minus
+		// the proof cost.  All the listed circuits must be supported
+		// by the configured ServerProofs
+		AltVerifiers []struct {
+			// MaxTx is the maximum number of txs supported by
+			// the alternative circuit
+			MaxTx int64 `validate:"required,gte=0"`
+			// ProofCostUSD is the estimated cost in USD of
+			// generating a proof for the alternative circuit
+			ProofCostUSD float64 `validate:"gte=0"`
+			// VerifyingKeyPath is the optional path to the
+			// Groth16 verifying key of the alternative circuit
+			VerifyingKeyPath string
+		}
 	} `validate:"required"`
 	EthClient struct {
 		// MaxGasPrice is the maximum gas price allowed for ethereum
@@ -220,11 +367,36 @@ type Coordinator struct {
 			// Password used to decrypt the keys in the keystore
 			Password string `validate:"required"`
 		} `validate:"required"`
+		// RemoteSigner configures signing the forger transactions
+		// with an external signing service that exposes the
+		// eth_signTransaction JSON-RPC method (e.g. web3signer,
+		// clef), so the forger private key doesn't reside on the
+		// coordinator host.  When URL is set and the local keystore
+		// also holds the forger key, the remote signer is used as
+		// primary with the keystore as fallback
+		RemoteSigner struct {
+			// URL of the JSON-RPC endpoint of the remote signer.
+			// Remote signing is disabled when empty
+			URL string
+		}
 		// ForgeBatchGasCost contains the cost of each action in the
 		// ForgeBatch transaction.
 		ForgeBatchGasCost ForgeBatchGasCost `validate:"required"`
 	} `validate:"required"`
-	API   CoordinatorAPI `validate:"required"`
+	API CoordinatorAPI `validate:"required"`
+	// ZKInputsArchive if set, persists the ZKInputs of every forged batch
+	// so they can be retrieved later through the debug API for re-proving,
+	// debugging proof failures, or circuit audits
+	ZKInputsArchive struct {
+		// Path is the directory where the ZKInputs are archived.
+		// Archiving is disabled when empty.
+		Path string
+		// GZip compresses the archived ZKInputs
+		GZip bool
+		// Retention is the number of most recent batches whose ZKInputs
+		// are kept; older ones are deleted.  0 keeps all batches.
+		Retention int
+	}
 	Debug struct {
 		// BatchPath if set, specifies the path where batchInfo is stored
 		// in JSON in every step/update of the pipeline
@@ -270,6 +442,58 @@ type PostgreSQL struct {
 	PasswordRead string
 	// Name of the PostgreSQL read server database
 	NameRead string
+	// ReadReplicas is the list of additional PostgreSQL read replicas used
+	// to scale read queries horizontally.  Write queries always go to the
+	// write server
+	ReadReplicas []PostgreSQLReplica
+	// ReadPolicy is the policy used to distribute read queries among the
+	// read replicas: "round-robin" (default) or "least-connections"
+	ReadPolicy string
+}
+
+// PostgreSQLReplica is the configuration of a PostgreSQL read replica
+type PostgreSQLReplica struct {
+	// Port of the PostgreSQL read replica
+	Port int `validate:"required"`
+	// Host of the PostgreSQL read replica
+	Host string `validate:"required"`
+	// User of the PostgreSQL read replica
+	User string `validate:"required"`
+	// Password of the PostgreSQL read replica
+	Password string `validate:"required"`
+	// Name of the PostgreSQL read replica database
+	Name string `validate:"required"`
+}
+
+// Fork is an entry of the protocol upgrade schedule of the rollup smart
+// contract (see Node.Forks)
+type Fork struct {
+	// BlockNum is the Ethereum block at which the fork activates
+	BlockNum int64 `validate:"gte=0"`
+	// TxCodec is the tx serialization version used from BlockNum on.  Set
+	// to 0 to keep the version of the previous fork
+	TxCodec uint8
+}
+
+// Tracing specifies the configuration of the distributed tracing
+// instrumentation (see the trace package)
+type Tracing struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector where the
+	// spans are exported.  Tracing is disabled when left empty
+	Endpoint string `validate:"omitempty,url"`
+	// ServiceName is the service.name resource attribute attached to the
+	// exported spans.  Defaults to "hermez-node"
+	ServiceName string
+	// SampleRatio is the fraction of traces that are sampled and
+	// exported, decided at the root span.  Defaults to 1 (export
+	// everything)
+	SampleRatio float64 `validate:"gte=0,lte=1"`
+	// BatchSize is the number of finished spans accumulated before an
+	// export
+	BatchSize int `validate:"gte=0"`
+	// FlushInterval is the maximum time a finished span waits to be
+	// exported
+	FlushInterval Duration
 }
 
 // NodeDebug specifies debug configuration parameters
@@ -298,18 +522,42 @@ type Node struct {
 		Statictokens string
 		// Fiat defines the prices for fiat currencies
 		Fiat priceupdater.Fiat
+		// HistoricBackfillInterval, when set, enables a background job
+		// that backfills the USD values of txs whose token had no
+		// known price when they were forged, using historic prices
+		// from the providers.  Set to 0 (or leave unset) to disable
+		HistoricBackfillInterval Duration
+		// StalePriceWindow is the time since the last update of a
+		// token USD price after which the price is considered stale.
+		// Stale prices are flagged in the token API responses and
+		// excluded from the fee USD computations.  Set to 0 (or leave
+		// unset) to use the default of 24 hours
+		StalePriceWindow Duration
 	} `validate:"required"`
 	StateDB struct {
 		// Path where the synchronizer StateDB is stored
 		Path string `validate:"required"`
 		// Keep is the number of checkpoints to keep
 		Keep int `validate:"required,gte=128"`
+		// KeepEvery, when set, retains one checkpoint every KeepEvery
+		// batches beyond the Keep most recent ones as sparse history
+		// (used for example by the historical account state proofs).
+		// Set to 0 to delete all checkpoints older than the Keep most
+		// recent ones
+		KeepEvery int `validate:"gte=0"`
 	} `validate:"required"`
 	PostgreSQL PostgreSQL `validate:"required"`
 	Web3       struct {
 		// URL is the URL of the web3 ethereum-node RPC server.  Only
 		// geth is officially supported.
 		URL string `validate:"required,url"`
+		// WSURL is the optional URL of the websocket RPC endpoint of
+		// the web3 ethereum-node.  When set, the synchronizer
+		// subscribes to the rollup smart contract logs via
+		// eth_subscribe and syncs new blocks as soon as they contain
+		// rollup events, falling back to polling at SyncLoopInterval
+		// when the subscription drops
+		WSURL string `validate:"omitempty,url"`
 	} `validate:"required"`
 	Synchronizer struct {
 		// SyncLoopInterval is the interval between attempts to
@@ -333,10 +581,42 @@ type Node struct {
 		// Rollup is the address of the Hermez.sol smart contract
 		Rollup ethCommon.Address `validate:"required"`
 	} `validate:"required"`
+	// Forks is the schedule of protocol upgrades of the rollup smart
+	// contract, sorted by ascending BlockNum.  It lets a single binary
+	// sync across contract upgrades that changed the tx serialization.
+	// Leave empty if the contract was never upgraded
+	Forks []Fork
+	// Webhooks configures the account activity webhook notifier (see the
+	// webhook package)
+	Webhooks struct {
+		// Interval between scans for new events.  Set to 0 (or leave
+		// unset) to disable the webhook notifier
+		Interval Duration
+		// Timeout of each delivery attempt
+		Timeout Duration
+		// MaxAttempts is the number of delivery attempts per
+		// notification before it's dropped
+		MaxAttempts int `validate:"gte=0"`
+		// RetryInterval is the wait after a failed delivery attempt,
+		// doubled on each retry
+		RetryInterval Duration
+	}
+	// DepositWatcher specifies the configuration parameters of the L1
+	// deposit watcher
+	DepositWatcher struct {
+		// Interval between scans of the watched ethereum
+		// transactions.  Set to 0 (or leave unset) to disable the
+		// deposit watcher
+		Interval Duration
+	}
 	// API specifies the configuration parameters of the API
 	API struct {
 		// Address where the API will listen if set
 		Address string
+		// GRPCAddress where the gRPC API will listen if set.  The
+		// gRPC API requires the REST API to be enabled, as it shares
+		// its access layer (see api/grpc)
+		GRPCAddress string
 		// Explorer enables the Explorer API endpoints
 		Explorer bool
 		// UpdateMetricsInterval is the interval between updates of the
@@ -350,10 +630,90 @@ type Node struct {
 		// SQLConnectionTimeout is the maximum amount of time that an API request
 		// can wait to establish a SQL connection
 		SQLConnectionTimeout Duration
+		// Auth specifies the credentials accepted by the operator-only
+		// API endpoints.  When left empty, those endpoints reject all
+		// requests.
+		Auth APIAuth
+		// CORS is the Cross-Origin Resource Sharing policy of the API,
+		// so browser-based clients can call it directly.  When left
+		// empty, all origins are allowed with the default methods and
+		// headers.
+		CORS APICORS
+		// Compression configures the compression of the API responses
+		Compression APICompression
+		// StalenessThreshold is the number of blocks the synchronizer
+		// can lag behind the ethereum head before the API enters safe
+		// mode, adding a staleness warning header to every response.
+		// Set to 0 (or leave unset) to disable the check
+		StalenessThreshold int64 `validate:"gte=0"`
+		// RejectStalePoolTxs makes the API reject pool tx submissions
+		// with 503 while in safe mode, since they would be validated
+		// against stale state
+		RejectStalePoolTxs bool
+		// SignedResponses makes the API sign the critical responses
+		// (pool admission receipts, exit data) with the coordinator
+		// forger key, returning the signature in a header.  Only
+		// available in coordinator mode
+		SignedResponses bool
+		// DisableQueryCache disables the in-process cache of the hot
+		// read-only queries (token list, auction vars, batches,
+		// metrics).  The cache is only used when the API runs along
+		// the synchronizer, which invalidates it on every
+		// synchronized block
+		DisableQueryCache bool
 	} `validate:"required"`
 	RecommendedFeePolicy stateapiupdater.RecommendedFeePolicy `validate:"required"`
-	Debug                NodeDebug                            `validate:"required"`
-	Coordinator          Coordinator                          `validate:"-"`
+	// Tracing configures the distributed tracing instrumentation (see
+	// the trace package)
+	Tracing     Tracing
+	Debug       NodeDebug   `validate:"required"`
+	Coordinator Coordinator `validate:"-"`
+	// Path is the filesystem path from which this configuration was
+	// loaded.  It is set by LoadNode and used to support hot reloading;
+	// it's not part of the TOML file.
+	Path string `toml:"-"`
+}
+
+// APIAuth specifies the credentials accepted by the operator-only API
+// endpoints
+type APIAuth struct {
+	// JWTSecret is the HS256 secret used to verify JWT bearer tokens.
+	// JWT authentication is disabled when left empty.
+	JWTSecret string
+	// APIKeys are the accepted API keys together with the scopes they
+	// grant
+	APIKeys []APIKeyAuth
+}
+
+// APIKeyAuth is an API key accepted by the operator-only API endpoints
+type APIKeyAuth struct {
+	// Key is the value expected in the X-API-Key header
+	Key string `validate:"required"`
+	// Scopes are the scopes granted to the key
+	Scopes []string `validate:"required"`
+}
+
+// APICORS is the Cross-Origin Resource Sharing policy of the API
+type APICORS struct {
+	// AllowedOrigins is the list of origins a cross-domain request can be
+	// executed from.  An empty list allows all origins.
+	AllowedOrigins []string
+	// AllowedMethods is the list of methods the client is allowed to use
+	// with cross-domain requests
+	AllowedMethods []string
+	// AllowedHeaders is the list of non-simple headers the client is
+	// allowed to use with cross-domain requests
+	AllowedHeaders []string
+}
+
+// APICompression is the compression applied to the API responses
+type APICompression struct {
+	// GZip enables gzip compression of the responses for the clients
+	// that accept it
+	GZip bool
+	// Level is the gzip compression level, from 1 (fastest) to 9 (best
+	// compression).  0 applies the default level.
+	Level int
 }
 
 // APIServer is the api server configuration parameters
@@ -369,6 +729,26 @@ type APIServer struct {
 		// SQLConnectionTimeout is the maximum amount of time that an API request
 		// can wait to establish a SQL connection
 		SQLConnectionTimeout Duration
+		// Auth specifies the credentials accepted by the operator-only
+		// API endpoints.  When left empty, those endpoints reject all
+		// requests.
+		Auth APIAuth
+		// CORS is the Cross-Origin Resource Sharing policy of the API,
+		// so browser-based clients can call it directly.  When left
+		// empty, all origins are allowed with the default methods and
+		// headers.
+		CORS APICORS
+		// Compression configures the compression of the API responses
+		Compression APICompression
+		// StalenessThreshold is the number of blocks the synchronizer
+		// can lag behind the ethereum head before the API enters safe
+		// mode, adding a staleness warning header to every response.
+		// Set to 0 (or leave unset) to disable the check
+		StalenessThreshold int64 `validate:"gte=0"`
+		// RejectStalePoolTxs makes the API reject pool tx submissions
+		// with 503 while in safe mode, since they would be validated
+		// against stale state
+		RejectStalePoolTxs bool
 	} `validate:"required"`
 	PostgreSQL  PostgreSQL `validate:"required"`
 	Coordinator struct {
@@ -390,9 +770,21 @@ type APIServer struct {
 			// order to be accepted into the pool.  Txs with greater than
 			// maximum fee will be rejected at the API level.
 			MaxFeeUSD float64 `validate:"required,gte=0"`
+			// TokenMinFees is a list of per-token minimum fee selectors.
+			// Txs of a listed token with a fee selector below the minimum
+			// will be rejected at the API level, regardless of MinFeeUSD.
+			TokenMinFees []TokenMinFee
+			// FreeTxsPerAccountPerDay is the number of txs per sender
+			// account and day that are accepted into the pool even if
+			// they don't reach the minimum fee.  0 disables the
+			// allowance.
+			FreeTxsPerAccountPerDay uint32 `validate:"gte=0"`
 		} `validate:"required"`
 	}
-	Debug NodeDebug `validate:"required"`
+	// Tracing configures the distributed tracing instrumentation (see
+	// the trace package)
+	Tracing Tracing
+	Debug   NodeDebug `validate:"required"`
 }
 
 // Load loads a generic config.
@@ -424,6 +816,7 @@ func LoadNode(path string, coordinator bool) (*Node, error) {
 			return nil, tracerr.Wrap(fmt.Errorf("error validating configuration file: %w", err))
 		}
 	}
+	cfg.Path = path
 	return &cfg, nil
 }
 
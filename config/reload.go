@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// defaultWatchInterval is the polling interval used to detect changes of the
+// configuration file
+const defaultWatchInterval = 10 * time.Second
+
+// Watcher watches a node configuration file and notifies subscribers with the
+// freshly loaded (and validated) configuration whenever the file changes.
+// Subscribers are expected to apply only the hot-reloadable subset of the
+// configuration (price updater interval, gas price caps, ...); settings that
+// require a restart are simply ignored by them.
+type Watcher struct {
+	path        string
+	coordinator bool
+	interval    time.Duration
+	modTime     time.Time
+
+	mutex sync.RWMutex
+	subs  []chan *Node
+}
+
+// NewWatcher creates a Watcher of the configuration file at path.  The
+// coordinator flag selects the validation applied on reloads, matching the
+// one of LoadNode.
+func NewWatcher(path string, coordinator bool) (*Watcher, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &Watcher{
+		path:        path,
+		coordinator: coordinator,
+		interval:    defaultWatchInterval,
+		modTime:     info.ModTime(),
+	}, nil
+}
+
+// Subscribe returns a channel on which the new configuration is sent every
+// time the watched file changes and loads successfully.  Slow subscribers
+// don't block the Watcher: if a subscriber hasn't consumed the previous
+// update yet, the update is dropped for that subscriber.
+func (w *Watcher) Subscribe() <-chan *Node {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	sub := make(chan *Node, 1)
+	w.subs = append(w.subs, sub)
+	return sub
+}
+
+// Run polls the configuration file until the context is canceled, reloading
+// it and notifying the subscribers on every change.  Invalid configurations
+// are logged and skipped, keeping the previous one in place.
+func (w *Watcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.interval):
+			if err := w.check(); err != nil {
+				log.Warnw("config.Watcher: reload skipped", "path", w.path, "err", err)
+			}
+		}
+	}
+}
+
+// check reloads the configuration file if it has changed since the last
+// check, broadcasting it to the subscribers
+func (w *Watcher) check() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !info.ModTime().After(w.modTime) {
+		return nil
+	}
+	w.modTime = info.ModTime()
+	cfg, err := LoadNode(w.path, w.coordinator)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	log.Infow("config.Watcher: configuration file changed", "path", w.path)
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	for _, sub := range w.subs {
+		select {
+		case sub <- cfg:
+		default:
+		}
+	}
+	return nil
+}
@@ -62,6 +62,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/txselector"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
 )
 
 var (
@@ -137,6 +138,12 @@ type Config struct {
 	// overrides `ForgeDelay`, `ForgeNoTxsDelay`, `MustForgeAtSlotDeadline`
 	// and `IgnoreSlotCommitment`.
 	ForgeOncePerSlotIfTxs bool
+	// ForgePolicy configures the batch trigger policy.  When enabled, a
+	// batch is forged as soon as any of the configured trigger conditions
+	// is met (pending pool txs, accumulated pool fees, approaching L1L2
+	// batch timeout or maximum interval since the last forged batch)
+	// instead of following the fixed `ForgeDelay` cadence.
+	ForgePolicy ForgePolicyCfg
 	// SyncRetryInterval is the waiting interval between calls to the main
 	// handler of a synced block after an error
 	SyncRetryInterval time.Duration
@@ -167,14 +174,58 @@ type Config struct {
 	// DebugBatchPath if set, specifies the path where batchInfo is stored
 	// in JSON in every step/update of the pipeline
 	DebugBatchPath string
-	Purger         PurgerCfg
+	// ZKInputsArchive if set, persists the ZKInputs of every forged batch
+	// so they can be retrieved later for re-proving or debugging
+	ZKInputsArchive *ZKInputsArchive
+	Purger          PurgerCfg
+	// WarmStartPool enables the pool warm start: before the first
+	// selection after the process starts, the pending pool txs are
+	// revalidated against the restored state and the stale ones are
+	// invalidated (see TxSelector.WarmStart)
+	WarmStartPool bool
+	// ForcedExits configures the automatic generation of L1 ForceExit
+	// transactions on behalf of accounts whose exit pool txs repeatedly
+	// fail selection
+	ForcedExits ForcedExitsCfg
+	// WithdrawRelayer configures the automatic submission of the
+	// withdrawMerkleProof of the exits whose owners have requested it
+	// through the API (see WithdrawRelayer)
+	WithdrawRelayer WithdrawRelayerCfg
 	// VerifierIdx is the index of the verifier contract registered in the
 	// smart contract
 	VerifierIdx uint8
+	// Verifiers optionally lists all the verifiers (sharing NLevels with
+	// the main circuit) the coordinator can forge with.  When set, the
+	// verifier of each batch is chosen among them to maximize the
+	// collected fees minus the proof cost, instead of always using
+	// VerifierIdx (see VerifierSelector)
+	Verifiers []VerifierCfg
+	// ProofVerifiers optionally maps a rollup verifier idx to the Groth16
+	// verifying key of its circuit.  When the verifying key of the
+	// verifier used to forge a batch is available, the proof received
+	// from the proof server is verified locally before being submitted in
+	// the forgeBatch call, and re-dispatched to another prover if invalid
+	ProofVerifiers map[uint8]*prover.VerifyingKey
 	// ForgeBatchGasCost contains the cost of each action in the
 	// ForgeBatch transaction.
 	ForgeBatchGasCost config.ForgeBatchGasCost
 	TxProcessorConfig txprocessor.Config
+	// HA configures the primary/standby failover pair mode, where only
+	// the instance holding the shared database lease forges (see HACfg)
+	HA HACfg
+}
+
+// ForcedExitsCfg is the forced exits generation configuration
+type ForcedExitsCfg struct {
+	// Enabled enables the forced exit generation
+	Enabled bool
+	// MaxFailedSelections is the number of consecutive batch selections in
+	// which the exit txs of an account must be discarded before a
+	// ForceExit is generated on its behalf
+	MaxFailedSelections int
+	// MaxPerScan is the maximum number of ForceExit transactions queued
+	// after a single batch selection
+	MaxPerScan int
 }
 
 func (c *Config) debugBatchStore(batchInfo *BatchInfo) {
@@ -186,6 +237,15 @@ func (c *Config) debugBatchStore(batchInfo *BatchInfo) {
 	}
 }
 
+func (c *Config) archiveZKInputs(batchNum common.BatchNum, zkInputs *common.ZKInputs) {
+	if c.ZKInputsArchive != nil {
+		if err := c.ZKInputsArchive.Store(batchNum, zkInputs); err != nil {
+			log.Warnw("Error archiving ZKInputs",
+				"batch", batchNum, "err", err)
+		}
+	}
+}
+
 type fromBatch struct {
 	BatchNum   common.BatchNum
 	ForgerAddr ethCommon.Address
@@ -226,9 +286,19 @@ type Coordinator struct {
 	mutexL2DBUpdateDelete sync.Mutex
 	pipeline              *Pipeline
 	lastNonFailedBatchNum common.BatchNum
+	// poolWarmStarted is set after the first pipeline start of the
+	// process has revalidated the pool (see Config.WarmStartPool)
+	poolWarmStarted bool
 
 	purger    *Purger
 	txManager *TxManager
+	// withdrawRelayer is nil unless the withdrawal relayer is enabled
+	withdrawRelayer *WithdrawRelayer
+	// tokenRegistrar runs the RollupAddToken admin workflow (see
+	// TokenRegistrar)
+	tokenRegistrar *TokenRegistrar
+	// leader is nil unless the HA failover pair mode is enabled
+	leader *leaderElector
 }
 
 // NewCoordinator creates a new Coordinator
@@ -283,6 +353,14 @@ func NewCoordinator(cfg Config,
 		// wg
 		cancel: cancel,
 	}
+	if cfg.HA.Enabled {
+		c.leader = newLeaderElector(cfg.HA, l2DB)
+	}
+	if cfg.WithdrawRelayer.Enabled {
+		c.withdrawRelayer = NewWithdrawRelayer(cfg.WithdrawRelayer,
+			historyDB, l2DB, ethClient)
+	}
+	c.tokenRegistrar = NewTokenRegistrar(historyDB, ethClient, cfg.ForgerAddress)
 	ctxTimeout, ctxTimeoutCancel := context.WithTimeout(ctx, 1*time.Second)
 	defer ctxTimeoutCancel()
 	txManager, err := NewTxManager(ctxTimeout, &cfg, ethClient, l2DB, &c,
@@ -307,6 +385,52 @@ func (c *Coordinator) BatchBuilder() *batchbuilder.BatchBuilder {
 	return c.batchBuilder
 }
 
+// RotateCoordAccount replaces the Coordinator fee account used by the
+// TxSelector, applying to the selections of the subsequent batches.  It
+// implements the api.CoordAccountRotator interface
+func (c *Coordinator) RotateCoordAccount(addr ethCommon.Address,
+	bjj babyjub.PublicKeyComp, accountCreationAuth []byte) error {
+	c.txSelector.SetCoordAccount(txselector.CoordAccount{
+		Addr:                addr,
+		BJJ:                 bjj,
+		AccountCreationAuth: accountCreationAuth,
+	})
+	log.Infow("Coordinator: CoordAccount rotated", "addr", addr)
+	return nil
+}
+
+// ReserveIdx returns the Idx that the account will get when the TxSelector
+// creates it in the next forged batch, holding a short-lived reservation so
+// that the promise is kept.  It implements the api.IdxReserver interface
+func (c *Coordinator) ReserveIdx(addr ethCommon.Address, bjj babyjub.PublicKeyComp,
+	tokenID common.TokenID, nPendingL1CreateAccounts int) (common.Idx, time.Time, error) {
+	idx, expiry, err := c.txSelector.ReserveIdx(addr, bjj, tokenID, nPendingL1CreateAccounts)
+	return idx, expiry, tracerr.Wrap(err)
+}
+
+// RegisterToken submits the registration of a token in the Rollup smart
+// contract (see TokenRegistrar.RegisterToken).  It implements the
+// api.TokenRegistrar interface
+func (c *Coordinator) RegisterToken(tokenAddr ethCommon.Address) (ethCommon.Hash, error) {
+	ethTxHash, err := c.tokenRegistrar.RegisterToken(tokenAddr)
+	return ethTxHash, tracerr.Wrap(err)
+}
+
+// TokenRegistrationStatus returns the status of the registration of a token
+// (see TokenRegistrar.TokenRegistrationStatus).  It implements the
+// api.TokenRegistrar interface
+func (c *Coordinator) TokenRegistrationStatus(
+	tokenAddr ethCommon.Address) (string, ethCommon.Hash, error) {
+	status, ethTxHash, err := c.tokenRegistrar.TokenRegistrationStatus(tokenAddr)
+	return status, ethTxHash, tracerr.Wrap(err)
+}
+
+// SetMaxGasPrice applies a new maximum gas price for ethereum transactions,
+// used to propagate configuration hot reloads to the TxManager
+func (c *Coordinator) SetMaxGasPrice(maxGasPrice *big.Int) {
+	c.txManager.SetMaxGasPrice(maxGasPrice)
+}
+
 func (c *Coordinator) newPipeline(ctx context.Context) (*Pipeline, error) {
 	c.pipelineNum++
 	return NewPipeline(ctx, c.cfg, c.pipelineNum, c.historyDB, c.l2DB, c.txSelector,
@@ -395,15 +519,21 @@ func canForge(auctionConstants *common.AuctionConstants, auctionVars *common.Auc
 	return false
 }
 
+// allowedToForge returns false when the HA failover pair mode is enabled and
+// this instance is not holding the leader lease
+func (c *Coordinator) allowedToForge() bool {
+	return c.leader == nil || c.leader.IsLeader()
+}
+
 func (c *Coordinator) canForgeAt(blockNum int64) bool {
-	return canForge(&c.consts.Auction, &c.vars.Auction,
+	return c.allowedToForge() && canForge(&c.consts.Auction, &c.vars.Auction,
 		&c.stats.Sync.Auction.CurrentSlot, &c.stats.Sync.Auction.NextSlot,
 		c.cfg.ForgerAddress, blockNum, c.cfg.MustForgeAtSlotDeadline)
 }
 
 func (c *Coordinator) canForge() bool {
 	blockNum := c.stats.Eth.LastBlock.Num + 1
-	return canForge(&c.consts.Auction, &c.vars.Auction,
+	return c.allowedToForge() && canForge(&c.consts.Auction, &c.vars.Auction,
 		&c.stats.Sync.Auction.CurrentSlot, &c.stats.Sync.Auction.NextSlot,
 		c.cfg.ForgerAddress, blockNum, c.cfg.MustForgeAtSlotDeadline)
 }
@@ -441,10 +571,15 @@ func (c *Coordinator) syncStats(ctx context.Context, stats *synchronizer.Stats)
 			// different than in the previous call to l2DB.Reorg,
 			// or in case the node was restarted when there was a
 			// started batch that included l2txs but was not mined.
-			if err := c.l2DB.Reorg(fromBatch.BatchNum); err != nil {
+			restored, err := c.l2DB.Reorg(fromBatch.BatchNum)
+			if err != nil {
 				return tracerr.Wrap(err)
 			}
-			var err error
+			if restored > 0 {
+				log.Infow("Coordinator: moved pool txs back to pending "+
+					"before starting pipeline", "restored", restored,
+					"batch", fromBatch.BatchNum)
+			}
 			if c.pipeline, err = c.newPipeline(ctx); err != nil {
 				return tracerr.Wrap(err)
 			}
@@ -471,6 +606,12 @@ func (c *Coordinator) syncStats(ctx context.Context, stats *synchronizer.Stats)
 			int64(stats.Sync.LastBatch.BatchNum)); err != nil {
 			return tracerr.Wrap(err)
 		}
+		// With no pipeline running, nothing else moves txs out of the
+		// forging states, so repair the ones left behind by a crash
+		if _, err := c.purger.ReconcileMaybe(c.l2DB, stats.Sync.LastBlock.Num,
+			int64(stats.Sync.LastBatch.BatchNum)); err != nil {
+			return tracerr.Wrap(err)
+		}
 	}
 	return nil
 }
@@ -504,9 +645,21 @@ func (c *Coordinator) handleMsgSyncBlock(ctx context.Context, msg *MsgSyncBlock)
 				return err
 			}
 		} else {
-			if err := c.l2DB.Reorg(lastValidBatch); err != nil {
+			restored, err := c.l2DB.Reorg(lastValidBatch)
+			if err != nil {
 				return err
 			}
+			if restored > 0 {
+				log.Infow("Coordinator: moved pool txs back to pending "+
+					"after syncing external batches", "restored", restored,
+					"batch", lastValidBatch)
+				if err := c.purger.InvalidateAfterReorg(c.l2DB,
+					c.txSelector.LocalAccountsDB(),
+					c.stats.Sync.LastBlock.Num,
+					int64(lastValidBatch)); err != nil {
+					return tracerr.Wrap(err)
+				}
+			}
 		}
 	}
 
@@ -557,9 +710,19 @@ func (c *Coordinator) handleStopPipeline(ctx context.Context, reason string,
 		c.pipeline.Stop(c.ctx)
 		c.pipeline = nil
 	}
-	if err := c.l2DB.Reorg(batchNum); err != nil {
+	restored, err := c.l2DB.Reorg(batchNum)
+	if err != nil {
 		return tracerr.Wrap(err)
 	}
+	if restored > 0 {
+		log.Infow("Coordinator: moved pool txs back to pending after discarding "+
+			"batches", "restored", restored, "batch", batchNum)
+		if err := c.purger.InvalidateAfterReorg(c.l2DB, c.txSelector.LocalAccountsDB(),
+			c.stats.Sync.LastBlock.Num, int64(batchNum)); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+
 	c.lastNonFailedBatchNum = batchNum
 	return nil
 }
@@ -591,11 +754,25 @@ func (c *Coordinator) Start() {
 		log.Fatal("Coordinator already started")
 	}
 	c.started = true
+	if c.leader != nil {
+		c.wg.Add(1)
+		go func() {
+			c.leader.Run(c.ctx)
+			c.wg.Done()
+		}()
+	}
 	c.wg.Add(1)
 	go func() {
 		c.txManager.Run(c.ctx)
 		c.wg.Done()
 	}()
+	if c.withdrawRelayer != nil {
+		c.wg.Add(1)
+		go func() {
+			c.withdrawRelayer.Run(c.ctx)
+			c.wg.Done()
+		}()
+	}
 
 	c.wg.Add(1)
 	go func() {
@@ -0,0 +1,77 @@
+package coordinator
+
+import (
+	"fmt"
+	"time"
+)
+
+// ForgePolicyCfg configures the batch trigger policy.  When enabled, a batch
+// is forged as soon as any of the configured trigger conditions is met,
+// instead of following the fixed ForgeDelay cadence.  Conditions with a zero
+// value are disabled.
+type ForgePolicyCfg struct {
+	// Enabled enables the batch trigger policy
+	Enabled bool
+	// MinPoolTxs triggers a forge when the number of pending txs in the
+	// pool reaches this value
+	MinPoolTxs int
+	// MinFeesUSD triggers a forge when the accumulated fees in USD of the
+	// pending txs in the pool reach this value
+	MinFeesUSD float64
+	// L1TimeoutBlocks triggers a forge when the number of remaining
+	// blocks until the L1L2 batch timeout is this value or less
+	L1TimeoutBlocks int64
+	// MaxInterval triggers a forge when this interval has passed since
+	// the last forged batch, regardless of the pool contents
+	MaxInterval time.Duration
+}
+
+// ForgePolicyInputs are the observed values over which the batch trigger
+// policy is evaluated
+type ForgePolicyInputs struct {
+	// PoolTxs is the number of pending txs in the pool
+	PoolTxs int
+	// PoolFeesUSD is the accumulated fees in USD of the pending txs in
+	// the pool
+	PoolFeesUSD float64
+	// BlocksToL1Timeout is the number of remaining blocks until the L1L2
+	// batch timeout
+	BlocksToL1Timeout int64
+	// LastForgeTime is the time at which the last batch was forged
+	LastForgeTime time.Time
+}
+
+// ForgePolicy evaluates the batch trigger policy over observed pool, L1
+// timeout and clock inputs.  The clock is injected so that the evaluation can
+// be unit-tested with simulated inputs.
+type ForgePolicy struct {
+	cfg ForgePolicyCfg
+	now func() time.Time
+}
+
+// NewForgePolicy creates a ForgePolicy using the real clock
+func NewForgePolicy(cfg ForgePolicyCfg) *ForgePolicy {
+	return &ForgePolicy{cfg: cfg, now: time.Now}
+}
+
+// ShouldForge returns true and the trigger reason when, following the
+// policy, a batch should be forged given the inputs
+func (f *ForgePolicy) ShouldForge(inputs ForgePolicyInputs) (bool, string) {
+	if f.cfg.MinPoolTxs > 0 && inputs.PoolTxs >= f.cfg.MinPoolTxs {
+		return true, fmt.Sprintf("pool txs (%v) >= cfg.MinPoolTxs (%v)",
+			inputs.PoolTxs, f.cfg.MinPoolTxs)
+	}
+	if f.cfg.MinFeesUSD > 0 && inputs.PoolFeesUSD >= f.cfg.MinFeesUSD {
+		return true, fmt.Sprintf("pool fees (%v USD) >= cfg.MinFeesUSD (%v)",
+			inputs.PoolFeesUSD, f.cfg.MinFeesUSD)
+	}
+	if f.cfg.L1TimeoutBlocks > 0 && inputs.BlocksToL1Timeout <= f.cfg.L1TimeoutBlocks {
+		return true, fmt.Sprintf("blocks to L1L2 timeout (%v) <= cfg.L1TimeoutBlocks (%v)",
+			inputs.BlocksToL1Timeout, f.cfg.L1TimeoutBlocks)
+	}
+	if f.cfg.MaxInterval > 0 && f.now().Sub(inputs.LastForgeTime) >= f.cfg.MaxInterval {
+		return true, fmt.Sprintf("more than cfg.MaxInterval (%v) since last forged batch",
+			f.cfg.MaxInterval)
+	}
+	return false, ""
+}
@@ -0,0 +1,76 @@
+package coordinator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForgePolicyShouldForge(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	newPolicy := func(cfg ForgePolicyCfg) *ForgePolicy {
+		policy := NewForgePolicy(cfg)
+		// Simulated clock
+		policy.now = func() time.Time { return now }
+		return policy
+	}
+	cfg := ForgePolicyCfg{
+		Enabled:         true,
+		MinPoolTxs:      10,
+		MinFeesUSD:      50.0,
+		L1TimeoutBlocks: 5,
+		MaxInterval:     10 * time.Minute,
+	}
+	quietInputs := ForgePolicyInputs{
+		PoolTxs:           3,
+		PoolFeesUSD:       1.0,
+		BlocksToL1Timeout: 100,
+		LastForgeTime:     now.Add(-1 * time.Minute),
+	}
+
+	// No trigger condition met
+	forge, reason := newPolicy(cfg).ShouldForge(quietInputs)
+	assert.False(t, forge)
+	assert.Equal(t, "", reason)
+
+	// Pool reaches MinPoolTxs
+	inputs := quietInputs
+	inputs.PoolTxs = 10
+	forge, reason = newPolicy(cfg).ShouldForge(inputs)
+	assert.True(t, forge)
+	assert.Contains(t, reason, "MinPoolTxs")
+
+	// Accumulated fees reach MinFeesUSD
+	inputs = quietInputs
+	inputs.PoolFeesUSD = 50.0
+	forge, reason = newPolicy(cfg).ShouldForge(inputs)
+	assert.True(t, forge)
+	assert.Contains(t, reason, "MinFeesUSD")
+
+	// L1L2 batch timeout approaches
+	inputs = quietInputs
+	inputs.BlocksToL1Timeout = 5
+	forge, reason = newPolicy(cfg).ShouldForge(inputs)
+	assert.True(t, forge)
+	assert.Contains(t, reason, "L1TimeoutBlocks")
+
+	// MaxInterval since the last forged batch
+	inputs = quietInputs
+	inputs.LastForgeTime = now.Add(-10 * time.Minute)
+	forge, reason = newPolicy(cfg).ShouldForge(inputs)
+	assert.True(t, forge)
+	assert.Contains(t, reason, "MaxInterval")
+
+	// Zero-valued conditions are disabled
+	zeroCfg := ForgePolicyCfg{Enabled: true}
+	inputs = ForgePolicyInputs{
+		PoolTxs:           1000,
+		PoolFeesUSD:       1000.0,
+		BlocksToL1Timeout: 0,
+		LastForgeTime:     now.Add(-24 * time.Hour),
+	}
+	forge, reason = newPolicy(zeroCfg).ShouldForge(inputs)
+	assert.False(t, forge)
+	assert.Equal(t, "", reason)
+}
@@ -0,0 +1,100 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/log"
+)
+
+// HACfg configures the high-availability failover pair mode.  When enabled,
+// two coordinator instances sharing the same PostgreSQL database coordinate
+// through a lease (see l2db.TryAcquireLease): only the instance holding the
+// lease forges, while the standby keeps synchronizing and takes over as soon
+// as the lease expires because the leader missed its heartbeats
+type HACfg struct {
+	// Enabled activates the leader election.  When disabled the
+	// coordinator always behaves as the leader
+	Enabled bool
+	// HolderID identifies this instance in the lease table.  Defaults to
+	// "<hostname>-<pid>" when empty
+	HolderID string
+	// LeaseTTL is the duration after which a non-renewed lease expires
+	// and can be taken over by the standby
+	LeaseTTL time.Duration
+	// HeartbeatInterval is the interval between lease renewal attempts.
+	// It must be shorter than LeaseTTL
+	HeartbeatInterval time.Duration
+}
+
+// leaderElector runs the lease heartbeat loop and exposes whether this
+// coordinator instance is currently the leader
+type leaderElector struct {
+	cfg    HACfg
+	l2DB   *l2db.L2DB
+	leader uint32 // accessed atomically; 1 when this instance holds the lease
+}
+
+func newLeaderElector(cfg HACfg, l2DB *l2db.L2DB) *leaderElector {
+	if cfg.HolderID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "coordinator"
+		}
+		cfg.HolderID = fmt.Sprintf("%v-%v", hostname, os.Getpid())
+	}
+	return &leaderElector{
+		cfg:  cfg,
+		l2DB: l2DB,
+	}
+}
+
+// IsLeader returns whether this instance currently holds the forging lease
+func (le *leaderElector) IsLeader() bool {
+	return atomic.LoadUint32(&le.leader) == 1
+}
+
+func (le *leaderElector) setLeader(leader bool) {
+	was := le.IsLeader()
+	if leader == was {
+		return
+	}
+	if leader {
+		atomic.StoreUint32(&le.leader, 1)
+		log.Infow("Coordinator is now the leader, forging enabled",
+			"holder", le.cfg.HolderID)
+	} else {
+		atomic.StoreUint32(&le.leader, 0)
+		log.Warnw("Coordinator lost the leader lease, forging disabled",
+			"holder", le.cfg.HolderID)
+	}
+}
+
+// Run heartbeats the lease until the context is canceled, releasing it on
+// exit so that the standby can take over without waiting for the expiration
+func (le *leaderElector) Run(ctx context.Context) {
+	for {
+		acquired, err := le.l2DB.TryAcquireLease(le.cfg.HolderID, le.cfg.LeaseTTL)
+		if err != nil {
+			// On error assume the lease is lost: it's better to
+			// stop forging than to risk two leaders
+			log.Errorw("leaderElector.TryAcquireLease", "err", err)
+			acquired = false
+		}
+		le.setLeader(acquired)
+		select {
+		case <-ctx.Done():
+			le.setLeader(false)
+			if err := le.l2DB.ReleaseLease(le.cfg.HolderID); err != nil {
+				log.Errorw("leaderElector.ReleaseLease", "err", err)
+			}
+			log.Info("leaderElector done")
+			return
+		case <-time.After(le.cfg.HeartbeatInterval):
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/metric"
 	"github.com/arnaubennassar/hermez-node/prover"
 	"github.com/arnaubennassar/hermez-node/synchronizer"
+	"github.com/arnaubennassar/hermez-node/trace"
 	"github.com/arnaubennassar/hermez-node/txselector"
 	"github.com/hermeznetwork/tracerr"
 )
@@ -60,6 +61,8 @@ type Pipeline struct {
 	batchBuilder          *batchbuilder.BatchBuilder
 	mutexL2DBUpdateDelete *sync.Mutex
 	purger                *Purger
+	forgePolicy           *ForgePolicy
+	verifierSelector      *VerifierSelector
 
 	stats       synchronizer.Stats
 	vars        common.SCVariables
@@ -112,6 +115,14 @@ func NewPipeline(ctx context.Context,
 	if proversPoolSize == 0 {
 		return nil, tracerr.Wrap(fmt.Errorf("no provers in the pool"))
 	}
+	var verifierSelector *VerifierSelector
+	if len(cfg.Verifiers) > 0 {
+		var err error
+		verifierSelector, err = NewVerifierSelector(cfg.Verifiers)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
 	return &Pipeline{
 		num:                   num,
 		cfg:                   cfg,
@@ -126,6 +137,8 @@ func NewPipeline(ctx context.Context,
 		coord:                 coord,
 		txManager:             txManager,
 		consts:                *scConsts,
+		forgePolicy:           NewForgePolicy(cfg.ForgePolicy),
+		verifierSelector:      verifierSelector,
 		statsVarsCh:           make(chan statsVars, queueLen),
 	}, nil
 }
@@ -206,31 +219,21 @@ func (p *Pipeline) syncSCVars(vars common.SCVariablesPtr) {
 	updateSCVars(&p.vars, vars)
 }
 
-// handleForgeBatch waits for an available proof server, calls p.forgeBatch to
-// forge the batch and get the zkInputs, and then  sends the zkInputs to the
-// selected proof server so that the proof computation begins.
+// handleForgeBatch calls p.forgeBatch to forge the batch and get the
+// zkInputs.  It doesn't require an available proof server: the selection and
+// the ZKInputs are built on top of the local checkpoint of the previous
+// batch, so the next batch can be prepared while the prover is still working
+// on the previous one.
 func (p *Pipeline) handleForgeBatch(ctx context.Context,
 	batchNum common.BatchNum) (batchInfo *BatchInfo, err error) {
-	// 1. Wait for an available serverProof (blocking call)
-	serverProof, err := p.proversPool.Get(ctx)
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	} else if err != nil {
-		log.Errorw("proversPool.Get", "err", err)
-		return nil, tracerr.Wrap(err)
-	}
-	defer func() {
-		// If we encounter any error (notice that this function returns
-		// errors to notify that a batch is not forged not only because
-		// of unexpected errors but also due to benign causes), add the
-		// serverProof back to the pool
-		if err != nil {
-			p.proversPool.Add(ctx, serverProof)
-		}
-	}()
-
-	// 2. Forge the batch internally (make a selection of txs and prepare
-	// all the smart contract arguments)
+	// Correlate all the logs of the batch across modules (see log.WithRequestID)
+	ctx = log.WithRequestID(ctx, fmt.Sprintf("batch-%d", batchNum))
+	// Forge the batch internally (make a selection of txs and prepare
+	// all the smart contract arguments).  The span covers the tx
+	// selection and the ZKInputs building on the local StateDBs
+	ctx, span := trace.StartSpan(ctx, "Pipeline.forgeBatch")
+	span.SetAttribute("batch", batchNum.BigInt().String())
+	defer span.End()
 	var skipReason *string
 	p.mutexL2DBUpdateDelete.Lock()
 	batchInfo, skipReason, err = p.forgeBatch(batchNum)
@@ -239,28 +242,44 @@ func (p *Pipeline) handleForgeBatch(ctx context.Context,
 		return nil, ctx.Err()
 	} else if err != nil {
 		if tracerr.Unwrap(err) == errLastL1BatchNotSynced {
-			log.Warnw("forgeBatch: scheduled L1Batch too early", "err", err,
+			log.WarnwCtx(ctx, "forgeBatch: scheduled L1Batch too early", "err", err,
 				"lastForgeL1TxsNum", p.state.lastForgeL1TxsNum,
 				"syncLastForgeL1TxsNum", p.stats.Sync.LastForgeL1TxsNum)
 		} else {
-			log.Errorw("forgeBatch", "err", err)
+			log.ErrorwCtx(ctx, "forgeBatch", "err", err)
 		}
+		span.SetError(err)
 		return nil, tracerr.Wrap(err)
 	} else if skipReason != nil {
-		log.Debugw("skipping batch", "batch", batchNum, "reason", *skipReason)
+		log.DebugwCtx(ctx, "skipping batch", "batch", batchNum, "reason", *skipReason)
 		return nil, tracerr.Wrap(errSkipBatchByPolicy)
 	}
+	return batchInfo, nil
+}
 
-	// 3. Send the ZKInputs to the proof server
+// dispatchProof waits for an available proof server (blocking call) and sends
+// the ZKInputs of an already forged batch to it so that the proof computation
+// begins
+func (p *Pipeline) dispatchProof(ctx context.Context, batchInfo *BatchInfo) error {
+	serverProof, err := p.proversPool.Get(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		log.Errorw("proversPool.Get", "err", err)
+		return tracerr.Wrap(err)
+	}
 	batchInfo.ServerProof = serverProof
 	batchInfo.ProofStart = time.Now()
 	if err := p.sendServerProof(ctx, batchInfo); ctx.Err() != nil {
-		return nil, ctx.Err()
+		return ctx.Err()
 	} else if err != nil {
 		log.Errorw("sendServerProof", "err", err)
-		return nil, tracerr.Wrap(err)
+		// The proof server hasn't accepted the inputs, add it back to
+		// the pool
+		p.proversPool.Add(ctx, serverProof)
+		return tracerr.Wrap(err)
 	}
-	return batchInfo, nil
+	return nil
 }
 
 // Start the forging pipeline
@@ -274,9 +293,28 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 	if err := p.reset(batchNum, stats, vars); err != nil {
 		return tracerr.Wrap(err)
 	}
+	// Warm start: on the first pipeline start of the process, revalidate
+	// the pool against the state restored by the reset so that txs that
+	// became stale while the node was down are invalidated instead of
+	// selected
+	if p.cfg.WarmStartPool && !p.coord.poolWarmStarted {
+		if err := p.txSelector.WarmStart(batchNum); err != nil {
+			return tracerr.Wrap(err)
+		}
+		p.coord.poolWarmStarted = true
+	}
 	p.ctx, p.cancel = context.WithCancel(context.Background())
 
 	queueSize := 1
+	// The pipeline is split in 3 stages connected by channels: forging
+	// (tx selection + ZKInputs building), proof dispatching and proof
+	// waiting.  Forging works on top of the local checkpoint of the last
+	// forged batch, so with a single prover the batch N+1 is selected and
+	// built while the prover is still computing the proof of batch N.  If
+	// any stage fails, errAtBatchNum stops the earlier stages and the
+	// pool and local state are rolled back when the coordinator resets
+	// the pipeline.
+	batchChForged := make(chan *BatchInfo, queueSize)
 	batchChSentServerProof := make(chan *BatchInfo, queueSize)
 
 	p.wg.Add(1)
@@ -322,7 +360,7 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 
 				p.state.batchNum = batchNum
 				select {
-				case batchChSentServerProof <- batchInfo:
+				case batchChForged <- batchInfo:
 				case <-p.ctx.Done():
 				}
 				if !timer.Stop() {
@@ -333,6 +371,44 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 		}
 	}()
 
+	p.wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-p.ctx.Done():
+				log.Info("Pipeline dispatchProof loop done")
+				p.wg.Done()
+				return
+			case batchInfo := <-batchChForged:
+				// Once errAtBatchNum != 0, we stop dispatching
+				// proofs because there's been an error and we
+				// wait for the pipeline to be stopped.
+				if p.getErrAtBatchNum() != 0 {
+					p.revertPoolChanges(batchInfo.BatchNum)
+					continue
+				}
+				err := p.dispatchProof(p.ctx, batchInfo)
+				if p.ctx.Err() != nil {
+					p.revertPoolChanges(batchInfo.BatchNum)
+					continue
+				} else if err != nil {
+					p.setErrAtBatchNum(batchInfo.BatchNum)
+					p.coord.SendMsg(p.ctx, MsgStopPipeline{
+						Reason: fmt.Sprintf(
+							"Pipeline.dispatchProof: %v", err),
+						FailedBatchNum: batchInfo.BatchNum,
+					})
+					p.revertPoolChanges(batchInfo.BatchNum)
+					continue
+				}
+				select {
+				case batchChSentServerProof <- batchInfo:
+				case <-p.ctx.Done():
+				}
+			}
+		}
+	}()
+
 	p.wg.Add(1)
 	go func() {
 		for {
@@ -346,12 +422,12 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 				// batches because there's been an error and we
 				// wait for the pipeline to be stopped.
 				if p.getErrAtBatchNum() != 0 {
-					p.revertPoolChanges(batchNum)
+					p.revertPoolChanges(batchInfo.BatchNum)
 					continue
 				}
 				err := p.waitServerProof(p.ctx, batchInfo)
 				if p.ctx.Err() != nil {
-					p.revertPoolChanges(batchNum)
+					p.revertPoolChanges(batchInfo.BatchNum)
 					continue
 				} else if err != nil {
 					log.Errorw("waitServerProof", "err", err)
@@ -361,7 +437,7 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 							"Pipeline.waitServerProof: %v", err),
 						FailedBatchNum: batchInfo.BatchNum,
 					})
-					p.revertPoolChanges(batchNum)
+					p.revertPoolChanges(batchInfo.BatchNum)
 					continue
 				}
 				// We are done with this serverProof, add it back to the pool
@@ -376,7 +452,7 @@ func (p *Pipeline) Start(batchNum common.BatchNum,
 // revertPoolChanges will undo changes made to the pool while trying to forge failedBatch.
 // Call this function only if the porcess of forging a batch fails
 func (p *Pipeline) revertPoolChanges(failedBatch common.BatchNum) {
-	if err := p.l2DB.Reorg(failedBatch - 1); err != nil {
+	if _, err := p.l2DB.Reorg(failedBatch - 1); err != nil {
 		// NOTE: the reason why this error si not returned is that this function is used in a error handling situation
 		// and at this point the flow shouldn't change (handling the error of handling an error), things could get really meesy
 		log.Error("Error trying to revert changes on the pool after the porcess of forging a batch failed: ", err)
@@ -404,10 +480,14 @@ func (p *Pipeline) Stop(ctx context.Context) {
 // sendServerProof sends the circuit inputs to the proof server
 func (p *Pipeline) sendServerProof(ctx context.Context, batchInfo *BatchInfo) error {
 	p.cfg.debugBatchStore(batchInfo)
+	ctx, span := trace.StartSpan(ctx, "Pipeline.sendServerProof")
+	span.SetAttribute("batch", batchInfo.BatchNum.BigInt().String())
+	defer span.End()
 
 	// 7. Call the selected idle server proof with BatchBuilder output,
 	// save server proof info for batchNum
 	if err := batchInfo.ServerProof.CalculateProof(ctx, batchInfo.ZKInputs); err != nil {
+		span.SetError(err)
 		return tracerr.Wrap(err)
 	}
 	return nil
@@ -439,6 +519,31 @@ func (p *Pipeline) forgePolicySkipPreSelection(now time.Time) (bool, string) {
 		return false, ""
 	}
 
+	// If the batch trigger policy is enabled, forge only when one of its
+	// trigger conditions is met, instead of following the fixed
+	// ForgeDelay cadence
+	if p.cfg.ForgePolicy.Enabled {
+		poolTxs, poolFeesUSD, err := p.l2DB.GetPendingTxsStats()
+		if err != nil {
+			// Fall back to the fixed ForgeDelay cadence if the
+			// pool can't be queried
+			log.Errorw("forge policy: l2DB.GetPendingTxsStats", "err", err)
+		} else {
+			forge, reason := p.forgePolicy.ShouldForge(ForgePolicyInputs{
+				PoolTxs:           poolTxs,
+				PoolFeesUSD:       poolFeesUSD,
+				BlocksToL1Timeout: p.blocksToL1Timeout(),
+				LastForgeTime:     p.lastForgeTime,
+			})
+			if forge {
+				log.Debugw("Pipeline: forge policy triggered",
+					"batch", p.state.batchNum+1, "reason", reason)
+				return false, ""
+			}
+			return true, "forge policy: no trigger condition met"
+		}
+	}
+
 	// If we haven't reached the ForgeDelay, skip forging the batch
 	if now.Sub(p.lastForgeTime) < p.cfg.ForgeDelay {
 		return true, "we haven't reached the forge delay"
@@ -446,6 +551,18 @@ func (p *Pipeline) forgePolicySkipPreSelection(now time.Time) (bool, string) {
 	return false, ""
 }
 
+// blocksToL1Timeout returns the number of remaining ethereum blocks until the
+// deadline at which an L1L2 batch would be scheduled (see shouldL1L2Batch)
+func (p *Pipeline) blocksToL1Timeout() int64 {
+	lastL1BatchBlockNum := p.state.lastScheduledL1BatchBlockNum
+	if p.stats.Sync.LastL1BatchBlock > lastL1BatchBlockNum {
+		lastL1BatchBlockNum = p.stats.Sync.LastL1BatchBlock
+	}
+	deadline := int64(float64(p.vars.Rollup.ForgeL1L2BatchTimeout-1) *
+		p.cfg.L1BatchTimeoutPerc)
+	return deadline - (p.stats.Eth.LastBlock.Num + 1 - lastL1BatchBlockNum)
+}
+
 // forgePolicySkipPostSelection is called after doing a tx selection in a batch to
 // determine by policy if we should forge the batch or not.  Returns true and
 // the reason when the forging of the batch must be skipped.
@@ -533,6 +650,14 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 		return nil, &reason, nil
 	}
 
+	// When multiple verifiers are available, run the selection with the
+	// MaxTx of the biggest circuit; the verifier of the batch is chosen
+	// after the selection (see below)
+	txProcessorConfig := p.cfg.TxProcessorConfig
+	if p.verifierSelector != nil {
+		txProcessorConfig.MaxTx = uint32(p.verifierSelector.MaxTx())
+	}
+
 	// 1. Decide if we forge L2Tx or L1+L2Tx
 	if p.shouldL1L2Batch(batchInfo) {
 		batchInfo.L1Batch = true
@@ -553,7 +678,7 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 		}
 
 		coordIdxs, auths, l1UserTxs, l1CoordTxs, poolL2Txs, discardedL2Txs, err =
-			p.txSelector.GetL1L2TxSelection(p.cfg.TxProcessorConfig, _l1UserTxs, l1UserFutureTxs)
+			p.txSelector.GetL1L2TxSelection(txProcessorConfig, _l1UserTxs, l1UserFutureTxs)
 		if err != nil {
 			return nil, nil, tracerr.Wrap(err)
 		}
@@ -567,7 +692,7 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 
 		// 2b: only L2 txs
 		coordIdxs, auths, l1CoordTxs, poolL2Txs, discardedL2Txs, err =
-			p.txSelector.GetL2TxSelection(p.cfg.TxProcessorConfig, l1UserFutureTxs)
+			p.txSelector.GetL2TxSelection(txProcessorConfig, l1UserFutureTxs)
 		if err != nil {
 			return nil, nil, tracerr.Wrap(err)
 		}
@@ -595,6 +720,15 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 	batchInfo.L1CoordinatorTxsAuths = auths
 	batchInfo.CoordIdxs = coordIdxs
 	batchInfo.VerifierIdx = p.cfg.VerifierIdx
+	if p.verifierSelector != nil {
+		nTxs := len(l1UserTxs) + len(l1CoordTxs) + len(poolL2Txs)
+		verifier, netUSD := p.verifierSelector.Select(nTxs, poolL2TxsFeesUSD(poolL2Txs))
+		batchInfo.VerifierIdx = verifier.Idx
+		txProcessorConfig.MaxTx = uint32(verifier.MaxTx)
+		log.Debugw("Pipeline: verifier chosen for batch", "batch", batchInfo.BatchNum,
+			"verifierIdx", verifier.Idx, "maxTx", verifier.MaxTx, "nTxs", nTxs,
+			"netUSD", netUSD)
+	}
 
 	if err := p.l2DB.StartForging(common.TxIDsFromPoolL2Txs(poolL2Txs),
 		batchInfo.BatchNum); err != nil {
@@ -604,6 +738,10 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 		return nil, nil, tracerr.Wrap(err)
 	}
 
+	// Generate L1 ForceExits for accounts whose exit txs keep failing
+	// selection.  Failures here don't affect the forging of the batch
+	p.forceStuckExits()
+
 	// Invalidate transactions that become invalid because of
 	// the poolL2Txs selected.  Will mark as invalid the txs that have a
 	// (fromIdx, nonce) which already appears in the selected txs (includes
@@ -615,7 +753,7 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 
 	// 4. Call BatchBuilder with TxSelector output
 	configBatch := &batchbuilder.ConfigBatch{
-		TxProcessorConfig: p.cfg.TxProcessorConfig,
+		TxProcessorConfig: txProcessorConfig,
 	}
 	zkInputs, err := p.batchBuilder.BuildBatch(coordIdxs, configBatch, l1UserTxs,
 		l1CoordTxs, poolL2Txs)
@@ -632,8 +770,23 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 	batchInfo.ZKInputs = zkInputs
 	batchInfo.Debug.Status = StatusForged
 	p.cfg.debugBatchStore(batchInfo)
+	p.cfg.archiveZKInputs(batchInfo.BatchNum, zkInputs)
 	log.Infow("Pipeline: batch forged internally", "batch", batchInfo.BatchNum)
 
+	// Record which Coordinator fee account was used to forge the batch.
+	// The CoordAccount can be rotated at runtime, so the recording can't
+	// be derived from the configuration.  A failure here doesn't affect
+	// the forging itself
+	coordAccount := p.txSelector.CoordAccount()
+	if err := p.historyDB.AddBatchCoordAccount(&historydb.BatchCoordAccount{
+		BatchNum: batchInfo.BatchNum,
+		Addr:     coordAccount.Addr,
+		BJJ:      coordAccount.BJJ,
+	}); err != nil {
+		log.Warnw("Pipeline: historyDB.AddBatchCoordAccount",
+			"err", err, "batch", batchInfo.BatchNum)
+	}
+
 	p.state.lastSlotForged = p.stats.Sync.Auction.CurrentSlot.SlotNum
 
 	return batchInfo, nil, nil
@@ -643,12 +796,28 @@ func (p *Pipeline) forgeBatch(batchNum common.BatchNum) (batchInfo *BatchInfo,
 func (p *Pipeline) waitServerProof(ctx context.Context, batchInfo *BatchInfo) error {
 	defer metric.MeasureDuration(metric.WaitServerProof, batchInfo.ProofStart,
 		batchInfo.BatchNum.BigInt().String(), strconv.Itoa(batchInfo.PipelineNum))
+	ctx, span := trace.StartSpan(ctx, "Pipeline.waitServerProof")
+	span.SetAttribute("batch", batchInfo.BatchNum.BigInt().String())
+	defer span.End()
 
 	proof, pubInputs, err := batchInfo.ServerProof.GetProof(ctx) // blocking call,
 	// until not resolved don't continue. Returns when the proof server has calculated the proof
 	if err != nil {
+		span.SetError(err)
 		return tracerr.Wrap(err)
 	}
+	if vk, ok := p.cfg.ProofVerifiers[batchInfo.VerifierIdx]; ok {
+		if err := vk.Verify(proof, pubInputs); err != nil {
+			log.Errorw("Pipeline: proof server returned an invalid proof",
+				"batch", batchInfo.BatchNum, "err", err)
+			proof, pubInputs, err = p.redispatchProof(ctx, batchInfo, vk)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			} else if err != nil {
+				return tracerr.Wrap(err)
+			}
+		}
+	}
 	batchInfo.Proof = proof
 	batchInfo.PublicInputs = pubInputs
 	batchInfo.ForgeBatchArgs = prepareForgeBatchArgs(batchInfo)
@@ -658,6 +827,34 @@ func (p *Pipeline) waitServerProof(ctx context.Context, batchInfo *BatchInfo) er
 	return nil
 }
 
+// redispatchProof discards the prover that generated an invalid proof for
+// batchInfo and dispatches the same ZKInputs to another prover from the
+// pool, waiting for the new proof and verifying it again.  The discarded
+// prover is not returned to the pool.
+func (p *Pipeline) redispatchProof(ctx context.Context, batchInfo *BatchInfo,
+	vk *prover.VerifyingKey) (*prover.Proof, []*big.Int, error) {
+	log.Warnw("Pipeline: discarding prover after invalid proof, "+
+		"re-dispatching batch to another prover", "batch", batchInfo.BatchNum)
+	serverProof, err := p.proversPool.Get(ctx)
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	} else if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	batchInfo.ServerProof = serverProof
+	if err := serverProof.CalculateProof(ctx, batchInfo.ZKInputs); err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	proof, pubInputs, err := serverProof.GetProof(ctx)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	if err := vk.Verify(proof, pubInputs); err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	return proof, pubInputs, nil
+}
+
 func (p *Pipeline) shouldL1L2Batch(batchInfo *BatchInfo) bool {
 	// Take the lastL1BatchBlockNum as the biggest between the last
 	// scheduled one, and the synchronized one.
@@ -701,3 +898,43 @@ func prepareForgeBatchArgs(batchInfo *BatchInfo) *eth.RollupForgeBatchArgs {
 		ProofC: [2]*big.Int{proof.PiC[0], proof.PiC[1]},
 	}
 }
+
+// forceStuckExits generates L1 ForceExit transactions on behalf of the
+// accounts reported by the TxSelector as having their exit txs repeatedly
+// discarded.  The generated txs are regular L1UserTxs sent from the forger
+// address, so they will be forged in a future L1Batch once the smart contract
+// queue that includes them is frozen
+func (p *Pipeline) forceStuckExits() {
+	cfg := p.cfg.ForcedExits
+	if !cfg.Enabled || cfg.MaxFailedSelections <= 0 {
+		return
+	}
+	idxs := p.txSelector.StuckExitAccounts(cfg.MaxFailedSelections)
+	if cfg.MaxPerScan > 0 && len(idxs) > cfg.MaxPerScan {
+		idxs = idxs[:cfg.MaxPerScan]
+	}
+	for _, idx := range idxs {
+		acc, err := p.txSelector.LocalAccountsDB().GetAccount(idx)
+		if err != nil {
+			log.Errorw("forceStuckExits: LocalAccountsDB().GetAccount",
+				"err", err, "idx", idx)
+			continue
+		}
+		if acc.Balance.Sign() == 0 {
+			continue
+		}
+		// A ForceExit is an L1UserTx with toIdx = 1 (the exit tree) and
+		// no deposit, moving the full balance of the account to the
+		// exit tree
+		ethTx, err := p.txManager.ethClient.RollupL1UserTxERC20ETH(
+			acc.BJJ, int64(idx), big.NewInt(0), acc.Balance,
+			uint32(acc.TokenID), 1)
+		if err != nil {
+			log.Errorw("forceStuckExits: RollupL1UserTxERC20ETH",
+				"err", err, "idx", idx)
+			continue
+		}
+		log.Infow("forceStuckExits: forced exit queued", "idx", idx,
+			"amount", acc.Balance, "ethTx", ethTx.Hash())
+	}
+}
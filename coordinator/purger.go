@@ -40,6 +40,8 @@ type Purger struct {
 	lastPurgeBatch      int64
 	lastInvalidateBlock int64
 	lastInvalidateBatch int64
+	lastReconcileBlock  int64
+	lastReconcileBatch  int64
 }
 
 // CanPurge returns true if it's a good time to purge according to the
@@ -78,6 +80,42 @@ func (p *Purger) PurgeMaybe(l2DB *l2db.L2DB, blockNum, batchNum int64) (bool, er
 	return true, tracerr.Wrap(err)
 }
 
+// CanReconcile returns true if it's a good time to reconcile the forging
+// state of the pool, reusing the purge delay configuration
+func (p *Purger) CanReconcile(blockNum, batchNum int64) bool {
+	if blockNum >= p.lastReconcileBlock+p.cfg.PurgeBlockDelay {
+		return true
+	}
+	if batchNum >= p.lastReconcileBatch+p.cfg.PurgeBatchDelay {
+		return true
+	}
+	return false
+}
+
+// ReconcileMaybe repairs the forging state of the pool if it's a good time
+// to do so.  Txs left in forging or forged state by a crash between
+// StartForging and DoneForging are cross-checked against the synced batches
+// and either confirmed as forged or returned to pending (see
+// l2db.ReconcileForgingState).  batchNum must be the last synced batch
+func (p *Purger) ReconcileMaybe(l2DB *l2db.L2DB, blockNum, batchNum int64) (bool, error) {
+	if !p.CanReconcile(blockNum, batchNum) {
+		return false, nil
+	}
+	p.lastReconcileBlock = blockNum
+	p.lastReconcileBatch = batchNum
+	log.Debugw("Purger: reconciling pool forging state", "block", blockNum, "batch", batchNum)
+	recoveredForged, orphaned, err := l2DB.ReconcileForgingState(common.BatchNum(batchNum))
+	if err != nil {
+		return true, tracerr.Wrap(err)
+	}
+	if recoveredForged > 0 || orphaned > 0 {
+		log.Warnw("Purger: repaired inconsistent pool forging states",
+			"recoveredForged", recoveredForged, "orphaned", orphaned,
+			"batch", batchNum)
+	}
+	return true, nil
+}
+
 // InvalidateMaybe invalidates txs if it's a good time to do so
 func (p *Purger) InvalidateMaybe(l2DB *l2db.L2DB, stateDB *statedb.LocalStateDB,
 	blockNum, batchNum int64) (bool, error) {
@@ -87,10 +125,26 @@ func (p *Purger) InvalidateMaybe(l2DB *l2db.L2DB, stateDB *statedb.LocalStateDB,
 	p.lastInvalidateBlock = blockNum
 	p.lastInvalidateBatch = batchNum
 	log.Debugw("Purger: invalidating l2txs in pool", "block", blockNum, "batch", batchNum)
+	if err := l2DB.InvalidateExpiredMaxNumBatch(common.BatchNum(batchNum)); err != nil {
+		return true, tracerr.Wrap(err)
+	}
 	err := poolMarkInvalidOldNonces(l2DB, stateDB, common.BatchNum(batchNum))
 	return true, tracerr.Wrap(err)
 }
 
+// InvalidateAfterReorg re-checks the nonces of the pending txs right after a
+// blockchain reorg has restored txs to the pool, bypassing the invalidation
+// delay configuration so that restored txs that no longer apply to the rolled
+// back state are marked invalid before the next selection
+func (p *Purger) InvalidateAfterReorg(l2DB *l2db.L2DB, stateDB *statedb.LocalStateDB,
+	blockNum, batchNum int64) error {
+	p.lastInvalidateBlock = blockNum
+	p.lastInvalidateBatch = batchNum
+	log.Debugw("Purger: invalidating l2txs in pool after reorg", "block", blockNum,
+		"batch", batchNum)
+	return tracerr.Wrap(poolMarkInvalidOldNonces(l2DB, stateDB, common.BatchNum(batchNum)))
+}
+
 //nolint:unused,deadcode
 func idxsNonceFromL2Txs(txs []common.L2Tx) []common.IdxNonce {
 	idxNonceMap := map[common.Idx]common.Nonce{}
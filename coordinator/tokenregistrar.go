@@ -0,0 +1,169 @@
+package coordinator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/eth"
+	"github.com/arnaubennassar/hermez-node/log"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Token registration statuses
+const (
+	// TokenRegistrationStatusSent is the status of a registration whose
+	// AddToken ethereum transaction has been submitted but not mined yet
+	TokenRegistrationStatusSent = "sent"
+	// TokenRegistrationStatusConfirmed is the status of a registration
+	// whose AddToken ethereum transaction has been mined but whose
+	// AddToken event has not been synchronized yet
+	TokenRegistrationStatusConfirmed = "confirmed"
+	// TokenRegistrationStatusFailed is the status of a registration whose
+	// AddToken ethereum transaction reverted
+	TokenRegistrationStatusFailed = "failed"
+	// TokenRegistrationStatusSynced is the status of a registration whose
+	// AddToken event has been synchronized, so the token is usable
+	TokenRegistrationStatusSynced = "synced"
+)
+
+// addTokenDeadline is the validity period of the HEZ permit signed to pay the
+// feeAddToken of an AddToken call
+const addTokenDeadline = 1 * time.Hour
+
+// tokenRegistration tracks a submitted AddToken ethereum transaction
+type tokenRegistration struct {
+	ethTxHash ethCommon.Hash
+	status    string
+}
+
+// TokenRegistrar implements the RollupAddToken admin workflow: it checks that
+// the token is not registered yet, that the HEZ balance of the forger account
+// covers the current feeAddToken, submits the AddToken call (paying the fee
+// through a HEZ permit) and tracks the registration until the AddToken event
+// is synchronized
+type TokenRegistrar struct {
+	historyDB  *historydb.HistoryDB
+	ethClient  eth.ClientInterface
+	forgerAddr ethCommon.Address
+
+	mutex         sync.Mutex
+	registrations map[ethCommon.Address]*tokenRegistration
+}
+
+// NewTokenRegistrar creates a new TokenRegistrar
+func NewTokenRegistrar(historyDB *historydb.HistoryDB, ethClient eth.ClientInterface,
+	forgerAddr ethCommon.Address) *TokenRegistrar {
+	return &TokenRegistrar{
+		historyDB:     historyDB,
+		ethClient:     ethClient,
+		forgerAddr:    forgerAddr,
+		registrations: make(map[ethCommon.Address]*tokenRegistration),
+	}
+}
+
+// RegisterToken submits the registration of a token in the Rollup smart
+// contract, returning the hash of the AddToken ethereum transaction.  Calling
+// it again for a token whose registration is in flight returns the hash of
+// the already submitted transaction
+func (t *TokenRegistrar) RegisterToken(tokenAddr ethCommon.Address) (ethCommon.Hash, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if reg, ok := t.registrations[tokenAddr]; ok &&
+		reg.status != TokenRegistrationStatusFailed {
+		return reg.ethTxHash, nil
+	}
+	// Check that the token is not registered yet.  The synced token list
+	// is only authoritative when it matches the on chain registered
+	// tokens count: if the chain is ahead, a registration (maybe this
+	// same token) is still being synchronized
+	if _, err := t.historyDB.GetTokenByEthAddr(tokenAddr); err == nil {
+		return ethCommon.Hash{}, tracerr.Wrap(
+			fmt.Errorf("token %s is already registered", tokenAddr))
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	registerCount, err := t.ethClient.RollupRegisterTokensCount()
+	if err != nil {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	syncedCount, err := t.historyDB.GetTokensCount()
+	if err != nil {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	if registerCount.Int64() != syncedCount {
+		return ethCommon.Hash{}, tracerr.Wrap(fmt.Errorf(
+			"there are token registrations pending to be synchronized "+
+				"(registered: %v, synced: %v), try again later",
+			registerCount, syncedCount))
+	}
+	// Fetch the current feeAddToken and check that the forger account
+	// holds enough HEZ to pay it
+	rollupVars, _, _, err := t.historyDB.GetSCVars()
+	if err != nil {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	feeAddToken := rollupVars.FeeAddToken
+	balance, err := t.ethClient.RollupTokenHEZBalance(t.forgerAddr)
+	if err != nil {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	if balance.Cmp(feeAddToken) < 0 {
+		return ethCommon.Hash{}, tracerr.Wrap(fmt.Errorf(
+			"not enough HEZ to pay the feeAddToken: balance %v, fee %v",
+			balance, feeAddToken))
+	}
+	deadline := big.NewInt(time.Now().Add(addTokenDeadline).Unix())
+	tx, err := t.ethClient.RollupAddToken(tokenAddr, feeAddToken, deadline)
+	if err != nil {
+		return ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	t.registrations[tokenAddr] = &tokenRegistration{
+		ethTxHash: tx.Hash(),
+		status:    TokenRegistrationStatusSent,
+	}
+	log.Infow("TokenRegistrar: AddToken submitted", "token", tokenAddr,
+		"feeAddToken", feeAddToken, "ethTxHash", tx.Hash())
+	return tx.Hash(), nil
+}
+
+// TokenRegistrationStatus returns the status of the registration of a token
+// and the hash of its AddToken ethereum transaction, when known.  Tokens
+// found in the synced token list report the synced status even if they were
+// not registered through this node; unknown tokens return sql.ErrNoRows
+func (t *TokenRegistrar) TokenRegistrationStatus(
+	tokenAddr ethCommon.Address) (string, ethCommon.Hash, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	reg := t.registrations[tokenAddr]
+	if _, err := t.historyDB.GetTokenByEthAddr(tokenAddr); err == nil {
+		ethTxHash := ethCommon.Hash{}
+		if reg != nil {
+			ethTxHash = reg.ethTxHash
+			reg.status = TokenRegistrationStatusSynced
+		}
+		return TokenRegistrationStatusSynced, ethTxHash, nil
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return "", ethCommon.Hash{}, tracerr.Wrap(err)
+	}
+	if reg == nil {
+		return "", ethCommon.Hash{}, tracerr.Wrap(sql.ErrNoRows)
+	}
+	if reg.status == TokenRegistrationStatusSent {
+		receipt, err := t.ethClient.EthTransactionReceipt(context.Background(),
+			reg.ethTxHash)
+		if err == nil && receipt != nil {
+			if receipt.Status == 0 {
+				reg.status = TokenRegistrationStatusFailed
+			} else {
+				reg.status = TokenRegistrationStatusConfirmed
+			}
+		}
+	}
+	return reg.status, reg.ethTxHash, nil
+}
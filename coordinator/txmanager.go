@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
@@ -13,6 +15,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/etherscan"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/arnaubennassar/hermez-node/synchronizer"
+	"github.com/arnaubennassar/hermez-node/trace"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -42,6 +45,10 @@ type TxManager struct {
 
 	discardPipelineCh chan int // int refers to the pipelineNum
 
+	// mutexMaxGasPrice protects cfg.MaxGasPrice, which can be updated at
+	// runtime via SetMaxGasPrice by a configuration hot reload
+	mutexMaxGasPrice sync.RWMutex
+
 	minPipelineNum int
 	queue          Queue
 	// lastSuccessBatch stores the last BatchNum that who's forge call was confirmed
@@ -128,6 +135,21 @@ func (t *TxManager) syncSCVars(vars common.SCVariablesPtr) {
 	updateSCVars(&t.vars, vars)
 }
 
+// SetMaxGasPrice updates the maximum gas price allowed for ethereum
+// transactions.  It is safe to call from other goroutines, and is used to
+// apply configuration hot reloads.
+func (t *TxManager) SetMaxGasPrice(maxGasPrice *big.Int) {
+	t.mutexMaxGasPrice.Lock()
+	defer t.mutexMaxGasPrice.Unlock()
+	t.cfg.MaxGasPrice = maxGasPrice
+}
+
+func (t *TxManager) maxGasPrice() *big.Int {
+	t.mutexMaxGasPrice.RLock()
+	defer t.mutexMaxGasPrice.RUnlock()
+	return t.cfg.MaxGasPrice
+}
+
 // NewAuth generates a new auth object for an ethereum transaction
 func (t *TxManager) NewAuth(ctx context.Context, batchInfo *BatchInfo) (*bind.TransactOpts, error) {
 	// First we try getting the gas price from etherscan. Later we get the gas price from the ethereum node.
@@ -192,7 +214,7 @@ func (t *TxManager) NewAuth(ctx context.Context, batchInfo *BatchInfo) (*bind.Tr
 		gasPrice.Add(gasPrice, inc)
 	}
 
-	auth, err := bind.NewKeyStoreTransactorWithChainID(t.ethClient.EthKeyStore(), t.account, t.chainID)
+	auth, err := t.ethClient.EthTransactor()
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -248,6 +270,14 @@ func addPerc(v *big.Int, p int64) *big.Int {
 
 func (t *TxManager) sendRollupForgeBatch(ctx context.Context, batchInfo *BatchInfo,
 	resend bool) error {
+	// Correlate the eth calls of the batch with the rest of its logs
+	ctx = log.WithRequestID(ctx, fmt.Sprintf("batch-%d", batchInfo.BatchNum))
+	// The span covers the eth RPC calls of the forge, including the
+	// gas price bump retries
+	ctx, span := trace.StartSpan(ctx, "TxManager.RollupForgeBatch")
+	span.SetAttribute("batch", batchInfo.BatchNum.BigInt().String())
+	span.SetAttribute("resend", strconv.FormatBool(resend))
+	defer span.End()
 	var ethTx *types.Transaction
 	var err error
 	var auth *bind.TransactOpts
@@ -264,9 +294,9 @@ func (t *TxManager) sendRollupForgeBatch(ctx context.Context, batchInfo *BatchIn
 	}
 	auth.Context = ctx
 	for attempt := 0; attempt < t.cfg.EthClientAttempts; attempt++ {
-		if auth.GasPrice.Cmp(t.cfg.MaxGasPrice) > 0 {
+		if maxGasPrice := t.maxGasPrice(); auth.GasPrice.Cmp(maxGasPrice) > 0 {
 			return tracerr.Wrap(fmt.Errorf("calculated gasPrice (%v) > maxGasPrice (%v)",
-				auth.GasPrice, t.cfg.MaxGasPrice))
+				auth.GasPrice, maxGasPrice))
 		}
 		// RollupForgeBatch() calls ethclient.SendTransaction()
 		ethTx, err = t.ethClient.RollupForgeBatch(batchInfo.ForgeBatchArgs, auth)
@@ -276,27 +306,27 @@ func (t *TxManager) sendRollupForgeBatch(ctx context.Context, batchInfo *BatchIn
 		if err == nil {
 			break
 		} else if strings.Contains(err.Error(), core.ErrNonceTooLow.Error()) {
-			log.Warnw("TxManager ethClient.RollupForgeBatch incrementing nonce",
+			log.WarnwCtx(ctx, "TxManager ethClient.RollupForgeBatch incrementing nonce",
 				"err", err, "nonce", auth.Nonce, "batchNum", batchInfo.BatchNum)
 			auth.Nonce.Add(auth.Nonce, big.NewInt(1))
 			attempt--
 		} else if strings.Contains(err.Error(), core.ErrNonceTooHigh.Error()) {
-			log.Warnw("TxManager ethClient.RollupForgeBatch decrementing nonce",
+			log.WarnwCtx(ctx, "TxManager ethClient.RollupForgeBatch decrementing nonce",
 				"err", err, "nonce", auth.Nonce, "batchNum", batchInfo.BatchNum)
 			auth.Nonce.Sub(auth.Nonce, big.NewInt(1))
 			attempt--
 		} else if strings.Contains(err.Error(), core.ErrReplaceUnderpriced.Error()) {
-			log.Warnw("TxManager ethClient.RollupForgeBatch incrementing gasPrice",
+			log.WarnwCtx(ctx, "TxManager ethClient.RollupForgeBatch incrementing gasPrice",
 				"err", err, "gasPrice", auth.GasPrice, "batchNum", batchInfo.BatchNum)
 			auth.GasPrice = addPerc(auth.GasPrice, 10)
 			attempt--
 		} else if strings.Contains(err.Error(), core.ErrUnderpriced.Error()) {
-			log.Warnw("TxManager ethClient.RollupForgeBatch incrementing gasPrice",
+			log.WarnwCtx(ctx, "TxManager ethClient.RollupForgeBatch incrementing gasPrice",
 				"err", err, "gasPrice", auth.GasPrice, "batchNum", batchInfo.BatchNum)
 			auth.GasPrice = addPerc(auth.GasPrice, 10)
 			attempt--
 		} else {
-			log.Errorw("TxManager ethClient.RollupForgeBatch",
+			log.ErrorwCtx(ctx, "TxManager ethClient.RollupForgeBatch",
 				"attempt", attempt, "err", err, "block", t.stats.Eth.LastBlock.Num+1,
 				"batchNum", batchInfo.BatchNum)
 		}
@@ -307,13 +337,14 @@ func (t *TxManager) sendRollupForgeBatch(ctx context.Context, batchInfo *BatchIn
 		}
 	}
 	if err != nil {
+		span.SetError(err)
 		return tracerr.Wrap(fmt.Errorf("reached max attempts for ethClient.RollupForgeBatch: %w", err))
 	}
 	if !resend {
 		t.accNextNonce = auth.Nonce.Uint64() + 1
 	}
 	batchInfo.EthTxs = append(batchInfo.EthTxs, ethTx)
-	log.Infow("TxManager ethClient.RollupForgeBatch", "batch", batchInfo.BatchNum, "tx", ethTx.Hash())
+	log.InfowCtx(ctx, "TxManager ethClient.RollupForgeBatch", "batch", batchInfo.BatchNum, "tx", ethTx.Hash())
 	now := time.Now()
 	batchInfo.SendTimestamp = now
 
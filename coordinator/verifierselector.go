@@ -0,0 +1,96 @@
+package coordinator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// VerifierCfg describes a verifier (proving circuit) registered in the rollup
+// smart contract that the coordinator is able to forge batches with, along
+// with the estimated cost of generating a proof for it
+type VerifierCfg struct {
+	// Idx is the index of the verifier in the rollup smart contract
+	Idx uint8
+	// MaxTx is the maximum number of txs supported by the circuit
+	MaxTx int64
+	// NLevels is the maximum number of merkle tree levels supported by
+	// the circuit
+	NLevels int64
+	// ProofCostUSD is the estimated cost in USD of generating a proof
+	// for this circuit (prover hardware time plus the extra gas of
+	// verifying a bigger proof on-chain, if any)
+	ProofCostUSD float64
+}
+
+// VerifierSelector chooses, for each batch, the verifier that maximizes the
+// collected fees minus the proof cost among a set of circuits that share the
+// same NLevels.  The tx selection is run with the MaxTx of the biggest
+// circuit (see MaxTx), and afterwards the batch is proven with the cheapest
+// circuit that fits the selected txs, so that small batches don't pay for a
+// big proof.
+type VerifierSelector struct {
+	// verifiers sorted by ascending MaxTx
+	verifiers []VerifierCfg
+}
+
+// NewVerifierSelector returns a VerifierSelector over the given verifiers.
+// All the verifiers must share the same NLevels, as they operate over the
+// same state tree.
+func NewVerifierSelector(verifiers []VerifierCfg) (*VerifierSelector, error) {
+	if len(verifiers) == 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("at least one verifier is required"))
+	}
+	for i := 1; i < len(verifiers); i++ {
+		if verifiers[i].NLevels != verifiers[0].NLevels {
+			return nil, tracerr.Wrap(
+				fmt.Errorf("all verifiers must share the same NLevels: "+
+					"verifier[%v].NLevels = %v, verifier[0].NLevels = %v",
+					i, verifiers[i].NLevels, verifiers[0].NLevels))
+		}
+	}
+	sorted := make([]VerifierCfg, len(verifiers))
+	copy(sorted, verifiers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].MaxTx < sorted[j].MaxTx
+	})
+	return &VerifierSelector{verifiers: sorted}, nil
+}
+
+// MaxTx returns the MaxTx of the biggest circuit.  The tx selection is run
+// with this limit so that the biggest circuit can always be filled.
+func (s *VerifierSelector) MaxTx() int64 {
+	return s.verifiers[len(s.verifiers)-1].MaxTx
+}
+
+// Select returns the verifier that maximizes feesUSD minus ProofCostUSD for
+// a selection of nTxs total txs (L1 plus L2), along with the resulting net
+// value in USD.  Since the fees are already fixed by the selection, this is
+// the fitting verifier with the lowest ProofCostUSD, preferring the smallest
+// circuit on ties.  If nTxs doesn't fit in any circuit (which can't happen
+// when the selection was limited by MaxTx), the biggest one is returned.
+func (s *VerifierSelector) Select(nTxs int, feesUSD float64) (VerifierCfg, float64) {
+	best := s.verifiers[len(s.verifiers)-1]
+	found := false
+	for _, verifier := range s.verifiers {
+		if verifier.MaxTx < int64(nTxs) {
+			continue
+		}
+		if !found || verifier.ProofCostUSD < best.ProofCostUSD {
+			best = verifier
+			found = true
+		}
+	}
+	return best, feesUSD - best.ProofCostUSD
+}
+
+// poolL2TxsFeesUSD returns the accumulated fees in USD of the given pool txs
+func poolL2TxsFeesUSD(poolL2Txs []common.PoolL2Tx) float64 {
+	feesUSD := 0.0
+	for i := range poolL2Txs {
+		feesUSD += poolL2Txs[i].AbsoluteFee
+	}
+	return feesUSD
+}
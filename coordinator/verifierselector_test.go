@@ -0,0 +1,85 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVerifierSelector(t *testing.T) {
+	// At least one verifier is required
+	_, err := NewVerifierSelector(nil)
+	assert.Error(t, err)
+
+	// All verifiers must share the same NLevels
+	_, err = NewVerifierSelector([]VerifierCfg{
+		{Idx: 0, MaxTx: 512, NLevels: 32, ProofCostUSD: 10},
+		{Idx: 1, MaxTx: 2048, NLevels: 16, ProofCostUSD: 30},
+	})
+	assert.Error(t, err)
+
+	// The selection limit is the MaxTx of the biggest circuit,
+	// regardless of the order in which the verifiers are given
+	s, err := NewVerifierSelector([]VerifierCfg{
+		{Idx: 1, MaxTx: 2048, NLevels: 32, ProofCostUSD: 30},
+		{Idx: 0, MaxTx: 512, NLevels: 32, ProofCostUSD: 10},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2048), s.MaxTx())
+}
+
+func TestVerifierSelectorSelect(t *testing.T) {
+	s, err := NewVerifierSelector([]VerifierCfg{
+		{Idx: 0, MaxTx: 512, NLevels: 32, ProofCostUSD: 10},
+		{Idx: 1, MaxTx: 2048, NLevels: 32, ProofCostUSD: 30},
+		{Idx: 2, MaxTx: 4096, NLevels: 32, ProofCostUSD: 80},
+	})
+	require.NoError(t, err)
+
+	// A small batch is proven with the smallest (cheapest) circuit
+	verifier, netUSD := s.Select(100, 50.0)
+	assert.Equal(t, uint8(0), verifier.Idx)
+	assert.Equal(t, 40.0, netUSD)
+
+	// A batch that exactly fills a circuit still fits in it
+	verifier, _ = s.Select(512, 50.0)
+	assert.Equal(t, uint8(0), verifier.Idx)
+
+	// A batch that overflows the smallest circuit moves to the next one
+	verifier, netUSD = s.Select(513, 50.0)
+	assert.Equal(t, uint8(1), verifier.Idx)
+	assert.Equal(t, 20.0, netUSD)
+
+	// The net can be negative; the choice still minimizes the proof cost
+	verifier, netUSD = s.Select(3000, 50.0)
+	assert.Equal(t, uint8(2), verifier.Idx)
+	assert.Equal(t, -30.0, netUSD)
+
+	// If no circuit fits (can't happen when the selection was limited by
+	// MaxTx), the biggest one is returned
+	verifier, _ = s.Select(5000, 50.0)
+	assert.Equal(t, uint8(2), verifier.Idx)
+}
+
+func TestVerifierSelectorPrefersCheapest(t *testing.T) {
+	// A bigger circuit with a cheaper proof (e.g. run on better hardware)
+	// wins over a smaller but more expensive one
+	s, err := NewVerifierSelector([]VerifierCfg{
+		{Idx: 0, MaxTx: 512, NLevels: 32, ProofCostUSD: 25},
+		{Idx: 1, MaxTx: 2048, NLevels: 32, ProofCostUSD: 15},
+	})
+	require.NoError(t, err)
+	verifier, _ := s.Select(100, 50.0)
+	assert.Equal(t, uint8(1), verifier.Idx)
+}
+
+func TestPoolL2TxsFeesUSD(t *testing.T) {
+	assert.Equal(t, 0.0, poolL2TxsFeesUSD(nil))
+	txs := []common.PoolL2Tx{
+		{AbsoluteFee: 1.5},
+		{AbsoluteFee: 2.25},
+	}
+	assert.Equal(t, 3.75, poolL2TxsFeesUSD(txs))
+}
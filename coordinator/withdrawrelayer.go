@@ -0,0 +1,203 @@
+package coordinator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/eth"
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// WithdrawRelayerTokenLimit is the safety limit of a token relayed by the
+// withdrawal relayer.  Exits of tokens without a limit entry are never
+// relayed
+type WithdrawRelayerTokenLimit struct {
+	TokenID common.TokenID
+	// MaxAmount is the maximum balance of a single relayed exit.
+	// Requests over this amount are rejected
+	MaxAmount *big.Int
+	// MinFee is the minimum fee, denominated in the token, that the owner
+	// must commit to pay for the relay.  Requests under this fee are
+	// rejected
+	MinFee *big.Int
+}
+
+// WithdrawRelayerCfg is the withdrawal relayer configuration
+type WithdrawRelayerCfg struct {
+	// Enabled enables the withdrawal relayer
+	Enabled bool
+	// Interval is the waiting interval between scans of the pending
+	// withdrawal relay requests
+	Interval time.Duration
+	// TokenLimits is the list of relayed tokens with their safety limits.
+	// Exits of tokens not in this list are never relayed
+	TokenLimits []WithdrawRelayerTokenLimit
+}
+
+// WithdrawRelayer submits the withdrawMerkleProof of the exits whose owners
+// have requested it through the API (see WithdrawalRelayAuth), tracking the
+// submission status in the L2DB.  The withdrawn amount is always sent to the
+// owner of the exit by the smart contract, so the relayer never holds user
+// funds
+type WithdrawRelayer struct {
+	cfg       WithdrawRelayerCfg
+	limits    map[common.TokenID]WithdrawRelayerTokenLimit
+	historyDB *historydb.HistoryDB
+	l2DB      *l2db.L2DB
+	ethClient eth.ClientInterface
+}
+
+// NewWithdrawRelayer creates a new WithdrawRelayer
+func NewWithdrawRelayer(cfg WithdrawRelayerCfg, historyDB *historydb.HistoryDB,
+	l2DB *l2db.L2DB, ethClient eth.ClientInterface) *WithdrawRelayer {
+	limits := make(map[common.TokenID]WithdrawRelayerTokenLimit)
+	for _, limit := range cfg.TokenLimits {
+		limits[limit.TokenID] = limit
+	}
+	return &WithdrawRelayer{
+		cfg:       cfg,
+		limits:    limits,
+		historyDB: historyDB,
+		l2DB:      l2DB,
+		ethClient: ethClient,
+	}
+}
+
+// Run processes the withdrawal relay requests every cfg.Interval until the
+// context is canceled
+func (r *WithdrawRelayer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("WithdrawRelayer done")
+			return
+		case <-time.After(r.cfg.Interval):
+			if err := r.checkSentRequests(ctx); err != nil {
+				log.Errorw("WithdrawRelayer.checkSentRequests", "err", err)
+			}
+			if err := r.relayPendingRequests(ctx); err != nil {
+				log.Errorw("WithdrawRelayer.relayPendingRequests", "err", err)
+			}
+		}
+	}
+}
+
+// checkSentRequests checks the receipts of the sent withdrawMerkleProof
+// ethereum transactions, moving the mined ones to the confirmed or failed
+// status
+func (r *WithdrawRelayer) checkSentRequests(ctx context.Context) error {
+	requests, err := r.l2DB.GetWithdrawalRelayRequestsByStatus(l2db.WithdrawalRelayStatusSent)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for i := range requests {
+		request := &requests[i]
+		receipt, err := r.ethClient.EthTransactionReceipt(ctx, request.EthTxHash)
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil || receipt == nil {
+			// Not mined yet, check again in the next scan
+			continue
+		}
+		status := l2db.WithdrawalRelayStatusConfirmed
+		info := ""
+		if receipt.Status == 0 {
+			status = l2db.WithdrawalRelayStatusFailed
+			info = "the withdrawMerkleProof ethereum transaction reverted"
+		}
+		if err := r.l2DB.UpdateWithdrawalRelayRequestStatus(request.ItemID,
+			status, info); err != nil {
+			return tracerr.Wrap(err)
+		}
+		log.Infow("WithdrawRelayer: withdrawal mined",
+			"batch", request.BatchNum, "idx", request.AccountIdx,
+			"ethTxHash", request.EthTxHash, "status", status)
+	}
+	return nil
+}
+
+// relayPendingRequests submits the withdrawMerkleProof of the pending
+// requests that pass the per token safety limits
+func (r *WithdrawRelayer) relayPendingRequests(ctx context.Context) error {
+	requests, err := r.l2DB.GetWithdrawalRelayRequestsByStatus(l2db.WithdrawalRelayStatusPending)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for i := range requests {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := r.relayRequest(&requests[i]); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func (r *WithdrawRelayer) relayRequest(request *l2db.WithdrawalRelayRequest) error {
+	exit, err := r.historyDB.GetExitRelayInfo(request.BatchNum, request.AccountIdx)
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		// The exit is not synchronized yet (or was reorged out), keep
+		// the request pending until it appears
+		return nil
+	} else if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if reason := r.rejectReason(request, exit); reason != "" {
+		log.Infow("WithdrawRelayer: request rejected",
+			"batch", request.BatchNum, "idx", request.AccountIdx,
+			"reason", reason)
+		return tracerr.Wrap(r.l2DB.UpdateWithdrawalRelayRequestStatus(
+			request.ItemID, l2db.WithdrawalRelayStatusRejected, reason))
+	}
+	siblings := []*big.Int{}
+	for _, sibling := range common.RmEndingZeroes(exit.MerkleProof.Siblings) {
+		siblings = append(siblings, sibling.BigInt())
+	}
+	ethTx, err := r.ethClient.RollupWithdrawMerkleProof(exit.BJJ,
+		uint32(exit.TokenID), int64(request.BatchNum), int64(request.AccountIdx),
+		exit.Balance, siblings, true)
+	if err != nil {
+		// The error can be transient (RPC failure) so the request is
+		// kept pending and retried in the next scan
+		log.Warnw("WithdrawRelayer: RollupWithdrawMerkleProof failed",
+			"batch", request.BatchNum, "idx", request.AccountIdx, "err", err)
+		return nil
+	}
+	log.Infow("WithdrawRelayer: withdrawal sent",
+		"batch", request.BatchNum, "idx", request.AccountIdx,
+		"ethTxHash", ethTx.Hash())
+	return tracerr.Wrap(r.l2DB.UpdateWithdrawalRelayRequestSent(
+		request.ItemID, ethTx.Hash()))
+}
+
+// rejectReason returns the reason why a request must be rejected according
+// to the exit state and the per token safety limits, or an empty string when
+// the request can be relayed
+func (r *WithdrawRelayer) rejectReason(request *l2db.WithdrawalRelayRequest,
+	exit *historydb.ExitRelayInfo) string {
+	if exit.InstantWithdrawn != nil {
+		return "the exit has already been withdrawn"
+	}
+	limit, relayed := r.limits[exit.TokenID]
+	if !relayed {
+		return fmt.Sprintf("token %v is not relayed by this coordinator",
+			exit.TokenID)
+	}
+	if limit.MaxAmount != nil && exit.Balance.Cmp(limit.MaxAmount) > 0 {
+		return fmt.Sprintf("the exit balance is over the maximum relayed "+
+			"amount of token %v", exit.TokenID)
+	}
+	if limit.MinFee != nil && request.Fee.Cmp(limit.MinFee) < 0 {
+		return fmt.Sprintf("the committed fee is under the minimum relay "+
+			"fee of token %v", exit.TokenID)
+	}
+	return ""
+}
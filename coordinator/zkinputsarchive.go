@@ -0,0 +1,183 @@
+package coordinator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// ZKInputsStorage is the storage abstraction used by ZKInputsArchive to
+// persist the ZKInputs of forged batches.  Implementations can store the
+// blobs on the local filesystem, object storage, etc.
+type ZKInputsStorage interface {
+	// Put stores the blob of a batch, overwriting any previous one
+	Put(batchNum common.BatchNum, data []byte) error
+	// Get returns the stored blob of a batch
+	Get(batchNum common.BatchNum) ([]byte, error)
+	// List returns the batchNums that have a stored blob, in ascending
+	// order
+	List() ([]common.BatchNum, error)
+	// Delete removes the stored blob of a batch
+	Delete(batchNum common.BatchNum) error
+}
+
+// FSZKInputsStorage is a ZKInputsStorage that stores the blobs as files in a
+// local directory, one file per batch
+type FSZKInputsStorage struct {
+	dir string
+}
+
+// NewFSZKInputsStorage creates a new FSZKInputsStorage that stores the blobs
+// under dir, creating it if it doesn't exist
+func NewFSZKInputsStorage(dir string) (*FSZKInputsStorage, error) {
+	if err := os.MkdirAll(dir, 0744); err != nil { //nolint:gomnd
+		return nil, tracerr.Wrap(err)
+	}
+	return &FSZKInputsStorage{dir: dir}, nil
+}
+
+func (s *FSZKInputsStorage) fileName(batchNum common.BatchNum) string {
+	return path.Join(s.dir, fmt.Sprintf("%08d.zkinputs", batchNum))
+}
+
+// Put stores the blob of a batch, overwriting any previous one
+func (s *FSZKInputsStorage) Put(batchNum common.BatchNum, data []byte) error {
+	// nolint reason: 0640 allows rw to owner and r to group
+	//nolint:gosec
+	return tracerr.Wrap(ioutil.WriteFile(s.fileName(batchNum), data, 0640))
+}
+
+// Get returns the stored blob of a batch
+func (s *FSZKInputsStorage) Get(batchNum common.BatchNum) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.fileName(batchNum))
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return data, nil
+}
+
+// List returns the batchNums that have a stored blob, in ascending order
+func (s *FSZKInputsStorage) List() ([]common.BatchNum, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var batchNums []common.BatchNum
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".zkinputs") {
+			continue
+		}
+		var batchNum common.BatchNum
+		if _, err := fmt.Sscanf(name, "%d.zkinputs", &batchNum); err != nil {
+			continue
+		}
+		batchNums = append(batchNums, batchNum)
+	}
+	sort.Slice(batchNums, func(i, j int) bool { return batchNums[i] < batchNums[j] })
+	return batchNums, nil
+}
+
+// Delete removes the stored blob of a batch
+func (s *FSZKInputsStorage) Delete(batchNum common.BatchNum) error {
+	return tracerr.Wrap(os.Remove(s.fileName(batchNum)))
+}
+
+// gzipMagic are the first two bytes of every gzip stream, used to detect
+// whether a stored blob is compressed
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ZKInputsArchive persists the ZKInputs of every forged batch through a
+// ZKInputsStorage, so they can be retrieved later for re-proving, debugging
+// proof failures or circuit audits
+type ZKInputsArchive struct {
+	storage ZKInputsStorage
+	// gz enables gzip compression of the stored blobs
+	gz bool
+	// retention is the number of most recent batches to keep.  Once
+	// exceeded, the oldest archived batches are deleted.  0 keeps all
+	// batches.
+	retention int
+}
+
+// NewZKInputsArchive creates a new ZKInputsArchive backed by storage.  If gz
+// is true the blobs are compressed with gzip before storing them, and if
+// retention is not 0 only the ZKInputs of the retention most recent batches
+// are kept.
+func NewZKInputsArchive(storage ZKInputsStorage, gz bool, retention int) *ZKInputsArchive {
+	return &ZKInputsArchive{
+		storage:   storage,
+		gz:        gz,
+		retention: retention,
+	}
+}
+
+// Store archives the ZKInputs of a batch and applies the retention policy
+func (a *ZKInputsArchive) Store(batchNum common.BatchNum, zkInputs *common.ZKInputs) error {
+	data, err := json.Marshal(zkInputs)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if a.gz {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		if _, err := gzw.Write(data); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := gzw.Close(); err != nil {
+			return tracerr.Wrap(err)
+		}
+		data = buf.Bytes()
+	}
+	if err := a.storage.Put(batchNum, data); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return a.prune()
+}
+
+// Get returns the archived ZKInputs of a batch as JSON, decompressing them if
+// they were stored compressed
+func (a *ZKInputsArchive) Get(batchNum common.BatchNum) ([]byte, error) {
+	data, err := a.storage.Get(batchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		gzr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		defer gzr.Close() //nolint:errcheck
+		if data, err = ioutil.ReadAll(gzr); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+	return data, nil
+}
+
+// prune deletes the oldest archived batches that exceed the retention policy
+func (a *ZKInputsArchive) prune() error {
+	if a.retention <= 0 {
+		return nil
+	}
+	batchNums, err := a.storage.List()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for len(batchNums) > a.retention {
+		if err := a.storage.Delete(batchNums[0]); err != nil {
+			return tracerr.Wrap(err)
+		}
+		batchNums = batchNums[1:]
+	}
+	return nil
+}
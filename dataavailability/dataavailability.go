@@ -0,0 +1,108 @@
+/*
+Package dataavailability decodes the data availability bytes published on-chain
+in the forgeBatch calls of the Rollup smart contract, so that the txs reported
+by the API (or any other source) can be audited against the on-chain data.
+
+Given a batchNum, the Decoder resolves the ethereum transaction of the
+forgeBatch call through the HistoryDB, fetches and unpacks its calldata, and
+decodes the L1/L2 txs data with the effective from/to idxs that were applied to
+the state.  The decoded batch is exposed through the API endpoint GET
+/batches/:batchNum/data-availability and the heznode batchda command.
+*/
+package dataavailability
+
+import (
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/eth"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// ForgeBatchArgsReader is the subset of the Rollup smart contract client used
+// to fetch and unpack the forgeBatch calldata
+type ForgeBatchArgsReader interface {
+	RollupForgeBatchArgs(ethCommon.Hash, uint16) (*eth.RollupForgeBatchArgs, *ethCommon.Address, error)
+}
+
+// L2TxData is a L2 tx decoded from the data availability bytes.  The from/to
+// idxs are the effective ones applied to the state: txs sent to an ethereum
+// address or a BJJ public key appear here with the idx that was resolved at
+// forge time, and exits appear with the exit idx (1)
+type L2TxData struct {
+	FromIdx common.Idx          `json:"fromAccountIndex"`
+	ToIdx   common.Idx          `json:"toAccountIndex"`
+	Amount  *apitypes.BigIntStr `json:"amount"`
+	Fee     common.FeeSelector  `json:"fee"`
+	Type    common.TxType       `json:"type"`
+}
+
+// BatchData is the decoded data availability of a forged batch
+type BatchData struct {
+	BatchNum         common.BatchNum     `json:"batchNum"`
+	EthTxHash        ethCommon.Hash      `json:"ethereumTxHash"`
+	Sender           ethCommon.Address   `json:"forgerAddr"`
+	VerifierIdx      uint8               `json:"verifierIdx"`
+	L1Batch          bool                `json:"l1Batch"`
+	NewLastIdx       int64               `json:"newLastIdx"`
+	NewStateRoot     *apitypes.BigIntStr `json:"newStateRoot"`
+	NewExitRoot      *apitypes.BigIntStr `json:"newExitRoot"`
+	L1UserTxs        []common.L1Tx       `json:"l1UserTransactions"`
+	L1CoordinatorTxs []common.L1Tx       `json:"l1CoordinatorTransactions"`
+	L2Txs            []L2TxData          `json:"l2Transactions"`
+}
+
+// Decoder decodes the data availability of forged batches
+type Decoder struct {
+	hdb    *historydb.HistoryDB
+	rollup ForgeBatchArgsReader
+}
+
+// NewDecoder creates a new Decoder
+func NewDecoder(hdb *historydb.HistoryDB, rollup ForgeBatchArgsReader) *Decoder {
+	return &Decoder{hdb: hdb, rollup: rollup}
+}
+
+// DecodeBatch returns the decoded data availability of the given batch
+func (d *Decoder) DecodeBatch(batchNum common.BatchNum) (*BatchData, error) {
+	batch, err := d.hdb.GetBatch(batchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	l1UserTxsLen, err := d.hdb.GetL1UserTxsCountByBatchNum(batchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	args, sender, err := d.rollup.RollupForgeBatchArgs(batch.EthTxHash, l1UserTxsLen)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	l2Txs := make([]L2TxData, len(args.L2TxsData))
+	for i, tx := range args.L2TxsData {
+		txType := common.TxTypeTransfer
+		if tx.ToIdx == common.Idx(1) {
+			txType = common.TxTypeExit
+		}
+		l2Txs[i] = L2TxData{
+			FromIdx: tx.FromIdx,
+			ToIdx:   tx.ToIdx,
+			Amount:  apitypes.NewBigIntStr(tx.Amount),
+			Fee:     tx.Fee,
+			Type:    txType,
+		}
+	}
+	return &BatchData{
+		BatchNum:         batchNum,
+		EthTxHash:        batch.EthTxHash,
+		Sender:           *sender,
+		VerifierIdx:      args.VerifierIdx,
+		L1Batch:          args.L1Batch,
+		NewLastIdx:       args.NewLastIdx,
+		NewStateRoot:     apitypes.NewBigIntStr(args.NewStRoot),
+		NewExitRoot:      apitypes.NewBigIntStr(args.NewExitRoot),
+		L1UserTxs:        args.L1UserTxs,
+		L1CoordinatorTxs: args.L1CoordinatorTxs,
+		L2Txs:            l2Txs,
+	}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/api/apitypes"
@@ -30,18 +31,26 @@ func (hdb *HistoryDB) GetLastBlockAPI() (*common.Block, error) {
 
 // GetBatchAPI return the batch with the given batchNum
 func (hdb *HistoryDB) GetBatchAPI(batchNum common.BatchNum) (*BatchAPI, error) {
+	key := fmt.Sprintf("batch %v", batchNum)
+	if cached, ok := hdb.apiCache.get(key); ok {
+		return cached.(*BatchAPI), nil
+	}
 	cancel, err := hdb.apiConnCon.Acquire()
 	defer cancel()
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 	defer hdb.apiConnCon.Release()
-	return hdb.getBatchAPI(hdb.dbRead, batchNum)
+	batch, err := hdb.getBatchAPI(hdb.read(), batchNum)
+	if err == nil {
+		hdb.apiCache.set(key, batch)
+	}
+	return batch, err
 }
 
 // GetBatchInternalAPI return the batch with the given batchNum
 func (hdb *HistoryDB) GetBatchInternalAPI(batchNum common.BatchNum) (*BatchAPI, error) {
-	return hdb.getBatchAPI(hdb.dbRead, batchNum)
+	return hdb.getBatchAPI(hdb.read(), batchNum)
 }
 
 func (hdb *HistoryDB) getBatchAPI(d meddler.DB, batchNum common.BatchNum) (*BatchAPI, error) {
@@ -52,7 +61,7 @@ func (hdb *HistoryDB) getBatchAPI(d meddler.DB, batchNum common.BatchNum) (*Batc
 		batch.forger_addr, batch.fees_collected, batch.total_fees_usd, batch.state_root,
 		batch.num_accounts, batch.exit_root, batch.forge_l1_txs_num, batch.slot_num,
 		COALESCE(batch.eth_tx_hash, DECODE('0000000000000000000000000000000000000000000000000000000000000000', 'hex')) as eth_tx_hash,
-		block.timestamp, block.hash, COALESCE ((SELECT COUNT(*) FROM tx WHERE batch_num = batch.batch_num), 0) AS forged_txs
+		block.timestamp, block.hash, COALESCE ((SELECT total_txs FROM batch_tx_count WHERE batch_num = batch.batch_num), 0) AS forged_txs
 	    FROM batch INNER JOIN block ON batch.eth_block_num = block.eth_block_num
 	 	WHERE batch_num = $1;`, batchNum,
 	); err != nil {
@@ -62,6 +71,71 @@ func (hdb *HistoryDB) getBatchAPI(d meddler.DB, batchNum common.BatchNum) (*Batc
 	return batch, nil
 }
 
+// GetBatchStateDiffAPI returns all the state changes introduced by a batch:
+// the accounts it created, the balance and nonce updates it applied and the
+// exits it added to the exit tree.  Returns sql.ErrNoRows when the batch
+// hasn't been forged
+func (hdb *HistoryDB) GetBatchStateDiffAPI(batchNum common.BatchNum) (*BatchStateDiffAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	// Check that the batch exists
+	if _, err := hdb.getBatchAPI(hdb.read(), batchNum); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	diff := &BatchStateDiffAPI{BatchNum: batchNum}
+	// Accounts created by the batch
+	createdAccounts := []*AccountAPI{}
+	if err := meddler.QueryAll(
+		hdb.read(), &createdAccounts, `SELECT account.item_id, hez_idx(account.idx,
+		token.symbol) as idx, account.batch_num, account.bjj, account.eth_addr,
+		token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
+		token.eth_addr as token_eth_addr, token.name, token.symbol, token.decimals, token.usd,
+		token.usd_update, account_update.nonce, account_update.balance
+		FROM account INNER JOIN account_state AS account_update
+			ON account_update.idx = account.idx
+		INNER JOIN token ON account.token_id = token.token_id
+		WHERE account.batch_num = $1 ORDER BY account.idx;`, batchNum,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	diff.CreatedAccounts = db.SlicePtrsToSlice(createdAccounts).([]AccountAPI)
+	// Balance and nonce updates applied by the batch
+	updatedAccounts := []*AccountUpdateAPI{}
+	if err := meddler.QueryAll(
+		hdb.read(), &updatedAccounts, `SELECT hez_idx(account_update.idx, token.symbol) AS idx,
+		account_update.nonce, account_update.balance
+		FROM account_update INNER JOIN account ON account_update.idx = account.idx
+		INNER JOIN token ON account.token_id = token.token_id
+		WHERE account_update.batch_num = $1 ORDER BY account_update.idx;`, batchNum,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	diff.UpdatedAccounts = db.SlicePtrsToSlice(updatedAccounts).([]AccountUpdateAPI)
+	// Exits added to the exit tree by the batch
+	exits := []*ExitAPI{}
+	if err := meddler.QueryAll(
+		hdb.read(), &exits, `SELECT exit_tree.item_id, exit_tree.batch_num,
+		hez_idx(exit_tree.account_idx, token.symbol) AS account_idx,
+		account.bjj, account.eth_addr,
+		exit_tree.merkle_proof, exit_tree.balance, exit_tree.instant_withdrawn,
+		exit_tree.delayed_withdraw_request, exit_tree.delayed_withdrawn,
+		token.token_id, token.item_id AS token_item_id,
+		token.eth_block_num AS token_block, token.eth_addr AS token_eth_addr, token.name, token.symbol,
+		token.decimals, token.usd, token.usd_update
+		FROM exit_tree INNER JOIN account ON exit_tree.account_idx = account.idx
+		INNER JOIN token ON account.token_id = token.token_id
+		WHERE exit_tree.batch_num = $1 ORDER BY exit_tree.account_idx;`, batchNum,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	diff.Exits = db.SlicePtrsToSlice(exits).([]ExitAPI)
+	return diff, nil
+}
+
 // GetBatchesAPIRequest is an API request struct for getting batches
 type GetBatchesAPIRequest struct {
 	MinBatchNum *uint
@@ -90,7 +164,7 @@ func (hdb *HistoryDB) GetBatchesAPI(
 	batch.forger_addr, batch.fees_collected, batch.total_fees_usd, batch.state_root,
 	batch.num_accounts, batch.exit_root, batch.forge_l1_txs_num, batch.slot_num,
 	batch.eth_tx_hash, block.timestamp, block.hash,
-	COALESCE ((SELECT COUNT(*) FROM tx WHERE batch_num = batch.batch_num), 0) AS forged_txs,
+	COALESCE ((SELECT total_txs FROM batch_tx_count WHERE batch_num = batch.batch_num), 0) AS forged_txs,
 	count(*) OVER() AS total_items
 	FROM batch INNER JOIN block ON batch.eth_block_num = block.eth_block_num `
 	// Apply filters
@@ -160,10 +234,10 @@ func (hdb *HistoryDB) GetBatchesAPI(
 		queryStr += " DESC "
 	}
 	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
-	query = hdb.dbRead.Rebind(queryStr)
+	query = hdb.read().Rebind(queryStr)
 	// log.Debug(query)
 	batchPtrs := []*BatchAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &batchPtrs, query, args...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &batchPtrs, query, args...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	batches := db.SlicePtrsToSlice(batchPtrs).([]BatchAPI)
@@ -176,6 +250,66 @@ func (hdb *HistoryDB) GetBatchesAPI(
 	return batches, batches[0].TotalItems - uint64(len(batches)), nil
 }
 
+// GetBatchesProfitabilityAPIRequest is an API request struct for getting the
+// profitability of the batches forged by a coordinator
+type GetBatchesProfitabilityAPIRequest struct {
+	ForgerAddr *ethCommon.Address
+
+	FromItem *uint
+	Limit    *uint
+	Order    string
+}
+
+// GetBatchesProfitabilityAPI returns, for each batch forged by the given
+// forger address, the fees collected in USD, the gas spent by the L1 forge tx
+// and the resulting net profit.  The USD cost of the gas is computed with the
+// current price of ETH (token 0).
+func (hdb *HistoryDB) GetBatchesProfitabilityAPI(
+	request GetBatchesProfitabilityAPIRequest,
+) ([]BatchProfitabilityAPI, uint64, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	var args []interface{}
+	queryStr := `SELECT batch.item_id, batch.batch_num, batch.eth_tx_hash, batch.forger_addr,
+	batch.total_fees_usd, batch.gas_used, batch.gas_price,
+	(SELECT usd FROM token WHERE token_id = 0) AS eth_usd,
+	count(*) OVER() AS total_items
+	FROM batch WHERE batch.forger_addr = ? `
+	args = append(args, request.ForgerAddr)
+	if request.FromItem != nil {
+		if request.Order == db.OrderAsc {
+			queryStr += "AND batch.item_id >= ? "
+		} else {
+			queryStr += "AND batch.item_id <= ? "
+		}
+		args = append(args, request.FromItem)
+	}
+	queryStr += "ORDER BY batch.item_id "
+	if request.Order == db.OrderAsc {
+		queryStr += " ASC "
+	} else {
+		queryStr += " DESC "
+	}
+	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
+	query := hdb.read().Rebind(queryStr)
+	batchPtrs := []*BatchProfitabilityAPI{}
+	if err := meddler.QueryAll(hdb.read(), &batchPtrs, query, args...); err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	batches := db.SlicePtrsToSlice(batchPtrs).([]BatchProfitabilityAPI)
+	if len(batches) == 0 {
+		return batches, 0, nil
+	}
+	for i := range batches {
+		batches[i].setProfit()
+	}
+	return batches, batches[0].TotalItems - uint64(len(batches)), nil
+}
+
 // GetBestBidAPI returns the best bid in specific slot by slotNum
 func (hdb *HistoryDB) GetBestBidAPI(slotNum *int64) (BidAPI, error) {
 	bid := &BidAPI{}
@@ -186,7 +320,7 @@ func (hdb *HistoryDB) GetBestBidAPI(slotNum *int64) (BidAPI, error) {
 	}
 	defer hdb.apiConnCon.Release()
 	err = meddler.QueryRow(
-		hdb.dbRead, bid, `SELECT bid.*, block.timestamp, coordinator.forger_addr, coordinator.url 
+		hdb.read(), bid, `SELECT bid.*, block.timestamp, coordinator.forger_addr, coordinator.url 
 		FROM bid INNER JOIN block ON bid.eth_block_num = block.eth_block_num
 		INNER JOIN (
 			SELECT bidder_addr, MAX(item_id) AS item_id FROM coordinator
@@ -198,6 +332,38 @@ func (hdb *HistoryDB) GetBestBidAPI(slotNum *int64) (BidAPI, error) {
 	return *bid, tracerr.Wrap(err)
 }
 
+// GetSlotForgingSummariesAPI returns the forging outcome of the slots in the
+// range [minSlotNum, maxSlotNum] that have at least one forged batch,
+// aggregating the batches by slot_num
+func (hdb *HistoryDB) GetSlotForgingSummariesAPI(
+	minSlotNum, maxSlotNum int64) ([]SlotForgingSummaryAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	var summaries []*SlotForgingSummaryAPI
+	err = meddler.QueryAll(
+		hdb.read(), &summaries,
+		`SELECT batch.slot_num,
+		(SELECT forger_addr FROM batch AS last_batch
+			WHERE last_batch.slot_num = batch.slot_num
+			ORDER BY last_batch.batch_num DESC LIMIT 1) AS forger_addr,
+		COUNT(*) AS forged_batches,
+		COALESCE(SUM((SELECT total_txs FROM batch_tx_count
+			WHERE batch_tx_count.batch_num = batch.batch_num)), 0) AS forged_txs,
+		SUM(batch.total_fees_usd) AS total_fees_usd
+		FROM batch WHERE batch.slot_num >= $1 AND batch.slot_num <= $2
+		GROUP BY batch.slot_num ORDER BY batch.slot_num ASC;`,
+		minSlotNum, maxSlotNum,
+	)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return db.SlicePtrsToSlice(summaries).([]SlotForgingSummaryAPI), nil
+}
+
 // GetBestBidsAPIRequest is an API request struct for getting best bids
 type GetBestBidsAPIRequest struct {
 	MinSlotNum      *int64
@@ -218,7 +384,7 @@ func (hdb *HistoryDB) GetBestBidsAPI(request GetBestBidsAPIRequest) ([]BidAPI, u
 		return nil, 0, tracerr.Wrap(err)
 	}
 	defer hdb.apiConnCon.Release()
-	return hdb.getBestBidsAPI(hdb.dbRead, request)
+	return hdb.getBestBidsAPI(hdb.read(), request)
 }
 
 func (hdb *HistoryDB) getBestBidsAPI(
@@ -256,7 +422,7 @@ func (hdb *HistoryDB) getBestBidsAPI(
 	if request.Limit != nil {
 		queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
 	}
-	query = hdb.dbRead.Rebind(queryStr)
+	query = hdb.read().Rebind(queryStr)
 	bidPtrs := []*BidAPI{}
 	if err := meddler.QueryAll(d, &bidPtrs, query, args...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
@@ -347,9 +513,9 @@ func (hdb *HistoryDB) GetBidsAPI(request GetBidsAPIRequest) ([]BidAPI, uint64, e
 	if err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
-	query = hdb.dbRead.Rebind(query)
+	query = hdb.read().Rebind(query)
 	bids := []*BidAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &bids, query, argsQ...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &bids, query, argsQ...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	if len(bids) == 0 {
@@ -384,12 +550,30 @@ type GetTokensAPIRequest struct {
 func (hdb *HistoryDB) GetTokensAPI(
 	request GetTokensAPIRequest,
 ) ([]TokenWithUSD, uint64, error) {
+	key := tokensCacheKey(request)
+	if cached, ok := hdb.apiCache.get(key); ok {
+		entry := cached.(cachedTokensEntry)
+		return entry.Tokens, entry.PendingItems, nil
+	}
 	cancel, err := hdb.apiConnCon.Acquire()
 	defer cancel()
 	if err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	defer hdb.apiConnCon.Release()
+	tokens, pendingItems, err := hdb.getTokensAPI(request)
+	if err == nil {
+		hdb.apiCache.set(key, cachedTokensEntry{
+			Tokens:       tokens,
+			PendingItems: pendingItems,
+		})
+	}
+	return tokens, pendingItems, err
+}
+
+func (hdb *HistoryDB) getTokensAPI(
+	request GetTokensAPIRequest,
+) ([]TokenWithUSD, uint64, error) {
 	var query string
 	var args []interface{}
 	queryStr := `SELECT * , COUNT(*) OVER() AS total_items FROM token `
@@ -445,15 +629,17 @@ func (hdb *HistoryDB) GetTokensAPI(
 	if err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
-	query = hdb.dbRead.Rebind(query)
+	query = hdb.read().Rebind(query)
 	tokens := []*TokenWithUSD{}
-	if err := meddler.QueryAll(hdb.dbRead, &tokens, query, argsQ...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &tokens, query, argsQ...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	if len(tokens) == 0 {
 		return []TokenWithUSD{}, 0, nil
 	}
-	return db.SlicePtrsToSlice(tokens).([]TokenWithUSD), tokens[0].TotalItems - uint64(len(tokens)), nil
+	tokensList := db.SlicePtrsToSlice(tokens).([]TokenWithUSD)
+	hdb.markStalePrices(tokensList)
+	return tokensList, tokens[0].TotalItems - uint64(len(tokens)), nil
 }
 
 // GetCurrencyAPI returns a Currency from the DB given its symbol
@@ -489,9 +675,9 @@ func (hdb *HistoryDB) GetCurrenciesAPI(
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
-	query = hdb.dbRead.Rebind(query)
+	query = hdb.read().Rebind(query)
 	currencies := []*FiatCurrency{}
-	if err := meddler.QueryAll(hdb.dbRead, &currencies, query, argsQ...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &currencies, query, argsQ...); err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 	if len(currencies) == 0 {
@@ -513,7 +699,7 @@ func (hdb *HistoryDB) GetTxAPI(txID common.TxID) (*TxAPI, error) {
 	defer hdb.apiConnCon.Release()
 	tx := &TxAPI{}
 	err = meddler.QueryRow(
-		hdb.dbRead, tx, `SELECT tx.item_id, tx.is_l1, tx.id, tx.type, tx.position, 
+		hdb.read(), tx, `SELECT tx.item_id, tx.is_l1, tx.id, tx.type, tx.position, 
 		hez_idx(tx.effective_from_idx, token.symbol) AS from_idx, tx.from_eth_addr, tx.from_bjj,
 		hez_idx(tx.to_idx, token.symbol) AS to_idx, tx.to_eth_addr, tx.to_bjj,
 		tx.amount, tx.amount_success, tx.token_id, tx.amount_usd, 
@@ -529,6 +715,28 @@ func (hdb *HistoryDB) GetTxAPI(txID common.TxID) (*TxAPI, error) {
 	return tx, tracerr.Wrap(err)
 }
 
+// GetTxInclusionProofAPI returns the data needed to verify that a forged L2
+// tx is included in the data availability bytes published on-chain in the
+// forgeBatch call of its batch
+func (hdb *HistoryDB) GetTxInclusionProofAPI(txID common.TxID) (*TxInclusionProofAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	proof := &TxInclusionProofAPI{}
+	err = meddler.QueryRow(
+		hdb.read(), proof,
+		`SELECT tx.id, tx.batch_num, tx.position, tx.da_offset, tx.da_length,
+		batch.state_root, batch.eth_tx_hash
+		FROM tx INNER JOIN batch ON tx.batch_num = batch.batch_num
+		WHERE tx.id = $1 AND tx.is_l1 = FALSE;`,
+		txID,
+	)
+	return proof, tracerr.Wrap(err)
+}
+
 // GetTxsAPIRequest is an API request struct for getting txs
 type GetTxsAPIRequest struct {
 	EthAddr           *ethCommon.Address
@@ -544,43 +752,22 @@ type GetTxsAPIRequest struct {
 	BatchNum          *uint
 	TxType            *common.TxType
 	IncludePendingL1s *bool
+	// ZeroedAmounts limits the results to forged L1 txs whose effective
+	// amounts were zeroed at forge time
+	ZeroedAmounts *bool
 
 	FromItem *uint
 	Limit    *uint
 	Order    string
 }
 
-// GetTxsAPI returns a list of txs from the DB using the HistoryTx struct
-// and pagination info
-func (hdb *HistoryDB) GetTxsAPI(
-	request GetTxsAPIRequest,
-) ([]TxAPI, uint64, error) {
-	// Warning: amount_success and deposit_amount_success have true as default for
-	// performance reasons. The expected default value is false (when txs are unforged)
-	// this case is handled at the function func (tx TxAPI) MarshalJSON() ([]byte, error)
-	cancel, err := hdb.apiConnCon.Acquire()
-	defer cancel()
-	if err != nil {
-		return nil, 0, tracerr.Wrap(err)
-	}
-	defer hdb.apiConnCon.Release()
-	if request.EthAddr != nil && request.Bjj != nil {
-		return nil, 0, tracerr.Wrap(errors.New("ethAddr and bjj are incompatible"))
-	}
-	var query string
+// buildTxsAPIFilters builds the WHERE filters and the ordering of the
+// transaction history query from the request.  The returned string is meant
+// to be appended to the SELECT clause (without LIMIT), and must be rebound
+// before being executed.  It is shared by GetTxsAPI and GetTxsStreamAPI.
+func buildTxsAPIFilters(request GetTxsAPIRequest) (string, []interface{}) {
+	var queryStr string
 	var args []interface{}
-	queryStr := `SELECT tx.item_id, tx.is_l1, tx.id, tx.type, tx.position, 
-	hez_idx(tx.effective_from_idx, token.symbol) AS from_idx, tx.from_eth_addr, tx.from_bjj,
-	hez_idx(tx.to_idx, token.symbol) AS to_idx, tx.to_eth_addr, tx.to_bjj,
-	tx.amount, tx.amount_success, tx.token_id, tx.amount_usd, 
-	tx.batch_num, tx.eth_block_num, tx.to_forge_l1_txs_num, tx.user_origin, 
-	tx.deposit_amount, tx.deposit_amount_usd, tx.deposit_amount_success, tx.fee, tx.fee_usd, tx.nonce,
-	token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
-	token.eth_addr, token.name, token.symbol, token.decimals, token.usd,
-	token.usd_update, block.timestamp, count(*) OVER() AS total_items 
-	FROM tx INNER JOIN token ON tx.token_id = token.token_id 
-	INNER JOIN block ON tx.eth_block_num = block.eth_block_num `
-	// Apply filters
 	nextIsAnd := false
 	// ethAddr filter
 	if request.EthAddr != nil {
@@ -687,6 +874,18 @@ func (hdb *HistoryDB) GetTxsAPI(
 		args = append(args, request.TxType)
 		nextIsAnd = true
 	}
+	// zeroed amounts filter: forged L1 txs whose amount or deposit amount
+	// didn't apply at forge time
+	if request.ZeroedAmounts != nil && *request.ZeroedAmounts {
+		if nextIsAnd {
+			queryStr += "AND "
+		} else {
+			queryStr += "WHERE "
+		}
+		queryStr += "tx.is_l1 AND tx.batch_num IS NOT NULL AND " +
+			"(NOT tx.amount_success OR NOT tx.deposit_amount_success) "
+		nextIsAnd = true
+	}
 	if request.FromItem != nil {
 		if nextIsAnd {
 			queryStr += "AND "
@@ -712,18 +911,51 @@ func (hdb *HistoryDB) GetTxsAPI(
 		queryStr += "tx.batch_num IS NOT NULL "
 	}
 
-	// pagination
 	queryStr += "ORDER BY tx.item_id "
 	if request.Order == db.OrderAsc {
 		queryStr += " ASC "
 	} else {
 		queryStr += " DESC "
 	}
+	return queryStr, args
+}
+
+// GetTxsAPI returns a list of txs from the DB using the HistoryTx struct
+// and pagination info
+func (hdb *HistoryDB) GetTxsAPI(
+	request GetTxsAPIRequest,
+) ([]TxAPI, uint64, error) {
+	// Warning: amount_success and deposit_amount_success have true as default for
+	// performance reasons. The expected default value is false (when txs are unforged)
+	// this case is handled at the function func (tx TxAPI) MarshalJSON() ([]byte, error)
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	if request.EthAddr != nil && request.Bjj != nil {
+		return nil, 0, tracerr.Wrap(errors.New("ethAddr and bjj are incompatible"))
+	}
+	var query string
+	queryStr := `SELECT tx.item_id, tx.is_l1, tx.id, tx.type, tx.position,
+	hez_idx(tx.effective_from_idx, token.symbol) AS from_idx, tx.from_eth_addr, tx.from_bjj,
+	hez_idx(tx.to_idx, token.symbol) AS to_idx, tx.to_eth_addr, tx.to_bjj,
+	tx.amount, tx.amount_success, tx.token_id, tx.amount_usd,
+	tx.batch_num, tx.eth_block_num, tx.to_forge_l1_txs_num, tx.user_origin,
+	tx.deposit_amount, tx.deposit_amount_usd, tx.deposit_amount_success, tx.fee, tx.fee_usd, tx.nonce,
+	token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
+	token.eth_addr, token.name, token.symbol, token.decimals, token.usd,
+	token.usd_update, block.timestamp, count(*) OVER() AS total_items
+	FROM tx INNER JOIN token ON tx.token_id = token.token_id
+	INNER JOIN block ON tx.eth_block_num = block.eth_block_num `
+	filters, args := buildTxsAPIFilters(request)
+	queryStr += filters
 	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
-	query = hdb.dbRead.Rebind(queryStr)
+	query = hdb.read().Rebind(queryStr)
 	// log.Debug(query)
 	txsPtrs := []*TxAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &txsPtrs, query, args...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &txsPtrs, query, args...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	txs := db.SlicePtrsToSlice(txsPtrs).([]TxAPI)
@@ -733,6 +965,55 @@ func (hdb *HistoryDB) GetTxsAPI(
 	return txs, txs[0].TotalItems - uint64(len(txs)), nil
 }
 
+// GetTxsStreamAPI applies the same filters as GetTxsAPI but streams every
+// matching tx to the given callback in order, without pagination, scanning
+// rows one by one so that arbitrarily large histories can be exported with
+// bounded memory.  Iteration stops at the first error returned by the
+// callback.
+func (hdb *HistoryDB) GetTxsStreamAPI(request GetTxsAPIRequest, fn func(tx *TxAPI) error) error {
+	// Warning: amount_success and deposit_amount_success have true as default for
+	// performance reasons. The expected default value is false (when txs are unforged)
+	// this case is handled at the function func (tx TxAPI) MarshalJSON() ([]byte, error)
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	if request.EthAddr != nil && request.Bjj != nil {
+		return tracerr.Wrap(errors.New("ethAddr and bjj are incompatible"))
+	}
+	queryStr := `SELECT tx.item_id, tx.is_l1, tx.id, tx.type, tx.position,
+	hez_idx(tx.effective_from_idx, token.symbol) AS from_idx, tx.from_eth_addr, tx.from_bjj,
+	hez_idx(tx.to_idx, token.symbol) AS to_idx, tx.to_eth_addr, tx.to_bjj,
+	tx.amount, tx.amount_success, tx.token_id, tx.amount_usd,
+	tx.batch_num, tx.eth_block_num, tx.to_forge_l1_txs_num, tx.user_origin,
+	tx.deposit_amount, tx.deposit_amount_usd, tx.deposit_amount_success, tx.fee, tx.fee_usd, tx.nonce,
+	token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
+	token.eth_addr, token.name, token.symbol, token.decimals, token.usd,
+	token.usd_update, block.timestamp
+	FROM tx INNER JOIN token ON tx.token_id = token.token_id
+	INNER JOIN block ON tx.eth_block_num = block.eth_block_num `
+	filters, args := buildTxsAPIFilters(request)
+	queryStr += filters + ";"
+	query := hdb.read().Rebind(queryStr)
+	rows, err := hdb.read().Query(query, args...)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer db.RowsClose(rows)
+	for rows.Next() {
+		tx := &TxAPI{}
+		if err := meddler.Scan(rows, tx); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := fn(tx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return tracerr.Wrap(rows.Err())
+}
+
 // GetExitAPI returns a exit from the DB
 func (hdb *HistoryDB) GetExitAPI(batchNum *uint, idx *common.Idx) (*ExitAPI, error) {
 	cancel, err := hdb.apiConnCon.Acquire()
@@ -743,7 +1024,7 @@ func (hdb *HistoryDB) GetExitAPI(batchNum *uint, idx *common.Idx) (*ExitAPI, err
 	defer hdb.apiConnCon.Release()
 	exit := &ExitAPI{}
 	err = meddler.QueryRow(
-		hdb.dbRead, exit, `SELECT exit_tree.item_id, exit_tree.batch_num,
+		hdb.read(), exit, `SELECT exit_tree.item_id, exit_tree.batch_num,
 		hez_idx(exit_tree.account_idx, token.symbol) AS account_idx,
 		account.bjj, account.eth_addr,
 		exit_tree.merkle_proof, exit_tree.balance, exit_tree.instant_withdrawn,
@@ -755,7 +1036,19 @@ func (hdb *HistoryDB) GetExitAPI(batchNum *uint, idx *common.Idx) (*ExitAPI, err
 		INNER JOIN token ON account.token_id = token.token_id 
 		WHERE exit_tree.batch_num = $1 AND exit_tree.account_idx = $2;`, batchNum, idx,
 	)
-	return exit, tracerr.Wrap(err)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if exit.MerkleProof == nil || exit.MerkleProof.Root == nil {
+		// Databases migrated from older nodes may miss the proof blob:
+		// rebuild the exit tree of the batch and regenerate it
+		proof, err := hdb.RegenerateExitProof(exit.BatchNum, *idx)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		exit.MerkleProof = proof
+	}
+	return exit, nil
 }
 
 // GetExitsAPIRequest is an API request struct for getting exits
@@ -876,10 +1169,10 @@ func (hdb *HistoryDB) GetExitsAPI(
 		queryStr += " DESC "
 	}
 	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
-	query = hdb.dbRead.Rebind(queryStr)
+	query = hdb.read().Rebind(queryStr)
 	// log.Debug(query)
 	exits := []*ExitAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &exits, query, args...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &exits, query, args...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	if len(exits) == 0 {
@@ -953,10 +1246,10 @@ func (hdb *HistoryDB) GetCoordinatorsAPI(
 		queryStr += " DESC "
 	}
 	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
-	query = hdb.dbRead.Rebind(queryStr)
+	query = hdb.read().Rebind(queryStr)
 
 	coordinators := []*CoordinatorAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &coordinators, query, args...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &coordinators, query, args...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	if len(coordinators) == 0 {
@@ -966,8 +1259,103 @@ func (hdb *HistoryDB) GetCoordinatorsAPI(
 		coordinators[0].TotalItems - uint64(len(coordinators)), nil
 }
 
+// GetCoordinatorStatsAPI computes the auction and forging track record of the
+// coordinator registered with the given bidder address.  currentSlot is used
+// to tell apart won slots that are still ongoing or upcoming from won slots
+// that finished without being forged.  Returns sql.ErrNoRows when no
+// coordinator has been registered with the address
+func (hdb *HistoryDB) GetCoordinatorStatsAPI(bidderAddr ethCommon.Address,
+	currentSlot int64) (*CoordinatorStatsAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	// Latest update of the coordinator, to resolve the forger address the
+	// forge events are recorded with
+	coord := &CoordinatorAPI{}
+	if err := meddler.QueryRow(
+		hdb.read(), coord,
+		`SELECT coordinator.* FROM coordinator WHERE bidder_addr = $1
+		ORDER BY item_id DESC LIMIT 1;`,
+		bidderAddr,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	// A slot is won when the latest (and therefore highest) bid of the
+	// slot belongs to the coordinator, following the same criteria as
+	// getBestBidsAPI.  Slots beyond the current one are not counted as
+	// their auction may still be open
+	type coordStatsRow struct {
+		SlotsWon         int64      `meddler:"slots_won"`
+		FinishedWon      int64      `meddler:"finished_won"`
+		SlotsForged      int64      `meddler:"slots_forged"`
+		SlotsMissed      int64      `meddler:"slots_missed"`
+		TotalBatches     int64      `meddler:"total_batches"`
+		TotalForgedSlots int64      `meddler:"total_forged_slots"`
+		FirstForged      *time.Time `meddler:"first_forged"`
+		LastForged       *time.Time `meddler:"last_forged"`
+	}
+	row := &coordStatsRow{}
+	if err := meddler.QueryRow(
+		hdb.read(), row,
+		`WITH won AS (
+			SELECT b.slot_num FROM (
+				SELECT slot_num, MAX(item_id) AS maxitem
+				FROM bid GROUP BY slot_num
+			) AS x INNER JOIN bid AS b ON b.item_id = x.maxitem
+			WHERE b.bidder_addr = $1 AND b.slot_num <= $3
+		), forged AS (
+			SELECT batch.slot_num, block.timestamp
+			FROM batch INNER JOIN block ON batch.eth_block_num = block.eth_block_num
+			WHERE batch.forger_addr = $2
+		)
+		SELECT
+		(SELECT COUNT(*) FROM won) AS slots_won,
+		(SELECT COUNT(*) FROM won WHERE won.slot_num < $3) AS finished_won,
+		(SELECT COUNT(*) FROM won WHERE EXISTS
+			(SELECT 1 FROM forged WHERE forged.slot_num = won.slot_num)) AS slots_forged,
+		(SELECT COUNT(*) FROM won WHERE won.slot_num < $3 AND NOT EXISTS
+			(SELECT 1 FROM forged WHERE forged.slot_num = won.slot_num)) AS slots_missed,
+		(SELECT COUNT(*) FROM forged) AS total_batches,
+		(SELECT COUNT(DISTINCT slot_num) FROM forged) AS total_forged_slots,
+		(SELECT MIN(timestamp) FROM forged) AS first_forged,
+		(SELECT MAX(timestamp) FROM forged) AS last_forged;`,
+		bidderAddr, coord.Forger, currentSlot,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	stats := &CoordinatorStatsAPI{
+		Bidder:             coord.Bidder,
+		Forger:             coord.Forger,
+		URL:                coord.URL,
+		SlotsWon:           row.SlotsWon,
+		SlotsForged:        row.SlotsForged,
+		SlotsMissed:        row.SlotsMissed,
+		TotalForgedBatches: row.TotalBatches,
+		FirstBatchForgedAt: row.FirstForged,
+		LastBatchForgedAt:  row.LastForged,
+	}
+	if row.TotalForgedSlots > 0 {
+		stats.AverageBatchesPerSlot =
+			float64(row.TotalBatches) / float64(row.TotalForgedSlots)
+	}
+	// Won slots that have already finished were either attended (forged)
+	// or missed
+	if row.FinishedWon > 0 {
+		uptime := 1 - float64(row.SlotsMissed)/float64(row.FinishedWon)
+		stats.Uptime = &uptime
+	}
+	return stats, nil
+}
+
 // GetAuctionVarsAPI returns auction variables
 func (hdb *HistoryDB) GetAuctionVarsAPI() (*common.AuctionVariables, error) {
+	const key = "auction-vars"
+	if cached, ok := hdb.apiCache.get(key); ok {
+		return cached.(*common.AuctionVariables), nil
+	}
 	cancel, err := hdb.apiConnCon.Acquire()
 	defer cancel()
 	if err != nil {
@@ -976,8 +1364,11 @@ func (hdb *HistoryDB) GetAuctionVarsAPI() (*common.AuctionVariables, error) {
 	defer hdb.apiConnCon.Release()
 	auctionVars := &common.AuctionVariables{}
 	err = meddler.QueryRow(
-		hdb.dbRead, auctionVars, `SELECT * FROM auction_vars;`,
+		hdb.read(), auctionVars, `SELECT * FROM auction_vars;`,
 	)
+	if err == nil {
+		hdb.apiCache.set(key, auctionVars)
+	}
 	return auctionVars, tracerr.Wrap(err)
 }
 
@@ -990,17 +1381,13 @@ func (hdb *HistoryDB) GetAccountAPI(idx common.Idx) (*AccountAPI, error) {
 	}
 	defer hdb.apiConnCon.Release()
 	account := &AccountAPI{}
-	err = meddler.QueryRow(hdb.dbRead, account, `SELECT account.item_id, hez_idx(account.idx, 
+	err = meddler.QueryRow(hdb.read(), account, `SELECT account.item_id, hez_idx(account.idx, 
 		token.symbol) as idx, account.batch_num, account.bjj, account.eth_addr,
 		token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
 		token.eth_addr as token_eth_addr, token.name, token.symbol, token.decimals, token.usd,
-		token.usd_update, account_update.nonce, account_update.balance 
-		FROM account inner JOIN (
-			SELECT idx, nonce, balance 
-			FROM account_update
-			WHERE idx = $1
-			ORDER BY item_id DESC LIMIT 1
-		) AS account_update ON account_update.idx = account.idx 
+		token.usd_update, account_update.nonce, account_update.balance
+		FROM account INNER JOIN account_state AS account_update
+			ON account_update.idx = account.idx
 		INNER JOIN token ON account.token_id = token.token_id
 		WHERE account.idx = $1;`, idx)
 
@@ -1011,11 +1398,30 @@ func (hdb *HistoryDB) GetAccountAPI(idx common.Idx) (*AccountAPI, error) {
 	return account, nil
 }
 
+// Sort options accepted by GetAccountsAPI in addition to the default sorting
+// by item
+const (
+	// AccountSortByBalance sorts the accounts by their token balance
+	AccountSortByBalance = "balance"
+	// AccountSortByBalanceUSD sorts the accounts by their balance
+	// normalized to USD with the token price and decimals
+	AccountSortByBalanceUSD = "balanceUSD"
+)
+
 // GetAccountsAPIRequest is an API request struct for getting accounts
 type GetAccountsAPIRequest struct {
 	TokenIDs []common.TokenID
 	EthAddr  *ethCommon.Address
 	Bjj      *babyjub.PublicKeyComp
+	// MinBalance and MaxBalance filter the accounts by their token
+	// balance
+	MinBalance *big.Int
+	MaxBalance *big.Int
+	// SortBy sorts the accounts by balance (AccountSortByBalance) or by
+	// balance normalized to USD (AccountSortByBalanceUSD) instead of by
+	// item.  Incompatible with FromItem, as the cursor pagination only
+	// works with the item ordering
+	SortBy string
 
 	FromItem *uint
 	Limit    *uint
@@ -1029,6 +1435,14 @@ func (hdb *HistoryDB) GetAccountsAPI(
 	if request.EthAddr != nil && request.Bjj != nil {
 		return nil, 0, tracerr.Wrap(errors.New("ethAddr and bjj are incompatible"))
 	}
+	switch request.SortBy {
+	case "", AccountSortByBalance, AccountSortByBalanceUSD:
+	default:
+		return nil, 0, tracerr.Wrap(fmt.Errorf("invalid sortBy: %v", request.SortBy))
+	}
+	if request.SortBy != "" && request.FromItem != nil {
+		return nil, 0, tracerr.Wrap(errors.New("sortBy and fromItem are incompatible"))
+	}
 	cancel, err := hdb.apiConnCon.Acquire()
 	defer cancel()
 	if err != nil {
@@ -1037,17 +1451,13 @@ func (hdb *HistoryDB) GetAccountsAPI(
 	defer hdb.apiConnCon.Release()
 	var query string
 	var args []interface{}
-	queryStr := `SELECT account.item_id, hez_idx(account.idx, token.symbol) as idx, account.batch_num, 
+	queryStr := `SELECT account.item_id, hez_idx(account.idx, token.symbol) as idx, account.batch_num,
 	account.bjj, account.eth_addr, token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
-	token.eth_addr as token_eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update, 
+	token.eth_addr as token_eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update,
 	account_update.nonce, account_update.balance, COUNT(*) OVER() AS total_items
-	FROM account INNER JOIN (
-		SELECT DISTINCT idx,
-		first_value(nonce) OVER w AS nonce,
-		first_value(balance) OVER w AS balance
-		FROM account_update
-		WINDOW w as (PARTITION BY idx ORDER BY item_id DESC)
-	) AS account_update ON account_update.idx = account.idx INNER JOIN token ON account.token_id = token.token_id `
+	FROM account INNER JOIN account_state AS account_update
+		ON account_update.idx = account.idx
+	INNER JOIN token ON account.token_id = token.token_id `
 	// Apply filters
 	nextIsAnd := false
 	// ethAddr filter
@@ -1071,6 +1481,27 @@ func (hdb *HistoryDB) GetAccountsAPI(
 		args = append(args, request.TokenIDs)
 		nextIsAnd = true
 	}
+	// balance filters
+	if request.MinBalance != nil {
+		if nextIsAnd {
+			queryStr += "AND "
+		} else {
+			queryStr += "WHERE "
+		}
+		queryStr += "account_update.balance >= ? "
+		args = append(args, request.MinBalance.String())
+		nextIsAnd = true
+	}
+	if request.MaxBalance != nil {
+		if nextIsAnd {
+			queryStr += "AND "
+		} else {
+			queryStr += "WHERE "
+		}
+		queryStr += "account_update.balance <= ? "
+		args = append(args, request.MaxBalance.String())
+		nextIsAnd = true
+	}
 	if request.FromItem != nil {
 		if nextIsAnd {
 			queryStr += "AND "
@@ -1084,8 +1515,18 @@ func (hdb *HistoryDB) GetAccountsAPI(
 		}
 		args = append(args, request.FromItem)
 	}
-	// pagination
-	queryStr += "ORDER BY account.item_id "
+	// sorting and pagination
+	switch request.SortBy {
+	case AccountSortByBalance:
+		queryStr += "ORDER BY account_update.balance "
+	case AccountSortByBalanceUSD:
+		// normalize the balance to USD with the token price and
+		// decimals, pricing unknown tokens at 0
+		queryStr += "ORDER BY (account_update.balance * " +
+			"COALESCE(token.usd, 0) / POWER(10::NUMERIC, token.decimals)) "
+	default:
+		queryStr += "ORDER BY account.item_id "
+	}
 	if request.Order == db.OrderAsc {
 		queryStr += " ASC "
 	} else {
@@ -1096,10 +1537,10 @@ func (hdb *HistoryDB) GetAccountsAPI(
 	if err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
-	query = hdb.dbRead.Rebind(query)
+	query = hdb.read().Rebind(query)
 
 	accounts := []*AccountAPI{}
-	if err := meddler.QueryAll(hdb.dbRead, &accounts, query, argsQ...); err != nil {
+	if err := meddler.QueryAll(hdb.read(), &accounts, query, argsQ...); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
 	if len(accounts) == 0 {
@@ -1110,6 +1551,61 @@ func (hdb *HistoryDB) GetAccountsAPI(
 		accounts[0].TotalItems - uint64(len(accounts)), nil
 }
 
+// GetAccountsByIdentifiersAPI returns the accounts that match any of the
+// given idxs, ethereum addresses or BJJ public keys, using a single query.
+// It is used to resolve a batch of mixed account identifiers at once
+func (hdb *HistoryDB) GetAccountsByIdentifiersAPI(idxs []common.Idx,
+	ethAddrs []ethCommon.Address, bjjs []babyjub.PublicKeyComp) ([]AccountAPI, error) {
+	if len(idxs) == 0 && len(ethAddrs) == 0 && len(bjjs) == 0 {
+		return []AccountAPI{}, nil
+	}
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	var args []interface{}
+	queryStr := `SELECT account.item_id, hez_idx(account.idx, token.symbol) as idx, account.batch_num,
+	account.bjj, account.eth_addr, token.token_id, token.item_id AS token_item_id, token.eth_block_num AS token_block,
+	token.eth_addr as token_eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update,
+	account_update.nonce, account_update.balance, COUNT(*) OVER() AS total_items
+	FROM account INNER JOIN account_state AS account_update
+		ON account_update.idx = account.idx
+	INNER JOIN token ON account.token_id = token.token_id `
+	// Apply the identifier filters as a disjunction
+	var conds []string
+	if len(idxs) > 0 {
+		conds = append(conds, "account.idx IN (?)")
+		args = append(args, idxs)
+	}
+	if len(ethAddrs) > 0 {
+		conds = append(conds, "account.eth_addr IN (?)")
+		args = append(args, ethAddrs)
+	}
+	if len(bjjs) > 0 {
+		conds = append(conds, "account.bjj IN (?)")
+		args = append(args, bjjs)
+	}
+	queryStr += "WHERE " + strings.Join(conds, " OR ") + " "
+	queryStr += "ORDER BY account.item_id ASC;"
+	query, argsQ, err := sqlx.In(queryStr, args...)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	query = hdb.read().Rebind(query)
+
+	accounts := []*AccountAPI{}
+	if err := meddler.QueryAll(hdb.read(), &accounts, query, argsQ...); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if len(accounts) == 0 {
+		return []AccountAPI{}, nil
+	}
+
+	return db.SlicePtrsToSlice(accounts).([]AccountAPI), nil
+}
+
 // GetCommonAccountAPI returns the account associated to an account idx
 func (hdb *HistoryDB) GetCommonAccountAPI(idx common.Idx) (*common.Account, error) {
 	cancel, err := hdb.apiConnCon.Acquire()
@@ -1129,14 +1625,9 @@ func (hdb *HistoryDB) GetCommonAccountAPI(idx common.Idx) (*common.Account, erro
 	}
 	account := &fullAccount{}
 	if err := meddler.QueryRow(
-		hdb.dbRead, account, `SELECT account.idx, token_id, batch_num, bjj, eth_addr, au.nonce, au.balance
-		FROM account INNER JOIN (
-			SELECT DISTINCT idx,
-			first_value(nonce) OVER w AS nonce,
-			first_value(balance) OVER w AS balance
-			FROM account_update
-			WINDOW w as (PARTITION BY idx ORDER BY item_id DESC)
-		) AS au ON au.idx = account.idx
+		hdb.read(), account, `SELECT account.idx, token_id, account.batch_num, bjj, eth_addr,
+			au.nonce, au.balance
+		FROM account INNER JOIN account_state AS au ON au.idx = account.idx
 		WHERE account.idx = $1;`, idx,
 	); err != nil {
 		return nil, tracerr.Wrap(err)
@@ -1163,7 +1654,7 @@ func (hdb *HistoryDB) CanSendToEthAddr(ethAddr ethCommon.Address, tokenID common
 	}
 	defer hdb.apiConnCon.Release()
 
-	row := hdb.dbRead.QueryRow(
+	row := hdb.read().QueryRow(
 		`SELECT (
 			SELECT COUNT(*) > 0 FROM account WHERE eth_addr = $1 AND token_id = $2 LIMIT 1
 		) OR (
@@ -1183,7 +1674,7 @@ func (hdb *HistoryDB) GetCoordinatorAPI(bidderAddr ethCommon.Address) (*Coordina
 		return nil, tracerr.Wrap(err)
 	}
 	defer hdb.apiConnCon.Release()
-	return hdb.getCoordinatorAPI(hdb.dbRead, bidderAddr)
+	return hdb.getCoordinatorAPI(hdb.read(), bidderAddr)
 }
 
 func (hdb *HistoryDB) getCoordinatorAPI(d meddler.DB, bidderAddr ethCommon.Address) (*CoordinatorAPI, error) {
@@ -1211,7 +1702,7 @@ func (hdb *HistoryDB) GetNodeInfoAPI() (*NodeInfo, error) {
 func (hdb *HistoryDB) GetBucketUpdatesInternalAPI() ([]BucketUpdateAPI, error) {
 	var bucketUpdates []*BucketUpdateAPI
 	err := meddler.QueryAll(
-		hdb.dbRead, &bucketUpdates,
+		hdb.read(), &bucketUpdates,
 		`SELECT num_bucket, withdrawals FROM bucket_update 
 			WHERE item_id in(SELECT max(item_id) FROM bucket_update 
 			group by num_bucket) 
@@ -1234,7 +1725,7 @@ func (hdb *HistoryDB) GetNextForgersInternalAPI(auctionVars *common.AuctionVaria
 		Limit:      &limit,
 		Order:      "ASC",
 	}
-	bids, _, err := hdb.getBestBidsAPI(hdb.dbRead, request)
+	bids, _, err := hdb.getBestBidsAPI(hdb.read(), request)
 	if err != nil && tracerr.Unwrap(err) != sql.ErrNoRows {
 		return nil, tracerr.Wrap(err)
 	}
@@ -1250,7 +1741,7 @@ func (hdb *HistoryDB) GetNextForgersInternalAPI(auctionVars *common.AuctionVaria
 		}}
 	} else {
 		// Get all the relevant updates from the DB
-		minBidInfo, err = hdb.getMinBidInfo(hdb.dbRead, currentSlot, lastClosedSlot)
+		minBidInfo, err = hdb.getMinBidInfo(hdb.read(), currentSlot, lastClosedSlot)
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
@@ -1301,7 +1792,7 @@ func (hdb *HistoryDB) GetNextForgersInternalAPI(auctionVars *common.AuctionVaria
 				if !foundForger { // There is no bid or it's smaller than the minimum
 					break
 				}
-				coordinator, err := hdb.getCoordinatorAPI(hdb.dbRead, bids[j].Bidder)
+				coordinator, err := hdb.getCoordinatorAPI(hdb.read(), bids[j].Bidder)
 				if err != nil {
 					return nil, tracerr.Wrap(err)
 				}
@@ -1322,7 +1813,20 @@ func (hdb *HistoryDB) GetNextForgersInternalAPI(auctionVars *common.AuctionVaria
 }
 
 // GetMetricsInternalAPI returns the MetricsAPI
-func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metrics *MetricsAPI, poolLoad int64, err error) {
+func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (*MetricsAPI, int64, error) {
+	key := fmt.Sprintf("metrics %v", lastBatchNum)
+	if cached, ok := hdb.apiCache.get(key); ok {
+		entry := cached.(cachedMetricsEntry)
+		return entry.Metrics, entry.PoolLoad, nil
+	}
+	metrics, poolLoad, err := hdb.getMetricsInternalAPI(lastBatchNum)
+	if err == nil {
+		hdb.apiCache.set(key, cachedMetricsEntry{Metrics: metrics, PoolLoad: poolLoad})
+	}
+	return metrics, poolLoad, err
+}
+
+func (hdb *HistoryDB) getMetricsInternalAPI(lastBatchNum common.BatchNum) (metrics *MetricsAPI, poolLoad int64, err error) {
 	metrics = &MetricsAPI{}
 	type period struct {
 		FromBatchNum  common.BatchNum `meddler:"from_batch_num"`
@@ -1334,7 +1838,7 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 		ToBatchNum: lastBatchNum,
 	}
 	if err := meddler.QueryRow(
-		hdb.dbRead, p, `SELECT
+		hdb.read(), p, `SELECT
 			COALESCE (MIN(batch.batch_num), 0) as from_batch_num,
 			COALESCE (MIN(block.timestamp), NOW()) AS from_timestamp, 
 			COALESCE (MAX(block.timestamp), NOW()) AS to_timestamp
@@ -1343,9 +1847,10 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	); err != nil {
 		return nil, 0, tracerr.Wrap(err)
 	}
-	// Get the amount of txs of that period
-	row := hdb.dbRead.QueryRow(
-		`SELECT COUNT(*) as total_txs FROM tx WHERE tx.batch_num between $1 AND $2;`,
+	// Get the amount of txs of that period from the per-batch summary
+	// maintained by triggers on the tx table
+	row := hdb.read().QueryRow(
+		`SELECT COALESCE (SUM(total_txs), 0) FROM batch_tx_count WHERE batch_num between $1 AND $2;`,
 		p.FromBatchNum, p.ToBatchNum,
 	)
 	var nTxs int
@@ -1370,7 +1875,7 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 		metrics.TransactionsPerBatch = 0
 	}
 	// Get total fee of that period
-	row = hdb.dbRead.QueryRow(
+	row = hdb.read().QueryRow(
 		`SELECT COALESCE (SUM(total_fees_usd), 0) FROM batch WHERE batch_num between $1 AND $2;`,
 		p.FromBatchNum, p.ToBatchNum,
 	)
@@ -1381,8 +1886,8 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	// Set batch frequency
 	metrics.BatchFrequency = seconds / float64(nBatches)
 	// Set avg transaction fee (only L2 txs have fee)
-	row = hdb.dbRead.QueryRow(
-		`SELECT COUNT(*) as total_txs FROM tx WHERE tx.batch_num between $1 AND $2 AND NOT is_l1;`,
+	row = hdb.read().QueryRow(
+		`SELECT COALESCE (SUM(l2_txs), 0) FROM batch_tx_count WHERE batch_num between $1 AND $2;`,
 		p.FromBatchNum, p.ToBatchNum,
 	)
 	var nL2Txs int
@@ -1401,7 +1906,7 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	}
 	ra := &registeredAccounts{}
 	if err := meddler.QueryRow(
-		hdb.dbRead, ra,
+		hdb.read(), ra,
 		`SELECT COUNT(*) AS token_accounts, COUNT(DISTINCT(bjj)) AS wallets FROM account;`,
 	); err != nil {
 		return nil, 0, tracerr.Wrap(err)
@@ -1409,7 +1914,7 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	metrics.TokenAccounts = ra.TokenAccounts
 	metrics.Wallets = ra.Wallets
 	// Get and set estimated time to forge L1 tx
-	row = hdb.dbRead.QueryRow(
+	row = hdb.read().QueryRow(
 		`SELECT COALESCE (AVG(EXTRACT(EPOCH FROM (forged.timestamp - added.timestamp))), 0) FROM tx
 			INNER JOIN block AS added ON tx.eth_block_num = added.eth_block_num
 			INNER JOIN batch AS forged_batch ON tx.batch_num = forged_batch.batch_num
@@ -1423,7 +1928,7 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	}
 	metrics.EstimatedTimeToForgeL1 = timeToForgeL1
 	// Get amount of txs in the pool
-	row = hdb.dbRead.QueryRow(
+	row = hdb.read().QueryRow(
 		`SELECT COUNT(*) FROM tx_pool WHERE state = $1 AND NOT external_delete;`,
 		common.PoolL2TxStatePending,
 	)
@@ -1433,6 +1938,210 @@ func (hdb *HistoryDB) GetMetricsInternalAPI(lastBatchNum common.BatchNum) (metri
 	return metrics, poolLoad, nil
 }
 
+// metricsSnapshotInterval is the minimum time between two stored metrics
+// snapshots.  The state updater refreshes the metrics much more often, but
+// only snapshots spaced by this interval get persisted.
+const metricsSnapshotInterval = 1 * time.Hour
+
+// AddMetricsSnapshotInternalAPI stores a snapshot of the given metrics in the
+// metrics history, unless the newest stored snapshot is more recent than
+// metricsSnapshotInterval
+func (hdb *HistoryDB) AddMetricsSnapshotInternalAPI(metrics *MetricsAPI) error {
+	_, err := hdb.dbWrite.Exec(
+		`INSERT INTO metrics_snapshot (
+			transactions_per_batch,
+			batch_frequency,
+			transactions_per_second,
+			token_accounts,
+			wallets,
+			avg_transaction_fee,
+			estimated_time_to_forge_l1
+		) SELECT $1, $2, $3, $4, $5, $6, $7 WHERE NOT EXISTS (
+			SELECT 1 FROM metrics_snapshot WHERE timestamp > $8
+		);`,
+		metrics.TransactionsPerBatch,
+		metrics.BatchFrequency,
+		metrics.TransactionsPerSecond,
+		metrics.TokenAccounts,
+		metrics.Wallets,
+		metrics.AvgTransactionFee,
+		metrics.EstimatedTimeToForgeL1,
+		time.Now().UTC().Add(-metricsSnapshotInterval),
+	)
+	return tracerr.Wrap(err)
+}
+
+// GetMetricsHistoryAPIRequest is an API request struct for getting the
+// metrics history
+type GetMetricsHistoryAPIRequest struct {
+	FromTimestamp *time.Time
+	ToTimestamp   *time.Time
+
+	FromItem *uint
+	Limit    *uint
+	Order    string
+}
+
+// GetMetricsHistoryAPI returns a list of metrics snapshots from the DB and
+// pagination info
+func (hdb *HistoryDB) GetMetricsHistoryAPI(
+	request GetMetricsHistoryAPIRequest,
+) ([]MetricsSnapshotAPI, uint64, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	var query string
+	var args []interface{}
+	queryStr := `SELECT metrics_snapshot.*, COUNT(*) OVER() AS total_items
+	FROM metrics_snapshot `
+	// Apply filters
+	nextIsAnd := false
+	if request.FromTimestamp != nil {
+		queryStr += "WHERE timestamp >= ? "
+		nextIsAnd = true
+		args = append(args, request.FromTimestamp)
+	}
+	if request.ToTimestamp != nil {
+		if nextIsAnd {
+			queryStr += "AND "
+		} else {
+			queryStr += "WHERE "
+		}
+		queryStr += "timestamp <= ? "
+		nextIsAnd = true
+		args = append(args, request.ToTimestamp)
+	}
+	if request.FromItem != nil {
+		if nextIsAnd {
+			queryStr += "AND "
+		} else {
+			queryStr += "WHERE "
+		}
+		if request.Order == db.OrderAsc {
+			queryStr += "item_id >= ? "
+		} else {
+			queryStr += "item_id <= ? "
+		}
+		args = append(args, request.FromItem)
+	}
+	// pagination
+	queryStr += "ORDER BY item_id "
+	if request.Order == db.OrderAsc {
+		queryStr += "ASC "
+	} else {
+		queryStr += "DESC "
+	}
+	queryStr += fmt.Sprintf("LIMIT %d;", *request.Limit)
+	query = hdb.read().Rebind(queryStr)
+	snapshots := []*MetricsSnapshotAPI{}
+	if err := meddler.QueryAll(hdb.read(), &snapshots, query, args...); err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	if len(snapshots) == 0 {
+		return []MetricsSnapshotAPI{}, 0, nil
+	}
+	return db.SlicePtrsToSlice(snapshots).([]MetricsSnapshotAPI),
+		snapshots[0].TotalItems - uint64(len(snapshots)), nil
+}
+
+// tokenStatsCacheTTL is the time during which the computed stats of a token
+// are served from cache instead of running the aggregation query again
+const tokenStatsCacheTTL = 5 * time.Minute
+
+// tokenStatsCacheEntry is a cached TokenStatsAPI together with the time it
+// was computed
+type tokenStatsCacheEntry struct {
+	stats  *TokenStatsAPI
+	update time.Time
+}
+
+// GetTokenStatsAPI returns the aggregated usage statistics of a token
+// (volume, tx count and unique active accounts over 24h, 7d and since
+// genesis), computed with a single query and cached for tokenStatsCacheTTL
+func (hdb *HistoryDB) GetTokenStatsAPI(tokenID common.TokenID) (*TokenStatsAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	return hdb.getTokenStats(tokenID)
+}
+
+func (hdb *HistoryDB) getTokenStats(tokenID common.TokenID) (*TokenStatsAPI, error) {
+	hdb.tokenStatsMutex.Lock()
+	defer hdb.tokenStatsMutex.Unlock()
+	if entry, ok := hdb.tokenStatsCache[tokenID]; ok &&
+		time.Since(entry.update) < tokenStatsCacheTTL {
+		return entry.stats, nil
+	}
+	type tokenStatsRow struct {
+		TotalTxs         int64    `meddler:"total_txs"`
+		TotalVolume      *big.Int `meddler:"total_volume,bigint"`
+		TotalVolumeUSD   float64  `meddler:"total_volume_usd"`
+		TotalAccounts    int64    `meddler:"total_accounts"`
+		Txs7Days         int64    `meddler:"txs_7d"`
+		Volume7Days      *big.Int `meddler:"volume_7d,bigint"`
+		Volume7DaysUSD   float64  `meddler:"volume_usd_7d"`
+		Accounts7Days    int64    `meddler:"accounts_7d"`
+		Txs24Hours       int64    `meddler:"txs_24h"`
+		Volume24Hours    *big.Int `meddler:"volume_24h,bigint"`
+		Volume24HoursUSD float64  `meddler:"volume_usd_24h"`
+		Accounts24Hours  int64    `meddler:"accounts_24h"`
+	}
+	now := time.Now().UTC()
+	row := &tokenStatsRow{}
+	if err := meddler.QueryRow(
+		hdb.read(), row,
+		`SELECT COUNT(*) AS total_txs,
+		COALESCE(SUM(tx.amount), 0) AS total_volume,
+		COALESCE(SUM(tx.amount_usd), 0) AS total_volume_usd,
+		COUNT(DISTINCT tx.from_idx) AS total_accounts,
+		COUNT(*) FILTER (WHERE block.timestamp > $2) AS txs_7d,
+		COALESCE(SUM(tx.amount) FILTER (WHERE block.timestamp > $2), 0) AS volume_7d,
+		COALESCE(SUM(tx.amount_usd) FILTER (WHERE block.timestamp > $2), 0) AS volume_usd_7d,
+		COUNT(DISTINCT tx.from_idx) FILTER (WHERE block.timestamp > $2) AS accounts_7d,
+		COUNT(*) FILTER (WHERE block.timestamp > $3) AS txs_24h,
+		COALESCE(SUM(tx.amount) FILTER (WHERE block.timestamp > $3), 0) AS volume_24h,
+		COALESCE(SUM(tx.amount_usd) FILTER (WHERE block.timestamp > $3), 0) AS volume_usd_24h,
+		COUNT(DISTINCT tx.from_idx) FILTER (WHERE block.timestamp > $3) AS accounts_24h
+		FROM tx INNER JOIN block ON tx.eth_block_num = block.eth_block_num
+		WHERE tx.token_id = $1 AND tx.batch_num IS NOT NULL;`,
+		tokenID, now.Add(-7*24*time.Hour), now.Add(-24*time.Hour),
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	stats := &TokenStatsAPI{
+		TokenID: tokenID,
+		Last24Hours: TokenStatsWindowAPI{
+			Transactions:   row.Txs24Hours,
+			Volume:         apitypes.NewBigIntStr(row.Volume24Hours),
+			VolumeUSD:      row.Volume24HoursUSD,
+			UniqueAccounts: row.Accounts24Hours,
+		},
+		Last7Days: TokenStatsWindowAPI{
+			Transactions:   row.Txs7Days,
+			Volume:         apitypes.NewBigIntStr(row.Volume7Days),
+			VolumeUSD:      row.Volume7DaysUSD,
+			UniqueAccounts: row.Accounts7Days,
+		},
+		Total: TokenStatsWindowAPI{
+			Transactions:   row.TotalTxs,
+			Volume:         apitypes.NewBigIntStr(row.TotalVolume),
+			VolumeUSD:      row.TotalVolumeUSD,
+			UniqueAccounts: row.TotalAccounts,
+		},
+	}
+	if hdb.tokenStatsCache == nil {
+		hdb.tokenStatsCache = make(map[common.TokenID]*tokenStatsCacheEntry)
+	}
+	hdb.tokenStatsCache[tokenID] = &tokenStatsCacheEntry{stats: stats, update: now}
+	return stats, nil
+}
+
 // GetStateAPI returns the StateAPI
 func (hdb *HistoryDB) GetStateAPI() (*StateAPI, error) {
 	cancel, err := hdb.apiConnCon.Acquire()
@@ -1441,5 +2150,62 @@ func (hdb *HistoryDB) GetStateAPI() (*StateAPI, error) {
 		return nil, tracerr.Wrap(err)
 	}
 	defer hdb.apiConnCon.Release()
-	return hdb.getStateAPI(hdb.dbRead)
+	return hdb.getStateAPI(hdb.read())
+}
+
+// GetExitsSummaryAPI returns the exits of the accounts of the given ethereum
+// address aggregated per token.  The amounts sitting in the withdrawal delayer
+// are split between withdrawable and pending using the withdrawal delay from
+// the last synced wdelayer variables.
+func (hdb *HistoryDB) GetExitsSummaryAPI(
+	ethAddr ethCommon.Address,
+) ([]ExitsSummaryAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	_, _, wDelayer, err := hdb.GetSCVars()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var summary []*ExitsSummaryAPI
+	// The delayed withdrawal request timestamp is the timestamp of the
+	// block in which the request was made, so the delay is counted from
+	// the timestamp of the block referenced by delayed_withdraw_request
+	err = meddler.QueryAll(
+		hdb.dbRead, &summary,
+		`SELECT token.token_id, token.symbol, token.decimals,
+		COALESCE(SUM(exit_tree.balance), 0) AS total_exited,
+		COALESCE(SUM(exit_tree.balance) FILTER (
+			WHERE exit_tree.instant_withdrawn IS NULL
+			AND exit_tree.delayed_withdraw_request IS NULL
+			AND exit_tree.delayed_withdrawn IS NULL), 0) AS withdrawable_instant,
+		COALESCE(SUM(exit_tree.balance) FILTER (
+			WHERE exit_tree.delayed_withdraw_request IS NOT NULL
+			AND exit_tree.delayed_withdrawn IS NULL
+			AND block.timestamp + make_interval(secs => $2) <=
+				timezone('utc', now())), 0) AS withdrawable_delayed,
+		COALESCE(SUM(exit_tree.balance) FILTER (
+			WHERE exit_tree.delayed_withdraw_request IS NOT NULL
+			AND exit_tree.delayed_withdrawn IS NULL
+			AND block.timestamp + make_interval(secs => $2) >
+				timezone('utc', now())), 0) AS pending_delayed,
+		COALESCE(SUM(exit_tree.balance) FILTER (
+			WHERE exit_tree.instant_withdrawn IS NOT NULL), 0) AS withdrawn_instant,
+		COALESCE(SUM(exit_tree.balance) FILTER (
+			WHERE exit_tree.delayed_withdrawn IS NOT NULL), 0) AS withdrawn_delayed
+		FROM exit_tree INNER JOIN account ON exit_tree.account_idx = account.idx
+		INNER JOIN token ON account.token_id = token.token_id
+		LEFT JOIN block ON exit_tree.delayed_withdraw_request = block.eth_block_num
+		WHERE account.eth_addr = $1
+		GROUP BY token.token_id, token.symbol, token.decimals
+		ORDER BY token.token_id;`,
+		ethAddr, int64(wDelayer.WithdrawalDelay),
+	)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return db.SlicePtrsToSlice(summary).([]ExitsSummaryAPI), nil
 }
@@ -0,0 +1,98 @@
+package historydb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/arnaubennassar/hermez-node/metric"
+)
+
+// apiCache is a small in-process cache for the hot read-only API queries
+// (token list, auction vars, batches, metrics).  Entries are keyed by the
+// query parameters and the whole cache is invalidated every time the
+// synchronizer commits or discards a block, so a cached result can never be
+// more stale than the DB view of the API itself.  Because of that, the cache
+// is only enabled when the API runs along the synchronizer (see
+// SetAPICacheEnabled).  Cached results are shared between callers, so they
+// must be treated as read-only.
+type apiCache struct {
+	mutex   sync.RWMutex
+	enabled bool
+	entries map[string]interface{}
+}
+
+func newAPICache() *apiCache {
+	return &apiCache{entries: make(map[string]interface{})}
+}
+
+// get returns the cached result of the query with the given key, recording
+// the hit or miss in the prometheus metrics
+func (c *apiCache) get(key string) (interface{}, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if !c.enabled {
+		return nil, false
+	}
+	entry, ok := c.entries[key]
+	if ok {
+		metric.APIQueryCacheHits.Inc()
+	} else {
+		metric.APIQueryCacheMisses.Inc()
+	}
+	return entry, ok
+}
+
+// set stores the result of the query with the given key
+func (c *apiCache) set(key string, entry interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.enabled {
+		return
+	}
+	c.entries[key] = entry
+}
+
+// invalidate drops every cached entry
+func (c *apiCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.entries) > 0 {
+		c.entries = make(map[string]interface{})
+	}
+}
+
+// SetAPICacheEnabled enables or disables the in-process cache of the hot
+// read-only API queries.  It must only be enabled when the HistoryDB is also
+// written by the local synchronizer, since the invalidation happens when the
+// synchronized blocks are committed
+func (hdb *HistoryDB) SetAPICacheEnabled(enabled bool) {
+	hdb.apiCache.mutex.Lock()
+	defer hdb.apiCache.mutex.Unlock()
+	hdb.apiCache.enabled = enabled
+}
+
+// cachedTokensEntry is the cached result of a GetTokensAPI call
+type cachedTokensEntry struct {
+	Tokens       []TokenWithUSD
+	PendingItems uint64
+}
+
+// cachedMetricsEntry is the cached result of a GetMetricsInternalAPI call
+type cachedMetricsEntry struct {
+	Metrics  *MetricsAPI
+	PoolLoad int64
+}
+
+// tokensCacheKey returns the cache key of a GetTokensAPI call, built from
+// the dereferenced request parameters
+func tokensCacheKey(request GetTokensAPIRequest) string {
+	fromItem, limit := uint(0), uint(0)
+	if request.FromItem != nil {
+		fromItem = *request.FromItem
+	}
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	return fmt.Sprintf("tokens %v %v %q %v %v %q",
+		request.Ids, request.Symbols, request.Name, fromItem, limit, request.Order)
+}
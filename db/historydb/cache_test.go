@@ -0,0 +1,45 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPICache(t *testing.T) {
+	cache := newAPICache()
+	// While disabled the cache neither stores nor returns entries
+	cache.set("foo", 1)
+	_, ok := cache.get("foo")
+	assert.False(t, ok)
+
+	cache.enabled = true
+	cache.set("foo", 1)
+	cached, ok := cache.get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, 1, cached)
+	_, ok = cache.get("bar")
+	assert.False(t, ok)
+
+	cache.invalidate()
+	_, ok = cache.get("foo")
+	assert.False(t, ok)
+}
+
+func TestTokensCacheKey(t *testing.T) {
+	fromItem, limit := uint(20), uint(10)
+	requestA := GetTokensAPIRequest{
+		Symbols:  []string{"ETH", "HEZ"},
+		FromItem: &fromItem,
+		Limit:    &limit,
+		Order:    "ASC",
+	}
+	requestB := requestA
+	// Same parameters give the same key regardless of the pointers
+	fromItemB := fromItem
+	requestB.FromItem = &fromItemB
+	assert.Equal(t, tokensCacheKey(requestA), tokensCacheKey(requestB))
+	// Different parameters give different keys
+	requestB.Symbols = []string{"ETH"}
+	assert.NotEqual(t, tokensCacheKey(requestA), tokensCacheKey(requestB))
+}
@@ -0,0 +1,98 @@
+package historydb
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/statedb"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-merkletree"
+	"github.com/iden3/go-merkletree/db/memory"
+	"github.com/russross/meddler"
+)
+
+// exitTreeLeaf holds the persisted data needed to rebuild one leaf of the
+// exit tree of a batch
+type exitTreeLeaf struct {
+	AccountIdx common.Idx            `meddler:"account_idx"`
+	Balance    *big.Int              `meddler:"balance,bigint"`
+	TokenID    common.TokenID        `meddler:"token_id"`
+	BJJ        babyjub.PublicKeyComp `meddler:"bjj"`
+	EthAddr    ethCommon.Address     `meddler:"eth_addr"`
+}
+
+// RegenerateExitProof rebuilds the exit tree of batchNum from the persisted
+// exit_tree leaves and generates the CircomVerifierProof for idx.  Databases
+// migrated from older nodes may lack the original merkle_proof blob, but the
+// tree is fully determined by the leaves: each one is an account with nonce 0
+// and the exit amount as balance (see txprocessor.applyExit).  Before
+// generating the proof the reconstructed root is checked against the exit
+// root persisted for the batch
+func (hdb *HistoryDB) RegenerateExitProof(batchNum common.BatchNum,
+	idx common.Idx) (*merkletree.CircomVerifierProof, error) {
+	var leaves []*exitTreeLeaf
+	err := meddler.QueryAll(
+		hdb.read(), &leaves,
+		`SELECT exit_tree.account_idx, exit_tree.balance,
+		account.token_id, account.bjj, account.eth_addr
+		FROM exit_tree INNER JOIN account ON exit_tree.account_idx = account.idx
+		WHERE exit_tree.batch_num = $1;`,
+		batchNum,
+	)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	exitTree, err := merkletree.NewMerkleTree(memory.NewMemoryStorage(), statedb.MaxNLevels)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	found := false
+	for _, leaf := range leaves {
+		account := common.Account{
+			TokenID: leaf.TokenID,
+			Nonce:   common.Nonce(0),
+			Balance: leaf.Balance,
+			BJJ:     leaf.BJJ,
+			EthAddr: leaf.EthAddr,
+		}
+		v, err := account.HashValue()
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if err := exitTree.Add(leaf.AccountIdx.BigInt(), v); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if leaf.AccountIdx == idx {
+			found = true
+		}
+	}
+	if !found {
+		return nil, tracerr.Wrap(sql.ErrNoRows)
+	}
+	var batch struct {
+		ExitRoot *big.Int `meddler:"exit_root,bigint"`
+	}
+	err = meddler.QueryRow(
+		hdb.read(), &batch,
+		"SELECT exit_root FROM batch WHERE batch_num = $1;",
+		batchNum,
+	)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if batch.ExitRoot != nil && exitTree.Root().BigInt().Cmp(batch.ExitRoot) != 0 {
+		return nil, tracerr.Wrap(fmt.Errorf(
+			"regenerated exit tree root (%v) of batch %v doesn't match the "+
+				"persisted exit root (%v)",
+			exitTree.Root().BigInt(), batchNum, batch.ExitRoot))
+	}
+	proof, err := exitTree.GenerateSCVerifierProof(idx.BigInt(), nil)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return proof, nil
+}
@@ -22,14 +22,19 @@ In some cases, some of the structs defined in this file also include custom Mars
 package historydb
 
 import (
+	"context"
 	"math"
 	"math/big"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-merkletree"
 	"github.com/jmoiron/sqlx"
 
 	//nolint:errcheck // driver for postgres DB
@@ -39,28 +44,110 @@ import (
 
 // TODO(Edu): Document here how HistoryDB is kept consistent
 
+// DefaultStalePriceWindow is the default time since the last update of a
+// token USD price after which the price is considered stale
+const DefaultStalePriceWindow = 24 * time.Hour
+
 // HistoryDB persist the historic of the rollup
 type HistoryDB struct {
 	dbRead     *sqlx.DB
 	dbWrite    *sqlx.DB
+	readRouter *db.ReadRouter
 	apiConnCon *db.APIConnectionController
+	// Time since the last update of a token USD price after which the
+	// price is considered stale (see SetStalePriceWindow)
+	stalePriceWindow time.Duration
+	// Token stats cache (see GetTokenStatsAPI)
+	tokenStatsMutex sync.Mutex
+	tokenStatsCache map[common.TokenID]*tokenStatsCacheEntry
+	// Hot read-only API query cache (see cache.go)
+	apiCache *apiCache
 }
 
 // NewHistoryDB initialize the DB
 func NewHistoryDB(dbRead, dbWrite *sqlx.DB, apiConnCon *db.APIConnectionController) *HistoryDB {
 	return &HistoryDB{
-		dbRead:     dbRead,
-		dbWrite:    dbWrite,
-		apiConnCon: apiConnCon,
+		dbRead:           dbRead,
+		dbWrite:          dbWrite,
+		apiConnCon:       apiConnCon,
+		apiCache:         newAPICache(),
+		stalePriceWindow: DefaultStalePriceWindow,
+	}
+}
+
+// SetStalePriceWindow overrides the time since the last update of a token
+// USD price after which the price is considered stale.  Stale prices are
+// flagged in the token API responses and excluded from the fee USD
+// computations, so that old prices don't skew metrics and recommended fees
+func (hdb *HistoryDB) SetStalePriceWindow(window time.Duration) {
+	hdb.stalePriceWindow = window
+}
+
+// isPriceStale returns true when a token USD price whose last update
+// happened at usdUpdate is considered stale
+func (hdb *HistoryDB) isPriceStale(usdUpdate *time.Time) bool {
+	if usdUpdate == nil {
+		return true
+	}
+	return time.Since(*usdUpdate) > hdb.stalePriceWindow
+}
+
+// markStalePrices sets the PriceStale flag of the tokens whose USD price
+// hasn't been updated within the stale price window
+func (hdb *HistoryDB) markStalePrices(tokens []TokenWithUSD) {
+	for i := range tokens {
+		if tokens[i].USD != nil && hdb.isPriceStale(tokens[i].USDUpdate) {
+			tokens[i].PriceStale = true
+		}
 	}
 }
 
+// SetReadRouter makes the read queries go through the given router, which
+// distributes them among a set of read replicas, instead of always using the
+// read connection
+func (hdb *HistoryDB) SetReadRouter(readRouter *db.ReadRouter) {
+	hdb.readRouter = readRouter
+}
+
+// read returns the connection to use for the next read query: a replica
+// picked by the read router when one is configured, or the plain read
+// connection otherwise
+func (hdb *HistoryDB) read() *sqlx.DB {
+	if hdb.readRouter != nil {
+		return hdb.readRouter.Read()
+	}
+	return hdb.dbRead
+}
+
 // DB returns a pointer to the L2DB.db. This method should be used only for
 // internal testing purposes.
 func (hdb *HistoryDB) DB() *sqlx.DB {
 	return hdb.dbWrite
 }
 
+// PingRead checks the read connection (or the read replica that would serve
+// the next query).  Used by the readiness endpoint
+func (hdb *HistoryDB) PingRead(ctx context.Context) error {
+	return tracerr.Wrap(hdb.read().PingContext(ctx))
+}
+
+// PingWrite checks the write connection.  Used by the readiness endpoint
+func (hdb *HistoryDB) PingWrite(ctx context.Context) error {
+	return tracerr.Wrap(hdb.dbWrite.PingContext(ctx))
+}
+
+// bulkWrite inserts all the elements of args into the given table.  When d is
+// a transaction, which is the case of the synchronizer ingestion through
+// AddBlockSCData, the write is done with a COPY statement to minimize the
+// per-row insert overhead; otherwise it falls back to a bulk INSERT with the
+// given query (following the BulkInsert query format)
+func (hdb *HistoryDB) bulkWrite(d meddler.DB, table, q string, args interface{}) error {
+	if txn, ok := d.(*sqlx.Tx); ok {
+		return tracerr.Wrap(db.BulkCopy(txn, table, args))
+	}
+	return tracerr.Wrap(db.BulkInsert(d, q, args))
+}
+
 // AddBlock insert a block into the DB
 func (hdb *HistoryDB) AddBlock(block *common.Block) error { return hdb.addBlock(hdb.dbWrite, block) }
 func (hdb *HistoryDB) addBlock(d meddler.DB, block *common.Block) error {
@@ -73,8 +160,8 @@ func (hdb *HistoryDB) AddBlocks(blocks []common.Block) error {
 }
 
 func (hdb *HistoryDB) addBlocks(d meddler.DB, blocks []common.Block) error {
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "block",
 		`INSERT INTO block (
 			eth_block_num,
 			timestamp,
@@ -88,7 +175,7 @@ func (hdb *HistoryDB) addBlocks(d meddler.DB, blocks []common.Block) error {
 func (hdb *HistoryDB) GetBlock(blockNum int64) (*common.Block, error) {
 	block := &common.Block{}
 	err := meddler.QueryRow(
-		hdb.dbRead, block,
+		hdb.read(), block,
 		"SELECT * FROM block WHERE eth_block_num = $1;", blockNum,
 	)
 	return block, tracerr.Wrap(err)
@@ -98,7 +185,7 @@ func (hdb *HistoryDB) GetBlock(blockNum int64) (*common.Block, error) {
 func (hdb *HistoryDB) GetAllBlocks() ([]common.Block, error) {
 	var blocks []*common.Block
 	err := meddler.QueryAll(
-		hdb.dbRead, &blocks,
+		hdb.read(), &blocks,
 		"SELECT * FROM block ORDER BY eth_block_num;",
 	)
 	return db.SlicePtrsToSlice(blocks).([]common.Block), tracerr.Wrap(err)
@@ -108,7 +195,7 @@ func (hdb *HistoryDB) GetAllBlocks() ([]common.Block, error) {
 func (hdb *HistoryDB) getBlocks(from, to int64) ([]common.Block, error) {
 	var blocks []*common.Block
 	err := meddler.QueryAll(
-		hdb.dbRead, &blocks,
+		hdb.read(), &blocks,
 		"SELECT * FROM block WHERE $1 <= eth_block_num AND eth_block_num < $2 ORDER BY eth_block_num;",
 		from, to,
 	)
@@ -119,7 +206,7 @@ func (hdb *HistoryDB) getBlocks(from, to int64) ([]common.Block, error) {
 func (hdb *HistoryDB) GetLastBlock() (*common.Block, error) {
 	block := &common.Block{}
 	err := meddler.QueryRow(
-		hdb.dbRead, block, "SELECT * FROM block ORDER BY eth_block_num DESC LIMIT 1;",
+		hdb.read(), block, "SELECT * FROM block ORDER BY eth_block_num DESC LIMIT 1;",
 	)
 	return block, tracerr.Wrap(err)
 }
@@ -135,14 +222,15 @@ func (hdb *HistoryDB) addBatch(d meddler.DB, batch *common.Batch) error {
 	}
 	// Get USD value of the tokens
 	type tokenPrice struct {
-		ID       common.TokenID `meddler:"token_id"`
-		USD      *float64       `meddler:"usd"`
-		Decimals int            `meddler:"decimals"`
+		ID        common.TokenID `meddler:"token_id"`
+		USD       *float64       `meddler:"usd"`
+		USDUpdate *time.Time     `meddler:"usd_update,utctime"`
+		Decimals  int            `meddler:"decimals"`
 	}
 	var tokenPrices []*tokenPrice
 	if len(tokenIDs) > 0 {
 		query, args, err := sqlx.In(
-			"SELECT token_id, usd, decimals FROM token WHERE token_id IN (?);",
+			"SELECT token_id, usd, usd_update, decimals FROM token WHERE token_id IN (?);",
 			tokenIDs,
 		)
 		if err != nil {
@@ -155,17 +243,31 @@ func (hdb *HistoryDB) addBatch(d meddler.DB, batch *common.Batch) error {
 			return tracerr.Wrap(err)
 		}
 	}
-	// Calculate total collected
-	var total float64
+	prices := make(map[common.TokenID]*tokenPrice)
 	for _, tokenPrice := range tokenPrices {
-		if tokenPrice.USD == nil {
+		prices[tokenPrice.ID] = tokenPrice
+	}
+	// Calculate total collected.  If any non zero collected fee can't be
+	// valued because the token has no known price or a stale one, the
+	// total is left NULL instead of recording a wrong value
+	var total float64
+	validTotal := true
+	for id, collected := range batch.CollectedFees {
+		if collected.Sign() == 0 {
 			continue
 		}
-		f := new(big.Float).SetInt(batch.CollectedFees[tokenPrice.ID])
+		price := prices[id]
+		if price == nil || price.USD == nil || hdb.isPriceStale(price.USDUpdate) {
+			validTotal = false
+			break
+		}
+		f := new(big.Float).SetInt(collected)
 		amount, _ := f.Float64()
-		total += *tokenPrice.USD * (amount / math.Pow(10, float64(tokenPrice.Decimals))) //nolint decimals have to be ^10
+		total += *price.USD * (amount / math.Pow(10, float64(price.Decimals))) //nolint decimals have to be ^10
+	}
+	if validTotal {
+		batch.TotalFeesUSD = &total
 	}
-	batch.TotalFeesUSD = &total
 	// Insert to DB
 	return tracerr.Wrap(meddler.Insert(d, "batch", batch))
 }
@@ -183,14 +285,49 @@ func (hdb *HistoryDB) addBatches(d meddler.DB, batches []common.Batch) error {
 	return nil
 }
 
+// BatchCoordAccount is the Coordinator fee account that was used to forge a
+// batch
+type BatchCoordAccount struct {
+	BatchNum common.BatchNum       `meddler:"batch_num"`
+	Addr     ethCommon.Address     `meddler:"addr"`
+	BJJ      babyjub.PublicKeyComp `meddler:"bjj"`
+}
+
+// AddBatchCoordAccount stores the Coordinator fee account used to forge a
+// batch, overwriting the previous record when the batch is forged again after
+// a reorg or a pipeline reset
+func (hdb *HistoryDB) AddBatchCoordAccount(batchCoordAccount *BatchCoordAccount) error {
+	_, err := hdb.dbWrite.Exec(
+		`INSERT INTO batch_coord_account (batch_num, addr, bjj)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (batch_num) DO UPDATE
+		SET addr = excluded.addr, bjj = excluded.bjj;`,
+		batchCoordAccount.BatchNum, batchCoordAccount.Addr,
+		batchCoordAccount.BJJ,
+	)
+	return tracerr.Wrap(err)
+}
+
+// GetBatchCoordAccount returns the Coordinator fee account that was used to
+// forge the given batch
+func (hdb *HistoryDB) GetBatchCoordAccount(batchNum common.BatchNum) (*BatchCoordAccount, error) {
+	batchCoordAccount := &BatchCoordAccount{}
+	err := meddler.QueryRow(
+		hdb.read(), batchCoordAccount,
+		"SELECT * FROM batch_coord_account WHERE batch_num = $1;", batchNum,
+	)
+	return batchCoordAccount, tracerr.Wrap(err)
+}
+
 // GetBatch returns the batch with the given batchNum
 func (hdb *HistoryDB) GetBatch(batchNum common.BatchNum) (*common.Batch, error) {
 	var batch common.Batch
 	err := meddler.QueryRow(
-		hdb.dbRead, &batch, `SELECT batch.batch_num, batch.eth_block_num, batch.forger_addr,
+		hdb.read(), &batch, `SELECT batch.batch_num, batch.eth_block_num, batch.forger_addr,
 		batch.fees_collected, batch.fee_idxs_coordinator, batch.state_root,
 		batch.num_accounts, batch.last_idx, batch.exit_root, batch.forge_l1_txs_num,
-		batch.slot_num, batch.total_fees_usd FROM batch WHERE batch_num = $1;`,
+		batch.slot_num, batch.total_fees_usd, batch.eth_tx_hash FROM batch
+		WHERE batch_num = $1;`,
 		batchNum,
 	)
 	return &batch, tracerr.Wrap(err)
@@ -200,7 +337,7 @@ func (hdb *HistoryDB) GetBatch(batchNum common.BatchNum) (*common.Batch, error)
 func (hdb *HistoryDB) GetAllBatches() ([]common.Batch, error) {
 	var batches []*common.Batch
 	err := meddler.QueryAll(
-		hdb.dbRead, &batches,
+		hdb.read(), &batches,
 		`SELECT batch.batch_num, batch.eth_block_num, batch.forger_addr, batch.fees_collected,
 		 batch.fee_idxs_coordinator, batch.state_root, batch.num_accounts, batch.last_idx, batch.exit_root,
 		 batch.forge_l1_txs_num, batch.slot_num, batch.total_fees_usd, batch.eth_tx_hash FROM batch
@@ -213,7 +350,7 @@ func (hdb *HistoryDB) GetAllBatches() ([]common.Batch, error) {
 func (hdb *HistoryDB) GetBatches(from, to common.BatchNum) ([]common.Batch, error) {
 	var batches []*common.Batch
 	err := meddler.QueryAll(
-		hdb.dbRead, &batches,
+		hdb.read(), &batches,
 		`SELECT batch_num, eth_block_num, forger_addr, fees_collected, fee_idxs_coordinator, 
 		state_root, num_accounts, last_idx, exit_root, forge_l1_txs_num, slot_num, total_fees_usd 
 		FROM batch WHERE $1 <= batch_num AND batch_num < $2 ORDER BY batch_num;`,
@@ -225,7 +362,7 @@ func (hdb *HistoryDB) GetBatches(from, to common.BatchNum) ([]common.Batch, erro
 // GetFirstBatchBlockNumBySlot returns the ethereum block number of the first
 // batch within a slot
 func (hdb *HistoryDB) GetFirstBatchBlockNumBySlot(slotNum int64) (int64, error) {
-	row := hdb.dbRead.QueryRow(
+	row := hdb.read().QueryRow(
 		`SELECT eth_block_num FROM batch
 		WHERE slot_num = $1 ORDER BY batch_num ASC LIMIT 1;`, slotNum,
 	)
@@ -235,7 +372,7 @@ func (hdb *HistoryDB) GetFirstBatchBlockNumBySlot(slotNum int64) (int64, error)
 
 // GetLastBatchNum returns the BatchNum of the latest forged batch
 func (hdb *HistoryDB) GetLastBatchNum() (common.BatchNum, error) {
-	row := hdb.dbRead.QueryRow("SELECT batch_num FROM batch ORDER BY batch_num DESC LIMIT 1;")
+	row := hdb.read().QueryRow("SELECT batch_num FROM batch ORDER BY batch_num DESC LIMIT 1;")
 	var batchNum common.BatchNum
 	return batchNum, tracerr.Wrap(row.Scan(&batchNum))
 }
@@ -244,7 +381,7 @@ func (hdb *HistoryDB) GetLastBatchNum() (common.BatchNum, error) {
 func (hdb *HistoryDB) GetLastBatch() (*common.Batch, error) {
 	var batch common.Batch
 	err := meddler.QueryRow(
-		hdb.dbRead, &batch, `SELECT batch.batch_num, batch.eth_block_num, batch.forger_addr,
+		hdb.read(), &batch, `SELECT batch.batch_num, batch.eth_block_num, batch.forger_addr,
 		batch.fees_collected, batch.fee_idxs_coordinator, batch.state_root,
 		batch.num_accounts, batch.last_idx, batch.exit_root, batch.forge_l1_txs_num,
 		batch.slot_num, batch.total_fees_usd FROM batch ORDER BY batch_num DESC LIMIT 1;`,
@@ -254,7 +391,7 @@ func (hdb *HistoryDB) GetLastBatch() (*common.Batch, error) {
 
 // GetLastL1BatchBlockNum returns the blockNum of the latest forged l1Batch
 func (hdb *HistoryDB) GetLastL1BatchBlockNum() (int64, error) {
-	row := hdb.dbRead.QueryRow(`SELECT eth_block_num FROM batch
+	row := hdb.read().QueryRow(`SELECT eth_block_num FROM batch
 		WHERE forge_l1_txs_num IS NOT NULL
 		ORDER BY batch_num DESC LIMIT 1;`)
 	var blockNum int64
@@ -264,7 +401,7 @@ func (hdb *HistoryDB) GetLastL1BatchBlockNum() (int64, error) {
 // GetLastL1TxsNum returns the greatest ForgeL1TxsNum in the DB from forged
 // batches.  If there's no batch in the DB (nil, nil) is returned.
 func (hdb *HistoryDB) GetLastL1TxsNum() (*int64, error) {
-	row := hdb.dbRead.QueryRow("SELECT MAX(forge_l1_txs_num) FROM batch;")
+	row := hdb.read().QueryRow("SELECT MAX(forge_l1_txs_num) FROM batch;")
 	lastL1TxsNum := new(int64)
 	return lastL1TxsNum, tracerr.Wrap(row.Scan(&lastL1TxsNum))
 }
@@ -279,6 +416,10 @@ func (hdb *HistoryDB) Reorg(lastValidBlock int64) error {
 	} else {
 		_, err = hdb.dbWrite.Exec("DELETE FROM block WHERE eth_block_num > $1;", lastValidBlock)
 	}
+	if err == nil {
+		// The discarded blocks can make any cached API query stale
+		hdb.apiCache.invalidate()
+	}
 	return tracerr.Wrap(err)
 }
 
@@ -289,8 +430,8 @@ func (hdb *HistoryDB) addBids(d meddler.DB, bids []common.Bid) error {
 		return nil
 	}
 	// TODO: check the coordinator info
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "bid",
 		"INSERT INTO bid (slot_num, bid_value, eth_block_num, bidder_addr) VALUES %s;",
 		bids,
 	))
@@ -300,7 +441,7 @@ func (hdb *HistoryDB) addBids(d meddler.DB, bids []common.Bid) error {
 func (hdb *HistoryDB) GetAllBids() ([]common.Bid, error) {
 	var bids []*common.Bid
 	err := meddler.QueryAll(
-		hdb.dbRead, &bids,
+		hdb.read(), &bids,
 		`SELECT bid.slot_num, bid.bid_value, bid.eth_block_num, bid.bidder_addr FROM bid
 		ORDER BY item_id;`,
 	)
@@ -311,7 +452,7 @@ func (hdb *HistoryDB) GetAllBids() ([]common.Bid, error) {
 func (hdb *HistoryDB) GetBestBidCoordinator(slotNum int64) (*common.BidCoordinator, error) {
 	bidCoord := &common.BidCoordinator{}
 	err := meddler.QueryRow(
-		hdb.dbRead, bidCoord,
+		hdb.read(), bidCoord,
 		`SELECT (
 			SELECT default_slot_set_bid
 			FROM auction_vars
@@ -340,8 +481,8 @@ func (hdb *HistoryDB) addCoordinators(d meddler.DB, coordinators []common.Coordi
 	if len(coordinators) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "coordinator",
 		"INSERT INTO coordinator (bidder_addr, forger_addr, eth_block_num, url) VALUES %s;",
 		coordinators,
 	))
@@ -355,8 +496,8 @@ func (hdb *HistoryDB) addExitTree(d meddler.DB, exitTree []common.ExitInfo) erro
 	if len(exitTree) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "exit_tree",
 		"INSERT INTO exit_tree (batch_num, account_idx, merkle_proof, balance, "+
 			"instant_withdrawn, delayed_withdraw_request, delayed_withdrawn) VALUES %s;",
 		exitTree,
@@ -454,8 +595,8 @@ func (hdb *HistoryDB) addTokens(d meddler.DB, tokens []common.Token) error {
 		token.Symbol = strings.ToValidUTF8(token.Symbol, " ")
 		tokens[i] = token
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "token",
 		`INSERT INTO token (
 			token_id,
 			eth_block_num,
@@ -469,23 +610,107 @@ func (hdb *HistoryDB) addTokens(d meddler.DB, tokens []common.Token) error {
 }
 
 // UpdateTokenValue updates the USD value of a token.  Value is the price in
-// USD of a normalized token (1 token = 10^decimals units)
+// USD of a normalized token (1 token = 10^decimals units).  Tokens whose
+// price has been pinned by the operator are not updated
 func (hdb *HistoryDB) UpdateTokenValue(tokenAddr ethCommon.Address, value float64) error {
 	_, err := hdb.dbWrite.Exec(
-		"UPDATE token SET usd = $1 WHERE eth_addr = $2;",
+		"UPDATE token SET usd = $1 WHERE eth_addr = $2 AND usd_pinned = false;",
 		value, tokenAddr,
 	)
+	if err == nil {
+		// Token prices are part of the cached token queries
+		hdb.apiCache.invalidate()
+	}
 	return tracerr.Wrap(err)
 }
 
+// GetLastTokenUSDUpdate returns the most recent price update timestamp among
+// all tokens, nil when no token has been priced yet.  Used by the readiness
+// endpoint to report the freshness of the price updater
+func (hdb *HistoryDB) GetLastTokenUSDUpdate() (*time.Time, error) {
+	var lastUpdate *time.Time
+	row := hdb.read().QueryRow("SELECT MAX(usd_update) FROM token;")
+	return lastUpdate, tracerr.Wrap(row.Scan(&lastUpdate))
+}
+
 // UpdateTokenValueByTokenID updates the USD value of a token. Value is the price in
-// USD of a normalized token (1 token = 10^decimals units)
+// USD of a normalized token (1 token = 10^decimals units).  Tokens whose
+// price has been pinned by the operator are not updated
 func (hdb *HistoryDB) UpdateTokenValueByTokenID(tokenID uint, value float64) error {
 	// usd_update field is gonna be updated automatically due to trigger trigger_token_usd_update
 	_, err := hdb.dbWrite.Exec(
-		"UPDATE token SET usd = $1 WHERE token_id = $2;",
+		"UPDATE token SET usd = $1 WHERE token_id = $2 AND usd_pinned = false;",
 		value, tokenID,
 	)
+	if err == nil {
+		hdb.apiCache.invalidate()
+	}
+	return tracerr.Wrap(err)
+}
+
+// PinTokenPrice sets a manual USD price override for a token.  While pinned,
+// the price updater leaves the token untouched
+func (hdb *HistoryDB) PinTokenPrice(tokenID common.TokenID, value float64) error {
+	_, err := hdb.dbWrite.Exec(
+		"UPDATE token SET usd = $1, usd_pinned = true WHERE token_id = $2;",
+		value, tokenID,
+	)
+	if err == nil {
+		hdb.apiCache.invalidate()
+	}
+	return tracerr.Wrap(err)
+}
+
+// UnpinTokenPrice clears the manual price override of a token, letting the
+// price updater manage its price again
+func (hdb *HistoryDB) UnpinTokenPrice(tokenID common.TokenID) error {
+	_, err := hdb.dbWrite.Exec(
+		"UPDATE token SET usd_pinned = false WHERE token_id = $1;",
+		tokenID,
+	)
+	if err == nil {
+		hdb.apiCache.invalidate()
+	}
+	return tracerr.Wrap(err)
+}
+
+// TxUSDMissing is a forged tx whose USD values couldn't be set at insertion
+// time because its token had no known price yet
+type TxUSDMissing struct {
+	TxID      common.TxID    `meddler:"id"`
+	TokenID   common.TokenID `meddler:"token_id"`
+	Timestamp time.Time      `meddler:"timestamp,utctime"`
+}
+
+// GetTxsWithoutUSD returns up to limit forged txs whose amount_usd is NULL,
+// oldest first, so that their historic token prices can be backfilled
+func (hdb *HistoryDB) GetTxsWithoutUSD(limit uint) ([]TxUSDMissing, error) {
+	var txs []*TxUSDMissing
+	err := meddler.QueryAll(
+		hdb.dbRead, &txs,
+		`SELECT tx.id, tx.token_id, block.timestamp FROM tx
+		INNER JOIN block ON tx.eth_block_num = block.eth_block_num
+		WHERE tx.amount_usd IS NULL AND tx.batch_num IS NOT NULL
+		ORDER BY tx.item_id ASC LIMIT $1;`,
+		limit,
+	)
+	return db.SlicePtrsToSlice(txs).([]TxUSDMissing), tracerr.Wrap(err)
+}
+
+// UpdateTxUSDByPrice sets the USD values of a tx from the given token price
+// in USD at the moment the tx was forged, following the same formulas used by
+// the insertion trigger (amount_usd = price * amount_f, fee_usd relative to
+// amount_usd through fee_percentage)
+func (hdb *HistoryDB) UpdateTxUSDByPrice(txID common.TxID, priceUSD float64) error {
+	_, err := hdb.dbWrite.Exec(
+		`UPDATE tx SET
+			amount_usd = $2 * amount_f,
+			fee_usd = CASE WHEN fee IS NOT NULL
+				THEN $2 * amount_f * fee_percentage(fee::NUMERIC) END,
+			deposit_amount_usd = $2 * deposit_amount_f
+		WHERE id = $1;`,
+		txID, priceUSD,
+	)
 	return tracerr.Wrap(err)
 }
 
@@ -513,7 +738,7 @@ func (hdb *HistoryDB) CreateFiatPrice(currency string, baseCurrency string, pric
 func (hdb *HistoryDB) GetFiatPrice(currency, baseCurrency string) (FiatCurrency, error) {
 	var currencyPrice = &FiatCurrency{}
 	err := meddler.QueryRow(
-		hdb.dbRead, currencyPrice, `SELECT currency, base_currency, price, last_update FROM fiat WHERE currency = $1 AND base_currency = $2;`,
+		hdb.read(), currencyPrice, `SELECT currency, base_currency, price, last_update FROM fiat WHERE currency = $1 AND base_currency = $2;`,
 		currency, baseCurrency,
 	)
 	return *currencyPrice, tracerr.Wrap(err)
@@ -523,7 +748,7 @@ func (hdb *HistoryDB) GetFiatPrice(currency, baseCurrency string) (FiatCurrency,
 func (hdb *HistoryDB) GetAllFiatPrice(baseCurrency string) ([]FiatCurrency, error) {
 	var currencyPrices []*FiatCurrency
 	err := meddler.QueryAll(
-		hdb.dbRead, &currencyPrices, `SELECT currency, base_currency, price, last_update FROM fiat WHERE base_currency = $1;`,
+		hdb.read(), &currencyPrices, `SELECT currency, base_currency, price, last_update FROM fiat WHERE base_currency = $1;`,
 		baseCurrency,
 	)
 	return db.SlicePtrsToSlice(currencyPrices).([]FiatCurrency), tracerr.Wrap(err)
@@ -533,26 +758,59 @@ func (hdb *HistoryDB) GetAllFiatPrice(baseCurrency string) ([]FiatCurrency, erro
 func (hdb *HistoryDB) GetToken(tokenID common.TokenID) (*TokenWithUSD, error) {
 	token := &TokenWithUSD{}
 	err := meddler.QueryRow(
-		hdb.dbRead, token, `SELECT * FROM token WHERE token_id = $1;`, tokenID,
+		hdb.read(), token, `SELECT * FROM token WHERE token_id = $1;`, tokenID,
 	)
+	if err == nil && token.USD != nil && hdb.isPriceStale(token.USDUpdate) {
+		token.PriceStale = true
+	}
 	return token, tracerr.Wrap(err)
 }
 
+// GetTokenByEthAddr returns a token from the DB given its ethereum address
+func (hdb *HistoryDB) GetTokenByEthAddr(ethAddr ethCommon.Address) (*TokenWithUSD, error) {
+	token := &TokenWithUSD{}
+	err := meddler.QueryRow(
+		hdb.read(), token, `SELECT * FROM token WHERE eth_addr = $1;`, ethAddr,
+	)
+	return token, tracerr.Wrap(err)
+}
+
+// GetTokensCount returns the number of tokens registered in the DB
+func (hdb *HistoryDB) GetTokensCount() (int64, error) {
+	row := hdb.read().QueryRow(
+		`SELECT COUNT(*) FROM token;`,
+	)
+	var count int64
+	return count, tracerr.Wrap(row.Scan(&count))
+}
+
 // GetAllTokens returns all tokens from the DB
 func (hdb *HistoryDB) GetAllTokens() ([]TokenWithUSD, error) {
 	var tokens []*TokenWithUSD
 	err := meddler.QueryAll(
-		hdb.dbRead, &tokens,
+		hdb.read(), &tokens,
 		"SELECT * FROM token ORDER BY token_id;",
 	)
 	return db.SlicePtrsToSlice(tokens).([]TokenWithUSD), tracerr.Wrap(err)
 }
 
+// GetStaleTokenSymbols returns the symbols of the tokens that have a USD
+// price that hasn't been updated within the stale price window
+func (hdb *HistoryDB) GetStaleTokenSymbols() ([]string, error) {
+	var symbols []string
+	err := hdb.read().Select(&symbols,
+		`SELECT symbol FROM token WHERE usd IS NOT NULL AND
+		(usd_update IS NULL OR usd_update < timezone('utc', now()) - $1 * interval '1 second');`,
+		hdb.stalePriceWindow.Seconds(),
+	)
+	return symbols, tracerr.Wrap(err)
+}
+
 // GetTokenSymbolsAndAddrs returns all the token symbols and addresses from the DB
 func (hdb *HistoryDB) GetTokenSymbolsAndAddrs() ([]TokenSymbolAndAddr, error) {
 	var tokens []*TokenSymbolAndAddr
 	err := meddler.QueryAll(
-		hdb.dbRead, &tokens,
+		hdb.read(), &tokens,
 		"SELECT symbol, eth_addr, token_id FROM token;",
 	)
 	return db.SlicePtrsToSlice(tokens).([]TokenSymbolAndAddr), tracerr.Wrap(err)
@@ -566,8 +824,8 @@ func (hdb *HistoryDB) addAccounts(d meddler.DB, accounts []common.Account) error
 	if len(accounts) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "account",
 		`INSERT INTO account (
 			idx,
 			token_id,
@@ -583,7 +841,7 @@ func (hdb *HistoryDB) addAccounts(d meddler.DB, accounts []common.Account) error
 func (hdb *HistoryDB) GetAllAccounts() ([]common.Account, error) {
 	var accs []*common.Account
 	err := meddler.QueryAll(
-		hdb.dbRead, &accs,
+		hdb.read(), &accs,
 		"SELECT idx, token_id, batch_num, bjj, eth_addr FROM account ORDER BY idx;",
 	)
 	return db.SlicePtrsToSlice(accs).([]common.Account), tracerr.Wrap(err)
@@ -597,8 +855,8 @@ func (hdb *HistoryDB) addAccountUpdates(d meddler.DB, accUpdates []common.Accoun
 	if len(accUpdates) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "account_update",
 		`INSERT INTO account_update (
 			eth_block_num,
 			batch_num,
@@ -614,7 +872,7 @@ func (hdb *HistoryDB) addAccountUpdates(d meddler.DB, accUpdates []common.Accoun
 func (hdb *HistoryDB) GetAllAccountUpdates() ([]common.AccountUpdate, error) {
 	var accUpdates []*common.AccountUpdate
 	err := meddler.QueryAll(
-		hdb.dbRead, &accUpdates,
+		hdb.read(), &accUpdates,
 		"SELECT eth_block_num, batch_num, idx, nonce, balance FROM account_update ORDER BY idx;",
 	)
 	return db.SlicePtrsToSlice(accUpdates).([]common.AccountUpdate), tracerr.Wrap(err)
@@ -688,6 +946,11 @@ func (hdb *HistoryDB) addL2Txs(d meddler.DB, l2txs []common.L2Tx) error {
 	for i := 0; i < len(l2txs); i++ {
 		f := new(big.Float).SetInt(l2txs[i].Amount)
 		amountFloat, _ := f.Float64()
+		var daOffset, daLength *int
+		if l2txs[i].DALength > 0 {
+			daOffset = &l2txs[i].DAOffset
+			daLength = &l2txs[i].DALength
+		}
 		txs = append(txs, txWrite{
 			// Generic
 			IsL1:             false,
@@ -703,8 +966,10 @@ func (hdb *HistoryDB) addL2Txs(d meddler.DB, l2txs []common.L2Tx) error {
 			BatchNum:         &l2txs[i].BatchNum,
 			EthBlockNum:      l2txs[i].EthBlockNum,
 			// L2
-			Fee:   &l2txs[i].Fee,
-			Nonce: &l2txs[i].Nonce,
+			Fee:      &l2txs[i].Fee,
+			Nonce:    &l2txs[i].Nonce,
+			DAOffset: daOffset,
+			DALength: daLength,
 		})
 	}
 	return tracerr.Wrap(hdb.addTxs(d, txs))
@@ -714,8 +979,8 @@ func (hdb *HistoryDB) addTxs(d meddler.DB, txs []txWrite) error {
 	if len(txs) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "tx",
 		`INSERT INTO tx (
 			is_l1,
 			id,
@@ -736,7 +1001,9 @@ func (hdb *HistoryDB) addTxs(d meddler.DB, txs []txWrite) error {
 			deposit_amount,
 			deposit_amount_f,
 			fee,
-			nonce
+			nonce,
+			da_offset,
+			da_length
 		) VALUES %s;`,
 		txs,
 	))
@@ -746,7 +1013,7 @@ func (hdb *HistoryDB) addTxs(d meddler.DB, txs []txWrite) error {
 func (hdb *HistoryDB) GetAllExits() ([]common.ExitInfo, error) {
 	var exits []*common.ExitInfo
 	err := meddler.QueryAll(
-		hdb.dbRead, &exits,
+		hdb.read(), &exits,
 		`SELECT exit_tree.batch_num, exit_tree.account_idx, exit_tree.merkle_proof,
 		exit_tree.balance, exit_tree.instant_withdrawn, exit_tree.delayed_withdraw_request,
 		exit_tree.delayed_withdrawn FROM exit_tree ORDER BY item_id;`,
@@ -754,11 +1021,41 @@ func (hdb *HistoryDB) GetAllExits() ([]common.ExitInfo, error) {
 	return db.SlicePtrsToSlice(exits).([]common.ExitInfo), tracerr.Wrap(err)
 }
 
+// ExitRelayInfo is the subset of an exit needed to submit its
+// withdrawMerkleProof, extracted by joining the account and token tables.
+// Used by the coordinator withdrawal relayer
+type ExitRelayInfo struct {
+	MerkleProof      *merkletree.CircomVerifierProof `meddler:"merkle_proof,json"`
+	Balance          *big.Int                        `meddler:"balance,bigint"`
+	InstantWithdrawn *int64                          `meddler:"instant_withdrawn"`
+	EthAddr          ethCommon.Address               `meddler:"eth_addr"`
+	BJJ              babyjub.PublicKeyComp           `meddler:"bjj"`
+	TokenID          common.TokenID                  `meddler:"token_id"`
+	TokenEthAddr     ethCommon.Address               `meddler:"token_eth_addr"`
+}
+
+// GetExitRelayInfo returns the data needed to submit the withdrawMerkleProof
+// of an exit.  Used by the coordinator withdrawal relayer
+func (hdb *HistoryDB) GetExitRelayInfo(batchNum common.BatchNum,
+	idx common.Idx) (*ExitRelayInfo, error) {
+	exit := &ExitRelayInfo{}
+	err := meddler.QueryRow(
+		hdb.read(), exit,
+		`SELECT exit_tree.merkle_proof, exit_tree.balance, exit_tree.instant_withdrawn,
+		account.eth_addr, account.bjj, token.token_id, token.eth_addr AS token_eth_addr
+		FROM exit_tree INNER JOIN account ON exit_tree.account_idx = account.idx
+		INNER JOIN token ON account.token_id = token.token_id
+		WHERE exit_tree.batch_num = $1 AND exit_tree.account_idx = $2;`,
+		batchNum, idx,
+	)
+	return exit, tracerr.Wrap(err)
+}
+
 // GetAllL1UserTxs returns all L1UserTxs from the DB
 func (hdb *HistoryDB) GetAllL1UserTxs() ([]common.L1Tx, error) {
 	var txs []*common.L1Tx
 	err := meddler.QueryAll(
-		hdb.dbRead, &txs,
+		hdb.read(), &txs,
 		`SELECT tx.id, tx.to_forge_l1_txs_num, tx.position, tx.user_origin,
 		tx.from_idx, tx.effective_from_idx, tx.from_eth_addr, tx.from_bjj, tx.to_idx, tx.token_id,
 		tx.amount, (CASE WHEN tx.batch_num IS NULL THEN NULL WHEN tx.amount_success THEN tx.amount ELSE 0 END) AS effective_amount,
@@ -775,7 +1072,7 @@ func (hdb *HistoryDB) GetAllL1CoordinatorTxs() ([]common.L1Tx, error) {
 	// Since the query specifies that only coordinator txs are returned, it's safe to assume
 	// that returned txs will always have effective amounts
 	err := meddler.QueryAll(
-		hdb.dbRead, &txs,
+		hdb.read(), &txs,
 		`SELECT tx.id, tx.to_forge_l1_txs_num, tx.position, tx.user_origin,
 		tx.from_idx, tx.effective_from_idx, tx.from_eth_addr, tx.from_bjj, tx.to_idx, tx.token_id,
 		tx.amount, tx.amount AS effective_amount,
@@ -790,7 +1087,7 @@ func (hdb *HistoryDB) GetAllL1CoordinatorTxs() ([]common.L1Tx, error) {
 func (hdb *HistoryDB) GetAllL2Txs() ([]common.L2Tx, error) {
 	var txs []*common.L2Tx
 	err := meddler.QueryAll(
-		hdb.dbRead, &txs,
+		hdb.read(), &txs,
 		`SELECT tx.id, tx.batch_num, tx.position,
 		tx.from_idx, tx.to_idx, tx.amount, tx.token_id,
 		tx.fee, tx.nonce, tx.type, tx.eth_block_num
@@ -803,7 +1100,7 @@ func (hdb *HistoryDB) GetAllL2Txs() ([]common.L2Tx, error) {
 func (hdb *HistoryDB) GetUnforgedL1UserTxs(toForgeL1TxsNum int64) ([]common.L1Tx, error) {
 	var txs []*common.L1Tx
 	err := meddler.QueryAll(
-		hdb.dbRead, &txs, // only L1 user txs can have batch_num set to null
+		hdb.read(), &txs, // only L1 user txs can have batch_num set to null
 		`SELECT tx.id, tx.to_forge_l1_txs_num, tx.position, tx.user_origin,
 		tx.from_idx, tx.from_eth_addr, tx.from_bjj, tx.to_idx, tx.token_id,
 		tx.amount, NULL AS effective_amount,
@@ -821,7 +1118,7 @@ func (hdb *HistoryDB) GetUnforgedL1UserTxs(toForgeL1TxsNum int64) ([]common.L1Tx
 func (hdb *HistoryDB) GetUnforgedL1UserFutureTxs(toForgeL1TxsNum int64) ([]common.L1Tx, error) {
 	var txs []*common.L1Tx
 	err := meddler.QueryAll(
-		hdb.dbRead, &txs, // only L1 user txs can have batch_num set to null
+		hdb.read(), &txs, // only L1 user txs can have batch_num set to null
 		`SELECT tx.id, tx.to_forge_l1_txs_num, tx.position, tx.user_origin,
 		tx.from_idx, tx.from_eth_addr, tx.from_bjj, tx.to_idx, tx.token_id,
 		tx.amount, NULL AS effective_amount,
@@ -837,18 +1134,42 @@ func (hdb *HistoryDB) GetUnforgedL1UserFutureTxs(toForgeL1TxsNum int64) ([]commo
 // GetUnforgedL1UserTxsCount returns the count of unforged L1Txs (either in
 // open or frozen queues that are not yet forged)
 func (hdb *HistoryDB) GetUnforgedL1UserTxsCount() (int, error) {
-	row := hdb.dbRead.QueryRow(
+	row := hdb.read().QueryRow(
 		`SELECT COUNT(*) FROM tx WHERE batch_num IS NULL;`,
 	)
 	var count int
 	return count, tracerr.Wrap(row.Scan(&count))
 }
 
+// GetUnforgedNewAccountL1UserTxsCount returns the number of L1 user txs of
+// the next queue to be forged that will create a new account, used to
+// predict the Idx that an account created by an L1CoordinatorTx will get
+func (hdb *HistoryDB) GetUnforgedNewAccountL1UserTxsCount() (int, error) {
+	row := hdb.read().QueryRow(
+		`SELECT COUNT(*) FROM tx WHERE batch_num IS NULL AND
+		to_forge_l1_txs_num = (SELECT MIN(to_forge_l1_txs_num) FROM tx WHERE batch_num IS NULL) AND
+		type IN ('CreateAccountDeposit', 'CreateAccountDepositTransfer');`,
+	)
+	var count int
+	return count, tracerr.Wrap(row.Scan(&count))
+}
+
+// GetL1UserTxsCountByBatchNum returns the number of L1 user txs that were
+// forged in the given batch
+func (hdb *HistoryDB) GetL1UserTxsCountByBatchNum(batchNum common.BatchNum) (uint16, error) {
+	row := hdb.read().QueryRow(
+		`SELECT COUNT(*) FROM tx WHERE batch_num = $1 AND is_l1 AND user_origin;`,
+		batchNum,
+	)
+	var count uint16
+	return count, tracerr.Wrap(row.Scan(&count))
+}
+
 // TODO: Think about chaning all the queries that return a last value, to queries that return the next valid value.
 
 // GetLastTxsPosition for a given to_forge_l1_txs_num
 func (hdb *HistoryDB) GetLastTxsPosition(toForgeL1TxsNum int64) (int, error) {
-	row := hdb.dbRead.QueryRow(
+	row := hdb.read().QueryRow(
 		"SELECT position FROM tx WHERE to_forge_l1_txs_num = $1 ORDER BY position DESC;",
 		toForgeL1TxsNum,
 	)
@@ -862,15 +1183,15 @@ func (hdb *HistoryDB) GetSCVars() (*common.RollupVariables, *common.AuctionVaria
 	var rollup common.RollupVariables
 	var auction common.AuctionVariables
 	var wDelayer common.WDelayerVariables
-	if err := meddler.QueryRow(hdb.dbRead, &rollup,
+	if err := meddler.QueryRow(hdb.read(), &rollup,
 		"SELECT * FROM rollup_vars ORDER BY eth_block_num DESC LIMIT 1;"); err != nil {
 		return nil, nil, nil, tracerr.Wrap(err)
 	}
-	if err := meddler.QueryRow(hdb.dbRead, &auction,
+	if err := meddler.QueryRow(hdb.read(), &auction,
 		"SELECT * FROM auction_vars ORDER BY eth_block_num DESC LIMIT 1;"); err != nil {
 		return nil, nil, nil, tracerr.Wrap(err)
 	}
-	if err := meddler.QueryRow(hdb.dbRead, &wDelayer,
+	if err := meddler.QueryRow(hdb.read(), &wDelayer,
 		"SELECT * FROM wdelayer_vars ORDER BY eth_block_num DESC LIMIT 1;"); err != nil {
 		return nil, nil, nil, tracerr.Wrap(err)
 	}
@@ -893,8 +1214,8 @@ func (hdb *HistoryDB) addBucketUpdates(d meddler.DB, bucketUpdates []common.Buck
 	if len(bucketUpdates) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "bucket_update",
 		`INSERT INTO bucket_update (
 		 	eth_block_num,
 		 	num_bucket,
@@ -915,7 +1236,7 @@ func (hdb *HistoryDB) AddBucketUpdatesTest(d meddler.DB, bucketUpdates []common.
 func (hdb *HistoryDB) GetAllBucketUpdates() ([]common.BucketUpdate, error) {
 	var bucketUpdates []*common.BucketUpdate
 	err := meddler.QueryAll(
-		hdb.dbRead, &bucketUpdates,
+		hdb.read(), &bucketUpdates,
 		`SELECT eth_block_num, num_bucket, block_stamp, withdrawals  
 		FROM bucket_update ORDER BY item_id;`,
 	)
@@ -938,8 +1259,8 @@ func (hdb *HistoryDB) addTokenExchanges(d meddler.DB, tokenExchanges []common.To
 	if len(tokenExchanges) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "token_exchange",
 		`INSERT INTO token_exchange (
 			eth_block_num,
     			eth_addr,
@@ -953,7 +1274,7 @@ func (hdb *HistoryDB) addTokenExchanges(d meddler.DB, tokenExchanges []common.To
 func (hdb *HistoryDB) GetAllTokenExchanges() ([]common.TokenExchange, error) {
 	var tokenExchanges []*common.TokenExchange
 	err := meddler.QueryAll(
-		hdb.dbRead, &tokenExchanges,
+		hdb.read(), &tokenExchanges,
 		"SELECT eth_block_num, eth_addr, value_usd FROM token_exchange ORDER BY item_id;",
 	)
 	return db.SlicePtrsToSlice(tokenExchanges).([]common.TokenExchange), tracerr.Wrap(err)
@@ -964,8 +1285,8 @@ func (hdb *HistoryDB) addEscapeHatchWithdrawals(d meddler.DB,
 	if len(escapeHatchWithdrawals) == 0 {
 		return nil
 	}
-	return tracerr.Wrap(db.BulkInsert(
-		d,
+	return tracerr.Wrap(hdb.bulkWrite(
+		d, "escape_hatch_withdrawal",
 		`INSERT INTO escape_hatch_withdrawal (
 			eth_block_num,
 			who_addr,
@@ -981,7 +1302,7 @@ func (hdb *HistoryDB) addEscapeHatchWithdrawals(d meddler.DB,
 func (hdb *HistoryDB) GetAllEscapeHatchWithdrawals() ([]common.WDelayerEscapeHatchWithdrawal, error) {
 	var escapeHatchWithdrawals []*common.WDelayerEscapeHatchWithdrawal
 	err := meddler.QueryAll(
-		hdb.dbRead, &escapeHatchWithdrawals,
+		hdb.read(), &escapeHatchWithdrawals,
 		"SELECT eth_block_num, who_addr, to_addr, token_addr, amount FROM escape_hatch_withdrawal ORDER BY item_id;",
 	)
 	return db.SlicePtrsToSlice(escapeHatchWithdrawals).([]common.WDelayerEscapeHatchWithdrawal),
@@ -1229,7 +1550,12 @@ func (hdb *HistoryDB) AddBlockSCData(blockData *common.BlockData) (err error) {
 		return tracerr.Wrap(err)
 	}
 
-	return tracerr.Wrap(txn.Commit())
+	if err := txn.Commit(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	// The committed block can make any cached API query stale
+	hdb.apiCache.invalidate()
+	return nil
 }
 
 // AddAuctionVars insert auction vars into the DB
@@ -1241,7 +1567,7 @@ func (hdb *HistoryDB) AddAuctionVars(auctionVars *common.AuctionVariables) error
 func (hdb *HistoryDB) GetTokensTest() ([]TokenWithUSD, error) {
 	tokens := []*TokenWithUSD{}
 	if err := meddler.QueryAll(
-		hdb.dbRead, &tokens,
+		hdb.read(), &tokens,
 		"SELECT * FROM token ORDER BY token_id ASC",
 	); err != nil {
 		return nil, tracerr.Wrap(err)
@@ -1273,7 +1599,7 @@ func (hdb *HistoryDB) GetRecommendedFee(minFeeUSD, maxFeeUSD float64) (*common.R
 	}
 	ttsbn := &totalTxsSinceBatchNum{}
 	if err := meddler.QueryRow(
-		hdb.dbRead, ttsbn, `SELECT COUNT(tx.*) as total_txs, 
+		hdb.read(), ttsbn, `SELECT COUNT(tx.*) as total_txs, 
 			COALESCE (MIN(tx.batch_num), 0) as batch_num 
 			FROM tx INNER JOIN block ON tx.eth_block_num = block.eth_block_num
 			WHERE block.timestamp >= NOW() - INTERVAL '1 HOURS';`,
@@ -1287,7 +1613,7 @@ func (hdb *HistoryDB) GetRecommendedFee(minFeeUSD, maxFeeUSD float64) (*common.R
 	}
 	tbf := &totalBatchesAndFee{}
 	if err := meddler.QueryRow(
-		hdb.dbRead, tbf, `SELECT COUNT(*) AS total_batches, 
+		hdb.read(), tbf, `SELECT COUNT(*) AS total_batches, 
 			COALESCE (SUM(total_fees_usd), 0) AS total_fees FROM batch 
 			WHERE batch_num > $1;`, ttsbn.FirstBatchNum,
 	); err != nil {
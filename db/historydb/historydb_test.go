@@ -26,8 +26,9 @@ var historyDB *HistoryDB
 var historyDBWithACC *HistoryDB
 
 func TestMain(m *testing.M) {
-	// init DB
-	db, err := dbUtils.InitTestSQLDB()
+	// init DB, on an isolated database so the suite can run in parallel
+	// with the ones of other packages
+	db, teardown, err := dbUtils.InitTestSQLDBIsolated("historydb")
 	if err != nil {
 		panic(err)
 	}
@@ -39,8 +40,8 @@ func TestMain(m *testing.M) {
 	historyDBWithACC = NewHistoryDB(db, db, apiConnCon)
 	// Run tests
 	result := m.Run()
-	// Close DB
-	if err := db.Close(); err != nil {
+	// Close and drop the test DB
+	if err := teardown(); err != nil {
 		log.Error("Error closing the history DB:", err)
 	}
 	os.Exit(result)
@@ -0,0 +1,115 @@
+package historydb
+
+import (
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/russross/meddler"
+)
+
+// L1DepositWatch represents a watched ethereum transaction that is expected
+// to call `addL1Transaction`.  Once the transaction is included in a block,
+// the deposit watcher decodes the generated L1UserTx and fills the
+// identification fields (see the depositwatcher package)
+type L1DepositWatch struct {
+	EthTxHash   ethCommon.Hash `meddler:"eth_tx_hash"`
+	CreatedAt   time.Time      `meddler:"created_at,utctime"`
+	EthBlockNum *int64         `meddler:"eth_block_num"`
+	Failed      bool           `meddler:"failed"`
+	TxID        *common.TxID   `meddler:"tx_id"`
+	// ToForgeL1TxsNum is the L1UserTx queue where the deposit waits to be
+	// forged
+	ToForgeL1TxsNum *int64 `meddler:"to_forge_l1_txs_num"`
+	// Position of the deposit inside the L1UserTx queue
+	Position *int `meddler:"position"`
+}
+
+// L1DepositWatchAPI is a representation of a L1DepositWatch with additional
+// context from the batch and tx tables to tell whether the L1UserTx has been
+// forged and to estimate the forging batch
+type L1DepositWatchAPI struct {
+	L1DepositWatch
+	// ForgedBatchNum is the batch where the L1UserTx was forged, nil if
+	// not forged yet
+	ForgedBatchNum *common.BatchNum `meddler:"forged_batch_num"`
+	// LastBatchNum is the last synced batch, used to estimate the
+	// forging batch
+	LastBatchNum *common.BatchNum `meddler:"last_batch_num"`
+	// LastForgedL1TxsNum is the last L1UserTx queue forged by a batch
+	LastForgedL1TxsNum *int64 `meddler:"last_forged_l1_txs_num"`
+}
+
+// AddL1DepositWatchAPI registers an ethereum transaction hash to be watched
+// by the deposit watcher
+func (hdb *HistoryDB) AddL1DepositWatchAPI(ethTxHash ethCommon.Hash) error {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	_, err = hdb.dbWrite.Exec(
+		"INSERT INTO l1_deposit_watch (eth_tx_hash) VALUES ($1);",
+		ethTxHash,
+	)
+	return tracerr.Wrap(err)
+}
+
+// GetL1DepositWatchAPI returns the watch registered for the given ethereum
+// transaction hash along with its forging context
+func (hdb *HistoryDB) GetL1DepositWatchAPI(ethTxHash ethCommon.Hash) (*L1DepositWatchAPI, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	watch := &L1DepositWatchAPI{}
+	err = meddler.QueryRow(
+		hdb.dbRead, watch, `SELECT l1_deposit_watch.*, tx.batch_num AS forged_batch_num,
+		(SELECT MAX(batch_num) FROM batch) AS last_batch_num,
+		(SELECT MAX(forge_l1_txs_num) FROM batch) AS last_forged_l1_txs_num
+		FROM l1_deposit_watch LEFT JOIN tx ON tx.id = l1_deposit_watch.tx_id
+		WHERE l1_deposit_watch.eth_tx_hash = $1;`,
+		ethTxHash,
+	)
+	return watch, tracerr.Wrap(err)
+}
+
+// GetPendingL1DepositWatches returns the watches whose transaction has not
+// been seen included in a block yet.  Used by the deposit watcher
+func (hdb *HistoryDB) GetPendingL1DepositWatches() ([]L1DepositWatch, error) {
+	var watches []*L1DepositWatch
+	err := meddler.QueryAll(
+		hdb.read(), &watches,
+		`SELECT * FROM l1_deposit_watch
+		WHERE eth_block_num IS NULL AND NOT failed ORDER BY created_at;`,
+	)
+	return db.SlicePtrsToSlice(watches).([]L1DepositWatch), tracerr.Wrap(err)
+}
+
+// SetL1DepositWatchIncluded stores the L1UserTx identification of a watched
+// transaction once it has been included in a block
+func (hdb *HistoryDB) SetL1DepositWatchIncluded(ethTxHash ethCommon.Hash,
+	blockNum int64, txID common.TxID, toForgeL1TxsNum int64, position int) error {
+	_, err := hdb.dbWrite.Exec(
+		`UPDATE l1_deposit_watch SET eth_block_num = $2, tx_id = $3,
+		to_forge_l1_txs_num = $4, position = $5 WHERE eth_tx_hash = $1;`,
+		ethTxHash, blockNum, txID, toForgeL1TxsNum, position,
+	)
+	return tracerr.Wrap(err)
+}
+
+// SetL1DepositWatchFailed marks a watched transaction as failed: it was
+// included in a block but reverted or didn't generate any L1UserTx
+func (hdb *HistoryDB) SetL1DepositWatchFailed(ethTxHash ethCommon.Hash, blockNum int64) error {
+	_, err := hdb.dbWrite.Exec(
+		`UPDATE l1_deposit_watch SET eth_block_num = $2, failed = true
+		WHERE eth_tx_hash = $1;`,
+		ethTxHash, blockNum,
+	)
+	return tracerr.Wrap(err)
+}
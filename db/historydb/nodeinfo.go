@@ -41,6 +41,52 @@ type NodePublicInfo struct {
 	ForgeDelay float64 `json:"forgeDelay"`
 	// PoolLoad amount of transactions in the pool
 	PoolLoad int64 `json:"poolLoad"`
+	// Pool statistics about the composition of the pool.  Only set when
+	// the node runs along an L2DB (coordinator mode)
+	Pool *PoolStats `json:"pool,omitempty"`
+	// MinFeePolicy describes the minimum fee rules applied when accepting
+	// txs into the pool, so that wallets can pre-validate txs
+	MinFeePolicy *MinFeePolicyAPI `json:"minFeePolicy,omitempty"`
+}
+
+// TokenMinFeeAPI is a per-token minimum fee rule exposed in the state API
+type TokenMinFeeAPI struct {
+	TokenID common.TokenID `json:"tokenId"`
+	// MinFeeSelector is the minimum fee selector that txs of the token
+	// must use in order to be accepted into the pool
+	MinFeeSelector uint8 `json:"minFeeSelector"`
+}
+
+// MinFeePolicyAPI describes the minimum fee rules that the coordinator
+// applies when accepting txs into the pool
+type MinFeePolicyAPI struct {
+	MinFeeUSD               float64          `json:"minFeeUSD"`
+	TokenMinFees            []TokenMinFeeAPI `json:"tokenMinFees"`
+	FreeTxsPerAccountPerDay uint32           `json:"freeTxsPerAccountPerDay"`
+}
+
+// PoolTokenCount is the number of pending pool txs of a token
+type PoolTokenCount struct {
+	TokenID common.TokenID `json:"tokenId"`
+	Count   int64          `json:"count"`
+}
+
+// PoolFeeTierCount is the number of pending pool txs within a fee tier.
+// Tier n covers the fee selectors [n*32, n*32+31]
+type PoolFeeTierCount struct {
+	FeeTier int   `json:"feeTier"`
+	Count   int64 `json:"count"`
+}
+
+// PoolStats contains aggregated statistics about the composition of the
+// pool, computed by the L2DB and exposed in the node state API
+type PoolStats struct {
+	PendingCount            int64              `json:"pendingCount"`
+	PendingByToken          []PoolTokenCount   `json:"pendingByToken"`
+	PendingByFeeTier        []PoolFeeTierCount `json:"pendingByFeeTier"`
+	OldestPendingAgeSeconds float64            `json:"oldestPendingAgeSeconds"`
+	InvalidCount            int64              `json:"invalidCount"`
+	ForgingCount            int64              `json:"forgingCount"`
 }
 
 // StateAPI is an object representing the node and network state exposed via the API
@@ -63,10 +109,12 @@ type Constants struct {
 
 // NodeConfig contains the node config exposed in the API
 type NodeConfig struct {
-	MaxPoolTxs uint32
-	MinFeeUSD  float64
-	MaxFeeUSD  float64
-	ForgeDelay float64
+	MaxPoolTxs              uint32
+	MinFeeUSD               float64
+	MaxFeeUSD               float64
+	TokenMinFees            []TokenMinFeeAPI
+	FreeTxsPerAccountPerDay uint32
+	ForgeDelay              float64
 }
 
 // NodeInfo contains information about he node used when serving the API
@@ -81,7 +129,7 @@ type NodeInfo struct {
 func (hdb *HistoryDB) GetNodeInfo() (*NodeInfo, error) {
 	ni := &NodeInfo{}
 	err := meddler.QueryRow(
-		hdb.dbRead, ni, `SELECT * FROM node_info WHERE item_id = 1;`,
+		hdb.read(), ni, `SELECT * FROM node_info WHERE item_id = 1;`,
 	)
 	return ni, tracerr.Wrap(err)
 }
@@ -90,7 +138,7 @@ func (hdb *HistoryDB) GetNodeInfo() (*NodeInfo, error) {
 func (hdb *HistoryDB) GetConstants() (*Constants, error) {
 	var nodeInfo NodeInfo
 	err := meddler.QueryRow(
-		hdb.dbRead, &nodeInfo,
+		hdb.read(), &nodeInfo,
 		"SELECT constants FROM node_info WHERE item_id = 1;",
 	)
 	return nodeInfo.Constants, tracerr.Wrap(err)
@@ -114,7 +162,7 @@ func (hdb *HistoryDB) SetConstants(constants *Constants) error {
 
 // GetStateInternalAPI returns the StateAPI
 func (hdb *HistoryDB) GetStateInternalAPI() (*StateAPI, error) {
-	return hdb.getStateAPI(hdb.dbRead)
+	return hdb.getStateAPI(hdb.read())
 }
 
 func (hdb *HistoryDB) getStateAPI(d meddler.DB) (*StateAPI, error) {
@@ -150,7 +198,7 @@ func (hdb *HistoryDB) SetStateInternalAPI(stateAPI *StateAPI) error {
 func (hdb *HistoryDB) GetNodeConfig() (*NodeConfig, error) {
 	var nodeInfo NodeInfo
 	err := meddler.QueryRow(
-		hdb.dbRead, &nodeInfo,
+		hdb.read(), &nodeInfo,
 		"SELECT config FROM node_info WHERE item_id = 1;",
 	)
 	return nodeInfo.NodeConfig, tracerr.Wrap(err)
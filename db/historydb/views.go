@@ -39,6 +39,7 @@ type ExitAPIJSON struct {
 	InstantWithdrawn       *int64                          `json:"instantWithdraw"`
 	DelayedWithdrawRequest *int64                          `json:"delayedWithdrawRequest"`
 	DelayedWithdrawn       *int64                          `json:"delayedWithdraw"`
+	InstantWithdrawAllowed *bool                           `json:"instantWithdrawAllowed"`
 	TokenJSON              TokenJSON                       `json:"token"`
 }
 
@@ -80,11 +81,16 @@ type TxAPIJSON struct {
 // L1infoJSON is a representation of the JSON structure returned
 // by the serialization method
 type L1infoJSON struct {
-	ToForgeL1TxsNum          *int64              `json:"toForgeL1TransactionsNum"`
-	UserOrigin               *bool               `json:"userOrigin"`
-	DepositAmount            *apitypes.BigIntStr `json:"depositAmount"`
-	AmountSuccess            bool                `json:"amountSuccess"`
-	DepositAmountSuccess     bool                `json:"depositAmountSuccess"`
+	ToForgeL1TxsNum *int64              `json:"toForgeL1TransactionsNum"`
+	UserOrigin      *bool               `json:"userOrigin"`
+	DepositAmount   *apitypes.BigIntStr `json:"depositAmount"`
+	// AmountSuccess, DepositAmountSuccess and the effective amounts they
+	// determine are null until the tx is forged, as whether the amounts
+	// will apply is only known at forge time
+	AmountSuccess            *bool               `json:"amountSuccess"`
+	DepositAmountSuccess     *bool               `json:"depositAmountSuccess"`
+	EffectiveAmount          *apitypes.BigIntStr `json:"effectiveAmount"`
+	EffectiveDepositAmount   *apitypes.BigIntStr `json:"effectiveDepositAmount"`
 	HistoricDepositAmountUSD *float64            `json:"historicDepositAmountUSD"`
 	EthereumBlockNum         int64               `json:"ethereumBlockNum"`
 }
@@ -178,11 +184,28 @@ func (tx TxAPI) MarshalJSON() ([]byte, error) {
 
 	if tx.IsL1 {
 		txa.L1orL2 = "L1"
-		amountSuccess := tx.AmountSuccess
-		depositAmountSuccess := tx.DepositAmountSuccess
-		if tx.BatchNum == nil {
-			amountSuccess = false
-			depositAmountSuccess = false
+		// amount_success and deposit_amount_success default to true in
+		// the DB for performance reasons, but until the tx is forged
+		// they carry no meaning: expose them (and the effective
+		// amounts they determine) as null instead of defaulting them,
+		// so that an unforged deposit can't be confused with one whose
+		// amounts were zeroed at forge time
+		var amountSuccess, depositAmountSuccess *bool
+		var effectiveAmount, effectiveDepositAmount *apitypes.BigIntStr
+		if tx.BatchNum != nil {
+			zero := apitypes.BigIntStr("0")
+			amountSuccess = &tx.AmountSuccess
+			depositAmountSuccess = &tx.DepositAmountSuccess
+			if tx.AmountSuccess {
+				effectiveAmount = &tx.Amount
+			} else {
+				effectiveAmount = &zero
+			}
+			if tx.DepositAmountSuccess {
+				effectiveDepositAmount = tx.DepositAmount
+			} else {
+				effectiveDepositAmount = &zero
+			}
 		}
 		txa.L1Info = &L1infoJSON{
 			ToForgeL1TxsNum:          tx.ToForgeL1TxsNum,
@@ -190,6 +213,8 @@ func (tx TxAPI) MarshalJSON() ([]byte, error) {
 			DepositAmount:            tx.DepositAmount,
 			AmountSuccess:            amountSuccess,
 			DepositAmountSuccess:     depositAmountSuccess,
+			EffectiveAmount:          effectiveAmount,
+			EffectiveDepositAmount:   effectiveDepositAmount,
 			HistoricDepositAmountUSD: tx.HistoricDepositAmountUSD,
 			EthereumBlockNum:         tx.EthBlockNum,
 		}
@@ -204,6 +229,21 @@ func (tx TxAPI) MarshalJSON() ([]byte, error) {
 	return json.Marshal(txa)
 }
 
+// TxInclusionProofAPI is the data needed to verify that a forged L2 tx is
+// included in the data availability bytes published on-chain in the
+// forgeBatch call of its batch: the tx is
+// calldata[ByteOffset:ByteOffset+ByteLength] of the ethereum tx.  The
+// offsets are NULL for txs synchronized before they were recorded
+type TxInclusionProofAPI struct {
+	TxID      common.TxID        `json:"id" meddler:"id"`
+	BatchNum  common.BatchNum    `json:"batchNum" meddler:"batch_num"`
+	Position  int                `json:"position" meddler:"position"`
+	StateRoot apitypes.BigIntStr `json:"stateRoot" meddler:"state_root"`
+	EthTxHash ethCommon.Hash     `json:"ethereumTxHash" meddler:"eth_tx_hash"`
+	DAOffset  *int               `json:"byteOffset" meddler:"da_offset"`
+	DALength  *int               `json:"byteLength" meddler:"da_length"`
+}
+
 // txWrite is an representatiion that merges common.L1Tx and common.L2Tx
 // in order to perform inserts into tx table
 // EffectiveAmount and EffectiveDepositAmount are not set since they have default values in the DB
@@ -231,6 +271,10 @@ type txWrite struct {
 	// L2
 	Fee   *common.FeeSelector `meddler:"fee"`
 	Nonce *common.Nonce       `meddler:"nonce"`
+	// Offset and length of the encoded bytes of the tx within the
+	// forgeBatch calldata, NULL when unknown
+	DAOffset *int `meddler:"da_offset"`
+	DALength *int `meddler:"da_length"`
 }
 
 // TokenSymbolAndAddr token representation with only Eth addr and symbol
@@ -251,9 +295,13 @@ type TokenWithUSD struct {
 	Decimals    uint64            `json:"decimals" meddler:"decimals"`
 	USD         *float64          `json:"USD" meddler:"usd"`
 	USDUpdate   *time.Time        `json:"fiatUpdate" meddler:"usd_update,utctime"`
-	TotalItems  uint64            `json:"-" meddler:"total_items"`
-	FirstItem   uint64            `json:"-" meddler:"first_item"`
-	LastItem    uint64            `json:"-" meddler:"last_item"`
+	// PriceStale is set when the USD price hasn't been updated within the
+	// stale price window.  Stale prices are excluded from the fee USD
+	// computations
+	PriceStale bool   `json:"priceStale" meddler:"-"`
+	TotalItems uint64 `json:"-" meddler:"total_items"`
+	FirstItem  uint64 `json:"-" meddler:"first_item"`
+	LastItem   uint64 `json:"-" meddler:"last_item"`
 }
 
 // ExitAPI is a representation of a exit with additional information
@@ -269,18 +317,23 @@ type ExitAPI struct {
 	InstantWithdrawn       *int64                          `meddler:"instant_withdrawn"`
 	DelayedWithdrawRequest *int64                          `meddler:"delayed_withdraw_request"`
 	DelayedWithdrawn       *int64                          `meddler:"delayed_withdrawn"`
-	TotalItems             uint64                          `meddler:"total_items"`
-	FirstItem              uint64                          `meddler:"first_item"`
-	LastItem               uint64                          `meddler:"last_item"`
-	TokenID                common.TokenID                  `meddler:"token_id"`
-	TokenItemID            uint64                          `meddler:"token_item_id"`
-	TokenEthBlockNum       int64                           `meddler:"token_block"`
-	TokenEthAddr           ethCommon.Address               `meddler:"token_eth_addr"`
-	TokenName              string                          `meddler:"name"`
-	TokenSymbol            string                          `meddler:"symbol"`
-	TokenDecimals          uint64                          `meddler:"decimals"`
-	TokenUSD               *float64                        `meddler:"usd"`
-	TokenUSDUpdate         *time.Time                      `meddler:"usd_update"`
+	// InstantWithdrawAllowed tells whether an instant withdraw of the
+	// full balance would currently pass the bucket check of the Rollup
+	// Smart Contract.  It is computed by the API and is null when the
+	// exit has already been withdrawn or the token has no known USD value
+	InstantWithdrawAllowed *bool             `meddler:"-"`
+	TotalItems             uint64            `meddler:"total_items"`
+	FirstItem              uint64            `meddler:"first_item"`
+	LastItem               uint64            `meddler:"last_item"`
+	TokenID                common.TokenID    `meddler:"token_id"`
+	TokenItemID            uint64            `meddler:"token_item_id"`
+	TokenEthBlockNum       int64             `meddler:"token_block"`
+	TokenEthAddr           ethCommon.Address `meddler:"token_eth_addr"`
+	TokenName              string            `meddler:"name"`
+	TokenSymbol            string            `meddler:"symbol"`
+	TokenDecimals          uint64            `meddler:"decimals"`
+	TokenUSD               *float64          `meddler:"usd"`
+	TokenUSDUpdate         *time.Time        `meddler:"usd_update"`
 }
 
 // MarshalJSON is used to neast some of the fields of ExitAPI
@@ -297,6 +350,7 @@ func (e ExitAPI) MarshalJSON() ([]byte, error) {
 		InstantWithdrawn:       e.InstantWithdrawn,
 		DelayedWithdrawRequest: e.DelayedWithdrawRequest,
 		DelayedWithdrawn:       e.DelayedWithdrawn,
+		InstantWithdrawAllowed: e.InstantWithdrawAllowed,
 		TokenJSON: TokenJSON{
 			TokenID:          e.TokenID,
 			TokenItemID:      e.TokenItemID,
@@ -326,6 +380,36 @@ type CoordinatorAPI struct {
 	LastItem    uint64            `json:"-" meddler:"last_item"`
 }
 
+// CoordinatorStatsAPI is the auction and forging track record of a
+// coordinator returned by the GET /coordinators/:bidderAddr/stats endpoint
+type CoordinatorStatsAPI struct {
+	Bidder ethCommon.Address `json:"bidderAddr"`
+	Forger ethCommon.Address `json:"forgerAddr"`
+	URL    string            `json:"URL"`
+	// SlotsWon is the number of slots up to the current one whose best
+	// bid belongs to the coordinator
+	SlotsWon int64 `json:"slotsWon"`
+	// SlotsForged is the number of won slots in which the coordinator
+	// forged at least one batch
+	SlotsForged int64 `json:"slotsForged"`
+	// SlotsMissed is the number of won slots that have already finished
+	// without the coordinator forging any batch during them
+	SlotsMissed int64 `json:"slotsMissed"`
+	// TotalForgedBatches is the number of batches forged by the
+	// coordinator since genesis
+	TotalForgedBatches int64 `json:"totalForgedBatches"`
+	// AverageBatchesPerSlot is TotalForgedBatches over the number of
+	// distinct slots with at least one batch forged by the coordinator
+	AverageBatchesPerSlot float64 `json:"averageBatchesPerSlot"`
+	// Uptime is the ratio of attended over finished won slots, between 0
+	// and 1.  Null until the first won slot of the coordinator finishes
+	Uptime *float64 `json:"uptime"`
+	// FirstBatchForgedAt and LastBatchForgedAt delimit the forging
+	// activity of the coordinator, null when it hasn't forged any batch
+	FirstBatchForgedAt *time.Time `json:"firstBatchForgedAt"`
+	LastBatchForgedAt  *time.Time `json:"lastBatchForgedAt"`
+}
+
 // FiatCurrency is a representation of a currency price object
 type FiatCurrency struct {
 	Currency     string    `json:"currency" meddler:"currency"`
@@ -383,6 +467,24 @@ func (account AccountAPI) MarshalJSON() ([]byte, error) {
 	return json.Marshal(act)
 }
 
+// AccountUpdateAPI is a representation of a balance and nonce change
+// applied to an account by a batch
+type AccountUpdateAPI struct {
+	Idx     apitypes.HezIdx     `json:"accountIndex" meddler:"idx"`
+	Nonce   common.Nonce        `json:"nonce" meddler:"nonce"`
+	Balance *apitypes.BigIntStr `json:"balance" meddler:"balance"`
+}
+
+// BatchStateDiffAPI groups all the state changes introduced by a batch:
+// the accounts it created, the balance and nonce updates it applied and the
+// exits it added to the exit tree
+type BatchStateDiffAPI struct {
+	BatchNum        common.BatchNum    `json:"batchNum"`
+	CreatedAccounts []AccountAPI       `json:"createdAccounts"`
+	UpdatedAccounts []AccountUpdateAPI `json:"updatedAccounts"`
+	Exits           []ExitAPI          `json:"exits"`
+}
+
 // BatchAPI is a representation of a batch with additional information
 // required by the API, and extracted by joining block table
 type BatchAPI struct {
@@ -407,6 +509,59 @@ type BatchAPI struct {
 	LastItem         uint64                      `json:"-" meddler:"last_item"`
 }
 
+// SlotForgingSummaryAPI is a representation of the forging outcome of a
+// slot, aggregating the batches forged during it.  ForgerAddr is the forger
+// of the last batch of the slot
+type SlotForgingSummaryAPI struct {
+	SlotNum       int64             `json:"slotNum" meddler:"slot_num"`
+	ForgerAddr    ethCommon.Address `json:"forgerAddr" meddler:"forger_addr"`
+	ForgedBatches int               `json:"forgedBatches" meddler:"forged_batches"`
+	ForgedTxs     int               `json:"forgedTransactions" meddler:"forged_txs"`
+	TotalFeesUSD  *float64          `json:"historicTotalCollectedFeesUSD" meddler:"total_fees_usd"`
+}
+
+// BatchProfitabilityAPI is a representation of the profitability of a batch:
+// fees collected in USD, cost of the L1 forge transaction and resulting net
+// profit.  Gas fields (and therefore the derived costs) can be null for
+// batches synced before the gas columns were introduced.
+type BatchProfitabilityAPI struct {
+	ItemID         uint64              `json:"itemId" meddler:"item_id"`
+	BatchNum       common.BatchNum     `json:"batchNum" meddler:"batch_num"`
+	EthereumTxHash ethCommon.Hash      `json:"ethereumTxHash" meddler:"eth_tx_hash"`
+	ForgerAddr     ethCommon.Address   `json:"forgerAddr" meddler:"forger_addr"`
+	TotalFeesUSD   *float64            `json:"historicTotalCollectedFeesUSD" meddler:"total_fees_usd"`
+	GasUsed        *int64              `json:"gasUsed" meddler:"gas_used"`
+	GasPrice       *apitypes.BigIntStr `json:"gasPrice" meddler:"gas_price"`
+	EthUSD         *float64            `json:"-" meddler:"eth_usd"`
+	GasCostUSD     *float64            `json:"gasCostUSD" meddler:"-"`
+	NetProfitUSD   *float64            `json:"netProfitUSD" meddler:"-"`
+	TotalItems     uint64              `json:"-" meddler:"total_items"`
+}
+
+// setProfit computes GasCostUSD and NetProfitUSD from the gas fields and the
+// current price of ETH, leaving them as null if the gas of the forge tx or
+// the ETH price are unknown
+func (b *BatchProfitabilityAPI) setProfit() {
+	if b.GasUsed == nil || b.GasPrice == nil || b.EthUSD == nil {
+		return
+	}
+	gasPrice, ok := new(big.Int).SetString(string(*b.GasPrice), 10)
+	if !ok {
+		return
+	}
+	costWei := new(big.Int).Mul(big.NewInt(*b.GasUsed), gasPrice)
+	costETH, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(costWei), big.NewFloat(1e18)).Float64() //nolint:gomnd
+	gasCostUSD := costETH * *b.EthUSD
+	b.GasCostUSD = &gasCostUSD
+	totalFeesUSD := float64(0)
+	if b.TotalFeesUSD != nil {
+		totalFeesUSD = *b.TotalFeesUSD
+	}
+	netProfitUSD := totalFeesUSD - gasCostUSD
+	b.NetProfitUSD = &netProfitUSD
+}
+
 // MetricsAPI define metrics of the network
 type MetricsAPI struct {
 	TransactionsPerBatch   float64 `json:"transactionsPerBatch"`
@@ -418,6 +573,21 @@ type MetricsAPI struct {
 	EstimatedTimeToForgeL1 float64 `json:"estimatedTimeToForgeL1" meddler:"estimated_time_to_forge_l1"`
 }
 
+// MetricsSnapshotAPI is a snapshot of the network metrics at a point in
+// time, stored periodically by the state updater loop
+type MetricsSnapshotAPI struct {
+	ItemID                 uint64    `json:"itemId" meddler:"item_id"`
+	Timestamp              time.Time `json:"timestamp" meddler:"timestamp,utctime"`
+	TransactionsPerBatch   float64   `json:"transactionsPerBatch" meddler:"transactions_per_batch"`
+	BatchFrequency         float64   `json:"batchFrequency" meddler:"batch_frequency"`
+	TransactionsPerSecond  float64   `json:"transactionsPerSecond" meddler:"transactions_per_second"`
+	TokenAccounts          int64     `json:"tokenAccounts" meddler:"token_accounts"`
+	Wallets                int64     `json:"wallets" meddler:"wallets"`
+	AvgTransactionFee      float64   `json:"avgTransactionFee" meddler:"avg_transaction_fee"`
+	EstimatedTimeToForgeL1 float64   `json:"estimatedTimeToForgeL1" meddler:"estimated_time_to_forge_l1"`
+	TotalItems             uint64    `json:"-" meddler:"total_items"`
+}
+
 // BidAPI is a representation of a bid with additional information
 // required by the API
 type BidAPI struct {
@@ -458,6 +628,28 @@ type BucketParamsAPI struct {
 	RateBlocks      *apitypes.BigIntStr `json:"rateBlocks"`
 	RateWithdrawals *apitypes.BigIntStr `json:"rateWithdrawals"`
 	MaxWithdrawals  *apitypes.BigIntStr `json:"maxWithdrawals"`
+	// WithdrawalsAvailable is the Withdrawals value with the refills
+	// accumulated since BlockStamp applied, computed at the last known
+	// ethereum block
+	WithdrawalsAvailable *apitypes.BigIntStr `json:"withdrawalsAvailable"`
+	// NextRefillBlock is the ethereum block at which the bucket will next
+	// gain withdrawable amount, 0 when the bucket is full or never refills
+	NextRefillBlock int64 `json:"nextRefillBlock"`
+}
+
+// NewBucketParamsAPI creates a BucketParamsAPI from common.BucketParams,
+// computing the refill derived fields at the given ethereum block
+func NewBucketParamsAPI(bucket *common.BucketParams, blockNum int64) BucketParamsAPI {
+	return BucketParamsAPI{
+		CeilUSD:              apitypes.NewBigIntStr(bucket.CeilUSD),
+		BlockStamp:           apitypes.NewBigIntStr(bucket.BlockStamp),
+		Withdrawals:          apitypes.NewBigIntStr(bucket.Withdrawals),
+		RateBlocks:           apitypes.NewBigIntStr(bucket.RateBlocks),
+		RateWithdrawals:      apitypes.NewBigIntStr(bucket.RateWithdrawals),
+		MaxWithdrawals:       apitypes.NewBigIntStr(bucket.MaxWithdrawals),
+		WithdrawalsAvailable: apitypes.NewBigIntStr(bucket.WithdrawalsAt(blockNum)),
+		NextRefillBlock:      bucket.NextRefillBlock(blockNum),
+	}
 }
 
 // RollupVariablesAPI are the variables of the Rollup Smart Contract
@@ -481,15 +673,9 @@ func NewRollupVariablesAPI(rollupVariables *common.RollupVariables) *RollupVaria
 		SafeMode:              rollupVariables.SafeMode,
 		Buckets:               buckets,
 	}
-	for i, bucket := range rollupVariables.Buckets {
-		rollupVars.Buckets[i] = BucketParamsAPI{
-			CeilUSD:         apitypes.NewBigIntStr(bucket.CeilUSD),
-			BlockStamp:      apitypes.NewBigIntStr(bucket.BlockStamp),
-			Withdrawals:     apitypes.NewBigIntStr(bucket.Withdrawals),
-			RateBlocks:      apitypes.NewBigIntStr(bucket.RateBlocks),
-			RateWithdrawals: apitypes.NewBigIntStr(bucket.RateWithdrawals),
-			MaxWithdrawals:  apitypes.NewBigIntStr(bucket.MaxWithdrawals),
-		}
+	for i := range rollupVariables.Buckets {
+		rollupVars.Buckets[i] = NewBucketParamsAPI(&rollupVariables.Buckets[i],
+			rollupVariables.EthBlockNum)
 	}
 	return &rollupVars
 }
@@ -543,3 +729,55 @@ func NewAuctionVariablesAPI(auctionVariables *common.AuctionVariables) *AuctionV
 
 	return &auctionVars
 }
+
+// TokenStatsWindowAPI are the aggregated usage statistics of a token over a
+// time window
+type TokenStatsWindowAPI struct {
+	// Transactions is the number of forged txs of the token
+	Transactions int64 `json:"transactions"`
+	// Volume is the sum of the transferred amounts, in the base unit of
+	// the token
+	Volume *apitypes.BigIntStr `json:"volume"`
+	// VolumeUSD is the sum of the transferred amounts valued in USD at
+	// the moment each tx entered the DB.  Txs whose token had no known
+	// price at that moment don't contribute
+	VolumeUSD float64 `json:"volumeUSD"`
+	// UniqueAccounts is the number of distinct sender accounts
+	UniqueAccounts int64 `json:"uniqueAccounts"`
+}
+
+// TokenStatsAPI are the aggregated usage statistics of a token returned by
+// the GET /tokens/:id/stats endpoint
+type TokenStatsAPI struct {
+	TokenID     common.TokenID      `json:"tokenId"`
+	Last24Hours TokenStatsWindowAPI `json:"last24Hours"`
+	Last7Days   TokenStatsWindowAPI `json:"last7Days"`
+	Total       TokenStatsWindowAPI `json:"total"`
+}
+
+// ExitsSummaryAPI is the per-token aggregation of the exits of a user
+// returned by the GET /exits-summary endpoint
+type ExitsSummaryAPI struct {
+	TokenID       common.TokenID `json:"tokenId" meddler:"token_id"`
+	TokenSymbol   string         `json:"tokenSymbol" meddler:"symbol"`
+	TokenDecimals uint64         `json:"tokenDecimals" meddler:"decimals"`
+	// TotalExited is the sum of the balances of all the exits of the
+	// user for the token, no matter their state
+	TotalExited apitypes.BigIntStr `json:"totalExited" meddler:"total_exited"`
+	// WithdrawableInstant is the amount that can be withdrawn from the
+	// rollup right now (exits that haven't been withdrawn nor requested
+	// as delayed withdrawals)
+	WithdrawableInstant apitypes.BigIntStr `json:"withdrawableInstant" meddler:"withdrawable_instant"`
+	// WithdrawableDelayed is the amount sitting in the withdrawal
+	// delayer whose withdrawal delay has already elapsed
+	WithdrawableDelayed apitypes.BigIntStr `json:"withdrawableDelayed" meddler:"withdrawable_delayed"`
+	// PendingDelayed is the amount sitting in the withdrawal delayer
+	// whose withdrawal delay hasn't elapsed yet
+	PendingDelayed apitypes.BigIntStr `json:"pendingDelayed" meddler:"pending_delayed"`
+	// WithdrawnInstant is the amount already withdrawn through instant
+	// withdrawals
+	WithdrawnInstant apitypes.BigIntStr `json:"withdrawnInstant" meddler:"withdrawn_instant"`
+	// WithdrawnDelayed is the amount already withdrawn through the
+	// withdrawal delayer
+	WithdrawnDelayed apitypes.BigIntStr `json:"withdrawnDelayed" meddler:"withdrawn_delayed"`
+}
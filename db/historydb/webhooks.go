@@ -0,0 +1,143 @@
+package historydb
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/russross/meddler"
+)
+
+// WebhookSubscription is a webhook registration: the node POSTs a signed JSON
+// notification to URL every time an event matching the filter happens.  The
+// filter fields that are nil don't restrict the subscription, so a
+// subscription with no filter receives every event of its subscribed types.
+type WebhookSubscription struct {
+	ID     int64  `meddler:"id"`
+	URL    string `meddler:"url"`
+	Secret string `meddler:"secret"`
+	// EthAddr, when set, restricts the subscription to events involving
+	// the given ethereum address
+	EthAddr *ethCommon.Address `meddler:"eth_addr"`
+	// BJJ, when set, restricts the subscription to events involving the
+	// given BabyJubJub public key
+	BJJ *babyjub.PublicKeyComp `meddler:"bjj"`
+	// Idx, when set, restricts the subscription to events involving the
+	// given account index
+	Idx *common.Idx `meddler:"idx"`
+	// EventTypes is the comma separated list of subscribed event types
+	// (see the webhook package for the valid types)
+	EventTypes string    `meddler:"event_types"`
+	CreatedAt  time.Time `meddler:"created_at,utctime"`
+}
+
+// AddWebhookSubscriptionAPI stores a new webhook subscription, setting its ID
+func (hdb *HistoryDB) AddWebhookSubscriptionAPI(sub *WebhookSubscription) error {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	return tracerr.Wrap(meddler.Insert(hdb.dbWrite, "webhook_subscription", sub))
+}
+
+// GetWebhookSubscriptionsAPI returns all the webhook subscriptions
+func (hdb *HistoryDB) GetWebhookSubscriptionsAPI() ([]WebhookSubscription, error) {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	return hdb.GetWebhookSubscriptions()
+}
+
+// DeleteWebhookSubscriptionAPI deletes the webhook subscription with the
+// given ID, returning sql.ErrNoRows if it doesn't exist
+func (hdb *HistoryDB) DeleteWebhookSubscriptionAPI(id int64) error {
+	cancel, err := hdb.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer hdb.apiConnCon.Release()
+	res, err := hdb.dbWrite.Exec(
+		"DELETE FROM webhook_subscription WHERE id = $1;", id)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if rowsAffected == 0 {
+		return tracerr.Wrap(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// GetWebhookSubscriptions returns all the webhook subscriptions.  Used by the
+// webhook notifier, which runs outside the API connection limiter
+func (hdb *HistoryDB) GetWebhookSubscriptions() ([]WebhookSubscription, error) {
+	var subs []*WebhookSubscription
+	err := meddler.QueryAll(
+		hdb.read(), &subs,
+		"SELECT * FROM webhook_subscription ORDER BY id ASC;",
+	)
+	return db.SlicePtrsToSlice(subs).([]WebhookSubscription), tracerr.Wrap(err)
+}
+
+// WebhookForgedTx is the subset of a forged tx sent in the tx-forged webhook
+// notifications
+type WebhookForgedTx struct {
+	TxID        common.TxID            `meddler:"id" json:"id"`
+	Type        common.TxType          `meddler:"type" json:"type"`
+	FromIdx     *common.Idx            `meddler:"from_idx" json:"fromAccountIndex"`
+	ToIdx       common.Idx             `meddler:"to_idx" json:"toAccountIndex"`
+	FromEthAddr *ethCommon.Address     `meddler:"from_eth_addr" json:"fromEthereumAddress"`
+	ToEthAddr   *ethCommon.Address     `meddler:"to_eth_addr" json:"toEthereumAddress"`
+	FromBJJ     *babyjub.PublicKeyComp `meddler:"from_bjj" json:"fromBJJ"`
+	ToBJJ       *babyjub.PublicKeyComp `meddler:"to_bjj" json:"toBJJ"`
+	Amount      *big.Int               `meddler:"amount,bigint" json:"amount"`
+	TokenID     common.TokenID         `meddler:"token_id" json:"tokenId"`
+	BatchNum    common.BatchNum        `meddler:"batch_num" json:"batchNum"`
+}
+
+// GetWebhookForgedTxs returns the txs forged in batches after fromBatchNum,
+// ordered by forging order.  Used by the webhook notifier
+func (hdb *HistoryDB) GetWebhookForgedTxs(fromBatchNum common.BatchNum) ([]WebhookForgedTx, error) {
+	var txs []*WebhookForgedTx
+	err := meddler.QueryAll(
+		hdb.read(), &txs,
+		`SELECT id, type, from_idx, to_idx, from_eth_addr, to_eth_addr,
+		from_bjj, to_bjj, amount, token_id, batch_num FROM tx
+		WHERE batch_num > $1 ORDER BY batch_num ASC, position ASC;`, fromBatchNum,
+	)
+	return db.SlicePtrsToSlice(txs).([]WebhookForgedTx), tracerr.Wrap(err)
+}
+
+// WebhookExit is the subset of an exit sent in the exit-withdrawable webhook
+// notifications
+type WebhookExit struct {
+	BatchNum   common.BatchNum `meddler:"batch_num" json:"batchNum"`
+	AccountIdx common.Idx      `meddler:"account_idx" json:"accountIndex"`
+	Balance    *big.Int        `meddler:"balance,bigint" json:"balance"`
+}
+
+// GetWebhookExits returns the exits of batches forged after fromBatchNum.
+// Used by the webhook notifier
+func (hdb *HistoryDB) GetWebhookExits(fromBatchNum common.BatchNum) ([]WebhookExit, error) {
+	var exits []*WebhookExit
+	err := meddler.QueryAll(
+		hdb.read(), &exits,
+		`SELECT batch_num, account_idx, balance FROM exit_tree
+		WHERE batch_num > $1 ORDER BY batch_num ASC, account_idx ASC;`, fromBatchNum,
+	)
+	return db.SlicePtrsToSlice(exits).([]WebhookExit), tracerr.Wrap(err)
+}
@@ -0,0 +1,218 @@
+package kvdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-merkletree/db"
+	"github.com/iden3/go-merkletree/db/memory"
+	"github.com/iden3/go-merkletree/db/pebble"
+)
+
+// StorageBackend defines the underlying key-value storage of the KVDB
+type StorageBackend string
+
+const (
+	// BackendPebble stores the state and its checkpoints on disk using
+	// pebble.  It's the default and the one that must be used in
+	// production, as the state survives restarts
+	BackendPebble StorageBackend = "pebble"
+	// BackendMemory keeps the state and its checkpoints in memory,
+	// avoiding temp directories and disk I/O.  Used by tests and
+	// simulation, where persistence across restarts is not needed
+	BackendMemory StorageBackend = "memory"
+)
+
+// storageBackend abstracts how the KVDB opens, copies and deletes the
+// key-value stores that hold the current state and its checkpoints.  Each
+// store is identified by a filesystem-like path, so that the same
+// checkpointing logic works over on-disk pebble storages and over in-memory
+// ones
+type storageBackend interface {
+	// Open opens the storage at the given path, creating it if it
+	// doesn't exist
+	Open(path string) (db.Storage, error)
+	// Checkpoint copies the opened storage sto into dest, overwriting a
+	// previous storage at dest if there was one
+	Checkpoint(sto db.Storage, dest string) error
+	// Copy copies the (non-opened) storage at source into dest,
+	// overwriting a previous storage at dest if there was one
+	Copy(source, dest string) error
+	// Remove deletes the storage at the given path, if it exists
+	Remove(path string) error
+	// Exists returns whether a storage exists at the given path
+	Exists(path string) (bool, error)
+	// List returns the names (relative to dir) of the storages under dir
+	List(dir string) ([]string, error)
+}
+
+// newStorageBackend returns the storageBackend implementation for the given
+// StorageBackend, defaulting to pebble when unset
+func newStorageBackend(backend StorageBackend) (storageBackend, error) {
+	switch backend {
+	case "", BackendPebble:
+		return pebbleBackend{}, nil
+	case BackendMemory:
+		return memoryBackend{}, nil
+	default:
+		return nil, tracerr.Wrap(fmt.Errorf("unknown storage backend %q", backend))
+	}
+}
+
+// pebbleBackend implements storageBackend over pebble storages stored on
+// disk, where each path is a directory
+type pebbleBackend struct{}
+
+func (pebbleBackend) Open(path string) (db.Storage, error) {
+	sto, err := pebble.NewPebbleStorage(path, false)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return sto, nil
+}
+
+func (b pebbleBackend) Checkpoint(sto db.Storage, dest string) error {
+	if err := b.Remove(dest); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(sto.(*pebble.Storage).Pebble().Checkpoint(dest))
+}
+
+func (pebbleBackend) Copy(source, dest string) error {
+	return PebbleMakeCheckpoint(source, dest)
+}
+
+func (pebbleBackend) Remove(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(os.RemoveAll(path))
+}
+
+func (pebbleBackend) Exists(path string) (bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	return true, nil
+}
+
+func (pebbleBackend) List(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	names := []string{}
+	for _, file := range files {
+		if file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
+
+// memoryStores is the global registry of in-memory storages, indexed by
+// path.  It plays the role that the filesystem plays for the pebble backend:
+// a shared namespace, so that copies between two KVDBs (as done by
+// ResetFromSynchronizer) also work when both use the memory backend
+var memoryStores = struct {
+	rw     sync.RWMutex
+	stores map[string]*memory.Storage
+}{stores: make(map[string]*memory.Storage)}
+
+// memoryBackend implements storageBackend over in-memory storages.  Since
+// closing a memory storage is a no-op, opening a path again returns the same
+// storage with its previous content, mimicking the on-disk behavior
+type memoryBackend struct{}
+
+func (memoryBackend) Open(path string) (db.Storage, error) {
+	memoryStores.rw.Lock()
+	defer memoryStores.rw.Unlock()
+	if sto, ok := memoryStores.stores[path]; ok {
+		return sto, nil
+	}
+	sto := memory.NewMemoryStorage()
+	memoryStores.stores[path] = sto
+	return sto, nil
+}
+
+func copyMemoryStorage(source db.Storage) (*memory.Storage, error) {
+	dest := memory.NewMemoryStorage()
+	tx, err := dest.NewTx()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := source.Iterate(func(key, value []byte) (bool, error) {
+		return true, tx.Put(key, value)
+	}); err != nil {
+		tx.Close()
+		return nil, tracerr.Wrap(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return dest, nil
+}
+
+func (memoryBackend) Checkpoint(sto db.Storage, dest string) error {
+	copied, err := copyMemoryStorage(sto)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	memoryStores.rw.Lock()
+	defer memoryStores.rw.Unlock()
+	memoryStores.stores[dest] = copied
+	return nil
+}
+
+func (memoryBackend) Copy(source, dest string) error {
+	memoryStores.rw.RLock()
+	sto, ok := memoryStores.stores[source]
+	memoryStores.rw.RUnlock()
+	if !ok {
+		return tracerr.Wrap(fmt.Errorf("memory storage %q does not exist", source))
+	}
+	copied, err := copyMemoryStorage(sto)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	memoryStores.rw.Lock()
+	defer memoryStores.rw.Unlock()
+	memoryStores.stores[dest] = copied
+	return nil
+}
+
+func (memoryBackend) Remove(path string) error {
+	memoryStores.rw.Lock()
+	defer memoryStores.rw.Unlock()
+	delete(memoryStores.stores, path)
+	return nil
+}
+
+func (memoryBackend) Exists(path string) (bool, error) {
+	memoryStores.rw.RLock()
+	defer memoryStores.rw.RUnlock()
+	_, ok := memoryStores.stores[path]
+	return ok, nil
+}
+
+func (memoryBackend) List(dir string) ([]string, error) {
+	memoryStores.rw.RLock()
+	defer memoryStores.rw.RUnlock()
+	prefix := path.Clean(dir) + "/"
+	names := []string{}
+	for path := range memoryStores.stores {
+		if strings.HasPrefix(path, prefix) {
+			names = append(names, strings.TrimPrefix(path, prefix))
+		}
+	}
+	return names, nil
+}
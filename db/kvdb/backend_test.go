@@ -0,0 +1,88 @@
+package kvdb
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runBackendScenario runs the same sequence of operations (puts, checkpoints,
+// resets) over a KVDB and returns its observable state, so that the result
+// can be compared between backends
+func runBackendScenario(t *testing.T, backend StorageBackend) ([]int, map[string]string,
+	common.BatchNum, common.Idx) {
+	dir, err := ioutil.TempDir("", "kvdbBackend")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dir)
+
+	db, err := NewKVDB(Config{Path: dir, Keep: 128, Backend: backend})
+	require.NoError(t, err)
+	defer db.Close()
+
+	// make 6 checkpoints, adding a key-value at each batch
+	for i := 0; i < 6; i++ {
+		addTestKV(t, db, []byte{byte(i)}, []byte{byte(i), byte(i)})
+		err = db.MakeCheckpoint()
+		require.NoError(t, err)
+	}
+	err = db.SetCurrentIdx(common.Idx(256 + 6))
+	require.NoError(t, err)
+
+	// reset to batch 3 and overwrite the keys of batches 3-5
+	err = db.Reset(3)
+	require.NoError(t, err)
+	addTestKV(t, db, []byte{3}, []byte{0xff, 0xff})
+	err = db.MakeCheckpoint()
+	require.NoError(t, err)
+
+	checkpoints, err := db.ListCheckpoints()
+	require.NoError(t, err)
+
+	values := map[string]string{}
+	for i := 0; i < 6; i++ {
+		v, err := db.db.Get([]byte{byte(i)})
+		if err != nil {
+			continue
+		}
+		values[string([]byte{byte(i)})] = string(v)
+	}
+	return checkpoints, values, db.CurrentBatch, db.CurrentIdx
+}
+
+func TestBackendParity(t *testing.T) {
+	pebbleCheckpoints, pebbleValues, pebbleBatch, pebbleIdx :=
+		runBackendScenario(t, BackendPebble)
+	memCheckpoints, memValues, memBatch, memIdx :=
+		runBackendScenario(t, BackendMemory)
+
+	assert.Equal(t, pebbleCheckpoints, memCheckpoints)
+	assert.Equal(t, pebbleValues, memValues)
+	assert.Equal(t, pebbleBatch, memBatch)
+	assert.Equal(t, pebbleIdx, memIdx)
+}
+
+func TestMemoryBackendReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvdbMemReopen")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dir)
+
+	db, err := NewKVDB(Config{Path: dir, Keep: 128, Backend: BackendMemory})
+	require.NoError(t, err)
+	addTestKV(t, db, []byte("key"), []byte("value"))
+	err = db.MakeCheckpoint()
+	require.NoError(t, err)
+	db.Close()
+
+	// closing a memory KVDB is a no-op for the stored state: reopening
+	// the same path must recover it, like the pebble backend does
+	db, err = NewKVDB(Config{Path: dir, Keep: 128, Backend: BackendMemory})
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, common.BatchNum(1), db.CurrentBatch)
+	v, err := db.db.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), v)
+}
@@ -2,8 +2,8 @@
 package kvdb
 
 import (
+	"bytes"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"sort"
@@ -36,15 +36,24 @@ var (
 	KeyCurrentBatch = []byte("k:currentbatch")
 	// keyCurrentIdx is used as key in the db to store the CurrentIdx
 	keyCurrentIdx = []byte("k:idx")
+	// keyLastSynced is used as key in the db to store the BatchNum of the
+	// last checkpoint that was brought from the synchronizer KVDB, which
+	// is the base for delta resets
+	keyLastSynced = []byte("k:lastsynced")
 	// ErrNoLast is returned when the KVDB has been configured to not have
 	// a Last checkpoint but a Last method is used
 	ErrNoLast = fmt.Errorf("no last checkpoint")
+	// errNoDeltaBase is returned when a reset from the synchronizer can't
+	// be applied incrementally because there's no base checkpoint shared
+	// with the synchronizer, so a full copy is required
+	errNoDeltaBase = fmt.Errorf("no base checkpoint for a delta reset")
 )
 
 // KVDB represents the Key-Value DB object
 type KVDB struct {
-	cfg Config
-	db  *pebble.Storage
+	cfg  Config
+	db   db.Storage
+	back storageBackend
 	// CurrentIdx holds the current Idx that the BatchBuilder is using
 	CurrentIdx      common.Idx
 	CurrentBatch    common.BatchNum
@@ -57,8 +66,9 @@ type KVDB struct {
 // Last is a consistent view to the last batch of the stateDB that can
 // be queried concurrently.
 type Last struct {
-	db   *pebble.Storage
+	db   db.Storage
 	path string
+	back storageBackend
 	rw   sync.RWMutex
 }
 
@@ -70,10 +80,10 @@ func (k *Last) setNew() error {
 		k.db = nil
 	}
 	lastPath := path.Join(k.path, PathLast)
-	if err := os.RemoveAll(lastPath); err != nil {
+	if err := k.back.Remove(lastPath); err != nil {
 		return tracerr.Wrap(err)
 	}
-	db, err := pebble.NewPebbleStorage(lastPath, false)
+	db, err := k.back.Open(lastPath)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -92,7 +102,7 @@ func (k *Last) set(kvdb *KVDB, batchNum common.BatchNum) error {
 	if err := kvdb.MakeCheckpointFromTo(batchNum, lastPath); err != nil {
 		return tracerr.Wrap(err)
 	}
-	db, err := pebble.NewPebbleStorage(lastPath, false)
+	db, err := k.back.Open(lastPath)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -116,21 +126,31 @@ type Config struct {
 	// Keep is the number of old checkpoints to keep.  If 0, all
 	// checkpoints are kept.
 	Keep int
+	// KeepEvery, when set along Keep, retains one checkpoint every
+	// KeepEvery batches beyond the Keep most recent ones instead of
+	// deleting all of them, keeping a sparse history of the state.  If 0,
+	// all checkpoints older than the Keep most recent ones are deleted.
+	KeepEvery int
 	// At every checkpoint, check that there are no gaps between the
 	// checkpoints
 	NoGapsCheck bool
 	// NoLast skips having an opened DB with a checkpoint to the last
 	// batchNum for thread-safe reads.
 	NoLast bool
+	// Backend selects the underlying key-value storage.  If empty,
+	// BackendPebble is used.
+	Backend StorageBackend
 }
 
 // NewKVDB creates a new KVDB, allowing to use an in-memory or in-disk storage.
 // Checkpoints older than the value defined by `keep` will be deleted.
 // func NewKVDB(pathDB string, keep int) (*KVDB, error) {
 func NewKVDB(cfg Config) (*KVDB, error) {
-	var sto *pebble.Storage
-	var err error
-	sto, err = pebble.NewPebbleStorage(path.Join(cfg.Path, PathCurrent), false)
+	back, err := newStorageBackend(cfg.Backend)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	sto, err := back.Open(path.Join(cfg.Path, PathCurrent))
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -139,11 +159,13 @@ func NewKVDB(cfg Config) (*KVDB, error) {
 	if !cfg.NoLast {
 		last = &Last{
 			path: cfg.Path,
+			back: back,
 		}
 	}
 	kvdb := &KVDB{
 		cfg:  cfg,
 		db:   sto,
+		back: back,
 		last: last,
 	}
 	// load currentBatch
@@ -162,7 +184,7 @@ func NewKVDB(cfg Config) (*KVDB, error) {
 }
 
 // LastRead is a thread-safe method to query the last KVDB
-func (k *KVDB) LastRead(fn func(db *pebble.Storage) error) error {
+func (k *KVDB) LastRead(fn func(db db.Storage) error) error {
 	if k.last == nil {
 		return tracerr.Wrap(ErrNoLast)
 	}
@@ -171,8 +193,8 @@ func (k *KVDB) LastRead(fn func(db *pebble.Storage) error) error {
 	return fn(k.last.db)
 }
 
-// DB returns the *pebble.Storage from the KVDB
-func (k *KVDB) DB() *pebble.Storage {
+// DB returns the db.Storage from the KVDB
+func (k *KVDB) DB() db.Storage {
 	return k.db
 }
 
@@ -203,7 +225,7 @@ func (k *KVDB) reset(batchNum common.BatchNum, closeCurrent bool) error {
 		k.db = nil
 	}
 	// remove 'current'
-	if err := os.RemoveAll(currentPath); err != nil {
+	if err := k.back.Remove(currentPath); err != nil {
 		return tracerr.Wrap(err)
 	}
 	// remove all checkpoints > batchNum
@@ -227,7 +249,7 @@ func (k *KVDB) reset(batchNum common.BatchNum, closeCurrent bool) error {
 
 	if batchNum == 0 {
 		// if batchNum == 0, open the new fresh 'current'
-		sto, err := pebble.NewPebbleStorage(currentPath, false)
+		sto, err := k.back.Open(currentPath)
 		if err != nil {
 			return tracerr.Wrap(err)
 		}
@@ -255,7 +277,7 @@ func (k *KVDB) reset(batchNum common.BatchNum, closeCurrent bool) error {
 	}
 
 	// open the new 'current'
-	sto, err := pebble.NewPebbleStorage(currentPath, false)
+	sto, err := k.back.Open(currentPath)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -276,12 +298,20 @@ func (k *KVDB) reset(batchNum common.BatchNum, closeCurrent bool) error {
 }
 
 // ResetFromSynchronizer performs a reset in the KVDB getting the state from
-// synchronizerKVDB for the given batchNum.
+// synchronizerKVDB for the given batchNum.  When a previous reset left a base
+// checkpoint that the synchronizer still has, only the keys modified between
+// the base and batchNum are transferred; otherwise the whole state is copied.
 func (k *KVDB) ResetFromSynchronizer(batchNum common.BatchNum, synchronizerKVDB *KVDB) error {
 	if synchronizerKVDB == nil {
 		return tracerr.Wrap(fmt.Errorf("synchronizerKVDB can not be nil"))
 	}
 
+	if err := k.resetFromSynchronizerDelta(batchNum, synchronizerKVDB); err == nil {
+		return nil
+	} else if tracerr.Unwrap(err) != errNoDeltaBase {
+		return tracerr.Wrap(err)
+	}
+
 	currentPath := path.Join(k.cfg.Path, PathCurrent)
 	if k.db != nil {
 		k.db.Close()
@@ -289,7 +319,7 @@ func (k *KVDB) ResetFromSynchronizer(batchNum common.BatchNum, synchronizerKVDB
 	}
 
 	// remove 'current'
-	if err := os.RemoveAll(currentPath); err != nil {
+	if err := k.back.Remove(currentPath); err != nil {
 		return tracerr.Wrap(err)
 	}
 	// remove all checkpoints
@@ -305,7 +335,7 @@ func (k *KVDB) ResetFromSynchronizer(batchNum common.BatchNum, synchronizerKVDB
 
 	if batchNum == 0 {
 		// if batchNum == 0, open the new fresh 'current'
-		sto, err := pebble.NewPebbleStorage(currentPath, false)
+		sto, err := k.back.Open(currentPath)
 		if err != nil {
 			return tracerr.Wrap(err)
 		}
@@ -330,12 +360,149 @@ func (k *KVDB) ResetFromSynchronizer(batchNum common.BatchNum, synchronizerKVDB
 	}
 
 	// open the new 'current'
-	sto, err := pebble.NewPebbleStorage(currentPath, false)
+	sto, err := k.back.Open(currentPath)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	k.db = sto
+
+	// get currentBatch num
+	k.CurrentBatch, err = k.GetCurrentBatch()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	// get currentIdx
+	k.CurrentIdx, err = k.GetCurrentIdx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	// record the base checkpoint for future delta resets
+	if err := k.setLastSynced(batchNum); err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	return nil
+}
+
+// resetFromSynchronizerDelta resets the KVDB to the state of the
+// synchronizerKVDB at batchNum by applying over the base checkpoint (the one
+// brought from the synchronizer in a previous reset) only the keys that the
+// synchronizer modified since then.  This works because the StateDB only adds
+// and updates keys, never deletes them.  Returns errNoDeltaBase when there's
+// no base checkpoint available in both KVDBs, in which case the caller must
+// fall back to a full copy.
+func (k *KVDB) resetFromSynchronizerDelta(batchNum common.BatchNum,
+	synchronizerKVDB *KVDB) error {
+	if k.db == nil || batchNum == 0 {
+		return tracerr.Wrap(errNoDeltaBase)
+	}
+	from, err := k.GetLastSynced()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if from == 0 || from >= batchNum {
+		return tracerr.Wrap(errNoDeltaBase)
+	}
+	// the base checkpoint must still exist locally (it can be garbage
+	// collected by DeleteOldCheckpoints) and in the synchronizer
+	for _, kvdb := range []*KVDB{k, synchronizerKVDB} {
+		if exists, err := kvdb.CheckpointExists(from); err != nil {
+			return tracerr.Wrap(err)
+		} else if !exists {
+			return tracerr.Wrap(errNoDeltaBase)
+		}
+	}
+	if exists, err := synchronizerKVDB.CheckpointExists(batchNum); err != nil {
+		return tracerr.Wrap(err)
+	} else if !exists {
+		return tracerr.Wrap(errNoDeltaBase)
+	}
+
+	currentPath := path.Join(k.cfg.Path, PathCurrent)
+	k.db.Close()
+	k.db = nil
+
+	// remove 'current' and all the checkpoints except the base
+	if err := k.back.Remove(currentPath); err != nil {
+		return tracerr.Wrap(err)
+	}
+	list, err := k.ListCheckpoints()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, bn := range list {
+		if common.BatchNum(bn) == from {
+			continue
+		}
+		if err := k.DeleteCheckpoint(common.BatchNum(bn)); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+
+	// copy the base checkpoint to 'current' and open it
+	if err := k.MakeCheckpointFromTo(from, currentPath); err != nil {
+		return tracerr.Wrap(err)
+	}
+	sto, err := k.back.Open(currentPath)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
 	k.db = sto
 
+	// apply over 'current' the keys that the synchronizer modified
+	// between the base and batchNum
+	oldSto, err := synchronizerKVDB.OpenCheckpoint(from)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer oldSto.Close()
+	newSto, err := synchronizerKVDB.OpenCheckpoint(batchNum)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer newSto.Close()
+	tx, err := k.db.NewTx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	nKeys := 0
+	if err := newSto.Iterate(func(key, value []byte) (bool, error) {
+		oldValue, err := oldSto.Get(key)
+		if err != nil && tracerr.Unwrap(err) != db.ErrNotFound {
+			return false, tracerr.Wrap(err)
+		}
+		if err == nil && bytes.Equal(oldValue, value) {
+			return true, nil
+		}
+		if err := tx.Put(key, value); err != nil {
+			return false, tracerr.Wrap(err)
+		}
+		nKeys++
+		return true, nil
+	}); err != nil {
+		tx.Close()
+		return tracerr.Wrap(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	log.Debugw("KVDB delta reset from synchronizer",
+		"batchNum", batchNum, "base", from, "keys", nKeys)
+
+	// checkpoint the new state at batchNum, as the full copy would
+	if err := k.setLastSynced(batchNum); err != nil {
+		return tracerr.Wrap(err)
+	}
+	checkpointPath := path.Join(k.cfg.Path, fmt.Sprintf("%s%d", PathBatchNum, batchNum))
+	if err := k.back.Checkpoint(k.db, checkpointPath); err != nil {
+		return tracerr.Wrap(err)
+	}
+	// the new checkpoint replaces the base one, which is removed so that no
+	// gap is left between the local checkpoints
+	if err := k.DeleteCheckpoint(from); err != nil {
+		return tracerr.Wrap(err)
+	}
+
 	// get currentBatch num
 	k.CurrentBatch, err = k.GetCurrentBatch()
 	if err != nil {
@@ -350,6 +517,35 @@ func (k *KVDB) ResetFromSynchronizer(batchNum common.BatchNum, synchronizerKVDB
 	return nil
 }
 
+// GetLastSynced returns the BatchNum of the last checkpoint brought from the
+// synchronizer KVDB, or 0 if there was none
+func (k *KVDB) GetLastSynced() (common.BatchNum, error) {
+	lsBytes, err := k.db.Get(keyLastSynced)
+	if tracerr.Unwrap(err) == db.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return common.BatchNumFromBytes(lsBytes)
+}
+
+// setLastSynced stores the BatchNum of the last checkpoint brought from the
+// synchronizer KVDB
+func (k *KVDB) setLastSynced(batchNum common.BatchNum) error {
+	tx, err := k.db.NewTx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := tx.Put(keyLastSynced, batchNum.Bytes()); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return nil
+}
+
 // GetCurrentBatch returns the current BatchNum stored in the KVDB
 func (k *KVDB) GetCurrentBatch() (common.BatchNum, error) {
 	cbBytes, err := k.db.Get(KeyCurrentBatch)
@@ -426,18 +622,9 @@ func (k *KVDB) MakeCheckpoint() error {
 		return tracerr.Wrap(err)
 	}
 
-	// if checkpoint BatchNum already exist in disk, delete it
-	if _, err := os.Stat(checkpointPath); os.IsNotExist(err) {
-	} else if err != nil {
-		return tracerr.Wrap(err)
-	} else {
-		if err := os.RemoveAll(checkpointPath); err != nil {
-			return tracerr.Wrap(err)
-		}
-	}
-
-	// execute Checkpoint
-	if err := k.db.Pebble().Checkpoint(checkpointPath); err != nil {
+	// execute Checkpoint, deleting a previous checkpoint at the same
+	// BatchNum if it exists
+	if err := k.back.Checkpoint(k.db, checkpointPath); err != nil {
 		return tracerr.Wrap(err)
 	}
 	// copy 'CurrentBatch' to 'last'
@@ -462,41 +649,56 @@ func (k *KVDB) MakeCheckpoint() error {
 // CheckpointExists returns true if the checkpoint exists
 func (k *KVDB) CheckpointExists(batchNum common.BatchNum) (bool, error) {
 	source := path.Join(k.cfg.Path, fmt.Sprintf("%s%d", PathBatchNum, batchNum))
-	if _, err := os.Stat(source); os.IsNotExist(err) {
-		return false, nil
-	} else if err != nil {
+	exists, err := k.back.Exists(source)
+	if err != nil {
 		return false, tracerr.Wrap(err)
 	}
-	return true, nil
+	return exists, nil
+}
+
+// OpenCheckpoint opens the storage of an existing checkpoint.  The returned
+// storage must be closed by the caller once it's no longer used.
+func (k *KVDB) OpenCheckpoint(batchNum common.BatchNum) (db.Storage, error) {
+	checkpointPath := path.Join(k.cfg.Path, fmt.Sprintf("%s%d", PathBatchNum, batchNum))
+	if exists, err := k.back.Exists(checkpointPath); err != nil {
+		return nil, tracerr.Wrap(err)
+	} else if !exists {
+		return nil, tracerr.Wrap(fmt.Errorf(
+			"Checkpoint with batchNum %d does not exist in DB", batchNum))
+	}
+	sto, err := k.back.Open(checkpointPath)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return sto, nil
 }
 
 // DeleteCheckpoint removes if exist the checkpoint of the given batchNum
 func (k *KVDB) DeleteCheckpoint(batchNum common.BatchNum) error {
 	checkpointPath := path.Join(k.cfg.Path, fmt.Sprintf("%s%d", PathBatchNum, batchNum))
 
-	if _, err := os.Stat(checkpointPath); os.IsNotExist(err) {
-		return tracerr.Wrap(fmt.Errorf("Checkpoint with batchNum %d does not exist in DB", batchNum))
-	} else if err != nil {
+	if exists, err := k.back.Exists(checkpointPath); err != nil {
 		return tracerr.Wrap(err)
+	} else if !exists {
+		return tracerr.Wrap(fmt.Errorf("Checkpoint with batchNum %d does not exist in DB", batchNum))
 	}
 
-	return os.RemoveAll(checkpointPath)
+	return k.back.Remove(checkpointPath)
 }
 
 // ListCheckpoints returns the list of batchNums of the checkpoints, sorted.
 // If there's a gap between the list of checkpoints, an error is returned.
 func (k *KVDB) ListCheckpoints() ([]int, error) {
-	files, err := ioutil.ReadDir(k.cfg.Path)
+	names, err := k.back.List(k.cfg.Path)
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 	checkpoints := []int{}
 	var checkpoint int
 	pattern := fmt.Sprintf("%s%%d", PathBatchNum)
-	for _, file := range files {
-		fileName := file.Name()
-		if file.IsDir() && strings.HasPrefix(fileName, PathBatchNum) {
-			if _, err := fmt.Sscanf(fileName, pattern, &checkpoint); err != nil {
+	for _, name := range names {
+		if strings.HasPrefix(name, PathBatchNum) {
+			if _, err := fmt.Sscanf(name, pattern, &checkpoint); err != nil {
 				return nil, tracerr.Wrap(err)
 			}
 			checkpoints = append(checkpoints, checkpoint)
@@ -504,8 +706,15 @@ func (k *KVDB) ListCheckpoints() ([]int, error) {
 	}
 	sort.Ints(checkpoints)
 	if !k.cfg.NoGapsCheck && len(checkpoints) > 0 {
-		first := checkpoints[0]
-		for _, checkpoint := range checkpoints[1:] {
+		// With sparse retention (KeepEvery), checkpoints older than the
+		// Keep most recent ones are expected to have gaps; only the
+		// recent ones must be contiguous
+		contiguous := checkpoints
+		if k.cfg.KeepEvery > 0 && k.cfg.Keep > 0 && len(contiguous) > k.cfg.Keep {
+			contiguous = contiguous[len(contiguous)-k.cfg.Keep:]
+		}
+		first := contiguous[0]
+		for _, checkpoint := range contiguous[1:] {
 			first++
 			if checkpoint != first {
 				log.Errorw("gap between checkpoints", "checkpoints", checkpoints)
@@ -528,6 +737,11 @@ func (k *KVDB) DeleteOldCheckpoints() error {
 	}
 	if k.cfg.Keep > 0 && len(list) > k.cfg.Keep {
 		for _, checkpoint := range list[:len(list)-k.cfg.Keep] {
+			// Retain one checkpoint every KeepEvery batches as
+			// sparse history
+			if k.cfg.KeepEvery > 0 && checkpoint%k.cfg.KeepEvery == 0 {
+				continue
+			}
 			if err := k.DeleteCheckpoint(common.BatchNum(checkpoint)); err != nil {
 				return tracerr.Wrap(err)
 			}
@@ -541,11 +755,11 @@ func (k *KVDB) DeleteOldCheckpoints() error {
 // multiple places at the same time.
 func (k *KVDB) MakeCheckpointFromTo(fromBatchNum common.BatchNum, dest string) error {
 	source := path.Join(k.cfg.Path, fmt.Sprintf("%s%d", PathBatchNum, fromBatchNum))
-	if _, err := os.Stat(source); os.IsNotExist(err) {
+	if exists, err := k.back.Exists(source); err != nil {
+		return tracerr.Wrap(err)
+	} else if !exists {
 		// if kvdb does not have checkpoint at batchNum, return err
 		return tracerr.Wrap(fmt.Errorf("Checkpoint \"%v\" does not exist", source))
-	} else if err != nil {
-		return tracerr.Wrap(err)
 	}
 	// By locking we allow calling MakeCheckpointFromTo from multiple
 	// places at the same time for the same stateDB.  This allows the
@@ -554,7 +768,7 @@ func (k *KVDB) MakeCheckpointFromTo(fromBatchNum common.BatchNum, dest string) e
 	// synchronizer to the same batchNum
 	k.mutexCheckpoint.Lock()
 	defer k.mutexCheckpoint.Unlock()
-	return PebbleMakeCheckpoint(source, dest)
+	return k.back.Copy(source, dest)
 }
 
 // PebbleMakeCheckpoint is a hepler function to make a pebble checkpoint from
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/iden3/go-merkletree/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -163,6 +164,108 @@ func TestCheckpoints(t *testing.T) {
 	db.Close()
 }
 
+// storageKVs returns all the key-values of a storage.  Keys and values are
+// copied because the Iterate callback reuses its buffers
+func storageKVs(t *testing.T, sto db.Storage) map[string][]byte {
+	kvs := make(map[string][]byte)
+	require.NoError(t, sto.Iterate(func(key, value []byte) (bool, error) {
+		kvs[string(key)] = append([]byte{}, value...)
+		return true, nil
+	}))
+	return kvs
+}
+
+// assertSameState checks that the current state of ldb is key-for-key equal to
+// the checkpoint of the synchronizer KVDB at batchNum.  keyLastSynced is local
+// bookkeeping of the delta resets, so it's only expected in ldb
+func assertSameState(t *testing.T, ldb, sdb *KVDB, batchNum common.BatchNum) {
+	sto, err := sdb.OpenCheckpoint(batchNum)
+	require.NoError(t, err)
+	defer sto.Close()
+	syncKVs := storageKVs(t, sto)
+	localKVs := storageKVs(t, ldb.DB())
+	delete(localKVs, string(keyLastSynced))
+	assert.Equal(t, syncKVs, localKVs)
+}
+
+// TestResetFromSynchronizerDelta checks that successive ResetFromSynchronizer
+// calls on the same local KVDB leave it key-for-key identical to the
+// synchronizer state: the first reset does a full copy and the following ones
+// only transfer the keys modified since the base checkpoint
+func TestResetFromSynchronizerDelta(t *testing.T) {
+	dirSync, err := ioutil.TempDir("", "sdb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dirSync)
+	sdb, err := NewKVDB(Config{Path: dirSync, Keep: 128})
+	require.NoError(t, err)
+
+	// batches 1 and 2 add ten keys each
+	for batch := 1; batch <= 2; batch++ {
+		for i := 0; i < 10; i++ {
+			addTestKV(t, sdb, []byte{byte(batch), byte(i)}, []byte{byte(batch), byte(i)})
+		}
+		require.NoError(t, sdb.MakeCheckpoint())
+	}
+
+	dirLocal, err := ioutil.TempDir("", "ldb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dirLocal)
+	ldb, err := NewKVDB(Config{Path: dirLocal, Keep: 128})
+	require.NoError(t, err)
+
+	// the first reset has no base checkpoint, so it does a full copy
+	err = ldb.ResetFromSynchronizer(2, sdb)
+	require.NoError(t, err)
+	cb, err := ldb.GetCurrentBatch()
+	require.NoError(t, err)
+	assert.Equal(t, common.BatchNum(2), cb)
+	base, err := ldb.GetLastSynced()
+	require.NoError(t, err)
+	assert.Equal(t, common.BatchNum(2), base)
+	assertSameState(t, ldb, sdb, 2)
+
+	// advance the synchronizer: batch 3 overwrites a key of batch 2 and
+	// adds new ones, batch 4 only adds keys
+	addTestKV(t, sdb, []byte{2, 0}, []byte{0xff, 0xff})
+	for i := 0; i < 10; i++ {
+		addTestKV(t, sdb, []byte{3, byte(i)}, []byte{3, byte(i)})
+	}
+	require.NoError(t, sdb.MakeCheckpoint())
+	for i := 0; i < 10; i++ {
+		addTestKV(t, sdb, []byte{4, byte(i)}, []byte{4, byte(i)})
+	}
+	require.NoError(t, sdb.MakeCheckpoint())
+
+	// the second reset on the same local KVDB shares the base checkpoint 2
+	// with the synchronizer, so it goes through the delta path
+	err = ldb.ResetFromSynchronizer(4, sdb)
+	require.NoError(t, err)
+	cb, err = ldb.GetCurrentBatch()
+	require.NoError(t, err)
+	assert.Equal(t, common.BatchNum(4), cb)
+	base, err = ldb.GetLastSynced()
+	require.NoError(t, err)
+	assert.Equal(t, common.BatchNum(4), base)
+	assertSameState(t, ldb, sdb, 4)
+	// the overwritten key carries the new value
+	v, err := ldb.DB().Get([]byte{2, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff}, v)
+
+	// advance one more batch and apply the delta directly, to make sure
+	// the fast path itself is the one exercised
+	for i := 0; i < 10; i++ {
+		addTestKV(t, sdb, []byte{5, byte(i)}, []byte{5, byte(i)})
+	}
+	require.NoError(t, sdb.MakeCheckpoint())
+	err = ldb.resetFromSynchronizerDelta(5, sdb)
+	require.NoError(t, err)
+	assertSameState(t, ldb, sdb, 5)
+
+	ldb.Close()
+	sdb.Close()
+}
+
 func TestListCheckpoints(t *testing.T) {
 	dir, err := ioutil.TempDir("", "tmpdb")
 	require.NoError(t, err)
@@ -220,6 +323,33 @@ func TestDeleteOldCheckpoints(t *testing.T) {
 	db.Close()
 }
 
+func TestDeleteOldCheckpointsKeepEvery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmpdb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dir)
+
+	keep := 4
+	keepEvery := 8
+	db, err := NewKVDB(Config{Path: dir, Keep: keep, KeepEvery: keepEvery})
+	require.NoError(t, err)
+
+	numCheckpoints := 32
+	for i := 0; i < numCheckpoints; i++ {
+		err = db.MakeCheckpoint()
+		require.NoError(t, err)
+		err = db.DeleteOldCheckpoints()
+		require.NoError(t, err)
+	}
+	checkpoints, err := db.ListCheckpoints()
+	require.NoError(t, err)
+	// The `keep` most recent checkpoints are contiguous, and one
+	// checkpoint every `keepEvery` batches is retained as sparse history
+	expected := []int{8, 16, 24, 29, 30, 31, 32}
+	assert.Equal(t, expected, checkpoints)
+
+	db.Close()
+}
+
 func TestConcurrentDeleteOldCheckpoints(t *testing.T) {
 	dir, err := ioutil.TempDir("", "tmpdb")
 	require.NoError(t, err)
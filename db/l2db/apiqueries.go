@@ -1,10 +1,14 @@
 package l2db
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-iden3-crypto/babyjub"
@@ -13,6 +17,32 @@ import (
 
 var (
 	errPoolFull = fmt.Errorf("the pool is at full capacity. More transactions are not accepted currently")
+	// ErrDuplicatedTx is returned when inserting a tx that is already in the pool
+	// with the exact same signed fields. Callers can treat the insert as an
+	// idempotent resubmission
+	ErrDuplicatedTx = fmt.Errorf("tx already exists in the pool")
+	// ErrTxIDConflict is returned when inserting a tx whose TxID is already in the
+	// pool but whose signed fields differ from the stored ones
+	ErrTxIDConflict = fmt.Errorf("a different tx with the same TxID already exists in the pool")
+	// ErrFeeTooLow is returned when inserting a tx whose fee is below the
+	// minimum required by the coordinator fee policy
+	ErrFeeTooLow = fmt.Errorf("tx fee is below the minimum required by the coordinator")
+	// ErrFreeTxAllowanceExceeded is returned when inserting a tx whose fee is
+	// below the minimum required by the coordinator fee policy and the sender
+	// account has already used up its free tx allowance for the day
+	ErrFreeTxAllowanceExceeded = fmt.Errorf("tx fee is below the minimum required by the coordinator " +
+		"and the free tx allowance of the account is exhausted")
+	// ErrTxAdminDeleted is returned when inserting a tx whose TxID was
+	// previously deleted by the operator
+	ErrTxAdminDeleted = fmt.Errorf("tx was deleted by the operator and can't be re-admitted")
+	// ErrTxNotPending is returned when trying to delete a tx that exists in
+	// the pool but is not in pending state
+	ErrTxNotPending = fmt.Errorf("tx exists in the pool but is not in pending state")
+	// ErrReplaceFeeTooLow is returned when inserting a tx from an account
+	// and nonce that already has a pending tx in the pool, and the fee of
+	// the new tx is not strictly higher than the fee of the pending one
+	ErrReplaceFeeTooLow = fmt.Errorf("a tx from the same account and nonce is already pending " +
+		"and can only be replaced by a tx with a strictly higher fee")
 )
 
 // AddAccountCreationAuthAPI inserts an account creation authorization into the DB
@@ -52,23 +82,51 @@ func (l2db *L2DB) AddTxAPI(tx *PoolL2TxWrite) error {
 	defer l2db.apiConnCon.Release()
 
 	row := l2db.dbRead.QueryRow(`SELECT
-		($1::NUMERIC * COALESCE(token.usd, 0) * fee_percentage($2::NUMERIC)) /
-			(10.0 ^ token.decimals::NUMERIC)
+		(CAST($1 AS NUMERIC) * COALESCE(token.usd, 0) * fee_percentage(CAST($2 AS NUMERIC))) /
+			POWER(10.0, token.decimals)
 		FROM token WHERE token.token_id = $3;`,
 		tx.AmountFloat, tx.Fee, tx.TokenID)
 	var feeUSD float64
 	if err := row.Scan(&feeUSD); err != nil {
 		return tracerr.Wrap(err)
 	}
-	if feeUSD < l2db.minFeeUSD {
-		return tracerr.Wrap(fmt.Errorf("tx.feeUSD (%v) < minFeeUSD (%v)",
-			feeUSD, l2db.minFeeUSD))
+	belowMinFee := feeUSD < l2db.minFeeUSD
+	if minSelector, ok := l2db.tokenMinFee[tx.TokenID]; ok && tx.Fee < minSelector {
+		belowMinFee = true
+	}
+	if belowMinFee {
+		if err := l2db.checkFreeTxAllowance(tx.FromIdx); err != nil {
+			return tracerr.Wrap(err)
+		}
 	}
 	if feeUSD > l2db.maxFeeUSD {
 		return tracerr.Wrap(fmt.Errorf("tx.feeUSD (%v) > maxFeeUSD (%v)",
 			feeUSD, l2db.maxFeeUSD))
 	}
 
+	// Replace-by-fee: if the account already has a pending tx with the
+	// same nonce, the new tx is only admitted if it pays a strictly
+	// higher fee, and the pending one is invalidated after the insert.
+	// The tx_id <> check leaves the resubmission of the exact same tx to
+	// the duplicated key handling below, which makes it idempotent
+	var replacedTxID common.TxID
+	var replacedFee common.FeeSelector
+	replace := false
+	row = l2db.dbRead.QueryRow(
+		`SELECT tx_id, fee FROM tx_pool
+		WHERE from_idx = $1 AND nonce = $2 AND state = $3
+		AND NOT external_delete AND tx_id <> $4;`,
+		tx.FromIdx, tx.Nonce, common.PoolL2TxStatePending, tx.TxID,
+	)
+	if err := row.Scan(&replacedTxID, &replacedFee); err == nil {
+		if tx.Fee <= replacedFee {
+			return tracerr.Wrap(ErrReplaceFeeTooLow)
+		}
+		replace = true
+	} else if tracerr.Unwrap(err) != sql.ErrNoRows {
+		return tracerr.Wrap(err)
+	}
+
 	// Prepare insert SQL query argument parameters
 	namesPart, err := meddler.Default.ColumnsQuoted(tx, false)
 	if err != nil {
@@ -92,6 +150,9 @@ func (l2db *L2DB) AddTxAPI(tx *PoolL2TxWrite) error {
 	values = append(values, common.PoolL2TxStatePending, l2db.maxTxs)
 	res, err := l2db.dbWrite.Exec(q, values...)
 	if err != nil {
+		if l2db.isDuplicatedKeyErr(err) {
+			return l2db.addTxConflict(tx)
+		}
 		return tracerr.Wrap(err)
 	}
 	rowsAffected, err := res.RowsAffected()
@@ -101,6 +162,127 @@ func (l2db *L2DB) AddTxAPI(tx *PoolL2TxWrite) error {
 	if rowsAffected == 0 {
 		return tracerr.Wrap(errPoolFull)
 	}
+	if replace {
+		if err := l2db.invalidateReplacedTx(replacedTxID, tx.TxID); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// invalidateReplacedTx marks a pending pool tx as invalid because a tx from
+// the same account and nonce with a strictly higher fee has been admitted
+// (see AddTxAPI), recording the replacement in the audit trail
+func (l2db *L2DB) invalidateReplacedTx(oldTxID, newTxID common.TxID) error {
+	if !l2db.dialect.Returning {
+		_, err := l2db.invalidateSingleTxFallback(oldTxID,
+			fmt.Sprintf("Replaced by tx %v", newTxID), false, auditReasonReplaced)
+		return tracerr.Wrap(err)
+	}
+	_, err := l2db.dbWrite.Exec(
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = $1, info = $2
+			WHERE tx_id = $3 AND state = $4
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, reason)
+		SELECT tx_id, $4, $1, $5 FROM updated;`,
+		common.PoolL2TxStateInvalid,
+		fmt.Sprintf("Replaced by tx %v", newTxID),
+		oldTxID,
+		common.PoolL2TxStatePending,
+		auditReasonReplaced,
+	)
+	return tracerr.Wrap(err)
+}
+
+// checkFreeTxAllowance decides whether a tx below the minimum fee can still be
+// accepted into the pool thanks to the free tx allowance of the sender
+// account.  The allowance is computed over the txs sent by the account in the
+// last 24 hours that are still tracked in the pool
+func (l2db *L2DB) checkFreeTxAllowance(fromIdx common.Idx) error {
+	if l2db.freeTxsPerAccountPerDay == 0 {
+		return tracerr.Wrap(ErrFeeTooLow)
+	}
+	row := l2db.dbRead.QueryRow(
+		`SELECT COUNT(*) FROM tx_pool
+		WHERE from_idx = $1 AND timestamp > $2;`,
+		fromIdx, time.Now().UTC().Add(-24*time.Hour),
+	)
+	var recentTxs uint32
+	if err := row.Scan(&recentTxs); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if recentTxs >= l2db.freeTxsPerAccountPerDay {
+		return tracerr.Wrap(ErrFreeTxAllowanceExceeded)
+	}
+	return nil
+}
+
+// InvalidateTxByAdminAPI marks a pending pool tx as invalid on request of the
+// operator, recording the supplied reason in the audit trail.  The tx row is
+// kept in the pool (marked with external_delete) so that the same TxID can't
+// be re-admitted
+func (l2db *L2DB) InvalidateTxByAdminAPI(txID common.TxID, reason string) error {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	auditReason := auditReasonAdminDelete
+	if reason != "" {
+		auditReason = fmt.Sprintf("%s: %s", auditReasonAdminDelete, reason)
+	}
+	if !l2db.dialect.Returning {
+		rowsAffected, err := l2db.invalidateSingleTxFallback(txID, "", true, auditReason)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		return l2db.invalidateTxByAdminResult(rowsAffected, txID)
+	}
+	res, err := l2db.dbWrite.Exec(
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = $1, external_delete = true
+			WHERE tx_id = $2 AND state = $3
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, reason)
+		SELECT tx_id, $3, $1, $4 FROM updated;`,
+		common.PoolL2TxStateInvalid,
+		txID,
+		common.PoolL2TxStatePending,
+		auditReason,
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return l2db.invalidateTxByAdminResult(rowsAffected, txID)
+}
+
+// invalidateTxByAdminResult translates the number of rows affected by an
+// admin invalidation into the error expected by the API handler
+func (l2db *L2DB) invalidateTxByAdminResult(rowsAffected int64, txID common.TxID) error {
+	if rowsAffected == 0 {
+		// Distinguish a tx that doesn't exist from one that is not
+		// pending anymore
+		row := l2db.dbRead.QueryRow(
+			"SELECT COUNT(*) FROM tx_pool WHERE tx_id = $1;", txID)
+		var count int
+		if err := row.Scan(&count); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if count == 0 {
+			return tracerr.Wrap(sql.ErrNoRows)
+		}
+		return tracerr.Wrap(ErrTxNotPending)
+	}
 	return nil
 }
 
@@ -110,8 +292,8 @@ tx_pool.effective_from_bjj, hez_idx(tx_pool.to_idx, token.symbol) AS to_idx, tx_
 tx_pool.effective_to_bjj, tx_pool.token_id, tx_pool.amount, tx_pool.fee, tx_pool.nonce, 
 tx_pool.state, tx_pool.info, tx_pool.signature, tx_pool.timestamp, tx_pool.batch_num, hez_idx(tx_pool.rq_from_idx, token.symbol) AS rq_from_idx, 
 hez_idx(tx_pool.rq_to_idx, token.symbol) AS rq_to_idx, tx_pool.rq_to_eth_addr, tx_pool.rq_to_bjj, tx_pool.rq_token_id, tx_pool.rq_amount, 
-tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, 
-token.item_id AS token_item_id, token.eth_block_num, token.eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update 
+tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, tx_pool.max_num_batch,
+token.item_id AS token_item_id, token.eth_block_num, token.eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update
 FROM tx_pool INNER JOIN token ON tx_pool.token_id = token.token_id `
 
 // selectPoolTxsAPI select part of queries to get PoolL2TxRead transactions
@@ -120,9 +302,9 @@ tx_pool.effective_from_bjj, hez_idx(tx_pool.to_idx, token.symbol) AS to_idx, tx_
 tx_pool.effective_to_bjj, tx_pool.token_id, tx_pool.amount, tx_pool.fee, tx_pool.nonce, 
 tx_pool.state, tx_pool.info, tx_pool.signature, tx_pool.timestamp, tx_pool.batch_num, hez_idx(tx_pool.rq_from_idx, token.symbol) AS rq_from_idx, 
 hez_idx(tx_pool.rq_to_idx, token.symbol) AS rq_to_idx, tx_pool.rq_to_eth_addr, tx_pool.rq_to_bjj, tx_pool.rq_token_id, tx_pool.rq_amount, 
-tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, 
-token.item_id AS token_item_id, token.eth_block_num, token.eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update, 
-count(*) OVER() AS total_items 
+tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, tx_pool.max_num_batch,
+token.item_id AS token_item_id, token.eth_block_num, token.eth_addr, token.name, token.symbol, token.decimals, token.usd, token.usd_update,
+count(*) OVER() AS total_items
 FROM tx_pool INNER JOIN token ON tx_pool.token_id = token.token_id `
 
 // GetTxAPI return the specified Tx in PoolTxAPI format
@@ -327,3 +509,169 @@ func (l2db *L2DB) GetPoolTxsAPI(request GetPoolTxsAPIRequest) ([]PoolTxAPI, uint
 	}
 	return txs, txs[0].TotalItems - uint64(len(txs)), tracerr.Wrap(err)
 }
+
+// GetTokenPolicyAPI returns the policy of a token.  Returns sql.ErrNoRows
+// when the token has no policy set.
+func (l2db *L2DB) GetTokenPolicyAPI(tokenID common.TokenID) (TokenPolicy, error) {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.GetTokenPolicy(tokenID)
+}
+
+// GetPendingTxsByFromIdxAPI returns the pending txs of the L2DB sent from the
+// given account, ordered by nonce
+func (l2db *L2DB) GetPendingTxsByFromIdxAPI(idx common.Idx) ([]common.PoolL2Tx, error) {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.GetPendingTxsByFromIdx(idx)
+}
+
+// GetTokenPoliciesAPI returns the policies of all the tokens that have one set
+func (l2db *L2DB) GetTokenPoliciesAPI() ([]TokenPolicyEntry, error) {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.GetTokenPolicies()
+}
+
+// SetTokenPolicyAPI inserts or updates the policy of a token
+func (l2db *L2DB) SetTokenPolicyAPI(tokenID common.TokenID, policy TokenPolicy) error {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.SetTokenPolicy(tokenID, policy)
+}
+
+// ClearTokenPolicyAPI removes the policy of a token
+func (l2db *L2DB) ClearTokenPolicyAPI(tokenID common.TokenID) error {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.ClearTokenPolicy(tokenID)
+}
+
+// poolStatsCacheTTL is the time during which a computed PoolStats is served
+// from cache instead of querying the DB again
+const poolStatsCacheTTL = 30 * time.Second
+
+// GetPoolStatsInternalAPI returns aggregated statistics about the
+// composition of the pool, computed with a single query and cached for
+// poolStatsCacheTTL
+func (l2db *L2DB) GetPoolStatsInternalAPI() (*historydb.PoolStats, error) {
+	l2db.statsMutex.Lock()
+	defer l2db.statsMutex.Unlock()
+	if l2db.statsCache != nil && time.Since(l2db.statsUpdate) < poolStatsCacheTTL {
+		return l2db.statsCache, nil
+	}
+	type poolStatsRow struct {
+		State   common.PoolL2TxState `meddler:"state"`
+		TokenID common.TokenID       `meddler:"token_id"`
+		FeeTier int                  `meddler:"fee_tier"`
+		Count   int64                `meddler:"count"`
+		Oldest  time.Time            `meddler:"oldest,utctime"`
+	}
+	rows := []*poolStatsRow{}
+	if err := meddler.QueryAll(l2db.dbRead, &rows,
+		`SELECT state, token_id, fee/32 AS fee_tier, COUNT(*) AS count,
+		MIN(timestamp) AS oldest
+		FROM tx_pool WHERE NOT external_delete AND state IN ($1, $2, $3)
+		GROUP BY state, token_id, fee/32;`,
+		common.PoolL2TxStatePending,
+		common.PoolL2TxStateForging,
+		common.PoolL2TxStateInvalid,
+	); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	stats := &historydb.PoolStats{
+		PendingByToken:   []historydb.PoolTokenCount{},
+		PendingByFeeTier: []historydb.PoolFeeTierCount{},
+	}
+	tokenCounts := map[common.TokenID]int64{}
+	tierCounts := map[int]int64{}
+	var oldestPending *time.Time
+	for _, row := range rows {
+		switch row.State {
+		case common.PoolL2TxStatePending:
+			stats.PendingCount += row.Count
+			tokenCounts[row.TokenID] += row.Count
+			tierCounts[row.FeeTier] += row.Count
+			oldest := row.Oldest
+			if oldestPending == nil || oldest.Before(*oldestPending) {
+				oldestPending = &oldest
+			}
+		case common.PoolL2TxStateForging:
+			stats.ForgingCount += row.Count
+		case common.PoolL2TxStateInvalid:
+			stats.InvalidCount += row.Count
+		}
+	}
+	if oldestPending != nil {
+		stats.OldestPendingAgeSeconds = time.Since(*oldestPending).Seconds()
+	}
+	for tokenID, count := range tokenCounts {
+		stats.PendingByToken = append(stats.PendingByToken,
+			historydb.PoolTokenCount{TokenID: tokenID, Count: count})
+	}
+	sort.Slice(stats.PendingByToken, func(i, j int) bool {
+		return stats.PendingByToken[i].TokenID < stats.PendingByToken[j].TokenID
+	})
+	for tier, count := range tierCounts {
+		stats.PendingByFeeTier = append(stats.PendingByFeeTier,
+			historydb.PoolFeeTierCount{FeeTier: tier, Count: count})
+	}
+	sort.Slice(stats.PendingByFeeTier, func(i, j int) bool {
+		return stats.PendingByFeeTier[i].FeeTier < stats.PendingByFeeTier[j].FeeTier
+	})
+	l2db.statsCache = stats
+	l2db.statsUpdate = time.Now()
+	return stats, nil
+}
+
+// AddWithdrawalRelayRequestAPI inserts a withdrawal relay authorization into
+// the DB
+func (l2db *L2DB) AddWithdrawalRelayRequestAPI(auth *common.WithdrawalRelayAuth) error {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	return l2db.AddWithdrawalRelayRequest(auth)
+}
+
+// GetWithdrawalRelayRequestAPI returns the withdrawal relay request of an
+// exit from the DB
+func (l2db *L2DB) GetWithdrawalRelayRequestAPI(batchNum common.BatchNum,
+	idx common.Idx) (*WithdrawalRelayRequest, error) {
+	cancel, err := l2db.apiConnCon.Acquire()
+	defer cancel()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer l2db.apiConnCon.Release()
+	request := new(WithdrawalRelayRequest)
+	return request, tracerr.Wrap(meddler.QueryRow(
+		l2db.dbRead, request,
+		selectWithdrawalRelayRequest+
+			`WHERE withdrawal_relay_request.batch_num = $1
+			AND withdrawal_relay_request.account_idx = $2;`,
+		batchNum, idx,
+	))
+}
@@ -0,0 +1,48 @@
+package l2db
+
+import (
+	"github.com/hermeznetwork/tracerr"
+	"github.com/lib/pq"
+	"github.com/russross/meddler"
+)
+
+// dialect holds the SQL behavior that differs between the supported database
+// engines.  The queries shared by both engines are written in the common SQL
+// subset (CAST instead of ::, POWER instead of ^); the statements that have
+// no common form (the audited state transitions, which use UPDATE ... FROM
+// and RETURNING on PostgreSQL) check the Returning capability and fall back
+// to an equivalent transaction (see fallbackqueries.go).  The default engine
+// is PostgreSQL; a SQLite backend is available when the node is built with
+// the `sqlite` build tag (see sqlite.go)
+type dialect struct {
+	// Name of the engine, used in logs and errors
+	Name string
+	// Returning is true when the engine supports UPDATE/DELETE ...
+	// RETURNING and common table expressions over them
+	Returning bool
+	// NowUTC is the SQL expression for the current UTC timestamp
+	NowUTC string
+	// IsDuplicatedKeyErr returns true when err is a unique constraint
+	// violation of the engine's driver
+	IsDuplicatedKeyErr func(err error) bool
+}
+
+// postgresDialect returns the dialect of the default PostgreSQL backend
+func postgresDialect() *dialect {
+	return &dialect{
+		Name:      "postgres",
+		Returning: true,
+		NowUTC:    "timezone('utc', now())",
+		IsDuplicatedKeyErr: func(err error) bool {
+			if pqErr, ok := tracerr.Unwrap(err).(*pq.Error); ok {
+				return pqErr.Code == "23505"
+			}
+			if driverErr, ok := meddler.DriverErr(tracerr.Unwrap(err)); ok {
+				if pqErr, ok := driverErr.(*pq.Error); ok {
+					return pqErr.Code == "23505"
+				}
+			}
+			return false
+		},
+	}
+}
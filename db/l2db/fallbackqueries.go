@@ -0,0 +1,443 @@
+package l2db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/jmoiron/sqlx"
+)
+
+// The audited state transitions use UPDATE ... FROM and RETURNING, which not
+// every engine supports (see dialect.Returning).  This file implements the
+// equivalent fallback transactions: the affected rows are selected first,
+// then recorded in tx_pool_audit, and finally mutated, all within a single
+// transaction so the audit trail can't diverge from the pool.
+
+// auditedRow is a tx_pool row selected by a fallback transaction before
+// mutating it, so its state transition can be recorded in tx_pool_audit
+type auditedRow struct {
+	TxID     common.TxID
+	State    common.PoolL2TxState
+	BatchNum *common.BatchNum
+}
+
+// selectAuditedRows runs a query that must return tx_id, state, batch_num of
+// the tx_pool rows about to be mutated
+func selectAuditedRows(txn *sqlx.Tx, query string, args ...interface{}) ([]auditedRow, error) {
+	rows, err := txn.Query(query, args...)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer db.RowsClose(rows)
+	var selected []auditedRow
+	for rows.Next() {
+		var row auditedRow
+		if err := rows.Scan(&row.TxID, &row.State, &row.BatchNum); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		selected = append(selected, row)
+	}
+	return selected, tracerr.Wrap(rows.Err())
+}
+
+// insertAuditRows records the state transition of the given rows in
+// tx_pool_audit.  newState and batchNum can be nil for deletions
+func insertAuditRows(txn *sqlx.Tx, selected []auditedRow, newState *common.PoolL2TxState,
+	batchNum *common.BatchNum, reason func(auditedRow) string) error {
+	for _, row := range selected {
+		if _, err := txn.Exec(
+			`INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+			VALUES ($1, $2, $3, $4, $5);`,
+			row.TxID, row.State, newState, batchNum, reason(row),
+		); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// txIDsOf returns the TxIDs of the given rows
+func txIDsOf(selected []auditedRow) []common.TxID {
+	txIDs := make([]common.TxID, len(selected))
+	for i, row := range selected {
+		txIDs[i] = row.TxID
+	}
+	return txIDs
+}
+
+// updateStateFallback implements StartForging and DoneForging without
+// RETURNING support
+func (l2db *L2DB) updateStateFallback(oldState, newState common.PoolL2TxState,
+	txIDs []common.TxID, batchNum common.BatchNum, reason string) (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	query, args, err := sqlx.In(
+		"SELECT tx_id, state, batch_num FROM tx_pool WHERE state = ? AND tx_id IN (?);",
+		oldState, txIDs,
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	selected, err := selectAuditedRows(txn, txn.Rebind(query), args...)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return tracerr.Wrap(txn.Commit())
+	}
+	if err := insertAuditRows(txn, selected, &newState, &batchNum,
+		func(auditedRow) string { return reason }); err != nil {
+		return tracerr.Wrap(err)
+	}
+	query, args, err = sqlx.In(
+		"UPDATE tx_pool SET state = ?, batch_num = ? WHERE tx_id IN (?);",
+		newState, batchNum, txIDsOf(selected),
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// invalidateTxsFallback implements InvalidateTxs without RETURNING support
+func (l2db *L2DB) invalidateTxsFallback(txIDs []common.TxID, batchNum common.BatchNum) (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	query, args, err := sqlx.In(
+		"SELECT tx_id, state, batch_num FROM tx_pool WHERE tx_id IN (?);", txIDs)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	selected, err := selectAuditedRows(txn, txn.Rebind(query), args...)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return tracerr.Wrap(txn.Commit())
+	}
+	newState := common.PoolL2TxStateInvalid
+	if err := insertAuditRows(txn, selected, &newState, &batchNum,
+		func(auditedRow) string { return auditReasonInvalidated }); err != nil {
+		return tracerr.Wrap(err)
+	}
+	query, args, err = sqlx.In(
+		"UPDATE tx_pool SET state = ?, batch_num = ? WHERE tx_id IN (?);",
+		newState, batchNum, txIDsOf(selected),
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// invalidateOldNoncesFallback implements InvalidateOldNonces without
+// UPDATE ... FROM and RETURNING support
+func (l2db *L2DB) invalidateOldNoncesFallback(updatedAccounts []common.IdxNonce,
+	batchNum common.BatchNum) (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	newState := common.PoolL2TxStateInvalid
+	for _, acc := range updatedAccounts {
+		selected, err := selectAuditedRows(txn,
+			txn.Rebind(`SELECT tx_id, state, batch_num FROM tx_pool
+			WHERE state = ? AND from_idx = ? AND nonce < ?;`),
+			common.PoolL2TxStatePending, acc.Idx, acc.Nonce,
+		)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		if err := insertAuditRows(txn, selected, &newState, &batchNum,
+			func(auditedRow) string { return invalidateOldNoncesInfo }); err != nil {
+			return tracerr.Wrap(err)
+		}
+		query, args, err := sqlx.In(
+			"UPDATE tx_pool SET state = ?, info = ?, batch_num = ? WHERE tx_id IN (?);",
+			newState, invalidateOldNoncesInfo, batchNum, txIDsOf(selected),
+		)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// invalidateExpiredMaxNumBatchFallback implements InvalidateExpiredMaxNumBatch
+// without RETURNING support
+func (l2db *L2DB) invalidateExpiredMaxNumBatchFallback(currentBatchNum common.BatchNum) (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	selected, err := selectAuditedRows(txn,
+		txn.Rebind(`SELECT tx_id, state, batch_num FROM tx_pool
+		WHERE state = ? AND max_num_batch <= ?;`),
+		common.PoolL2TxStatePending, currentBatchNum,
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return tracerr.Wrap(txn.Commit())
+	}
+	newState := common.PoolL2TxStateInvalid
+	if err := insertAuditRows(txn, selected, &newState, &currentBatchNum,
+		func(auditedRow) string { return auditReasonMaxNumBatch }); err != nil {
+		return tracerr.Wrap(err)
+	}
+	query, args, err := sqlx.In(
+		"UPDATE tx_pool SET state = ?, info = ?, batch_num = ? WHERE tx_id IN (?);",
+		newState, invalidateMaxNumBatchInfo, currentBatchNum, txIDsOf(selected),
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// reorgFallback implements Reorg without RETURNING support
+func (l2db *L2DB) reorgFallback(lastValidBatch common.BatchNum) (restored int64, err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	selected, err := selectAuditedRows(txn,
+		txn.Rebind(`SELECT tx_id, state, batch_num FROM tx_pool
+		WHERE (state = ? OR state = ? OR state = ?) AND batch_num > ?;`),
+		common.PoolL2TxStateForging, common.PoolL2TxStateForged,
+		common.PoolL2TxStateInvalid, lastValidBatch,
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return 0, tracerr.Wrap(txn.Commit())
+	}
+	newState := common.PoolL2TxStatePending
+	if err := insertAuditRows(txn, selected, &newState, nil,
+		func(auditedRow) string { return auditReasonReorg }); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	query, args, err := sqlx.In(
+		`UPDATE tx_pool SET batch_num = NULL, state = ?, info = NULL
+		WHERE tx_id IN (?);`,
+		newState, txIDsOf(selected),
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return int64(len(selected)), tracerr.Wrap(txn.Commit())
+}
+
+// purgeFallback implements Purge without RETURNING support
+func (l2db *L2DB) purgeFallback(currentBatchNum common.BatchNum) (err error) {
+	now := time.Now().UTC().Unix()
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	selected, err := selectAuditedRows(txn,
+		txn.Rebind(`SELECT tx_id, state, batch_num FROM tx_pool WHERE (
+			batch_num < ? AND (state = ? OR state = ?)
+		) OR (
+			state = ? AND timestamp < ?
+		);`),
+		currentBatchNum-l2db.safetyPeriod,
+		common.PoolL2TxStateForged,
+		common.PoolL2TxStateInvalid,
+		common.PoolL2TxStatePending,
+		time.Unix(now-int64(l2db.ttl.Seconds()), 0),
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return tracerr.Wrap(txn.Commit())
+	}
+	for _, row := range selected {
+		reason := auditReasonPurgeSafety
+		if row.State == common.PoolL2TxStatePending {
+			reason = auditReasonPurgeTTL
+		}
+		if _, err := txn.Exec(
+			`INSERT INTO tx_pool_audit (tx_id, old_state, batch_num, reason)
+			VALUES ($1, $2, $3, $4);`,
+			row.TxID, row.State, row.BatchNum, reason,
+		); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	query, args, err := sqlx.In("DELETE FROM tx_pool WHERE tx_id IN (?);", txIDsOf(selected))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// purgeByExternalDeleteFallback implements PurgeByExternalDelete without
+// RETURNING support
+func (l2db *L2DB) purgeByExternalDeleteFallback() (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	selected, err := selectAuditedRows(txn,
+		txn.Rebind(`SELECT tx_id, state, batch_num FROM tx_pool
+		WHERE external_delete = true AND state = ?;`),
+		common.PoolL2TxStatePending,
+	)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return tracerr.Wrap(txn.Commit())
+	}
+	if err := insertAuditRows(txn, selected, nil, nil,
+		func(auditedRow) string { return auditReasonPurgeExternalDel }); err != nil {
+		return tracerr.Wrap(err)
+	}
+	query, args, err := sqlx.In("DELETE FROM tx_pool WHERE tx_id IN (?);", txIDsOf(selected))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(txn.Commit())
+}
+
+// invalidateSingleTxFallback implements invalidateReplacedTx and
+// InvalidateTxByAdminAPI without RETURNING support.  It moves a single
+// pending tx to invalid and returns the number of affected rows
+func (l2db *L2DB) invalidateSingleTxFallback(txID common.TxID, info string,
+	externalDelete bool, reason string) (affected int64, err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	selected, err := selectAuditedRows(txn,
+		txn.Rebind("SELECT tx_id, state, batch_num FROM tx_pool WHERE tx_id = ? AND state = ?;"),
+		txID, common.PoolL2TxStatePending,
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return 0, tracerr.Wrap(txn.Commit())
+	}
+	newState := common.PoolL2TxStateInvalid
+	if err := insertAuditRows(txn, selected, &newState, nil,
+		func(auditedRow) string { return reason }); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if info != "" {
+		_, err = txn.Exec(
+			txn.Rebind("UPDATE tx_pool SET state = ?, info = ?, external_delete = ? WHERE tx_id = ?;"),
+			newState, info, externalDelete, txID,
+		)
+	} else {
+		_, err = txn.Exec(
+			txn.Rebind("UPDATE tx_pool SET state = ?, external_delete = ? WHERE tx_id = ?;"),
+			newState, externalDelete, txID,
+		)
+	}
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return int64(len(selected)), tracerr.Wrap(txn.Commit())
+}
+
+// updateTxsInfoFallback implements UpdateTxsInfo without UPDATE ... FROM
+// support, updating the txs one by one
+func (l2db *L2DB) updateTxsInfoFallback(txs []common.PoolL2Tx, batchNum common.BatchNum) (err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	batchN := strconv.FormatInt(int64(batchNum), 10)
+	for i := range txs {
+		info := fmt.Sprintf("BatchNum: %s. %s", batchN, txs[i].Info)
+		if _, err := txn.Exec(
+			txn.Rebind("UPDATE tx_pool SET info = ? WHERE tx_id = ?;"),
+			info, txs[i].TxID,
+		); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return tracerr.Wrap(txn.Commit())
+}
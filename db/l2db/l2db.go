@@ -21,16 +21,16 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/jmoiron/sqlx"
 
-	//nolint:errcheck // driver for postgres DB
-	_ "github.com/lib/pq"
 	"github.com/russross/meddler"
 )
 
@@ -41,12 +41,24 @@ import (
 type L2DB struct {
 	dbRead       *sqlx.DB
 	dbWrite      *sqlx.DB
+	dialect      *dialect
 	safetyPeriod common.BatchNum
 	ttl          time.Duration
 	maxTxs       uint32 // limit of txs that are accepted in the pool
 	minFeeUSD    float64
 	maxFeeUSD    float64
-	apiConnCon   *db.APIConnectionController
+	// tokenMinFee maps tokens to the minimum fee selector that their txs
+	// must use in order to be accepted into the pool
+	tokenMinFee map[common.TokenID]common.FeeSelector
+	// freeTxsPerAccountPerDay is the number of txs per sender account and
+	// day that are accepted into the pool below the minimum fee.  0
+	// disables the allowance
+	freeTxsPerAccountPerDay uint32
+	apiConnCon              *db.APIConnectionController
+	// Pool stats cache (see GetPoolStatsInternalAPI)
+	statsMutex  sync.Mutex
+	statsCache  *historydb.PoolStats
+	statsUpdate time.Time
 }
 
 // NewL2DB creates a L2DB.
@@ -64,6 +76,7 @@ func NewL2DB(
 	return &L2DB{
 		dbRead:       dbRead,
 		dbWrite:      dbWrite,
+		dialect:      postgresDialect(),
 		safetyPeriod: safetyPeriod,
 		ttl:          TTL,
 		maxTxs:       maxTxs,
@@ -85,6 +98,32 @@ func (l2db *L2DB) MinFeeUSD() float64 {
 	return l2db.minFeeUSD
 }
 
+// FeePolicy groups the optional minimum fee rules that AddTxAPI applies on
+// top of the absolute USD limits given to NewL2DB
+type FeePolicy struct {
+	// TokenMinFee maps tokens to the minimum fee selector that their txs
+	// must use in order to be accepted into the pool
+	TokenMinFee map[common.TokenID]common.FeeSelector
+	// FreeTxsPerAccountPerDay is the number of txs per sender account and
+	// day that are accepted into the pool below the minimum fee.  0
+	// disables the allowance
+	FreeTxsPerAccountPerDay uint32
+}
+
+// SetFeePolicy sets the minimum fee rules applied by AddTxAPI.  Until this
+// method is called, only the absolute USD limits are enforced
+func (l2db *L2DB) SetFeePolicy(policy FeePolicy) {
+	l2db.tokenMinFee = policy.TokenMinFee
+	l2db.freeTxsPerAccountPerDay = policy.FreeTxsPerAccountPerDay
+}
+
+// TokenMinFee returns the minimum fee selector that the txs of a token must
+// use in order to be accepted into the pool, 0 when the token has no
+// specific minimum (see SetFeePolicy)
+func (l2db *L2DB) TokenMinFee(tokenID common.TokenID) common.FeeSelector {
+	return l2db.tokenMinFee[tokenID]
+}
+
 // AddAccountCreationAuth inserts an account creation authorization into the DB
 func (l2db *L2DB) AddAccountCreationAuth(auth *common.AccountCreationAuth) error {
 	_, err := l2db.dbWrite.Exec(
@@ -120,6 +159,9 @@ func (l2db *L2DB) UpdateTxsInfo(txs []common.PoolL2Tx, batchNum common.BatchNum)
 	if len(txs) == 0 {
 		return nil
 	}
+	if !l2db.dialect.Returning {
+		return l2db.updateTxsInfoFallback(txs, batchNum)
+	}
 	type txUpdate struct {
 		ID   common.TxID `db:"id"`
 		Info string      `db:"info"`
@@ -151,16 +193,17 @@ func (l2db *L2DB) UpdateTxsInfo(txs []common.PoolL2Tx, batchNum common.BatchNum)
 func NewPoolL2TxWriteFromPoolL2Tx(tx *common.PoolL2Tx) *PoolL2TxWrite {
 	// transform tx from *common.PoolL2Tx to PoolL2TxWrite
 	insertTx := &PoolL2TxWrite{
-		TxID:      tx.TxID,
-		FromIdx:   tx.FromIdx,
-		TokenID:   tx.TokenID,
-		Amount:    tx.Amount,
-		Fee:       tx.Fee,
-		Nonce:     tx.Nonce,
-		State:     common.PoolL2TxStatePending,
-		Signature: tx.Signature,
-		RqAmount:  tx.RqAmount,
-		Type:      tx.Type,
+		TxID:        tx.TxID,
+		FromIdx:     tx.FromIdx,
+		TokenID:     tx.TokenID,
+		Amount:      tx.Amount,
+		Fee:         tx.Fee,
+		Nonce:       tx.Nonce,
+		State:       common.PoolL2TxStatePending,
+		Signature:   tx.Signature,
+		RqAmount:    tx.RqAmount,
+		Type:        tx.Type,
+		MaxNumBatch: tx.MaxNumBatch,
 	}
 	if tx.ToIdx != 0 {
 		insertTx.ToIdx = &tx.ToIdx
@@ -198,7 +241,55 @@ func NewPoolL2TxWriteFromPoolL2Tx(tx *common.PoolL2Tx) *PoolL2TxWrite {
 func (l2db *L2DB) AddTxTest(tx *common.PoolL2Tx) error {
 	insertTx := NewPoolL2TxWriteFromPoolL2Tx(tx)
 	// insert tx
-	return tracerr.Wrap(meddler.Insert(l2db.dbWrite, "tx_pool", insertTx))
+	if err := meddler.Insert(l2db.dbWrite, "tx_pool", insertTx); err != nil {
+		if l2db.isDuplicatedKeyErr(err) {
+			return l2db.addTxConflict(insertTx)
+		}
+		return tracerr.Wrap(err)
+	}
+	return nil
+}
+
+// selectPoolTxSignedFields selects the signed fields of a tx_pool row, used to
+// compare a resubmitted tx against the stored one
+const selectPoolTxSignedFields = `SELECT tx_pool.tx_id, tx_pool.from_idx, tx_pool.to_idx,
+tx_pool.to_eth_addr, tx_pool.to_bjj, tx_pool.token_id, tx_pool.amount, tx_pool.fee,
+tx_pool.nonce, tx_pool.signature, tx_pool.rq_from_idx, tx_pool.rq_to_idx, tx_pool.rq_to_eth_addr,
+tx_pool.rq_to_bjj, tx_pool.rq_token_id, tx_pool.rq_amount, tx_pool.rq_fee, tx_pool.rq_nonce,
+tx_pool.max_num_batch
+FROM tx_pool `
+
+// addTxConflict resolves an insert that failed because tx.TxID is already in
+// the pool. If the stored tx was signed over the same fields the insert is an
+// idempotent resubmission and ErrDuplicatedTx is returned, otherwise the
+// resubmission carries different content under the same TxID and
+// ErrTxIDConflict is returned
+func (l2db *L2DB) addTxConflict(tx *PoolL2TxWrite) error {
+	row := l2db.dbRead.QueryRow(
+		"SELECT external_delete FROM tx_pool WHERE tx_id = $1;", tx.TxID)
+	var externalDelete bool
+	if err := row.Scan(&externalDelete); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if externalDelete {
+		// The tx was deleted by the operator, don't accept it again
+		return tracerr.Wrap(ErrTxAdminDeleted)
+	}
+	stored := &PoolL2TxWrite{}
+	if err := meddler.QueryRow(l2db.dbRead, stored,
+		selectPoolTxSignedFields+"WHERE tx_pool.tx_id = $1;", tx.TxID); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if tx.SameSignedFields(stored) {
+		return tracerr.Wrap(ErrDuplicatedTx)
+	}
+	return tracerr.Wrap(ErrTxIDConflict)
+}
+
+// isDuplicatedKeyErr returns true when err is a unique violation of the
+// configured engine
+func (l2db *L2DB) isDuplicatedKeyErr(err error) bool {
+	return l2db.dialect.IsDuplicatedKeyErr(err)
 }
 
 // selectPoolTxCommon select part of queries to get common.PoolL2Tx
@@ -206,9 +297,9 @@ const selectPoolTxCommon = `SELECT  tx_pool.tx_id, from_idx, to_idx, tx_pool.to_
 tx_pool.to_bjj, tx_pool.token_id, tx_pool.amount, tx_pool.fee, tx_pool.nonce, 
 tx_pool.state, tx_pool.info, tx_pool.signature, tx_pool.timestamp, rq_from_idx, 
 rq_to_idx, tx_pool.rq_to_eth_addr, tx_pool.rq_to_bjj, tx_pool.rq_token_id, tx_pool.rq_amount, 
-tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, 
-(fee_percentage(tx_pool.fee::NUMERIC) * token.usd * tx_pool.amount_f) /
-	(10.0 ^ token.decimals::NUMERIC) AS fee_usd, token.usd_update
+tx_pool.rq_fee, tx_pool.rq_nonce, tx_pool.tx_type, tx_pool.max_num_batch,
+(fee_percentage(CAST(tx_pool.fee AS NUMERIC)) * token.usd * tx_pool.amount_f) /
+	POWER(10.0, token.decimals) AS fee_usd, token.usd_update
 FROM tx_pool INNER JOIN token ON tx_pool.token_id = token.token_id `
 
 // GetTx  return the specified Tx in common.PoolL2Tx format
@@ -221,31 +312,168 @@ func (l2db *L2DB) GetTx(txID common.TxID) (*common.PoolL2Tx, error) {
 	))
 }
 
-// GetPendingTxs return all the pending txs of the L2DB, that have a non NULL AbsoluteFee
+// GetPendingTxs return all the pending txs of the L2DB, that have a non NULL
+// AbsoluteFee, skipping the txs of tokens with a policy set.  Txs are returned
+// ordered by sender account and nonce, so that queued txs (those with a nonce
+// ahead of the account nonce) are released in order once the gap fills
 func (l2db *L2DB) GetPendingTxs() ([]common.PoolL2Tx, error) {
 	var txs []*common.PoolL2Tx
 	err := meddler.QueryAll(
 		l2db.dbRead, &txs,
-		selectPoolTxCommon+"WHERE state = $1 AND NOT external_delete;",
+		selectPoolTxCommon+`WHERE state = $1 AND NOT external_delete AND
+		NOT EXISTS (SELECT 1 FROM token_policy
+			WHERE token_policy.token_id = tx_pool.token_id)
+		ORDER BY tx_pool.from_idx, tx_pool.nonce;`,
 		common.PoolL2TxStatePending,
 	)
 	return db.SlicePtrsToSlice(txs).([]common.PoolL2Tx), tracerr.Wrap(err)
 }
 
+// GetPendingTxsByFromIdx returns the pending txs of the L2DB sent from the
+// given account, ordered by nonce
+func (l2db *L2DB) GetPendingTxsByFromIdx(idx common.Idx) ([]common.PoolL2Tx, error) {
+	var txs []*common.PoolL2Tx
+	err := meddler.QueryAll(
+		l2db.dbRead, &txs,
+		selectPoolTxCommon+`WHERE state = $1 AND NOT external_delete AND
+		tx_pool.from_idx = $2
+		ORDER BY tx_pool.nonce;`,
+		common.PoolL2TxStatePending, idx,
+	)
+	return db.SlicePtrsToSlice(txs).([]common.PoolL2Tx), tracerr.Wrap(err)
+}
+
+// GetPendingTxsStats returns the number of pending txs of the L2DB and their
+// accumulated fees in USD
+func (l2db *L2DB) GetPendingTxsStats() (int, float64, error) {
+	row := l2db.dbRead.QueryRow(
+		`SELECT COUNT(*),
+		COALESCE(SUM((fee_percentage(CAST(tx_pool.fee AS NUMERIC)) * token.usd * tx_pool.amount_f) /
+			POWER(10.0, token.decimals)), 0)
+		FROM tx_pool INNER JOIN token ON tx_pool.token_id = token.token_id
+		WHERE state = $1 AND NOT external_delete AND
+		NOT EXISTS (SELECT 1 FROM token_policy
+			WHERE token_policy.token_id = tx_pool.token_id);`,
+		common.PoolL2TxStatePending,
+	)
+	var count int
+	var feesUSD float64
+	if err := row.Scan(&count, &feesUSD); err != nil {
+		return 0, 0, tracerr.Wrap(err)
+	}
+	return count, feesUSD, nil
+}
+
+// TokenPolicy restricts the pool acceptance of the txs of a token
+type TokenPolicy string
+
+const (
+	// TokenPolicyDenied rejects new pool txs of the token and skips the
+	// already-pooled ones in the tx selection
+	TokenPolicyDenied TokenPolicy = "denied"
+	// TokenPolicyPaused temporarily rejects new pool txs of the token and
+	// skips the already-pooled ones in the tx selection, until the policy
+	// is cleared
+	TokenPolicyPaused TokenPolicy = "paused"
+)
+
+// TokenPolicyEntry is the policy of a token as stored in the DB
+type TokenPolicyEntry struct {
+	TokenID   common.TokenID `meddler:"token_id" json:"tokenId"`
+	Policy    TokenPolicy    `meddler:"policy" json:"policy"`
+	Timestamp time.Time      `meddler:"timestamp,utctime" json:"timestamp"`
+}
+
+// SetTokenPolicy inserts or updates the policy of a token
+func (l2db *L2DB) SetTokenPolicy(tokenID common.TokenID, policy TokenPolicy) error {
+	_, err := l2db.dbWrite.Exec(
+		fmt.Sprintf(`INSERT INTO token_policy (token_id, policy) VALUES ($1, $2)
+		ON CONFLICT (token_id) DO UPDATE
+		SET policy = $2, timestamp = %s;`, l2db.dialect.NowUTC),
+		tokenID, policy,
+	)
+	return tracerr.Wrap(err)
+}
+
+// ClearTokenPolicy removes the policy of a token, so that its txs are
+// accepted and selected again
+func (l2db *L2DB) ClearTokenPolicy(tokenID common.TokenID) error {
+	_, err := l2db.dbWrite.Exec(
+		"DELETE FROM token_policy WHERE token_id = $1;",
+		tokenID,
+	)
+	return tracerr.Wrap(err)
+}
+
+// GetTokenPolicy returns the policy of a token.  Returns sql.ErrNoRows when
+// the token has no policy set.
+func (l2db *L2DB) GetTokenPolicy(tokenID common.TokenID) (TokenPolicy, error) {
+	row := l2db.dbRead.QueryRow(
+		"SELECT policy FROM token_policy WHERE token_id = $1;",
+		tokenID,
+	)
+	var policy TokenPolicy
+	if err := row.Scan(&policy); err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	return policy, nil
+}
+
+// GetTokenPolicies returns the policies of all the tokens that have one set
+func (l2db *L2DB) GetTokenPolicies() ([]TokenPolicyEntry, error) {
+	var policies []*TokenPolicyEntry
+	err := meddler.QueryAll(
+		l2db.dbRead, &policies,
+		"SELECT * FROM token_policy ORDER BY token_id;",
+	)
+	return db.SlicePtrsToSlice(policies).([]TokenPolicyEntry), tracerr.Wrap(err)
+}
+
+// Reasons recorded in the tx_pool_audit table for each kind of state
+// transition, so that the lifecycle of a tx can be reconstructed without log
+// spelunking
+const (
+	auditReasonStartForging     = "selected to be forged"
+	auditReasonDoneForging      = "forged"
+	auditReasonInvalidated      = "invalidated by the tx selector"
+	auditReasonReorg            = "batch discarded due to a reorg"
+	auditReasonPurgeSafety      = "purge: safety period expired"
+	auditReasonPurgeTTL         = "purge: pending for longer than the TTL"
+	auditReasonPurgeExternalDel = "purge: marked for external deletion"
+	auditReasonReplaced         = "replaced by a tx with a higher fee"
+	auditReasonAdminDelete      = "deleted by the operator"
+	auditReasonMaxNumBatch      = "MaxNumBatch exceeded"
+	auditReasonReconcileForged  = "reconcile: tx found in a synced batch"
+	auditReasonReconcileOrphan  = "reconcile: batch synced without the tx"
+)
+
 // StartForging updates the state of the transactions that will begin the forging process.
 // The state of the txs referenced by txIDs will be changed from Pending -> Forging
 func (l2db *L2DB) StartForging(txIDs []common.TxID, batchNum common.BatchNum) error {
 	if len(txIDs) == 0 {
 		return nil
 	}
+	if !l2db.dialect.Returning {
+		return l2db.updateStateFallback(common.PoolL2TxStatePending,
+			common.PoolL2TxStateForging, txIDs, batchNum, auditReasonStartForging)
+	}
 	query, args, err := sqlx.In(
-		`UPDATE tx_pool
-		SET state = ?, batch_num = ?
-		WHERE state = ? AND tx_id IN (?);`,
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = ?, batch_num = ?
+			WHERE state = ? AND tx_id IN (?)
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+		SELECT tx_id, ?, ?, ?, ? FROM updated;`,
 		common.PoolL2TxStateForging,
 		batchNum,
 		common.PoolL2TxStatePending,
 		txIDs,
+		common.PoolL2TxStatePending,
+		common.PoolL2TxStateForging,
+		batchNum,
+		auditReasonStartForging,
 	)
 	if err != nil {
 		return tracerr.Wrap(err)
@@ -261,14 +489,27 @@ func (l2db *L2DB) DoneForging(txIDs []common.TxID, batchNum common.BatchNum) err
 	if len(txIDs) == 0 {
 		return nil
 	}
+	if !l2db.dialect.Returning {
+		return l2db.updateStateFallback(common.PoolL2TxStateForging,
+			common.PoolL2TxStateForged, txIDs, batchNum, auditReasonDoneForging)
+	}
 	query, args, err := sqlx.In(
-		`UPDATE tx_pool
-		SET state = ?, batch_num = ?
-		WHERE state = ? AND tx_id IN (?);`,
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = ?, batch_num = ?
+			WHERE state = ? AND tx_id IN (?)
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+		SELECT tx_id, ?, ?, ?, ? FROM updated;`,
 		common.PoolL2TxStateForged,
 		batchNum,
 		common.PoolL2TxStateForging,
 		txIDs,
+		common.PoolL2TxStateForging,
+		common.PoolL2TxStateForged,
+		batchNum,
+		auditReasonDoneForging,
 	)
 	if err != nil {
 		return tracerr.Wrap(err)
@@ -284,13 +525,27 @@ func (l2db *L2DB) InvalidateTxs(txIDs []common.TxID, batchNum common.BatchNum) e
 	if len(txIDs) == 0 {
 		return nil
 	}
+	if !l2db.dialect.Returning {
+		return l2db.invalidateTxsFallback(txIDs, batchNum)
+	}
+	// The previous state is captured through a self join because the update
+	// doesn't restrict it
 	query, args, err := sqlx.In(
-		`UPDATE tx_pool
-		SET state = ?, batch_num = ?
-		WHERE tx_id IN (?);`,
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = ?, batch_num = ?
+			FROM (SELECT tx_id, state FROM tx_pool WHERE tx_id IN (?)) AS prev
+			WHERE tx_pool.tx_id = prev.tx_id
+			RETURNING tx_pool.tx_id, prev.state
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+		SELECT tx_id, state, ?, ?, ? FROM updated;`,
 		common.PoolL2TxStateInvalid,
 		batchNum,
 		txIDs,
+		common.PoolL2TxStateInvalid,
+		batchNum,
+		auditReasonInvalidated,
 	)
 	if err != nil {
 		return tracerr.Wrap(err)
@@ -324,18 +579,24 @@ func (l2db *L2DB) GetPendingUniqueFromIdxs() ([]common.Idx, error) {
 const invalidateOldNoncesInfo = `Nonce is smaller than account nonce`
 
 var invalidateOldNoncesQuery = fmt.Sprintf(`
-		UPDATE tx_pool SET
-			state = '%s',
-			info = '%s',
-			batch_num = %%d
-		FROM (VALUES
-			(NULL::::BIGINT, NULL::::BIGINT),
-			(:idx, :nonce)
-		) as updated_acc (idx, nonce)
-		WHERE tx_pool.state = '%s' AND
-			tx_pool.from_idx = updated_acc.idx AND
-			tx_pool.nonce < updated_acc.nonce;
-	`, common.PoolL2TxStateInvalid, invalidateOldNoncesInfo, common.PoolL2TxStatePending)
+		WITH updated AS (
+			UPDATE tx_pool SET
+				state = '%s',
+				info = '%s',
+				batch_num = %%d
+			FROM (VALUES
+				(NULL::::BIGINT, NULL::::BIGINT),
+				(:idx, :nonce)
+			) as updated_acc (idx, nonce)
+			WHERE tx_pool.state = '%s' AND
+				tx_pool.from_idx = updated_acc.idx AND
+				tx_pool.nonce < updated_acc.nonce
+			RETURNING tx_pool.tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+		SELECT tx_id, '%s', '%s', %%d, '%s' FROM updated;
+	`, common.PoolL2TxStateInvalid, invalidateOldNoncesInfo, common.PoolL2TxStatePending,
+	common.PoolL2TxStatePending, common.PoolL2TxStateInvalid, invalidateOldNoncesInfo)
 
 // InvalidateOldNonces invalidate txs with nonces that are smaller or equal than their
 // respective accounts nonces.  The state of the affected txs will be changed
@@ -344,46 +605,107 @@ func (l2db *L2DB) InvalidateOldNonces(updatedAccounts []common.IdxNonce, batchNu
 	if len(updatedAccounts) == 0 {
 		return nil
 	}
+	if !l2db.dialect.Returning {
+		return l2db.invalidateOldNoncesFallback(updatedAccounts, batchNum)
+	}
 	// Fill the batch_num in the query with Sprintf because we are using a
 	// named query which works with slices, and doesn't handle an extra
 	// individual argument.
-	query := fmt.Sprintf(invalidateOldNoncesQuery, batchNum)
+	query := fmt.Sprintf(invalidateOldNoncesQuery, batchNum, batchNum)
 	if _, err := sqlx.NamedExec(l2db.dbWrite, query, updatedAccounts); err != nil {
 		return tracerr.Wrap(err)
 	}
 	return nil
 }
 
-// Reorg updates the state of txs that were updated in a batch that has been discarted due to a blockchain reorg.
-// The state of the affected txs can change form Forged -> Pending or from Invalid -> Pending
-func (l2db *L2DB) Reorg(lastValidBatch common.BatchNum) error {
+const invalidateMaxNumBatchInfo = `MaxNumBatch exceeded`
+
+// InvalidateExpiredMaxNumBatch invalidates pending txs whose MaxNumBatch is
+// smaller than the next batch to be forged, as they can no longer be selected.
+// The state of the affected txs will be changed from Pending to Invalid
+func (l2db *L2DB) InvalidateExpiredMaxNumBatch(currentBatchNum common.BatchNum) error {
+	if !l2db.dialect.Returning {
+		return l2db.invalidateExpiredMaxNumBatchFallback(currentBatchNum)
+	}
 	_, err := l2db.dbWrite.Exec(
-		`UPDATE tx_pool SET batch_num = NULL, state = $1, info = NULL
-		WHERE (state = $2 OR state = $3 OR state = $4) AND batch_num > $5`,
+		`WITH updated AS (
+			UPDATE tx_pool
+			SET state = $1, info = $2, batch_num = $3
+			WHERE state = $4 AND max_num_batch <= $3
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+		SELECT tx_id, $4, $1, $3, $5 FROM updated;`,
+		common.PoolL2TxStateInvalid,
+		invalidateMaxNumBatchInfo,
+		currentBatchNum,
+		common.PoolL2TxStatePending,
+		auditReasonMaxNumBatch,
+	)
+	return tracerr.Wrap(err)
+}
+
+// Reorg updates the state of txs that were updated in a batch that has been discarted due to a blockchain reorg.
+// The state of the affected txs can change form Forged -> Pending or from Invalid -> Pending.
+// Returns the number of txs moved back to pending, so that callers can report
+// the effect of the rollback and trigger a nonce re-check on the restored txs.
+func (l2db *L2DB) Reorg(lastValidBatch common.BatchNum) (int64, error) {
+	if !l2db.dialect.Returning {
+		return l2db.reorgFallback(lastValidBatch)
+	}
+	res, err := l2db.dbWrite.Exec(
+		`WITH updated AS (
+			UPDATE tx_pool SET batch_num = NULL, state = $1, info = NULL
+			FROM (
+				SELECT tx_id, state FROM tx_pool
+				WHERE (state = $2 OR state = $3 OR state = $4) AND batch_num > $5
+			) AS prev
+			WHERE tx_pool.tx_id = prev.tx_id
+			RETURNING tx_pool.tx_id, prev.state
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, new_state, reason)
+		SELECT tx_id, state, $1, $6 FROM updated;`,
 		common.PoolL2TxStatePending,
 		common.PoolL2TxStateForging,
 		common.PoolL2TxStateForged,
 		common.PoolL2TxStateInvalid,
 		lastValidBatch,
+		auditReasonReorg,
 	)
-	return tracerr.Wrap(err)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	restored, err := res.RowsAffected()
+	return restored, tracerr.Wrap(err)
 }
 
 // Purge deletes transactions that have been forged or marked as invalid for longer than the safety period
 // it also deletes pending txs that have been in the L2DB for longer than the ttl if maxTxs has been exceeded
 func (l2db *L2DB) Purge(currentBatchNum common.BatchNum) (err error) {
+	if !l2db.dialect.Returning {
+		return l2db.purgeFallback(currentBatchNum)
+	}
 	now := time.Now().UTC().Unix()
 	_, err = l2db.dbWrite.Exec(
-		`DELETE FROM tx_pool WHERE (
-			batch_num < $1 AND (state = $2 OR state = $3)
-		) OR (
-			state = $4 AND timestamp < $5
-		);`,
+		`WITH deleted AS (
+			DELETE FROM tx_pool WHERE (
+				batch_num < $1 AND (state = $2 OR state = $3)
+			) OR (
+				state = $4 AND timestamp < $5
+			)
+			RETURNING tx_id, state, batch_num
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, batch_num, reason)
+		SELECT tx_id, state, batch_num,
+			CASE WHEN state = $4 THEN CAST($6 AS VARCHAR) ELSE CAST($7 AS VARCHAR) END
+		FROM deleted;`,
 		currentBatchNum-l2db.safetyPeriod,
 		common.PoolL2TxStateForged,
 		common.PoolL2TxStateInvalid,
 		common.PoolL2TxStatePending,
 		time.Unix(now-int64(l2db.ttl.Seconds()), 0),
+		auditReasonPurgeTTL,
+		auditReasonPurgeSafety,
 	)
 	return tracerr.Wrap(err)
 }
@@ -392,9 +714,68 @@ func (l2db *L2DB) Purge(currentBatchNum common.BatchNum) (err error) {
 // the `external_delete` column.  An external process can set this column to
 // true to instruct the coordinator to delete the tx when possible.
 func (l2db *L2DB) PurgeByExternalDelete() error {
+	if !l2db.dialect.Returning {
+		return l2db.purgeByExternalDeleteFallback()
+	}
 	_, err := l2db.dbWrite.Exec(
-		`DELETE from tx_pool WHERE (external_delete = true AND state = $1);`,
+		`WITH deleted AS (
+			DELETE from tx_pool WHERE (external_delete = true AND state = $1)
+			RETURNING tx_id
+		)
+		INSERT INTO tx_pool_audit (tx_id, old_state, reason)
+		SELECT tx_id, $1, $2 FROM deleted;`,
 		common.PoolL2TxStatePending,
+		auditReasonPurgeExternalDel,
 	)
 	return tracerr.Wrap(err)
 }
+
+// GetTxAuditTrail returns the recorded state transitions of a tx in
+// chronological order, including those of txs that have already been purged
+// from the pool
+func (l2db *L2DB) GetTxAuditTrail(txID common.TxID) ([]PoolTxAudit, error) {
+	var audits []*PoolTxAudit
+	err := meddler.QueryAll(
+		l2db.dbRead, &audits,
+		`SELECT item_id, tx_id, old_state, new_state, batch_num, reason, timestamp
+		FROM tx_pool_audit WHERE tx_id = $1 ORDER BY item_id ASC;`,
+		txID,
+	)
+	return db.SlicePtrsToSlice(audits).([]PoolTxAudit), tracerr.Wrap(err)
+}
+
+// WebhookInvalidatedTx is the subset of a pool tx invalidation sent in the
+// pool-tx-invalidated webhook notifications
+type WebhookInvalidatedTx struct {
+	ItemID    uint64      `meddler:"item_id" json:"-"`
+	TxID      common.TxID `meddler:"tx_id" json:"id"`
+	FromIdx   common.Idx  `meddler:"from_idx" json:"fromAccountIndex"`
+	Reason    string      `meddler:"reason" json:"reason"`
+	Timestamp time.Time   `meddler:"timestamp,utctime" json:"timestamp"`
+}
+
+// GetWebhookInvalidatedTxs returns the pool txs invalidated after the audit
+// trail item fromItem, joined with their sender so that webhook filters can
+// be applied.  Used by the webhook notifier
+func (l2db *L2DB) GetWebhookInvalidatedTxs(fromItem uint64) ([]WebhookInvalidatedTx, error) {
+	var txs []*WebhookInvalidatedTx
+	err := meddler.QueryAll(
+		l2db.dbRead, &txs,
+		`SELECT tx_pool_audit.item_id, tx_pool_audit.tx_id, tx_pool.from_idx,
+		tx_pool_audit.reason, tx_pool_audit.timestamp
+		FROM tx_pool_audit INNER JOIN tx_pool ON tx_pool.tx_id = tx_pool_audit.tx_id
+		WHERE tx_pool_audit.item_id > $1 AND tx_pool_audit.new_state = $2
+		ORDER BY tx_pool_audit.item_id ASC;`,
+		fromItem, common.PoolL2TxStateInvalid,
+	)
+	return db.SlicePtrsToSlice(txs).([]WebhookInvalidatedTx), tracerr.Wrap(err)
+}
+
+// GetLastTxAuditItemID returns the highest item_id of the tx_pool_audit
+// table, 0 when the audit trail is empty.  Used by the webhook notifier to
+// initialize its cursor
+func (l2db *L2DB) GetLastTxAuditItemID() (uint64, error) {
+	row := l2db.dbRead.QueryRow("SELECT COALESCE(MAX(item_id), 0) FROM tx_pool_audit;")
+	var itemID uint64
+	return itemID, tracerr.Wrap(row.Scan(&itemID))
+}
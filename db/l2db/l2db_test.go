@@ -1,3 +1,11 @@
+//go:build !sqlite
+// +build !sqlite
+
+// The suite of this file runs against PostgreSQL.  It is excluded from the
+// sqlite build tag because NewSQLite reconfigures meddler process-wide (see
+// its doc comment), so both backends can't be tested in the same process.
+// The SQLite counterpart lives in sqlite_test.go.
+
 package l2db
 
 import (
@@ -31,8 +39,9 @@ var tokens map[common.TokenID]historydb.TokenWithUSD
 var accs map[common.Idx]common.Account
 
 func TestMain(m *testing.M) {
-	// init DB
-	db, err := dbUtils.InitTestSQLDB()
+	// init DB, on an isolated database so the suite can run in parallel
+	// with the ones of other packages
+	db, teardown, err := dbUtils.InitTestSQLDBIsolated("l2db")
 	if err != nil {
 		panic(err)
 	}
@@ -43,8 +52,8 @@ func TestMain(m *testing.M) {
 	historyDB = historydb.NewHistoryDB(db, db, nil)
 	// Run tests
 	result := m.Run()
-	// Close DB
-	if err := db.Close(); err != nil {
+	// Close and drop the test DB
+	if err := teardown(); err != nil {
 		log.Error("Error closing the history DB:", err)
 	}
 	os.Exit(result)
@@ -221,9 +230,27 @@ func TestAddTxAPI(t *testing.T) {
 	l2DBWithACC.minFeeUSD = feeAmountUSD + 1
 	err = l2DBWithACC.AddTxAPI(tx)
 	require.Error(t, err)
-	assert.Regexp(t, "tx.feeUSD (.*) < minFeeUSD (.*)", err.Error())
+	assert.Equal(t, ErrFeeTooLow, tracerr.Unwrap(err))
+	// with a free tx allowance the tx is accepted despite the low fee
+	l2DBWithACC.freeTxsPerAccountPerDay = 100
+	err = l2DBWithACC.AddTxAPI(tx)
+	assert.NoError(t, err)
 	// reset minFeeUSD to original value
 	l2DBWithACC.minFeeUSD = oldMinFeeUSD
+
+	// a per-token minimum fee selector triggers the same rejection, and
+	// since the sender already has txs in the pool the free tx allowance
+	// of 1 is exhausted
+	l2DBWithACC.tokenMinFee = map[common.TokenID]common.FeeSelector{
+		txs[6].TokenID: common.FeeSelector(255),
+	}
+	l2DBWithACC.freeTxsPerAccountPerDay = 1
+	err = l2DBWithACC.AddTxAPI(txs[6])
+	require.Error(t, err)
+	assert.Equal(t, ErrFreeTxAllowanceExceeded, tracerr.Unwrap(err))
+	// reset the fee policy
+	l2DBWithACC.tokenMinFee = nil
+	l2DBWithACC.freeTxsPerAccountPerDay = 0
 }
 
 func TestUpdateTxsInfo(t *testing.T) {
@@ -561,7 +588,7 @@ func TestReorg(t *testing.T) {
 	err = l2DB.DoneForging(doneForgingTxIDs, reorgBatch)
 	require.NoError(t, err)
 
-	err = l2DB.Reorg(lastValidBatch)
+	_, err = l2DB.Reorg(lastValidBatch)
 	require.NoError(t, err)
 	for _, id := range reorgedTxIDs {
 		tx, err := l2DBWithACC.GetTxAPI(id)
@@ -638,7 +665,7 @@ func TestReorg2(t *testing.T) {
 	err = l2DB.InvalidateTxs(invalidTxIDs, reorgBatch)
 	require.NoError(t, err)
 
-	err = l2DB.Reorg(lastValidBatch)
+	_, err = l2DB.Reorg(lastValidBatch)
 	require.NoError(t, err)
 	for _, id := range reorgedTxIDs {
 		tx, err := l2DBWithACC.GetTxAPI(id)
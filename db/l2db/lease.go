@@ -0,0 +1,43 @@
+package l2db
+
+import (
+	"time"
+
+	"github.com/hermeznetwork/tracerr"
+)
+
+// TryAcquireLease tries to acquire or renew the coordinator forging lease on
+// behalf of holder for the given ttl.  The lease is a singleton row: it can
+// only be taken over by another holder once it has expired, so a standby
+// coordinator sharing the database becomes the leader when the primary stops
+// heartbeating.  Returns true when holder owns the lease after the call
+func (l2db *L2DB) TryAcquireLease(holder string, ttl time.Duration) (bool, error) {
+	res, err := l2db.dbWrite.Exec(
+		`INSERT INTO coordinator_lease (id, holder, expires_at)
+		VALUES (true, $1, timezone('utc', now()) + $2 * interval '1 second')
+		ON CONFLICT (id) DO UPDATE
+		SET holder = $1, expires_at = timezone('utc', now()) + $2 * interval '1 second'
+		WHERE coordinator_lease.holder = $1 OR
+			coordinator_lease.expires_at < timezone('utc', now());`,
+		holder, ttl.Seconds(),
+	)
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	return rows > 0, nil
+}
+
+// ReleaseLease releases the coordinator forging lease if holder owns it, so
+// that the standby can take over immediately instead of waiting for the
+// lease to expire
+func (l2db *L2DB) ReleaseLease(holder string) error {
+	_, err := l2db.dbWrite.Exec(
+		"DELETE FROM coordinator_lease WHERE holder = $1;",
+		holder,
+	)
+	return tracerr.Wrap(err)
+}
@@ -0,0 +1,110 @@
+package l2db
+
+import (
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/jmoiron/sqlx"
+)
+
+// ReconcileForgingState cross-checks the pool txs in forging or forged state
+// against the batches persisted by the synchronizer, repairing the rows that
+// a crash between StartForging and DoneForging can leave behind:
+//   - Txs in forging state that appear in a synced batch are moved to forged
+//     (a missed DoneForging).
+//   - Txs in forging or forged state whose batch has been synced without
+//     them are returned to pending so they can be selected again (orphans).
+//
+// Both repairs are recorded in the audit trail.  lastSyncedBatch must be the
+// last batch persisted by the synchronizer: rows assigned to later batches
+// belong to a forge still in flight and are left untouched.  The check joins
+// against the synchronizer tx table, so it's only available when the L2DB
+// shares the database with the HistoryDB
+func (l2db *L2DB) ReconcileForgingState(lastSyncedBatch common.BatchNum) (recoveredForged,
+	orphaned int64, err error) {
+	txn, err := l2db.dbWrite.Beginx()
+	if err != nil {
+		return 0, 0, tracerr.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			db.Rollback(txn)
+		}
+	}()
+	recoveredForged, err = l2db.reconcileMissedForged(txn, lastSyncedBatch)
+	if err != nil {
+		return 0, 0, tracerr.Wrap(err)
+	}
+	orphaned, err = l2db.reconcileOrphans(txn, lastSyncedBatch)
+	if err != nil {
+		return 0, 0, tracerr.Wrap(err)
+	}
+	return recoveredForged, orphaned, tracerr.Wrap(txn.Commit())
+}
+
+// reconcileMissedForged moves the txs in forging state that appear in a
+// synced batch to forged, assigning them the batch they were actually forged
+// in, which can differ from the one they were selected for
+func (l2db *L2DB) reconcileMissedForged(txn *sqlx.Tx,
+	lastSyncedBatch common.BatchNum) (int64, error) {
+	selected, err := selectAuditedRows(txn,
+		`SELECT tx_pool.tx_id, tx_pool.state, tx.batch_num
+		FROM tx_pool INNER JOIN tx ON tx_pool.tx_id = tx.id
+		WHERE tx_pool.state = $1 AND tx.batch_num <= $2;`,
+		common.PoolL2TxStateForging, lastSyncedBatch,
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	newState := common.PoolL2TxStateForged
+	for _, row := range selected {
+		if _, err := txn.Exec(
+			`INSERT INTO tx_pool_audit (tx_id, old_state, new_state, batch_num, reason)
+			VALUES ($1, $2, $3, $4, $5);`,
+			row.TxID, row.State, newState, row.BatchNum, auditReasonReconcileForged,
+		); err != nil {
+			return 0, tracerr.Wrap(err)
+		}
+		if _, err := txn.Exec(
+			`UPDATE tx_pool SET state = $1, batch_num = $2 WHERE tx_id = $3;`,
+			newState, row.BatchNum, row.TxID,
+		); err != nil {
+			return 0, tracerr.Wrap(err)
+		}
+	}
+	return int64(len(selected)), nil
+}
+
+// reconcileOrphans returns the txs in forging or forged state to pending when
+// their batch has been synced without them, so they can be selected again
+func (l2db *L2DB) reconcileOrphans(txn *sqlx.Tx,
+	lastSyncedBatch common.BatchNum) (int64, error) {
+	selected, err := selectAuditedRows(txn,
+		`SELECT tx_id, state, batch_num FROM tx_pool
+		WHERE (state = $1 OR state = $2) AND batch_num <= $3
+		AND NOT EXISTS (SELECT 1 FROM tx WHERE tx.id = tx_pool.tx_id);`,
+		common.PoolL2TxStateForging, common.PoolL2TxStateForged, lastSyncedBatch,
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return 0, nil
+	}
+	newState := common.PoolL2TxStatePending
+	if err := insertAuditRows(txn, selected, &newState, nil,
+		func(auditedRow) string { return auditReasonReconcileOrphan }); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	query, args, err := sqlx.In(
+		"UPDATE tx_pool SET state = ?, batch_num = NULL WHERE tx_id IN (?);",
+		newState, txIDsOf(selected),
+	)
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	if _, err := txn.Exec(txn.Rebind(query), args...); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	return int64(len(selected)), nil
+}
@@ -0,0 +1,224 @@
+//go:build sqlite
+// +build sqlite
+
+package l2db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/jmoiron/sqlx"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/russross/meddler"
+)
+
+// sqliteDriverName is the name under which the SQLite driver extended with
+// the stored functions of the schema (see init) is registered
+const sqliteDriverName = "sqlite3_hermez"
+
+func init() {
+	// The PostgreSQL schema defines the stored functions fee_percentage
+	// and hez_idx, which the pool queries rely on.  SQLite has no stored
+	// functions (nor a POWER built-in), so the equivalents are registered
+	// as Go functions on every connection
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("fee_percentage", sqliteFeePercentage, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("power", sqlitePower, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("hez_idx", sqliteHezIdx, true)
+		},
+	})
+}
+
+// sqliteFloat converts any numeric value passed by SQLite to a float64.
+// SQLite is dynamically typed, so an expression like CAST(fee AS NUMERIC)
+// can yield either an INTEGER or a FLOAT depending on the stored value
+func sqliteFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("expected a numeric argument, got %T", v)
+	}
+}
+
+// sqliteFeePercentage mirrors the fee_percentage stored function of the
+// PostgreSQL schema, which maps a fee selector to its percentage
+func sqliteFeePercentage(fee interface{}) (float64, error) {
+	f, err := sqliteFloat(fee)
+	if err != nil {
+		return 0, err
+	}
+	return common.FeeSelector(uint8(f)).Percentage(), nil
+}
+
+// sqlitePower replaces the POWER built-in, which SQLite lacks
+func sqlitePower(base, exponent interface{}) (float64, error) {
+	b, err := sqliteFloat(base)
+	if err != nil {
+		return 0, err
+	}
+	e, err := sqliteFloat(exponent)
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(b, e), nil
+}
+
+// sqliteHezIdx mirrors the hez_idx stored function of the PostgreSQL schema,
+// which formats an account idx as hez:SYMBOL:idx.  A NULL idx (passed by the
+// driver as a nil []byte) yields NULL, as in the SQL version
+func sqliteHezIdx(idx, symbol interface{}) []byte {
+	if b, ok := idx.([]byte); ok && b == nil {
+		return nil
+	}
+	return []byte(fmt.Sprintf("hez:%v:%v", symbol, idx))
+}
+
+// sqliteDialect returns the dialect of the SQLite backend
+func sqliteDialect() *dialect {
+	return &dialect{
+		Name: "sqlite",
+		// The bundled SQLite predates RETURNING (added in 3.35)
+		Returning: false,
+		NowUTC:    "CURRENT_TIMESTAMP",
+		IsDuplicatedKeyErr: func(err error) bool {
+			if sqliteErr, ok := tracerr.Unwrap(err).(sqlite3.Error); ok {
+				return sqliteErr.Code == sqlite3.ErrConstraint
+			}
+			if driverErr, ok := meddler.DriverErr(tracerr.Unwrap(err)); ok {
+				if sqliteErr, ok := driverErr.(sqlite3.Error); ok {
+					return sqliteErr.Code == sqlite3.ErrConstraint
+				}
+			}
+			return false
+		},
+	}
+}
+
+// sqliteSchema is the subset of the PostgreSQL schema used by the L2DB,
+// translated to SQLite (BYTEA -> BLOB, SERIAL -> AUTOINCREMENT).  The
+// DECIMAL(78,0) amounts become TEXT because they are stored as decimal
+// strings (see BigIntMeddler) and must not go through SQLite numeric
+// affinity, which would round them to 64 bit floats.  The references to the
+// tables of the HistoryDB (which remains PostgreSQL-only) are dropped, so
+// the token rows must be maintained by the caller
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS token (
+    item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_id INTEGER UNIQUE NOT NULL,
+    eth_block_num BIGINT NOT NULL,
+    eth_addr BLOB UNIQUE NOT NULL,
+    name VARCHAR(20) NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    decimals INTEGER NOT NULL,
+    usd REAL,
+    usd_update TIMESTAMP,
+    usd_pinned BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS tx_pool (
+    item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tx_id BLOB UNIQUE NOT NULL,
+    from_idx BIGINT NOT NULL,
+    effective_from_eth_addr BLOB,
+    effective_from_bjj BLOB,
+    to_idx BIGINT,
+    to_eth_addr BLOB,
+    to_bjj BLOB,
+    effective_to_eth_addr BLOB,
+    effective_to_bjj BLOB,
+    token_id INTEGER NOT NULL,
+    amount TEXT NOT NULL,
+    amount_f REAL NOT NULL,
+    fee SMALLINT NOT NULL,
+    nonce BIGINT NOT NULL,
+    state CHAR(4) NOT NULL,
+    info VARCHAR,
+    signature BLOB NOT NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    batch_num BIGINT,
+    rq_from_idx BIGINT,
+    rq_to_idx BIGINT,
+    rq_to_eth_addr BLOB,
+    rq_to_bjj BLOB,
+    rq_token_id INTEGER,
+    rq_amount TEXT,
+    rq_fee SMALLINT,
+    rq_nonce BIGINT,
+    tx_type VARCHAR(40) NOT NULL,
+    client_ip VARCHAR,
+    external_delete BOOLEAN NOT NULL DEFAULT 0,
+    max_num_batch BIGINT DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tx_pool_audit (
+    item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tx_id BLOB NOT NULL,
+    old_state CHAR(4),
+    new_state CHAR(4),
+    batch_num BIGINT,
+    reason VARCHAR NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS tx_pool_audit_tx_id_idx ON tx_pool_audit (tx_id);
+
+CREATE TABLE IF NOT EXISTS token_policy (
+    token_id INTEGER PRIMARY KEY,
+    policy VARCHAR(8) NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS account_creation_auth (
+    eth_addr BLOB PRIMARY KEY,
+    bjj BLOB NOT NULL,
+    signature BLOB NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// NewSQLite creates a L2DB backed by SQLite, for development and lightweight
+// standalone deployments that don't want to run PostgreSQL.  The database is
+// created at path (":memory:" is accepted) with the schema already applied.
+// It takes the same parameters as NewL2DB.
+//
+// Because meddler is configured process-wide, a SQLite L2DB can't be
+// combined with the PostgreSQL-backed databases in the same process
+func NewSQLite(
+	path string,
+	safetyPeriod common.BatchNum,
+	maxTxs uint32,
+	minFeeUSD float64,
+	maxFeeUSD float64,
+	TTL time.Duration,
+	apiConnCon *db.APIConnectionController,
+) (*L2DB, error) {
+	conn, err := sqlx.Open(sqliteDriverName, path)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	// A single connection serializes the writes (SQLite only supports one
+	// writer) and keeps an in-memory database alive, which would otherwise
+	// be dropped and recreated on every connection of the pool
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.Exec(sqliteSchema); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	meddler.Default = meddler.SQLite
+	meddler.Register("bigint", db.BigIntMeddler{})
+	meddler.Register("bigintnull", db.BigIntNullMeddler{})
+	l2db := NewL2DB(conn, conn, safetyPeriod, maxTxs, minFeeUSD, maxFeeUSD, TTL, apiConnCon)
+	l2db.dialect = sqliteDialect()
+	return l2db, nil
+}
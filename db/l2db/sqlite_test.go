@@ -0,0 +1,301 @@
+//go:build sqlite
+// +build sqlite
+
+package l2db
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	dbUtils "github.com/arnaubennassar/hermez-node/db"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The PostgreSQL suite never exercises the fallback state transitions of
+// fallbackqueries.go because dialect.Returning is true there.  This file runs
+// targeted tests of each fallback query against an in-memory SQLite L2DB:
+//
+//	go test -tags sqlite ./db/l2db/
+//
+// Each test creates its own database, so no TestMain nor external services
+// are needed.
+
+func newSQLiteL2DB(t *testing.T) *L2DB {
+	apiConnCon := dbUtils.NewAPIConnectionController(1, time.Second)
+	l2db, err := NewSQLite(":memory:", 10, 1000, 0.0, 1000.0, 24*time.Hour, apiConnCon)
+	require.NoError(t, err)
+	// The token table is not fed by a HistoryDB in the SQLite backend (see
+	// sqliteSchema), so the token of the test txs is inserted directly
+	_, err = l2db.dbWrite.Exec(
+		`INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+		VALUES (1, 1, $1, 'Test Token', 'TST', 3, 1.0, CURRENT_TIMESTAMP);`,
+		ethCommon.HexToAddress("0x1B36A4DED4DF40248C0E0E52CEA5EDC9A298B721"),
+	)
+	require.NoError(t, err)
+	return l2db
+}
+
+// addSQLitePoolTxs inserts n pending transfers into the pool.  Each tx is
+// sent from its own account so the tests can transition them independently
+func addSQLitePoolTxs(t *testing.T, l2db *L2DB, n int) []common.PoolL2Tx {
+	txs := make([]common.PoolL2Tx, n)
+	for i := 0; i < n; i++ {
+		txs[i] = common.PoolL2Tx{
+			FromIdx: common.Idx(256 + i),
+			ToIdx:   256,
+			TokenID: 1,
+			Amount:  big.NewInt(1000),
+			Fee:     126,
+			Nonce:   0,
+			State:   common.PoolL2TxStatePending,
+			Type:    common.TxTypeTransfer,
+		}
+		require.NoError(t, txs[i].SetID())
+		require.NoError(t, l2db.AddTxTest(&txs[i]))
+	}
+	return txs
+}
+
+// assertSQLiteTxState checks the state and batch_num of a pool tx
+func assertSQLiteTxState(t *testing.T, l2db *L2DB, txID common.TxID,
+	state common.PoolL2TxState, batchNum *common.BatchNum) {
+	row := l2db.dbRead.QueryRow(
+		"SELECT state, batch_num FROM tx_pool WHERE tx_id = $1;", txID)
+	var gotState common.PoolL2TxState
+	var gotBatchNum *common.BatchNum
+	require.NoError(t, row.Scan(&gotState, &gotBatchNum))
+	assert.Equal(t, state, gotState)
+	assert.Equal(t, batchNum, gotBatchNum)
+}
+
+// lastAuditEntry returns the most recent audit trail entry of a tx
+func lastAuditEntry(t *testing.T, l2db *L2DB, txID common.TxID) PoolTxAudit {
+	audits, err := l2db.GetTxAuditTrail(txID)
+	require.NoError(t, err)
+	require.Greater(t, len(audits), 0)
+	return audits[len(audits)-1]
+}
+
+func TestSQLiteAddGet(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	txs := addSQLitePoolTxs(t, l2db, 2)
+	for i := range txs {
+		fetched, err := l2db.GetTx(txs[i].TxID)
+		require.NoError(t, err)
+		assert.Equal(t, txs[i].TxID, fetched.TxID)
+		assert.Equal(t, txs[i].FromIdx, fetched.FromIdx)
+		assert.Equal(t, 0, txs[i].Amount.Cmp(fetched.Amount))
+		assert.Equal(t, common.PoolL2TxStatePending, fetched.State)
+		// fee_percentage and POWER are registered Go functions in the
+		// SQLite backend: an amount of 1000 with 3 decimals and a token
+		// price of 1.0 USD is worth 1 USD
+		assert.InEpsilon(t, txs[i].Fee.Percentage(), fetched.AbsoluteFee, 0.001)
+	}
+	// a resubmission of the same signed tx hits the duplicated key
+	// detection of the dialect
+	err := l2db.AddTxTest(&txs[0])
+	assert.Equal(t, ErrDuplicatedTx, tracerr.Unwrap(err))
+}
+
+func TestSQLiteStartDoneForging(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	var batchNum common.BatchNum = 33
+	txs := addSQLitePoolTxs(t, l2db, 3)
+	forgeIDs := []common.TxID{txs[0].TxID, txs[1].TxID}
+
+	require.NoError(t, l2db.StartForging(forgeIDs, batchNum))
+	for _, id := range forgeIDs {
+		assertSQLiteTxState(t, l2db, id, common.PoolL2TxStateForging, &batchNum)
+		audit := lastAuditEntry(t, l2db, id)
+		assert.Equal(t, common.PoolL2TxStatePending, *audit.OldState)
+		assert.Equal(t, common.PoolL2TxStateForging, *audit.NewState)
+		assert.Equal(t, auditReasonStartForging, audit.Reason)
+	}
+	// the non-selected tx is untouched
+	assertSQLiteTxState(t, l2db, txs[2].TxID, common.PoolL2TxStatePending, nil)
+
+	require.NoError(t, l2db.DoneForging(forgeIDs, batchNum))
+	for _, id := range forgeIDs {
+		assertSQLiteTxState(t, l2db, id, common.PoolL2TxStateForged, &batchNum)
+		assert.Equal(t, auditReasonDoneForging, lastAuditEntry(t, l2db, id).Reason)
+	}
+	// DoneForging on a tx that is not forging is a no-op
+	require.NoError(t, l2db.DoneForging([]common.TxID{txs[2].TxID}, batchNum))
+	assertSQLiteTxState(t, l2db, txs[2].TxID, common.PoolL2TxStatePending, nil)
+}
+
+func TestSQLiteInvalidateTxs(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	var batchNum common.BatchNum = 33
+	txs := addSQLitePoolTxs(t, l2db, 2)
+
+	require.NoError(t, l2db.InvalidateTxs([]common.TxID{txs[0].TxID}, batchNum))
+	assertSQLiteTxState(t, l2db, txs[0].TxID, common.PoolL2TxStateInvalid, &batchNum)
+	assert.Equal(t, auditReasonInvalidated, lastAuditEntry(t, l2db, txs[0].TxID).Reason)
+	assertSQLiteTxState(t, l2db, txs[1].TxID, common.PoolL2TxStatePending, nil)
+}
+
+func TestSQLiteInvalidateOldNonces(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	var batchNum common.BatchNum = 33
+	// two txs of the same account with consecutive nonces
+	txs := make([]common.PoolL2Tx, 2)
+	for i := range txs {
+		txs[i] = common.PoolL2Tx{
+			FromIdx: 256,
+			ToIdx:   257,
+			TokenID: 1,
+			Amount:  big.NewInt(1000),
+			Fee:     126,
+			Nonce:   common.Nonce(i),
+			State:   common.PoolL2TxStatePending,
+			Type:    common.TxTypeTransfer,
+		}
+		require.NoError(t, txs[i].SetID())
+		require.NoError(t, l2db.AddTxTest(&txs[i]))
+	}
+
+	require.NoError(t, l2db.InvalidateOldNonces(
+		[]common.IdxNonce{{Idx: 256, Nonce: 1}}, batchNum))
+	// the tx with nonce 0 is invalidated, the one with nonce 1 remains
+	assertSQLiteTxState(t, l2db, txs[0].TxID, common.PoolL2TxStateInvalid, &batchNum)
+	var info string
+	row := l2db.dbRead.QueryRow("SELECT info FROM tx_pool WHERE tx_id = $1;", txs[0].TxID)
+	require.NoError(t, row.Scan(&info))
+	assert.Equal(t, invalidateOldNoncesInfo, info)
+	assertSQLiteTxState(t, l2db, txs[1].TxID, common.PoolL2TxStatePending, nil)
+}
+
+func TestSQLiteInvalidateExpiredMaxNumBatch(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	const currentBatchNum common.BatchNum = 5
+	txs := addSQLitePoolTxs(t, l2db, 2)
+	_, err := l2db.dbWrite.Exec(
+		"UPDATE tx_pool SET max_num_batch = $1 WHERE tx_id = $2;",
+		currentBatchNum, txs[0].TxID)
+	require.NoError(t, err)
+
+	require.NoError(t, l2db.InvalidateExpiredMaxNumBatch(currentBatchNum))
+	expiredBatchNum := currentBatchNum
+	assertSQLiteTxState(t, l2db, txs[0].TxID, common.PoolL2TxStateInvalid, &expiredBatchNum)
+	assert.Equal(t, auditReasonMaxNumBatch, lastAuditEntry(t, l2db, txs[0].TxID).Reason)
+	// the tx without MaxNumBatch is untouched
+	assertSQLiteTxState(t, l2db, txs[1].TxID, common.PoolL2TxStatePending, nil)
+}
+
+func TestSQLiteReorg(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	const lastValidBatch common.BatchNum = 20
+	const reorgBatch common.BatchNum = lastValidBatch + 1
+	txs := addSQLitePoolTxs(t, l2db, 3)
+
+	// tx 0 is forged in the reorged batch, tx 1 is forged in a valid batch
+	// and tx 2 is invalidated in the reorged batch
+	require.NoError(t, l2db.StartForging(
+		[]common.TxID{txs[0].TxID, txs[1].TxID}, lastValidBatch))
+	require.NoError(t, l2db.DoneForging([]common.TxID{txs[0].TxID}, reorgBatch))
+	require.NoError(t, l2db.DoneForging([]common.TxID{txs[1].TxID}, lastValidBatch))
+	require.NoError(t, l2db.InvalidateTxs([]common.TxID{txs[2].TxID}, reorgBatch))
+
+	restored, err := l2db.Reorg(lastValidBatch)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), restored)
+	for _, id := range []common.TxID{txs[0].TxID, txs[2].TxID} {
+		assertSQLiteTxState(t, l2db, id, common.PoolL2TxStatePending, nil)
+		assert.Equal(t, auditReasonReorg, lastAuditEntry(t, l2db, id).Reason)
+	}
+	forgedBatchNum := lastValidBatch
+	assertSQLiteTxState(t, l2db, txs[1].TxID, common.PoolL2TxStateForged, &forgedBatchNum)
+}
+
+func TestSQLitePurge(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	const forgedBatchNum common.BatchNum = 30
+	safeBatchNum := forgedBatchNum + l2db.safetyPeriod + 1
+	txs := addSQLitePoolTxs(t, l2db, 3)
+
+	// tx 0 is forged before the safety period, tx 1 is pending for longer
+	// than the TTL and tx 2 is kept
+	require.NoError(t, l2db.StartForging([]common.TxID{txs[0].TxID}, forgedBatchNum))
+	require.NoError(t, l2db.DoneForging([]common.TxID{txs[0].TxID}, forgedBatchNum))
+	expiredTimestamp := time.Unix(
+		time.Now().UTC().Unix()-int64(l2db.ttl.Seconds())-4, 0)
+	_, err := l2db.dbWrite.Exec(
+		"UPDATE tx_pool SET timestamp = $1 WHERE tx_id = $2;",
+		expiredTimestamp, txs[1].TxID)
+	require.NoError(t, err)
+
+	require.NoError(t, l2db.Purge(safeBatchNum))
+	for i, reason := range map[int]string{0: auditReasonPurgeSafety, 1: auditReasonPurgeTTL} {
+		_, err := l2db.GetTx(txs[i].TxID)
+		assert.Equal(t, sql.ErrNoRows, tracerr.Unwrap(err))
+		// the audit trail survives the deletion and records the reason
+		audit := lastAuditEntry(t, l2db, txs[i].TxID)
+		assert.Nil(t, audit.NewState)
+		assert.Equal(t, reason, audit.Reason)
+	}
+	_, err = l2db.GetTx(txs[2].TxID)
+	require.NoError(t, err)
+}
+
+func TestSQLitePurgeByExternalDelete(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	txs := addSQLitePoolTxs(t, l2db, 3)
+	// tx 0 is marked for external deletion, tx 1 too but it is forging so
+	// it must be kept, tx 2 is not marked
+	require.NoError(t, l2db.StartForging([]common.TxID{txs[1].TxID}, 1))
+	_, err := l2db.dbWrite.Exec(
+		"UPDATE tx_pool SET external_delete = true WHERE tx_id IN ($1, $2);",
+		txs[0].TxID, txs[1].TxID)
+	require.NoError(t, err)
+
+	require.NoError(t, l2db.PurgeByExternalDelete())
+	_, err = l2db.GetTx(txs[0].TxID)
+	assert.Equal(t, sql.ErrNoRows, tracerr.Unwrap(err))
+	assert.Equal(t, auditReasonPurgeExternalDel,
+		lastAuditEntry(t, l2db, txs[0].TxID).Reason)
+	for _, i := range []int{1, 2} {
+		_, err := l2db.GetTx(txs[i].TxID)
+		require.NoError(t, err)
+	}
+}
+
+func TestSQLiteInvalidateTxByAdminAPI(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	txs := addSQLitePoolTxs(t, l2db, 2)
+
+	require.NoError(t, l2db.InvalidateTxByAdminAPI(txs[0].TxID, "spam"))
+	assertSQLiteTxState(t, l2db, txs[0].TxID, common.PoolL2TxStateInvalid, nil)
+	audit := lastAuditEntry(t, l2db, txs[0].TxID)
+	assert.Equal(t, fmt.Sprintf("%s: spam", auditReasonAdminDelete), audit.Reason)
+	// the tx is marked with external_delete so it can't be re-admitted
+	var externalDelete bool
+	row := l2db.dbRead.QueryRow(
+		"SELECT external_delete FROM tx_pool WHERE tx_id = $1;", txs[0].TxID)
+	require.NoError(t, row.Scan(&externalDelete))
+	assert.True(t, externalDelete)
+	// invalidating a tx that is not pending reports it as not found
+	err := l2db.InvalidateTxByAdminAPI(txs[0].TxID, "")
+	assert.Equal(t, ErrTxNotPending, tracerr.Unwrap(err))
+}
+
+func TestSQLiteUpdateTxsInfo(t *testing.T) {
+	l2db := newSQLiteL2DB(t)
+	txs := addSQLitePoolTxs(t, l2db, 2)
+	for i := range txs {
+		txs[i].Info = "test"
+	}
+	require.NoError(t, l2db.UpdateTxsInfo(txs, 0))
+	for i := range txs {
+		fetched, err := l2db.GetTx(txs[i].TxID)
+		require.NoError(t, err)
+		assert.Equal(t, "BatchNum: 0. test", fetched.Info)
+	}
+}
@@ -34,9 +34,87 @@ type PoolL2TxWrite struct {
 	RqFee       *common.FeeSelector    `meddler:"rq_fee"`
 	RqNonce     *common.Nonce          `meddler:"rq_nonce"`
 	Type        common.TxType          `meddler:"tx_type"`
+	MaxNumBatch uint32                 `meddler:"max_num_batch,zeroisnull"`
 	ClientIP    string                 `meddler:"client_ip"`
 }
 
+// SameSignedFields returns true if both txs have the same value on every field
+// covered by the tx signature.  Fields set by the node rather than by the
+// signer (state, client IP, ...) are not compared, so a resubmission of the
+// same signed tx matches the stored one even if they differ
+func (tx *PoolL2TxWrite) SameSignedFields(other *PoolL2TxWrite) bool {
+	if tx.FromIdx != other.FromIdx ||
+		tx.TokenID != other.TokenID ||
+		tx.Fee != other.Fee ||
+		tx.Nonce != other.Nonce ||
+		tx.MaxNumBatch != other.MaxNumBatch ||
+		tx.Signature != other.Signature {
+		return false
+	}
+	if !eqBigIntPtr(tx.Amount, other.Amount) || !eqBigIntPtr(tx.RqAmount, other.RqAmount) {
+		return false
+	}
+	return eqIdxPtr(tx.ToIdx, other.ToIdx) &&
+		eqAddrPtr(tx.ToEthAddr, other.ToEthAddr) &&
+		eqBJJPtr(tx.ToBJJ, other.ToBJJ) &&
+		eqIdxPtr(tx.RqFromIdx, other.RqFromIdx) &&
+		eqIdxPtr(tx.RqToIdx, other.RqToIdx) &&
+		eqAddrPtr(tx.RqToEthAddr, other.RqToEthAddr) &&
+		eqBJJPtr(tx.RqToBJJ, other.RqToBJJ) &&
+		eqTokenIDPtr(tx.RqTokenID, other.RqTokenID) &&
+		eqFeePtr(tx.RqFee, other.RqFee) &&
+		eqNoncePtr(tx.RqNonce, other.RqNonce)
+}
+
+func eqBigIntPtr(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
+
+func eqIdxPtr(a, b *common.Idx) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func eqAddrPtr(a, b *ethCommon.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func eqBJJPtr(a, b *babyjub.PublicKeyComp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func eqTokenIDPtr(a, b *common.TokenID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func eqFeePtr(a, b *common.FeeSelector) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func eqNoncePtr(a, b *common.Nonce) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // PoolTxAPI represents a L2 Tx pool with extra metadata used by the API
 type PoolTxAPI struct {
 	ItemID               uint64                `meddler:"item_id"`
@@ -62,6 +140,7 @@ type PoolTxAPI struct {
 	RqFee                *common.FeeSelector   `meddler:"rq_fee"`
 	RqNonce              *common.Nonce         `meddler:"rq_nonce"`
 	Type                 common.TxType         `meddler:"tx_type"`
+	MaxNumBatch          uint32                `meddler:"max_num_batch,zeroisnull"`
 	// Extra read fileds
 	BatchNum         *common.BatchNum  `meddler:"batch_num"`
 	Timestamp        time.Time         `meddler:"timestamp,utctime"`
@@ -105,6 +184,7 @@ func (tx PoolTxAPI) MarshalJSON() ([]byte, error) {
 		"requestAmount":               tx.RqAmount,
 		"requestFee":                  tx.RqFee,
 		"requestNonce":                tx.RqNonce,
+		"maxNumBatch":                 tx.MaxNumBatch,
 		"token": map[string]interface{}{
 			"id":               tx.TokenID,
 			"itemId":           tx.TokenItemID,
@@ -126,3 +206,16 @@ type AccountCreationAuthAPI struct {
 	Signature apitypes.EthSignature `json:"signature"          meddler:"signature" `
 	Timestamp time.Time             `json:"timestamp"          meddler:"timestamp,utctime"`
 }
+
+// PoolTxAudit represents a row of the tx_pool_audit table, which records one
+// state transition of a pool tx.  OldState and NewState are nil when the tx
+// was inserted or deleted respectively
+type PoolTxAudit struct {
+	ItemID    uint64                `json:"itemId"    meddler:"item_id"`
+	TxID      common.TxID           `json:"id"        meddler:"tx_id"`
+	OldState  *common.PoolL2TxState `json:"oldState"  meddler:"old_state"`
+	NewState  *common.PoolL2TxState `json:"newState"  meddler:"new_state"`
+	BatchNum  *common.BatchNum      `json:"batchNum"  meddler:"batch_num"`
+	Reason    string                `json:"reason"    meddler:"reason"`
+	Timestamp time.Time             `json:"timestamp" meddler:"timestamp,utctime"`
+}
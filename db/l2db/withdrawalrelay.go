@@ -0,0 +1,121 @@
+package l2db
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/api/apitypes"
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/russross/meddler"
+)
+
+// Status values of a withdrawal relay request (see
+// coordinator/withdrawrelayer.go for the lifecycle)
+const (
+	// WithdrawalRelayStatusPending is the status of a request that hasn't
+	// been processed by the relayer yet
+	WithdrawalRelayStatusPending = "pending"
+	// WithdrawalRelayStatusSent is the status of a request whose
+	// withdrawMerkleProof ethereum transaction has been sent but not mined
+	WithdrawalRelayStatusSent = "sent"
+	// WithdrawalRelayStatusConfirmed is the status of a request whose
+	// withdrawMerkleProof ethereum transaction has been mined successfully
+	WithdrawalRelayStatusConfirmed = "confirmed"
+	// WithdrawalRelayStatusFailed is the status of a request whose
+	// withdrawMerkleProof ethereum transaction reverted
+	WithdrawalRelayStatusFailed = "failed"
+	// WithdrawalRelayStatusRejected is the status of a request that the
+	// relayer refused to submit (token not relayed, over the per token
+	// limit, fee under the minimum or exit already withdrawn)
+	WithdrawalRelayStatusRejected = "rejected"
+)
+
+// WithdrawalRelayRequest is a row of the withdrawal_relay_request table: an
+// authorization from the owner of an exit to submit its withdrawMerkleProof
+// on their behalf, along with the L1 submission tracking of the relayer
+type WithdrawalRelayRequest struct {
+	ItemID     uint64          `meddler:"item_id"`
+	BatchNum   common.BatchNum `meddler:"batch_num"`
+	AccountIdx common.Idx      `meddler:"account_idx"`
+	// AccountIdxHez is the hez:SYM:idx representation of AccountIdx,
+	// used by the API responses
+	AccountIdxHez apitypes.HezIdx   `meddler:"account_idx_hez"`
+	EthAddr       ethCommon.Address `meddler:"owner_addr"`
+	Fee           *big.Int          `meddler:"fee,bigint"`
+	Signature     []byte            `meddler:"signature"`
+	Status        string            `meddler:"status"`
+	// EthTxHash is the hash of the withdrawMerkleProof ethereum
+	// transaction, zero until the request reaches the sent status
+	EthTxHash ethCommon.Hash `meddler:"eth_tx_hash"`
+	// Info contains the reason of the rejected and failed statuses
+	Info      string    `meddler:"info"`
+	Timestamp time.Time `meddler:"timestamp,utctime"`
+}
+
+const selectWithdrawalRelayRequest = `SELECT withdrawal_relay_request.item_id,
+	withdrawal_relay_request.batch_num, withdrawal_relay_request.account_idx,
+	hez_idx(withdrawal_relay_request.account_idx, token.symbol) AS account_idx_hez,
+	withdrawal_relay_request.owner_addr, withdrawal_relay_request.fee,
+	withdrawal_relay_request.signature, withdrawal_relay_request.status,
+	COALESCE(withdrawal_relay_request.eth_tx_hash, DECODE('0000000000000000000000000000000000000000000000000000000000000000', 'hex')) AS eth_tx_hash,
+	COALESCE(withdrawal_relay_request.info, '') AS info,
+	withdrawal_relay_request.timestamp
+	FROM withdrawal_relay_request
+	INNER JOIN account ON withdrawal_relay_request.account_idx = account.idx
+	INNER JOIN token ON account.token_id = token.token_id `
+
+// AddWithdrawalRelayRequest inserts a withdrawal relay authorization into the
+// DB with the pending status
+func (l2db *L2DB) AddWithdrawalRelayRequest(auth *common.WithdrawalRelayAuth) error {
+	_, err := l2db.dbWrite.Exec(
+		`INSERT INTO withdrawal_relay_request
+		(batch_num, account_idx, owner_addr, fee, signature)
+		VALUES ($1, $2, $3, $4, $5);`,
+		auth.BatchNum, auth.AccountIdx, auth.EthAddr, auth.Fee.String(), auth.Signature,
+	)
+	return tracerr.Wrap(err)
+}
+
+// GetWithdrawalRelayRequestsByStatus returns the withdrawal relay requests
+// that are in the given status, oldest first.  Used by the relayer to pick
+// the requests to submit and the sent transactions to check
+func (l2db *L2DB) GetWithdrawalRelayRequestsByStatus(status string) (
+	[]WithdrawalRelayRequest, error) {
+	var requests []*WithdrawalRelayRequest
+	err := meddler.QueryAll(
+		l2db.dbRead, &requests,
+		selectWithdrawalRelayRequest+
+			`WHERE withdrawal_relay_request.status = $1
+			ORDER BY withdrawal_relay_request.item_id ASC;`,
+		status,
+	)
+	return db.SlicePtrsToSlice(requests).([]WithdrawalRelayRequest), tracerr.Wrap(err)
+}
+
+// UpdateWithdrawalRelayRequestSent moves a withdrawal relay request to the
+// sent status, recording the hash of the withdrawMerkleProof ethereum
+// transaction
+func (l2db *L2DB) UpdateWithdrawalRelayRequestSent(itemID uint64,
+	ethTxHash ethCommon.Hash) error {
+	_, err := l2db.dbWrite.Exec(
+		`UPDATE withdrawal_relay_request SET status = $1, eth_tx_hash = $2
+		WHERE item_id = $3;`,
+		WithdrawalRelayStatusSent, ethTxHash, itemID,
+	)
+	return tracerr.Wrap(err)
+}
+
+// UpdateWithdrawalRelayRequestStatus moves a withdrawal relay request to the
+// given status, recording the reason in the info column
+func (l2db *L2DB) UpdateWithdrawalRelayRequestStatus(itemID uint64,
+	status, info string) error {
+	_, err := l2db.dbWrite.Exec(
+		`UPDATE withdrawal_relay_request SET status = $1, info = $2
+		WHERE item_id = $3;`,
+		status, info, itemID,
+	)
+	return tracerr.Wrap(err)
+}
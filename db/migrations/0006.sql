@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE batch ADD COLUMN gas_used BIGINT;
+ALTER TABLE batch ADD COLUMN gas_price DECIMAL(78,0);
+
+-- +migrate Down
+ALTER TABLE batch DROP COLUMN gas_used;
+ALTER TABLE batch DROP COLUMN gas_price;
@@ -0,0 +1,89 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration adds the columns `gas_used` and `gas_price` on `batch`
+
+type migrationTest0006 struct{}
+
+func (m migrationTest0006) InsertData(db *sqlx.DB) error {
+	// insert block to respect the FKey of batch
+	const queryInsertBlock = `INSERT INTO block (
+		eth_block_num,"timestamp",hash
+	) VALUES (
+		4417296,'2021-03-10 16:44:06.000',decode('C4D46677F3B2511D96389521C2BDFFE91127DE214423FF14899A6177631D2105','hex')
+	);`
+	// insert batch
+	const queryInsertBatch = `INSERT INTO batch (
+		batch_num,
+		eth_block_num,
+		forger_addr,
+		fees_collected,
+		fee_idxs_coordinator,
+		state_root,
+		num_accounts,
+		last_idx,
+		exit_root,
+		forge_l1_txs_num,
+		slot_num,
+		total_fees_usd
+	) VALUES (
+		6758,
+		4417296,
+		decode('459264CC7D2BF350AFDDA828C273E81367729C1F', 'hex'),
+		decode('7B2230223A34383337383531313632323134343030307D0A', 'hex'),
+		decode('5B3236335D0A', 'hex'),
+		12898140512818699175738765060248919016800434587665040485377676113605873428098,
+		256,
+		1044,
+		0,
+		NULL,
+		717,
+		115.047487133272
+	);`
+	_, err := db.Exec(queryInsertBlock + queryInsertBatch)
+	return err
+}
+
+func (m migrationTest0006) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the batch inserted in previous step gets NULL gas columns
+	// and that they can be updated
+	const queryGetBatch = `SELECT COUNT(*) FROM batch WHERE
+		batch_num = 6758 AND
+		gas_used IS NULL AND
+		gas_price IS NULL;
+	`
+	row := db.QueryRow(queryGetBatch)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 1, result)
+	const queryUpdateGas = `UPDATE batch SET
+		gas_used = 612345, gas_price = 110000000000 WHERE batch_num = 6758;`
+	_, err := db.Exec(queryUpdateGas)
+	assert.NoError(t, err)
+}
+
+func (m migrationTest0006) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the batch inserted in previous step is persisted
+	const queryGetBatch = `SELECT COUNT(*) FROM batch WHERE batch_num = 6758;`
+	row := db.QueryRow(queryGetBatch)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 1, result)
+	// check that the gas columns don't exist anymore
+	const queryCheckGasUsed = `SELECT COUNT(*) FROM batch WHERE gas_used IS NULL;`
+	row = db.QueryRow(queryCheckGasUsed)
+	assert.Equal(t, `pq: column "gas_used" does not exist`, row.Scan(&result).Error())
+	const queryCheckGasPrice = `SELECT COUNT(*) FROM batch WHERE gas_price IS NULL;`
+	row = db.QueryRow(queryCheckGasPrice)
+	assert.Equal(t, `pq: column "gas_price" does not exist`, row.Scan(&result).Error())
+}
+
+func TestMigration0006(t *testing.T) {
+	runMigrationTest(t, 6, migrationTest0006{})
+}
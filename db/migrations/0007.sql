@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE token_policy (
+    token_id INT PRIMARY KEY,
+    policy VARCHAR(8) NOT NULL,
+    timestamp TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc', now())
+);
+
+-- +migrate Down
+DROP TABLE token_policy;
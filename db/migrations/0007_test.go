@@ -0,0 +1,47 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `token_policy`
+
+type migrationTest0007 struct{}
+
+func (m migrationTest0007) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0007) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that token policies can be inserted and updated
+	const queryInsertPolicy = `INSERT INTO token_policy (token_id, policy) VALUES (33, 'denied');`
+	_, err := db.Exec(queryInsertPolicy)
+	assert.NoError(t, err)
+	const queryUpdatePolicy = `UPDATE token_policy SET policy = 'paused' WHERE token_id = 33;`
+	_, err = db.Exec(queryUpdatePolicy)
+	assert.NoError(t, err)
+	const queryGetPolicy = `SELECT COUNT(*) FROM token_policy WHERE
+		token_id = 33 AND
+		policy = 'paused' AND
+		timestamp IS NOT NULL;
+	`
+	row := db.QueryRow(queryGetPolicy)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 1, result)
+}
+
+func (m migrationTest0007) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetPolicy = `SELECT COUNT(*) FROM token_policy;`
+	row := db.QueryRow(queryGetPolicy)
+	var result int
+	assert.Equal(t, `pq: relation "token_policy" does not exist`, row.Scan(&result).Error())
+}
+
+func TestMigration0007(t *testing.T) {
+	runMigrationTest(t, 7, migrationTest0007{})
+}
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE metrics_snapshot (
+    item_id SERIAL PRIMARY KEY,
+    timestamp TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc', now()),
+    transactions_per_batch NUMERIC NOT NULL,
+    batch_frequency NUMERIC NOT NULL,
+    transactions_per_second NUMERIC NOT NULL,
+    token_accounts BIGINT NOT NULL,
+    wallets BIGINT NOT NULL,
+    avg_transaction_fee NUMERIC NOT NULL,
+    estimated_time_to_forge_l1 NUMERIC NOT NULL
+);
+CREATE INDEX metrics_snapshot_timestamp_idx ON metrics_snapshot (timestamp);
+
+-- +migrate Down
+DROP TABLE metrics_snapshot;
@@ -0,0 +1,52 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `metrics_snapshot`
+
+type migrationTest0008 struct{}
+
+func (m migrationTest0008) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0008) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that metrics snapshots can be inserted with a default timestamp
+	const queryInsertSnapshot = `INSERT INTO metrics_snapshot (
+		transactions_per_batch,
+		batch_frequency,
+		transactions_per_second,
+		token_accounts,
+		wallets,
+		avg_transaction_fee,
+		estimated_time_to_forge_l1
+	) VALUES (12.5, 300.0, 0.04, 1500, 900, 0.17, 600.0);`
+	_, err := db.Exec(queryInsertSnapshot)
+	assert.NoError(t, err)
+	const queryGetSnapshot = `SELECT COUNT(*) FROM metrics_snapshot WHERE
+		item_id = 1 AND
+		timestamp IS NOT NULL AND
+		token_accounts = 1500;
+	`
+	row := db.QueryRow(queryGetSnapshot)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 1, result)
+}
+
+func (m migrationTest0008) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetSnapshot = `SELECT COUNT(*) FROM metrics_snapshot;`
+	row := db.QueryRow(queryGetSnapshot)
+	var result int
+	assert.Equal(t, `pq: relation "metrics_snapshot" does not exist`, row.Scan(&result).Error())
+}
+
+func TestMigration0008(t *testing.T) {
+	runMigrationTest(t, 8, migrationTest0008{})
+}
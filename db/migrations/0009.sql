@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE tx_pool_audit (
+    item_id SERIAL PRIMARY KEY,
+    tx_id BYTEA NOT NULL,
+    old_state CHAR(4),
+    new_state CHAR(4),
+    batch_num BIGINT,
+    reason VARCHAR NOT NULL,
+    timestamp TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc', now())
+);
+CREATE INDEX tx_pool_audit_tx_id_idx ON tx_pool_audit (tx_id);
+
+-- +migrate Down
+DROP TABLE tx_pool_audit;
@@ -0,0 +1,57 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `tx_pool_audit`
+
+type migrationTest0009 struct{}
+
+func (m migrationTest0009) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0009) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that audit rows can be inserted with nullable states and a
+	// default timestamp
+	const queryInsertAudit = `INSERT INTO tx_pool_audit (
+		tx_id,
+		old_state,
+		new_state,
+		batch_num,
+		reason
+	) VALUES (decode('02000000000000000001', 'hex'), 'pend', 'fing', 42, 'start forging');`
+	_, err := db.Exec(queryInsertAudit)
+	assert.NoError(t, err)
+	const queryInsertPurge = `INSERT INTO tx_pool_audit (
+		tx_id,
+		old_state,
+		reason
+	) VALUES (decode('02000000000000000001', 'hex'), 'fged', 'purge: safety period expired');`
+	_, err = db.Exec(queryInsertPurge)
+	assert.NoError(t, err)
+	const queryGetAudit = `SELECT COUNT(*) FROM tx_pool_audit WHERE
+		tx_id = decode('02000000000000000001', 'hex') AND
+		timestamp IS NOT NULL;
+	`
+	row := db.QueryRow(queryGetAudit)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 2, result)
+}
+
+func (m migrationTest0009) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetAudit = `SELECT COUNT(*) FROM tx_pool_audit;`
+	row := db.QueryRow(queryGetAudit)
+	var result int
+	assert.Equal(t, `pq: relation "tx_pool_audit" does not exist`, row.Scan(&result).Error())
+}
+
+func TestMigration0009(t *testing.T) {
+	runMigrationTest(t, 9, migrationTest0009{})
+}
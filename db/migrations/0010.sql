@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE token ADD COLUMN usd_pinned BOOLEAN NOT NULL DEFAULT false;
+
+-- +migrate Down
+ALTER TABLE token DROP COLUMN usd_pinned;
@@ -0,0 +1,46 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration adds the column `usd_pinned` to the table `token`
+
+type migrationTest0010 struct{}
+
+func (m migrationTest0010) InsertData(db *sqlx.DB) error {
+	// insert a block and a token
+	const queryInsert = `
+	INSERT INTO block (eth_block_num, timestamp, hash)
+	VALUES (4417296, '2021-03-10 16:19:06', decode('C4D4ABEBD2C54CA16DA3EFBFD21F3AB4F2E04D1D9F3FA7F5BE875E1A237F3F42', 'hex'));
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd)
+	VALUES (64, 4417296, decode('1CA1A2C4CF4E8F66609DAB7A2B2C4F8F5CA1A2C4', 'hex'), 'Pinned', 'PIN', 18, 1.5);
+	`
+	_, err := db.Exec(queryInsert)
+	return err
+}
+
+func (m migrationTest0010) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the existing token is not pinned and that the column can
+	// be updated
+	row := db.QueryRow(`SELECT usd_pinned FROM token WHERE token_id = 64;`)
+	var pinned bool
+	assert.NoError(t, row.Scan(&pinned))
+	assert.False(t, pinned)
+	_, err := db.Exec(`UPDATE token SET usd_pinned = true WHERE token_id = 64;`)
+	assert.NoError(t, err)
+}
+
+func (m migrationTest0010) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the column doesn't exist anymore
+	row := db.QueryRow(`SELECT usd_pinned FROM token WHERE token_id = 64;`)
+	var pinned bool
+	assert.Error(t, row.Scan(&pinned))
+}
+
+func TestMigration0010(t *testing.T) {
+	runMigrationTest(t, 10, migrationTest0010{})
+}
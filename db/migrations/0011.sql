@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE coordinator_lease (
+    id BOOLEAN PRIMARY KEY DEFAULT true CONSTRAINT coordinator_lease_singleton CHECK (id),
+    holder VARCHAR NOT NULL,
+    expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE coordinator_lease;
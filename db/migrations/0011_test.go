@@ -0,0 +1,45 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `coordinator_lease`
+
+type migrationTest0011 struct{}
+
+func (m migrationTest0011) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0011) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the lease can be inserted and that the singleton
+	// constraint prevents a second row
+	const queryInsertLease = `INSERT INTO coordinator_lease (holder, expires_at)
+	VALUES ('coord-1', timezone('utc', now()));`
+	_, err := db.Exec(queryInsertLease)
+	assert.NoError(t, err)
+	const queryInsertSecondLease = `INSERT INTO coordinator_lease (id, holder, expires_at)
+	VALUES (false, 'coord-2', timezone('utc', now()));`
+	_, err = db.Exec(queryInsertSecondLease)
+	assert.Error(t, err)
+	row := db.QueryRow(`SELECT holder FROM coordinator_lease;`)
+	var holder string
+	assert.NoError(t, row.Scan(&holder))
+	assert.Equal(t, "coord-1", holder)
+}
+
+func (m migrationTest0011) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetLease = `SELECT COUNT(*) FROM coordinator_lease;`
+	row := db.QueryRow(queryGetLease)
+	var result int
+	assert.Equal(t, `pq: relation "coordinator_lease" does not exist`, row.Scan(&result).Error())
+}
+
+func TestMigration0011(t *testing.T) {
+	runMigrationTest(t, 11, migrationTest0011{})
+}
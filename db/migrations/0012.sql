@@ -0,0 +1,105 @@
+-- +migrate Up
+-- Replace the account_state view with a summary table that keeps the latest
+-- account_update per account, maintained incrementally by triggers.  This
+-- avoids the window-function scan over account_update in the accounts
+-- listing queries.
+DROP VIEW IF EXISTS account_state;
+CREATE TABLE account_state (
+    idx BIGINT PRIMARY KEY REFERENCES account (idx) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL,
+    eth_block_num BIGINT NOT NULL,
+    batch_num BIGINT NOT NULL,
+    nonce BIGINT NOT NULL,
+    balance DECIMAL(78,0) NOT NULL
+);
+INSERT INTO account_state (idx, item_id, eth_block_num, batch_num, nonce, balance)
+SELECT DISTINCT ON (idx) idx, item_id, eth_block_num, batch_num, nonce, balance
+FROM account_update ORDER BY idx, item_id DESC;
+
+-- +migrate StatementBegin
+CREATE FUNCTION refresh_account_state()
+    RETURNS TRIGGER
+AS
+$BODY$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO account_state (idx, item_id, eth_block_num, batch_num, nonce, balance)
+        VALUES (NEW.idx, NEW.item_id, NEW.eth_block_num, NEW.batch_num, NEW.nonce, NEW.balance)
+        ON CONFLICT (idx) DO UPDATE
+        SET item_id = EXCLUDED.item_id, eth_block_num = EXCLUDED.eth_block_num,
+            batch_num = EXCLUDED.batch_num, nonce = EXCLUDED.nonce, balance = EXCLUDED.balance
+        WHERE account_state.item_id < EXCLUDED.item_id;
+        RETURN NEW;
+    END IF;
+    -- DELETE only happens on reorgs: roll the state back to the previous
+    -- account_update.  When no update remains the account itself is being
+    -- deleted by cascade, which also removes the account_state row.
+    UPDATE account_state SET (item_id, eth_block_num, batch_num, nonce, balance) = (
+        SELECT item_id, eth_block_num, batch_num, nonce, balance
+        FROM account_update WHERE idx = OLD.idx ORDER BY item_id DESC LIMIT 1
+    ) WHERE account_state.idx = OLD.idx AND account_state.item_id = OLD.item_id
+    AND EXISTS (SELECT 1 FROM account_update WHERE idx = OLD.idx);
+    RETURN OLD;
+END;
+$BODY$
+LANGUAGE plpgsql;
+-- +migrate StatementEnd
+CREATE TRIGGER trigger_refresh_account_state AFTER INSERT OR DELETE ON account_update
+FOR EACH ROW EXECUTE PROCEDURE refresh_account_state();
+
+-- Per-batch tx counts maintained by triggers on tx, so that the metrics
+-- aggregates don't need to count over the whole tx table.
+CREATE TABLE batch_tx_count (
+    batch_num BIGINT PRIMARY KEY REFERENCES batch (batch_num) ON DELETE CASCADE,
+    total_txs BIGINT NOT NULL DEFAULT 0,
+    l2_txs BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO batch_tx_count (batch_num, total_txs, l2_txs)
+SELECT batch_num, COUNT(*), COUNT(*) FILTER (WHERE NOT is_l1)
+FROM tx WHERE batch_num IS NOT NULL GROUP BY batch_num;
+
+-- +migrate StatementBegin
+CREATE FUNCTION refresh_batch_tx_count()
+    RETURNS TRIGGER
+AS
+$BODY$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.batch_num IS NOT NULL THEN
+        -- On reorgs the batch_tx_count row is deleted by cascade before
+        -- the txs, in which case this UPDATE is a no-op
+        UPDATE batch_tx_count
+        SET total_txs = total_txs - 1, l2_txs = l2_txs - (NOT OLD.is_l1)::INT
+        WHERE batch_num = OLD.batch_num;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.batch_num IS NOT NULL THEN
+        INSERT INTO batch_tx_count (batch_num, total_txs, l2_txs)
+        VALUES (NEW.batch_num, 1, (NOT NEW.is_l1)::INT)
+        ON CONFLICT (batch_num) DO UPDATE
+        SET total_txs = batch_tx_count.total_txs + 1,
+            l2_txs = batch_tx_count.l2_txs + (NOT NEW.is_l1)::INT;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$BODY$
+LANGUAGE plpgsql;
+-- +migrate StatementEnd
+CREATE TRIGGER trigger_refresh_batch_tx_count AFTER INSERT OR DELETE OR UPDATE OF batch_num ON tx
+FOR EACH ROW EXECUTE PROCEDURE refresh_batch_tx_count();
+
+-- +migrate Down
+DROP TRIGGER IF EXISTS trigger_refresh_batch_tx_count ON tx;
+DROP FUNCTION IF EXISTS refresh_batch_tx_count;
+DROP TABLE IF EXISTS batch_tx_count;
+DROP TRIGGER IF EXISTS trigger_refresh_account_state ON account_update;
+DROP FUNCTION IF EXISTS refresh_account_state;
+DROP TABLE IF EXISTS account_state;
+CREATE VIEW account_state AS SELECT DISTINCT idx,
+first_value(nonce) OVER w AS nonce,
+first_value(balance) OVER w AS balance,
+first_value(eth_block_num) OVER w AS eth_block_num,
+first_value(batch_num) OVER w AS batch_num
+FROM account_update
+window w AS (partition by idx ORDER BY item_id desc);
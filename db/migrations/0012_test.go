@@ -0,0 +1,100 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This migration replaces the account_state view with a summary table
+// maintained by triggers, and adds the batch_tx_count summary table
+
+type migrationTest0012 struct{}
+
+func (m migrationTest0012) InsertData(db *sqlx.DB) error {
+	// insert a block, token, batch and account to respect the FKeys
+	const queryInsert = `
+	INSERT INTO block (eth_block_num, timestamp, hash)
+	VALUES (4417296, '2021-03-10 16:19:06', decode('C4D4ABEBD2C54CA16DA3EFBFD21F3AB4F2E04D1D9F3FA7F5BE875E1A237F3F42', 'hex'));
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+	VALUES (2, 4417296, decode('1B36A4DED4DF40248C0E0E52CEA5EDC9A298B721', 'hex'), 'Dai Stablecoin', 'DAI', 18, 1.01, NOW());
+	INSERT INTO batch (batch_num, eth_block_num, forger_addr, fees_collected, fee_idxs_coordinator,
+		state_root, num_accounts, last_idx, exit_root, forge_l1_txs_num, slot_num, total_fees_usd)
+	VALUES (6758, 4417296, decode('459264CC7D2BF350AFDDA828C273E81367729C1F', 'hex'),
+		decode('7B2230223A34383337383531313632323134343030307D0A', 'hex'), decode('5B3236335D0A', 'hex'),
+		12898140512818699175738765060248919016800434587665040485377676113605873428098,
+		256, 1044, 0, NULL, 717, 115.047487133272);
+	INSERT INTO account (idx, token_id, batch_num, bjj, eth_addr)
+	VALUES (789, 2, 6758, decode('FDDACE21457376B0952CCD19CE66B854FDD7C6E45905B0A0A75747C87D41719A', 'hex'),
+		decode('A631BE6995643E6085330A31B9E1AF48DD5D6B7F', 'hex'));
+	INSERT INTO account_update (eth_block_num, batch_num, idx, nonce, balance)
+	VALUES (4417296, 6758, 789, 0, 100);
+	INSERT INTO account_update (eth_block_num, batch_num, idx, nonce, balance)
+	VALUES (4417296, 6758, 789, 1, 80);
+	INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx, to_idx,
+		amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce)
+	VALUES (false, decode('02E62B9E1D365B8F092F8D0B4DE8767930F43C3AF7A2CBF18A433FD9CF90A1DB17', 'hex'),
+		'Transfer', 0, 789, 789, 789, 20, 20, 2, 6758, 4417296, 126, 0);
+	`
+	_, err := db.Exec(queryInsert)
+	return err
+}
+
+func (m migrationTest0012) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// the account_state table is backfilled with the latest account_update
+	var nonce, balance int64
+	row := db.QueryRow(`SELECT nonce, balance::BIGINT FROM account_state WHERE idx = 789;`)
+	require.NoError(t, row.Scan(&nonce, &balance))
+	assert.Equal(t, int64(1), nonce)
+	assert.Equal(t, int64(80), balance)
+	// the batch_tx_count table is backfilled from the tx table
+	var totalTxs, l2Txs int64
+	row = db.QueryRow(`SELECT total_txs, l2_txs FROM batch_tx_count WHERE batch_num = 6758;`)
+	require.NoError(t, row.Scan(&totalTxs, &l2Txs))
+	assert.Equal(t, int64(1), totalTxs)
+	assert.Equal(t, int64(1), l2Txs)
+	// a new account_update refreshes the summary through the trigger
+	_, err := db.Exec(`INSERT INTO account_update (eth_block_num, batch_num, idx, nonce, balance)
+		VALUES (4417296, 6758, 789, 2, 60);`)
+	require.NoError(t, err)
+	row = db.QueryRow(`SELECT nonce, balance::BIGINT FROM account_state WHERE idx = 789;`)
+	require.NoError(t, row.Scan(&nonce, &balance))
+	assert.Equal(t, int64(2), nonce)
+	assert.Equal(t, int64(60), balance)
+	// deleting the latest account_update (reorg) rolls the summary back
+	_, err = db.Exec(`DELETE FROM account_update WHERE idx = 789 AND nonce = 2;`)
+	require.NoError(t, err)
+	row = db.QueryRow(`SELECT nonce, balance::BIGINT FROM account_state WHERE idx = 789;`)
+	require.NoError(t, row.Scan(&nonce, &balance))
+	assert.Equal(t, int64(1), nonce)
+	assert.Equal(t, int64(80), balance)
+	// a new tx increments the per-batch count through the trigger
+	_, err = db.Exec(`INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx,
+		to_idx, amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce)
+	VALUES (false, decode('02A8FC0E2B6A7733800BBA6A0212B6F29D9238566FD0EBA8F2C366C6FD6A4ECF66', 'hex'),
+		'Transfer', 1, 789, 789, 789, 10, 10, 2, 6758, 4417296, 126, 1);`)
+	require.NoError(t, err)
+	row = db.QueryRow(`SELECT total_txs, l2_txs FROM batch_tx_count WHERE batch_num = 6758;`)
+	require.NoError(t, row.Scan(&totalTxs, &l2Txs))
+	assert.Equal(t, int64(2), totalTxs)
+	assert.Equal(t, int64(2), l2Txs)
+}
+
+func (m migrationTest0012) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// account_state is a view again and still resolves the latest update
+	var nonce, balance int64
+	row := db.QueryRow(`SELECT nonce, balance::BIGINT FROM account_state WHERE idx = 789;`)
+	require.NoError(t, row.Scan(&nonce, &balance))
+	assert.Equal(t, int64(1), nonce)
+	assert.Equal(t, int64(80), balance)
+	// batch_tx_count doesn't exist anymore
+	var count int64
+	row = db.QueryRow(`SELECT total_txs FROM batch_tx_count WHERE batch_num = 6758;`)
+	assert.Error(t, row.Scan(&count))
+}
+
+func TestMigration0012(t *testing.T) {
+	runMigrationTest(t, 12, migrationTest0012{})
+}
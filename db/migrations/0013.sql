@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE webhook_subscription (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR NOT NULL,
+    secret VARCHAR NOT NULL,
+    eth_addr BYTEA,
+    bjj BYTEA,
+    idx BIGINT,
+    event_types VARCHAR NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc', now())
+);
+
+-- +migrate Down
+DROP TABLE webhook_subscription;
@@ -0,0 +1,57 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `webhook_subscription`
+
+type migrationTest0013 struct{}
+
+func (m migrationTest0013) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0013) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that subscriptions can be inserted with nullable filters and a
+	// default timestamp
+	const queryInsertFiltered = `INSERT INTO webhook_subscription (
+		url,
+		secret,
+		eth_addr,
+		event_types
+	) VALUES ('https://example.com/hook', 's3cret',
+		decode('c58d29fa6e86e4fae04ddced660d45bcf3cb2370', 'hex'), 'tx-forged');`
+	_, err := db.Exec(queryInsertFiltered)
+	assert.NoError(t, err)
+	const queryInsertCatchAll = `INSERT INTO webhook_subscription (
+		url,
+		secret,
+		event_types
+	) VALUES ('https://example.com/all', 's3cret',
+		'tx-forged,exit-withdrawable,pool-tx-invalidated');`
+	_, err = db.Exec(queryInsertCatchAll)
+	assert.NoError(t, err)
+	const queryGetSubscriptions = `SELECT COUNT(*) FROM webhook_subscription
+		WHERE created_at IS NOT NULL;`
+	row := db.QueryRow(queryGetSubscriptions)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 2, result)
+}
+
+func (m migrationTest0013) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetSubscriptions = `SELECT COUNT(*) FROM webhook_subscription;`
+	row := db.QueryRow(queryGetSubscriptions)
+	var result int
+	assert.Equal(t, `pq: relation "webhook_subscription" does not exist`,
+		row.Scan(&result).Error())
+}
+
+func TestMigration0013(t *testing.T) {
+	runMigrationTest(t, 13, migrationTest0013{})
+}
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE l1_deposit_watch (
+    eth_tx_hash BYTEA PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (timezone('utc', now())),
+    -- Ethereum block in which the watched transaction was included, NULL
+    -- while the transaction is still pending
+    eth_block_num BIGINT,
+    -- Set when the watched transaction was included but reverted or didn't
+    -- emit any L1UserTxEvent
+    failed BOOLEAN NOT NULL DEFAULT false,
+    -- L1UserTx identification decoded from the L1UserTxEvent once the
+    -- transaction is included.  No foreign key against the tx table because
+    -- the L1UserTx may not be synced yet (and is deleted during reorgs)
+    tx_id BYTEA,
+    to_forge_l1_txs_num BIGINT,
+    position INT
+);
+
+-- +migrate Down
+DROP TABLE l1_deposit_watch;
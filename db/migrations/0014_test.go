@@ -0,0 +1,51 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `l1_deposit_watch`
+
+type migrationTest0014 struct{}
+
+func (m migrationTest0014) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func (m migrationTest0014) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that a watch can be registered with only the eth tx hash, and
+	// later updated with the inclusion data
+	const queryInsert = `INSERT INTO l1_deposit_watch (eth_tx_hash) VALUES
+		(decode('69b20d1be8875dbfa8fa6a3675ee4a6a390ed712b0f7ab33579411b917b677e0', 'hex'));`
+	_, err := db.Exec(queryInsert)
+	assert.NoError(t, err)
+	const queryUpdate = `UPDATE l1_deposit_watch SET
+		eth_block_num = 1234,
+		tx_id = decode('00eb5e95e1ce5e9f6c4ed402d415e8d0bdd7664769cfd2064d28da04a2c76be432', 'hex'),
+		to_forge_l1_txs_num = 7,
+		position = 3;`
+	_, err = db.Exec(queryUpdate)
+	assert.NoError(t, err)
+	const queryGetPending = `SELECT COUNT(*) FROM l1_deposit_watch
+		WHERE eth_block_num IS NULL AND NOT failed;`
+	row := db.QueryRow(queryGetPending)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	assert.Equal(t, 0, result)
+}
+
+func (m migrationTest0014) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	const queryGetWatches = `SELECT COUNT(*) FROM l1_deposit_watch;`
+	row := db.QueryRow(queryGetWatches)
+	var result int
+	assert.Equal(t, `pq: relation "l1_deposit_watch" does not exist`,
+		row.Scan(&result).Error())
+}
+
+func TestMigration0014(t *testing.T) {
+	runMigrationTest(t, 14, migrationTest0014{})
+}
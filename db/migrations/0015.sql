@@ -0,0 +1,7 @@
+-- +migrate Up
+-- Support the balance filters and sorting of the accounts listing queries
+-- without scanning the whole account_state table.
+CREATE INDEX account_state_balance_idx ON account_state (balance);
+
+-- +migrate Down
+DROP INDEX IF EXISTS account_state_balance_idx;
@@ -0,0 +1,39 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the index `account_state_balance_idx`
+
+type migrationTest0015 struct{}
+
+func (m migrationTest0015) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func queryAccountStateBalanceIdx(t *testing.T, db *sqlx.DB) int {
+	const query = `SELECT COUNT(*) FROM pg_indexes
+		WHERE indexname = 'account_state_balance_idx';`
+	row := db.QueryRow(query)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	return result
+}
+
+func (m migrationTest0015) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the index exists
+	assert.Equal(t, 1, queryAccountStateBalanceIdx(t, db))
+}
+
+func (m migrationTest0015) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the index doesn't exist anymore
+	assert.Equal(t, 0, queryAccountStateBalanceIdx(t, db))
+}
+
+func TestMigration0015(t *testing.T) {
+	runMigrationTest(t, 15, migrationTest0015{})
+}
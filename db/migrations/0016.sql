@@ -0,0 +1,7 @@
+-- +migrate Up
+-- Maximum batch number in which a pool tx can be forged, NULL when the tx
+-- doesn't expire.
+ALTER TABLE tx_pool ADD COLUMN max_num_batch BIGINT DEFAULT NULL;
+
+-- +migrate Down
+ALTER TABLE tx_pool DROP COLUMN max_num_batch;
@@ -0,0 +1,77 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This migration adds the column `max_num_batch` on `tx_pool`
+
+type migrationTest0016 struct{}
+
+func (m migrationTest0016) InsertData(db *sqlx.DB) error {
+	// insert a block, a token, a batch and an account to respect the
+	// FKeys of tx_pool and the set_pool_tx() trigger
+	const queryInsert = `
+	INSERT INTO block (eth_block_num, timestamp, hash)
+	VALUES (4417296, '2021-03-10 16:44:06.000', decode('C4D46677F3B2511D96389521C2BDFFE91127DE214423FF14899A6177631D2105', 'hex'));
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+	VALUES (2, 4417296, decode('1B36A4DED4DF40248C0E0E52CEA5EDC9A298B721', 'hex'), 'Dai Stablecoin', 'DAI', 18, 1.01, '2021-04-17 20:21:16.870');
+	INSERT INTO batch (batch_num, eth_block_num, forger_addr, fees_collected, fee_idxs_coordinator,
+		state_root, num_accounts, last_idx, exit_root, forge_l1_txs_num, slot_num, total_fees_usd)
+	VALUES (6758, 4417296, decode('459264CC7D2BF350AFDDA828C273E81367729C1F', 'hex'),
+		decode('7B2230223A34383337383531313632323134343030307D0A', 'hex'), decode('5B3236335D0A', 'hex'),
+		12898140512818699175738765060248919016800434587665040485377676113605873428098,
+		256, 1044, 0, NULL, 717, 115.047487133272);
+	INSERT INTO account (idx, token_id, batch_num, bjj, eth_addr)
+	VALUES (789, 2, 6758, decode('FDDACE21457376B0952CCD19CE66B854FDD7C6E45905B0A0A75747C87D41719A', 'hex'),
+		decode('A631BE6995643E6085330A31B9E1AF48DD5D6B7F', 'hex'));
+	INSERT INTO tx_pool (tx_id, from_idx, token_id, amount, amount_f, fee, nonce, state, signature, tx_type)
+	VALUES (decode('023A0D72BEB1095C28A7130D896F484CC9D465C1C95F1617C0A7B2094E3E1F11FF', 'hex'),
+		789, 2, 5, 5, 227, 3, 'pend',
+		decode('9C6A159C57D7FC58E3E5D3510FBC64EAC9C0D56A1B3144D94D6BBA4C23B9402CEE57D0CFF4A3BE135CBD2393AB8FD2A1840A62281B1721801DBF708D27F1DF00', 'hex'),
+		'Transfer');
+	`
+	_, err := db.Exec(queryInsert)
+	return err
+}
+
+func (m migrationTest0016) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the tx inserted in the previous step is persisted and
+	// gets a NULL max_num_batch
+	var maxNumBatch *int64
+	row := db.QueryRow(`SELECT max_num_batch FROM tx_pool WHERE
+		tx_id = decode('023A0D72BEB1095C28A7130D896F484CC9D465C1C95F1617C0A7B2094E3E1F11FF', 'hex');`)
+	require.NoError(t, row.Scan(&maxNumBatch))
+	assert.Nil(t, maxNumBatch)
+	// check that the column can be set and read back
+	_, err := db.Exec(`UPDATE tx_pool SET max_num_batch = 6760 WHERE
+		tx_id = decode('023A0D72BEB1095C28A7130D896F484CC9D465C1C95F1617C0A7B2094E3E1F11FF', 'hex');`)
+	require.NoError(t, err)
+	row = db.QueryRow(`SELECT max_num_batch FROM tx_pool WHERE
+		tx_id = decode('023A0D72BEB1095C28A7130D896F484CC9D465C1C95F1617C0A7B2094E3E1F11FF', 'hex');`)
+	require.NoError(t, row.Scan(&maxNumBatch))
+	require.NotNil(t, maxNumBatch)
+	assert.Equal(t, int64(6760), *maxNumBatch)
+}
+
+func (m migrationTest0016) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the column doesn't exist anymore
+	var maxNumBatch *int64
+	row := db.QueryRow(`SELECT max_num_batch FROM tx_pool;`)
+	assert.Equal(t, `pq: column "max_num_batch" does not exist`, row.Scan(&maxNumBatch).Error())
+	// check that the tx inserted in the previous step is persisted
+	const queryGetTx = `SELECT COUNT(*) FROM tx_pool WHERE
+		tx_id = decode('023A0D72BEB1095C28A7130D896F484CC9D465C1C95F1617C0A7B2094E3E1F11FF', 'hex') AND
+		state = 'pend';`
+	var result int
+	require.NoError(t, db.QueryRow(queryGetTx).Scan(&result))
+	assert.Equal(t, 1, result)
+}
+
+func TestMigration0016(t *testing.T) {
+	runMigrationTest(t, 16, migrationTest0016{})
+}
@@ -0,0 +1,12 @@
+-- +migrate Up
+-- Coordinator fee account used to forge each batch forged by this node.  Only
+-- filled by the coordinator, so there is no foreign key to batch: the batch
+-- may not be synchronized yet when the row is inserted.
+CREATE TABLE batch_coord_account (
+    batch_num BIGINT PRIMARY KEY,
+    addr BYTEA NOT NULL,
+    bjj BYTEA NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE batch_coord_account;
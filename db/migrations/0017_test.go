@@ -0,0 +1,43 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `batch_coord_account`
+
+type migrationTest0017 struct{}
+
+func (m migrationTest0017) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func queryBatchCoordAccountTable(t *testing.T, db *sqlx.DB) int {
+	const query = `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_name = 'batch_coord_account';`
+	row := db.QueryRow(query)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	return result
+}
+
+func (m migrationTest0017) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the table exists and accepts inserts
+	assert.Equal(t, 1, queryBatchCoordAccountTable(t, db))
+	const insert = `INSERT INTO batch_coord_account
+		(batch_num, addr, bjj) VALUES (1, '\x01', '\x02');`
+	_, err := db.Exec(insert)
+	assert.NoError(t, err)
+}
+
+func (m migrationTest0017) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	assert.Equal(t, 0, queryBatchCoordAccountTable(t, db))
+}
+
+func TestMigration0017(t *testing.T) {
+	runMigrationTest(t, 17, migrationTest0017{})
+}
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- Requests from users that authorize the coordinator to submit the
+-- withdrawMerkleProof of their exits on their behalf for a fee (see
+-- coordinator/withdrawrelayer.go).  Only filled through the coordinator API,
+-- so there are no foreign keys to the synchronized tables: the referenced
+-- exit may be reorged out after the request is inserted.
+CREATE TABLE withdrawal_relay_request (
+    item_id SERIAL PRIMARY KEY,
+    batch_num BIGINT NOT NULL,
+    account_idx BIGINT NOT NULL,
+    owner_addr BYTEA NOT NULL,
+    fee DECIMAL(78,0) NOT NULL,
+    signature BYTEA NOT NULL,
+    -- pending | sent | confirmed | failed | rejected
+    status VARCHAR(9) NOT NULL DEFAULT 'pending',
+    eth_tx_hash BYTEA,
+    info VARCHAR,
+    timestamp TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT timezone('utc', now()),
+    UNIQUE (batch_num, account_idx)
+);
+
+-- +migrate Down
+DROP TABLE withdrawal_relay_request;
@@ -0,0 +1,53 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// This migration creates the table `withdrawal_relay_request`
+
+type migrationTest0018 struct{}
+
+func (m migrationTest0018) InsertData(db *sqlx.DB) error {
+	return nil
+}
+
+func queryWithdrawalRelayRequestTable(t *testing.T, db *sqlx.DB) int {
+	const query = `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_name = 'withdrawal_relay_request';`
+	row := db.QueryRow(query)
+	var result int
+	assert.NoError(t, row.Scan(&result))
+	return result
+}
+
+func (m migrationTest0018) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// check that the table exists and accepts inserts with the default
+	// status
+	assert.Equal(t, 1, queryWithdrawalRelayRequestTable(t, db))
+	const insert = `INSERT INTO withdrawal_relay_request
+		(batch_num, account_idx, owner_addr, fee, signature)
+		VALUES (1, 256, '\x01', 100, '\x02');`
+	_, err := db.Exec(insert)
+	assert.NoError(t, err)
+	row := db.QueryRow(`SELECT status FROM withdrawal_relay_request
+		WHERE batch_num = 1 AND account_idx = 256;`)
+	var status string
+	assert.NoError(t, row.Scan(&status))
+	assert.Equal(t, "pending", status)
+	// check that duplicated requests for the same exit are rejected
+	_, err = db.Exec(insert)
+	assert.Error(t, err)
+}
+
+func (m migrationTest0018) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// check that the table doesn't exist anymore
+	assert.Equal(t, 0, queryWithdrawalRelayRequestTable(t, db))
+}
+
+func TestMigration0018(t *testing.T) {
+	runMigrationTest(t, 18, migrationTest0018{})
+}
@@ -0,0 +1,131 @@
+-- +migrate Up
+-- Fix the stale price check of the set_tx() trigger: the old condition
+-- compared usd_update against itself, so it always passed and the USD values
+-- of txs were computed from arbitrarily old prices, silently skewing metrics
+-- and recommended fees.  The price is now required to be within 24 hours of
+-- the timestamp of the block of the tx, leaving the USD fields NULL otherwise
+-- (they can be recovered later through the historic price backfill).  The
+-- same window, configurable through PriceUpdater.StalePriceWindow, drives the
+-- staleness checks done outside the database.
+-- +migrate StatementBegin
+CREATE OR REPLACE FUNCTION set_tx()
+    RETURNS TRIGGER
+AS
+$BODY$
+DECLARE
+	_value NUMERIC;
+	_usd_update TIMESTAMP;
+    _tx_timestamp TIMESTAMP;
+BEGIN
+    IF NEW.is_l1  THEN
+        -- Validate L1 Tx
+        IF NEW.user_origin IS NULL OR
+        NEW.from_eth_addr IS NULL OR
+        NEW.from_bjj IS NULL OR
+        NEW.deposit_amount IS NULL OR
+        NEW.deposit_amount_f IS NULL OR
+        (NOT NEW.user_origin AND NEW.batch_num IS NULL)  THEN -- If is Coordinator L1, must include batch_num
+            RAISE EXCEPTION 'Invalid L1 tx: %', NEW;
+        END IF;
+    ELSE
+        -- Validate L2 Tx
+        IF NEW.batch_num IS NULL OR NEW.nonce IS NULL THEN
+            RAISE EXCEPTION 'Invalid L2 tx: %', NEW;
+        END IF;
+        -- Set fee if it's null
+        IF NEW.fee IS NULL THEN
+            NEW.fee = (SELECT 0);
+        END IF;
+        -- Set token_id
+        NEW."token_id" = (SELECT token_id FROM account WHERE idx = NEW."from_idx");
+        -- Set from_{eth_addr,bjj}
+        SELECT INTO NEW."from_eth_addr", NEW."from_bjj" eth_addr, bjj FROM account WHERE idx = NEW.from_idx;
+    END IF;
+    -- Set USD related, only when the price is not stale: the last price
+    -- update must be within 24 hours of the timestamp of the block of the tx
+    SELECT INTO _value, _usd_update
+        usd / POWER(10, decimals), usd_update FROM token WHERE token_id = NEW.token_id;
+    SELECT INTO _tx_timestamp timestamp FROM block WHERE eth_block_num = NEW.eth_block_num;
+    IF _usd_update IS NOT NULL AND _tx_timestamp - interval '24 hours' < _usd_update AND _tx_timestamp + interval '24 hours' > _usd_update THEN
+        IF _value > 0.0 THEN
+            IF NEW."amount_f" > 0.0 THEN
+                NEW."amount_usd" = (SELECT _value * NEW."amount_f");
+                IF NOT NEW."is_l1" AND NEW."fee" > 0 THEN
+                    NEW."fee_usd" = (SELECT NEW."amount_usd" * fee_percentage(NEW.fee::NUMERIC));
+                END IF;
+            END IF;
+            IF NEW."is_l1" AND NEW."deposit_amount_f" > 0.0 THEN
+                NEW."deposit_amount_usd" = (SELECT _value * NEW.deposit_amount_f);
+            END IF;
+        END IF;
+    END IF;
+    -- Set to_{eth_addr,bjj}
+    IF NEW."to_idx" > 255 THEN
+        SELECT INTO NEW."to_eth_addr", NEW."to_bjj" eth_addr, bjj FROM account WHERE idx = NEW."to_idx";
+    END IF;
+    RETURN NEW;
+END;
+$BODY$
+LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+-- +migrate Down
+-- +migrate StatementBegin
+CREATE OR REPLACE FUNCTION set_tx()
+    RETURNS TRIGGER
+AS
+$BODY$
+DECLARE
+	_value NUMERIC;
+	_usd_update TIMESTAMP;
+    _tx_timestamp TIMESTAMP;
+BEGIN
+    IF NEW.is_l1  THEN
+        -- Validate L1 Tx
+        IF NEW.user_origin IS NULL OR
+        NEW.from_eth_addr IS NULL OR
+        NEW.from_bjj IS NULL OR
+        NEW.deposit_amount IS NULL OR
+        NEW.deposit_amount_f IS NULL OR
+        (NOT NEW.user_origin AND NEW.batch_num IS NULL)  THEN -- If is Coordinator L1, must include batch_num
+            RAISE EXCEPTION 'Invalid L1 tx: %', NEW;
+        END IF;
+    ELSE
+        -- Validate L2 Tx
+        IF NEW.batch_num IS NULL OR NEW.nonce IS NULL THEN
+            RAISE EXCEPTION 'Invalid L2 tx: %', NEW;
+        END IF;
+        -- Set fee if it's null
+        IF NEW.fee IS NULL THEN
+            NEW.fee = (SELECT 0);
+        END IF;
+        -- Set token_id
+        NEW."token_id" = (SELECT token_id FROM account WHERE idx = NEW."from_idx");
+        -- Set from_{eth_addr,bjj}
+        SELECT INTO NEW."from_eth_addr", NEW."from_bjj" eth_addr, bjj FROM account WHERE idx = NEW.from_idx;
+    END IF;
+    -- Set USD related
+    SELECT INTO _value, _usd_update, _tx_timestamp
+        usd / POWER(10, decimals), usd_update, timestamp FROM token INNER JOIN block on token.eth_block_num = block.eth_block_num WHERE token_id = NEW.token_id;
+    IF _usd_update - interval '24 hours' < _usd_update AND _usd_update + interval '24 hours' > _usd_update THEN
+        IF _value > 0.0 THEN
+            IF NEW."amount_f" > 0.0 THEN
+                NEW."amount_usd" = (SELECT _value * NEW."amount_f");
+                IF NOT NEW."is_l1" AND NEW."fee" > 0 THEN
+                    NEW."fee_usd" = (SELECT NEW."amount_usd" * fee_percentage(NEW.fee::NUMERIC));
+                END IF;
+            END IF;
+            IF NEW."is_l1" AND NEW."deposit_amount_f" > 0.0 THEN
+                NEW."deposit_amount_usd" = (SELECT _value * NEW.deposit_amount_f);
+            END IF;
+        END IF;
+    END IF;
+    -- Set to_{eth_addr,bjj}
+    IF NEW."to_idx" > 255 THEN
+        SELECT INTO NEW."to_eth_addr", NEW."to_bjj" eth_addr, bjj FROM account WHERE idx = NEW."to_idx";
+    END IF;
+    RETURN NEW;
+END;
+$BODY$
+LANGUAGE plpgsql;
+-- +migrate StatementEnd
@@ -0,0 +1,95 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This migration fixes the stale price check of the set_tx() trigger, so
+// that txs of tokens whose USD price is older than 24 hours get NULL USD
+// values instead of values computed from the stale price
+
+type migrationTest0019 struct{}
+
+func (m migrationTest0019) InsertData(db *sqlx.DB) error {
+	// insert a block, a token whose price is 48 hours old, a batch and an
+	// account to respect the FKeys
+	const queryInsert = `
+	INSERT INTO block (eth_block_num, timestamp, hash)
+	VALUES (4417296, NOW(), decode('C4D4ABEBD2C54CA16DA3EFBFD21F3AB4F2E04D1D9F3FA7F5BE875E1A237F3F42', 'hex'));
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+	VALUES (2, 4417296, decode('1B36A4DED4DF40248C0E0E52CEA5EDC9A298B721', 'hex'), 'Dai Stablecoin', 'DAI', 18, 1.01, NOW() - interval '48 hours');
+	INSERT INTO batch (batch_num, eth_block_num, forger_addr, fees_collected, fee_idxs_coordinator,
+		state_root, num_accounts, last_idx, exit_root, forge_l1_txs_num, slot_num, total_fees_usd)
+	VALUES (6758, 4417296, decode('459264CC7D2BF350AFDDA828C273E81367729C1F', 'hex'),
+		decode('7B2230223A34383337383531313632323134343030307D0A', 'hex'), decode('5B3236335D0A', 'hex'),
+		12898140512818699175738765060248919016800434587665040485377676113605873428098,
+		256, 1044, 0, NULL, 717, 115.047487133272);
+	INSERT INTO account (idx, token_id, batch_num, bjj, eth_addr)
+	VALUES (789, 2, 6758, decode('FDDACE21457376B0952CCD19CE66B854FDD7C6E45905B0A0A75747C87D41719A', 'hex'),
+		decode('A631BE6995643E6085330A31B9E1AF48DD5D6B7F', 'hex'));
+	`
+	_, err := db.Exec(queryInsert)
+	return err
+}
+
+func insertTx0019(db *sqlx.DB, id string, position int) error {
+	_, err := db.Exec(`INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx,
+		to_idx, amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce)
+	VALUES (false, decode($1, 'hex'), 'Transfer', $2, 789, 789, 789,
+		1000000000000000000, 1000000000000000000, 2, 6758, 4417296, 126, $2);`, id, position)
+	return err
+}
+
+func (m migrationTest0019) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// a tx of a token with a stale price gets NULL USD values
+	require.NoError(t, insertTx0019(db,
+		"02E62B9E1D365B8F092F8D0B4DE8767930F43C3AF7A2CBF18A433FD9CF90A1DB17", 0))
+	var amountUSD, feeUSD *float64
+	row := db.QueryRow(`SELECT amount_usd, fee_usd FROM tx WHERE position = 0;`)
+	require.NoError(t, row.Scan(&amountUSD, &feeUSD))
+	assert.Nil(t, amountUSD)
+	assert.Nil(t, feeUSD)
+	// refreshing the price (the trigger updates usd_update) makes new txs
+	// get USD values again
+	_, err := db.Exec(`UPDATE token SET usd = 1.01 WHERE token_id = 2;`)
+	require.NoError(t, err)
+	require.NoError(t, insertTx0019(db,
+		"02A8FC0E2B6A7733800BBA6A0212B6F29D9238566FD0EBA8F2C366C6FD6A4ECF66", 1))
+	row = db.QueryRow(`SELECT amount_usd, fee_usd FROM tx WHERE position = 1;`)
+	require.NoError(t, row.Scan(&amountUSD, &feeUSD))
+	require.NotNil(t, amountUSD)
+	assert.InDelta(t, 1.01, *amountUSD, 0.001)
+	assert.NotNil(t, feeUSD)
+}
+
+func (m migrationTest0019) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// with the old trigger a tx of a token with a stale price still gets
+	// USD values computed from the stale price.  A new token is inserted
+	// because updating the existing one would refresh its usd_update
+	queryInsert := `
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+	VALUES (3, 4417296, decode('2B36A4DED4DF40248C0E0E52CEA5EDC9A298B722', 'hex'), 'Token', 'TKN', 18, 1.01, NOW() - interval '48 hours');
+	INSERT INTO account (idx, token_id, batch_num, bjj, eth_addr)
+	VALUES (790, 3, 6758, decode('FDDACE21457376B0952CCD19CE66B854FDD7C6E45905B0A0A75747C87D41719A', 'hex'),
+		decode('A631BE6995643E6085330A31B9E1AF48DD5D6B7F', 'hex'));
+	`
+	_, err := db.Exec(queryInsert)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx,
+		to_idx, amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce)
+	VALUES (false, decode('02B8FC0E2B6A7733800BBA6A0212B6F29D9238566FD0EBA8F2C366C6FD6A4ECF67', 'hex'),
+		'Transfer', 2, 790, 790, 789, 1000000000000000000, 1000000000000000000, 3, 6758, 4417296, 126, 0);`)
+	require.NoError(t, err)
+	var amountUSD *float64
+	row := db.QueryRow(`SELECT amount_usd FROM tx WHERE position = 2;`)
+	require.NoError(t, row.Scan(&amountUSD))
+	assert.NotNil(t, amountUSD)
+}
+
+func TestMigration0019(t *testing.T) {
+	runMigrationTest(t, 19, migrationTest0019{})
+}
@@ -0,0 +1,13 @@
+-- +migrate Up
+-- Offset and length of the encoded bytes of each forged L2 tx within the
+-- forgeBatch calldata, recorded by the synchronizer so that the inclusion of
+-- a tx can be verified against the on-chain data.  NULL for L1 txs and for
+-- txs synchronized before this migration
+ALTER TABLE tx
+    ADD COLUMN da_offset INT DEFAULT NULL,
+    ADD COLUMN da_length INT DEFAULT NULL;
+
+-- +migrate Down
+ALTER TABLE tx
+    DROP COLUMN da_offset,
+    DROP COLUMN da_length;
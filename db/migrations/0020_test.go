@@ -0,0 +1,76 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This migration adds the da_offset and da_length columns to the tx table,
+// which locate the encoded bytes of each forged L2 tx within the forgeBatch
+// calldata
+
+type migrationTest0020 struct{}
+
+func (m migrationTest0020) InsertData(db *sqlx.DB) error {
+	// insert a block, a token, a batch, an account and a tx to check that
+	// existing txs get NULL offsets
+	const queryInsert = `
+	INSERT INTO block (eth_block_num, timestamp, hash)
+	VALUES (4417296, NOW(), decode('C4D4ABEBD2C54CA16DA3EFBFD21F3AB4F2E04D1D9F3FA7F5BE875E1A237F3F42', 'hex'));
+	INSERT INTO token (token_id, eth_block_num, eth_addr, name, symbol, decimals, usd, usd_update)
+	VALUES (2, 4417296, decode('1B36A4DED4DF40248C0E0E52CEA5EDC9A298B721', 'hex'), 'Dai Stablecoin', 'DAI', 18, 1.01, NOW());
+	INSERT INTO batch (batch_num, eth_block_num, forger_addr, fees_collected, fee_idxs_coordinator,
+		state_root, num_accounts, last_idx, exit_root, forge_l1_txs_num, slot_num, total_fees_usd)
+	VALUES (6758, 4417296, decode('459264CC7D2BF350AFDDA828C273E81367729C1F', 'hex'),
+		decode('7B2230223A34383337383531313632323134343030307D0A', 'hex'), decode('5B3236335D0A', 'hex'),
+		12898140512818699175738765060248919016800434587665040485377676113605873428098,
+		256, 1044, 0, NULL, 717, 115.047487133272);
+	INSERT INTO account (idx, token_id, batch_num, bjj, eth_addr)
+	VALUES (789, 2, 6758, decode('FDDACE21457376B0952CCD19CE66B854FDD7C6E45905B0A0A75747C87D41719A', 'hex'),
+		decode('A631BE6995643E6085330A31B9E1AF48DD5D6B7F', 'hex'));
+	INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx,
+		to_idx, amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce)
+	VALUES (false, decode('02E62B9E1D365B8F092F8D0B4DE8767930F43C3AF7A2CBF18A433FD9CF90A1DB17', 'hex'),
+		'Transfer', 0, 789, 789, 789, 1000000000000000000, 1000000000000000000, 2, 6758, 4417296, 126, 0);
+	`
+	_, err := db.Exec(queryInsert)
+	return err
+}
+
+func (m migrationTest0020) RunAssertsAfterMigrationUp(t *testing.T, db *sqlx.DB) {
+	// the existing tx has NULL offsets
+	var daOffset, daLength *int
+	row := db.QueryRow(`SELECT da_offset, da_length FROM tx WHERE position = 0;`)
+	require.NoError(t, row.Scan(&daOffset, &daLength))
+	assert.Nil(t, daOffset)
+	assert.Nil(t, daLength)
+	// a new tx can be inserted with its calldata byte offsets
+	_, err := db.Exec(`INSERT INTO tx (is_l1, id, type, position, from_idx, effective_from_idx,
+		to_idx, amount, amount_f, token_id, batch_num, eth_block_num, fee, nonce, da_offset, da_length)
+	VALUES (false, decode('02A8FC0E2B6A7733800BBA6A0212B6F29D9238566FD0EBA8F2C366C6FD6A4ECF66', 'hex'),
+		'Transfer', 1, 789, 789, 789, 1000000000000000000, 1000000000000000000, 2, 6758, 4417296, 126, 1, 548, 11);`)
+	require.NoError(t, err)
+	row = db.QueryRow(`SELECT da_offset, da_length FROM tx WHERE position = 1;`)
+	require.NoError(t, row.Scan(&daOffset, &daLength))
+	require.NotNil(t, daOffset)
+	require.NotNil(t, daLength)
+	assert.Equal(t, 548, *daOffset)
+	assert.Equal(t, 11, *daLength)
+}
+
+func (m migrationTest0020) RunAssertsAfterMigrationDown(t *testing.T, db *sqlx.DB) {
+	// the columns are gone but the txs remain
+	_, err := db.Exec(`SELECT da_offset FROM tx LIMIT 1;`)
+	assert.Error(t, err)
+	var count int
+	row := db.QueryRow(`SELECT COUNT(*) FROM tx;`)
+	require.NoError(t, row.Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestMigration0020(t *testing.T) {
+	runMigrationTest(t, 20, migrationTest0020{})
+}
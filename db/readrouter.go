@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// readRouterHealthInterval is the interval between health checks of
+	// the read replicas
+	readRouterHealthInterval = 10 * time.Second
+	// readRouterHealthTimeout is the timeout applied to each health check
+	// ping
+	readRouterHealthTimeout = 3 * time.Second
+)
+
+const (
+	// ReadPolicyRoundRobin distributes read queries among the healthy read
+	// replicas in rotating order
+	ReadPolicyRoundRobin = "round-robin"
+	// ReadPolicyLeastConnections sends each read query to the healthy read
+	// replica with the least connections currently in use
+	ReadPolicyLeastConnections = "least-connections"
+)
+
+// ReadRouter distributes read queries among a set of read replicas following
+// a routing policy.  Replicas are health checked periodically: the ones that
+// fail are taken out of rotation and put back automatically once they recover.
+// When no replica is healthy the queries fall back to the primary read
+// connection, so the router never leaves the caller without a connection.
+type ReadRouter struct {
+	primary  *sqlx.DB
+	replicas []*sqlx.DB
+	policy   string
+	next     uint64
+
+	mutex   sync.RWMutex
+	healthy []bool
+}
+
+// NewReadRouter creates a ReadRouter that distributes read queries among the
+// given replicas following the policy, falling back to primary when no
+// replica is available.  An empty policy defaults to round robin.
+func NewReadRouter(primary *sqlx.DB, replicas []*sqlx.DB, policy string) (*ReadRouter, error) {
+	if policy == "" {
+		policy = ReadPolicyRoundRobin
+	}
+	if policy != ReadPolicyRoundRobin && policy != ReadPolicyLeastConnections {
+		return nil, tracerr.Wrap(fmt.Errorf("unknown read policy %q", policy))
+	}
+	healthy := make([]bool, len(replicas))
+	for i := range healthy {
+		healthy[i] = true
+	}
+	return &ReadRouter{
+		primary:  primary,
+		replicas: replicas,
+		policy:   policy,
+		healthy:  healthy,
+	}, nil
+}
+
+// Read returns the connection where the next read query should be sent: a
+// healthy replica selected by the routing policy, or the primary when no
+// replica is healthy
+func (r *ReadRouter) Read() *sqlx.DB {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	candidates := make([]int, 0, len(r.replicas))
+	for i := range r.replicas {
+		if r.healthy[i] {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return r.primary
+	}
+	switch r.policy {
+	case ReadPolicyLeastConnections:
+		selected := candidates[0]
+		for _, i := range candidates[1:] {
+			if r.replicas[i].Stats().InUse < r.replicas[selected].Stats().InUse {
+				selected = i
+			}
+		}
+		return r.replicas[selected]
+	default: // ReadPolicyRoundRobin
+		n := atomic.AddUint64(&r.next, 1)
+		return r.replicas[candidates[n%uint64(len(candidates))]]
+	}
+}
+
+// healthCheck pings every replica, taking the ones that fail out of rotation
+// and putting the ones that recover back in
+func (r *ReadRouter) healthCheck(ctx context.Context) {
+	for i, replica := range r.replicas {
+		ctxTimeout, cancel := context.WithTimeout(ctx, readRouterHealthTimeout)
+		err := replica.PingContext(ctxTimeout)
+		cancel()
+		r.mutex.Lock()
+		if err != nil && r.healthy[i] {
+			log.Warnw("ReadRouter: replica out of rotation", "replica", i, "err", err)
+		} else if err == nil && !r.healthy[i] {
+			log.Infow("ReadRouter: replica back in rotation", "replica", i)
+		}
+		r.healthy[i] = err == nil
+		r.mutex.Unlock()
+	}
+}
+
+// Close closes the connections to all the replicas.  The primary connection
+// is shared with the caller and is not closed here
+func (r *ReadRouter) Close() {
+	for i, replica := range r.replicas {
+		if err := replica.Close(); err != nil {
+			log.Errorw("ReadRouter: closing replica", "replica", i, "err", err)
+		}
+	}
+}
+
+// Run health checks the replicas periodically until the context is canceled
+func (r *ReadRouter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readRouterHealthInterval):
+			r.healthCheck(ctx)
+		}
+	}
+}
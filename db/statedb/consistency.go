@@ -0,0 +1,120 @@
+package statedb
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-merkletree"
+	"github.com/iden3/go-merkletree/db/memory"
+)
+
+// ConsistencyDivergence describes an account leaf whose value stored in the
+// merkle tree doesn't match the value recomputed by hashing the account
+// stored alongside it.
+type ConsistencyDivergence struct {
+	Idx common.Idx
+	// TreeLeaf is the leaf value found in the merkle tree, nil if the
+	// tree has no leaf for the Idx
+	TreeLeaf *big.Int
+	// ComputedLeaf is the Poseidon hash recomputed from the stored
+	// account
+	ComputedLeaf *big.Int
+}
+
+// ConsistencyReport is the result of CheckConsistency
+type ConsistencyReport struct {
+	BatchNum  common.BatchNum
+	NAccounts int
+	// Root is the root of the merkle tree stored in the last checkpoint
+	Root *big.Int
+	// ComputedRoot is the root obtained by rebuilding the merkle tree
+	// from scratch out of the stored account leaves
+	ComputedRoot *big.Int
+	// ExpectedRoot is the state root to compare against (normally the
+	// one stored in the HistoryDB for BatchNum), nil if not provided
+	ExpectedRoot *big.Int
+	Divergences  []ConsistencyDivergence
+}
+
+// Ok returns true when the stored tree, the recomputed tree and the expected
+// root (if provided) all agree and no divergent leaves were found
+func (r *ConsistencyReport) Ok() bool {
+	if len(r.Divergences) != 0 {
+		return false
+	}
+	if r.Root.Cmp(r.ComputedRoot) != 0 {
+		return false
+	}
+	if r.ExpectedRoot != nil && r.ExpectedRoot.Cmp(r.Root) != 0 {
+		return false
+	}
+	return true
+}
+
+// CheckConsistency walks all the account leaves in the last checkpoint of the
+// StateDB, recomputes the merkle root from scratch and compares it against
+// the root stored in the checkpoint and against expectedRoot (pass nil to
+// skip this last comparison).  Accounts whose stored leaf doesn't match the
+// recomputed hash are reported as divergences.  This is an expensive
+// operation meant for debugging, it shouldn't be called in the hot path of
+// the node.
+func (s *StateDB) CheckConsistency(expectedRoot *big.Int) (*ConsistencyReport, error) {
+	if s.cfg.NLevels == 0 {
+		return nil, tracerr.Wrap(
+			errors.New("cannot check consistency of a StateDB without a MerkleTree"))
+	}
+	report := &ConsistencyReport{ExpectedRoot: expectedRoot}
+	if err := s.LastRead(func(sdb *Last) error {
+		batchNum, err := sdb.GetCurrentBatch()
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		report.BatchNum = batchNum
+		mt, err := merkletree.NewMerkleTree(sdb.DB().WithPrefix(PrefixKeyMT), s.cfg.NLevels)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		report.Root = mt.Root().BigInt()
+		recomputedMT, err := merkletree.NewMerkleTree(memory.NewMemoryStorage(), s.cfg.NLevels)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := accountsIter(sdb.DB(), func(acc *common.Account) (bool, error) {
+			report.NAccounts++
+			computedLeaf, err := acc.HashValue()
+			if err != nil {
+				return false, tracerr.Wrap(err)
+			}
+			if err := recomputedMT.Add(acc.Idx.BigInt(), computedLeaf); err != nil {
+				return false, tracerr.Wrap(err)
+			}
+			_, treeLeaf, _, err := mt.Get(acc.Idx.BigInt())
+			if tracerr.Unwrap(err) == merkletree.ErrKeyNotFound {
+				report.Divergences = append(report.Divergences, ConsistencyDivergence{
+					Idx:          acc.Idx,
+					ComputedLeaf: computedLeaf,
+				})
+				return true, nil
+			} else if err != nil {
+				return false, tracerr.Wrap(err)
+			}
+			if treeLeaf.Cmp(computedLeaf) != 0 {
+				report.Divergences = append(report.Divergences, ConsistencyDivergence{
+					Idx:          acc.Idx,
+					TreeLeaf:     treeLeaf,
+					ComputedLeaf: computedLeaf,
+				})
+			}
+			return true, nil
+		}); err != nil {
+			return tracerr.Wrap(err)
+		}
+		report.ComputedRoot = recomputedMT.Root().BigInt()
+		return nil
+	}); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return report, nil
+}
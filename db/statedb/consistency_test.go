@@ -0,0 +1,79 @@
+package statedb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmpdb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dir)
+
+	sdb, err := NewStateDB(Config{Path: dir, Keep: 128, Type: TypeSynchronizer, NLevels: 32})
+	require.NoError(t, err)
+
+	// create test accounts
+	var accounts []*common.Account
+	for i := 0; i < 4; i++ {
+		accounts = append(accounts, newAccount(t, i))
+	}
+	for i := 0; i < len(accounts); i++ {
+		_, err = sdb.CreateAccount(accounts[i].Idx, accounts[i])
+		require.NoError(t, err)
+	}
+	require.NoError(t, sdb.MakeCheckpoint())
+
+	root := sdb.MT.Root().BigInt()
+
+	// the recomputed root matches both the stored tree and the expected
+	// root, and there are no divergent leaves
+	report, err := sdb.CheckConsistency(root)
+	require.NoError(t, err)
+	assert.True(t, report.Ok())
+	assert.Equal(t, common.BatchNum(1), report.BatchNum)
+	assert.Equal(t, len(accounts), report.NAccounts)
+	assert.Equal(t, 0, report.Root.Cmp(report.ComputedRoot))
+	assert.Len(t, report.Divergences, 0)
+
+	// without an expected root the check still passes
+	report, err = sdb.CheckConsistency(nil)
+	require.NoError(t, err)
+	assert.True(t, report.Ok())
+
+	// a wrong expected root is reported as inconsistent
+	report, err = sdb.CheckConsistency(big.NewInt(1234))
+	require.NoError(t, err)
+	assert.False(t, report.Ok())
+	assert.Len(t, report.Divergences, 0)
+
+	// corrupt the stored account of one of the leaves without updating
+	// the merkle tree: the leaf must be reported as divergent
+	corrupted := *accounts[0]
+	corrupted.Balance = big.NewInt(999999)
+	v, err := corrupted.HashValue()
+	require.NoError(t, err)
+	accountBytes, err := corrupted.Bytes()
+	require.NoError(t, err)
+	idxBytes, err := corrupted.Idx.Bytes()
+	require.NoError(t, err)
+	tx, err := sdb.db.DB().NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx.Put(append(PrefixKeyAccHash, v.Bytes()...), accountBytes[:]))
+	require.NoError(t, tx.Put(append(PrefixKeyIdx, idxBytes[:]...), v.Bytes()))
+	require.NoError(t, tx.Commit())
+	require.NoError(t, sdb.MakeCheckpoint())
+
+	report, err = sdb.CheckConsistency(root)
+	require.NoError(t, err)
+	assert.False(t, report.Ok())
+	require.Len(t, report.Divergences, 1)
+	assert.Equal(t, corrupted.Idx, report.Divergences[0].Idx)
+	assert.Equal(t, 0, report.Divergences[0].ComputedLeaf.Cmp(v))
+	assert.NotEqual(t, 0, report.Divergences[0].TreeLeaf.Cmp(v))
+}
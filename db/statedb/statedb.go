@@ -11,7 +11,6 @@ import (
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-merkletree"
 	"github.com/iden3/go-merkletree/db"
-	"github.com/iden3/go-merkletree/db/pebble"
 )
 
 var (
@@ -69,9 +68,17 @@ type Config struct {
 	// Keep is the number of old checkpoints to keep.  If 0, all
 	// checkpoints are kept.
 	Keep int
+	// KeepEvery, when set along Keep, retains one checkpoint every
+	// KeepEvery batches beyond the Keep most recent ones as sparse
+	// history instead of deleting all of them.
+	KeepEvery int
 	// NoLast skips having an opened DB with a checkpoint to the last
 	// batchNum for thread-safe reads.
 	NoLast bool
+	// Backend selects the underlying key-value storage of the KVDB.  If
+	// empty, kvdb.BackendPebble is used.  kvdb.BackendMemory avoids temp
+	// directories and disk I/O, and is meant for tests and simulation.
+	Backend kvdb.StorageBackend
 	// Type of StateDB (
 	Type TypeStateDB
 	// NLevels is the number of merkle tree levels in case the Type uses a
@@ -133,7 +140,8 @@ func NewStateDB(cfg Config) (*StateDB, error) {
 	var err error
 
 	kv, err = kvdb.NewKVDB(kvdb.Config{Path: cfg.Path, Keep: cfg.Keep,
-		NoGapsCheck: cfg.noGapsCheck, NoLast: cfg.NoLast})
+		KeepEvery: cfg.KeepEvery, NoGapsCheck: cfg.noGapsCheck, NoLast: cfg.NoLast,
+		Backend: cfg.Backend})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -162,11 +170,16 @@ func (s *StateDB) Type() TypeStateDB {
 	return s.cfg.Type
 }
 
+// Backend returns the StateDB configured storage Backend
+func (s *StateDB) Backend() kvdb.StorageBackend {
+	return s.cfg.Backend
+}
+
 // LastRead is a thread-safe method to query the last checkpoint of the StateDB
 // via the Last type methods
 func (s *StateDB) LastRead(fn func(sdbLast *Last) error) error {
 	return s.db.LastRead(
-		func(db *pebble.Storage) error {
+		func(db db.Storage) error {
 			return fn(&Last{
 				db: db,
 			})
@@ -482,6 +495,31 @@ func (s *StateDB) MTGetProof(idx common.Idx) (*merkletree.CircomVerifierProof, e
 	return p, nil
 }
 
+// MTGetProofAtBatch returns the CircomVerifierProof of the account leaf at
+// the given Idx against the state root of the checkpoint of the given
+// batchNum.  It returns an error if the checkpoint of batchNum is no longer
+// kept in the StateDB.
+func (s *StateDB) MTGetProofAtBatch(batchNum common.BatchNum,
+	idx common.Idx) (*merkletree.CircomVerifierProof, error) {
+	if s.MT == nil {
+		return nil, tracerr.Wrap(ErrStateDBWithoutMT)
+	}
+	sto, err := s.db.OpenCheckpoint(batchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer sto.Close()
+	mt, err := merkletree.NewMerkleTree(sto.WithPrefix(PrefixKeyMT), s.cfg.NLevels)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	p, err := mt.GenerateSCVerifierProof(idx.BigInt(), mt.Root())
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return p, nil
+}
+
 // Close the StateDB
 func (s *StateDB) Close() {
 	s.db.Close()
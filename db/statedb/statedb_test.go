@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/kvdb"
 	"github.com/arnaubennassar/hermez-node/log"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	ethCrypto "github.com/ethereum/go-ethereum/crypto"
@@ -730,3 +731,61 @@ func TestResetFromBadCheckpoint(t *testing.T) {
 
 	sdb.Close()
 }
+
+// TestMemoryBackendParity checks that a StateDB over the memory backend
+// behaves like one over the default pebble backend: same merkle roots after
+// the same account operations, checkpoints and resets
+func TestMemoryBackendParity(t *testing.T) {
+	dirPebble, err := ioutil.TempDir("", "tmpdb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dirPebble)
+	dirMem, err := ioutil.TempDir("", "tmpdb")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dirMem)
+
+	sdbPebble, err := NewStateDB(Config{Path: dirPebble, Keep: 128,
+		Type: TypeSynchronizer, NLevels: 32})
+	require.NoError(t, err)
+	sdbMem, err := NewStateDB(Config{Path: dirMem, Keep: 128,
+		Type: TypeSynchronizer, NLevels: 32, Backend: kvdb.BackendMemory})
+	require.NoError(t, err)
+
+	// create the same accounts in both StateDBs
+	var accounts []*common.Account
+	for i := 0; i < 8; i++ {
+		accounts = append(accounts, newAccount(t, i))
+	}
+	for _, sdb := range []*StateDB{sdbPebble, sdbMem} {
+		for i := 0; i < 4; i++ {
+			_, err = sdb.CreateAccount(accounts[i].Idx, accounts[i])
+			require.NoError(t, err)
+		}
+		err = sdb.MakeCheckpoint()
+		require.NoError(t, err)
+		for i := 4; i < 8; i++ {
+			_, err = sdb.CreateAccount(accounts[i].Idx, accounts[i])
+			require.NoError(t, err)
+		}
+		err = sdb.MakeCheckpoint()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, sdbPebble.MT.Root(), sdbMem.MT.Root())
+
+	// reset both to the first checkpoint and check that the roots still
+	// match
+	err = sdbPebble.Reset(1)
+	require.NoError(t, err)
+	err = sdbMem.Reset(1)
+	require.NoError(t, err)
+	assert.Equal(t, sdbPebble.MT.Root(), sdbMem.MT.Root())
+	for i := 0; i < 4; i++ {
+		accPebble, err := sdbPebble.GetAccount(accounts[i].Idx)
+		require.NoError(t, err)
+		accMem, err := sdbMem.GetAccount(accounts[i].Idx)
+		require.NoError(t, err)
+		assert.Equal(t, accPebble, accMem)
+	}
+
+	sdbPebble.Close()
+	sdbMem.Close()
+}
@@ -0,0 +1,119 @@
+/*
+Package depositwatcher implements the L1 deposit watcher.
+
+Clients register the hash of an ethereum transaction that calls
+`addL1Transaction` through the API, and the watcher polls the registered
+hashes until the transaction is mined.  Once mined, the L1UserTx generated by
+the call is decoded from the L1UserTxEvent logs of the receipt, and its
+identification (TxID, L1UserTx queue and position inside the queue) is stored
+so that the API can report the queue position and the expected forging batch
+of the deposit.
+
+Following the design of the rest of the node, the watcher doesn't talk to the
+API directly: the registered hashes and the decoded results are exchanged
+through the l1_deposit_watch table of the SQL database.
+*/
+package depositwatcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/ethereum/go-ethereum"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hermeznetwork/tracerr"
+)
+
+const defaultInterval = 10 * time.Second
+
+// RollupTxDecoder decodes the L1UserTxs generated by an `addL1Transaction`
+// ethereum transaction, implemented by eth.RollupClient
+type RollupTxDecoder interface {
+	RollupL1UserTxsFromEthTxHash(ethTxHash ethCommon.Hash) ([]common.L1Tx,
+		*types.Receipt, error)
+}
+
+// Config is the configuration of the deposit Watcher
+type Config struct {
+	// Interval between scans of the pending watched transactions
+	Interval time.Duration
+}
+
+// Watcher polls the watched ethereum transactions until they are mined and
+// stores the L1UserTx they generate
+type Watcher struct {
+	hdb    *historydb.HistoryDB
+	rollup RollupTxDecoder
+	cfg    Config
+}
+
+// NewWatcher creates a Watcher that polls the transactions registered in the
+// given HistoryDB
+func NewWatcher(hdb *historydb.HistoryDB, rollup RollupTxDecoder, cfg Config) *Watcher {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	return &Watcher{
+		hdb:    hdb,
+		rollup: rollup,
+		cfg:    cfg,
+	}
+}
+
+// Run starts the watcher loop, polling the pending watched transactions
+// every Interval until the context is canceled
+func (w *Watcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Deposit watcher done")
+			return
+		case <-time.After(w.cfg.Interval):
+			if err := w.iteration(); err != nil {
+				log.Errorw("Deposit watcher iteration", "err", err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) iteration() error {
+	watches, err := w.hdb.GetPendingL1DepositWatches()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for i := range watches {
+		if err := w.check(&watches[i]); err != nil {
+			log.Errorw("Deposit watcher check",
+				"ethTxHash", watches[i].EthTxHash, "err", err)
+		}
+	}
+	return nil
+}
+
+// check queries the receipt of a watched transaction and, if it has been
+// mined, stores the decoded L1UserTx identification
+func (w *Watcher) check(watch *historydb.L1DepositWatch) error {
+	l1UserTxs, receipt, err := w.rollup.RollupL1UserTxsFromEthTxHash(watch.EthTxHash)
+	if tracerr.Unwrap(err) == ethereum.NotFound {
+		// Not mined yet
+		return nil
+	} else if err != nil {
+		return tracerr.Wrap(err)
+	}
+	blockNum := receipt.BlockNumber.Int64()
+	if receipt.Status == types.ReceiptStatusFailed || len(l1UserTxs) == 0 {
+		log.Infow("Deposit watcher: watched tx failed or didn't generate any L1UserTx",
+			"ethTxHash", watch.EthTxHash, "block", blockNum)
+		return tracerr.Wrap(w.hdb.SetL1DepositWatchFailed(watch.EthTxHash, blockNum))
+	}
+	l1UserTx := l1UserTxs[0]
+	log.Infow("Deposit watcher: watched tx included",
+		"ethTxHash", watch.EthTxHash, "block", blockNum, "txID", l1UserTx.TxID,
+		"toForgeL1TxsNum", *l1UserTx.ToForgeL1TxsNum, "position", l1UserTx.Position)
+	return tracerr.Wrap(w.hdb.SetL1DepositWatchIncluded(watch.EthTxHash, blockNum,
+		l1UserTx.TxID, *l1UserTx.ToForgeL1TxsNum, l1UserTx.Position))
+}
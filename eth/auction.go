@@ -266,7 +266,11 @@ type AuctionInterface interface {
 // Implementation
 //
 
-// AuctionClient is the implementation of the interface to the Auction Smart Contract in ethereum.
+// AuctionClient is the implementation of the interface to the Auction Smart
+// Contract in ethereum.  Besides the bidding and parameter setter/getter
+// calls, it decodes all the auction events (NewBid, NewSlotDeadline,
+// NewForgeAllocated, ...), which the synchronizer stores through the
+// HistoryDB in the bid, coordinator and auction_vars tables.
 type AuctionClient struct {
 	client      *EthereumClient
 	chainID     *big.Int
@@ -604,7 +608,7 @@ func (c *AuctionClient) AuctionBid(amount *big.Int, slot int64, bidAmount *big.I
 			tokenName := c.token.name
 			tokenAddr := c.token.address
 			digest, _ := createPermitDigest(tokenAddr, owner, spender, c.chainID,
-				amount, nonce, deadline, tokenName)
+				amount, nonce, deadline, tokenName, c.token.version)
 			signature, _ := c.client.ks.SignHash(*c.client.account, digest)
 			permit := createPermit(owner, spender, amount, deadline, digest, signature)
 			_slot := big.NewInt(slot)
@@ -631,7 +635,7 @@ func (c *AuctionClient) AuctionMultiBid(amount *big.Int, startingSlot, endingSlo
 			tokenName := c.token.name
 			tokenAddr := c.token.address
 			digest, _ := createPermitDigest(tokenAddr, owner, spender, c.chainID,
-				amount, nonce, deadline, tokenName)
+				amount, nonce, deadline, tokenName, c.token.version)
 			signature, _ := c.client.ks.SignHash(*c.client.account, digest)
 			permit := createPermit(owner, spender, amount, deadline, digest, signature)
 			_startingSlot := big.NewInt(startingSlot)
@@ -3,6 +3,7 @@ package eth
 import (
 	"fmt"
 
+	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/ethereum/go-ethereum/accounts"
 	ethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
 	ethCommon "github.com/ethereum/go-ethereum/common"
@@ -47,6 +48,11 @@ type Client struct {
 // RollupConfig is the configuration for the Rollup smart contract interface
 type RollupConfig struct {
 	Address ethCommon.Address
+	// Forks is the schedule of protocol upgrades of the smart contract,
+	// used to decode events and forgeBatch calldata with the tx
+	// serialization in force at each block.  Leave empty if the contract
+	// was never upgraded
+	Forks common.ForkConfig
 }
 
 // ClientConfig is the configuration of the Client
@@ -66,6 +72,7 @@ func NewClient(client *ethclient.Client, account *accounts.Account, ks *ethKeyst
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	rollupClient.forks = cfg.Rollup.Forks
 	auctionClient, err := NewAuctionClient(ethereumClient,
 		rollupClient.consts.HermezAuctionContract,
 		rollupClient.consts.TokenHEZ)
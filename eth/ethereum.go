@@ -2,8 +2,10 @@ package eth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
@@ -26,6 +28,12 @@ type ERC20Consts struct {
 	Decimals uint64
 }
 
+// ERC20MetadataReader is the interface to retrieve the metadata constants
+// (name, symbol, decimals) of an ERC20 token contract
+type ERC20MetadataReader interface {
+	EthERC20Consts(ethCommon.Address) (*ERC20Consts, error)
+}
+
 // EthereumInterface is the interface to Ethereum
 type EthereumInterface interface {
 	EthLastBlock() (int64, error)
@@ -33,6 +41,7 @@ type EthereumInterface interface {
 	EthBlockByNumber(context.Context, int64) (*common.Block, error)
 	EthAddress() (*ethCommon.Address, error)
 	EthTransactionReceipt(context.Context, ethCommon.Hash) (*types.Receipt, error)
+	EthTransactionByHash(context.Context, ethCommon.Hash) (*types.Transaction, bool, error)
 
 	EthERC20Consts(ethCommon.Address) (*ERC20Consts, error)
 	EthChainID() (*big.Int, error)
@@ -41,6 +50,7 @@ type EthereumInterface interface {
 	EthNonceAt(ctx context.Context, account ethCommon.Address, blockNumber *big.Int) (uint64, error)
 	EthSuggestGasPrice(ctx context.Context) (*big.Int, error)
 	EthKeyStore() *ethKeystore.KeyStore
+	EthTransactor() (*bind.TransactOpts, error)
 	EthCall(ctx context.Context, tx *types.Transaction, blockNum *big.Int) ([]byte, error)
 }
 
@@ -71,6 +81,7 @@ type EthereumClient struct {
 	chainID *big.Int
 	account *accounts.Account
 	ks      *ethKeystore.KeyStore
+	signer  Signer
 	config  *EthereumConfig
 	opts    *bind.CallOpts
 }
@@ -85,10 +96,15 @@ func NewEthereumClient(client *ethclient.Client, account *accounts.Account,
 			GasPriceDiv:  defaultGasPriceDiv,
 		}
 	}
+	var signer Signer
+	if account != nil {
+		signer = NewKeyStoreSigner(ks, *account)
+	}
 	c := &EthereumClient{
 		client:  client,
 		account: account,
 		ks:      ks,
+		signer:  signer,
 		config:  config,
 		opts:    newCallOpts(),
 	}
@@ -138,9 +154,22 @@ func (c *EthereumClient) EthKeyStore() *ethKeystore.KeyStore {
 	return c.ks
 }
 
+// SetSigner overrides the signer used for the authorized calls.  By default
+// the EthereumClient signs with the keystore key of the account it was created
+// with
+func (c *EthereumClient) SetSigner(signer Signer) {
+	c.signer = signer
+}
+
+// EthTransactor returns a bind.TransactOpts bound to the client signer,
+// without any gas parameter set
+func (c *EthereumClient) EthTransactor() (*bind.TransactOpts, error) {
+	return NewTransactorFromSigner(c.signer, c.chainID)
+}
+
 // NewAuth builds a new auth object to make a transaction
 func (c *EthereumClient) NewAuth() (*bind.TransactOpts, error) {
-	if c.account == nil {
+	if c.signer == nil {
 		return nil, tracerr.Wrap(ErrAccountNil)
 	}
 
@@ -153,7 +182,7 @@ func (c *EthereumClient) NewAuth() (*bind.TransactOpts, error) {
 	gasPrice.Add(gasPrice, inc)
 	log.Debugw("Transaction metadata", "gasPrice", gasPrice)
 
-	auth, err := bind.NewKeyStoreTransactorWithChainID(c.ks, *c.account, c.chainID)
+	auth, err := c.EthTransactor()
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -170,7 +199,7 @@ func (c *EthereumClient) NewAuth() (*bind.TransactOpts, error) {
 // call.
 func (c *EthereumClient) CallAuth(gasLimit uint64, fn func(*ethclient.Client, *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction,
 	error) {
-	if c.account == nil {
+	if c.signer == nil {
 		return nil, tracerr.Wrap(ErrAccountNil)
 	}
 
@@ -183,7 +212,7 @@ func (c *EthereumClient) CallAuth(gasLimit uint64, fn func(*ethclient.Client, *b
 	gasPrice.Add(gasPrice, inc)
 	log.Debugw("Transaction metadata", "gasPrice", gasPrice)
 
-	auth, err := bind.NewKeyStoreTransactorWithChainID(c.ks, *c.account, c.chainID)
+	auth, err := c.EthTransactor()
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -221,6 +250,13 @@ func (c *EthereumClient) EthTransactionReceipt(ctx context.Context,
 	return c.client.TransactionReceipt(ctx, txHash)
 }
 
+// EthTransactionByHash returns the transaction with the given txHash, and
+// whether it is pending
+func (c *EthereumClient) EthTransactionByHash(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Transaction, bool, error) {
+	return c.client.TransactionByHash(ctx, txHash)
+}
+
 // EthLastBlock returns the last block number in the blockchain
 func (c *EthereumClient) EthLastBlock() (int64, error) {
 	ctx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
@@ -259,7 +295,27 @@ func (c *EthereumClient) EthBlockByNumber(ctx context.Context, number int64) (*c
 	return b, nil
 }
 
+// isContractCallErr reports whether the error of a contract call comes from
+// the contract itself (execution revert, missing method, return data that
+// doesn't match the ABI) rather than from the transport, so that the caller
+// can fall back to a default value instead of retrying
+func isContractCallErr(err error) bool {
+	if errors.Is(err, bind.ErrNoCode) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "execution reverted") ||
+		strings.Contains(msg, "invalid opcode") ||
+		strings.Contains(msg, "abi: ")
+}
+
 // EthERC20Consts returns the constants defined for a particular ERC20 Token instance.
+// The metadata calls of the ERC20 standard are optional, so tokens that don't
+// implement some of them get sane fallbacks instead of an error: the short
+// token address as name and symbol, and 18 decimals, which is by far the most
+// common value.  The fallbacks only apply when the contract itself fails to
+// answer the call; transport errors are returned so that they can be retried
+// instead of persisting fabricated metadata.
 func (c *EthereumClient) EthERC20Consts(tokenAddress ethCommon.Address) (*ERC20Consts, error) {
 	// We use the HEZ token smart contract interfacehere because it's an
 	// ERC20, which allows us to access the standard ERC20 constants.
@@ -267,19 +323,26 @@ func (c *EthereumClient) EthERC20Consts(tokenAddress ethCommon.Address) (*ERC20C
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	shortAddr := tokenAddress.Hex()[:10]
 	name, err := instance.Name(c.opts)
-	if err != nil {
+	if err != nil && !isContractCallErr(err) {
 		return nil, tracerr.Wrap(err)
+	} else if err != nil {
+		name = shortAddr
 	}
 
 	symbol, err := instance.Symbol(c.opts)
-	if err != nil {
+	if err != nil && !isContractCallErr(err) {
 		return nil, tracerr.Wrap(err)
+	} else if err != nil {
+		symbol = shortAddr
 	}
 
 	decimals, err := instance.Decimals(c.opts)
-	if err != nil {
+	if err != nil && !isContractCallErr(err) {
 		return nil, tracerr.Wrap(err)
+	} else if err != nil {
+		decimals = 18
 	}
 	return &ERC20Consts{
 		Name:     name,
@@ -0,0 +1,108 @@
+package eth
+
+import (
+	"context"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/ethereum/go-ethereum"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// eventStreamRetryInterval is the waiting interval between attempts to
+// re-establish a dropped websocket log subscription
+const eventStreamRetryInterval = 10 * time.Second
+
+// RollupEventStream subscribes to the logs of a smart contract over a
+// websocket RPC endpoint via eth_subscribe and signals through the Notify
+// channel the number of every new block that contains logs of the contract.
+// It is used to wake up the synchronizer as soon as a relevant block appears
+// at the head of the chain instead of waiting for the next poll, reducing
+// head-of-chain latency.  The stream is only a hint: when the subscription
+// drops it is re-established in the background while the caller keeps
+// polling FilterLogs at its regular interval, so no events are ever missed.
+type RollupEventStream struct {
+	wsURL   string
+	address ethCommon.Address
+	notify  chan int64
+}
+
+// NewRollupEventStream creates a new RollupEventStream that will subscribe
+// to the logs of the contract at address via the websocket RPC endpoint at
+// wsURL
+func NewRollupEventStream(wsURL string, address ethCommon.Address) *RollupEventStream {
+	return &RollupEventStream{
+		wsURL:   wsURL,
+		address: address,
+		notify:  make(chan int64, 1),
+	}
+}
+
+// Notify returns the channel where the numbers of new blocks containing
+// logs of the contract are sent.  The channel has a single slot and
+// notifications are dropped when the receiver is not keeping up, as a
+// pending notification already forces a new sync iteration.
+func (s *RollupEventStream) Notify() <-chan int64 {
+	return s.notify
+}
+
+// Start runs the subscription loop in a new goroutine until ctx is
+// canceled, re-establishing the subscription with a delay every time it
+// drops
+func (s *RollupEventStream) Start(ctx context.Context) {
+	go func() {
+		for {
+			if err := s.subscribe(ctx); ctx.Err() != nil {
+				log.Info("RollupEventStream done")
+				return
+			} else if err != nil {
+				log.Warnw("RollupEventStream: subscription dropped, "+
+					"falling back to polling until it is re-established",
+					"err", err, "retryInterval", eventStreamRetryInterval)
+			}
+			select {
+			case <-ctx.Done():
+				log.Info("RollupEventStream done")
+				return
+			case <-time.After(eventStreamRetryInterval):
+			}
+		}
+	}()
+}
+
+// subscribe dials the websocket endpoint, subscribes to the logs of the
+// contract and forwards the block number of each received log until the
+// subscription fails or ctx is canceled
+func (s *RollupEventStream) subscribe(ctx context.Context) error {
+	client, err := ethclient.DialContext(ctx, s.wsURL)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer client.Close()
+	logsCh := make(chan types.Log)
+	sub, err := client.SubscribeFilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []ethCommon.Address{s.address},
+	}, logsCh)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer sub.Unsubscribe()
+	log.Infow("RollupEventStream: subscribed to contract logs",
+		"address", s.address, "wsURL", s.wsURL)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return tracerr.Wrap(err)
+		case vLog := <-logsCh:
+			select {
+			case s.notify <- int64(vLog.BlockNumber):
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package eth
+
+import (
+	"math/big"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// GovernanceCall is an unsigned contract call for a governance operation,
+// meant to be submitted through a multisig wallet (e.g. a Gnosis Safe)
+// instead of being executed directly with a loaded key via CallAuth
+type GovernanceCall struct {
+	// To is the address of the contract to call
+	To ethCommon.Address
+	// Value in wei to send with the call, always 0 for governance
+	// operations
+	Value *big.Int
+	// Data is the ABI encoded calldata of the call
+	Data []byte
+}
+
+// RollupUpdateForgeL1L2BatchTimeoutCall returns the unsigned calldata of an
+// `updateForgeL1L2BatchTimeout` call, to be executed by the governance
+func (c *RollupClient) RollupUpdateForgeL1L2BatchTimeoutCall(
+	newForgeL1L2BatchTimeout int64) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("updateForgeL1L2BatchTimeout",
+		uint8(newForgeL1L2BatchTimeout))
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// RollupUpdateFeeAddTokenCall returns the unsigned calldata of an
+// `updateFeeAddToken` call, to be executed by the governance
+func (c *RollupClient) RollupUpdateFeeAddTokenCall(
+	newFeeAddToken *big.Int) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("updateFeeAddToken", newFeeAddToken)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// RollupUpdateWithdrawalDelayCall returns the unsigned calldata of an
+// `updateWithdrawalDelay` call, to be executed by the governance
+func (c *RollupClient) RollupUpdateWithdrawalDelayCall(
+	newWithdrawalDelay int64) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("updateWithdrawalDelay", uint64(newWithdrawalDelay))
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetSlotDeadlineCall returns the unsigned calldata of a
+// `setSlotDeadline` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetSlotDeadlineCall(
+	newDeadline uint8) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setSlotDeadline", newDeadline)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetOpenAuctionSlotsCall returns the unsigned calldata of a
+// `setOpenAuctionSlots` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetOpenAuctionSlotsCall(
+	newOpenAuctionSlots uint16) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setOpenAuctionSlots", newOpenAuctionSlots)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetClosedAuctionSlotsCall returns the unsigned calldata of a
+// `setClosedAuctionSlots` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetClosedAuctionSlotsCall(
+	newClosedAuctionSlots uint16) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setClosedAuctionSlots", newClosedAuctionSlots)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetOutbiddingCall returns the unsigned calldata of a
+// `setOutbidding` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetOutbiddingCall(
+	newOutbidding uint16) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setOutbidding", newOutbidding)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetAllocationRatioCall returns the unsigned calldata of a
+// `setAllocationRatio` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetAllocationRatioCall(
+	newAllocationRatio [3]uint16) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setAllocationRatio", newAllocationRatio)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetDonationAddressCall returns the unsigned calldata of a
+// `setDonationAddress` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetDonationAddressCall(
+	newDonationAddress ethCommon.Address) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setDonationAddress", newDonationAddress)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
+
+// AuctionSetBootCoordinatorCall returns the unsigned calldata of a
+// `setBootCoordinator` call, to be executed by the governance
+func (c *AuctionClient) AuctionSetBootCoordinatorCall(newBootCoordinator ethCommon.Address,
+	newBootCoordinatorURL string) (*GovernanceCall, error) {
+	data, err := c.contractAbi.Pack("setBootCoordinator", newBootCoordinator,
+		newBootCoordinatorURL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &GovernanceCall{To: c.address, Value: big.NewInt(0), Data: data}, nil
+}
@@ -69,8 +69,12 @@ func addTime(seconds float64, url string) {
 	}()
 }
 
+// createPermitDigest builds the EIP-712 digest that the owner must sign to
+// authorize the spender via the EIP-2612 permit call.  The digest is computed
+// from the token's domain (name, version, chainID, address) and the current
+// permit nonce of the owner in the token contract.
 func createPermitDigest(tokenAddr, owner, spender ethCommon.Address, chainID, value, nonce,
-	deadline *big.Int, tokenName string) ([]byte, error) {
+	deadline *big.Int, tokenName, tokenVersion string) ([]byte, error) {
 	// NOTE: We ignore hash.Write errors because we are writing to a memory
 	// buffer and don't expect any errors to occur.
 	abiPermit :=
@@ -87,7 +91,7 @@ func createPermitDigest(tokenAddr, owner, spender ethCommon.Address, chainID, va
 	hashName.Write([]byte(tokenName)) //nolint:errcheck,gosec
 	paddedName := ethCommon.LeftPadBytes(hashName.Sum(nil), 32)
 	hashVersion := sha3.NewLegacyKeccak256()
-	hashVersion.Write([]byte("1")) //nolint:errcheck,gosec
+	hashVersion.Write([]byte(tokenVersion)) //nolint:errcheck,gosec
 	paddedX := ethCommon.LeftPadBytes(hashVersion.Sum(nil), 32)
 	paddedChainID := ethCommon.LeftPadBytes(chainID.Bytes(), 32)
 	paddedAddr := ethCommon.LeftPadBytes(tokenAddr.Bytes(), 32)
@@ -199,6 +199,11 @@ type RollupForgeBatchArgs struct {
 	L1CoordinatorTxsAuths [][]byte // Authorization for accountCreations for each L1CoordinatorTx
 	L2TxsData             []common.L2Tx
 	FeeIdxCoordinator     []common.Idx
+	// L2TxsDataOffset is the offset within the forgeBatch calldata of the
+	// encoded bytes of the first tx in L2TxsData, and L2TxBytesLen the
+	// length in bytes of each encoded tx
+	L2TxsDataOffset int
+	L2TxBytesLen    int
 	// Circuit selector
 	VerifierIdx uint8
 	L1Batch     bool
@@ -253,6 +258,7 @@ type RollupInterface interface {
 
 	// Viewers
 	RollupRegisterTokensCount() (*big.Int, error)
+	RollupTokenHEZBalance(addr ethCommon.Address) (*big.Int, error)
 	RollupLastForgedBatch() (int64, error)
 
 	//
@@ -261,6 +267,7 @@ type RollupInterface interface {
 
 	RollupConstants() (*common.RollupConstants, error)
 	RollupEventsByBlock(blockNum int64, blockHash *ethCommon.Hash) (*RollupEvents, error)
+	RollupEventsByBlockRange(fromBlock, toBlock int64) ([]RollupBlockEvents, error)
 	RollupForgeBatchArgs(ethCommon.Hash, uint16) (*RollupForgeBatchArgs, *ethCommon.Address, error)
 	RollupEventInit(genesisBlockNum int64) (*RollupEventInitialize, int64, error)
 }
@@ -276,9 +283,14 @@ type RollupClient struct {
 	address     ethCommon.Address
 	hermez      *Hermez.Hermez
 	token       *TokenClient
+	tokens      map[ethCommon.Address]*TokenClient
 	contractAbi abi.ABI
 	opts        *bind.CallOpts
 	consts      *common.RollupConstants
+	// forks is the protocol upgrade schedule of the smart contract, used
+	// to pick the tx serialization in force at each block.  A nil forks
+	// decodes everything with the current serialization
+	forks common.ForkConfig
 }
 
 // NewRollupClient creates a new RollupClient
@@ -300,6 +312,7 @@ func NewRollupClient(client *EthereumClient, address ethCommon.Address) (*Rollup
 		chainID:     chainID,
 		address:     address,
 		hermez:      hermez,
+		tokens:      make(map[ethCommon.Address]*TokenClient),
 		contractAbi: contractAbi,
 		opts:        newCallOpts(),
 	}
@@ -312,9 +325,28 @@ func NewRollupClient(client *EthereumClient, address ethCommon.Address) (*Rollup
 	if err != nil {
 		return nil, tracerr.Wrap(fmt.Errorf("new token client at %v: %w", address, err))
 	}
+	c.tokens[consts.TokenHEZ] = c.token
 	return c, nil
 }
 
+// tokenClient returns the TokenClient of the token registered in the Rollup
+// Smart Contract with the given tokenID, caching clients by token address.
+func (c *RollupClient) tokenClient(tokenID uint32) (*TokenClient, error) {
+	tokenAddr, err := c.hermez.TokenList(c.opts, big.NewInt(int64(tokenID)))
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if token, ok := c.tokens[tokenAddr]; ok {
+		return token, nil
+	}
+	token, err := NewTokenClient(c.client, tokenAddr)
+	if err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("new token client at %v: %w", tokenAddr, err))
+	}
+	c.tokens[tokenAddr] = token
+	return token, nil
+}
+
 // RollupForgeBatch is the interface to call the smart contract function
 func (c *RollupClient) RollupForgeBatch(args *RollupForgeBatchArgs, auth *bind.TransactOpts) (tx *types.Transaction, err error) {
 	if auth == nil {
@@ -407,7 +439,7 @@ func (c *RollupClient) RollupAddToken(tokenAddress ethCommon.Address, feeAddToke
 			tokenName := c.token.name
 			tokenAddr := c.token.address
 			digest, _ := createPermitDigest(tokenAddr, owner, spender, c.chainID,
-				feeAddToken, nonce, deadline, tokenName)
+				feeAddToken, nonce, deadline, tokenName, c.token.version)
 			signature, _ := c.client.ks.SignHash(*c.client.account, digest)
 			permit := createPermit(owner, spender, feeAddToken, deadline, digest,
 				signature)
@@ -512,16 +544,18 @@ func (c *RollupClient) RollupL1UserTxERC20Permit(fromBJJ babyjub.PublicKeyComp,
 			if tokenID == 0 {
 				auth.Value = depositAmount
 			}
+			token, err := c.tokenClient(tokenID)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
 			owner := c.client.account.Address
 			spender := c.address
-			nonce, err := c.token.hez.Nonces(c.opts, owner)
+			nonce, err := token.hez.Nonces(c.opts, owner)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
-			tokenName := c.token.name
-			tokenAddr := c.token.address
-			digest, _ := createPermitDigest(tokenAddr, owner, spender, c.chainID,
-				amount, nonce, deadline, tokenName)
+			digest, _ := createPermitDigest(token.address, owner, spender, c.chainID,
+				amount, nonce, deadline, token.name, token.version)
 			signature, _ := c.client.ks.SignHash(*c.client.account, digest)
 			permit := createPermit(owner, spender, amount, deadline, digest, signature)
 			return c.hermez.AddL1Transaction(auth, babyPubKey, fromIdxBig,
@@ -544,6 +578,19 @@ func (c *RollupClient) RollupRegisterTokensCount() (registerTokensCount *big.Int
 	return registerTokensCount, nil
 }
 
+// RollupTokenHEZBalance returns the HEZ balance of an address, used to check
+// that the feeAddToken can be paid before submitting an AddToken call
+func (c *RollupClient) RollupTokenHEZBalance(addr ethCommon.Address) (balance *big.Int,
+	err error) {
+	if err := c.client.Call(func(ec *ethclient.Client) error {
+		balance, err = c.token.hez.BalanceOf(c.opts, addr)
+		return tracerr.Wrap(err)
+	}); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return balance, nil
+}
+
 // RollupLastForgedBatch is the interface to call the smart contract function
 func (c *RollupClient) RollupLastForgedBatch() (lastForgedBatch int64, err error) {
 	if err := c.client.Call(func(ec *ethclient.Client) error {
@@ -763,6 +810,153 @@ func (c *RollupClient) RollupEventInit(genesisBlockNum int64) (*RollupEventIniti
 	return &rollupInit, int64(vLog.BlockNumber), tracerr.Wrap(err)
 }
 
+// parseRollupEventLog parses a single log emitted by the rollup smart
+// contract, appending the decoded event to rollupEvents
+func (c *RollupClient) parseRollupEventLog(vLog types.Log, rollupEvents *RollupEvents) error {
+	switch vLog.Topics[0] {
+	case logHermezL1UserTxEvent:
+		var L1UserTxAux rollupEventL1UserTxAux
+		var L1UserTx RollupEventL1UserTx
+		err := c.contractAbi.UnpackIntoInterface(&L1UserTxAux, "L1UserTxEvent", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		codec, err := c.forks.TxCodecAt(int64(vLog.BlockNumber))
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		L1Tx, err := codec.L1UserTxFromBytes(L1UserTxAux.L1UserTx)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		toForgeL1TxsNum := new(big.Int).SetBytes(vLog.Topics[1][:]).Int64()
+		L1Tx.ToForgeL1TxsNum = &toForgeL1TxsNum
+		L1Tx.Position = int(new(big.Int).SetBytes(vLog.Topics[2][:]).Int64())
+		L1Tx.UserOrigin = true
+		L1UserTx.L1UserTx = *L1Tx
+		rollupEvents.L1UserTx = append(rollupEvents.L1UserTx, L1UserTx)
+	case logHermezAddToken:
+		var addToken RollupEventAddToken
+		err := c.contractAbi.UnpackIntoInterface(&addToken, "AddToken", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		addToken.TokenAddress = ethCommon.BytesToAddress(vLog.Topics[1].Bytes())
+		rollupEvents.AddToken = append(rollupEvents.AddToken, addToken)
+	case logHermezForgeBatch:
+		var forgeBatch RollupEventForgeBatch
+		err := c.contractAbi.UnpackIntoInterface(&forgeBatch, "ForgeBatch", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		forgeBatch.BatchNum = new(big.Int).SetBytes(vLog.Topics[1][:]).Int64()
+		forgeBatch.EthTxHash = vLog.TxHash
+		// forgeBatch.Sender = vLog.Address
+		rollupEvents.ForgeBatch = append(rollupEvents.ForgeBatch, forgeBatch)
+	case logHermezUpdateForgeL1L2BatchTimeout:
+		var updateForgeL1L2BatchTimeout struct {
+			NewForgeL1L2BatchTimeout uint8
+		}
+		err := c.contractAbi.UnpackIntoInterface(&updateForgeL1L2BatchTimeout,
+			"UpdateForgeL1L2BatchTimeout", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		rollupEvents.UpdateForgeL1L2BatchTimeout = append(rollupEvents.UpdateForgeL1L2BatchTimeout,
+			RollupEventUpdateForgeL1L2BatchTimeout{
+				NewForgeL1L2BatchTimeout: int64(updateForgeL1L2BatchTimeout.NewForgeL1L2BatchTimeout),
+			})
+	case logHermezUpdateFeeAddToken:
+		var updateFeeAddToken RollupEventUpdateFeeAddToken
+		err := c.contractAbi.UnpackIntoInterface(&updateFeeAddToken, "UpdateFeeAddToken", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		rollupEvents.UpdateFeeAddToken = append(rollupEvents.UpdateFeeAddToken, updateFeeAddToken)
+	case logHermezWithdrawEvent:
+		var withdraw RollupEventWithdraw
+		withdraw.Idx = new(big.Int).SetBytes(vLog.Topics[1][:]).Uint64()
+		withdraw.NumExitRoot = new(big.Int).SetBytes(vLog.Topics[2][:]).Uint64()
+		instantWithdraw := new(big.Int).SetBytes(vLog.Topics[3][:]).Uint64()
+		if instantWithdraw == 1 {
+			withdraw.InstantWithdraw = true
+		}
+		withdraw.TxHash = vLog.TxHash
+		rollupEvents.Withdraw = append(rollupEvents.Withdraw, withdraw)
+	case logHermezUpdateBucketWithdraw:
+		var updateBucketWithdrawAux rollupEventUpdateBucketWithdrawAux
+		var updateBucketWithdraw RollupEventUpdateBucketWithdraw
+		err := c.contractAbi.UnpackIntoInterface(&updateBucketWithdrawAux,
+			"UpdateBucketWithdraw", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		updateBucketWithdraw.Withdrawals = updateBucketWithdrawAux.Withdrawals
+		updateBucketWithdraw.NumBucket = int(new(big.Int).SetBytes(vLog.Topics[1][:]).Int64())
+		updateBucketWithdraw.BlockStamp = new(big.Int).SetBytes(vLog.Topics[2][:]).Int64()
+		rollupEvents.UpdateBucketWithdraw =
+			append(rollupEvents.UpdateBucketWithdraw, updateBucketWithdraw)
+
+	case logHermezUpdateWithdrawalDelay:
+		var withdrawalDelay RollupEventUpdateWithdrawalDelay
+		err := c.contractAbi.UnpackIntoInterface(&withdrawalDelay, "UpdateWithdrawalDelay", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		rollupEvents.UpdateWithdrawalDelay = append(rollupEvents.UpdateWithdrawalDelay, withdrawalDelay)
+	case logHermezUpdateBucketsParameters:
+		var bucketsParametersAux rollupEventUpdateBucketsParametersAux
+		var bucketsParameters RollupEventUpdateBucketsParameters
+		err := c.contractAbi.UnpackIntoInterface(&bucketsParametersAux,
+			"UpdateBucketsParameters", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		bucketsParameters.ArrayBuckets = make([]RollupUpdateBucketsParameters, len(bucketsParametersAux.ArrayBuckets))
+		for i, bucket := range bucketsParametersAux.ArrayBuckets {
+			bucket, err := c.hermez.UnpackBucket(c.opts, bucket)
+			if err != nil {
+				return tracerr.Wrap(err)
+			}
+			bucketsParameters.ArrayBuckets[i].CeilUSD = bucket.CeilUSD
+			bucketsParameters.ArrayBuckets[i].BlockStamp = bucket.BlockStamp
+			bucketsParameters.ArrayBuckets[i].Withdrawals = bucket.Withdrawals
+			bucketsParameters.ArrayBuckets[i].RateBlocks = bucket.RateBlocks
+			bucketsParameters.ArrayBuckets[i].RateWithdrawals = bucket.RateWithdrawals
+			bucketsParameters.ArrayBuckets[i].MaxWithdrawals = bucket.MaxWithdrawals
+		}
+		rollupEvents.UpdateBucketsParameters =
+			append(rollupEvents.UpdateBucketsParameters, bucketsParameters)
+	case logHermezUpdateTokenExchange:
+		var tokensExchange RollupEventUpdateTokenExchange
+		err := c.contractAbi.UnpackIntoInterface(&tokensExchange, "UpdateTokenExchange", vLog.Data)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		rollupEvents.UpdateTokenExchange = append(rollupEvents.UpdateTokenExchange, tokensExchange)
+	case logHermezSafeMode:
+		var safeMode RollupEventSafeMode
+		rollupEvents.SafeMode = append(rollupEvents.SafeMode, safeMode)
+		// Also add an UpdateBucketsParameter with
+		// SafeMode=true to keep the order between `safeMode`
+		// and `UpdateBucketsParameters`
+		bucketsParameters := RollupEventUpdateBucketsParameters{
+			SafeMode: true,
+		}
+		for i := range bucketsParameters.ArrayBuckets {
+			bucketsParameters.ArrayBuckets[i].CeilUSD = big.NewInt(0)
+			bucketsParameters.ArrayBuckets[i].BlockStamp = big.NewInt(0)
+			bucketsParameters.ArrayBuckets[i].Withdrawals = big.NewInt(0)
+			bucketsParameters.ArrayBuckets[i].RateBlocks = big.NewInt(0)
+			bucketsParameters.ArrayBuckets[i].RateWithdrawals = big.NewInt(0)
+			bucketsParameters.ArrayBuckets[i].MaxWithdrawals = big.NewInt(0)
+		}
+		rollupEvents.UpdateBucketsParameters = append(rollupEvents.UpdateBucketsParameters,
+			bucketsParameters)
+	}
+	return nil
+}
+
 // RollupEventsByBlock returns the events in a block that happened in the
 // Rollup Smart Contract.
 // To query by blockNum, set blockNum >= 0 and blockHash == nil.
@@ -798,145 +992,116 @@ func (c *RollupClient) RollupEventsByBlock(blockNum int64,
 			log.Errorw("Block hash mismatch", "expected", blockHash.String(), "got", vLog.BlockHash.String())
 			return nil, tracerr.Wrap(ErrBlockHashMismatchEvent)
 		}
-		switch vLog.Topics[0] {
-		case logHermezL1UserTxEvent:
-			var L1UserTxAux rollupEventL1UserTxAux
-			var L1UserTx RollupEventL1UserTx
-			err := c.contractAbi.UnpackIntoInterface(&L1UserTxAux, "L1UserTxEvent", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			L1Tx, err := common.L1UserTxFromBytes(L1UserTxAux.L1UserTx)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			toForgeL1TxsNum := new(big.Int).SetBytes(vLog.Topics[1][:]).Int64()
-			L1Tx.ToForgeL1TxsNum = &toForgeL1TxsNum
-			L1Tx.Position = int(new(big.Int).SetBytes(vLog.Topics[2][:]).Int64())
-			L1Tx.UserOrigin = true
-			L1UserTx.L1UserTx = *L1Tx
-			rollupEvents.L1UserTx = append(rollupEvents.L1UserTx, L1UserTx)
-		case logHermezAddToken:
-			var addToken RollupEventAddToken
-			err := c.contractAbi.UnpackIntoInterface(&addToken, "AddToken", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			addToken.TokenAddress = ethCommon.BytesToAddress(vLog.Topics[1].Bytes())
-			rollupEvents.AddToken = append(rollupEvents.AddToken, addToken)
-		case logHermezForgeBatch:
-			var forgeBatch RollupEventForgeBatch
-			err := c.contractAbi.UnpackIntoInterface(&forgeBatch, "ForgeBatch", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			forgeBatch.BatchNum = new(big.Int).SetBytes(vLog.Topics[1][:]).Int64()
-			forgeBatch.EthTxHash = vLog.TxHash
-			// forgeBatch.Sender = vLog.Address
-			rollupEvents.ForgeBatch = append(rollupEvents.ForgeBatch, forgeBatch)
-		case logHermezUpdateForgeL1L2BatchTimeout:
-			var updateForgeL1L2BatchTimeout struct {
-				NewForgeL1L2BatchTimeout uint8
-			}
-			err := c.contractAbi.UnpackIntoInterface(&updateForgeL1L2BatchTimeout,
-				"UpdateForgeL1L2BatchTimeout", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			rollupEvents.UpdateForgeL1L2BatchTimeout = append(rollupEvents.UpdateForgeL1L2BatchTimeout,
-				RollupEventUpdateForgeL1L2BatchTimeout{
-					NewForgeL1L2BatchTimeout: int64(updateForgeL1L2BatchTimeout.NewForgeL1L2BatchTimeout),
-				})
-		case logHermezUpdateFeeAddToken:
-			var updateFeeAddToken RollupEventUpdateFeeAddToken
-			err := c.contractAbi.UnpackIntoInterface(&updateFeeAddToken, "UpdateFeeAddToken", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			rollupEvents.UpdateFeeAddToken = append(rollupEvents.UpdateFeeAddToken, updateFeeAddToken)
-		case logHermezWithdrawEvent:
-			var withdraw RollupEventWithdraw
-			withdraw.Idx = new(big.Int).SetBytes(vLog.Topics[1][:]).Uint64()
-			withdraw.NumExitRoot = new(big.Int).SetBytes(vLog.Topics[2][:]).Uint64()
-			instantWithdraw := new(big.Int).SetBytes(vLog.Topics[3][:]).Uint64()
-			if instantWithdraw == 1 {
-				withdraw.InstantWithdraw = true
-			}
-			withdraw.TxHash = vLog.TxHash
-			rollupEvents.Withdraw = append(rollupEvents.Withdraw, withdraw)
-		case logHermezUpdateBucketWithdraw:
-			var updateBucketWithdrawAux rollupEventUpdateBucketWithdrawAux
-			var updateBucketWithdraw RollupEventUpdateBucketWithdraw
-			err := c.contractAbi.UnpackIntoInterface(&updateBucketWithdrawAux,
-				"UpdateBucketWithdraw", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			updateBucketWithdraw.Withdrawals = updateBucketWithdrawAux.Withdrawals
-			updateBucketWithdraw.NumBucket = int(new(big.Int).SetBytes(vLog.Topics[1][:]).Int64())
-			updateBucketWithdraw.BlockStamp = new(big.Int).SetBytes(vLog.Topics[2][:]).Int64()
-			rollupEvents.UpdateBucketWithdraw =
-				append(rollupEvents.UpdateBucketWithdraw, updateBucketWithdraw)
-
-		case logHermezUpdateWithdrawalDelay:
-			var withdrawalDelay RollupEventUpdateWithdrawalDelay
-			err := c.contractAbi.UnpackIntoInterface(&withdrawalDelay, "UpdateWithdrawalDelay", vLog.Data)
-			if err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			rollupEvents.UpdateWithdrawalDelay = append(rollupEvents.UpdateWithdrawalDelay, withdrawalDelay)
-		case logHermezUpdateBucketsParameters:
-			var bucketsParametersAux rollupEventUpdateBucketsParametersAux
-			var bucketsParameters RollupEventUpdateBucketsParameters
-			err := c.contractAbi.UnpackIntoInterface(&bucketsParametersAux,
-				"UpdateBucketsParameters", vLog.Data)
+		if err := c.parseRollupEventLog(vLog, &rollupEvents); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+	return &rollupEvents, nil
+}
+
+// RollupBlockEvents are the rollup events of a single block, as returned by
+// RollupEventsByBlockRange
+type RollupBlockEvents struct {
+	BlockNum  int64
+	BlockHash ethCommon.Hash
+	Events    RollupEvents
+}
+
+// isProviderLimitErr returns true when err looks like an RPC provider
+// rejecting a log query for covering too many blocks or returning too much
+// data
+func isProviderLimitErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "query returned more than") ||
+		strings.Contains(msg, "response size") ||
+		strings.Contains(msg, "block range") ||
+		strings.Contains(msg, "too many")
+}
+
+// RollupEventsByBlockRange returns the events in the range of blocks
+// [fromBlock, toBlock] grouped per block in ascending order.  Blocks without
+// events are not included in the result.  Querying many blocks with a single
+// FilterLogs call avoids the one-call-per-block overhead during an initial
+// sync; when the provider rejects a query for exceeding its limits, the range
+// is split in halves and retried, effectively auto-tuning the chunk size to
+// the provider limits.
+func (c *RollupClient) RollupEventsByBlockRange(fromBlock, toBlock int64) ([]RollupBlockEvents, error) {
+	if fromBlock > toBlock {
+		return nil, tracerr.Wrap(fmt.Errorf("invalid block range: [%v, %v]", fromBlock, toBlock))
+	}
+	query := ethereum.FilterQuery{
+		FromBlock: big.NewInt(fromBlock),
+		ToBlock:   big.NewInt(toBlock),
+		Addresses: []ethCommon.Address{
+			c.address,
+		},
+		Topics: [][]ethCommon.Hash{},
+	}
+	logs, err := c.client.client.FilterLogs(context.Background(), query)
+	if err != nil {
+		if fromBlock < toBlock && isProviderLimitErr(err) {
+			mid := fromBlock + (toBlock-fromBlock)/2
+			left, err := c.RollupEventsByBlockRange(fromBlock, mid)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
-			bucketsParameters.ArrayBuckets = make([]RollupUpdateBucketsParameters, len(bucketsParametersAux.ArrayBuckets))
-			for i, bucket := range bucketsParametersAux.ArrayBuckets {
-				bucket, err := c.hermez.UnpackBucket(c.opts, bucket)
-				if err != nil {
-					return nil, tracerr.Wrap(err)
-				}
-				bucketsParameters.ArrayBuckets[i].CeilUSD = bucket.CeilUSD
-				bucketsParameters.ArrayBuckets[i].BlockStamp = bucket.BlockStamp
-				bucketsParameters.ArrayBuckets[i].Withdrawals = bucket.Withdrawals
-				bucketsParameters.ArrayBuckets[i].RateBlocks = bucket.RateBlocks
-				bucketsParameters.ArrayBuckets[i].RateWithdrawals = bucket.RateWithdrawals
-				bucketsParameters.ArrayBuckets[i].MaxWithdrawals = bucket.MaxWithdrawals
-			}
-			rollupEvents.UpdateBucketsParameters =
-				append(rollupEvents.UpdateBucketsParameters, bucketsParameters)
-		case logHermezUpdateTokenExchange:
-			var tokensExchange RollupEventUpdateTokenExchange
-			err := c.contractAbi.UnpackIntoInterface(&tokensExchange, "UpdateTokenExchange", vLog.Data)
+			right, err := c.RollupEventsByBlockRange(mid+1, toBlock)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
-			rollupEvents.UpdateTokenExchange = append(rollupEvents.UpdateTokenExchange, tokensExchange)
-		case logHermezSafeMode:
-			var safeMode RollupEventSafeMode
-			rollupEvents.SafeMode = append(rollupEvents.SafeMode, safeMode)
-			// Also add an UpdateBucketsParameter with
-			// SafeMode=true to keep the order between `safeMode`
-			// and `UpdateBucketsParameters`
-			bucketsParameters := RollupEventUpdateBucketsParameters{
-				SafeMode: true,
-			}
-			for i := range bucketsParameters.ArrayBuckets {
-				bucketsParameters.ArrayBuckets[i].CeilUSD = big.NewInt(0)
-				bucketsParameters.ArrayBuckets[i].BlockStamp = big.NewInt(0)
-				bucketsParameters.ArrayBuckets[i].Withdrawals = big.NewInt(0)
-				bucketsParameters.ArrayBuckets[i].RateBlocks = big.NewInt(0)
-				bucketsParameters.ArrayBuckets[i].RateWithdrawals = big.NewInt(0)
-				bucketsParameters.ArrayBuckets[i].MaxWithdrawals = big.NewInt(0)
-			}
-			rollupEvents.UpdateBucketsParameters = append(rollupEvents.UpdateBucketsParameters,
-				bucketsParameters)
+			return append(left, right...), nil
 		}
+		return nil, tracerr.Wrap(err)
 	}
-	return &rollupEvents, nil
+	var blockEvents []RollupBlockEvents
+	var current *RollupBlockEvents
+	for _, vLog := range logs {
+		blockNum := int64(vLog.BlockNumber)
+		if current == nil || current.BlockNum != blockNum {
+			blockEvents = append(blockEvents, RollupBlockEvents{
+				BlockNum:  blockNum,
+				BlockHash: vLog.BlockHash,
+			})
+			current = &blockEvents[len(blockEvents)-1]
+		}
+		if err := c.parseRollupEventLog(vLog, &current.Events); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+	return blockEvents, nil
+}
+
+// abiHeadSize returns the size in bytes that a value of the given type
+// occupies in the head of the ABI encoding of a method call.  Dynamic types
+// occupy a single word holding the offset of their content
+func abiHeadSize(t abi.Type) int {
+	if t.T == abi.ArrayTy {
+		return t.Size * abiHeadSize(*t.Elem)
+	}
+	return 32
+}
+
+// calldataArgOffset returns the offset within the calldata of the content of
+// the given dynamic argument, skipping the length word that precedes it
+func calldataArgOffset(method *abi.Method, txData []byte, argName string) (int, error) {
+	headOffset := 0
+	for _, input := range method.Inputs {
+		if input.Name != argName {
+			headOffset += abiHeadSize(input.Type)
+			continue
+		}
+		if len(txData) < 4+headOffset+32 {
+			return 0, tracerr.Wrap(fmt.Errorf(
+				"calldata too short to hold the offset of argument %v", argName))
+		}
+		tailOffset := new(big.Int).SetBytes(txData[4+headOffset : 4+headOffset+32])
+		if !tailOffset.IsInt64() {
+			return 0, tracerr.Wrap(fmt.Errorf(
+				"invalid offset of calldata argument %v", argName))
+		}
+		return 4 + int(tailOffset.Int64()) + 32, nil
+	}
+	return 0, tracerr.Wrap(fmt.Errorf("calldata argument %v not found", argName))
 }
 
 // RollupForgeBatchArgs returns the arguments used in a ForgeBatch call in the
@@ -982,19 +1147,34 @@ func (c *RollupClient) RollupForgeBatchArgs(ethTxHash ethCommon.Hash,
 		L2TxsData:             []common.L2Tx{},
 		FeeIdxCoordinator:     []common.Idx{},
 	}
+	// Decode the txs with the serialization in force at the block where
+	// the batch was forged
+	codec, err := c.forks.TxCodecAt(receipt.BlockNumber.Int64())
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
 	nLevels := c.consts.Verifiers[rollupForgeBatchArgs.VerifierIdx].NLevels
-	lenL1L2TxsBytes := int((nLevels/8)*2 + common.Float40BytesLength + 1) //nolint:gomnd
+	lenL1L2TxsBytes := codec.L1L2TxsDataAvailabilityBytesLen(uint32(nLevels))
 	numBytesL1TxUser := int(l1UserTxsLen) * lenL1L2TxsBytes
 	numTxsL1Coord := len(aux.EncodedL1CoordinatorTx) / common.RollupConstL1CoordinatorTotalBytes
 	numBytesL1TxCoord := numTxsL1Coord * lenL1L2TxsBytes
 	numBeginL2Tx := numBytesL1TxCoord + numBytesL1TxUser
+	// Record where the encoded bytes of the L2 txs live within the
+	// calldata, so that the inclusion of a tx can later be verified
+	// against the on-chain data
+	l1L2TxsDataOffset, err := calldataArgOffset(method, txData, "l1L2TxsData")
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	rollupForgeBatchArgs.L2TxsDataOffset = l1L2TxsDataOffset + numBeginL2Tx
+	rollupForgeBatchArgs.L2TxBytesLen = lenL1L2TxsBytes
 	l1UserTxsData := []byte{}
 	if l1UserTxsLen > 0 {
 		l1UserTxsData = aux.L1L2TxsData[:numBytesL1TxUser]
 	}
 	for i := 0; i < int(l1UserTxsLen); i++ {
 		l1Tx, err :=
-			common.L1TxFromDataAvailability(l1UserTxsData[i*lenL1L2TxsBytes:(i+1)*lenL1L2TxsBytes],
+			codec.L1TxFromDataAvailability(l1UserTxsData[i*lenL1L2TxsBytes:(i+1)*lenL1L2TxsBytes],
 				uint32(nLevels))
 		if err != nil {
 			return nil, nil, tracerr.Wrap(err)
@@ -1008,7 +1188,7 @@ func (c *RollupClient) RollupForgeBatchArgs(ethTxHash ethCommon.Hash,
 	numTxsL2 := len(l2TxsData) / lenL1L2TxsBytes
 	for i := 0; i < numTxsL2; i++ {
 		l2Tx, err :=
-			common.L2TxFromBytesDataAvailability(l2TxsData[i*lenL1L2TxsBytes:(i+1)*lenL1L2TxsBytes],
+			codec.L2TxFromBytesDataAvailability(l2TxsData[i*lenL1L2TxsBytes:(i+1)*lenL1L2TxsBytes],
 				int(nLevels))
 		if err != nil {
 			return nil, nil, tracerr.Wrap(err)
@@ -1057,3 +1237,46 @@ func (c *RollupClient) RollupForgeBatchArgs(ethTxHash ethCommon.Hash,
 	}
 	return &rollupForgeBatchArgs, &sender, nil
 }
+
+// RollupL1UserTxsFromEthTxHash returns the L1UserTxs registered by the
+// `addL1Transaction` call with the given transaction hash, decoded from the
+// L1UserTxEvent logs of its receipt, along with the receipt itself.  If the
+// transaction is not yet mined, the error `ethereum.NotFound` is returned.
+func (c *RollupClient) RollupL1UserTxsFromEthTxHash(ethTxHash ethCommon.Hash) ([]common.L1Tx,
+	*types.Receipt, error) {
+	receipt, err := c.client.client.TransactionReceipt(context.Background(), ethTxHash)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	var l1UserTxs []common.L1Tx
+	for _, vLog := range receipt.Logs {
+		if vLog.Address != c.address || len(vLog.Topics) == 0 ||
+			vLog.Topics[0] != logHermezL1UserTxEvent {
+			continue
+		}
+		var L1UserTxAux rollupEventL1UserTxAux
+		if err := c.contractAbi.UnpackIntoInterface(&L1UserTxAux, "L1UserTxEvent",
+			vLog.Data); err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		codec, err := c.forks.TxCodecAt(int64(vLog.BlockNumber))
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		l1Tx, err := codec.L1UserTxFromBytes(L1UserTxAux.L1UserTx)
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		toForgeL1TxsNum := new(big.Int).SetBytes(vLog.Topics[1][:]).Int64()
+		l1Tx.ToForgeL1TxsNum = &toForgeL1TxsNum
+		l1Tx.Position = int(new(big.Int).SetBytes(vLog.Topics[2][:]).Int64())
+		l1Tx.UserOrigin = true
+		l1Tx.EthBlockNum = int64(vLog.BlockNumber)
+		l1TxWithID, err := common.NewL1Tx(l1Tx)
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		l1UserTxs = append(l1UserTxs, *l1TxWithID)
+	}
+	return l1UserTxs, receipt, nil
+}
@@ -0,0 +1,226 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Signer abstracts the signing of the ethereum transactions sent in the
+// authorized calls, so that the forger private key can be kept either in a
+// local keystore or in a remote signing service
+type Signer interface {
+	// Address returns the address whose key the signer holds
+	Address() ethCommon.Address
+	// SignTx signs the given transaction
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// Health returns nil if the signer is currently able to sign
+	Health() error
+}
+
+// NewTransactorFromSigner builds a bind.TransactOpts that signs through the
+// given Signer, analogous to bind.NewKeyStoreTransactorWithChainID
+func NewTransactorFromSigner(signer Signer, chainID *big.Int) (*bind.TransactOpts, error) {
+	if signer == nil {
+		return nil, tracerr.Wrap(ErrAccountNil)
+	}
+	if chainID == nil {
+		return nil, tracerr.Wrap(bind.ErrNoChainID)
+	}
+	addr := signer.Address()
+	return &bind.TransactOpts{
+		From: addr,
+		Signer: func(address ethCommon.Address,
+			tx *types.Transaction) (*types.Transaction, error) {
+			if address != addr {
+				return nil, tracerr.Wrap(bind.ErrNotAuthorized)
+			}
+			return signer.SignTx(tx, chainID)
+		},
+		Context: context.Background(),
+	}, nil
+}
+
+// KeyStoreSigner signs transactions with a key kept in a local encrypted
+// keystore
+type KeyStoreSigner struct {
+	ks      *ethKeystore.KeyStore
+	account accounts.Account
+}
+
+// NewKeyStoreSigner creates a KeyStoreSigner that signs with the key of the
+// given account, which must be present (and unlocked) in the keystore
+func NewKeyStoreSigner(ks *ethKeystore.KeyStore, account accounts.Account) *KeyStoreSigner {
+	return &KeyStoreSigner{ks: ks, account: account}
+}
+
+// Address returns the address whose key the signer holds
+func (s *KeyStoreSigner) Address() ethCommon.Address {
+	return s.account.Address
+}
+
+// SignTx signs the given transaction with the keystore key
+func (s *KeyStoreSigner) SignTx(tx *types.Transaction,
+	chainID *big.Int) (*types.Transaction, error) {
+	if s.ks == nil {
+		return nil, tracerr.Wrap(ErrAccountNil)
+	}
+	signedTx, err := s.ks.SignTx(s.account, tx, chainID)
+	return signedTx, tracerr.Wrap(err)
+}
+
+// Health returns nil if the keystore holds the key of the signer account
+func (s *KeyStoreSigner) Health() error {
+	if s.ks == nil {
+		return tracerr.Wrap(ErrAccountNil)
+	}
+	if !s.ks.HasAddress(s.account.Address) {
+		return tracerr.Wrap(fmt.Errorf(
+			"ethereum keystore doesn't have the key for address %v", s.account.Address))
+	}
+	return nil
+}
+
+// remoteSignerTimeout is the timeout applied to every call to the remote
+// signer
+const remoteSignerTimeout = 10 * time.Second
+
+// RemoteSigner signs transactions through an external signing service that
+// exposes the eth_signTransaction JSON-RPC method (e.g. web3signer, clef with
+// the standard-http-rpc gateway), so the forger private key never resides on
+// the coordinator host
+type RemoteSigner struct {
+	client *rpc.Client
+	url    string
+	addr   ethCommon.Address
+}
+
+// NewRemoteSigner creates a RemoteSigner that signs with the key of addr held
+// by the signing service at url
+func NewRemoteSigner(url string, addr ethCommon.Address) (*RemoteSigner, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &RemoteSigner{client: client, url: url, addr: addr}, nil
+}
+
+// Address returns the address whose key the signer holds
+func (s *RemoteSigner) Address() ethCommon.Address {
+	return s.addr
+}
+
+// SignTx signs the given transaction calling eth_signTransaction on the
+// remote signing service
+func (s *RemoteSigner) SignTx(tx *types.Transaction,
+	chainID *big.Int) (*types.Transaction, error) {
+	args := struct {
+		From     ethCommon.Address  `json:"from"`
+		To       *ethCommon.Address `json:"to"`
+		Gas      hexutil.Uint64     `json:"gas"`
+		GasPrice *hexutil.Big       `json:"gasPrice"`
+		Value    *hexutil.Big       `json:"value"`
+		Nonce    hexutil.Uint64     `json:"nonce"`
+		Data     hexutil.Bytes      `json:"data"`
+		ChainID  *hexutil.Big       `json:"chainId"`
+	}{
+		From:     s.addr,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+		ChainID:  (*hexutil.Big)(chainID),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	var raw hexutil.Bytes
+	if err := s.client.CallContext(ctx, &raw, "eth_signTransaction", args); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(raw); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return signedTx, nil
+}
+
+// Health returns nil if the signing service is reachable and holds the key of
+// the signer address
+func (s *RemoteSigner) Health() error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	var addrs []ethCommon.Address
+	if err := s.client.CallContext(ctx, &addrs, "eth_accounts"); err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, addr := range addrs {
+		if addr == s.addr {
+			return nil
+		}
+	}
+	return tracerr.Wrap(fmt.Errorf(
+		"remote signer at %v doesn't have the key for address %v", s.url, s.addr))
+}
+
+// FailoverSigner signs with the primary signer while it's healthy, falling
+// back to the fallback signer when the primary is unreachable or fails to
+// sign.  Both signers must hold the key of the same address
+type FailoverSigner struct {
+	primary  Signer
+	fallback Signer
+}
+
+// NewFailoverSigner creates a FailoverSigner from a primary and a fallback
+// signer of the same address
+func NewFailoverSigner(primary, fallback Signer) (*FailoverSigner, error) {
+	if primary.Address() != fallback.Address() {
+		return nil, tracerr.Wrap(fmt.Errorf(
+			"failover signer address mismatch: primary %v, fallback %v",
+			primary.Address(), fallback.Address()))
+	}
+	return &FailoverSigner{primary: primary, fallback: fallback}, nil
+}
+
+// Address returns the address whose key the signers hold
+func (s *FailoverSigner) Address() ethCommon.Address {
+	return s.primary.Address()
+}
+
+// SignTx signs the given transaction with the primary signer, failing over to
+// the fallback signer if the primary is unhealthy or returns an error
+func (s *FailoverSigner) SignTx(tx *types.Transaction,
+	chainID *big.Int) (*types.Transaction, error) {
+	if err := s.primary.Health(); err != nil {
+		log.Warnw("Signer: primary signer unhealthy, failing over",
+			"addr", s.Address(), "err", err)
+		return s.fallback.SignTx(tx, chainID)
+	}
+	signedTx, err := s.primary.SignTx(tx, chainID)
+	if err != nil {
+		log.Warnw("Signer: primary signer failed to sign, failing over",
+			"addr", s.Address(), "err", err)
+		return s.fallback.SignTx(tx, chainID)
+	}
+	return signedTx, nil
+}
+
+// Health returns nil if any of the two signers is able to sign
+func (s *FailoverSigner) Health() error {
+	if err := s.primary.Health(); err == nil {
+		return nil
+	}
+	return s.fallback.Health()
+}
@@ -0,0 +1,105 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSigner struct {
+	addr      ethCommon.Address
+	healthErr error
+	signErr   error
+	signCalls int
+}
+
+func (s *testSigner) Address() ethCommon.Address { return s.addr }
+
+func (s *testSigner) SignTx(tx *types.Transaction,
+	chainID *big.Int) (*types.Transaction, error) {
+	s.signCalls++
+	if s.signErr != nil {
+		return nil, s.signErr
+	}
+	return tx, nil
+}
+
+func (s *testSigner) Health() error { return s.healthErr }
+
+func TestKeyStoreSigner(t *testing.T) {
+	dir := t.TempDir()
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount(password)
+	require.NoError(t, err)
+	require.NoError(t, ks.Unlock(account, password))
+
+	signer := NewKeyStoreSigner(ks, account)
+	assert.Equal(t, account.Address, signer.Address())
+	assert.NoError(t, signer.Health())
+
+	chainID := big.NewInt(1337)
+	tx := types.NewTransaction(0, ethCommon.Address{}, big.NewInt(0), 21000,
+		big.NewInt(1), nil)
+	signedTx, err := signer.SignTx(tx, chainID)
+	require.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	require.NoError(t, err)
+	assert.Equal(t, account.Address, sender)
+
+	// A signer of an address not present in the keystore is unhealthy
+	missingSigner := NewKeyStoreSigner(ks, accounts.Account{
+		Address: ethCommon.HexToAddress("0x0000000000000000000000000000000000000001"),
+	})
+	assert.Error(t, missingSigner.Health())
+}
+
+func TestFailoverSigner(t *testing.T) {
+	addr := ethCommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	tx := types.NewTransaction(0, ethCommon.Address{}, big.NewInt(0), 21000,
+		big.NewInt(1), nil)
+	chainID := big.NewInt(1337)
+
+	// Addresses must match
+	_, err := NewFailoverSigner(&testSigner{addr: addr}, &testSigner{
+		addr: ethCommon.HexToAddress("0x0000000000000000000000000000000000000002"),
+	})
+	assert.Error(t, err)
+
+	// Healthy primary signs
+	primary := &testSigner{addr: addr}
+	fallback := &testSigner{addr: addr}
+	signer, err := NewFailoverSigner(primary, fallback)
+	require.NoError(t, err)
+	_, err = signer.SignTx(tx, chainID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.signCalls)
+	assert.Equal(t, 0, fallback.signCalls)
+
+	// Unhealthy primary fails over without being called
+	primary = &testSigner{addr: addr, healthErr: fmt.Errorf("unreachable")}
+	fallback = &testSigner{addr: addr}
+	signer, err = NewFailoverSigner(primary, fallback)
+	require.NoError(t, err)
+	_, err = signer.SignTx(tx, chainID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, primary.signCalls)
+	assert.Equal(t, 1, fallback.signCalls)
+	assert.NoError(t, signer.Health())
+
+	// Signing error on the primary also fails over
+	primary = &testSigner{addr: addr, signErr: fmt.Errorf("sign failed")}
+	fallback = &testSigner{addr: addr}
+	signer, err = NewFailoverSigner(primary, fallback)
+	require.NoError(t, err)
+	_, err = signer.SignTx(tx, chainID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.signCalls)
+	assert.Equal(t, 1, fallback.signCalls)
+}
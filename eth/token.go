@@ -1,18 +1,34 @@
 package eth
 
 import (
+	"strings"
+
 	HEZ "github.com/arnaubennassar/hermez-node/eth/contracts/tokenHEZ"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
 )
 
-// TokenClient is the implementation of the interface to the Hez Token Smart Contract in ethereum.
+// tokenDefaultVersion is the EIP-712 domain version used by tokens that don't
+// expose a version getter (the HEZ token and most EIP-2612 implementations
+// use "1").
+const tokenDefaultVersion = "1"
+
+// tokenVersionABI is the fragment of the EIP-2612 interface needed to read
+// the EIP-712 domain version of the tokens that expose it.  It's not part of
+// the HEZ binding because the HEZ token only publishes the hash of its
+// version.
+const tokenVersionABI = `[{"constant":true,"inputs":[],"name":"version","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"}]`
+
+// TokenClient is the implementation of the interface to an EIP-2612
+// (permit-capable) Token Smart Contract in ethereum.
 type TokenClient struct {
 	client  *EthereumClient
 	hez     *HEZ.HEZ
 	address ethCommon.Address
 	name    string
+	version string
 	opts    *bind.CallOpts
 }
 
@@ -27,11 +43,45 @@ func NewTokenClient(client *EthereumClient, address ethCommon.Address) (*TokenCl
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	version, err := tokenVersion(client, address, opts)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
 	return &TokenClient{
 		client:  client,
 		hez:     hez,
 		address: address,
 		name:    name,
+		version: version,
 		opts:    opts,
 	}, nil
 }
+
+// tokenVersion reads the EIP-712 domain version of the token through its
+// version() getter, falling back to tokenDefaultVersion when the contract
+// doesn't expose it.  Transport errors are returned instead, so that a
+// transient failure doesn't cache a version that invalidates every permit of
+// a token whose domain version isn't "1".
+func tokenVersion(client *EthereumClient, address ethCommon.Address,
+	opts *bind.CallOpts) (string, error) {
+	versionABI, err := abi.JSON(strings.NewReader(tokenVersionABI))
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	contract := bind.NewBoundContract(address, versionABI, client.Client(), nil, nil)
+	var results []interface{}
+	if err := contract.Call(opts, &results, "version"); err != nil {
+		if isContractCallErr(err) {
+			return tokenDefaultVersion, nil
+		}
+		return "", tracerr.Wrap(err)
+	}
+	if len(results) != 1 {
+		return tokenDefaultVersion, nil
+	}
+	version, ok := results[0].(string)
+	if !ok || version == "" {
+		return tokenDefaultVersion, nil
+	}
+	return version, nil
+}
@@ -145,7 +145,10 @@ type WDelayerInterface interface {
 //
 
 // WDelayerClient is the implementation of the interface to the WithdrawDelayer
-// Smart Contract in ethereum.
+// Smart Contract in ethereum.  Its constants, variables and events (Deposit,
+// Withdraw, EscapeHatchWithdrawal, emergency mode and parameter updates) are
+// consumed by the synchronizer, which persists them in the HistoryDB so that
+// the exits API can expose the full lifecycle of delayed withdrawals.
 type WDelayerClient struct {
 	client      *EthereumClient
 	address     ethCommon.Address
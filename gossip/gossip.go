@@ -0,0 +1,115 @@
+/*
+Package gossip implements an optional federation subsystem between
+cooperating coordinators.  A coordinator forwards the valid PoolL2Txs
+received by its API to a configurable set of peer coordinators, so that the
+txs get forged by whichever coordinator wins the slot instead of waiting for
+the turn of the operator that originally received them.
+
+Txs are forwarded to the standard POST /v1/transactions-pool endpoint of
+each peer with the ForwardedHeader set, so that the receiving coordinator
+revalidates the signature as with any other received tx but doesn't forward
+it again, suppressing forwarding loops between peers.
+*/
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+)
+
+// ForwardedHeader is the HTTP header set on forwarded pool txs.  A
+// coordinator receiving a tx with this header doesn't forward it again
+const ForwardedHeader = "X-Hermez-Gossip"
+
+// poolTxsPath is the path of the pool txs endpoint of the peers
+const poolTxsPath = "/v1/transactions-pool"
+
+const (
+	// queueSize is the maximum number of txs waiting to be forwarded.
+	// New txs are dropped (not forwarded) when the queue is full
+	queueSize = 1024
+	// forwardTimeout is the timeout of each request to a peer
+	forwardTimeout = 10 * time.Second
+)
+
+// Gossiper forwards pool txs to a set of peer coordinators in the
+// background
+type Gossiper struct {
+	peers  []string
+	client *http.Client
+	queue  chan []byte
+}
+
+// NewGossiper creates a new Gossiper that will forward pool txs to the
+// given peer coordinator API base URLs
+func NewGossiper(peers []string) *Gossiper {
+	return &Gossiper{
+		peers:  peers,
+		client: &http.Client{Timeout: forwardTimeout},
+		queue:  make(chan []byte, queueSize),
+	}
+}
+
+// ForwardPoolTx enqueues the JSON encoded body of a received pool tx to be
+// forwarded to all the peers.  The call never blocks: when the queue is
+// full the tx is not forwarded
+func (g *Gossiper) ForwardPoolTx(body []byte) {
+	select {
+	case g.queue <- body:
+	default:
+		log.Warnw("Gossiper: forward queue full, tx not forwarded to peers",
+			"queueSize", queueSize)
+	}
+}
+
+// Start runs the forwarding loop in a new goroutine until ctx is canceled
+func (g *Gossiper) Start(ctx context.Context) {
+	log.Infow("Starting pool tx gossiper...", "peers", g.peers)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Gossiper done")
+				return
+			case body := <-g.queue:
+				g.forward(ctx, body)
+			}
+		}
+	}()
+}
+
+// forward sends the tx to every peer.  Errors are logged and don't stop the
+// forwarding to the remaining peers: a peer can legitimately reject a tx,
+// for example when it hasn't synced the account yet
+func (g *Gossiper) forward(ctx context.Context, body []byte) {
+	for _, peer := range g.peers {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			peer+poolTxsPath, bytes.NewReader(body))
+		if err != nil {
+			log.Errorw("Gossiper: cannot build request", "peer", peer, "err", err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(ForwardedHeader, "1")
+		res, err := g.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnw("Gossiper: cannot forward tx", "peer", peer, "err", err)
+			continue
+		}
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode >= http.StatusBadRequest {
+			log.Debugw("Gossiper: peer rejected forwarded tx",
+				"peer", peer, "status", res.StatusCode)
+		}
+	}
+}
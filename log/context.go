@@ -0,0 +1,75 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// ctxKey is the type of the keys used to store logging metadata in a
+// context.  It is unexported to avoid collisions with context keys defined
+// in other packages
+type ctxKey int
+
+const requestIDKey ctxKey = 0
+
+// NewRequestID returns a random correlation ID to be attached to a context
+// with WithRequestID.  Used by the API for per-request IDs and by the
+// coordinator for per-batch IDs
+func NewRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// The only effect of a failed read is a non-unique ID, and
+		// crypto/rand reads only fail on broken systems
+		return "0000000000000000"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithRequestID returns a copy of ctx carrying the given correlation ID.
+// The *wCtx logging functions attach it to every entry, so that all the logs
+// of a request or batch can be correlated across modules
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the correlation ID carried by ctx, "" when it carries
+// none
+func RequestID(ctx context.Context) string {
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok {
+		return requestID
+	}
+	return ""
+}
+
+// appendRequestIDMaybeKV appends the correlation ID carried by ctx to the KV
+func appendRequestIDMaybeKV(ctx context.Context, kv []interface{}) []interface{} {
+	if requestID := RequestID(ctx); requestID != "" {
+		return append(kv, "requestID", requestID)
+	}
+	return kv
+}
+
+// DebugwCtx calls log.Debugw attaching the correlation ID carried by ctx
+func DebugwCtx(ctx context.Context, template string, kv ...interface{}) {
+	log.Debugw(template, appendRequestIDMaybeKV(ctx, kv)...)
+}
+
+// InfowCtx calls log.Infow attaching the correlation ID carried by ctx
+func InfowCtx(ctx context.Context, template string, kv ...interface{}) {
+	log.Infow(template, appendRequestIDMaybeKV(ctx, kv)...)
+}
+
+// WarnwCtx calls log.Warnw attaching the correlation ID carried by ctx
+func WarnwCtx(ctx context.Context, template string, kv ...interface{}) {
+	kv = appendRequestIDMaybeKV(ctx, kv)
+	template = appendStackTraceMaybeKV(template, kv)
+	log.Warnw(template, kv...)
+}
+
+// ErrorwCtx calls log.Errorw attaching the correlation ID carried by ctx
+func ErrorwCtx(ctx context.Context, template string, kv ...interface{}) {
+	kv = appendRequestIDMaybeKV(ctx, kv)
+	template = appendStackTraceMaybeKV(template, kv)
+	log.Errorw(template, kv...)
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"testing"
 )
 
@@ -17,3 +18,17 @@ func TestLog(t *testing.T) {
 	Warnf("Test log.Warnf %d", 10)
 	Warnw("Test log.Warnw", "value", 10)
 }
+
+func TestLogCtx(t *testing.T) {
+	ctx := WithRequestID(context.Background(), NewRequestID())
+	if RequestID(ctx) == "" {
+		t.Fatal("expected a correlation ID in ctx")
+	}
+	if RequestID(context.Background()) != "" {
+		t.Fatal("expected no correlation ID in a fresh ctx")
+	}
+	InfowCtx(ctx, "Test log.InfowCtx", "value", 10)
+	DebugwCtx(ctx, "Test log.DebugwCtx", "value", 10)
+	WarnwCtx(ctx, "Test log.WarnwCtx", "value", 10)
+	ErrorwCtx(ctx, "Test log.ErrorwCtx", "value", 10)
+}
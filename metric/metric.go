@@ -124,6 +124,23 @@ var (
 			Name:      "discarded_l2_txs",
 			Help:      "",
 		})
+
+	// APIQueryCacheHits API queries served from the in-process query
+	// cache
+	APIQueryCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespaceAPI,
+			Name:      "query_cache_hits",
+			Help:      "",
+		})
+
+	// APIQueryCacheMisses API queries that had to hit the SQL database
+	APIQueryCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespaceAPI,
+			Name:      "query_cache_misses",
+			Help:      "",
+		})
 )
 
 func init() {
@@ -165,7 +182,13 @@ func registerCollectors() error {
 	if err := registerCollector(SelectedL1UserTxs); err != nil {
 		return err
 	}
-	return registerCollector(DiscardedL2Txs)
+	if err := registerCollector(DiscardedL2Txs); err != nil {
+		return err
+	}
+	if err := registerCollector(APIQueryCacheHits); err != nil {
+		return err
+	}
+	return registerCollector(APIQueryCacheMisses)
 }
 
 func registerCollector(collector prometheus.Collector) error {
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/config"
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// NetworkConfig couples the name under which a network is exposed with its
+// node configuration
+type NetworkConfig struct {
+	// Name is the network identifier, used as the API path prefix
+	// ("/<name>")
+	Name string
+	// Cfg is the node configuration of the network.  Each network must
+	// use its own PostgreSQL database and StateDB path, and set its own
+	// API.Address (e.g. distinct loopback ports) for its API to be
+	// reachable through the shared server.
+	Cfg *config.Node
+}
+
+// MultiNode runs one Node per configured network (e.g. the mainnet and
+// testnet contracts) in a single process.  Each network keeps its own
+// chainID, contract addresses, PostgreSQL database and StateDB, and all the
+// APIs are exposed through a shared server that routes requests by their
+// "/<network>" path prefix.
+type MultiNode struct {
+	nodes   []*Node
+	byName  map[string]*Node
+	apiAddr string
+	server  *http.Server
+	wg      sync.WaitGroup
+}
+
+// NewMultiNode creates a Node for every network and a shared API server
+// listening at apiAddr (if set) that exposes the API of each network under
+// its name as path prefix
+func NewMultiNode(mode Mode, networks []NetworkConfig, version,
+	apiAddr string) (*MultiNode, error) {
+	if len(networks) == 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("no networks configured"))
+	}
+	// Each network must have a unique name and its own storage, otherwise
+	// the nodes would corrupt each other's state
+	byName := make(map[string]*Node)
+	dbNames := make(map[string]string)
+	stateDBPaths := make(map[string]string)
+	for _, network := range networks {
+		if network.Name == "" {
+			return nil, tracerr.Wrap(fmt.Errorf("network with empty name"))
+		}
+		if _, ok := byName[network.Name]; ok {
+			return nil, tracerr.Wrap(fmt.Errorf("duplicated network name %q",
+				network.Name))
+		}
+		byName[network.Name] = nil
+		if prev, ok := dbNames[network.Cfg.PostgreSQL.NameWrite]; ok {
+			return nil, tracerr.Wrap(fmt.Errorf(
+				"networks %q and %q share the PostgreSQL database %q",
+				prev, network.Name, network.Cfg.PostgreSQL.NameWrite))
+		}
+		dbNames[network.Cfg.PostgreSQL.NameWrite] = network.Name
+		if prev, ok := stateDBPaths[network.Cfg.StateDB.Path]; ok {
+			return nil, tracerr.Wrap(fmt.Errorf(
+				"networks %q and %q share the StateDB path %q",
+				prev, network.Name, network.Cfg.StateDB.Path))
+		}
+		stateDBPaths[network.Cfg.StateDB.Path] = network.Name
+	}
+	m := &MultiNode{
+		byName:  byName,
+		apiAddr: apiAddr,
+	}
+	for _, network := range networks {
+		log.Infow("Starting network node", "network", network.Name)
+		innerNode, err := NewNode(mode, network.Cfg, version)
+		if err != nil {
+			return nil, tracerr.Wrap(fmt.Errorf("NewNode network %q: %w",
+				network.Name, err))
+		}
+		m.nodes = append(m.nodes, innerNode)
+		m.byName[network.Name] = innerNode
+	}
+	return m, nil
+}
+
+// handler routes each request to the API of the network selected by the
+// first path segment, which is stripped before forwarding
+func (m *MultiNode) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trimmedPath := strings.TrimPrefix(r.URL.Path, "/")
+		name, rest := trimmedPath, "/"
+		if i := strings.Index(trimmedPath, "/"); i != -1 {
+			name, rest = trimmedPath[:i], trimmedPath[i:]
+		}
+		innerNode, ok := m.byName[name]
+		if !ok || innerNode.nodeAPI == nil {
+			http.NotFound(w, r)
+			return
+		}
+		forwarded := r.Clone(r.Context())
+		forwarded.URL.Path = rest
+		innerNode.nodeAPI.engine.ServeHTTP(w, forwarded)
+	})
+}
+
+// Start starts all the network nodes and the shared API server
+func (m *MultiNode) Start() {
+	for _, innerNode := range m.nodes {
+		innerNode.Start()
+	}
+	if m.apiAddr == "" {
+		return
+	}
+	m.server = &http.Server{
+		Addr:    m.apiAddr,
+		Handler: m.handler(),
+		// TODO: Figure out best parameters for production
+		ReadTimeout:    30 * time.Second, //nolint:gomnd
+		WriteTimeout:   30 * time.Second, //nolint:gomnd
+		MaxHeaderBytes: 1 << 20,          //nolint:gomnd
+	}
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			log.Info("MultiNode API routine stopped")
+			m.wg.Done()
+		}()
+		log.Infof("MultiNode API is ready at %v", m.apiAddr)
+		if err := m.server.ListenAndServe(); err != nil &&
+			tracerr.Unwrap(err) != http.ErrServerClosed {
+			log.Fatalw("MultiNode API server", "err", err)
+		}
+	}()
+}
+
+// Stop stops the shared API server and all the network nodes
+func (m *MultiNode) Stop() {
+	if m.server != nil {
+		if err := m.server.Close(); err != nil {
+			log.Errorw("MultiNode API server close", "err", err)
+		}
+	}
+	m.wg.Wait()
+	for _, innerNode := range m.nodes {
+		innerNode.Stop()
+	}
+}
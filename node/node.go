@@ -25,24 +25,30 @@ import (
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/api"
+	grpcapi "github.com/arnaubennassar/hermez-node/api/grpc"
 	"github.com/arnaubennassar/hermez-node/api/stateapiupdater"
 	"github.com/arnaubennassar/hermez-node/batchbuilder"
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/config"
 	"github.com/arnaubennassar/hermez-node/coordinator"
+	"github.com/arnaubennassar/hermez-node/dataavailability"
 	dbUtils "github.com/arnaubennassar/hermez-node/db"
 	"github.com/arnaubennassar/hermez-node/db/historydb"
 	"github.com/arnaubennassar/hermez-node/db/l2db"
 	"github.com/arnaubennassar/hermez-node/db/statedb"
+	"github.com/arnaubennassar/hermez-node/depositwatcher"
 	"github.com/arnaubennassar/hermez-node/eth"
 	"github.com/arnaubennassar/hermez-node/etherscan"
+	"github.com/arnaubennassar/hermez-node/gossip"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/arnaubennassar/hermez-node/priceupdater"
 	"github.com/arnaubennassar/hermez-node/prover"
 	"github.com/arnaubennassar/hermez-node/synchronizer"
 	"github.com/arnaubennassar/hermez-node/test/debugapi"
+	"github.com/arnaubennassar/hermez-node/trace"
 	"github.com/arnaubennassar/hermez-node/txprocessor"
 	"github.com/arnaubennassar/hermez-node/txselector"
+	"github.com/arnaubennassar/hermez-node/webhook"
 	"github.com/ethereum/go-ethereum/accounts"
 	ethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
 	ethCommon "github.com/ethereum/go-ethereum/common"
@@ -52,49 +58,187 @@ import (
 	"github.com/hermeznetwork/tracerr"
 	"github.com/jmoiron/sqlx"
 	"github.com/russross/meddler"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
-// Mode sets the working mode of the node (synchronizer or coordinator)
+// Mode sets the working mode of the node (synchronizer, coordinator or full)
 type Mode string
 
 const (
 	// ModeCoordinator defines the mode of the HermezNode as Coordinator, which
 	// means that the node is set to forge (which also will be synchronizing with
-	// the L1 blockchain state)
+	// the L1 blockchain state).  The API only serves the coordinator endpoints
+	// unless the explorer endpoints are explicitly enabled in the configuration
 	ModeCoordinator Mode = "coordinator"
 
 	// ModeSynchronizer defines the mode of the HermezNode as Synchronizer, which
 	// means that the node is set to only synchronize with the L1 blockchain state
-	// and will not forge
+	// and will not forge.  None of the forging subsystems (TxSelector,
+	// BatchBuilder, prover clients) are started in this mode
 	ModeSynchronizer Mode = "synchronizer"
+
+	// ModeFull defines the mode of the HermezNode as Full, which runs the
+	// coordinator together with the full API (coordinator and explorer
+	// endpoints), regardless of the API endpoints enabled in the
+	// configuration
+	ModeFull Mode = "full"
 )
 
+// Forging returns true when the mode runs the forging subsystems of the
+// coordinator (TxSelector, BatchBuilder, prover clients)
+func (mode Mode) Forging() bool {
+	return mode == ModeCoordinator || mode == ModeFull
+}
+
+// validateModeConfig checks that the configuration is consistent with the
+// selected mode before any subsystem is wired
+func validateModeConfig(mode Mode, cfg *config.Node) error {
+	switch mode {
+	case ModeSynchronizer:
+		if cfg.API.SignedResponses {
+			return tracerr.Wrap(fmt.Errorf(
+				"API.SignedResponses requires the forger key, which is only " +
+					"available in coordinator or full mode"))
+		}
+	case ModeCoordinator:
+	case ModeFull:
+		if cfg.API.Address == "" {
+			return tracerr.Wrap(fmt.Errorf("full mode requires API.Address"))
+		}
+	default:
+		return tracerr.Wrap(fmt.Errorf("invalid mode \"%v\"", mode))
+	}
+	return nil
+}
+
 // Node is the Hermez Node
 type Node struct {
-	nodeAPI         *NodeAPI
+	nodeAPI *NodeAPI
+	// nodeGRPC serves the gRPC API alongside the REST one, nil when
+	// disabled
+	nodeGRPC        *NodeGRPC
 	stateAPIUpdater *stateapiupdater.Updater
 	debugAPI        *debugapi.DebugAPI
 	priceUpdater    *priceupdater.PriceUpdater
+	// webhookNotifier delivers the account activity webhooks, nil when
+	// disabled
+	webhookNotifier *webhook.Notifier
+	// depositWatcher maps watched ethereum txs to L1UserTxs, nil when
+	// disabled
+	depositWatcher *depositwatcher.Watcher
 	// Coordinator
 	coord *coordinator.Coordinator
 
 	// Synchronizer
 	sync *synchronizer.Synchronizer
+	// eventStream optionally signals new blocks with rollup events
+	// received over a websocket subscription, used to trigger sync
+	// iterations without waiting for the next poll
+	eventStream *eth.RollupEventStream
 
 	// General
 	cfg          *config.Node
+	cfgMutex     sync.RWMutex
 	mode         Mode
 	sqlConnRead  *sqlx.DB
 	sqlConnWrite *sqlx.DB
 	historyDB    *historydb.HistoryDB
+	readRouter   *dbUtils.ReadRouter
 	ctx          context.Context
 	wg           sync.WaitGroup
 	cancel       context.CancelFunc
+	// syncCtx is the context passed to the synchronizer iterations.  It is
+	// independent of ctx so that on shutdown the block being synchronized
+	// can complete, and is only canceled when the draining times out
+	syncCtx    context.Context
+	syncCancel context.CancelFunc
+}
+
+// newReadRouter opens the configured PostgreSQL read replicas and returns a
+// router that distributes read queries among them, or nil when no replica is
+// configured
+func newReadRouter(dbRead *sqlx.DB, cfg *config.PostgreSQL) (*dbUtils.ReadRouter, error) {
+	if len(cfg.ReadReplicas) == 0 {
+		return nil, nil
+	}
+	replicas := make([]*sqlx.DB, len(cfg.ReadReplicas))
+	for i, replica := range cfg.ReadReplicas {
+		conn, err := dbUtils.ConnectSQLDB(
+			replica.Port,
+			replica.Host,
+			replica.User,
+			replica.Password,
+			replica.Name,
+		)
+		if err != nil {
+			return nil, tracerr.Wrap(fmt.Errorf("dbUtils.ConnectSQLDB: %w", err))
+		}
+		replicas[i] = conn
+	}
+	return dbUtils.NewReadRouter(dbRead, replicas, cfg.ReadPolicy)
+}
+
+// tokenMinFeeMap converts the per-token minimum fee rules from the config
+// format to the map used by the L2DB
+func tokenMinFeeMap(rules []config.TokenMinFee) map[common.TokenID]common.FeeSelector {
+	tokenMinFee := make(map[common.TokenID]common.FeeSelector)
+	for _, rule := range rules {
+		tokenMinFee[common.TokenID(rule.TokenID)] = common.FeeSelector(rule.MinFeeSelector)
+	}
+	return tokenMinFee
+}
+
+// tokenMinFeesAPI converts the per-token minimum fee rules from the config
+// format to the representation exposed in the state API
+func tokenMinFeesAPI(rules []config.TokenMinFee) []historydb.TokenMinFeeAPI {
+	tokenMinFees := make([]historydb.TokenMinFeeAPI, len(rules))
+	for i, rule := range rules {
+		tokenMinFees[i] = historydb.TokenMinFeeAPI{
+			TokenID:        common.TokenID(rule.TokenID),
+			MinFeeSelector: rule.MinFeeSelector,
+		}
+	}
+	return tokenMinFees
+}
+
+// withdrawRelayerTokenLimits converts the withdrawal relayer per-token
+// safety limits from the config format to the coordinator representation
+func withdrawRelayerTokenLimits(
+	limits []config.WithdrawRelayerTokenLimit) []coordinator.WithdrawRelayerTokenLimit {
+	tokenLimits := make([]coordinator.WithdrawRelayerTokenLimit, len(limits))
+	for i, limit := range limits {
+		tokenLimits[i] = coordinator.WithdrawRelayerTokenLimit{
+			TokenID:   common.TokenID(limit.TokenID),
+			MaxAmount: limit.MaxAmount,
+			MinFee:    limit.MinFee,
+		}
+	}
+	return tokenLimits
+}
+
+// forkConfig converts the fork schedule from the config format to the
+// validated common.ForkConfig consumed by the eth client and the synchronizer
+func forkConfig(forks []config.Fork) (common.ForkConfig, error) {
+	schedule := make([]common.Fork, len(forks))
+	for i, fork := range forks {
+		schedule[i] = common.Fork{
+			BlockNum: fork.BlockNum,
+			TxCodec:  common.TxCodecVersion(fork.TxCodec),
+		}
+	}
+	return common.NewForkConfig(schedule)
 }
 
 // NewNode creates a Node
 func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
+	if err := validateModeConfig(mode, cfg); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
 	meddler.Debug = cfg.Debug.MeddlerLogs
+	if err := initTracing(cfg.Tracing); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
 	// Stablish DB connection
 	dbWrite, err := dbUtils.InitSQLDB(
 		cfg.PostgreSQL.PortWrite,
@@ -126,7 +270,7 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		}
 	}
 	var apiConnCon *dbUtils.APIConnectionController
-	if cfg.API.Explorer || mode == ModeCoordinator {
+	if cfg.API.Explorer || mode.Forging() {
 		apiConnCon = dbUtils.NewAPIConnectionController(
 			cfg.API.MaxSQLConnections,
 			cfg.API.SQLConnectionTimeout.Duration,
@@ -134,6 +278,23 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	}
 
 	historyDB := historydb.NewHistoryDB(dbRead, dbWrite, apiConnCon)
+	readRouter, err := newReadRouter(dbRead, &cfg.PostgreSQL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if readRouter != nil {
+		historyDB.SetReadRouter(readRouter)
+	}
+	if cfg.PriceUpdater.StalePriceWindow.Duration > 0 {
+		historyDB.SetStalePriceWindow(cfg.PriceUpdater.StalePriceWindow.Duration)
+	}
+	if cfg.API.Address != "" && !cfg.API.DisableQueryCache {
+		// The synchronizer runs in this process, so it invalidates
+		// the cached API queries on every synchronized block.  The
+		// standalone api mode can't rely on that, so the cache stays
+		// disabled there (see NewAPIServer)
+		historyDB.SetAPICacheEnabled(true)
+	}
 
 	ethClient, err := ethclient.Dial(cfg.Web3.URL)
 	if err != nil {
@@ -142,7 +303,8 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	var ethCfg eth.EthereumConfig
 	var account *accounts.Account
 	var keyStore *ethKeystore.KeyStore
-	if mode == ModeCoordinator {
+	var signer eth.Signer
+	if mode.Forging() {
 		ethCfg = eth.EthereumConfig{
 			CallGasLimit: 0, // cfg.Coordinator.EthClient.CallGasLimit,
 			GasPriceDiv:  0, // cfg.Coordinator.EthClient.GasPriceDiv,
@@ -175,8 +337,11 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		)
 
 		// Unlock Coordinator ForgerAddr in the keystore to make calls
-		// to ForgeBatch in the smart contract
-		if !keyStore.HasAddress(cfg.Coordinator.ForgerAddress) {
+		// to ForgeBatch in the smart contract.  The key is not
+		// required locally when a remote signer is configured
+		remoteSignerURL := cfg.Coordinator.EthClient.RemoteSigner.URL
+		hasLocalKey := keyStore.HasAddress(cfg.Coordinator.ForgerAddress)
+		if !hasLocalKey && remoteSignerURL == "" {
 			return nil, tracerr.Wrap(fmt.Errorf(
 				"ethereum keystore doesn't have the key for address %v",
 				cfg.Coordinator.ForgerAddress))
@@ -184,22 +349,55 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		account = &accounts.Account{
 			Address: cfg.Coordinator.ForgerAddress,
 		}
-		if err := keyStore.Unlock(*account,
-			cfg.Coordinator.EthClient.Keystore.Password); err != nil {
-			return nil, tracerr.Wrap(err)
+		if hasLocalKey {
+			if err := keyStore.Unlock(*account,
+				cfg.Coordinator.EthClient.Keystore.Password); err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			log.Infow("Forger ethereum account unlocked in the keystore",
+				"addr", cfg.Coordinator.ForgerAddress)
+		}
+		if remoteSignerURL != "" {
+			remoteSigner, err := eth.NewRemoteSigner(remoteSignerURL,
+				cfg.Coordinator.ForgerAddress)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			if err := remoteSigner.Health(); err != nil {
+				log.Warnw("Remote signer health check failed",
+					"url", remoteSignerURL, "err", err)
+			} else {
+				log.Infow("Forger ethereum account available in the remote signer",
+					"addr", cfg.Coordinator.ForgerAddress, "url", remoteSignerURL)
+			}
+			if hasLocalKey {
+				signer, err = eth.NewFailoverSigner(remoteSigner,
+					eth.NewKeyStoreSigner(keyStore, *account))
+				if err != nil {
+					return nil, tracerr.Wrap(err)
+				}
+			} else {
+				signer = remoteSigner
+			}
 		}
-		log.Infow("Forger ethereum account unlocked in the keystore",
-			"addr", cfg.Coordinator.ForgerAddress)
+	}
+	forks, err := forkConfig(cfg.Forks)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
 	}
 	client, err := eth.NewClient(ethClient, account, keyStore, &eth.ClientConfig{
 		Ethereum: ethCfg,
 		Rollup: eth.RollupConfig{
 			Address: cfg.SmartContracts.Rollup,
+			Forks:   forks,
 		},
 	})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	if signer != nil {
+		client.SetSigner(signer)
+	}
 
 	chainID, err := client.EthChainID()
 	if err != nil {
@@ -216,17 +414,18 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	chainIDU16 := uint16(chainIDU64)
 
 	stateDB, err := statedb.NewStateDB(statedb.Config{
-		Path:    cfg.StateDB.Path,
-		Keep:    cfg.StateDB.Keep,
-		Type:    statedb.TypeSynchronizer,
-		NLevels: statedb.MaxNLevels,
+		Path:      cfg.StateDB.Path,
+		Keep:      cfg.StateDB.Keep,
+		KeepEvery: cfg.StateDB.KeepEvery,
+		Type:      statedb.TypeSynchronizer,
+		NLevels:   statedb.MaxNLevels,
 	})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 
 	var l2DB *l2db.L2DB
-	if mode == ModeCoordinator {
+	if mode.Forging() {
 		l2DB = l2db.NewL2DB(
 			dbRead, dbWrite,
 			cfg.Coordinator.L2DB.SafetyPeriod,
@@ -236,12 +435,17 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 			cfg.Coordinator.L2DB.TTL.Duration,
 			apiConnCon,
 		)
+		l2DB.SetFeePolicy(l2db.FeePolicy{
+			TokenMinFee:             tokenMinFeeMap(cfg.Coordinator.L2DB.TokenMinFees),
+			FreeTxsPerAccountPerDay: cfg.Coordinator.L2DB.FreeTxsPerAccountPerDay,
+		})
 	}
 
 	sync, err := synchronizer.NewSynchronizer(client, historyDB, l2DB, stateDB, synchronizer.Config{
 		StatsUpdateBlockNumDiffThreshold: cfg.Synchronizer.StatsUpdateBlockNumDiffThreshold,
 		StatsUpdateFrequencyDivider:      cfg.Synchronizer.StatsUpdateFrequencyDivider,
 		ChainID:                          chainIDU16,
+		Forks:                            forks,
 	})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
@@ -255,10 +459,12 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	}
 
 	hdbNodeCfg := historydb.NodeConfig{
-		MaxPoolTxs: cfg.Coordinator.L2DB.MaxTxs,
-		MinFeeUSD:  cfg.Coordinator.L2DB.MinFeeUSD,
-		MaxFeeUSD:  cfg.Coordinator.L2DB.MaxFeeUSD,
-		ForgeDelay: cfg.Coordinator.ForgeDelay.Duration.Seconds(),
+		MaxPoolTxs:              cfg.Coordinator.L2DB.MaxTxs,
+		MinFeeUSD:               cfg.Coordinator.L2DB.MinFeeUSD,
+		MaxFeeUSD:               cfg.Coordinator.L2DB.MaxFeeUSD,
+		TokenMinFees:            tokenMinFeesAPI(cfg.Coordinator.L2DB.TokenMinFees),
+		FreeTxsPerAccountPerDay: cfg.Coordinator.L2DB.FreeTxsPerAccountPerDay,
+		ForgeDelay:              cfg.Coordinator.ForgeDelay.Duration.Seconds(),
 	}
 	if err := historyDB.SetNodeConfig(&hdbNodeCfg); err != nil {
 		return nil, tracerr.Wrap(err)
@@ -286,9 +492,15 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	if l2DB != nil {
+		// Include pool composition statistics in the state
+		stateAPIUpdater.SetL2DB(l2DB)
+	}
 
 	var coord *coordinator.Coordinator
-	if mode == ModeCoordinator {
+	var zkInputsArchive *coordinator.ZKInputsArchive
+	var serverProofs []prover.Client
+	if mode.Forging() {
 		// Unlock FeeAccount EthAddr in the keystore to generate the
 		// account creation authorization
 		if !keyStore.HasAddress(cfg.Coordinator.FeeAccount.Address) {
@@ -322,6 +534,19 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
+		if cfg.Coordinator.TxSelector.FeeIdxStrategy != "" {
+			tokenWhitelist := make([]common.TokenID,
+				len(cfg.Coordinator.TxSelector.FeeIdxTokenWhitelist))
+			for i, tokenID := range cfg.Coordinator.TxSelector.FeeIdxTokenWhitelist {
+				tokenWhitelist[i] = common.TokenID(tokenID)
+			}
+			if err := txSelector.SetFeeIdxConfig(txselector.FeeIdxConfig{
+				Strategy:       txselector.FeeIdxStrategy(cfg.Coordinator.TxSelector.FeeIdxStrategy),
+				TokenWhitelist: tokenWhitelist,
+			}); err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+		}
 		batchBuilder, err := batchbuilder.NewBatchBuilder(cfg.Coordinator.BatchBuilder.Path,
 			stateDB, 0, uint64(cfg.Coordinator.Circuit.NLevels))
 		if err != nil {
@@ -336,10 +561,13 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 			log.Info("EtherScan method not configured in config file")
 			etherScanService = nil
 		}
-		serverProofs := make([]prover.Client, len(cfg.Coordinator.ServerProofs))
+		serverProofs = make([]prover.Client, len(cfg.Coordinator.ServerProofs))
 		for i, serverProofCfg := range cfg.Coordinator.ServerProofs {
-			serverProofs[i] = prover.NewProofServerClient(serverProofCfg.URL,
+			serverProofs[i], err = prover.NewClient(serverProofCfg.URL,
 				cfg.Coordinator.ProofServerPollInterval.Duration)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
 		}
 
 		txProcessorCfg := txprocessor.Config{
@@ -381,6 +609,58 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 			}
 		}
 
+		proofVerifiers := make(map[uint8]*prover.VerifyingKey)
+		if cfg.Coordinator.Circuit.VerifyingKeyPath != "" {
+			vk, err := prover.LoadVerifyingKey(cfg.Coordinator.Circuit.VerifyingKeyPath)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			proofVerifiers[uint8(verifierIdx)] = vk
+		}
+		var verifiers []coordinator.VerifierCfg
+		if len(cfg.Coordinator.Circuit.AltVerifiers) > 0 {
+			verifiers = append(verifiers, coordinator.VerifierCfg{
+				Idx:          uint8(verifierIdx),
+				MaxTx:        cfg.Coordinator.Circuit.MaxTx,
+				NLevels:      cfg.Coordinator.Circuit.NLevels,
+				ProofCostUSD: cfg.Coordinator.Circuit.ProofCostUSD,
+			})
+			for _, alt := range cfg.Coordinator.Circuit.AltVerifiers {
+				altVerifierIdx, err := scConsts.Rollup.FindVerifierIdx(
+					alt.MaxTx,
+					cfg.Coordinator.Circuit.NLevels,
+				)
+				if err != nil {
+					return nil, tracerr.Wrap(err)
+				}
+				verifiers = append(verifiers, coordinator.VerifierCfg{
+					Idx:          uint8(altVerifierIdx),
+					MaxTx:        alt.MaxTx,
+					NLevels:      cfg.Coordinator.Circuit.NLevels,
+					ProofCostUSD: alt.ProofCostUSD,
+				})
+				if alt.VerifyingKeyPath != "" {
+					vk, err := prover.LoadVerifyingKey(alt.VerifyingKeyPath)
+					if err != nil {
+						return nil, tracerr.Wrap(err)
+					}
+					proofVerifiers[uint8(altVerifierIdx)] = vk
+				}
+			}
+			log.Infow("Forging with verifier selection among multiple circuits",
+				"verifiers", len(verifiers))
+		}
+
+		if cfg.Coordinator.ZKInputsArchive.Path != "" {
+			storage, err := coordinator.NewFSZKInputsStorage(
+				cfg.Coordinator.ZKInputsArchive.Path)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			zkInputsArchive = coordinator.NewZKInputsArchive(storage,
+				cfg.Coordinator.ZKInputsArchive.GZip,
+				cfg.Coordinator.ZKInputsArchive.Retention)
+		}
 		coord, err = coordinator.NewCoordinator(
 			coordinator.Config{
 				ForgerAddress:           cfg.Coordinator.ForgerAddress,
@@ -392,25 +672,52 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 				IgnoreSlotCommitment:    cfg.Coordinator.IgnoreSlotCommitment,
 				ForgeOncePerSlotIfTxs:   cfg.Coordinator.ForgeOncePerSlotIfTxs,
 				ForgeNoTxsDelay:         cfg.Coordinator.ForgeNoTxsDelay.Duration,
-				SyncRetryInterval:       cfg.Coordinator.SyncRetryInterval.Duration,
-				PurgeByExtDelInterval:   cfg.Coordinator.PurgeByExtDelInterval.Duration,
-				EthClientAttempts:       cfg.Coordinator.EthClient.Attempts,
-				EthClientAttemptsDelay:  cfg.Coordinator.EthClient.AttemptsDelay.Duration,
-				EthNoReuseNonce:         cfg.Coordinator.EthClient.NoReuseNonce,
-				EthTxResendTimeout:      cfg.Coordinator.EthClient.TxResendTimeout.Duration,
-				MaxGasPrice:             cfg.Coordinator.EthClient.MaxGasPrice,
-				GasPriceIncPerc:         cfg.Coordinator.EthClient.GasPriceIncPerc,
-				TxManagerCheckInterval:  cfg.Coordinator.EthClient.CheckLoopInterval.Duration,
-				DebugBatchPath:          cfg.Coordinator.Debug.BatchPath,
+				ForgePolicy: coordinator.ForgePolicyCfg{
+					Enabled:         cfg.Coordinator.ForgePolicy.Enabled,
+					MinPoolTxs:      cfg.Coordinator.ForgePolicy.MinPoolTxs,
+					MinFeesUSD:      cfg.Coordinator.ForgePolicy.MinFeesUSD,
+					L1TimeoutBlocks: cfg.Coordinator.ForgePolicy.L1TimeoutBlocks,
+					MaxInterval:     cfg.Coordinator.ForgePolicy.MaxInterval.Duration,
+				},
+				SyncRetryInterval:      cfg.Coordinator.SyncRetryInterval.Duration,
+				PurgeByExtDelInterval:  cfg.Coordinator.PurgeByExtDelInterval.Duration,
+				EthClientAttempts:      cfg.Coordinator.EthClient.Attempts,
+				EthClientAttemptsDelay: cfg.Coordinator.EthClient.AttemptsDelay.Duration,
+				EthNoReuseNonce:        cfg.Coordinator.EthClient.NoReuseNonce,
+				EthTxResendTimeout:     cfg.Coordinator.EthClient.TxResendTimeout.Duration,
+				MaxGasPrice:            cfg.Coordinator.EthClient.MaxGasPrice,
+				GasPriceIncPerc:        cfg.Coordinator.EthClient.GasPriceIncPerc,
+				TxManagerCheckInterval: cfg.Coordinator.EthClient.CheckLoopInterval.Duration,
+				DebugBatchPath:         cfg.Coordinator.Debug.BatchPath,
+				ZKInputsArchive:        zkInputsArchive,
+				ForcedExits: coordinator.ForcedExitsCfg{
+					Enabled:             cfg.Coordinator.ForcedExits.Enabled,
+					MaxFailedSelections: cfg.Coordinator.ForcedExits.MaxFailedSelections,
+					MaxPerScan:          cfg.Coordinator.ForcedExits.MaxPerScan,
+				},
+				WithdrawRelayer: coordinator.WithdrawRelayerCfg{
+					Enabled:     cfg.Coordinator.WithdrawRelayer.Enabled,
+					Interval:    cfg.Coordinator.WithdrawRelayer.Interval.Duration,
+					TokenLimits: withdrawRelayerTokenLimits(cfg.Coordinator.WithdrawRelayer.TokenLimits),
+				},
 				Purger: coordinator.PurgerCfg{
 					PurgeBatchDelay:      cfg.Coordinator.L2DB.PurgeBatchDelay,
 					InvalidateBatchDelay: cfg.Coordinator.L2DB.InvalidateBatchDelay,
 					PurgeBlockDelay:      cfg.Coordinator.L2DB.PurgeBlockDelay,
 					InvalidateBlockDelay: cfg.Coordinator.L2DB.InvalidateBlockDelay,
 				},
+				WarmStartPool:     cfg.Coordinator.L2DB.WarmStart,
 				ForgeBatchGasCost: cfg.Coordinator.EthClient.ForgeBatchGasCost,
 				VerifierIdx:       uint8(verifierIdx),
+				Verifiers:         verifiers,
+				ProofVerifiers:    proofVerifiers,
 				TxProcessorConfig: txProcessorCfg,
+				HA: coordinator.HACfg{
+					Enabled:           cfg.Coordinator.HA.Enabled,
+					HolderID:          cfg.Coordinator.HA.HolderID,
+					LeaseTTL:          cfg.Coordinator.HA.LeaseTTL.Duration,
+					HeartbeatInterval: cfg.Coordinator.HA.HeartbeatInterval.Duration,
+				},
 			},
 			historyDB,
 			l2DB,
@@ -427,6 +734,7 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		}
 	}
 	var nodeAPI *NodeAPI
+	var nodeGRPC *NodeGRPC
 	if cfg.API.Address != "" {
 		if cfg.Debug.GinDebugMode {
 			gin.SetMode(gin.DebugMode)
@@ -435,27 +743,77 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 		}
 		server := gin.Default()
 		coord := false
-		if mode == ModeCoordinator {
+		if mode.Forging() {
 			coord = cfg.Coordinator.API.Coordinator
 		}
+		explorer := cfg.API.Explorer
+		if mode == ModeFull {
+			// Full mode always serves the whole API
+			coord = true
+			explorer = true
+		}
 		var err error
 		nodeAPI, err = NewNodeAPI(
 			version,
 			cfg.API.Address,
-			coord, cfg.API.Explorer,
+			coord, explorer,
 			server,
 			historyDB,
 			l2DB,
 			ethClient,
 			&cfg.Coordinator.ForgerAddress,
+			&cfg.API.CORS,
+			&cfg.API.Compression,
 		)
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
+		if auth := newAPIAuth(&cfg.API.Auth); auth != nil {
+			nodeAPI.api.SetAuth(auth)
+		}
+		nodeAPI.api.SetStalenessPolicy(cfg.API.StalenessThreshold,
+			cfg.API.RejectStalePoolTxs)
+		// The API runs along the synchronizer, so its StateDB
+		// checkpoints can back the account state proof endpoint
+		nodeAPI.api.SetStateProofProvider(stateDB)
+		// The eth client is available, so the on-chain forgeBatch
+		// calldata can back the batch data availability endpoint
+		nodeAPI.api.SetDataAvailabilityProvider(
+			dataavailability.NewDecoder(historyDB, client))
+		// Report in the readiness endpoint the dependencies that the
+		// API can't reach through the database
+		nodeAPI.api.AddReadinessProbe("stateDB", func(ctx context.Context) error {
+			_, err := stateDB.LastGetCurrentBatch()
+			return err
+		})
+		for i, serverProof := range serverProofs {
+			nodeAPI.api.AddReadinessProbe(fmt.Sprintf("prover-%v", i),
+				serverProof.Healthy)
+		}
+		if mode.Forging() && cfg.API.SignedResponses {
+			// the forger account is already unlocked in the
+			// keystore at this point
+			forger := accounts.Account{Address: cfg.Coordinator.ForgerAddress}
+			nodeAPI.api.SetResponseSigner(api.NewResponseSigner(
+				cfg.Coordinator.ForgerAddress,
+				func(hash []byte) ([]byte, error) {
+					return keyStore.SignHash(forger, hash)
+				}))
+		}
+		if cfg.API.GRPCAddress != "" {
+			// The gRPC API queries the DBs through the same *API
+			// methods as the REST handlers, so both surfaces share
+			// the API connection limiter
+			nodeGRPC = NewNodeGRPC(cfg.API.GRPCAddress, historyDB, l2DB,
+				nodeAPI.api)
+		}
 	}
 	var debugAPI *debugapi.DebugAPI
 	if cfg.Debug.APIAddress != "" {
 		debugAPI = debugapi.NewDebugAPI(cfg.Debug.APIAddress, stateDB, sync)
+		if zkInputsArchive != nil {
+			debugAPI.SetZKInputsGetter(zkInputsArchive)
+		}
 	}
 	priceUpdater, err := priceupdater.NewPriceUpdater(
 		cfg.PriceUpdater.Priority,
@@ -467,36 +825,85 @@ func NewNode(mode Mode, cfg *config.Node, version string) (*Node, error) {
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	var webhookNotifier *webhook.Notifier
+	if cfg.Webhooks.Interval.Duration > 0 {
+		webhookNotifier = webhook.NewNotifier(historyDB, l2DB, webhook.Config{
+			Interval:      cfg.Webhooks.Interval.Duration,
+			Timeout:       cfg.Webhooks.Timeout.Duration,
+			MaxAttempts:   cfg.Webhooks.MaxAttempts,
+			RetryInterval: cfg.Webhooks.RetryInterval.Duration,
+		})
+	}
+	var depositWatcher *depositwatcher.Watcher
+	if cfg.DepositWatcher.Interval.Duration > 0 {
+		depositWatcher = depositwatcher.NewWatcher(historyDB, &client.RollupClient,
+			depositwatcher.Config{
+				Interval: cfg.DepositWatcher.Interval.Duration,
+			})
+	}
 	ctx, cancel := context.WithCancel(context.Background())
+	syncCtx, syncCancel := context.WithCancel(context.Background())
+	if nodeAPI != nil && mode.Forging() &&
+		len(cfg.Coordinator.Gossip.Peers) > 0 {
+		gossiper := gossip.NewGossiper(cfg.Coordinator.Gossip.Peers)
+		gossiper.Start(ctx)
+		nodeAPI.api.SetPoolTxGossiper(gossiper)
+	}
+	if nodeAPI != nil && mode.Forging() {
+		// The Coordinator fee account of the TxSelector can be
+		// rotated through the admin API
+		nodeAPI.api.SetCoordAccountRotator(coord)
+		// Account Idx reservations are held by the TxSelector
+		nodeAPI.api.SetIdxReserver(coord)
+		// New tokens can be registered in the Rollup smart contract
+		// through the admin API
+		nodeAPI.api.SetTokenRegistrar(coord)
+	}
+	var eventStream *eth.RollupEventStream
+	if cfg.Web3.WSURL != "" {
+		eventStream = eth.NewRollupEventStream(cfg.Web3.WSURL,
+			cfg.SmartContracts.Rollup)
+	}
 	return &Node{
 		stateAPIUpdater: stateAPIUpdater,
 		nodeAPI:         nodeAPI,
+		nodeGRPC:        nodeGRPC,
 		debugAPI:        debugAPI,
 		priceUpdater:    priceUpdater,
+		webhookNotifier: webhookNotifier,
+		depositWatcher:  depositWatcher,
 		coord:           coord,
 		sync:            sync,
+		eventStream:     eventStream,
 		cfg:             cfg,
 		mode:            mode,
 		sqlConnRead:     dbRead,
 		sqlConnWrite:    dbWrite,
 		historyDB:       historyDB,
+		readRouter:      readRouter,
 		ctx:             ctx,
 		cancel:          cancel,
+		syncCtx:         syncCtx,
+		syncCancel:      syncCancel,
 	}, nil
 }
 
 // APIServer is a server that only runs the API
 type APIServer struct {
-	nodeAPI *NodeAPI
-	mode    Mode
-	ctx     context.Context
-	wg      sync.WaitGroup
-	cancel  context.CancelFunc
+	nodeAPI    *NodeAPI
+	mode       Mode
+	readRouter *dbUtils.ReadRouter
+	ctx        context.Context
+	wg         sync.WaitGroup
+	cancel     context.CancelFunc
 }
 
 // NewAPIServer creates a new APIServer
 func NewAPIServer(mode Mode, cfg *config.APIServer, version string, ethClient *ethclient.Client, forgerAddress *ethCommon.Address) (*APIServer, error) {
 	meddler.Debug = cfg.Debug.MeddlerLogs
+	if err := initTracing(cfg.Tracing); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
 	// Stablish DB connection
 	dbWrite, err := dbUtils.InitSQLDB(
 		cfg.PostgreSQL.PortWrite,
@@ -529,9 +936,16 @@ func NewAPIServer(mode Mode, cfg *config.APIServer, version string, ethClient *e
 	)
 
 	historyDB := historydb.NewHistoryDB(dbRead, dbWrite, apiConnCon)
+	readRouter, err := newReadRouter(dbRead, &cfg.PostgreSQL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if readRouter != nil {
+		historyDB.SetReadRouter(readRouter)
+	}
 
 	var l2DB *l2db.L2DB
-	if mode == ModeCoordinator {
+	if mode.Forging() {
 		l2DB = l2db.NewL2DB(
 			dbRead, dbWrite,
 			0,
@@ -541,6 +955,10 @@ func NewAPIServer(mode Mode, cfg *config.APIServer, version string, ethClient *e
 			0,
 			apiConnCon,
 		)
+		l2DB.SetFeePolicy(l2db.FeePolicy{
+			TokenMinFee:             tokenMinFeeMap(cfg.Coordinator.L2DB.TokenMinFees),
+			FreeTxsPerAccountPerDay: cfg.Coordinator.L2DB.FreeTxsPerAccountPerDay,
+		})
 	}
 
 	if cfg.Debug.GinDebugMode {
@@ -550,28 +968,42 @@ func NewAPIServer(mode Mode, cfg *config.APIServer, version string, ethClient *e
 	}
 	server := gin.Default()
 	coord := false
-	if mode == ModeCoordinator {
+	if mode.Forging() {
 		coord = cfg.Coordinator.API.Coordinator
 	}
+	explorer := cfg.API.Explorer
+	if mode == ModeFull {
+		// Full mode always serves the whole API
+		coord = true
+		explorer = true
+	}
 	nodeAPI, err := NewNodeAPI(
 		version,
 		cfg.API.Address,
-		coord, cfg.API.Explorer,
+		coord, explorer,
 		server,
 		historyDB,
 		l2DB,
 		ethClient,
 		forgerAddress,
+		&cfg.API.CORS,
+		&cfg.API.Compression,
 	)
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
+	if auth := newAPIAuth(&cfg.API.Auth); auth != nil {
+		nodeAPI.api.SetAuth(auth)
+	}
+	nodeAPI.api.SetStalenessPolicy(cfg.API.StalenessThreshold,
+		cfg.API.RejectStalePoolTxs)
 	ctx, cancel := context.WithCancel(context.Background())
 	return &APIServer{
-		nodeAPI: nodeAPI,
-		mode:    mode,
-		ctx:     ctx,
-		cancel:  cancel,
+		nodeAPI:    nodeAPI,
+		mode:       mode,
+		readRouter: readRouter,
+		ctx:        ctx,
+		cancel:     cancel,
 	}, nil
 }
 
@@ -592,6 +1024,13 @@ func (s *APIServer) Start() {
 			log.Fatalw("NodeAPI.Run", "err", err)
 		}
 	}()
+	if s.readRouter != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.readRouter.Run(s.ctx)
+		}()
+	}
 }
 
 // Stop the APIServer
@@ -599,6 +1038,22 @@ func (s *APIServer) Stop() {
 	log.Infow("Stopping NodeAPI...")
 	s.cancel()
 	s.wg.Wait()
+	trace.Stop()
+}
+
+// initTracing initializes the process wide tracer when a collector endpoint
+// is configured (see the trace package)
+func initTracing(cfg config.Tracing) error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	return tracerr.Wrap(trace.Init(trace.Config{
+		Endpoint:      cfg.Endpoint,
+		ServiceName:   cfg.ServiceName,
+		SampleRatio:   cfg.SampleRatio,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval.Duration,
+	}))
 }
 
 // NodeAPI holds the node http API
@@ -618,9 +1073,18 @@ func NewNodeAPI(
 	l2db *l2db.L2DB,
 	ethClient *ethclient.Client,
 	forgerAddress *ethCommon.Address,
+	corsCfg *config.APICORS,
+	compressionCfg *config.APICompression,
 ) (*NodeAPI, error) {
 	engine := gin.Default()
-	engine.Use(cors.Default())
+	engine.Use(apiCORS(corsCfg))
+	if compressionCfg != nil && compressionCfg.GZip {
+		gzipMiddleware, err := api.NewGzipMiddleware(compressionCfg.Level)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		engine.Use(gzipMiddleware)
+	}
 	_api, err := api.NewAPI(
 		version,
 		coordinatorEndpoints, explorerEndpoints,
@@ -640,6 +1104,97 @@ func NewNodeAPI(
 	}, nil
 }
 
+// apiCORS builds the CORS middleware of the API from the configuration,
+// allowing all origins with the default policy when nothing is configured
+func apiCORS(cfg *config.APICORS) gin.HandlerFunc {
+	if cfg == nil || (len(cfg.AllowedOrigins) == 0 &&
+		len(cfg.AllowedMethods) == 0 && len(cfg.AllowedHeaders) == 0) {
+		return cors.Default()
+	}
+	corsCfg := cors.DefaultConfig()
+	if len(cfg.AllowedOrigins) == 0 {
+		corsCfg.AllowAllOrigins = true
+	} else {
+		corsCfg.AllowOrigins = cfg.AllowedOrigins
+	}
+	if len(cfg.AllowedMethods) > 0 {
+		corsCfg.AllowMethods = cfg.AllowedMethods
+	}
+	if len(cfg.AllowedHeaders) > 0 {
+		corsCfg.AllowHeaders = cfg.AllowedHeaders
+	}
+	return cors.New(corsCfg)
+}
+
+// apiAuthKeys converts the API keys from the configuration format to the api
+// package format
+func apiAuthKeys(cfg *config.APIAuth) []api.APIKey {
+	keys := make([]api.APIKey, len(cfg.APIKeys))
+	for i, key := range cfg.APIKeys {
+		keys[i] = api.APIKey{Key: key.Key, Scopes: key.Scopes}
+	}
+	return keys
+}
+
+// newAPIAuth builds the auth subsystem of the operator-only API endpoints
+// from the configuration, returning nil when no credentials are configured
+func newAPIAuth(cfg *config.APIAuth) *api.Auth {
+	if cfg.JWTSecret == "" && len(cfg.APIKeys) == 0 {
+		return nil
+	}
+	return api.NewAuth(cfg.JWTSecret, apiAuthKeys(cfg))
+}
+
+// NodeGRPC holds the node gRPC API (see the api/grpc package)
+type NodeGRPC struct {
+	server *grpcapi.Server
+	addr   string
+}
+
+// NewNodeGRPC creates a new NodeGRPC serving the gRPC API on addr, submitting
+// pool txs through the admission pipeline of _api
+func NewNodeGRPC(addr string, hdb *historydb.HistoryDB, l2db *l2db.L2DB,
+	_api *api.API) *NodeGRPC {
+	server := grpcapi.NewServer(hdb, l2db)
+	server.SetPoolSubmitter(_api)
+	return &NodeGRPC{
+		server: server,
+		addr:   addr,
+	}
+}
+
+// Run starts the http server of the NodeGRPC, speaking plaintext HTTP/2
+// (h2c).  To stop it, pass a context with cancellation.
+func (a *NodeGRPC) Run(ctx context.Context) error {
+	server := &http.Server{
+		Handler: h2c.NewHandler(a.server, &http2.Server{}),
+		// No Read/WriteTimeout: they would cut the long-lived
+		// server streams (WatchBatches)
+		MaxHeaderBytes: 1 << 20, //nolint:gomnd
+	}
+	listener, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	log.Infof("NodeGRPC is ready at %v", a.addr)
+	go func() {
+		if err := server.Serve(listener); err != nil &&
+			tracerr.Unwrap(err) != http.ErrServerClosed {
+			log.Fatalf("Listen: %s\n", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("Stopping NodeGRPC...")
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), 10*time.Second) //nolint:gomnd
+	defer cancel()
+	if err := server.Shutdown(ctxTimeout); err != nil {
+		return tracerr.Wrap(err)
+	}
+	log.Info("NodeGRPC done")
+	return nil
+}
+
 // Run starts the http server of the NodeAPI.  To stop it, pass a context
 // with cancellation.
 func (a *NodeAPI) Run(ctx context.Context) error {
@@ -675,7 +1230,7 @@ func (a *NodeAPI) Run(ctx context.Context) error {
 
 func (n *Node) handleNewBlock(ctx context.Context, stats *synchronizer.Stats,
 	vars *common.SCVariablesPtr, batches []common.BatchData) error {
-	if n.mode == ModeCoordinator {
+	if n.mode.Forging() {
 		n.coord.SendMsg(ctx, coordinator.MsgSyncBlock{
 			Stats:   *stats,
 			Vars:    *vars,
@@ -704,7 +1259,7 @@ func (n *Node) handleNewBlock(ctx context.Context, stats *synchronizer.Stats,
 
 func (n *Node) handleReorg(ctx context.Context, stats *synchronizer.Stats,
 	vars *common.SCVariables) error {
-	if n.mode == ModeCoordinator {
+	if n.mode.Forging() {
 		n.coord.SendMsg(ctx, coordinator.MsgSyncReorg{
 			Stats: *stats,
 			Vars:  *vars.AsPtr(),
@@ -769,6 +1324,17 @@ func (n *Node) StartSynchronizer() {
 		log.Fatalw("Node.handleNewBlock", "err", err)
 	}
 
+	// When a websocket endpoint is configured, subscribe to the rollup
+	// smart contract logs to trigger a sync iteration as soon as a block
+	// with rollup events appears, without waiting for the next poll.  A
+	// nil notify channel blocks forever, so without subscription the loop
+	// only wakes up by polling
+	var notifyCh <-chan int64
+	if n.eventStream != nil {
+		n.eventStream.Start(n.ctx)
+		notifyCh = n.eventStream.Notify()
+	}
+
 	n.wg.Add(1)
 	go func() {
 		var err error
@@ -780,19 +1346,31 @@ func (n *Node) StartSynchronizer() {
 				log.Info("Synchronizer done")
 				n.wg.Done()
 				return
+			case blockNum := <-notifyCh:
+				log.Debugw("Synchronizer: new block with rollup events "+
+					"notified by subscription", "block", blockNum)
 			case <-time.After(waitDuration):
-				if lastBlock, waitDuration, err = n.syncLoopFn(n.ctx,
-					lastBlock); err != nil {
-					if n.ctx.Err() != nil {
-						continue
-					}
-					if errors.Is(err, eth.ErrBlockHashMismatchEvent) {
-						log.Warnw("Synchronizer.Sync", "err", err)
-					} else if errors.Is(err, synchronizer.ErrUnknownBlock) {
-						log.Warnw("Synchronizer.Sync", "err", err)
-					} else {
-						log.Errorw("Synchronizer.Sync", "err", err)
-					}
+			}
+			if n.ctx.Err() != nil {
+				// Don't start a new iteration while shutting
+				// down; the next select returns through the
+				// ctx.Done case
+				continue
+			}
+			// The iteration runs under syncCtx so that a regular
+			// shutdown doesn't interrupt the block being
+			// synchronized; see Node.Stop
+			if lastBlock, waitDuration, err = n.syncLoopFn(n.syncCtx,
+				lastBlock); err != nil {
+				if n.ctx.Err() != nil || n.syncCtx.Err() != nil {
+					continue
+				}
+				if errors.Is(err, eth.ErrBlockHashMismatchEvent) {
+					log.Warnw("Synchronizer.Sync", "err", err)
+				} else if errors.Is(err, synchronizer.ErrUnknownBlock) {
+					log.Warnw("Synchronizer.Sync", "err", err)
+				} else {
+					log.Errorw("Synchronizer.Sync", "err", err)
 				}
 			}
 		}
@@ -806,7 +1384,7 @@ func (n *Node) StartSynchronizer() {
 				log.Info("PriceUpdater done")
 				n.wg.Done()
 				return
-			case <-time.After(n.cfg.PriceUpdater.Interval.Duration):
+			case <-time.After(n.priceUpdaterInterval()):
 				if err := n.priceUpdater.UpdateFiatPrices(n.ctx); err != nil {
 					log.Errorw("PriceUpdater.UpdateFiatPrices()", "err", err)
 				}
@@ -817,6 +1395,82 @@ func (n *Node) StartSynchronizer() {
 			}
 		}
 	}()
+
+	if interval := n.cfg.PriceUpdater.HistoricBackfillInterval.Duration; interval > 0 {
+		n.wg.Add(1)
+		go func() {
+			for {
+				select {
+				case <-n.ctx.Done():
+					log.Info("PriceUpdater backfill done")
+					n.wg.Done()
+					return
+				case <-time.After(interval):
+					n.priceUpdater.BackfillHistoricPrices(n.ctx)
+				}
+			}
+		}()
+	}
+}
+
+// priceUpdaterInterval returns the current interval between price updater
+// calls, which can be changed by a configuration hot reload
+func (n *Node) priceUpdaterInterval() time.Duration {
+	n.cfgMutex.RLock()
+	defer n.cfgMutex.RUnlock()
+	return n.cfg.PriceUpdater.Interval.Duration
+}
+
+// StartConfigWatcher starts watching the configuration file used to launch
+// the node, applying the hot-reloadable settings to the running components
+// whenever it changes.  If the configuration origin is unknown (not loaded
+// from a file), the watcher is not started.
+func (n *Node) StartConfigWatcher() {
+	if n.cfg.Path == "" {
+		return
+	}
+	watcher, err := config.NewWatcher(n.cfg.Path, n.mode.Forging())
+	if err != nil {
+		log.Warnw("NewWatcher", "path", n.cfg.Path, "err", err)
+		return
+	}
+	log.Info("Starting ConfigWatcher...")
+	sub := watcher.Subscribe()
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		watcher.Run(n.ctx)
+		log.Info("ConfigWatcher done")
+	}()
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for {
+			select {
+			case <-n.ctx.Done():
+				return
+			case newCfg := <-sub:
+				n.applyConfigReload(newCfg)
+			}
+		}
+	}()
+}
+
+// applyConfigReload applies the hot-reloadable subset of a freshly loaded
+// configuration to the running components
+func (n *Node) applyConfigReload(newCfg *config.Node) {
+	n.cfgMutex.Lock()
+	n.cfg.PriceUpdater.Interval = newCfg.PriceUpdater.Interval
+	n.cfgMutex.Unlock()
+	if n.mode.Forging() {
+		n.coord.SetMaxGasPrice(newCfg.Coordinator.EthClient.MaxGasPrice)
+	}
+	if n.nodeAPI != nil {
+		n.nodeAPI.api.SetAuthKeys(apiAuthKeys(&newCfg.API.Auth))
+	}
+	log.Infow("applied hot-reloaded configuration",
+		"priceUpdaterInterval", newCfg.PriceUpdater.Interval.Duration,
+		"maxGasPrice", newCfg.Coordinator.EthClient.MaxGasPrice)
 }
 
 // StartDebugAPI starts the DebugAPI
@@ -909,6 +1563,24 @@ func (n *Node) StartNodeAPI() {
 	}()
 }
 
+// StartNodeGRPC starts the NodeGRPC
+func (n *Node) StartNodeGRPC() {
+	log.Info("Starting NodeGRPC...")
+	n.wg.Add(1)
+	go func() {
+		defer func() {
+			log.Info("NodeGRPC routine stopped")
+			n.wg.Done()
+		}()
+		if err := n.nodeGRPC.Run(n.ctx); err != nil {
+			if n.ctx.Err() != nil {
+				return
+			}
+			log.Fatalw("NodeGRPC.Run", "err", err)
+		}
+	}()
+}
+
 // Start the node
 func (n *Node) Start() {
 	log.Infow("Starting node...", "mode", n.mode)
@@ -918,26 +1590,97 @@ func (n *Node) Start() {
 	if n.nodeAPI != nil {
 		n.StartNodeAPI()
 	}
-	if n.mode == ModeCoordinator {
+	if n.nodeGRPC != nil {
+		n.StartNodeGRPC()
+	}
+	if n.mode.Forging() {
 		log.Info("Starting Coordinator...")
 		n.coord.Start()
 	}
 	n.StartSynchronizer()
+	n.StartConfigWatcher()
+	if n.webhookNotifier != nil {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.webhookNotifier.Run(n.ctx)
+		}()
+	}
+	if n.depositWatcher != nil {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.depositWatcher.Run(n.ctx)
+		}()
+	}
+	if n.readRouter != nil {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.readRouter.Run(n.ctx)
+		}()
+	}
+}
+
+// stopTimeout is the time given to the subsystems to drain their work in
+// progress during shutdown before it is aborted
+const stopTimeout = 30 * time.Second
+
+// waitTimeout waits for the WaitGroup, giving up after the timeout.  Returns
+// true when the WaitGroup finished in time
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
 
-// Stop the node
+// Stop the node.  Shutdown is staged so that each subsystem can drain its
+// work in progress: the API finishes the in-flight requests, the synchronizer
+// completes the block being processed, the coordinator stops the pipeline
+// canceling the provers, and only then the database connections are closed
 func (n *Node) Stop() {
 	log.Infow("Stopping node...")
+	// Stop accepting new work.  The NodeAPI does a graceful http shutdown,
+	// and the synchronizer loop exits after the iteration in progress
+	// (which runs under syncCtx) completes
 	n.cancel()
-	n.wg.Wait()
-	if n.mode == ModeCoordinator {
+	if !waitTimeout(&n.wg, stopTimeout) {
+		log.Warnw("Node.Stop: draining timed out, aborting the "+
+			"synchronization in progress", "timeout", stopTimeout)
+		n.syncCancel()
+		n.wg.Wait()
+	}
+	n.syncCancel()
+	if n.mode.Forging() {
 		log.Info("Stopping Coordinator...")
 		n.coord.Stop()
 	}
 	// Close kv DBs
 	n.sync.StateDB().Close()
-	if n.mode == ModeCoordinator {
+	if n.mode.Forging() {
 		n.coord.TxSelector().LocalAccountsDB().Close()
 		n.coord.BatchBuilder().LocalStateDB().Close()
 	}
+	// Close the SQL connections once no subsystem is using them
+	if n.readRouter != nil {
+		n.readRouter.Close()
+	}
+	if n.sqlConnRead != n.sqlConnWrite {
+		if err := n.sqlConnRead.Close(); err != nil {
+			log.Errorw("sqlConnRead.Close", "err", err)
+		}
+	}
+	if err := n.sqlConnWrite.Close(); err != nil {
+		log.Errorw("sqlConnWrite.Close", "err", err)
+	}
+	// Flush the pending tracing spans, if any
+	trace.Stop()
 }
@@ -3,6 +3,7 @@ package priceupdater
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -20,6 +21,12 @@ import (
 const (
 	defaultMaxIdleConns    = 10
 	defaultIdleConnTimeout = 2 * time.Second
+	// backfillBatchSize is the maximum number of txs whose USD values are
+	// backfilled on each call to BackfillHistoricPrices
+	backfillBatchSize = 1000
+	// historicPriceRange is the time window queried around the tx
+	// timestamp when asking a provider for a historic price
+	historicPriceRange = 12 * time.Hour
 )
 
 const (
@@ -314,6 +321,128 @@ func (p *PriceUpdater) UpdatePrices(ctx context.Context) {
 			}
 		}
 	}
+	// Flag tokens whose price couldn't be refreshed within the stale price
+	// window.  Their USD values are excluded from the fee and metrics
+	// computations until a fresh price is obtained
+	if staleSymbols, err := p.db.GetStaleTokenSymbols(); err != nil {
+		log.Errorw("PriceUpdater: error getting stale token prices", "err", err)
+	} else if len(staleSymbols) > 0 {
+		log.Warnw("PriceUpdater: token USD prices are stale",
+			"tokens", staleSymbols)
+	}
+}
+
+// marketChartAPI is the format of the CoinGecko V3 market_chart responses
+type marketChartAPI struct {
+	// Prices is a list of [timestampMillis, priceUSD] samples
+	Prices [][]float64 `json:"prices"`
+}
+
+// getTokenHistoricPriceFromProvider returns the USD price of a token at the
+// given moment, using the first provider of the priority list that serves
+// historic prices (currently only CoinGecko V3)
+func (p *PriceUpdater) getTokenHistoricPriceFromProvider(ctx context.Context,
+	tokenID uint, moment time.Time) (float64, error) {
+	for i := 0; i < len(p.updateMethodsPriority); i++ {
+		provider := p.providers[p.updateMethodsPriority[i]]
+		if provider.Provider != UpdateMethodTypeCoingeckoV3 {
+			// Only CoinGecko V3 serves historic prices
+			continue
+		}
+		addr, hasAddr := provider.AddressesMap.Addresses[tokenID]
+		if !hasAddr {
+			continue
+		}
+		rangeParams := fmt.Sprintf("market_chart/range?vs_currency=usd&from=%d&to=%d",
+			moment.Add(-historicPriceRange).Unix(), moment.Add(historicPriceRange).Unix())
+		var url string
+		if addr == common.EmptyAddr {
+			url = "coins/ethereum/" + rangeParams
+		} else {
+			url = "coins/ethereum/contract/" + strings.ToLower(addr.String()) + "/" + rangeParams
+		}
+		req, err := p.clientProviders[provider.Provider].New().Get(url).Request()
+		if err != nil {
+			return 0, tracerr.Wrap(err)
+		}
+		var chart marketChartAPI
+		res, err := p.clientProviders[provider.Provider].Do(req.WithContext(ctx), &chart, nil)
+		if err != nil || res.StatusCode != http.StatusOK || len(chart.Prices) == 0 {
+			var errMsg strings.Builder
+			errMsg.WriteString("Historic price not available.")
+			if err != nil {
+				errMsg.WriteString(" - Error: " + err.Error())
+			}
+			if res != nil {
+				errMsg.WriteString(fmt.Sprintf(" - HTTP Error: %d %s", res.StatusCode, res.Status))
+			}
+			errMsg.WriteString(fmt.Sprintf(" - TokenID: %d - URL: %s", tokenID, url))
+			log.Warn(errMsg.String())
+			continue
+		}
+		// Use the price sample closest to the requested moment
+		best := chart.Prices[0]
+		target := float64(moment.UnixMilli())
+		for _, sample := range chart.Prices[1:] {
+			if math.Abs(sample[0]-target) < math.Abs(best[0]-target) {
+				best = sample
+			}
+		}
+		return best[1], nil
+	}
+	return 0, tracerr.Wrap(fmt.Errorf("Error getting historic price. "+
+		"No provider serves historic prices for tokenID %d", tokenID))
+}
+
+// BackfillHistoricPrices sets the USD values of forged txs that were inserted
+// while their token had no known price, using historic prices from the
+// providers.  Up to backfillBatchSize txs are processed per call; prices are
+// cached per token and day to keep the amount of provider requests low.
+func (p *PriceUpdater) BackfillHistoricPrices(ctx context.Context) {
+	txs, err := p.db.GetTxsWithoutUSD(backfillBatchSize)
+	if err != nil {
+		log.Errorw("BackfillHistoricPrices GetTxsWithoutUSD", "err", err)
+		return
+	}
+	type tokenDay struct {
+		tokenID uint
+		day     string
+	}
+	// A nil price marks a token and day whose price couldn't be obtained,
+	// so that it's only requested once per call
+	prices := map[tokenDay]*float64{}
+	updated := 0
+	for i := range txs {
+		if ctx.Err() != nil {
+			return
+		}
+		tokenID := uint(txs[i].TokenID)
+		key := tokenDay{tokenID: tokenID, day: txs[i].Timestamp.Format("2006-01-02")}
+		price, cached := prices[key]
+		if !cached {
+			if staticPrice, ok := p.statictokensMap.Statictokens[tokenID]; ok {
+				price = &staticPrice
+			} else if historicPrice, err := p.getTokenHistoricPriceFromProvider(ctx,
+				tokenID, txs[i].Timestamp); err != nil {
+				log.Warnw("BackfillHistoricPrices historic price not available",
+					"err", err, "tokenID", tokenID, "day", key.day)
+			} else {
+				price = &historicPrice
+			}
+			prices[key] = price
+		}
+		if price == nil {
+			continue
+		}
+		if err := p.db.UpdateTxUSDByPrice(txs[i].TxID, *price); err != nil {
+			log.Errorw("BackfillHistoricPrices UpdateTxUSDByPrice", "err", err)
+			return
+		}
+		updated++
+	}
+	if updated > 0 {
+		log.Infow("BackfillHistoricPrices: USD values backfilled", "txs", updated)
+	}
 }
 
 // UpdateTokenList get the registered token symbols from HistoryDB
@@ -0,0 +1,249 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/hermeznetwork/tracerr"
+	"golang.org/x/net/http2"
+)
+
+// grpcServicePath is the path prefix of the prover gRPC service.  The remote
+// service is expected to expose the same methods as the HTTP proof server API
+// (Status, Input, Cancel) under this service name.
+const grpcServicePath = "/prover.ProverService/"
+
+// grpcContentType is the gRPC content type of the JSON codec.  Using the JSON
+// codec allows reusing the proof server API types without requiring protobuf
+// definitions for the ZKInputs.
+const grpcContentType = "application/grpc+json"
+
+// NewClient creates a prover Client from its URL, selecting the backend from
+// the URL scheme: "grpc://" and "grpcs://" use the gRPC backend (the latter
+// with TLS), any other scheme uses the HTTP proof server backend.
+func NewClient(URL string, pollInterval time.Duration) (Client, error) {
+	parsedURL, err := url.Parse(URL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	switch parsedURL.Scheme {
+	case "grpc":
+		return NewGRPCProofServerClient(parsedURL.Host, false, pollInterval), nil
+	case "grpcs":
+		return NewGRPCProofServerClient(parsedURL.Host, true, pollInterval), nil
+	default:
+		return NewProofServerClient(URL, pollInterval), nil
+	}
+}
+
+// GRPCProofServerClient is a Client that connects to a remote prover through
+// gRPC.  It speaks the gRPC wire protocol over HTTP/2 directly with the JSON
+// codec, so it doesn't require extra dependencies nor protobuf definitions of
+// the prover messages, which match the ones of the HTTP proof server API.
+type GRPCProofServerClient struct {
+	addr         string
+	baseURL      string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+// NewGRPCProofServerClient creates a new GRPCProofServerClient connecting to
+// addr ("host:port").  If useTLS is false the connection is made with
+// plaintext HTTP/2 (h2c).
+func NewGRPCProofServerClient(addr string, useTLS bool,
+	pollInterval time.Duration) *GRPCProofServerClient {
+	var transport *http2.Transport
+	scheme := "https"
+	if useTLS {
+		transport = &http2.Transport{}
+	} else {
+		scheme = "http"
+		transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}
+	}
+	return &GRPCProofServerClient{
+		addr:         addr,
+		baseURL:      scheme + "://" + addr + grpcServicePath,
+		client:       &http.Client{Transport: transport},
+		pollInterval: pollInterval,
+	}
+}
+
+// grpcFrame wraps a JSON encoded message with the gRPC length-prefixed frame
+// header (1 byte compression flag + 4 bytes big endian length)
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5+len(msg)) //nolint:gomnd
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	return frame
+}
+
+// grpcUnframe extracts the JSON encoded message from a gRPC length-prefixed
+// frame
+func grpcUnframe(data []byte) ([]byte, error) {
+	if len(data) < 5 { //nolint:gomnd
+		return nil, tracerr.Wrap(fmt.Errorf("gRPC frame too short: %v bytes", len(data)))
+	}
+	if data[0] != 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("unexpected compressed gRPC frame"))
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		return nil, tracerr.Wrap(fmt.Errorf("incomplete gRPC frame"))
+	}
+	return data[5 : 5+length], nil
+}
+
+// grpcCall performs a unary gRPC call to the given method of the prover
+// service, encoding body and decoding the reply as JSON.  The deadline of ctx,
+// if any, is propagated to the server through the grpc-timeout header.
+func (p *GRPCProofServerClient) grpcCall(ctx context.Context, method string,
+	body, ret interface{}) error {
+	if body == nil {
+		body = struct{}{}
+	}
+	msg, err := json.Marshal(body)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.baseURL+method, bytes.NewReader(grpcFrame(msg)))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return tracerr.Wrap(ctx.Err())
+		}
+		req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", timeout.Milliseconds()))
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil && err != io.EOF {
+		return tracerr.Wrap(err)
+	}
+	// The gRPC status is sent in the trailers, or in the headers in
+	// trailers-only responses
+	status := res.Trailer.Get("Grpc-Status")
+	message := res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = res.Header.Get("Grpc-Status")
+		message = res.Header.Get("Grpc-Message")
+	}
+	if status != "" && status != "0" {
+		return tracerr.Wrap(fmt.Errorf("gRPC error (code %v): %v", status, message))
+	}
+	if res.StatusCode != http.StatusOK {
+		return tracerr.Wrap(fmt.Errorf("gRPC call failed with http status %v", res.StatusCode))
+	}
+	if ret != nil {
+		reply, err := grpcUnframe(resBody)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := json.Unmarshal(reply, ret); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func (p *GRPCProofServerClient) apiStatus(ctx context.Context) (*Status, error) {
+	var status Status
+	return &status, tracerr.Wrap(p.grpcCall(ctx, "Status", nil, &status))
+}
+
+// CalculateProof sends the *common.ZKInputs to the remote prover to compute
+// the Proof
+func (p *GRPCProofServerClient) CalculateProof(ctx context.Context,
+	zkInputs *common.ZKInputs) error {
+	return tracerr.Wrap(p.grpcCall(ctx, "Input", zkInputs, nil))
+}
+
+// GetProof retrieves the Proof and Public Data (public inputs) from the
+// remote prover, blocking until the proof is ready.
+func (p *GRPCProofServerClient) GetProof(ctx context.Context) (*Proof, []*big.Int, error) {
+	if err := p.WaitReady(ctx); err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	status, err := p.apiStatus(ctx)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	if status.Status == StatusCodeSuccess {
+		var proof Proof
+		if err := json.Unmarshal([]byte(status.Proof), &proof); err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		var pubInputs PublicInputs
+		if err := json.Unmarshal([]byte(status.PubData), &pubInputs); err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		return &proof, pubInputs, nil
+	}
+	return nil, nil, tracerr.Wrap(fmt.Errorf("status != %v, status = %v", StatusCodeSuccess,
+		status.Status))
+}
+
+// Cancel cancels any current proof computation
+func (p *GRPCProofServerClient) Cancel(ctx context.Context) error {
+	return tracerr.Wrap(p.grpcCall(ctx, "Cancel", nil, nil))
+}
+
+// Healthy returns an error when the remote prover can't be reached or is
+// not initialized.  Unlike WaitReady, it doesn't wait for an ongoing proof
+// computation to finish
+func (p *GRPCProofServerClient) Healthy(ctx context.Context) error {
+	status, err := p.apiStatus(ctx)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !status.Status.IsInitialized() {
+		return tracerr.Wrap(fmt.Errorf("Proof Server is not initialized"))
+	}
+	return nil
+}
+
+// WaitReady waits until the remote prover is ready
+func (p *GRPCProofServerClient) WaitReady(ctx context.Context) error {
+	for {
+		status, err := p.apiStatus(ctx)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if !status.Status.IsInitialized() {
+			return tracerr.Wrap(fmt.Errorf("Proof Server is not initialized"))
+		}
+		if status.Status.IsReady() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return tracerr.Wrap(common.ErrDone)
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
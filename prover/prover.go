@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/trace"
 	"github.com/dghubble/sling"
 	"github.com/hermeznetwork/tracerr"
 )
@@ -96,6 +97,9 @@ type Client interface {
 	Cancel(ctx context.Context) error
 	// Blocking
 	WaitReady(ctx context.Context) error
+	// Non-Blocking.  Returns an error when the prover can't be reached
+	// or is not initialized.  A prover busy computing a proof is healthy
+	Healthy(ctx context.Context) error
 }
 
 // StatusCode is the status string of the ProofServer
@@ -191,6 +195,11 @@ func NewProofServerClient(URL string, pollInterval time.Duration) *ProofServerCl
 func (p *ProofServerClient) apiRequest(ctx context.Context, method apiMethod, path string,
 	body interface{}, ret interface{}) error {
 	path = strings.TrimPrefix(path, "/")
+	// The span covers the whole exchange with the proof server,
+	// including the wait for the response (see the trace package)
+	ctx, span := trace.StartSpan(ctx, "prover "+string(method)+" /"+path)
+	span.SetAttribute("prover.url", p.URL)
+	defer span.End()
 	var errSrv ErrorServer
 	var req *http.Request
 	var err error
@@ -207,10 +216,12 @@ func (p *ProofServerClient) apiRequest(ctx context.Context, method apiMethod, pa
 	}
 	res, err := p.client.Do(req.WithContext(ctx), ret, &errSrv)
 	if err != nil {
+		span.SetError(err)
 		return tracerr.Wrap(err)
 	}
 	defer res.Body.Close() //nolint:errcheck
 	if !(200 <= res.StatusCode && res.StatusCode < 300) {
+		span.SetError(errSrv)
 		return tracerr.Wrap(errSrv)
 	}
 	return nil
@@ -286,6 +297,20 @@ func (p *ProofServerClient) WaitReady(ctx context.Context) error {
 	}
 }
 
+// Healthy returns an error when the prover can't be reached or is not
+// initialized.  Unlike WaitReady, it doesn't wait for an ongoing proof
+// computation to finish
+func (p *ProofServerClient) Healthy(ctx context.Context) error {
+	status, err := p.apiStatus(ctx)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !status.Status.IsInitialized() {
+		return tracerr.Wrap(fmt.Errorf("Proof Server is not initialized"))
+	}
+	return nil
+}
+
 // MockClient is a mock ServerProof to be used in tests.  It doesn't calculate anything
 type MockClient struct {
 	counter int64
@@ -347,3 +372,8 @@ func (p *MockClient) WaitReady(ctx context.Context) error {
 		return tracerr.Wrap(common.ErrDone)
 	}
 }
+
+// Healthy always reports the mock prover as healthy
+func (p *MockClient) Healthy(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,204 @@
+package prover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// bn256Q is the modulus of the bn256 base field, where the proof and
+// verifying key point coordinates live
+var bn256Q, _ = new(big.Int).SetString(
+	"21888242871839275222246405745257275088696311157297823662689037894645226208583", 10)
+
+// VerifyingKey is the Groth16 verifying key of a circuit, in the JSON
+// format emitted by snarkjs (verification_key.json).  It allows checking
+// locally that a proof returned by a proof server is valid for a set of
+// public inputs before spending gas submitting it to the smart contract.
+type VerifyingKey struct {
+	Protocol string         `json:"protocol"`
+	NPublic  int            `json:"nPublic"`
+	Alpha1   [3]*big.Int    `json:"vk_alpha_1"`
+	Beta2    [3][2]*big.Int `json:"vk_beta_2"`
+	Gamma2   [3][2]*big.Int `json:"vk_gamma_2"`
+	Delta2   [3][2]*big.Int `json:"vk_delta_2"`
+	IC       [][3]*big.Int  `json:"IC"`
+}
+
+// UnmarshalJSON unmarshals the verifying key from a JSON encoded key with
+// the big ints as strings
+func (vk *VerifyingKey) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Protocol string        `json:"protocol"`
+		NPublic  int           `json:"nPublic"`
+		Alpha1   [3]*bigInt    `json:"vk_alpha_1"`
+		Beta2    [3][2]*bigInt `json:"vk_beta_2"`
+		Gamma2   [3][2]*bigInt `json:"vk_gamma_2"`
+		Delta2   [3][2]*bigInt `json:"vk_delta_2"`
+		IC       [][3]*bigInt  `json:"IC"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return tracerr.Wrap(err)
+	}
+	vk.Protocol = raw.Protocol
+	vk.NPublic = raw.NPublic
+	for i := 0; i < 3; i++ {
+		vk.Alpha1[i] = (*big.Int)(raw.Alpha1[i])
+		for j := 0; j < 2; j++ {
+			vk.Beta2[i][j] = (*big.Int)(raw.Beta2[i][j])
+			vk.Gamma2[i][j] = (*big.Int)(raw.Gamma2[i][j])
+			vk.Delta2[i][j] = (*big.Int)(raw.Delta2[i][j])
+		}
+	}
+	vk.IC = make([][3]*big.Int, len(raw.IC))
+	for i := range raw.IC {
+		for j := 0; j < 3; j++ {
+			vk.IC[i][j] = (*big.Int)(raw.IC[i][j])
+		}
+	}
+	return nil
+}
+
+// LoadVerifyingKey loads a Groth16 verifying key from a snarkjs
+// verification_key.json file
+func LoadVerifyingKey(path string) (*VerifyingKey, error) {
+	bs, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var vk VerifyingKey
+	if err := json.Unmarshal(bs, &vk); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if vk.Protocol != "" && vk.Protocol != "groth16" {
+		return nil, tracerr.Wrap(fmt.Errorf("expected \"groth16\" protocol in "+
+			"verifying key, but got \"%v\"", vk.Protocol))
+	}
+	if len(vk.IC) == 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("verifying key has no IC points"))
+	}
+	return &vk, nil
+}
+
+// writePadded writes the 32 byte big-endian representation of v into buf
+func writePadded(buf []byte, v *big.Int) error {
+	bs := v.Bytes()
+	if len(bs) > len(buf) {
+		return tracerr.Wrap(fmt.Errorf("value %v doesn't fit in %v bytes", v, len(buf)))
+	}
+	copy(buf[len(buf)-len(bs):], bs)
+	return nil
+}
+
+// g1Point builds a bn256 G1 point from affine coordinates [x, y, 1]
+func g1Point(p [3]*big.Int) (*bn256.G1, error) {
+	if p[0] == nil || p[1] == nil {
+		return nil, tracerr.Wrap(fmt.Errorf("nil G1 point coordinate"))
+	}
+	buf := make([]byte, 64)
+	if err := writePadded(buf[0:32], p[0]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := writePadded(buf[32:64], p[1]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	g := new(bn256.G1)
+	if _, err := g.Unmarshal(buf); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return g, nil
+}
+
+// g2Point builds a bn256 G2 point from affine coordinates
+// [[x0, x1], [y0, y1], [1, 0]], where each coordinate is x0 + x1*i as in the
+// snarkjs encoding.  bn256 marshals the imaginary part of each coordinate
+// first.
+func g2Point(p [3][2]*big.Int) (*bn256.G2, error) {
+	if p[0][0] == nil || p[0][1] == nil || p[1][0] == nil || p[1][1] == nil {
+		return nil, tracerr.Wrap(fmt.Errorf("nil G2 point coordinate"))
+	}
+	buf := make([]byte, 128)
+	if err := writePadded(buf[0:32], p[0][1]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := writePadded(buf[32:64], p[0][0]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := writePadded(buf[64:96], p[1][1]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := writePadded(buf[96:128], p[1][0]); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	g := new(bn256.G2)
+	if _, err := g.Unmarshal(buf); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return g, nil
+}
+
+// Verify checks that proof is a valid Groth16 proof of the circuit for the
+// given public inputs.  It returns nil when the proof is valid and an error
+// otherwise, checking the pairing equation
+// e(-A, B) * e(alpha, beta) * e(vk_x, gamma) * e(C, delta) == 1.
+func (vk *VerifyingKey) Verify(proof *Proof, pubInputs []*big.Int) error {
+	if proof == nil {
+		return tracerr.Wrap(fmt.Errorf("nil proof"))
+	}
+	if len(vk.IC) != len(pubInputs)+1 {
+		return tracerr.Wrap(fmt.Errorf("verifying key expects %v public inputs "+
+			"but got %v", len(vk.IC)-1, len(pubInputs)))
+	}
+	vkx, err := g1Point(vk.IC[0])
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for i, pubInput := range pubInputs {
+		icPoint, err := g1Point(vk.IC[i+1])
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		vkx.Add(vkx, new(bn256.G1).ScalarMult(icPoint, pubInput))
+	}
+	// Negate A so that the product of all the pairings must be one
+	negAY := new(big.Int).Mod(new(big.Int).Neg(proof.PiA[1]), bn256Q)
+	negA, err := g1Point([3]*big.Int{proof.PiA[0], negAY, proof.PiA[2]})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	b, err := g2Point(proof.PiB)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	c, err := g1Point(proof.PiC)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	alpha, err := g1Point(vk.Alpha1)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	beta, err := g2Point(vk.Beta2)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	gamma, err := g2Point(vk.Gamma2)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	delta, err := g2Point(vk.Delta2)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !bn256.PairingCheck(
+		[]*bn256.G1{negA, alpha, vkx, c},
+		[]*bn256.G2{b, beta, gamma, delta},
+	) {
+		return tracerr.Wrap(fmt.Errorf("invalid proof"))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package prover
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func g1Coords(g *bn256.G1) [3]*big.Int {
+	bs := g.Marshal()
+	return [3]*big.Int{
+		new(big.Int).SetBytes(bs[0:32]),
+		new(big.Int).SetBytes(bs[32:64]),
+		big.NewInt(1),
+	}
+}
+
+func g2Coords(g *bn256.G2) [3][2]*big.Int {
+	bs := g.Marshal()
+	return [3][2]*big.Int{
+		{new(big.Int).SetBytes(bs[32:64]), new(big.Int).SetBytes(bs[0:32])},
+		{new(big.Int).SetBytes(bs[96:128]), new(big.Int).SetBytes(bs[64:96])},
+		{big.NewInt(1), big.NewInt(0)},
+	}
+}
+
+// testVerifyingKeyAndProof builds a verifying key together with a proof and
+// public input that satisfy the Groth16 pairing equation:  with
+// gamma = delta = G2, A = alpha and B = beta make
+// e(-A, B) * e(alpha, beta) == 1, and C = -vk_x makes
+// e(vk_x, gamma) * e(C, delta) == 1.
+func testVerifyingKeyAndProof() (*VerifyingKey, *Proof, []*big.Int) {
+	a := big.NewInt(777)
+	b := big.NewInt(888)
+	x0 := big.NewInt(1234)
+	x1 := big.NewInt(5678)
+	pubInput := big.NewInt(42)
+
+	alpha := new(bn256.G1).ScalarBaseMult(a)
+	beta := new(bn256.G2).ScalarBaseMult(b)
+	one := big.NewInt(1)
+	gamma := new(bn256.G2).ScalarBaseMult(one)
+	delta := new(bn256.G2).ScalarBaseMult(one)
+	ic0 := new(bn256.G1).ScalarBaseMult(x0)
+	ic1 := new(bn256.G1).ScalarBaseMult(x1)
+
+	vkx := new(bn256.G1).ScalarBaseMult(
+		new(big.Int).Add(x0, new(big.Int).Mul(pubInput, x1)))
+	vkxCoords := g1Coords(vkx)
+	cCoords := [3]*big.Int{
+		vkxCoords[0],
+		new(big.Int).Mod(new(big.Int).Neg(vkxCoords[1]), bn256Q),
+		big.NewInt(1),
+	}
+
+	vk := &VerifyingKey{
+		Protocol: "groth16",
+		NPublic:  1,
+		Alpha1:   g1Coords(alpha),
+		Beta2:    g2Coords(beta),
+		Gamma2:   g2Coords(gamma),
+		Delta2:   g2Coords(delta),
+		IC:       [][3]*big.Int{g1Coords(ic0), g1Coords(ic1)},
+	}
+	proof := &Proof{
+		PiA:      g1Coords(new(bn256.G1).ScalarBaseMult(a)),
+		PiB:      g2Coords(beta),
+		PiC:      cCoords,
+		Protocol: "groth16",
+	}
+	return vk, proof, []*big.Int{pubInput}
+}
+
+func TestVerifyingKeyVerify(t *testing.T) {
+	vk, proof, pubInputs := testVerifyingKeyAndProof()
+	require.NoError(t, vk.Verify(proof, pubInputs))
+}
+
+func TestVerifyingKeyVerifyInvalid(t *testing.T) {
+	vk, proof, pubInputs := testVerifyingKeyAndProof()
+
+	// Tampered public input
+	err := vk.Verify(proof, []*big.Int{big.NewInt(43)})
+	assert.Error(t, err)
+
+	// Tampered proof
+	badProof := *proof
+	badProof.PiC = vk.Alpha1
+	assert.Error(t, vk.Verify(&badProof, pubInputs))
+
+	// Wrong number of public inputs
+	assert.Error(t, vk.Verify(proof, nil))
+
+	// Point not on the curve
+	badProof = *proof
+	badProof.PiA = [3]*big.Int{big.NewInt(1), big.NewInt(1), big.NewInt(1)}
+	assert.Error(t, vk.Verify(&badProof, pubInputs))
+}
+
+func TestVerifyingKeyUnmarshalJSON(t *testing.T) {
+	vk, proof, pubInputs := testVerifyingKeyAndProof()
+	vkJSON := `{
+		"protocol": "groth16",
+		"nPublic": 1,
+		"vk_alpha_1": ["` + vk.Alpha1[0].String() + `", "` + vk.Alpha1[1].String() + `", "1"],
+		"vk_beta_2": [
+			["` + vk.Beta2[0][0].String() + `", "` + vk.Beta2[0][1].String() + `"],
+			["` + vk.Beta2[1][0].String() + `", "` + vk.Beta2[1][1].String() + `"],
+			["1", "0"]
+		],
+		"vk_gamma_2": [
+			["` + vk.Gamma2[0][0].String() + `", "` + vk.Gamma2[0][1].String() + `"],
+			["` + vk.Gamma2[1][0].String() + `", "` + vk.Gamma2[1][1].String() + `"],
+			["1", "0"]
+		],
+		"vk_delta_2": [
+			["` + vk.Delta2[0][0].String() + `", "` + vk.Delta2[0][1].String() + `"],
+			["` + vk.Delta2[1][0].String() + `", "` + vk.Delta2[1][1].String() + `"],
+			["1", "0"]
+		],
+		"IC": [
+			["` + vk.IC[0][0].String() + `", "` + vk.IC[0][1].String() + `", "1"],
+			["` + vk.IC[1][0].String() + `", "` + vk.IC[1][1].String() + `", "1"]
+		]
+	}`
+	var vk2 VerifyingKey
+	require.NoError(t, vk2.UnmarshalJSON([]byte(vkJSON)))
+	assert.Equal(t, vk.NPublic, vk2.NPublic)
+	require.NoError(t, vk2.Verify(proof, pubInputs))
+}
@@ -49,6 +49,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/metric"
 	"github.com/arnaubennassar/hermez-node/txprocessor"
 	"github.com/ethereum/go-ethereum"
+	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
 )
 
@@ -56,6 +57,12 @@ const (
 	// errStrUnknownBlock is the string returned by geth when querying an
 	// unknown block
 	errStrUnknownBlock = "unknown block"
+	// erc20ConstsAttempts is the number of attempts to retrieve the ERC20
+	// metadata constants of a registered token before giving up
+	erc20ConstsAttempts = 3
+	// erc20ConstsAttemptsDelay is the delay between attempts to retrieve
+	// the ERC20 metadata constants of a registered token
+	erc20ConstsAttemptsDelay = 500 * time.Millisecond
 )
 
 var (
@@ -213,6 +220,10 @@ type Config struct {
 	StatsUpdateBlockNumDiffThreshold uint16
 	StatsUpdateFrequencyDivider      uint16
 	ChainID                          uint16
+	// Forks is the protocol upgrade schedule of the chain being synced,
+	// used to replay each batch with the tx serialization in force at the
+	// block where it was forged
+	Forks common.ForkConfig
 }
 
 // Synchronizer implements the Synchronizer type
@@ -228,6 +239,16 @@ type Synchronizer struct {
 	vars             common.SCVariables
 	stats            *StatsHolder
 	resetStateFailed bool
+	// inconsistencyDetected is set when a consistency check between the
+	// synced data and the local state fails, so that Sync triggers a
+	// targeted resync from the last consistent batch
+	inconsistencyDetected bool
+	// resyncRange holds an admin-requested block range to resync (see
+	// RequestResyncRange), consumed at the beginning of the next Sync
+	// call.  Guarded by resyncRangeMutex because the request comes from
+	// a different goroutine than the Sync loop
+	resyncRangeMutex sync.Mutex
+	resyncRange      *[2]int64
 }
 
 // NewSynchronizer creates a new Synchronizer
@@ -508,6 +529,117 @@ func (s *Synchronizer) resetIntermediateState() error {
 	return nil
 }
 
+// inconsistency flags that a consistency check between the synced data and
+// the local state has failed, so that Sync triggers a targeted resync, and
+// returns err
+func (s *Synchronizer) inconsistency(err error) error {
+	log.Errorw("Synchronizer: consistency check failed", "err", err)
+	s.inconsistencyDetected = true
+	return err
+}
+
+// resyncLastConsistentBatch performs a targeted resync after a consistency
+// check has failed: the historyDB and stateDB are rolled back to the block
+// previous to the one where the last batch was forged, so that batch and all
+// the blocks after it are fetched from ethereum and replayed.
+func (s *Synchronizer) resyncLastConsistentBatch() error {
+	lastBatch, err := s.historyDB.GetLastBatch()
+	if tracerr.Unwrap(err) == sql.ErrNoRows {
+		// No batch has been synced yet, reset to the last block
+		return tracerr.Wrap(s.resetIntermediateState())
+	} else if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastBatch: %w", err))
+	}
+	blockNum := lastBatch.EthBlockNum - 1
+	if blockNum < s.startBlockNum {
+		// There is no block before the last batch to reset to, reset to
+		// the last block
+		return tracerr.Wrap(s.resetIntermediateState())
+	}
+	block, err := s.historyDB.GetBlock(blockNum)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetBlock: %w", err))
+	}
+	log.Warnw("Synchronizer: resyncing from last consistent batch",
+		"batch", lastBatch.BatchNum, "block", block.Num)
+	if err := s.historyDB.Reorg(block.Num); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := s.resetState(block); err != nil {
+		s.resetStateFailed = true
+		return tracerr.Wrap(err)
+	}
+	s.resetStateFailed = false
+	return nil
+}
+
+// RequestResyncRange schedules a targeted resync of the block range [from,
+// to], usable to recover from a bug that corrupted the data of some blocks
+// without wiping the databases.  The resync is performed at the beginning of
+// the next Sync call: the HistoryDB is rolled back to the block previous to
+// `from` (the SQL foreign keys cascade the deletion of all the dependent
+// data) and the StateDB is replayed from the nearest checkpoint, so that all
+// the blocks from `from` are fetched from ethereum and re-ingested.  Note
+// that because batches and accounts of later blocks depend on the data of the
+// requested range, the blocks after `to` are discarded and replayed as well;
+// `to` is only used to validate that the requested range has been synced.
+func (s *Synchronizer) RequestResyncRange(from, to int64) error {
+	if from > to {
+		return tracerr.Wrap(fmt.Errorf("invalid range: from (%v) > to (%v)", from, to))
+	}
+	if from <= s.startBlockNum {
+		return tracerr.Wrap(fmt.Errorf(
+			"from (%v) must be bigger than the first synced block (%v), "+
+				"to resync from the beginning wipe the DBs instead",
+			from, s.startBlockNum))
+	}
+	lastBlock, err := s.historyDB.GetLastBlock()
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetLastBlock: %w", err))
+	}
+	if to > lastBlock.Num {
+		return tracerr.Wrap(fmt.Errorf("to (%v) is beyond the last synced block (%v)",
+			to, lastBlock.Num))
+	}
+	s.resyncRangeMutex.Lock()
+	s.resyncRange = &[2]int64{from, to}
+	s.resyncRangeMutex.Unlock()
+	return nil
+}
+
+// takeResyncRange pops the pending admin-requested resync range, if any
+func (s *Synchronizer) takeResyncRange() (from, to int64, ok bool) {
+	s.resyncRangeMutex.Lock()
+	defer s.resyncRangeMutex.Unlock()
+	if s.resyncRange == nil {
+		return 0, 0, false
+	}
+	from, to = s.resyncRange[0], s.resyncRange[1]
+	s.resyncRange = nil
+	return from, to, true
+}
+
+// doResyncRange rolls back the HistoryDB and the StateDB to the block
+// previous to `from` so that the Sync loop re-ingests all the blocks from
+// `from` (see RequestResyncRange)
+func (s *Synchronizer) doResyncRange(from, to int64) error {
+	block, err := s.historyDB.GetBlock(from - 1)
+	if err != nil {
+		return tracerr.Wrap(fmt.Errorf("historyDB.GetBlock: %w", err))
+	}
+	log.Warnw("Synchronizer: resyncing block range",
+		"from", from, "to", to, "resetToBlock", block.Num)
+	if err := s.historyDB.Reorg(block.Num); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := s.resetState(block); err != nil {
+		s.resetStateFailed = true
+		return tracerr.Wrap(err)
+	}
+	s.resetStateFailed = false
+	return nil
+}
+
 // Sync attempts to synchronize an ethereum block starting from lastSavedBlock.
 // If lastSavedBlock is nil, the lastSavedBlock value is obtained from de DB.
 // If a block is synced, it will be returned and also stored in the DB.  If a
@@ -522,6 +654,20 @@ func (s *Synchronizer) Sync(ctx context.Context,
 		}
 	}
 
+	// Apply a pending admin-requested resync (see RequestResyncRange).
+	// The discarded blocks are reported like in a reorg so that the
+	// caller continues from the new last block of the HistoryDB
+	if from, to, ok := s.takeResyncRange(); ok {
+		if err := s.doResyncRange(from, to); err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		discarded := int64(0)
+		if lastSavedBlock != nil {
+			discarded = lastSavedBlock.Num - (from - 1)
+		}
+		return nil, &discarded, nil
+	}
+
 	var nextBlockNum int64 // next block number to sync
 	if lastSavedBlock == nil {
 		// Get lastSavedBlock from History DB
@@ -593,7 +739,12 @@ func (s *Synchronizer) Sync(ctx context.Context,
 		// processed but the historyDB block was not committed due to an
 		// error.
 		if err != nil {
-			if err2 := s.resetIntermediateState(); err2 != nil {
+			if s.inconsistencyDetected {
+				s.inconsistencyDetected = false
+				if err2 := s.resyncLastConsistentBatch(); err2 != nil {
+					log.Errorw("sync inconsistency resync", "err", err2)
+				}
+			} else if err2 := s.resetIntermediateState(); err2 != nil {
 				log.Errorw("sync revert", "err", err2)
 			}
 		}
@@ -829,6 +980,23 @@ func (s *Synchronizer) resetState(block *common.Block) error {
 	return nil
 }
 
+// ethERC20ConstsWithRetry retrieves the ERC20 metadata constants of the token
+// at addr, retrying transient eth client errors before giving up
+func (s *Synchronizer) ethERC20ConstsWithRetry(addr ethCommon.Address) (*eth.ERC20Consts, error) {
+	var consts *eth.ERC20Consts
+	var err error
+	for attempt := 0; attempt < erc20ConstsAttempts; attempt++ {
+		if attempt != 0 {
+			time.Sleep(erc20ConstsAttemptsDelay)
+		}
+		if consts, err = s.ethClient.EthERC20Consts(addr); err == nil {
+			return consts, nil
+		}
+		log.Debugw("EthERC20Consts failed", "attempt", attempt, "addr", addr, "err", err)
+	}
+	return nil, tracerr.Wrap(err)
+}
+
 // rollupSync retrieves all the Rollup Smart Contract Data that happened at
 // ethBlock.blockNum with ethBlock.Hash.
 func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, error) {
@@ -879,6 +1047,13 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 		}
 		ethTxHash := evtForgeBatch.EthTxHash
 		batchNum := common.BatchNum(evtForgeBatch.BatchNum)
+		// Consistency check: batch numbers must be sequential without
+		// gaps
+		if expectedBatchNum := s.stateDB.CurrentBatch() + 1; batchNum != expectedBatchNum {
+			return nil, tracerr.Wrap(s.inconsistency(fmt.Errorf(
+				"gap in batch numbers: expecting to sync batch %v but got batch %v",
+				expectedBatchNum, batchNum)))
+		}
 		var l1UserTxs []common.L1Tx
 		// Check if this is a L1Batch to get L1 Tx from it
 		if forgeBatchArgs.L1Batch {
@@ -904,6 +1079,17 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 				}
 			}
 
+			// Consistency check: the L1UserTxs forged in this batch
+			// must have consecutive positions starting at 0
+			for i := range l1UserTxs {
+				if l1UserTxs[i].Position != i {
+					return nil, tracerr.Wrap(s.inconsistency(fmt.Errorf(
+						"gap in tx positions of batch %v: "+
+							"L1UserTx %v at position %v, expecting %v",
+						batchNum, l1UserTxs[i].TxID,
+						l1UserTxs[i].Position, i)))
+				}
+			}
 			position = len(l1UserTxs)
 		}
 
@@ -975,6 +1161,7 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 			ChainID:  s.cfg.ChainID,
 			MaxFeeTx: common.RollupConstMaxFeeIdxCoordinator,
 			MaxL1Tx:  common.RollupConstMaxL1Tx,
+			TxCodec:  s.cfg.Forks.TxCodecVersionAt(blockNum),
 		}
 		tp := txprocessor.NewTxProcessor(s.stateDB, tpc)
 
@@ -985,14 +1172,14 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 			return nil, tracerr.Wrap(err)
 		}
 		if s.stateDB.CurrentBatch() != batchNum {
-			return nil, tracerr.Wrap(fmt.Errorf("stateDB.BatchNum (%v) != "+
+			return nil, tracerr.Wrap(s.inconsistency(fmt.Errorf("stateDB.BatchNum (%v) != "+
 				"evtForgeBatch.BatchNum = (%v)",
-				s.stateDB.CurrentBatch(), batchNum))
+				s.stateDB.CurrentBatch(), batchNum)))
 		}
 		if s.stateDB.MT.Root().BigInt().Cmp(forgeBatchArgs.NewStRoot) != 0 {
-			return nil, tracerr.Wrap(fmt.Errorf("stateDB.MTRoot (%v) != "+
+			return nil, tracerr.Wrap(s.inconsistency(fmt.Errorf("stateDB.MTRoot (%v) != "+
 				"forgeBatchArgs.NewStRoot (%v)",
-				s.stateDB.MT.Root().BigInt(), forgeBatchArgs.NewStRoot))
+				s.stateDB.MT.Root().BigInt(), forgeBatchArgs.NewStRoot)))
 		}
 
 		l2Txs := make([]common.L2Tx, len(poolL2Txs))
@@ -1005,6 +1192,13 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 			l2Txs[i].EthBlockNum = blockNum
 			l2Txs[i].BatchNum = batchNum
 			l2Txs[i].Position = position
+			// Record where the encoded bytes of the tx live within
+			// the forgeBatch calldata (unknown offsets are left 0)
+			if forgeBatchArgs.L2TxBytesLen > 0 {
+				l2Txs[i].DAOffset = forgeBatchArgs.L2TxsDataOffset +
+					i*forgeBatchArgs.L2TxBytesLen
+				l2Txs[i].DALength = forgeBatchArgs.L2TxBytesLen
+			}
 			position++
 		}
 		batchData.L2Txs = l2Txs
@@ -1049,6 +1243,26 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 				int64(s.consts.Auction.BlocksPerSlot)
 		}
 
+		// Get the gas used and gas price of the forge tx so that the
+		// profitability of the batch can be computed afterwards.  Not
+		// being able to get them is not considered an error because
+		// some ethereum nodes prune old receipts.
+		var gasUsed *int64
+		var gasPrice *big.Int
+		if receipt, err := s.ethClient.EthTransactionReceipt(context.Background(),
+			ethTxHash); err != nil || receipt == nil {
+			log.Warnw("Error retrieving forge tx receipt", "tx", ethTxHash, "err", err)
+		} else {
+			gasUsedV := int64(receipt.GasUsed)
+			gasUsed = &gasUsedV
+		}
+		if forgeTx, _, err := s.ethClient.EthTransactionByHash(context.Background(),
+			ethTxHash); err != nil || forgeTx == nil {
+			log.Warnw("Error retrieving forge tx", "tx", ethTxHash, "err", err)
+		} else {
+			gasPrice = forgeTx.GasPrice()
+		}
+
 		// Get Batch information
 		batch := common.Batch{
 			BatchNum:           batchNum,
@@ -1062,6 +1276,8 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 			LastIdx:            forgeBatchArgs.NewLastIdx,
 			ExitRoot:           forgeBatchArgs.NewExitRoot,
 			SlotNum:            slotNum,
+			GasUsed:            gasUsed,
+			GasPrice:           gasPrice,
 		}
 		nextForgeL1TxsNumCpy := nextForgeL1TxsNum
 		if forgeBatchArgs.L1Batch {
@@ -1081,7 +1297,7 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 		token.EthAddr = evtAddToken.TokenAddress
 		token.EthBlockNum = blockNum
 
-		if consts, err := s.ethClient.EthERC20Consts(evtAddToken.TokenAddress); err != nil {
+		if consts, err := s.ethERC20ConstsWithRetry(evtAddToken.TokenAddress); err != nil {
 			log.Warnw("Error retrieving ERC20 token constants", "addr", evtAddToken.TokenAddress)
 			token.Name = "ERC20_ETH_ERROR"
 			token.Symbol = "ERROR"
@@ -1169,6 +1385,20 @@ func (s *Synchronizer) rollupSync(ethBlock *common.Block) (*common.RollupData, e
 		varsUpdate = true
 	}
 
+	// Apply the bucket withdraw events on top of the bucket parameters so
+	// that the Buckets of the variables track the remaining withdrawable
+	// amount of each bucket, and not only its initial parameters
+	for _, evt := range rollupEvents.UpdateBucketWithdraw {
+		if evt.NumBucket >= len(s.vars.Rollup.Buckets) {
+			return nil, tracerr.Wrap(fmt.Errorf("in RollupEventUpdateBucketWithdraw "+
+				"NumBucket (%v) >= len(Buckets) (%v)",
+				evt.NumBucket, len(s.vars.Rollup.Buckets)))
+		}
+		s.vars.Rollup.Buckets[evt.NumBucket].BlockStamp = big.NewInt(evt.BlockStamp)
+		s.vars.Rollup.Buckets[evt.NumBucket].Withdrawals = evt.Withdrawals
+		varsUpdate = true
+	}
+
 	if varsUpdate {
 		s.vars.Rollup.EthBlockNum = blockNum
 		rollupData.Vars = s.vars.Rollup.Copy()
@@ -18,6 +18,7 @@ import (
 	"github.com/arnaubennassar/hermez-node/db/statedb"
 	"github.com/arnaubennassar/hermez-node/eth"
 	"github.com/arnaubennassar/hermez-node/test"
+	"github.com/arnaubennassar/hermez-node/test/ethreplay"
 	"github.com/arnaubennassar/hermez-node/test/til"
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/jinzhu/copier"
@@ -872,3 +873,146 @@ func TestSyncForgerCommitment(t *testing.T) {
 
 	closeTestModules(t, stateDB, historyDB, l2DB)
 }
+
+func TestSyncRecordReplay(t *testing.T) {
+	stateDB, historyDB, l2DB := newTestModules(t)
+
+	// Init eth client, wrapped in a recorder that dumps every response
+	// consumed by the synchronizer
+	var timer timer
+	clientSetup := test.NewClientSetupExample()
+	clientSetup.ChainID = big.NewInt(int64(chainID))
+	bootCoordAddr := clientSetup.AuctionVariables.BootCoordinator
+	client := test.NewClient(true, &timer, &ethCommon.Address{}, clientSetup)
+	recorder := ethreplay.NewRecorder(client)
+
+	// Create Synchronizer on top of the recorder
+	s, err := NewSynchronizer(recorder, historyDB, l2DB, stateDB, Config{
+		StatsUpdateBlockNumDiffThreshold: 100,
+		StatsUpdateFrequencyDivider:      100,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// Generate a sync window with til: tokens, L1 deposits, L2 txs and
+	// forged batches
+	set := `
+		Type: Blockchain
+
+		AddToken(1)
+		AddToken(2)
+
+		CreateAccountDeposit(1) C: 2000 // Idx=256+1=257
+		CreateAccountDeposit(2) B: 1000 // Idx=256+2=258
+		CreateAccountDeposit(1) D: 500  // Idx=256+3=259
+
+		CreateAccountCoordinator(1) A // Idx=256+0=256
+
+		> batchL1 // forge L1UserTxs{nil}, freeze defined L1UserTxs{3}
+		> batchL1 // forge defined L1UserTxs{3}, freeze L1UserTxs{nil}
+		> block // blockNum=2
+
+		ForceTransfer(1) C-D: 80
+		Transfer(1) C-A: 100 (126)
+		Exit(1) C: 50 (100)
+
+		> batchL1 // forge L1UserTxs{nil}, freeze defined L1UserTxs{1}
+		> batchL1 // forge L1UserTxs{1}, freeze defined L1UserTxs{nil}
+		> block // blockNum=3
+	`
+	tc := til.NewContext(chainID, common.RollupConstMaxL1UserTx)
+	tilCfgExtra := til.ConfigExtra{
+		BootCoordAddr: bootCoordAddr,
+		CoordUser:     "A",
+	}
+	blocks, err := tc.GenerateBlocks(set)
+	require.NoError(t, err)
+	ethAddTokens(blocks, client)
+	err = tc.FillBlocksExtra(blocks, &tilCfgExtra)
+	require.NoError(t, err)
+	tc.FillBlocksL1UserTxsBatchNum(blocks)
+	err = tc.FillBlocksForgedL1UserTxs(blocks)
+	require.NoError(t, err)
+	require.NoError(t, client.CtlAddBlocks(blocks))
+
+	// Sync the whole window through the recorder
+	for {
+		syncBlock, discards, err := s.Sync(ctx, nil)
+		require.NoError(t, err)
+		require.Nil(t, discards)
+		if syncBlock == nil {
+			break
+		}
+	}
+	stats := s.Stats()
+	require.True(t, stats.Synced())
+	require.Equal(t, int64(3), stats.Sync.LastBlock.Num)
+
+	// Capture the synchronized state before the DBs are wiped
+	recordedBlocks, err := historyDB.GetAllBlocks()
+	require.NoError(t, err)
+	recordedBatches, err := historyDB.GetAllBatches()
+	require.NoError(t, err)
+	recordedAccounts, err := historyDB.GetAllAccounts()
+	require.NoError(t, err)
+	recordedL1Txs, err := historyDB.GetAllL1UserTxs()
+	require.NoError(t, err)
+	recordedL2Txs, err := historyDB.GetAllL2Txs()
+	require.NoError(t, err)
+	require.NotEqual(t, 0, len(recordedBatches))
+	require.NotEqual(t, 0, len(recordedAccounts))
+
+	// Dump the fixtures
+	dir, err := ioutil.TempDir("", "ethreplay")
+	require.NoError(t, err)
+	deleteme = append(deleteme, dir)
+	require.NoError(t, recorder.Dump(dir))
+	closeTestModules(t, stateDB, historyDB, l2DB)
+
+	// Replay the fixtures from scratch on fresh DBs, without the eth
+	// client
+	replayStateDB, replayHistoryDB, replayL2DB := newTestModules(t)
+	replay, err := ethreplay.NewClient(dir)
+	require.NoError(t, err)
+	sReplay, err := NewSynchronizer(replay, replayHistoryDB, replayL2DB,
+		replayStateDB, Config{
+			StatsUpdateBlockNumDiffThreshold: 100,
+			StatsUpdateFrequencyDivider:      100,
+		})
+	require.NoError(t, err)
+	for {
+		syncBlock, discards, err := sReplay.Sync(ctx, nil)
+		require.NoError(t, err)
+		require.Nil(t, discards)
+		if syncBlock == nil {
+			break
+		}
+	}
+	replayStats := sReplay.Stats()
+	require.True(t, replayStats.Synced())
+	assert.Equal(t, stats.Sync.LastBlock, replayStats.Sync.LastBlock)
+	assert.Equal(t, stats.Sync.LastBatch, replayStats.Sync.LastBatch)
+
+	// The replayed state matches the recorded one
+	replayBlocks, err := replayHistoryDB.GetAllBlocks()
+	require.NoError(t, err)
+	assert.Equal(t, recordedBlocks, replayBlocks)
+	replayBatches, err := replayHistoryDB.GetAllBatches()
+	require.NoError(t, err)
+	assert.Equal(t, recordedBatches, replayBatches)
+	replayAccounts, err := replayHistoryDB.GetAllAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, recordedAccounts, replayAccounts)
+	replayL1Txs, err := replayHistoryDB.GetAllL1UserTxs()
+	require.NoError(t, err)
+	assert.Equal(t, recordedL1Txs, replayL1Txs)
+	replayL2Txs, err := replayHistoryDB.GetAllL2Txs()
+	require.NoError(t, err)
+	assert.Equal(t, recordedL2Txs, replayL2Txs)
+	sdbAccounts, err := replayStateDB.TestGetAccounts()
+	require.NoError(t, err)
+	assertEqualAccountsHistoryDBStateDB(t, recordedAccounts, sdbAccounts)
+
+	closeTestModules(t, replayStateDB, replayHistoryDB, replayL2DB)
+}
@@ -2,6 +2,8 @@ package debugapi
 
 import (
 	"context"
+	"errors"
+	"math/big"
 	"net"
 	"net/http"
 	"time"
@@ -33,11 +35,17 @@ func badReq(err error, c *gin.Context) {
 	})
 }
 
+// ZKInputsGetter returns the archived ZKInputs of a batch as JSON
+type ZKInputsGetter interface {
+	Get(batchNum common.BatchNum) ([]byte, error)
+}
+
 // DebugAPI is an http API with debugging endpoints
 type DebugAPI struct {
-	addr    string
-	stateDB *statedb.StateDB // synchronizer statedb
-	sync    *synchronizer.Synchronizer
+	addr     string
+	stateDB  *statedb.StateDB // synchronizer statedb
+	sync     *synchronizer.Synchronizer
+	zkInputs ZKInputsGetter
 }
 
 // NewDebugAPI creates a new DebugAPI
@@ -49,6 +57,32 @@ func NewDebugAPI(addr string, stateDB *statedb.StateDB, sync *synchronizer.Synch
 	}
 }
 
+// SetZKInputsGetter enables the ZKInputs retrieval endpoint, serving the
+// archived ZKInputs from the given getter
+func (a *DebugAPI) SetZKInputsGetter(zkInputs ZKInputsGetter) {
+	a.zkInputs = zkInputs
+}
+
+func (a *DebugAPI) handleZKInputs(c *gin.Context) {
+	if a.zkInputs == nil {
+		badReq(errors.New("ZKInputs archiving is not enabled"), c)
+		return
+	}
+	uri := struct {
+		BatchNum uint32
+	}{}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		badReq(err, c)
+		return
+	}
+	data, err := a.zkInputs.Get(common.BatchNum(uri.BatchNum))
+	if err != nil {
+		badReq(err, c)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", data)
+}
+
 func (a *DebugAPI) handleAccount(c *gin.Context) {
 	uri := struct {
 		Idx uint32
@@ -96,6 +130,48 @@ func (a *DebugAPI) handleMTRoot(c *gin.Context) {
 	c.JSON(http.StatusOK, root)
 }
 
+func (a *DebugAPI) handleMTConsistency(c *gin.Context) {
+	var expectedRoot *big.Int
+	if rootStr := c.Query("expectedRoot"); rootStr != "" {
+		root, ok := new(big.Int).SetString(rootStr, 10)
+		if !ok {
+			badReq(errors.New("expectedRoot is not a valid decimal number"), c)
+			return
+		}
+		expectedRoot = root
+	}
+	report, err := a.stateDB.CheckConsistency(expectedRoot)
+	if err != nil {
+		badReq(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (a *DebugAPI) handlePrune(c *gin.Context) {
+	if err := a.stateDB.DeleteOldCheckpoints(); err != nil {
+		badReq(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func (a *DebugAPI) handleResyncRange(c *gin.Context) {
+	query := struct {
+		From int64 `form:"from" binding:"required"`
+		To   int64 `form:"to" binding:"required"`
+	}{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		badReq(err, c)
+		return
+	}
+	if err := a.sync.RequestResyncRange(query.From, query.To); err != nil {
+		badReq(err, c)
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
 func (a *DebugAPI) handleSyncStats(c *gin.Context) {
 	stats := a.sync.Stats()
 	c.JSON(http.StatusOK, stats)
@@ -119,8 +195,24 @@ func (a *DebugAPI) Run(ctx context.Context) error {
 	debugAPI.GET("sdb/accounts", a.handleAccounts)
 	debugAPI.GET("sdb/accounts/:Idx", a.handleAccount)
 
+	// Walk all the account leaves of the last checkpoint, recompute the
+	// merkle root and report divergent leaves.  The optional expectedRoot
+	// query parameter (decimal) is compared against the stored root.
+	// This is an expensive endpoint on a big StateDB.
+	debugAPI.GET("sdb/consistency", a.handleMTConsistency)
+
 	debugAPI.GET("sync/stats", a.handleSyncStats)
 
+	// Schedule a targeted resync of the block range [from, to] without
+	// wiping the databases (see synchronizer.RequestResyncRange)
+	debugAPI.POST("sync/resync", a.handleResyncRange)
+
+	// Trigger a manual prune of old StateDB checkpoints following the
+	// configured retention policy
+	debugAPI.POST("sdb/prune", a.handlePrune)
+
+	debugAPI.GET("zkinputs/:BatchNum", a.handleZKInputs)
+
 	debugAPIServer := &http.Server{
 		Handler: api,
 		// Use some hardcoded numbers that are suitable for testing
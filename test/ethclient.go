@@ -573,6 +573,19 @@ func (c *Client) CtlRollback() {
 	c.blocks[c.blockNum+1] = blockNext
 }
 
+// CtlReorg discards the last numBlocks mined blocks and mines the given
+// alternative blocks in their place, simulating a blockchain reorg.  The hash
+// counter is not rewound, so the alternative blocks are guaranteed to have
+// different hashes than the discarded ones.  Use this together with the til
+// `> reorg numBlocks` directive to exercise the synchronizer reorg handling.
+// Not thread safe.
+func (c *Client) CtlReorg(numBlocks int64, blocks []common.BlockData) error {
+	for i := int64(0); i < numBlocks; i++ {
+		c.CtlRollback()
+	}
+	return tracerr.Wrap(c.CtlAddBlocks(blocks))
+}
+
 //
 // Ethereum
 //
@@ -633,6 +646,23 @@ func (c *Client) EthKeyStore() *ethKeystore.KeyStore {
 	return nil
 }
 
+// EthTransactor returns a bind.TransactOpts whose signer returns the
+// transaction unmodified
+func (c *Client) EthTransactor() (*bind.TransactOpts, error) {
+	if c.addr == nil {
+		return nil, tracerr.Wrap(eth.ErrAccountNil)
+	}
+	// NOTE: For now Client doesn't simulate signatures
+	return &bind.TransactOpts{
+		From: *c.addr,
+		Signer: func(address ethCommon.Address,
+			tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		},
+		Context: context.Background(),
+	}, nil
+}
+
 // EthCall runs the transaction as a call (without paying) in the local node at
 // blockNum.
 func (c *Client) EthCall(ctx context.Context, tx *types.Transaction,
@@ -677,6 +707,27 @@ func (c *Client) EthTransactionReceipt(ctx context.Context,
 	return nil, nil
 }
 
+// EthTransactionByHash returns the transaction with the given txHash, and
+// whether it is pending
+func (c *Client) EthTransactionByHash(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Transaction, bool, error) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	for i := int64(0); i < c.blockNum; i++ {
+		b := c.blocks[i]
+		tx, ok := b.Rollup.Txs[txHash]
+		if !ok {
+			tx, ok = b.Auction.Txs[txHash]
+		}
+		if ok {
+			return tx, false, nil
+		}
+	}
+
+	return nil, false, tracerr.Wrap(fmt.Errorf("transaction not found"))
+}
+
 // CtlAddERC20 adds an ERC20 token to the blockchain.
 func (c *Client) CtlAddERC20(tokenAddr ethCommon.Address, constants eth.ERC20Consts) {
 	nextBlock := c.nextBlock()
@@ -852,6 +903,12 @@ func (c *Client) RollupRegisterTokensCount() (*big.Int, error) {
 	return nil, tracerr.Wrap(errTODO)
 }
 
+// RollupTokenHEZBalance is the interface to call the smart contract function
+func (c *Client) RollupTokenHEZBalance(addr ethCommon.Address) (*big.Int, error) {
+	log.Error("TODO")
+	return nil, tracerr.Wrap(errTODO)
+}
+
 // RollupLastForgedBatch is the interface to call the smart contract function
 func (c *Client) RollupLastForgedBatch() (int64, error) {
 	c.rw.RLock()
@@ -1142,6 +1199,31 @@ func (c *Client) RollupEventsByBlock(blockNum int64,
 	return &block.Rollup.Events, nil
 }
 
+// RollupEventsByBlockRange returns the rollup events of the blocks in the
+// range [fromBlock, toBlock] grouped per block, skipping blocks without
+// events
+func (c *Client) RollupEventsByBlockRange(fromBlock, toBlock int64) ([]eth.RollupBlockEvents, error) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	if fromBlock > toBlock {
+		return nil, tracerr.Wrap(fmt.Errorf("invalid block range: [%v, %v]", fromBlock, toBlock))
+	}
+	var blockEvents []eth.RollupBlockEvents
+	for blockNum := fromBlock; blockNum <= toBlock; blockNum++ {
+		block, ok := c.blocks[blockNum]
+		if !ok {
+			return nil, tracerr.Wrap(fmt.Errorf("Block %v doesn't exist", blockNum))
+		}
+		blockEvents = append(blockEvents, eth.RollupBlockEvents{
+			BlockNum:  blockNum,
+			BlockHash: block.Eth.Hash,
+			Events:    block.Rollup.Events,
+		})
+	}
+	return blockEvents, nil
+}
+
 // RollupEventInit returns the initialize event with its corresponding block number
 func (c *Client) RollupEventInit(genesisBlockNum int64) (*eth.RollupEventInitialize, int64, error) {
 	vars := c.blocks[0].Rollup.Vars
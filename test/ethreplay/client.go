@@ -0,0 +1,239 @@
+package ethreplay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/eth"
+	"github.com/ethereum/go-ethereum"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Client is a replay eth.ClientInterface that serves the fixtures recorded by
+// a Recorder, returning byte-for-byte the same responses that the wrapped
+// client returned during the recorded sync window.  Only the methods consumed
+// by the Synchronizer are implemented: calling any other method panics via
+// the embedded nil interface
+type Client struct {
+	eth.ClientInterface
+	blocks    map[int64]*BlockFixture
+	blockNums []int64
+	consts    ConstsFixture
+}
+
+// NewClient returns a new replay Client loading the fixtures from the given
+// directory
+func NewClient(dir string) (*Client, error) {
+	c := &Client{blocks: make(map[int64]*BlockFixture)}
+	if err := loadJSON(path.Join(dir, constsFileName), &c.consts); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "block_") {
+			continue
+		}
+		var fixture BlockFixture
+		if err := loadJSON(path.Join(dir, file.Name()), &fixture); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if fixture.Block == nil {
+			return nil, tracerr.Wrap(fmt.Errorf("fixture %v has no block", file.Name()))
+		}
+		c.blocks[fixture.Block.Num] = &fixture
+		c.blockNums = append(c.blockNums, fixture.Block.Num)
+	}
+	if len(c.blockNums) == 0 {
+		return nil, tracerr.Wrap(fmt.Errorf("no block fixtures found at %v", dir))
+	}
+	sort.Slice(c.blockNums, func(i, j int) bool { return c.blockNums[i] < c.blockNums[j] })
+	return c, nil
+}
+
+func loadJSON(fileName string, v interface{}) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(json.Unmarshal(data, v))
+}
+
+// EthChainID returns the recorded chain id
+func (c *Client) EthChainID() (*big.Int, error) {
+	return c.consts.ChainID, nil
+}
+
+// RollupConstants returns the recorded rollup constants
+func (c *Client) RollupConstants() (*common.RollupConstants, error) {
+	return c.consts.RollupConstants, nil
+}
+
+// AuctionConstants returns the recorded auction constants
+func (c *Client) AuctionConstants() (*common.AuctionConstants, error) {
+	return c.consts.AuctionConstants, nil
+}
+
+// WDelayerConstants returns the recorded wdelayer constants
+func (c *Client) WDelayerConstants() (*common.WDelayerConstants, error) {
+	return c.consts.WDelayerConstants, nil
+}
+
+// RollupEventInit returns the recorded rollup initialization event
+func (c *Client) RollupEventInit(genesisBlockNum int64) (*eth.RollupEventInitialize,
+	int64, error) {
+	return c.consts.RollupEventInit, c.consts.RollupInitBlockNum, nil
+}
+
+// AuctionEventInit returns the recorded auction initialization event
+func (c *Client) AuctionEventInit(genesisBlockNum int64) (*eth.AuctionEventInitialize,
+	int64, error) {
+	return c.consts.AuctionEventInit, c.consts.AuctionInitBlockNum, nil
+}
+
+// WDelayerEventInit returns the recorded wdelayer initialization event
+func (c *Client) WDelayerEventInit(genesisBlockNum int64) (*eth.WDelayerEventInitialize,
+	int64, error) {
+	return c.consts.WDelayerEventInit, c.consts.WDelayerInitBlockNum, nil
+}
+
+// EthERC20Consts returns the recorded constants of the given ERC20 token
+func (c *Client) EthERC20Consts(tokenAddr ethCommon.Address) (*eth.ERC20Consts, error) {
+	consts, ok := c.consts.ERC20Consts[tokenAddr]
+	if !ok {
+		return nil, tracerr.Wrap(fmt.Errorf("no recorded ERC20Consts for %v", tokenAddr))
+	}
+	return consts, nil
+}
+
+// EthBlockByNumber returns the recorded block.  With number == -1 the last
+// recorded block is returned, and requesting a block past the recorded window
+// returns ethereum.NotFound, just like a real client at the chain head
+func (c *Client) EthBlockByNumber(ctx context.Context, number int64) (*common.Block, error) {
+	if number == -1 {
+		number = c.blockNums[len(c.blockNums)-1]
+	}
+	fixture, ok := c.blocks[number]
+	if !ok {
+		return nil, tracerr.Wrap(ethereum.NotFound)
+	}
+	return fixture.Block, nil
+}
+
+func (c *Client) blockFixture(blockNum int64, blockHash *ethCommon.Hash) (*BlockFixture, error) {
+	fixture, ok := c.blocks[blockNum]
+	if !ok {
+		return nil, tracerr.Wrap(fmt.Errorf("no fixture for block %v", blockNum))
+	}
+	if blockHash != nil && *blockHash != fixture.Block.Hash {
+		return nil, tracerr.Wrap(fmt.Errorf(
+			"hash mismatch, requested %v got %v", blockHash, fixture.Block.Hash))
+	}
+	return fixture, nil
+}
+
+// RollupEventsByBlock returns the recorded rollup events of the block
+func (c *Client) RollupEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.RollupEvents, error) {
+	fixture, err := c.blockFixture(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if fixture.RollupEvents == nil {
+		events := eth.NewRollupEvents()
+		return &events, nil
+	}
+	return fixture.RollupEvents, nil
+}
+
+// AuctionEventsByBlock returns the recorded auction events of the block
+func (c *Client) AuctionEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.AuctionEvents, error) {
+	fixture, err := c.blockFixture(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if fixture.AuctionEvents == nil {
+		events := eth.NewAuctionEvents()
+		return &events, nil
+	}
+	return fixture.AuctionEvents, nil
+}
+
+// WDelayerEventsByBlock returns the recorded wdelayer events of the block
+func (c *Client) WDelayerEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.WDelayerEvents, error) {
+	fixture, err := c.blockFixture(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if fixture.WDelayerEvents == nil {
+		events := eth.NewWDelayerEvents()
+		return &events, nil
+	}
+	return fixture.WDelayerEvents, nil
+}
+
+// RollupForgeBatchArgs returns the recorded args of the forgeBatch tx
+func (c *Client) RollupForgeBatchArgs(ethTxHash ethCommon.Hash,
+	l1UserTxsLen uint16) (*eth.RollupForgeBatchArgs, *ethCommon.Address, error) {
+	for _, fixture := range c.blocks {
+		if forgeBatch, ok := fixture.ForgeBatchArgs[ethTxHash]; ok {
+			sender := forgeBatch.Sender
+			return forgeBatch.Args, &sender, nil
+		}
+	}
+	return nil, nil, tracerr.Wrap(fmt.Errorf(
+		"no recorded forgeBatch args for tx %v", ethTxHash))
+}
+
+// RollupLastForgedBatch returns the recorded last forged batch, which is the
+// value at the head of the recorded window
+func (c *Client) RollupLastForgedBatch() (int64, error) {
+	return c.consts.LastForgedBatch, nil
+}
+
+// AuctionCanForge returns the recorded response of whether the forger can
+// forge at the given block
+func (c *Client) AuctionCanForge(forger ethCommon.Address, blockNum int64) (bool, error) {
+	canForge, ok := c.consts.CanForge[canForgeKey(forger, blockNum)]
+	if !ok {
+		return false, tracerr.Wrap(fmt.Errorf(
+			"no recorded AuctionCanForge for %v at block %v", forger, blockNum))
+	}
+	return canForge, nil
+}
+
+// EthTransactionByHash returns the recorded transaction.  Recorded
+// transactions were mined, so pending is always false
+func (c *Client) EthTransactionByHash(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Transaction, bool, error) {
+	for _, fixture := range c.blocks {
+		if tx, ok := fixture.Txs[txHash]; ok {
+			return tx, false, nil
+		}
+	}
+	return nil, false, tracerr.Wrap(fmt.Errorf("no recorded transaction %v", txHash))
+}
+
+// EthTransactionReceipt returns the recorded transaction receipt
+func (c *Client) EthTransactionReceipt(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Receipt, error) {
+	for _, fixture := range c.blocks {
+		if receipt, ok := fixture.Receipts[txHash]; ok {
+			return receipt, nil
+		}
+	}
+	return nil, tracerr.Wrap(fmt.Errorf("no recorded receipt for tx %v", txHash))
+}
@@ -0,0 +1,385 @@
+// Package ethreplay provides a Recorder that dumps every eth client response
+// consumed by the Synchronizer during a sync window to fixture files, and a
+// replay eth client that serves those fixtures, enabling byte-for-byte
+// reproducible synchronizer integration tests without an Ethereum node.
+//
+// The fixtures are stored as JSON files in a directory: a `consts.json` with
+// the chain constants and initialization events, and a `block_%09d.json` per
+// block with the block header, the contract events and the forgeBatch args,
+// txs and receipts consumed while syncing that block.
+package ethreplay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/eth"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hermeznetwork/tracerr"
+)
+
+const (
+	constsFileName   = "consts.json"
+	blockFileNameFmt = "block_%09d.json"
+)
+
+// ForgeBatchFixture holds a recorded RollupForgeBatchArgs response
+type ForgeBatchFixture struct {
+	Args   *eth.RollupForgeBatchArgs
+	Sender ethCommon.Address
+}
+
+// BlockFixture holds every recorded response consumed while syncing a single
+// block
+type BlockFixture struct {
+	Block          *common.Block
+	RollupEvents   *eth.RollupEvents
+	AuctionEvents  *eth.AuctionEvents
+	WDelayerEvents *eth.WDelayerEvents
+	// ForgeBatchArgs are the recorded RollupForgeBatchArgs responses of
+	// the forgeBatch txs of the block, keyed by eth tx hash
+	ForgeBatchArgs map[ethCommon.Hash]*ForgeBatchFixture
+	// Txs and Receipts are the recorded EthTransactionByHash and
+	// EthTransactionReceipt responses of the forgeBatch txs of the block,
+	// keyed by eth tx hash
+	Txs      map[ethCommon.Hash]*types.Transaction
+	Receipts map[ethCommon.Hash]*types.Receipt
+}
+
+// ConstsFixture holds the recorded chain constants and initialization events,
+// which don't belong to a particular block
+type ConstsFixture struct {
+	ChainID           *big.Int
+	RollupConstants   *common.RollupConstants
+	AuctionConstants  *common.AuctionConstants
+	WDelayerConstants *common.WDelayerConstants
+
+	RollupEventInit      *eth.RollupEventInitialize
+	RollupInitBlockNum   int64
+	AuctionEventInit     *eth.AuctionEventInitialize
+	AuctionInitBlockNum  int64
+	WDelayerEventInit    *eth.WDelayerEventInitialize
+	WDelayerInitBlockNum int64
+
+	// ERC20Consts are the recorded EthERC20Consts responses keyed by
+	// token address
+	ERC20Consts map[ethCommon.Address]*eth.ERC20Consts
+	// LastForgedBatch is the last observed RollupLastForgedBatch
+	// response, which at dump time is the value at the recorded chain
+	// head
+	LastForgedBatch int64
+	// CanForge are the recorded AuctionCanForge responses, keyed by
+	// canForgeKey (the sanity checks of the synchronizer repeat the same
+	// calls during a replay)
+	CanForge map[string]bool
+}
+
+// canForgeKey is the CanForge fixture key of an AuctionCanForge call
+func canForgeKey(forger ethCommon.Address, blockNum int64) string {
+	return fmt.Sprintf("%v-%v", forger.Hex(), blockNum)
+}
+
+// Recorder wraps an eth.ClientInterface recording every response consumed by
+// the Synchronizer, so that it can be dumped with Dump and replayed later
+// with a replay Client.  The rest of the methods are forwarded untouched
+type Recorder struct {
+	eth.ClientInterface
+	mutex  sync.Mutex
+	blocks map[int64]*BlockFixture
+	consts ConstsFixture
+}
+
+// NewRecorder returns a new Recorder wrapping the given client
+func NewRecorder(client eth.ClientInterface) *Recorder {
+	return &Recorder{
+		ClientInterface: client,
+		blocks:          make(map[int64]*BlockFixture),
+		consts: ConstsFixture{
+			ERC20Consts: make(map[ethCommon.Address]*eth.ERC20Consts),
+			CanForge:    make(map[string]bool),
+		},
+	}
+}
+
+func (r *Recorder) blockFixture(blockNum int64) *BlockFixture {
+	if _, ok := r.blocks[blockNum]; !ok {
+		r.blocks[blockNum] = &BlockFixture{
+			ForgeBatchArgs: make(map[ethCommon.Hash]*ForgeBatchFixture),
+			Txs:            make(map[ethCommon.Hash]*types.Transaction),
+			Receipts:       make(map[ethCommon.Hash]*types.Receipt),
+		}
+	}
+	return r.blocks[blockNum]
+}
+
+// lastBlockFixture returns the fixture of the last recorded block, where the
+// responses consumed while syncing the block currently being synced are
+// recorded
+func (r *Recorder) lastBlockFixture() *BlockFixture {
+	var lastBlockNum int64
+	for blockNum := range r.blocks {
+		if blockNum > lastBlockNum {
+			lastBlockNum = blockNum
+		}
+	}
+	return r.blockFixture(lastBlockNum)
+}
+
+// EthChainID returns the recorded chain id
+func (r *Recorder) EthChainID() (*big.Int, error) {
+	chainID, err := r.ClientInterface.EthChainID()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.ChainID = chainID
+	return chainID, nil
+}
+
+// RollupConstants returns the recorded rollup constants
+func (r *Recorder) RollupConstants() (*common.RollupConstants, error) {
+	consts, err := r.ClientInterface.RollupConstants()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.RollupConstants = consts
+	return consts, nil
+}
+
+// AuctionConstants returns the recorded auction constants
+func (r *Recorder) AuctionConstants() (*common.AuctionConstants, error) {
+	consts, err := r.ClientInterface.AuctionConstants()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.AuctionConstants = consts
+	return consts, nil
+}
+
+// WDelayerConstants returns the recorded wdelayer constants
+func (r *Recorder) WDelayerConstants() (*common.WDelayerConstants, error) {
+	consts, err := r.ClientInterface.WDelayerConstants()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.WDelayerConstants = consts
+	return consts, nil
+}
+
+// RollupEventInit returns the recorded rollup initialization event
+func (r *Recorder) RollupEventInit(genesisBlockNum int64) (*eth.RollupEventInitialize,
+	int64, error) {
+	init, blockNum, err := r.ClientInterface.RollupEventInit(genesisBlockNum)
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.RollupEventInit = init
+	r.consts.RollupInitBlockNum = blockNum
+	return init, blockNum, nil
+}
+
+// AuctionEventInit returns the recorded auction initialization event
+func (r *Recorder) AuctionEventInit(genesisBlockNum int64) (*eth.AuctionEventInitialize,
+	int64, error) {
+	init, blockNum, err := r.ClientInterface.AuctionEventInit(genesisBlockNum)
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.AuctionEventInit = init
+	r.consts.AuctionInitBlockNum = blockNum
+	return init, blockNum, nil
+}
+
+// WDelayerEventInit returns the recorded wdelayer initialization event
+func (r *Recorder) WDelayerEventInit(genesisBlockNum int64) (*eth.WDelayerEventInitialize,
+	int64, error) {
+	init, blockNum, err := r.ClientInterface.WDelayerEventInit(genesisBlockNum)
+	if err != nil {
+		return nil, 0, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.WDelayerEventInit = init
+	r.consts.WDelayerInitBlockNum = blockNum
+	return init, blockNum, nil
+}
+
+// EthERC20Consts returns the recorded constants of the given ERC20 token
+func (r *Recorder) EthERC20Consts(tokenAddr ethCommon.Address) (*eth.ERC20Consts, error) {
+	consts, err := r.ClientInterface.EthERC20Consts(tokenAddr)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.ERC20Consts[tokenAddr] = consts
+	return consts, nil
+}
+
+// EthBlockByNumber returns the recorded block
+func (r *Recorder) EthBlockByNumber(ctx context.Context, number int64) (*common.Block, error) {
+	block, err := r.ClientInterface.EthBlockByNumber(ctx, number)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.blockFixture(block.Num).Block = block
+	return block, nil
+}
+
+// RollupEventsByBlock returns the recorded rollup events of the block
+func (r *Recorder) RollupEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.RollupEvents, error) {
+	events, err := r.ClientInterface.RollupEventsByBlock(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.blockFixture(blockNum).RollupEvents = events
+	return events, nil
+}
+
+// AuctionEventsByBlock returns the recorded auction events of the block
+func (r *Recorder) AuctionEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.AuctionEvents, error) {
+	events, err := r.ClientInterface.AuctionEventsByBlock(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.blockFixture(blockNum).AuctionEvents = events
+	return events, nil
+}
+
+// WDelayerEventsByBlock returns the recorded wdelayer events of the block
+func (r *Recorder) WDelayerEventsByBlock(blockNum int64,
+	blockHash *ethCommon.Hash) (*eth.WDelayerEvents, error) {
+	events, err := r.ClientInterface.WDelayerEventsByBlock(blockNum, blockHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.blockFixture(blockNum).WDelayerEvents = events
+	return events, nil
+}
+
+// RollupForgeBatchArgs returns the recorded args of the forgeBatch tx
+func (r *Recorder) RollupForgeBatchArgs(ethTxHash ethCommon.Hash,
+	l1UserTxsLen uint16) (*eth.RollupForgeBatchArgs, *ethCommon.Address, error) {
+	args, sender, err := r.ClientInterface.RollupForgeBatchArgs(ethTxHash, l1UserTxsLen)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// the forgeBatch args are recorded in the fixture of the block that
+	// contains the ForgeBatch event currently being synced, which is the
+	// last recorded block
+	r.lastBlockFixture().ForgeBatchArgs[ethTxHash] = &ForgeBatchFixture{
+		Args:   args,
+		Sender: *sender,
+	}
+	return args, sender, nil
+}
+
+// RollupLastForgedBatch returns the recorded last forged batch
+func (r *Recorder) RollupLastForgedBatch() (int64, error) {
+	lastForgedBatch, err := r.ClientInterface.RollupLastForgedBatch()
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.LastForgedBatch = lastForgedBatch
+	return lastForgedBatch, nil
+}
+
+// AuctionCanForge returns the recorded response of whether the forger can
+// forge at the given block
+func (r *Recorder) AuctionCanForge(forger ethCommon.Address, blockNum int64) (bool, error) {
+	canForge, err := r.ClientInterface.AuctionCanForge(forger, blockNum)
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.consts.CanForge[canForgeKey(forger, blockNum)] = canForge
+	return canForge, nil
+}
+
+// EthTransactionByHash returns the recorded transaction
+func (r *Recorder) EthTransactionByHash(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Transaction, bool, error) {
+	tx, pending, err := r.ClientInterface.EthTransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, false, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastBlockFixture().Txs[txHash] = tx
+	return tx, pending, nil
+}
+
+// EthTransactionReceipt returns the recorded transaction receipt
+func (r *Recorder) EthTransactionReceipt(ctx context.Context,
+	txHash ethCommon.Hash) (*types.Receipt, error) {
+	receipt, err := r.ClientInterface.EthTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastBlockFixture().Receipts[txHash] = receipt
+	return receipt, nil
+}
+
+// Dump writes the recorded fixtures to the given directory, one file per
+// block plus a consts.json file
+func (r *Recorder) Dump(dir string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := dumpJSON(path.Join(dir, constsFileName), r.consts); err != nil {
+		return tracerr.Wrap(err)
+	}
+	for blockNum, fixture := range r.blocks {
+		fileName := fmt.Sprintf(blockFileNameFmt, blockNum)
+		if err := dumpJSON(path.Join(dir, fileName), fixture); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func dumpJSON(fileName string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(ioutil.WriteFile(fileName, data, 0o600))
+}
@@ -0,0 +1,172 @@
+package ethreplay
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/arnaubennassar/hermez-node/eth"
+	"github.com/arnaubennassar/hermez-node/test"
+	"github.com/ethereum/go-ethereum"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timer struct {
+	time int64
+}
+
+func (t *timer) Time() int64 {
+	t.time++
+	return t.time
+}
+
+// assertSameJSON checks that the replayed response encodes to the very same
+// bytes as the recorded one
+func assertSameJSON(t *testing.T, recorded, replayed interface{}) {
+	recordedJSON, err := json.Marshal(recorded)
+	require.NoError(t, err)
+	replayedJSON, err := json.Marshal(replayed)
+	require.NoError(t, err)
+	assert.Equal(t, recordedJSON, replayedJSON)
+}
+
+func TestRecordReplay(t *testing.T) {
+	// Record a window of calls against the test eth client
+	var timer timer
+	clientSetup := test.NewClientSetupExample()
+	client := test.NewClient(true, &timer, &ethCommon.Address{}, clientSetup)
+	tokenAddr := ethCommon.HexToAddress("0x1b36a4ded4df40248c0e0e52cea5edc9a298b721")
+	client.CtlAddERC20(tokenAddr, eth.ERC20Consts{
+		Name: "Dai Stablecoin", Symbol: "DAI", Decimals: 18})
+	client.CtlMineBlock()
+
+	ctx := context.Background()
+	recorder := NewRecorder(client)
+	chainID, err := recorder.EthChainID()
+	require.NoError(t, err)
+	rollupConstants, err := recorder.RollupConstants()
+	require.NoError(t, err)
+	auctionConstants, err := recorder.AuctionConstants()
+	require.NoError(t, err)
+	wDelayerConstants, err := recorder.WDelayerConstants()
+	require.NoError(t, err)
+	rollupInit, rollupInitBlockNum, err := recorder.RollupEventInit(1)
+	require.NoError(t, err)
+	auctionInit, auctionInitBlockNum, err := recorder.AuctionEventInit(1)
+	require.NoError(t, err)
+	wDelayerInit, wDelayerInitBlockNum, err := recorder.WDelayerEventInit(1)
+	require.NoError(t, err)
+	erc20Consts, err := recorder.EthERC20Consts(tokenAddr)
+	require.NoError(t, err)
+	lastForgedBatch, err := recorder.RollupLastForgedBatch()
+	require.NoError(t, err)
+	canForge, err := recorder.AuctionCanForge(
+		clientSetup.AuctionVariables.BootCoordinator, 10)
+	require.NoError(t, err)
+	blocks := make(map[int64]interface{})
+	rollupEvents := make(map[int64]interface{})
+	auctionEvents := make(map[int64]interface{})
+	wDelayerEvents := make(map[int64]interface{})
+	for blockNum := int64(1); blockNum <= 2; blockNum++ {
+		block, err := recorder.EthBlockByNumber(ctx, blockNum)
+		require.NoError(t, err)
+		blocks[blockNum] = block
+		rollupEvents[blockNum], err = recorder.RollupEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+		auctionEvents[blockNum], err = recorder.AuctionEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+		wDelayerEvents[blockNum], err = recorder.WDelayerEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+	}
+
+	// Dump the fixtures and load them in a replay client
+	dir, err := ioutil.TempDir("", "ethreplay")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, recorder.Dump(dir))
+	replay, err := NewClient(dir)
+	require.NoError(t, err)
+
+	// The replayed responses match the recorded ones byte for byte
+	replayChainID, err := replay.EthChainID()
+	require.NoError(t, err)
+	assertSameJSON(t, chainID, replayChainID)
+	replayRollupConstants, err := replay.RollupConstants()
+	require.NoError(t, err)
+	assertSameJSON(t, rollupConstants, replayRollupConstants)
+	replayAuctionConstants, err := replay.AuctionConstants()
+	require.NoError(t, err)
+	assertSameJSON(t, auctionConstants, replayAuctionConstants)
+	replayWDelayerConstants, err := replay.WDelayerConstants()
+	require.NoError(t, err)
+	assertSameJSON(t, wDelayerConstants, replayWDelayerConstants)
+	replayRollupInit, replayRollupInitBlockNum, err := replay.RollupEventInit(1)
+	require.NoError(t, err)
+	assert.Equal(t, rollupInitBlockNum, replayRollupInitBlockNum)
+	assertSameJSON(t, rollupInit, replayRollupInit)
+	replayAuctionInit, replayAuctionInitBlockNum, err := replay.AuctionEventInit(1)
+	require.NoError(t, err)
+	assert.Equal(t, auctionInitBlockNum, replayAuctionInitBlockNum)
+	assertSameJSON(t, auctionInit, replayAuctionInit)
+	replayWDelayerInit, replayWDelayerInitBlockNum, err := replay.WDelayerEventInit(1)
+	require.NoError(t, err)
+	assert.Equal(t, wDelayerInitBlockNum, replayWDelayerInitBlockNum)
+	assertSameJSON(t, wDelayerInit, replayWDelayerInit)
+	replayERC20Consts, err := replay.EthERC20Consts(tokenAddr)
+	require.NoError(t, err)
+	assertSameJSON(t, erc20Consts, replayERC20Consts)
+	replayLastForgedBatch, err := replay.RollupLastForgedBatch()
+	require.NoError(t, err)
+	assert.Equal(t, lastForgedBatch, replayLastForgedBatch)
+	replayCanForge, err := replay.AuctionCanForge(
+		clientSetup.AuctionVariables.BootCoordinator, 10)
+	require.NoError(t, err)
+	assert.Equal(t, canForge, replayCanForge)
+	for blockNum := int64(1); blockNum <= 2; blockNum++ {
+		block, err := replay.EthBlockByNumber(ctx, blockNum)
+		require.NoError(t, err)
+		assertSameJSON(t, blocks[blockNum], block)
+		replayRollupEvents, err := replay.RollupEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+		assertSameJSON(t, rollupEvents[blockNum], replayRollupEvents)
+		replayAuctionEvents, err := replay.AuctionEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+		assertSameJSON(t, auctionEvents[blockNum], replayAuctionEvents)
+		replayWDelayerEvents, err := replay.WDelayerEventsByBlock(blockNum, &block.Hash)
+		require.NoError(t, err)
+		assertSameJSON(t, wDelayerEvents[blockNum], replayWDelayerEvents)
+	}
+	// number == -1 returns the last recorded block, like a real client at
+	// the chain head
+	lastBlock, err := replay.EthBlockByNumber(ctx, -1)
+	require.NoError(t, err)
+	assertSameJSON(t, blocks[2], lastBlock)
+
+	// Calls beyond the recorded window error out
+	_, err = replay.EthBlockByNumber(ctx, 99)
+	assert.Equal(t, ethereum.NotFound, tracerr.Unwrap(err))
+	_, err = replay.RollupEventsByBlock(99, nil)
+	assert.Error(t, err)
+	// A hash mismatch means the replayed sync diverged from the recording
+	badHash := ethCommon.HexToHash("0x01")
+	_, err = replay.RollupEventsByBlock(1, &badHash)
+	assert.Error(t, err)
+	_, err = replay.EthERC20Consts(ethCommon.HexToAddress(
+		"0xa631be6995643e6085330a31b9e1af48dd5d6b7f"))
+	assert.Error(t, err)
+	_, err = replay.AuctionCanForge(clientSetup.AuctionVariables.BootCoordinator, 11)
+	assert.Error(t, err)
+	_, _, err = replay.RollupForgeBatchArgs(ethCommon.Hash{}, 0)
+	assert.Error(t, err)
+	_, _, err = replay.EthTransactionByHash(ctx, ethCommon.Hash{})
+	assert.Error(t, err)
+	_, err = replay.EthTransactionReceipt(ctx, ethCommon.Hash{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// LoadGen generates large volumes of accounts and txs with a realistic
+// statistical profile, meant to populate databases for performance work: a
+// few tokens concentrate most of the traffic (Zipf distribution), fee
+// selectors cluster around the commonly used values and amounts are
+// log-normally distributed.  Generation is deterministic for a given seed so
+// that datasets are reproducible.
+// WARNING: like the rest of the generators of this package, the generated txs
+// don't follow the protocol (the pool txs are properly signed, but are not
+// checked against any state tree).
+type LoadGen struct {
+	rng       *rand.Rand
+	tokenZipf *rand.Zipf
+	tokens    []common.Token
+	accounts  []common.Account
+	privKs    map[common.Idx]babyjub.PrivateKey
+	txCounter uint64
+}
+
+// loadGenFeeMean and loadGenFeeStdDev describe the normal distribution used
+// to sample fee selectors, centered on the range commonly seen in production
+const (
+	loadGenFeeMean   = 126
+	loadGenFeeStdDev = 20
+)
+
+// NewLoadGen creates a LoadGen over the given tokens.  The same seed always
+// produces the same dataset
+func NewLoadGen(seed int64, tokens []common.Token) *LoadGen {
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec
+	return &LoadGen{
+		rng: rng,
+		// s=1.3 makes the top token take roughly half of the traffic
+		tokenZipf: rand.NewZipf(rng, 1.3, 1, uint64(len(tokens)-1)), //nolint:gomnd
+		tokens:    tokens,
+		privKs:    make(map[common.Idx]babyjub.PrivateKey),
+	}
+}
+
+// randToken picks a token following the Zipf distribution
+func (lg *LoadGen) randToken() common.Token {
+	return lg.tokens[lg.tokenZipf.Uint64()]
+}
+
+// randFee samples a fee selector from a normal distribution clamped to the
+// valid range
+func (lg *LoadGen) randFee() common.FeeSelector {
+	fee := lg.rng.NormFloat64()*loadGenFeeStdDev + loadGenFeeMean
+	if fee < 0 {
+		fee = 0
+	} else if fee > 255 {
+		fee = 255
+	}
+	return common.FeeSelector(fee)
+}
+
+// randAmount samples a log-normally distributed amount
+func (lg *LoadGen) randAmount() *big.Int {
+	amount := math.Exp(lg.rng.NormFloat64()*2 + 18) //nolint:gomnd
+	f := new(big.Float).SetFloat64(amount)
+	res, _ := f.Int(nil)
+	if res.Sign() <= 0 {
+		res = big.NewInt(1)
+	}
+	return res
+}
+
+// nextTxID returns a unique (but protocol-inconsistent) TxID
+func (lg *LoadGen) nextTxID() common.TxID {
+	lg.txCounter++
+	var txID common.TxID
+	txID[0] = 2
+	n := lg.txCounter
+	for i := 0; i < 8; i++ {
+		txID[common.TxIDLen-1-i] = byte(n)
+		n >>= 8
+	}
+	return txID
+}
+
+// GenLoadAccounts generates n accounts with random BJJ keys, keeping the
+// private keys so that pool txs from these accounts can be signed later.
+// Idxs are assigned sequentially starting after the already generated ones
+func (lg *LoadGen) GenLoadAccounts(n int, batches []common.Batch) []common.Account {
+	accs := make([]common.Account, 0, n)
+	firstIdx := common.UserThreshold + len(lg.accounts)
+	for i := firstIdx; i < firstIdx+n; i++ {
+		// Keys are derived from the seeded rng (instead of
+		// babyjub.NewRandPrivKey) so that the dataset is reproducible
+		var privK babyjub.PrivateKey
+		if _, err := lg.rng.Read(privK[:]); err != nil {
+			panic(err)
+		}
+		token := lg.randToken()
+		accs = append(accs, common.Account{
+			Idx:      common.Idx(i),
+			TokenID:  token.TokenID,
+			EthAddr:  ethCommon.BigToAddress(big.NewInt(int64(i))),
+			BatchNum: batches[i%len(batches)].BatchNum,
+			BJJ:      privK.Public().Compress(),
+			Balance:  big.NewInt(int64(i) * 10000000), //nolint:gomnd
+		})
+		lg.privKs[common.Idx(i)] = privK
+	}
+	lg.accounts = append(lg.accounts, accs...)
+	return accs
+}
+
+// GenLoadL2Txs generates n forged L2 txs between the generated accounts,
+// spread over the given blocks and batches
+func (lg *LoadGen) GenLoadL2Txs(n int, blocks []common.Block,
+	batches []common.Batch) []common.L2Tx {
+	txs := make([]common.L2Tx, 0, n)
+	for i := 0; i < n; i++ {
+		from := lg.accounts[lg.rng.Intn(len(lg.accounts))]
+		to := lg.accounts[lg.rng.Intn(len(lg.accounts))]
+		txs = append(txs, common.L2Tx{
+			TxID:        lg.nextTxID(),
+			BatchNum:    batches[i%len(batches)].BatchNum,
+			Position:    i,
+			FromIdx:     from.Idx,
+			ToIdx:       to.Idx,
+			Amount:      lg.randAmount(),
+			Fee:         lg.randFee(),
+			Nonce:       common.Nonce(i),
+			EthBlockNum: blocks[i%len(blocks)].Num,
+			Type:        common.TxTypeTransfer,
+		})
+	}
+	return txs
+}
+
+// GenLoadPoolTxs generates n pending pool txs between the generated accounts.
+// The txs are signed with the account keys, so they pass the signature
+// verification done by the POST /transactions-pool endpoint
+func (lg *LoadGen) GenLoadPoolTxs(n int, chainID uint16) ([]*common.PoolL2Tx, error) {
+	txs := make([]*common.PoolL2Tx, 0, n)
+	nonces := make(map[common.Idx]common.Nonce)
+	for i := 0; i < n; i++ {
+		from := lg.accounts[lg.rng.Intn(len(lg.accounts))]
+		to := lg.accounts[lg.rng.Intn(len(lg.accounts))]
+		tx := &common.PoolL2Tx{
+			FromIdx: from.Idx,
+			ToIdx:   to.Idx,
+			TokenID: from.TokenID,
+			Amount:  lg.randAmount(),
+			Fee:     lg.randFee(),
+			Nonce:   nonces[from.Idx],
+			State:   common.PoolL2TxStatePending,
+			Type:    common.TxTypeTransfer,
+		}
+		nonces[from.Idx]++
+		tx, err := common.NewPoolL2Tx(tx)
+		if err != nil {
+			return nil, err
+		}
+		toSign, err := tx.HashToSign(chainID)
+		if err != nil {
+			return nil, err
+		}
+		privK := lg.privKs[from.Idx]
+		tx.Signature = privK.SignPoseidon(toSign).Compress()
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
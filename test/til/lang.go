@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/log"
@@ -41,6 +42,22 @@ var TypeNewBatchL1 common.TxType = "InstrTypeNewBatchL1"
 // common.TxType of a new ethereum block
 var TypeNewBlock common.TxType = "InstrTypeNewBlock"
 
+// TypeAdvanceTime is used for testing purposes only, and advances the
+// simulated timestamp of the subsequent blocks
+var TypeAdvanceTime common.TxType = "InstrTypeAdvanceTime"
+
+// TypeForceL1QueueOverflow is used for testing purposes only, and makes the
+// open L1UserTxs queue accept txs past the MaxL1UserTx limit
+var TypeForceL1QueueOverflow common.TxType = "InstrTypeForceL1QueueOverflow"
+
+// TypeReorg is used for testing purposes only, and discards the last blocks
+// of the set simulating a blockchain reorg
+var TypeReorg common.TxType = "InstrTypeReorg"
+
+// TypeExpectError is used for testing purposes only, and declares that the
+// next instruction is expected to fail with a given error
+var TypeExpectError common.TxType = "InstrTypeExpectError"
+
 // TypeAddToken is used for testing purposes only, and represents the
 // common.TxType of a new Token regsitration.
 // It has 'nolint:gosec' as the string 'Token' triggers gosec as a potential
@@ -52,7 +69,7 @@ var TypeAddToken common.TxType = "InstrTypeAddToken" //nolint:gosec
 // coordinator
 var TxTypeCreateAccountDepositCoordinator common.TxType = "TypeCreateAccountDepositCoordinator"
 
-//nolint
+// nolint
 const (
 	ILLEGAL token = iota
 	WS
@@ -72,6 +89,9 @@ type Instruction struct {
 	Fee           uint8
 	TokenID       common.TokenID
 	Typ           common.TxType // D: Deposit, T: Transfer, E: ForceExit
+	// ExpectedErr is the message declared by an 'expectError' directive,
+	// which the instruction that follows it is expected to fail with
+	ExpectedErr string
 }
 
 // parsedSet contains the full Set of Instructions representing a full code
@@ -297,8 +317,43 @@ func (p *parser) parseLine(setType setType) (*Instruction, error) {
 		} else if lit == "block" {
 			_, _ = p.s.r.ReadString('\n')
 			return &Instruction{Typ: TypeNewBlock}, newEventLine
+		} else if lit == "advanceTime" {
+			_, lit = p.scanIgnoreWhitespace()
+			c.Literal += lit
+			seconds, err := strconv.ParseInt(lit, 10, 64)
+			if err != nil || seconds <= 0 {
+				_, _ = p.s.r.ReadString('\n')
+				return c, tracerr.Wrap(fmt.Errorf("Expected number of seconds "+
+					"greater than 0 after 'advanceTime', found '%s'", lit))
+			}
+			_, _ = p.s.r.ReadString('\n')
+			return &Instruction{Typ: TypeAdvanceTime, Amount: big.NewInt(seconds)}, newEventLine
+		} else if lit == "forceL1QueueOverflow" {
+			_, _ = p.s.r.ReadString('\n')
+			return &Instruction{Typ: TypeForceL1QueueOverflow}, newEventLine
+		} else if lit == "reorg" {
+			_, lit = p.scanIgnoreWhitespace()
+			c.Literal += lit
+			numBlocks, err := strconv.ParseInt(lit, 10, 64)
+			if err != nil || numBlocks <= 0 {
+				_, _ = p.s.r.ReadString('\n')
+				return c, tracerr.Wrap(fmt.Errorf("Expected number of blocks "+
+					"greater than 0 after 'reorg', found '%s'", lit))
+			}
+			_, _ = p.s.r.ReadString('\n')
+			return &Instruction{Typ: TypeReorg, Amount: big.NewInt(numBlocks)}, newEventLine
+		} else if lit == "expectError" {
+			line, _ := p.s.r.ReadString('\n')
+			expectedErr := strings.TrimSpace(line)
+			if expectedErr == "" {
+				return c, tracerr.Wrap(fmt.Errorf("Expected an error message after 'expectError'"))
+			}
+			return &Instruction{Typ: TypeExpectError, ExpectedErr: expectedErr}, newEventLine
 		} else {
-			return c, tracerr.Wrap(fmt.Errorf("Unexpected '> %s', expected '> batch' or '> block'", lit))
+			return c, tracerr.Wrap(fmt.Errorf("Unexpected '> %s', expected '> batch', "+
+				"'> batchL1', '> block', '> advanceTime <seconds>', "+
+				"'> forceL1QueueOverflow', '> reorg <blocks>' or "+
+				"'> expectError <message>'", lit))
 		}
 	} else if lit == "Type" {
 		if err := p.expectChar(c, ":"); err != nil {
@@ -195,7 +195,38 @@ func TestParseErrors(t *testing.T) {
 	parser = newParser(strings.NewReader(s))
 	_, err = parser.parse()
 	assert.Equal(t,
-		"Line 2: >, err: Unexpected '> btch', expected '> batch' or '> block'",
+		"Line 2: >, err: Unexpected '> btch', expected '> batch', '> batchL1', "+
+			"'> block', '> advanceTime <seconds>', '> forceL1QueueOverflow', "+
+			"'> reorg <blocks>' or '> expectError <message>'",
+		err.Error())
+	s = `
+		Type: Blockchain
+		> advanceTime xx
+	`
+	parser = newParser(strings.NewReader(s))
+	_, err = parser.parse()
+	assert.Equal(t,
+		"Line 2: >xx, err: Expected number of seconds greater than 0 after "+
+			"'advanceTime', found 'xx'",
+		err.Error())
+	s = `
+		Type: Blockchain
+		> reorg 0
+	`
+	parser = newParser(strings.NewReader(s))
+	_, err = parser.parse()
+	assert.Equal(t,
+		"Line 2: >0, err: Expected number of blocks greater than 0 after "+
+			"'reorg', found '0'",
+		err.Error())
+	s = `
+		Type: Blockchain
+		> expectError
+	`
+	parser = newParser(strings.NewReader(s))
+	_, err = parser.parse()
+	assert.Equal(t,
+		"Line 2: >, err: Expected an error message after 'expectError'",
 		err.Error())
 
 	// check definition of set Type
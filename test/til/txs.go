@@ -76,6 +76,24 @@ type Context struct {
 	}
 	blockNum int64
 
+	// currentTime is the simulated timestamp of the blocks, starting at
+	// the zero time and only advanced by the 'advanceTime' directive
+	currentTime time.Time
+	// l1QueueOverflow, when set by the 'forceL1QueueOverflow' directive,
+	// makes the open L1UserTxs queue accept txs past the MaxL1UserTx
+	// limit instead of rolling over to a new queue
+	l1QueueOverflow bool
+	// expectedErr is the error message declared by the last 'expectError'
+	// directive, which the instruction that follows it must fail with
+	expectedErr     string
+	expectedErrLine int
+	// instrIdx is the index in instructions of the instruction being
+	// processed, used by the 'reorg' directive to replay the set prefix
+	instrIdx int
+	// blockBoundaries contains, for each generated block, the index in
+	// instructions right after the '> block' that closed it
+	blockBoundaries []int
+
 	extra contextExtra
 }
 
@@ -198,225 +216,309 @@ func (tc *Context) generateBlocks() ([]common.BlockData, error) {
 	tc.generateKeys(tc.userNames)
 
 	var blocks []common.BlockData
-	for _, inst := range tc.instructions {
-		switch inst.Typ {
-		case TxTypeCreateAccountDepositCoordinator: // tx source: L1CoordinatorTx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L1Tx{
-				FromEthAddr:   tc.Users[inst.From].Addr,
-				FromBJJ:       tc.Users[inst.From].BJJ.Public().Compress(),
-				TokenID:       inst.TokenID,
-				Amount:        big.NewInt(0),
-				DepositAmount: big.NewInt(0),
-				// as TxTypeCreateAccountDepositCoordinator is
-				// not valid oustide Til package
-				Type: common.TxTypeCreateAccountDeposit,
-			}
-			testTx := L1Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				L1Tx:        tx,
-			}
-
-			tc.currBatchTest.l1CoordinatorTxs = append(tc.currBatchTest.l1CoordinatorTxs, testTx)
-		case common.TxTypeCreateAccountDeposit, common.TxTypeCreateAccountDepositTransfer:
-			// tx source: L1UserTx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L1Tx{
-				FromEthAddr:   tc.Users[inst.From].Addr,
-				FromBJJ:       tc.Users[inst.From].BJJ.Public().Compress(),
-				TokenID:       inst.TokenID,
-				Amount:        big.NewInt(0),
-				DepositAmount: inst.DepositAmount,
-				Type:          inst.Typ,
-			}
-			if inst.Typ == common.TxTypeCreateAccountDepositTransfer {
-				tx.Amount = inst.Amount
-			}
-			testTx := L1Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				L1Tx:        tx,
-			}
-			if err := tc.addToL1UserQueue(testTx); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-		case common.TxTypeDeposit, common.TxTypeDepositTransfer: // tx source: L1UserTx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			if err := tc.checkIfAccountExists(inst.From, inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L1Tx{
-				TokenID:       inst.TokenID,
-				Amount:        big.NewInt(0),
-				DepositAmount: inst.DepositAmount,
-				Type:          inst.Typ,
-			}
-			if inst.Typ == common.TxTypeDepositTransfer {
-				tx.Amount = inst.Amount
-			}
-			testTx := L1Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				L1Tx:        tx,
-			}
-			if err := tc.addToL1UserQueue(testTx); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-		case common.TxTypeTransfer: // L2Tx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L2Tx{
-				Amount:      inst.Amount,
-				Fee:         common.FeeSelector(inst.Fee),
-				Type:        common.TxTypeTransfer,
-				EthBlockNum: tc.blockNum,
-			}
-			// when converted to PoolL2Tx BatchNum parameter is lost
-			tx.BatchNum = common.BatchNum(tc.currBatchNum)
-			testTx := L2Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				tokenID:     inst.TokenID,
-				L2Tx:        tx,
-			}
-			tc.currBatchTest.l2Txs = append(tc.currBatchTest.l2Txs, testTx)
-		case common.TxTypeForceTransfer: // tx source: L1UserTx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L1Tx{
-				TokenID:       inst.TokenID,
-				Amount:        inst.Amount,
-				DepositAmount: big.NewInt(0),
-				Type:          common.TxTypeForceTransfer,
-			}
-			testTx := L1Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				L1Tx:        tx,
-			}
-			if err := tc.addToL1UserQueue(testTx); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-		case common.TxTypeExit: // tx source: L2Tx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L2Tx{
-				ToIdx:       common.Idx(1), // as is an Exit
-				Fee:         common.FeeSelector(inst.Fee),
-				Amount:      inst.Amount,
-				Type:        common.TxTypeExit,
-				EthBlockNum: tc.blockNum,
-			}
-			// when converted to PoolL2Tx BatchNum parameter is lost
-			tx.BatchNum = common.BatchNum(tc.currBatchNum)
-			testTx := L2Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				tokenID:     inst.TokenID,
-				L2Tx:        tx,
-			}
-			tc.currBatchTest.l2Txs = append(tc.currBatchTest.l2Txs, testTx)
-		case common.TxTypeForceExit: // tx source: L1UserTx
-			if err := tc.checkIfTokenIsRegistered(inst); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
-			}
-			tx := common.L1Tx{
-				ToIdx:         common.Idx(1), // as is an Exit
-				TokenID:       inst.TokenID,
-				Amount:        inst.Amount,
-				DepositAmount: big.NewInt(0),
-				Type:          common.TxTypeForceExit,
-			}
-			testTx := L1Tx{
-				lineNum:     inst.LineNum,
-				fromIdxName: inst.From,
-				toIdxName:   inst.To,
-				L1Tx:        tx,
-			}
-			if err := tc.addToL1UserQueue(testTx); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-		case TypeNewBatch:
-			if err := tc.calculateIdxForL1Txs(true, tc.currBatchTest.l1CoordinatorTxs); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			if err := tc.setIdxs(); err != nil {
-				log.Error(err)
-				return nil, tracerr.Wrap(err)
-			}
-		case TypeNewBatchL1:
-			// for each L1UserTx of the Queues[ToForgeNum], calculate the Idx
-			if err := tc.calculateIdxForL1Txs(false, tc.Queues[tc.ToForgeNum]); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			if err := tc.calculateIdxForL1Txs(true, tc.currBatchTest.l1CoordinatorTxs); err != nil {
-				return nil, tracerr.Wrap(err)
+	for i, inst := range tc.instructions {
+		tc.instrIdx = i
+		if inst.Typ == TypeExpectError {
+			if tc.expectedErr != "" {
+				return nil, tracerr.Wrap(fmt.Errorf("Line %d: 'expectError' after "+
+					"another 'expectError' without an instruction in between",
+					inst.LineNum))
 			}
-			tc.currBatch.L1Batch = true
-			if err := tc.setIdxs(); err != nil {
-				return nil, tracerr.Wrap(err)
-			}
-			toForgeL1TxsNum := int64(tc.openToForge)
-			tc.currBatch.Batch.ForgeL1TxsNum = &toForgeL1TxsNum
-			// advance batch
-			tc.ToForgeNum++
-			if tc.ToForgeNum == tc.openToForge {
-				tc.openToForge++
-				newQueue := []L1Tx{}
-				tc.Queues = append(tc.Queues, newQueue)
-			}
-		case TypeNewBlock:
-			blocks = append(blocks, tc.currBlock)
-			tc.blockNum++
-			tc.currBlock = newBlock(tc.blockNum)
-		case TypeAddToken:
-			newToken := common.Token{
-				EthAddr: ethCommon.BigToAddress(big.NewInt(int64(inst.TokenID * 100))), //nolint:gomnd
-				// Name:        fmt.Sprintf("Token %d", inst.TokenID),
-				// Symbol:      fmt.Sprintf("TK%d", inst.TokenID),
-				// Decimals:    18,
-				TokenID:     inst.TokenID,
-				EthBlockNum: tc.blockNum,
+			tc.expectedErr = inst.ExpectedErr
+			tc.expectedErrLine = inst.LineNum
+			continue
+		}
+		err := tc.generateBlockInstruction(&blocks, inst)
+		if tc.expectedErr != "" {
+			expectedErr := tc.expectedErr
+			tc.expectedErr = ""
+			if err == nil {
+				return nil, tracerr.Wrap(fmt.Errorf("Line %d: expected error \"%s\" "+
+					"but the instruction succeeded", inst.LineNum, expectedErr))
 			}
-			if inst.TokenID != tc.LastRegisteredTokenID+1 {
-				return nil,
-					tracerr.Wrap(fmt.Errorf("Line %d: AddToken TokenID should be "+
-						"sequential, expected TokenID: %d, defined TokenID: %d",
-						inst.LineNum, tc.LastRegisteredTokenID+1, inst.TokenID))
+			if !strings.Contains(err.Error(), expectedErr) {
+				return nil, tracerr.Wrap(fmt.Errorf("Line %d: expected error \"%s\" "+
+					"but got \"%s\"", inst.LineNum, expectedErr, err.Error()))
 			}
-			tc.LastRegisteredTokenID++
-			tc.currBlock.Rollup.AddedTokens = append(tc.currBlock.Rollup.AddedTokens, newToken)
-		default:
-			return nil, tracerr.Wrap(fmt.Errorf("Line %d: Unexpected type: %s", inst.LineNum, inst.Typ))
+			continue
 		}
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+	if tc.expectedErr != "" {
+		return nil, tracerr.Wrap(fmt.Errorf("Line %d: 'expectError' without a "+
+			"following instruction", tc.expectedErrLine))
 	}
 
 	return blocks, nil
 }
 
+// generateBlockInstruction processes a single instruction of a Blockchain
+// set, updating the Context and appending to blocks when the instruction
+// closes an ethereum block
+func (tc *Context) generateBlockInstruction(blocks *[]common.BlockData, inst Instruction) error {
+	switch inst.Typ {
+	case TxTypeCreateAccountDepositCoordinator: // tx source: L1CoordinatorTx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L1Tx{
+			FromEthAddr:   tc.Users[inst.From].Addr,
+			FromBJJ:       tc.Users[inst.From].BJJ.Public().Compress(),
+			TokenID:       inst.TokenID,
+			Amount:        big.NewInt(0),
+			DepositAmount: big.NewInt(0),
+			// as TxTypeCreateAccountDepositCoordinator is
+			// not valid oustide Til package
+			Type: common.TxTypeCreateAccountDeposit,
+		}
+		testTx := L1Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			L1Tx:        tx,
+		}
+
+		tc.currBatchTest.l1CoordinatorTxs = append(tc.currBatchTest.l1CoordinatorTxs, testTx)
+	case common.TxTypeCreateAccountDeposit, common.TxTypeCreateAccountDepositTransfer:
+		// tx source: L1UserTx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L1Tx{
+			FromEthAddr:   tc.Users[inst.From].Addr,
+			FromBJJ:       tc.Users[inst.From].BJJ.Public().Compress(),
+			TokenID:       inst.TokenID,
+			Amount:        big.NewInt(0),
+			DepositAmount: inst.DepositAmount,
+			Type:          inst.Typ,
+		}
+		if inst.Typ == common.TxTypeCreateAccountDepositTransfer {
+			tx.Amount = inst.Amount
+		}
+		testTx := L1Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			L1Tx:        tx,
+		}
+		if err := tc.addToL1UserQueue(testTx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	case common.TxTypeDeposit, common.TxTypeDepositTransfer: // tx source: L1UserTx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		if err := tc.checkIfAccountExists(inst.From, inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L1Tx{
+			TokenID:       inst.TokenID,
+			Amount:        big.NewInt(0),
+			DepositAmount: inst.DepositAmount,
+			Type:          inst.Typ,
+		}
+		if inst.Typ == common.TxTypeDepositTransfer {
+			tx.Amount = inst.Amount
+		}
+		testTx := L1Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			L1Tx:        tx,
+		}
+		if err := tc.addToL1UserQueue(testTx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	case common.TxTypeTransfer: // L2Tx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L2Tx{
+			Amount:      inst.Amount,
+			Fee:         common.FeeSelector(inst.Fee),
+			Type:        common.TxTypeTransfer,
+			EthBlockNum: tc.blockNum,
+		}
+		// when converted to PoolL2Tx BatchNum parameter is lost
+		tx.BatchNum = common.BatchNum(tc.currBatchNum)
+		testTx := L2Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			tokenID:     inst.TokenID,
+			L2Tx:        tx,
+		}
+		tc.currBatchTest.l2Txs = append(tc.currBatchTest.l2Txs, testTx)
+	case common.TxTypeForceTransfer: // tx source: L1UserTx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L1Tx{
+			TokenID:       inst.TokenID,
+			Amount:        inst.Amount,
+			DepositAmount: big.NewInt(0),
+			Type:          common.TxTypeForceTransfer,
+		}
+		testTx := L1Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			L1Tx:        tx,
+		}
+		if err := tc.addToL1UserQueue(testTx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	case common.TxTypeExit: // tx source: L2Tx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L2Tx{
+			ToIdx:       common.Idx(1), // as is an Exit
+			Fee:         common.FeeSelector(inst.Fee),
+			Amount:      inst.Amount,
+			Type:        common.TxTypeExit,
+			EthBlockNum: tc.blockNum,
+		}
+		// when converted to PoolL2Tx BatchNum parameter is lost
+		tx.BatchNum = common.BatchNum(tc.currBatchNum)
+		testTx := L2Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			tokenID:     inst.TokenID,
+			L2Tx:        tx,
+		}
+		tc.currBatchTest.l2Txs = append(tc.currBatchTest.l2Txs, testTx)
+	case common.TxTypeForceExit: // tx source: L1UserTx
+		if err := tc.checkIfTokenIsRegistered(inst); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(fmt.Errorf("Line %d: %s", inst.LineNum, err.Error()))
+		}
+		tx := common.L1Tx{
+			ToIdx:         common.Idx(1), // as is an Exit
+			TokenID:       inst.TokenID,
+			Amount:        inst.Amount,
+			DepositAmount: big.NewInt(0),
+			Type:          common.TxTypeForceExit,
+		}
+		testTx := L1Tx{
+			lineNum:     inst.LineNum,
+			fromIdxName: inst.From,
+			toIdxName:   inst.To,
+			L1Tx:        tx,
+		}
+		if err := tc.addToL1UserQueue(testTx); err != nil {
+			return tracerr.Wrap(err)
+		}
+	case TypeNewBatch:
+		if err := tc.calculateIdxForL1Txs(true, tc.currBatchTest.l1CoordinatorTxs); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := tc.setIdxs(); err != nil {
+			log.Error(err)
+			return tracerr.Wrap(err)
+		}
+	case TypeNewBatchL1:
+		// for each L1UserTx of the Queues[ToForgeNum], calculate the Idx
+		if err := tc.calculateIdxForL1Txs(false, tc.Queues[tc.ToForgeNum]); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := tc.calculateIdxForL1Txs(true, tc.currBatchTest.l1CoordinatorTxs); err != nil {
+			return tracerr.Wrap(err)
+		}
+		tc.currBatch.L1Batch = true
+		if err := tc.setIdxs(); err != nil {
+			return tracerr.Wrap(err)
+		}
+		toForgeL1TxsNum := int64(tc.openToForge)
+		tc.currBatch.Batch.ForgeL1TxsNum = &toForgeL1TxsNum
+		// advance batch
+		tc.ToForgeNum++
+		if tc.ToForgeNum == tc.openToForge {
+			tc.openToForge++
+			// the new open queue is back under the MaxL1UserTx
+			// limit, until the directive is used again
+			tc.l1QueueOverflow = false
+			newQueue := []L1Tx{}
+			tc.Queues = append(tc.Queues, newQueue)
+		}
+	case TypeNewBlock:
+		tc.currBlock.Block.Timestamp = tc.currentTime
+		*blocks = append(*blocks, tc.currBlock)
+		tc.blockNum++
+		tc.currBlock = newBlock(tc.blockNum)
+		tc.blockBoundaries = append(tc.blockBoundaries, tc.instrIdx+1)
+	case TypeReorg:
+		numBlocks := int(inst.Amount.Int64())
+		if numBlocks > len(*blocks) {
+			return tracerr.Wrap(fmt.Errorf("Line %d: reorg of %d blocks, but the "+
+				"set has only generated %d blocks", inst.LineNum, numBlocks,
+				len(*blocks)))
+		}
+		for _, prev := range tc.instructions[tc.blockBoundaries[len(*blocks)-1]:tc.instrIdx] {
+			if prev.Typ != TypeExpectError {
+				return tracerr.Wrap(fmt.Errorf("Line %d: 'reorg' must immediately "+
+					"follow a '> block', found '%s' in between",
+					inst.LineNum, prev.Typ))
+			}
+		}
+		// Rewind the context by replaying the set from the beginning
+		// up to the last block that is kept.  Keys and state are
+		// deterministic, so the kept blocks are regenerated identical
+		// and the instructions that follow define the alternative
+		// chain.
+		boundary := 0
+		if numBlocks < len(*blocks) {
+			boundary = tc.blockBoundaries[len(*blocks)-numBlocks-1]
+		}
+		forked := NewContext(tc.chainID, tc.rollupConstMaxL1UserTx)
+		forked.userNames = tc.userNames
+		forked.instructions = tc.instructions[:boundary]
+		replayed, err := forked.generateBlocks()
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		instructions := tc.instructions
+		expectedErr, expectedErrLine := tc.expectedErr, tc.expectedErrLine
+		*tc = *forked
+		tc.instructions = instructions
+		tc.expectedErr, tc.expectedErrLine = expectedErr, expectedErrLine
+		*blocks = replayed
+	case TypeAdvanceTime:
+		tc.currentTime = tc.currentTime.Add(
+			time.Duration(inst.Amount.Int64()) * time.Second)
+	case TypeForceL1QueueOverflow:
+		tc.l1QueueOverflow = true
+	case TypeAddToken:
+		newToken := common.Token{
+			EthAddr: ethCommon.BigToAddress(big.NewInt(int64(inst.TokenID * 100))), //nolint:gomnd
+			// Name:        fmt.Sprintf("Token %d", inst.TokenID),
+			// Symbol:      fmt.Sprintf("TK%d", inst.TokenID),
+			// Decimals:    18,
+			TokenID:     inst.TokenID,
+			EthBlockNum: tc.blockNum,
+		}
+		if inst.TokenID != tc.LastRegisteredTokenID+1 {
+			return tracerr.Wrap(fmt.Errorf("Line %d: AddToken TokenID should be "+
+				"sequential, expected TokenID: %d, defined TokenID: %d",
+				inst.LineNum, tc.LastRegisteredTokenID+1, inst.TokenID))
+		}
+		tc.LastRegisteredTokenID++
+		tc.currBlock.Rollup.AddedTokens = append(tc.currBlock.Rollup.AddedTokens, newToken)
+	default:
+		return tracerr.Wrap(fmt.Errorf("Line %d: Unexpected type: %s", inst.LineNum, inst.Typ))
+	}
+	return nil
+}
+
 // calculateIdxsForL1Txs calculates new Idx for new created accounts. If
 // 'isCoordinatorTxs==true', adds the tx to tc.currBatch.L1CoordinatorTxs.
 func (tc *Context) calculateIdxForL1Txs(isCoordinatorTxs bool, txs []L1Tx) error {
@@ -502,9 +604,10 @@ func (tc *Context) setIdxs() error {
 
 // addToL1UserQueue adds the L1UserTx into the queue that is open and has space
 func (tc *Context) addToL1UserQueue(tx L1Tx) error {
-	if len(tc.Queues[tc.openToForge]) >= tc.rollupConstMaxL1UserTx {
+	if len(tc.Queues[tc.openToForge]) >= tc.rollupConstMaxL1UserTx && !tc.l1QueueOverflow {
 		// if current OpenToForge queue reached its Max, move into a
-		// new queue
+		// new queue (unless the 'forceL1QueueOverflow' directive is in
+		// effect, which keeps filling the open queue past the limit)
 		tc.openToForge++
 		newQueue := []L1Tx{}
 		tc.Queues = append(tc.Queues, newQueue)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -472,6 +473,167 @@ func TestGenerateErrors(t *testing.T) {
 	assert.Equal(t, common.Idx(257), tc.Users["B"].Accounts[common.TokenID(1)].Idx)
 }
 
+func TestGenerateDirectives(t *testing.T) {
+	// advanceTime advances the timestamp of the subsequent blocks
+	set := `
+		Type: Blockchain
+		> advanceTime 3600
+		> block
+		> advanceTime 60
+		> block
+	`
+	tc := NewContext(0, common.RollupConstMaxL1UserTx)
+	blocks, err := tc.GenerateBlocks(set)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(blocks))
+	assert.True(t, blocks[0].Block.Timestamp.Equal(time.Time{}.Add(time.Hour)))
+	assert.True(t, blocks[1].Block.Timestamp.Equal(time.Time{}.Add(time.Hour+time.Minute)))
+
+	// forceL1QueueOverflow keeps filling the open queue past MaxL1UserTx
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> forceL1QueueOverflow
+		CreateAccountDeposit(1) A: 10
+		CreateAccountDeposit(1) B: 10
+		CreateAccountDeposit(1) C: 10
+		> block
+	`
+	tc = NewContext(0, 2)
+	blocks, err = tc.GenerateBlocks(set)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(blocks))
+	assert.Equal(t, 3, len(blocks[0].Rollup.L1UserTxs))
+	// all the txs went into the open queue, no extra queue was created
+	assert.Equal(t, 2, len(tc.Queues))
+	assert.Equal(t, 3, len(tc.Queues[tc.openToForge]))
+	// without the directive the third tx rolls over to a new queue
+	tc = NewContext(0, 2)
+	_, err = tc.GenerateBlocks(`
+		Type: Blockchain
+		AddToken(1)
+		CreateAccountDeposit(1) A: 10
+		CreateAccountDeposit(1) B: 10
+		CreateAccountDeposit(1) C: 10
+		> block
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(tc.Queues))
+
+	// expectError swallows the declared error and continues the generation
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> expectError TokenID 2 not registered
+		CreateAccountDeposit(2) A: 10
+		CreateAccountDeposit(1) A: 10
+		> batchL1
+		> block
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	blocks, err = tc.GenerateBlocks(set)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(blocks))
+	assert.Equal(t, 1, len(blocks[0].Rollup.L1UserTxs))
+
+	// the declared error must match the one returned by the instruction
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> expectError some unrelated error
+		CreateAccountDeposit(2) A: 10
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	_, err = tc.GenerateBlocks(set)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected error \"some unrelated error\" but got")
+
+	// the instruction after expectError must fail
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> expectError TokenID 2 not registered
+		CreateAccountDeposit(1) A: 10
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	_, err = tc.GenerateBlocks(set)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "but the instruction succeeded")
+
+	// expectError must be followed by an instruction
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> expectError TokenID 2 not registered
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	_, err = tc.GenerateBlocks(set)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'expectError' without a following instruction")
+
+	// reorg discards the last blocks and the instructions that follow
+	// define the alternative chain
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		CreateAccountDeposit(1) A: 10
+		> batchL1
+		> batchL1
+		> block
+		CreateAccountDeposit(1) B: 20
+		> batchL1
+		> batchL1
+		> block
+		> block
+		> reorg 2
+		CreateAccountDeposit(1) C: 30
+		> batchL1
+		> batchL1
+		> block
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	blocks, err = tc.GenerateBlocks(set)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(blocks))
+	// the kept block is regenerated identical
+	assert.Equal(t, int64(2), blocks[0].Block.Num)
+	require.Equal(t, 1, len(blocks[0].Rollup.L1UserTxs))
+	assert.Equal(t, big.NewInt(10), blocks[0].Rollup.L1UserTxs[0].DepositAmount)
+	// the discarded blocks are replaced by the alternative chain
+	assert.Equal(t, int64(3), blocks[1].Block.Num)
+	require.Equal(t, 1, len(blocks[1].Rollup.L1UserTxs))
+	assert.Equal(t, big.NewInt(30), blocks[1].Rollup.L1UserTxs[0].DepositAmount)
+	// the account of the discarded deposit is gone from the context, and
+	// its Idx is reused by the alternative chain
+	assert.Nil(t, tc.Users["B"].Accounts[common.TokenID(1)])
+	require.NotNil(t, tc.Users["C"].Accounts[common.TokenID(1)])
+	assert.Equal(t, common.Idx(257), tc.Users["C"].Accounts[common.TokenID(1)].Idx)
+
+	// reorg can't discard more blocks than the set has generated
+	set = `
+		Type: Blockchain
+		> block
+		> reorg 2
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	_, err = tc.GenerateBlocks(set)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reorg of 2 blocks, but the set has only generated 1 blocks")
+
+	// reorg must immediately follow a '> block'
+	set = `
+		Type: Blockchain
+		AddToken(1)
+		> block
+		CreateAccountDeposit(1) A: 10
+		> reorg 1
+	`
+	tc = NewContext(0, common.RollupConstMaxL1UserTx)
+	_, err = tc.GenerateBlocks(set)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'reorg' must immediately follow a '> block'")
+}
+
 func TestGenerateFromInstructions(t *testing.T) {
 	// Generate block from instructions
 	setInst := []Instruction{}
@@ -0,0 +1,210 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+)
+
+const (
+	// defaultBatchSize is the number of finished spans accumulated
+	// before an export when Config.BatchSize is unset
+	defaultBatchSize = 128
+	// defaultFlushInterval is the maximum time a finished span waits to
+	// be exported when Config.FlushInterval is unset
+	defaultFlushInterval = 5 * time.Second
+	// queueSize is the size of the finished spans queue.  Spans finished
+	// while the queue is full are dropped
+	queueSize = 2048
+	// exportTimeout is the timeout of each HTTP export request
+	exportTimeout = 10 * time.Second
+)
+
+// finishedSpan is the exportable form of an ended Span
+type finishedSpan struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []attribute
+	errMsg   string
+}
+
+// exporter batches finished spans and POSTs them to an OTLP/HTTP collector
+// encoded as JSON (the protobuf JSON mapping of
+// opentelemetry/proto/trace/v1/trace.proto)
+type exporter struct {
+	cfg    Config
+	client *http.Client
+	queue  chan finishedSpan
+	done   chan struct{}
+}
+
+func newExporter(cfg Config) *exporter {
+	e := &exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan finishedSpan, queueSize),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// enqueue queues a finished span for export, dropping it when the queue is
+// full so that the instrumented code never blocks on the exporter
+func (e *exporter) enqueue(span finishedSpan) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// stop flushes the pending spans and stops the export loop
+func (e *exporter) stop() {
+	close(e.queue)
+	<-e.done
+}
+
+// run is the export loop: it accumulates finished spans and exports them
+// when the batch is full or the flush interval elapses
+func (e *exporter) run() {
+	defer close(e.done)
+	batch := make([]finishedSpan, 0, e.cfg.BatchSize)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= e.cfg.BatchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// The otlp* types are the subset of the OTLP trace JSON encoding that the
+// exporter emits
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	// otlpSpanKindInternal is the default span kind
+	otlpSpanKindInternal = 1
+	// otlpStatusError is the OTLP status code of a failed span
+	otlpStatusError = 2
+)
+
+// export POSTs a batch of finished spans to the collector.  Export failures
+// are logged and the batch is dropped: tracing must never degrade the node
+func (e *exporter) export(batch []finishedSpan) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]otlpSpan, len(batch))
+	for i, span := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		for _, attr := range span.attrs {
+			spans[i].Attributes = append(spans[i].Attributes, otlpKeyValue{
+				Key:   attr.key,
+				Value: otlpAnyValue{StringValue: attr.value},
+			})
+		}
+		if span.errMsg != "" {
+			spans[i].Status = &otlpStatus{
+				Code:    otlpStatusError,
+				Message: span.errMsg,
+			}
+		}
+	}
+	request := otlpExportRequest{ResourceSpans: []otlpResourceSpans{{}}}
+	request.ResourceSpans[0].Resource.Attributes = []otlpKeyValue{{
+		Key:   "service.name",
+		Value: otlpAnyValue{StringValue: e.cfg.ServiceName},
+	}}
+	scopeSpans := otlpScopeSpans{Spans: spans}
+	scopeSpans.Scope.Name = e.cfg.ServiceName
+	request.ResourceSpans[0].ScopeSpans = []otlpScopeSpans{scopeSpans}
+	body, err := json.Marshal(request)
+	if err != nil {
+		log.Errorw("trace: marshal spans", "err", err)
+		return
+	}
+	resp, err := e.client.Post(e.cfg.Endpoint+"/v1/traces",
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warnw("trace: export spans", "err", err)
+		return
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		log.Warnw("trace: export spans", "status", resp.StatusCode)
+	}
+}
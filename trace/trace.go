@@ -0,0 +1,222 @@
+/*
+Package trace implements a minimal distributed tracing instrumentation for
+the node.  Spans are created with StartSpan, propagated through contexts and
+exported to an OpenTelemetry collector using the OTLP/HTTP JSON encoding
+(see otlp.go), so they can be viewed in any OTLP compatible backend (Jaeger,
+Tempo, ...).
+
+The package is used the same way as the log package: a process wide tracer
+initialized once with Init, and package level functions everywhere else.
+When Init is never called (or the collector endpoint is left empty) all the
+operations are cheap no-ops, so the instrumented code paths don't need to
+check whether tracing is enabled.
+
+Sampling is decided once per trace at the root span: a root span is sampled
+with probability SampleRatio and all its children inherit the decision, so
+traces are always exported complete or not at all.
+*/
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	mathRand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// Config specifies the configuration of the tracing instrumentation
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector (spans are
+	// POSTed to Endpoint + "/v1/traces").  Tracing is disabled when left
+	// empty
+	Endpoint string `validate:"omitempty,url"`
+	// ServiceName is the value of the service.name resource attribute
+	// attached to the exported spans.  Defaults to "hermez-node"
+	ServiceName string
+	// SampleRatio is the fraction of traces that are sampled and
+	// exported, decided at the root span.  Defaults to 1 (export
+	// everything)
+	SampleRatio float64 `validate:"gte=0,lte=1"`
+	// BatchSize is the number of finished spans accumulated before an
+	// export.  Defaults to defaultBatchSize
+	BatchSize int `validate:"gte=0"`
+	// FlushInterval is the maximum time a finished span waits to be
+	// exported.  Defaults to defaultFlushInterval
+	FlushInterval time.Duration
+}
+
+// tracer is the process wide tracer set by Init, nil until then.  A nil
+// tracer makes StartSpan return unsampled spans, whose methods are all
+// no-ops
+var tracer *exporter
+
+// randMutex guards randSource, since math/rand sources are not safe for
+// concurrent use
+var (
+	randMutex  sync.Mutex
+	randSource = mathRand.New(mathRand.NewSource(time.Now().UnixNano()))
+)
+
+// Init initializes the process wide tracer, which exports the sampled spans
+// to the OTLP collector of the configuration.  Calling it twice is a fatal
+// error, like log.Init
+func Init(cfg Config) error {
+	if tracer != nil {
+		log.Fatal("trace.Init called twice")
+	}
+	if cfg.Endpoint == "" {
+		return tracerr.Wrap(fmt.Errorf("trace.Init: empty Endpoint"))
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "hermez-node"
+	}
+	if cfg.SampleRatio == 0 {
+		cfg.SampleRatio = 1
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	tracer = newExporter(cfg)
+	log.Infow("Tracing initialized", "endpoint", cfg.Endpoint,
+		"sampleRatio", cfg.SampleRatio)
+	return nil
+}
+
+// Stop flushes the pending spans and stops the exporter.  It's a no-op when
+// Init was never called
+func Stop() {
+	if tracer != nil {
+		tracer.stop()
+	}
+}
+
+// ctxKey is the type of the key used to store the current span in a
+// context.  It is unexported to avoid collisions with context keys defined
+// in other packages
+type ctxKey int
+
+const spanKey ctxKey = 0
+
+// attribute is a key-value pair attached to a span
+type attribute struct {
+	key   string
+	value string
+}
+
+// Span is a timed operation of a trace.  The zero value and nil are valid
+// unsampled spans: all the methods are no-ops on them
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	attrs    []attribute
+	errMsg   string
+	sampled  bool
+}
+
+// StartSpan starts a span named name as a child of the span carried by ctx,
+// or as the root span of a new trace when ctx carries none, and returns a
+// context carrying it.  The returned span must be finished with End.  When
+// tracing is disabled (or the trace was not sampled) the returned span is
+// unsampled and all its methods are no-ops
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{name: name, start: time.Now()}
+	if parent, ok := ctx.Value(spanKey).(*Span); ok && parent.sampled {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = true
+	} else if parent == nil || !ok {
+		// Root span: decide the sampling of the whole trace
+		if tracer != nil && sample(tracer.cfg.SampleRatio) {
+			if _, err := rand.Read(span.traceID[:]); err == nil {
+				span.sampled = true
+				// Correlate the trace with the logs when the
+				// context carries a correlation ID (see the
+				// log package)
+				if requestID := log.RequestID(ctx); requestID != "" {
+					span.attrs = append(span.attrs,
+						attribute{"request.id", requestID})
+				}
+			}
+		}
+	}
+	if span.sampled {
+		if _, err := rand.Read(span.spanID[:]); err != nil {
+			span.sampled = false
+		}
+	}
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// sample returns true with probability ratio
+func sample(ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return randSource.Float64() < ratio
+}
+
+// SetAttribute attaches a key-value attribute to the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// SetError marks the span status as error with the message of err
+func (s *Span) SetError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.errMsg = tracerr.Unwrap(err).Error()
+}
+
+// End finishes the span and queues it for export.  Spans whose export queue
+// is full are dropped rather than blocking the instrumented code
+func (s *Span) End() {
+	if s == nil || !s.sampled {
+		return
+	}
+	tracer.enqueue(finishedSpan{
+		traceID:  hex.EncodeToString(s.traceID[:]),
+		spanID:   hex.EncodeToString(s.spanID[:]),
+		parentID: parentIDHex(s.parentID),
+		name:     s.name,
+		start:    s.start,
+		end:      time.Now(),
+		attrs:    s.attrs,
+		errMsg:   s.errMsg,
+	})
+}
+
+// TraceID returns the hex encoded trace ID of the span, "" when the span is
+// unsampled
+func (s *Span) TraceID() string {
+	if s == nil || !s.sampled {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// parentIDHex returns the hex encoded parentID, "" for a root span
+func parentIDHex(parentID [8]byte) string {
+	if binary.BigEndian.Uint64(parentID[:]) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(parentID[:])
+}
@@ -0,0 +1,83 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	// Before Init all the spans are unsampled no-ops
+	ctx, span := StartSpan(context.Background(), "noop")
+	assert.Equal(t, "", span.TraceID())
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("noop"))
+	span.End()
+	_, child := StartSpan(ctx, "noop child")
+	assert.Equal(t, "", child.TraceID())
+	child.End()
+
+	// Collector that records the export requests
+	var mutex sync.Mutex
+	var received otlpExportRequest
+	collector := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			require.Equal(t, "/v1/traces", r.URL.Path)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusOK)
+		}))
+	defer collector.Close()
+
+	require.NoError(t, Init(Config{
+		Endpoint:      collector.URL,
+		ServiceName:   "trace-test",
+		SampleRatio:   1,
+		BatchSize:     2,
+		FlushInterval: 50 * time.Millisecond,
+	}))
+
+	ctx = log.WithRequestID(context.Background(), "test-request-id")
+	ctx, root := StartSpan(ctx, "root")
+	require.NotEqual(t, "", root.TraceID())
+	_, child = StartSpan(ctx, "child")
+	require.Equal(t, root.TraceID(), child.TraceID())
+	child.SetAttribute("key", "value")
+	child.SetError(errors.New("child error"))
+	child.End()
+	root.End()
+	Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, received.ResourceSpans, 1)
+	assert.Equal(t, "trace-test",
+		received.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+	require.Len(t, received.ResourceSpans[0].ScopeSpans, 1)
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	// Spans are exported in End order: child first
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, []otlpKeyValue{{Key: "key", Value: otlpAnyValue{StringValue: "value"}}},
+		spans[0].Attributes)
+	require.NotNil(t, spans[0].Status)
+	assert.Equal(t, otlpStatusError, spans[0].Status.Code)
+	assert.Equal(t, "child error", spans[0].Status.Message)
+	assert.Equal(t, "root", spans[1].Name)
+	assert.Equal(t, root.TraceID(), spans[1].TraceID)
+	assert.Equal(t, "", spans[1].ParentSpanID)
+	// The log correlation ID is attached to the root span
+	assert.Equal(t, []otlpKeyValue{{Key: "request.id",
+		Value: otlpAnyValue{StringValue: "test-request-id"}}}, spans[1].Attributes)
+}
@@ -6,22 +6,25 @@ It's a package used by 3 other different packages, and its behaviour will differ
 depending on the Type of the StateDB of the TxProcessor:
 
 - TypeSynchronizer:
-	- The StateDB contains the full State MerkleTree, where the leafs are
-	the accounts
-	- Updates the StateDB and as output returns: ExitInfos, CreatedAccounts,
-	CoordinatorIdxsMap, CollectedFees, UpdatedAccounts
-	- Internally computes the ExitTree
+  - The StateDB contains the full State MerkleTree, where the leafs are
+    the accounts
+  - Updates the StateDB and as output returns: ExitInfos, CreatedAccounts,
+    CoordinatorIdxsMap, CollectedFees, UpdatedAccounts
+  - Internally computes the ExitTree
+
 - TypeTxSelector:
-	- The StateDB contains only the Accounts, which are the equivalent to
-	only the leafs of the State MerkleTree
-	- Updates the Accounts from the StateDB
+  - The StateDB contains only the Accounts, which are the equivalent to
+    only the leafs of the State MerkleTree
+  - Updates the Accounts from the StateDB
+
 - TypeBatchBuilder:
-	- The StateDB contains the full State MerkleTree, where the leafs are
-	the accounts
-	- Updates the StateDB. As output returns: ZKInputs, CoordinatorIdxsMap
-	- Internally computes the ZKInputs
+  - The StateDB contains the full State MerkleTree, where the leafs are
+    the accounts
+  - Updates the StateDB. As output returns: ZKInputs, CoordinatorIdxsMap
+  - Internally computes the ZKInputs
 
 Packages dependency overview:
+
 	Outputs: + ExitInfos              +                  +                       +
 		 | CreatedAccounts        |                  |                       |
 		 | CoordinatorIdxsMap     |                  |    ZKInputs           |
@@ -49,32 +52,32 @@ Packages dependency overview:
 	      KVDB   MerkleTree                                 KVDB   MerkleTree
 
 The structure of the TxProcessor can be understand as:
-	- StateDB: where the Rollup state is stored. It contains the Accounts &
-	MerkleTree.
-	- Config: parameters of the configuration of the circuit
-	- ZKInputs: computed inputs for the circuit, depends on the Config parameters
-	- ExitTree: only in the TypeSynchronizer & TypeBatchBuilder, contains
-	the MerkleTree with the processed Exits of the Batch
+  - StateDB: where the Rollup state is stored. It contains the Accounts &
+    MerkleTree.
+  - Config: parameters of the configuration of the circuit
+  - ZKInputs: computed inputs for the circuit, depends on the Config parameters
+  - ExitTree: only in the TypeSynchronizer & TypeBatchBuilder, contains
+    the MerkleTree with the processed Exits of the Batch
 
 The main exposed method of the TxProcessor is `ProcessTxs`, which as general
 lines does:
-	- if type==(Synchronizer || BatchBuilder), creates an ephemeral ExitTree
-	- processes:
-		- L1UserTxs --> for each tx calls ProcessL1Tx()
-		- L1CoordinatorTxs --> for each tx calls ProcessL1Tx()
-		- L2Txs --> for each tx calls ProcessL2Tx()
-			- internally, it computes the Fees
-	- each transaction processment includes:
-		- updating the Account Balances (for sender & receiver, and in
-		case that there is fee, updates the fee receiver account)
-			- which includes updating the State MerkleTree (except
-			for the type==TxSelector, which only updates the
-			Accounts (leafs))
-		- in case of Synchronizer & BatchBuilder, updates the ExitTree
-		for the txs of type Exit (L1 & L2)
-		- in case of BatchBuilder, computes the ZKInputs while processing the txs
-	- if type==Synchronizer, once all the txs are processed, for each Exit
-	it generates the ExitInfo data
+  - if type==(Synchronizer || BatchBuilder), creates an ephemeral ExitTree
+  - processes:
+  - L1UserTxs --> for each tx calls ProcessL1Tx()
+  - L1CoordinatorTxs --> for each tx calls ProcessL1Tx()
+  - L2Txs --> for each tx calls ProcessL2Tx()
+  - internally, it computes the Fees
+  - each transaction processment includes:
+  - updating the Account Balances (for sender & receiver, and in
+    case that there is fee, updates the fee receiver account)
+  - which includes updating the State MerkleTree (except
+    for the type==TxSelector, which only updates the
+    Accounts (leafs))
+  - in case of Synchronizer & BatchBuilder, updates the ExitTree
+    for the txs of type Exit (L1 & L2)
+  - in case of BatchBuilder, computes the ZKInputs while processing the txs
+  - if type==Synchronizer, once all the txs are processed, for each Exit
+    it generates the ExitInfo data
 */
 package txprocessor
 
@@ -87,12 +90,14 @@ import (
 	"os"
 
 	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/kvdb"
 	"github.com/arnaubennassar/hermez-node/db/statedb"
 	"github.com/arnaubennassar/hermez-node/log"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-iden3-crypto/babyjub"
 	"github.com/iden3/go-merkletree"
 	"github.com/iden3/go-merkletree/db"
+	"github.com/iden3/go-merkletree/db/memory"
 	"github.com/iden3/go-merkletree/db/pebble"
 )
 
@@ -120,6 +125,10 @@ type Config struct {
 	MaxL1Tx  uint32
 	// ChainID of the blockchain
 	ChainID uint16
+	// TxCodec is the tx serialization version used to encode the data
+	// availability of the processed batch.  Set to 0 to use
+	// common.TxCodecCurrent
+	TxCodec common.TxCodecVersion
 }
 
 type processedExit struct {
@@ -224,6 +233,15 @@ func (tp *TxProcessor) ProcessTxs(coordIdxs []common.Idx, l1usertxs, l1coordinat
 		tp.updatedAccounts = make(map[common.Idx]*common.Account)
 	}
 
+	codecVersion := tp.config.TxCodec
+	if codecVersion == 0 {
+		codecVersion = common.TxCodecCurrent
+	}
+	codec, err := common.TxCodecByVersion(codecVersion)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
 	exits := make([]processedExit, nTx)
 
 	if tp.s.Type() == statedb.TypeBatchBuilder {
@@ -237,18 +255,24 @@ func (tp *TxProcessor) ProcessTxs(coordIdxs []common.Idx, l1usertxs, l1coordinat
 	// TBD if ExitTree is only in memory or stored in disk, for the moment
 	// is only needed in memory
 	if tp.s.Type() == statedb.TypeSynchronizer || tp.s.Type() == statedb.TypeBatchBuilder {
-		tmpDir, err := ioutil.TempDir("", "hermez-statedb-exittree")
-		if err != nil {
-			return nil, tracerr.Wrap(err)
-		}
-		defer func() {
-			if err := os.RemoveAll(tmpDir); err != nil {
-				log.Errorw("Deleting statedb temp exit tree", "err", err)
+		var sto db.Storage
+		if tp.s.Backend() == kvdb.BackendMemory {
+			sto = memory.NewMemoryStorage()
+		} else {
+			tmpDir, err := ioutil.TempDir("", "hermez-statedb-exittree")
+			if err != nil {
+				return nil, tracerr.Wrap(err)
 			}
-		}()
-		sto, err := pebble.NewPebbleStorage(tmpDir, false)
-		if err != nil {
-			return nil, tracerr.Wrap(err)
+			defer func() {
+				if err := os.RemoveAll(tmpDir); err != nil {
+					log.Errorw("Deleting statedb temp exit tree", "err", err)
+				}
+			}()
+			pebbleSto, err := pebble.NewPebbleStorage(tmpDir, false)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			sto = pebbleSto
 		}
 		defer sto.Close()
 		exitTree, err = merkletree.NewMerkleTree(sto, tp.s.MT.MaxLevels())
@@ -281,7 +305,7 @@ func (tp *TxProcessor) ProcessTxs(coordIdxs []common.Idx, l1usertxs, l1coordinat
 			tp.zki.Metadata.L1TxsData = append(tp.zki.Metadata.L1TxsData, l1TxData)
 
 			l1TxDataAvailability, err :=
-				l1usertxs[i].BytesDataAvailability(tp.zki.Metadata.NLevels)
+				codec.L1TxBytesDataAvailability(&l1usertxs[i], tp.zki.Metadata.NLevels)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
@@ -331,7 +355,7 @@ func (tp *TxProcessor) ProcessTxs(coordIdxs []common.Idx, l1usertxs, l1coordinat
 			}
 			tp.zki.Metadata.L1TxsData = append(tp.zki.Metadata.L1TxsData, l1TxData)
 			l1TxDataAvailability, err :=
-				l1coordinatortxs[i].BytesDataAvailability(tp.zki.Metadata.NLevels)
+				codec.L1TxBytesDataAvailability(&l1coordinatortxs[i], tp.zki.Metadata.NLevels)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
@@ -401,7 +425,7 @@ func (tp *TxProcessor) ProcessTxs(coordIdxs []common.Idx, l1usertxs, l1coordinat
 			return nil, tracerr.Wrap(err)
 		}
 		if tp.zki != nil {
-			l2TxData, err := l2txs[i].L2Tx().BytesDataAvailability(tp.zki.Metadata.NLevels)
+			l2TxData, err := codec.L2TxBytesDataAvailability(l2txs[i].L2Tx(), tp.zki.Metadata.NLevels)
 			if err != nil {
 				return nil, tracerr.Wrap(err)
 			}
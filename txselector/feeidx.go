@@ -0,0 +1,137 @@
+package txselector
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/statedb"
+	"github.com/arnaubennassar/hermez-node/txprocessor"
+	"github.com/hermeznetwork/tracerr"
+)
+
+// FeeIdxStrategy selects how the Coordinator fee collection idxs
+// (FeeIdxCoordinator) are chosen among the TokenIDs used in the batch
+type FeeIdxStrategy string
+
+const (
+	// FeeIdxStrategyAllTokens collects fees for every TokenID used in the
+	// batch, creating the missing Coordinator accounts via
+	// L1CoordinatorTxs.  This is the default strategy
+	FeeIdxStrategyAllTokens FeeIdxStrategy = "all-tokens"
+	// FeeIdxStrategyHighestFees collects fees only for the TokenIDs with
+	// the highest accrued fees in the batch, dropping the idxs that
+	// accrued no fee at all
+	FeeIdxStrategyHighestFees FeeIdxStrategy = "highest-fees"
+	// FeeIdxStrategyWhitelist collects fees only for the configured
+	// TokenIDs.  Fees of txs of other tokens are not collected, and no
+	// Coordinator accounts are created for them
+	FeeIdxStrategyWhitelist FeeIdxStrategy = "whitelist"
+)
+
+// FeeIdxConfig configures the strategy used to choose the Coordinator fee
+// collection idxs
+type FeeIdxConfig struct {
+	Strategy FeeIdxStrategy
+	// TokenWhitelist is the list of TokenIDs for which fees are
+	// collected, only used with FeeIdxStrategyWhitelist
+	TokenWhitelist []common.TokenID
+}
+
+// SetFeeIdxConfig sets the strategy used to choose the Coordinator fee
+// collection idxs.  By default FeeIdxStrategyAllTokens is used
+func (txsel *TxSelector) SetFeeIdxConfig(cfg FeeIdxConfig) error {
+	switch cfg.Strategy {
+	case FeeIdxStrategyAllTokens, FeeIdxStrategyHighestFees:
+	case FeeIdxStrategyWhitelist:
+		if len(cfg.TokenWhitelist) == 0 {
+			return tracerr.Wrap(fmt.Errorf(
+				"FeeIdxStrategy %v requires a non empty TokenWhitelist",
+				cfg.Strategy))
+		}
+	default:
+		return tracerr.Wrap(fmt.Errorf("invalid FeeIdxStrategy: %v", cfg.Strategy))
+	}
+	txsel.feeIdxStrategy = cfg.Strategy
+	txsel.feeTokenWhitelist = make(map[common.TokenID]bool)
+	for _, tokenID := range cfg.TokenWhitelist {
+		txsel.feeTokenWhitelist[tokenID] = true
+	}
+	return nil
+}
+
+// feeTokenAllowed returns true if the current strategy collects fees for the
+// given TokenID
+func (txsel *TxSelector) feeTokenAllowed(tokenID common.TokenID) bool {
+	if txsel.feeIdxStrategy != FeeIdxStrategyWhitelist {
+		return true
+	}
+	return txsel.feeTokenWhitelist[tokenID]
+}
+
+// selectCoordIdxs returns the Coordinator fee collection idxs for the
+// TokenIDs used by the given valid txs, chosen with the configured strategy
+// and capped at MaxFeeTx-1 (the maximum length of FeeIdxCoordinator accepted
+// by the TxProcessor).  When the cap is exceeded the idxs with the highest
+// accrued fees are kept
+func (txsel *TxSelector) selectCoordIdxs(tp *txprocessor.TxProcessor,
+	selectionConfig txprocessor.Config,
+	validTxs []common.PoolL2Tx) ([]common.Idx, error) {
+	coordIdxsMap := make(map[common.TokenID]common.Idx)
+	for i := 0; i < len(validTxs); i++ {
+		// get TokenID from tx.Sender
+		accSender, err := tp.StateDB().GetAccount(validTxs[i].FromIdx)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		tokenID := accSender.TokenID
+		if !txsel.feeTokenAllowed(tokenID) {
+			continue
+		}
+		coordIdx, err := txsel.getCoordIdx(tokenID)
+		if tracerr.Unwrap(err) == statedb.ErrIdxNotFound {
+			// can happen when the L1CoordinatorTx creating the
+			// account didn't fit in the batch; the fee of the tx
+			// is simply not collected
+			continue
+		}
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		coordIdxsMap[tokenID] = coordIdx
+	}
+
+	var coordIdxs []common.Idx
+	for _, idx := range coordIdxsMap {
+		if txsel.feeIdxStrategy == FeeIdxStrategyHighestFees &&
+			accumulatedFee(tp, idx).Cmp(big.NewInt(0)) == 0 {
+			// the token was used in the batch but no fee was
+			// accrued for it, don't waste a FeeIdxCoordinator slot
+			continue
+		}
+		coordIdxs = append(coordIdxs, idx)
+	}
+	// ProcessTxs requires len(coordIdxs) < MaxFeeTx
+	maxCoordIdxs := int(selectionConfig.MaxFeeTx) - 1
+	if len(coordIdxs) > maxCoordIdxs {
+		// keep the idxs with the highest accrued fees
+		sort.SliceStable(coordIdxs, func(i, j int) bool {
+			return accumulatedFee(tp, coordIdxs[i]).
+				Cmp(accumulatedFee(tp, coordIdxs[j])) > 0
+		})
+		coordIdxs = coordIdxs[:maxCoordIdxs]
+	}
+	// sort CoordIdxs
+	sort.SliceStable(coordIdxs, func(i, j int) bool {
+		return coordIdxs[i] < coordIdxs[j]
+	})
+	return coordIdxs, nil
+}
+
+func accumulatedFee(tp *txprocessor.TxProcessor, idx common.Idx) *big.Int {
+	if fee, ok := tp.AccumulatedFees[idx]; ok {
+		return fee
+	}
+	return big.NewInt(0)
+}
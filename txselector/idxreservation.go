@@ -0,0 +1,150 @@
+package txselector
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/log"
+	"github.com/arnaubennassar/hermez-node/txprocessor"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// IdxReservationTTL is the time during which a reserved account Idx is held.
+// While a reservation is held, every selection creates the L1CoordinatorTx of
+// the reserved account right after the L1UserTxs, so that the account gets
+// the promised Idx.
+const IdxReservationTTL = 2 * time.Minute
+
+// idxReservation holds the future Idx promised to an account pending
+// creation (see ReserveIdx)
+type idxReservation struct {
+	ethAddr ethCommon.Address
+	bjj     babyjub.PublicKeyComp
+	tokenID common.TokenID
+	idx     common.Idx
+	expiry  time.Time
+}
+
+// purgeExpiredIdxReservations drops the reservations past their expiry.
+// Assumes that idxReservationsMu is held
+func (txsel *TxSelector) purgeExpiredIdxReservations(now time.Time) {
+	var active []*idxReservation
+	for _, res := range txsel.idxReservations {
+		if now.Before(res.expiry) {
+			active = append(active, res)
+		}
+	}
+	txsel.idxReservations = active
+}
+
+// ReserveIdx returns the Idx that the account (addr, bjj, tokenID) will get
+// when the Coordinator creates it via an L1CoordinatorTx in the next forged
+// batch, holding a short-lived reservation so that the TxSelector creates
+// the account at the position that matches the returned Idx.  Requires an
+// AccountCreationAuth of addr with the same BJJ in the L2DB.  The
+// reservation is best effort: it is dropped without creating the account if
+// L1UserTxs that create accounts enter the queue after the reservation is
+// made (nPendingL1CreateAccounts accounts for the ones already queued),
+// shifting the Idx that the account would get.
+func (txsel *TxSelector) ReserveIdx(addr ethCommon.Address, bjj babyjub.PublicKeyComp,
+	tokenID common.TokenID, nPendingL1CreateAccounts int) (common.Idx, time.Time, error) {
+	accAuth, err := txsel.l2db.GetAccountCreationAuth(addr)
+	if err != nil {
+		return 0, time.Time{}, tracerr.Wrap(fmt.Errorf("no account creation authorization "+
+			"found for EthAddr: %s", addr.Hex()))
+	}
+	if accAuth.BJJ != bjj {
+		return 0, time.Time{}, tracerr.Wrap(fmt.Errorf("account creation authorization of "+
+			"EthAddr %s authorizes BJJ %s, not %s", addr.Hex(),
+			accAuth.BJJ.String(), bjj.String()))
+	}
+	txsel.idxReservationsMu.Lock()
+	defer txsel.idxReservationsMu.Unlock()
+	now := time.Now()
+	txsel.purgeExpiredIdxReservations(now)
+	if _, err := txsel.localAccountsDB.GetIdxByEthAddrBJJ(addr, bjj, tokenID); err == nil {
+		return 0, time.Time{}, tracerr.Wrap(fmt.Errorf("account already exists for "+
+			"EthAddr: %s, BJJ: %s, TokenID: %d", addr.Hex(), bjj.String(), tokenID))
+	}
+	for _, res := range txsel.idxReservations {
+		if res.ethAddr == addr && res.bjj == bjj && res.tokenID == tokenID {
+			// the account is already reserved, renew the reservation
+			res.expiry = now.Add(IdxReservationTTL)
+			return res.idx, res.expiry, nil
+		}
+	}
+	res := &idxReservation{
+		ethAddr: addr,
+		bjj:     bjj,
+		tokenID: tokenID,
+		idx: txsel.localAccountsDB.CurrentIdx() + 1 +
+			common.Idx(nPendingL1CreateAccounts) + common.Idx(len(txsel.idxReservations)),
+		expiry: now.Add(IdxReservationTTL),
+	}
+	txsel.idxReservations = append(txsel.idxReservations, res)
+	return res.idx, res.expiry, nil
+}
+
+// processIdxReservations creates and processes the L1CoordinatorTxs of the
+// active Idx reservations, placed right after the L1UserTxs so that the
+// created accounts get the promised Idxs.  Reservations whose account has
+// already been created are cleared, and reservations whose promise no longer
+// holds (the L1UserTxs of the batch created more or fewer accounts than
+// expected at reservation time) are dropped without creating the account.
+func (txsel *TxSelector) processIdxReservations(tp *txprocessor.TxProcessor,
+	selectionConfig txprocessor.Config, nL1UserTxs int) ([][]byte, []common.L1Tx, error) {
+	txsel.idxReservationsMu.Lock()
+	defer txsel.idxReservationsMu.Unlock()
+	txsel.purgeExpiredIdxReservations(time.Now())
+	var accAuths [][]byte
+	var l1CoordinatorTxs []common.L1Tx
+	var kept []*idxReservation
+	for _, res := range txsel.idxReservations {
+		if _, err := txsel.localAccountsDB.GetIdxByEthAddrBJJ(res.ethAddr, res.bjj,
+			res.tokenID); err == nil {
+			// the account was already created in a previous
+			// selection, the reservation is fulfilled
+			continue
+		}
+		if nL1UserTxs+len(l1CoordinatorTxs) >= int(selectionConfig.MaxL1Tx) {
+			// no space left for the L1CoordinatorTx, keep the
+			// reservation for the next selection
+			kept = append(kept, res)
+			continue
+		}
+		if expected := txsel.localAccountsDB.CurrentIdx() + 1; expected != res.idx {
+			log.Debugw("TxSelector: Idx reservation dropped, the promised Idx "+
+				"no longer matches", "promised", res.idx, "expected", expected)
+			continue
+		}
+		accAuth, err := txsel.l2db.GetAccountCreationAuth(res.ethAddr)
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		l1CoordinatorTx := common.L1Tx{
+			Position:      nL1UserTxs + len(l1CoordinatorTxs),
+			UserOrigin:    false,
+			FromEthAddr:   res.ethAddr,
+			FromBJJ:       res.bjj,
+			TokenID:       res.tokenID,
+			Amount:        big.NewInt(0),
+			DepositAmount: big.NewInt(0),
+			Type:          common.TxTypeCreateAccountDeposit,
+		}
+		_, _, _, _, err = tp.ProcessL1Tx(nil, &l1CoordinatorTx)
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		accAuths = append(accAuths, accAuth.Signature)
+		l1CoordinatorTxs = append(l1CoordinatorTxs, l1CoordinatorTx)
+		// keep the reservation until the batch is forged, so that it
+		// is re-created with the same Idx if the selection is Reset
+		kept = append(kept, res)
+	}
+	txsel.idxReservations = kept
+	return accAuths, l1CoordinatorTxs, nil
+}
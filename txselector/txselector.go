@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/big"
 	"sort"
+	"sync"
 
 	"github.com/arnaubennassar/hermez-node/common"
 	"github.com/arnaubennassar/hermez-node/db/kvdb"
@@ -17,6 +18,7 @@ import (
 	ethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/hermeznetwork/tracerr"
 	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-merkletree/db"
 )
 
 // CoordAccount contains the data of the Coordinator account, that will be used
@@ -34,7 +36,27 @@ type TxSelector struct {
 	l2db            *l2db.L2DB
 	localAccountsDB *statedb.LocalStateDB
 
-	coordAccount *CoordAccount
+	// coordAccount is the account where the Coordinator fees are
+	// collected.  It can be rotated at runtime (see SetCoordAccount), so
+	// it must always be accessed through the CoordAccount method
+	coordAccount   *CoordAccount
+	coordAccountMu sync.RWMutex
+
+	// feeIdxStrategy and feeTokenWhitelist define how the Coordinator fee
+	// collection idxs are chosen (see SetFeeIdxConfig)
+	feeIdxStrategy    FeeIdxStrategy
+	feeTokenWhitelist map[common.TokenID]bool
+
+	// exitFailures keeps track of how many consecutive selections the
+	// exit txs of each account have been discarded, used by the
+	// coordinator to detect accounts that can only exit through an L1
+	// ForceExit
+	exitFailures map[common.Idx]int
+
+	// idxReservations are the short-lived account Idx reservations served
+	// to wallets (see ReserveIdx)
+	idxReservations   []*idxReservation
+	idxReservationsMu sync.Mutex
 }
 
 // NewTxSelector returns a *TxSelector
@@ -56,9 +78,42 @@ func NewTxSelector(coordAccount *CoordAccount, dbpath string,
 		l2db:            l2,
 		localAccountsDB: localAccountsDB,
 		coordAccount:    coordAccount,
+		feeIdxStrategy:  FeeIdxStrategyAllTokens,
+		exitFailures:    make(map[common.Idx]int),
 	}, nil
 }
 
+// updateExitFailures updates the per-account count of consecutive selections
+// in which an exit tx has been discarded.  Selecting an exit of an account
+// resets its count
+func (txsel *TxSelector) updateExitFailures(selectedTxs, discardedTxs []common.PoolL2Tx) {
+	for i := range selectedTxs {
+		if selectedTxs[i].Type == common.TxTypeExit {
+			delete(txsel.exitFailures, selectedTxs[i].FromIdx)
+		}
+	}
+	for i := range discardedTxs {
+		if discardedTxs[i].Type == common.TxTypeExit {
+			txsel.exitFailures[discardedTxs[i].FromIdx]++
+		}
+	}
+}
+
+// StuckExitAccounts returns the accounts whose exit txs have been discarded in
+// at least threshold consecutive selections.  The count of the returned
+// accounts is reset so that each stuck account is only reported once per
+// streak of failures
+func (txsel *TxSelector) StuckExitAccounts(threshold int) []common.Idx {
+	var idxs []common.Idx
+	for idx, failures := range txsel.exitFailures {
+		if failures >= threshold {
+			idxs = append(idxs, idx)
+			delete(txsel.exitFailures, idx)
+		}
+	}
+	return idxs
+}
+
 // LocalAccountsDB returns the LocalStateDB of the TxSelector
 func (txsel *TxSelector) LocalAccountsDB() *statedb.LocalStateDB {
 	return txsel.localAccountsDB
@@ -70,9 +125,77 @@ func (txsel *TxSelector) Reset(batchNum common.BatchNum, fromSynchronizer bool)
 	return tracerr.Wrap(txsel.localAccountsDB.Reset(batchNum, fromSynchronizer))
 }
 
+// WarmStart revalidates all the pending pool txs against the local accounts
+// state, which must have been previously Reset to batchNum, marking as
+// invalid the ones that no longer apply: txs whose sender account doesn't
+// exist in the restored state, with a token different than the sender
+// account token, with an already used nonce or with an expired MaxNumBatch.
+// It is meant to be called once before the first selection after a node
+// restart, so that txs that became stale while the node was down are
+// invalidated instead of selected
+func (txsel *TxSelector) WarmStart(batchNum common.BatchNum) error {
+	if err := txsel.l2db.InvalidateExpiredMaxNumBatch(batchNum); err != nil {
+		return tracerr.Wrap(err)
+	}
+	poolTxs, err := txsel.l2db.GetPendingTxs()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	lastIdx, err := txsel.localAccountsDB.GetCurrentIdx()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	var invalidTxIDs []common.TxID
+	for i := range poolTxs {
+		tx := &poolTxs[i]
+		acc, err := txsel.localAccountsDB.GetAccount(tx.FromIdx)
+		if err != nil {
+			if tracerr.Unwrap(err) != db.ErrNotFound {
+				return tracerr.Wrap(err)
+			} else if tx.FromIdx <= lastIdx {
+				return tracerr.Wrap(fmt.Errorf("account with idx %v (lastIdx: %v) "+
+					"not found: %w", tx.FromIdx, lastIdx, err))
+			}
+			// The sender account doesn't exist in the restored
+			// state (e.g. its creation was rolled back by a reorg
+			// while the node was down)
+			invalidTxIDs = append(invalidTxIDs, tx.TxID)
+			continue
+		}
+		if tx.TokenID != acc.TokenID || tx.Nonce < acc.Nonce {
+			invalidTxIDs = append(invalidTxIDs, tx.TxID)
+		}
+	}
+	if len(invalidTxIDs) > 0 {
+		log.Infow("TxSelector: WarmStart invalidating stale pool txs",
+			"txs", len(invalidTxIDs), "batch", batchNum)
+		if err := txsel.l2db.InvalidateTxs(invalidTxIDs, batchNum); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// CoordAccount returns the Coordinator account currently used to collect the
+// fees of the selections
+func (txsel *TxSelector) CoordAccount() CoordAccount {
+	txsel.coordAccountMu.RLock()
+	defer txsel.coordAccountMu.RUnlock()
+	return *txsel.coordAccount
+}
+
+// SetCoordAccount replaces the Coordinator account used to collect the fees,
+// applying to the selections of the subsequent batches
+func (txsel *TxSelector) SetCoordAccount(coordAccount CoordAccount) {
+	txsel.coordAccountMu.Lock()
+	defer txsel.coordAccountMu.Unlock()
+	txsel.coordAccount = &coordAccount
+}
+
 func (txsel *TxSelector) getCoordIdx(tokenID common.TokenID) (common.Idx, error) {
-	return txsel.localAccountsDB.GetIdxByEthAddrBJJ(txsel.coordAccount.Addr,
-		txsel.coordAccount.BJJ, tokenID)
+	coordAccount := txsel.CoordAccount()
+	return txsel.localAccountsDB.GetIdxByEthAddrBJJ(coordAccount.Addr,
+		coordAccount.BJJ, tokenID)
 }
 
 // coordAccountForTokenID creates a new L1CoordinatorTx to create a new
@@ -84,9 +207,15 @@ func (txsel *TxSelector) getCoordIdx(tokenID common.TokenID) (common.Idx, error)
 // selection, positionL1 must be increased 1.
 func (txsel *TxSelector) coordAccountForTokenID(l1CoordinatorTxs []common.L1Tx,
 	tokenID common.TokenID, positionL1 int) (*common.L1Tx, int, error) {
+	// don't create accounts for tokens whose fees won't be collected by
+	// the configured strategy
+	if !txsel.feeTokenAllowed(tokenID) {
+		return nil, positionL1, nil
+	}
+	coordAccount := txsel.CoordAccount()
 	// check if CoordinatorAccount for TokenID is already pending to create
 	if checkPendingToCreateL1CoordTx(l1CoordinatorTxs, tokenID,
-		txsel.coordAccount.Addr, txsel.coordAccount.BJJ) {
+		coordAccount.Addr, coordAccount.BJJ) {
 		return nil, positionL1, nil
 	}
 	_, err := txsel.getCoordIdx(tokenID)
@@ -96,8 +225,8 @@ func (txsel *TxSelector) coordAccountForTokenID(l1CoordinatorTxs []common.L1Tx,
 		l1CoordinatorTx := common.L1Tx{
 			Position:      positionL1,
 			UserOrigin:    false,
-			FromEthAddr:   txsel.coordAccount.Addr,
-			FromBJJ:       txsel.coordAccount.BJJ,
+			FromEthAddr:   coordAccount.Addr,
+			FromBJJ:       coordAccount.BJJ,
 			TokenID:       tokenID,
 			Amount:        big.NewInt(0),
 			DepositAmount: big.NewInt(0),
@@ -203,6 +332,15 @@ func (txsel *TxSelector) getL1L2TxSelection(selectionConfig txprocessor.Config,
 		}
 	}
 
+	// Create and process the L1CoordinatorTxs of the active account Idx
+	// reservations (see ReserveIdx), placed right after the L1UserTxs so
+	// that the created accounts get the Idxs that were promised
+	accAuths, l1CoordinatorTxs, err := txsel.processIdxReservations(tp,
+		selectionConfig, len(l1UserTxs))
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
+	}
+
 	l2TxsFromDB, err := txsel.l2db.GetPendingTxs()
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
@@ -222,84 +360,83 @@ func (txsel *TxSelector) getL1L2TxSelection(selectionConfig txprocessor.Config,
 		}
 
 		metric.SelectedL1UserTxs.Set(float64(len(l1UserTxs)))
-		metric.SelectedL1CoordinatorTxs.Set(0)
+		metric.SelectedL1CoordinatorTxs.Set(float64(len(l1CoordinatorTxs)))
 		metric.SelectedL2Txs.Set(0)
 		metric.DiscardedL2Txs.Set(float64(len(discardedL2Txs)))
+		txsel.updateExitFailures(nil, discardedL2Txs)
 
-		return nil, nil, l1UserTxs, nil, nil, discardedL2Txs, nil
+		return nil, accAuths, l1UserTxs, l1CoordinatorTxs, nil, discardedL2Txs, nil
 	}
 
-	var accAuths [][]byte
-	var l1CoordinatorTxs []common.L1Tx
 	var validTxs, discardedL2Txs []common.PoolL2Tx
 	l2TxsForgable = sortL2Txs(l2TxsForgable)
-	accAuths, l1CoordinatorTxs, validTxs, discardedL2Txs, err =
-		txsel.processL2Txs(tp, selectionConfig, len(l1UserTxs), l1UserFutureTxs,
-			l2TxsForgable, validTxs, discardedL2Txs)
+	var accAuths2 [][]byte
+	var l1CoordinatorTxs2 []common.L1Tx
+	accAuths2, l1CoordinatorTxs2, validTxs, discardedL2Txs, err =
+		txsel.processL2Txs(tp, selectionConfig, len(l1UserTxs)+len(l1CoordinatorTxs),
+			l1UserFutureTxs, l2TxsForgable, validTxs, discardedL2Txs)
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
 	}
+	accAuths = append(accAuths, accAuths2...)
+	l1CoordinatorTxs = append(l1CoordinatorTxs, l1CoordinatorTxs2...)
 
 	// if there is space for more txs get also the NonForgable txs, that may
-	// be unblocked once the Forgable ones are processed
-	if len(validTxs) < int(selectionConfig.MaxTx)-(len(l1UserTxs)+len(l1CoordinatorTxs)) {
-		l2TxsNonForgable = sortL2Txs(l2TxsNonForgable)
+	// be unblocked once the Forgable ones are processed.  Queued txs (those
+	// with a Nonce ahead of the sender account Nonce) are retried until a
+	// pass stops unblocking new txs, so that a burst of txs with
+	// consecutive Nonces from the same account can be fully selected within
+	// a single batch.
+	for len(l2TxsNonForgable) > 0 &&
+		len(validTxs) < int(selectionConfig.MaxTx)-(len(l1UserTxs)+len(l1CoordinatorTxs)) {
+		var l2TxsUnblocked []common.PoolL2Tx
+		l2TxsUnblocked, l2TxsNonForgable = splitL2ForgableAndNonForgable(tp, l2TxsNonForgable)
+		if len(l2TxsUnblocked) == 0 {
+			break
+		}
+		l2TxsUnblocked = sortL2Txs(l2TxsUnblocked)
 		var accAuths2 [][]byte
 		var l1CoordinatorTxs2 []common.L1Tx
 		accAuths2, l1CoordinatorTxs2, validTxs, discardedL2Txs, err =
 			txsel.processL2Txs(tp, selectionConfig,
 				len(l1UserTxs)+len(l1CoordinatorTxs), l1UserFutureTxs,
-				l2TxsNonForgable, validTxs, discardedL2Txs)
+				l2TxsUnblocked, validTxs, discardedL2Txs)
 		if err != nil {
 			return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
 		}
 
 		accAuths = append(accAuths, accAuths2...)
 		l1CoordinatorTxs = append(l1CoordinatorTxs, l1CoordinatorTxs2...)
-	} else {
-		// if there is no space for NonForgable txs, put them at the
-		// discardedL2Txs array
+	}
+	// the txs that remain NonForgable stay pending in the pool with the
+	// Info set by the last split, unless the selection ran out of space
+	if len(l2TxsNonForgable) > 0 &&
+		len(validTxs) >= int(selectionConfig.MaxTx)-(len(l1UserTxs)+len(l1CoordinatorTxs)) {
 		for i := 0; i < len(l2TxsNonForgable); i++ {
 			l2TxsNonForgable[i].Info =
 				"Tx not selected due not available slots for L2Txs"
-			discardedL2Txs = append(discardedL2Txs, l2TxsNonForgable[i])
 		}
 	}
+	discardedL2Txs = append(discardedL2Txs, l2TxsNonForgable...)
 
-	// get CoordIdxsMap for the TokenIDs
-	coordIdxsMap := make(map[common.TokenID]common.Idx)
-	for i := 0; i < len(validTxs); i++ {
-		// get TokenID from tx.Sender
-		accSender, err := tp.StateDB().GetAccount(validTxs[i].FromIdx)
-		if err != nil {
-			return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
-		}
-		tokenID := accSender.TokenID
-
-		coordIdx, err := txsel.getCoordIdx(tokenID)
-		if err != nil {
-			// if err is db.ErrNotFound, should not happen, as all
-			// the validTxs.TokenID should have a CoordinatorIdx
-			// created in the DB at this point
-			return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
-		}
-		coordIdxsMap[tokenID] = coordIdx
+	// get the CoordIdxs for the TokenIDs of the validTxs, chosen with the
+	// configured strategy (see SetFeeIdxConfig)
+	coordIdxs, err := txsel.selectCoordIdxs(tp, selectionConfig, validTxs)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, tracerr.Wrap(err)
 	}
-
-	var coordIdxs []common.Idx
-	for _, idx := range coordIdxsMap {
-		coordIdxs = append(coordIdxs, idx)
+	selectedCoordIdxs := make(map[common.Idx]bool)
+	for _, idx := range coordIdxs {
+		selectedCoordIdxs[idx] = true
 	}
-	// sort CoordIdxs
-	sort.SliceStable(coordIdxs, func(i, j int) bool {
-		return coordIdxs[i] < coordIdxs[j]
-	})
 
 	// distribute the AccumulatedFees from the processed L2Txs into the
-	// Coordinator Idxs
+	// selected Coordinator Idxs.  Fees accumulated into idxs that didn't
+	// make it into the selection are not distributed, as ProcessTxs won't
+	// collect them when the batch is forged
 	for idx, accumulatedFee := range tp.AccumulatedFees {
 		cmp := accumulatedFee.Cmp(big.NewInt(0))
-		if cmp == 1 { // accumulatedFee>0
+		if cmp == 1 && selectedCoordIdxs[idx] { // accumulatedFee>0
 			// send the fee to the Idx of the Coordinator for the TokenID
 			accCoord, err := txsel.localAccountsDB.GetAccount(idx)
 			if err != nil {
@@ -325,6 +462,7 @@ func (txsel *TxSelector) getL1L2TxSelection(selectionConfig txprocessor.Config,
 	metric.SelectedL1UserTxs.Set(float64(len(l1UserTxs)))
 	metric.SelectedL2Txs.Set(float64(len(validTxs)))
 	metric.DiscardedL2Txs.Set(float64(len(discardedL2Txs)))
+	txsel.updateExitFailures(validTxs, discardedL2Txs)
 
 	return coordIdxs, accAuths, l1UserTxs, l1CoordinatorTxs, validTxs, discardedL2Txs, nil
 }
@@ -336,6 +474,8 @@ func (txsel *TxSelector) processL2Txs(tp *txprocessor.TxProcessor,
 	var l1CoordinatorTxs []common.L1Tx
 	positionL1 := nL1Txs
 	var accAuths [][]byte
+	// batch that is being selected
+	nextBatchNum := uint32(txsel.localAccountsDB.CurrentBatch()) + 1
 	// Iterate over l2Txs
 	// - check Nonces
 	// - check enough Balance for the Amount+Fee
@@ -356,6 +496,16 @@ func (txsel *TxSelector) processL2Txs(tp *txprocessor.TxProcessor,
 			break
 		}
 
+		// Discard tx if the batch that is being selected is greater
+		// than its MaxNumBatch.  The tx can't be forged anymore, the
+		// purger will invalidate it (see
+		// l2db.InvalidateExpiredMaxNumBatch)
+		if l2Txs[i].MaxNumBatch != 0 && nextBatchNum > l2Txs[i].MaxNumBatch {
+			l2Txs[i].Info = "Tx not selected due to MaxNumBatch exceeded"
+			discardedL2Txs = append(discardedL2Txs, l2Txs[i])
+			continue
+		}
+
 		// Discard exits with amount 0
 		if l2Txs[i].Type == common.TxTypeExit && l2Txs[i].Amount.Cmp(big.NewInt(0)) <= 0 {
 			l2Txs[i].Info = "Exits with amount 0 have no sense, not accepting to prevent unintended transactions"
@@ -421,7 +571,7 @@ func (txsel *TxSelector) processL2Txs(tp *txprocessor.TxProcessor,
 			// increase positionL1
 			positionL1++
 			l1CoordinatorTxs = append(l1CoordinatorTxs, *newL1CoordTx)
-			accAuths = append(accAuths, txsel.coordAccount.AccountCreationAuth)
+			accAuths = append(accAuths, txsel.CoordAccount().AccountCreationAuth)
 
 			// process the L1CoordTx
 			_, _, _, _, err := tp.ProcessL1Tx(nil, newL1CoordTx)
@@ -509,21 +659,24 @@ func (txsel *TxSelector) processL2Txs(tp *txprocessor.TxProcessor,
 		// get CoordIdxsMap for the TokenID of the current l2Txs[i]
 		// get TokenID from tx.Sender account
 		tokenID := accSender.TokenID
-		coordIdx, err := txsel.getCoordIdx(tokenID)
-		if err != nil {
-			// if err is db.ErrNotFound, should not happen, as all
-			// the validTxs.TokenID should have a CoordinatorIdx
-			// created in the DB at this point
-			return nil, nil, nil, nil,
-				tracerr.Wrap(fmt.Errorf("Could not get CoordIdx for TokenID=%d, "+
-					"due: %s", tokenID, err))
-		}
-		// prepare temp coordIdxsMap & AccumulatedFees for the call to
-		// ProcessL2Tx
-		coordIdxsMap := map[common.TokenID]common.Idx{tokenID: coordIdx}
-		// tp.AccumulatedFees = make(map[common.Idx]*big.Int)
-		if _, ok := tp.AccumulatedFees[coordIdx]; !ok {
-			tp.AccumulatedFees[coordIdx] = big.NewInt(0)
+		coordIdxsMap := make(map[common.TokenID]common.Idx)
+		if txsel.feeTokenAllowed(tokenID) {
+			coordIdx, err := txsel.getCoordIdx(tokenID)
+			if err != nil {
+				// if err is db.ErrNotFound, should not happen, as all
+				// the validTxs.TokenID should have a CoordinatorIdx
+				// created in the DB at this point
+				return nil, nil, nil, nil,
+					tracerr.Wrap(fmt.Errorf("Could not get CoordIdx for TokenID=%d, "+
+						"due: %s", tokenID, err))
+			}
+			// prepare temp coordIdxsMap & AccumulatedFees for the
+			// call to ProcessL2Tx
+			coordIdxsMap[tokenID] = coordIdx
+			// tp.AccumulatedFees = make(map[common.Idx]*big.Int)
+			if _, ok := tp.AccumulatedFees[coordIdx]; !ok {
+				tp.AccumulatedFees[coordIdx] = big.NewInt(0)
+			}
 		}
 
 		_, _, _, err = tp.ProcessL2Tx(coordIdxsMap, nil, nil, &l2Txs[i])
@@ -741,7 +894,15 @@ func splitL2ForgableAndNonForgable(tp *txprocessor.TxProcessor,
 			continue
 		}
 
-		if l2Txs[i].Nonce != accSender.Nonce {
+		if l2Txs[i].Nonce > accSender.Nonce {
+			// tx queued: it will become forgable once the txs that
+			// fill the nonce gap are selected
+			l2Txs[i].Info = fmt.Sprintf("Tx queued, tx nonce %d is ahead of account sender nonce %d",
+				l2Txs[i].Nonce, accSender.Nonce)
+			l2TxsNonForgable = append(l2TxsNonForgable, l2Txs[i])
+			continue
+		}
+		if l2Txs[i].Nonce < accSender.Nonce {
 			l2Txs[i].Info = fmt.Sprintf("Tx not selected due to wrong nonce, tx nonce %d, account sender nonce %d",
 				l2Txs[i].Nonce, accSender.Nonce)
 			l2TxsNonForgable = append(l2TxsNonForgable, l2Txs[i])
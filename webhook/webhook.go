@@ -0,0 +1,387 @@
+/*
+Package webhook implements the account activity webhook notifier.
+
+Clients register a URL, a secret and an optional filter (ethereum address,
+BJJ public key, account index and/or event types) through the operator-only
+API endpoints, and the notifier POSTs a signed JSON notification to the URL
+every time a matching event happens: a tx involving the account is forged, an
+exit becomes withdrawable or a pool tx is invalidated.
+
+Following the design of the rest of the node, the notifier doesn't receive
+the events from the synchronizer or the coordinator directly: it polls the
+SQL database (the tx and exit_tree tables through the HistoryDB and the
+tx_pool_audit trail through the L2DB), so it works the same no matter which
+process produced the event.  Deliveries are retried with exponential backoff,
+and each request carries an HMAC-SHA256 signature of the body computed with
+the subscription secret so that receivers can authenticate the origin.
+*/
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arnaubennassar/hermez-node/common"
+	"github.com/arnaubennassar/hermez-node/db/historydb"
+	"github.com/arnaubennassar/hermez-node/db/l2db"
+	"github.com/arnaubennassar/hermez-node/log"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hermeznetwork/tracerr"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// Event types that can be subscribed to
+const (
+	// EventTxForged notifies a tx involving the account forged in a batch
+	EventTxForged = "tx-forged"
+	// EventExitWithdrawable notifies an exit of the account included in
+	// the exit tree of a forged batch
+	EventExitWithdrawable = "exit-withdrawable"
+	// EventPoolTxInvalidated notifies a pool tx of the account marked as
+	// invalid
+	EventPoolTxInvalidated = "pool-tx-invalidated"
+)
+
+// SignatureHeader is the header carrying the hex encoded HMAC-SHA256
+// signature of the notification body, computed with the subscription secret
+const SignatureHeader = "X-Hermez-Webhook-Signature"
+
+// ValidEventType returns true if eventType is one of the event types that
+// can be subscribed to
+func ValidEventType(eventType string) bool {
+	switch eventType {
+	case EventTxForged, EventExitWithdrawable, EventPoolTxInvalidated:
+		return true
+	}
+	return false
+}
+
+const (
+	defaultTimeout       = 5 * time.Second
+	defaultMaxAttempts   = 3
+	defaultRetryInterval = 1 * time.Second
+)
+
+// Config is the configuration of the webhook Notifier
+type Config struct {
+	// Interval between scans of the database looking for new events
+	Interval time.Duration
+	// Timeout of each delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is the number of delivery attempts per notification
+	// before it's dropped
+	MaxAttempts int
+	// RetryInterval is the wait after a failed delivery attempt, doubled
+	// on each retry
+	RetryInterval time.Duration
+}
+
+// Notifier polls the database for new account activity and delivers the
+// matching webhook notifications
+type Notifier struct {
+	hdb    *historydb.HistoryDB
+	l2db   *l2db.L2DB
+	cfg    Config
+	client *http.Client
+	// lastBatchNum is the last batch whose forged txs and exits have been
+	// notified
+	lastBatchNum common.BatchNum
+	// lastAuditItem is the last tx_pool_audit item whose invalidation has
+	// been notified
+	lastAuditItem uint64
+	initialized   bool
+}
+
+// NewNotifier creates a Notifier that polls the given databases.  l2DB can be
+// nil when the node doesn't run a tx pool, disabling the pool-tx-invalidated
+// events
+func NewNotifier(hdb *historydb.HistoryDB, l2DB *l2db.L2DB, cfg Config) *Notifier {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+	return &Notifier{
+		hdb:    hdb,
+		l2db:   l2DB,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Run starts the notifier loop, polling the database every Interval until the
+// context is canceled.  Only events that happen after the first iteration are
+// notified, so restarting the node doesn't replay old activity.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Webhook notifier done")
+			return
+		case <-time.After(n.cfg.Interval):
+			if err := n.iteration(ctx); err != nil {
+				log.Errorw("Webhook notifier iteration", "err", err)
+			}
+		}
+	}
+}
+
+// init sets the cursors to the current end of the database, so that only new
+// events are notified
+func (n *Notifier) init() error {
+	batchNum, err := n.hdb.GetLastBatchNum()
+	if err != nil && tracerr.Unwrap(err) != sql.ErrNoRows {
+		return tracerr.Wrap(err)
+	}
+	n.lastBatchNum = batchNum
+	if n.l2db != nil {
+		auditItem, err := n.l2db.GetLastTxAuditItemID()
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		n.lastAuditItem = auditItem
+	}
+	n.initialized = true
+	return nil
+}
+
+// notification is the JSON body POSTed to the subscription URLs
+type notification struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// event is a notification together with the accounts it involves, used to
+// match the subscription filters
+type event struct {
+	notification notification
+	idxs         []common.Idx
+	ethAddrs     []ethCommon.Address
+	bjjs         []babyjub.PublicKeyComp
+}
+
+func (n *Notifier) iteration(ctx context.Context) error {
+	if !n.initialized {
+		return n.init()
+	}
+	subs, err := n.hdb.GetWebhookSubscriptions()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	events, err := n.pollEvents()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	for _, ev := range events {
+		for i := range subs {
+			if !matches(&subs[i], &ev) {
+				continue
+			}
+			if err := n.deliver(ctx, &subs[i], &ev.notification); err != nil {
+				log.Warnw("Webhook delivery dropped",
+					"url", subs[i].URL, "type", ev.notification.Type, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// pollEvents reads the events that happened since the last iteration,
+// advancing the cursors
+func (n *Notifier) pollEvents() ([]event, error) {
+	var events []event
+	now := time.Now().UTC()
+	fromBatchNum := n.lastBatchNum
+	txs, err := n.hdb.GetWebhookForgedTxs(fromBatchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	for i := range txs {
+		tx := txs[i]
+		ev := event{notification: notification{
+			Type:      EventTxForged,
+			Timestamp: now,
+			Data:      tx,
+		}}
+		ev.idxs = append(ev.idxs, tx.ToIdx)
+		if tx.FromIdx != nil {
+			ev.idxs = append(ev.idxs, *tx.FromIdx)
+		}
+		if tx.FromEthAddr != nil {
+			ev.ethAddrs = append(ev.ethAddrs, *tx.FromEthAddr)
+		}
+		if tx.ToEthAddr != nil {
+			ev.ethAddrs = append(ev.ethAddrs, *tx.ToEthAddr)
+		}
+		if tx.FromBJJ != nil {
+			ev.bjjs = append(ev.bjjs, *tx.FromBJJ)
+		}
+		if tx.ToBJJ != nil {
+			ev.bjjs = append(ev.bjjs, *tx.ToBJJ)
+		}
+		events = append(events, ev)
+		if tx.BatchNum > n.lastBatchNum {
+			n.lastBatchNum = tx.BatchNum
+		}
+	}
+	exits, err := n.hdb.GetWebhookExits(fromBatchNum)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	for i := range exits {
+		exit := exits[i]
+		events = append(events, event{
+			notification: notification{
+				Type:      EventExitWithdrawable,
+				Timestamp: now,
+				Data:      exit,
+			},
+			idxs: []common.Idx{exit.AccountIdx},
+		})
+		if exit.BatchNum > n.lastBatchNum {
+			n.lastBatchNum = exit.BatchNum
+		}
+	}
+	if n.l2db != nil {
+		invalidated, err := n.l2db.GetWebhookInvalidatedTxs(n.lastAuditItem)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		for i := range invalidated {
+			tx := invalidated[i]
+			events = append(events, event{
+				notification: notification{
+					Type:      EventPoolTxInvalidated,
+					Timestamp: now,
+					Data:      tx,
+				},
+				idxs: []common.Idx{tx.FromIdx},
+			})
+			if tx.ItemID > n.lastAuditItem {
+				n.lastAuditItem = tx.ItemID
+			}
+		}
+	}
+	return events, nil
+}
+
+// matches returns true if the event passes the filter of the subscription:
+// the event type is subscribed and every filter field that is set matches
+// one of the accounts involved in the event
+func matches(sub *historydb.WebhookSubscription, ev *event) bool {
+	subscribed := false
+	for _, eventType := range strings.Split(sub.EventTypes, ",") {
+		if eventType == ev.notification.Type {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return false
+	}
+	if sub.Idx != nil {
+		found := false
+		for _, idx := range ev.idxs {
+			if idx == *sub.Idx {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if sub.EthAddr != nil {
+		found := false
+		for _, addr := range ev.ethAddrs {
+			if addr == *sub.EthAddr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if sub.BJJ != nil {
+		found := false
+		for _, bjj := range ev.bjjs {
+			if bjj == *sub.BJJ {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver POSTs the notification to the subscription URL, signing the body
+// with the subscription secret and retrying with exponential backoff until it
+// succeeds or MaxAttempts is reached
+func (n *Notifier) deliver(ctx context.Context, sub *historydb.WebhookSubscription,
+	notif *notification) error {
+	body, err := json.Marshal(notif)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	mac := hmac.New(sha256.New, []byte(sub.Secret))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	retryInterval := n.cfg.RetryInterval
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, sub.URL, body, signature)
+		if err == nil {
+			return nil
+		}
+		if attempt >= n.cfg.MaxAttempts {
+			return tracerr.Wrap(err)
+		}
+		log.Debugw("Webhook delivery attempt failed, retrying",
+			"url", sub.URL, "attempt", attempt, "err", err)
+		select {
+		case <-ctx.Done():
+			return tracerr.Wrap(ctx.Err())
+		case <-time.After(retryInterval):
+		}
+		retryInterval *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return tracerr.Wrap(fmt.Errorf("webhook POST %v: status %v", url, res.StatusCode))
+	}
+	return nil
+}